@@ -0,0 +1,310 @@
+//go:build cshared
+
+// Package main builds libdogfetch, a C shared library exposing the fetcher to
+// other languages. Build it with:
+//
+//	go build -tags cshared -buildmode=c-shared -o libdogfetch.so ./bindings/libdogfetch
+package main
+
+/*
+#include <stdlib.h>
+
+// dogfetch_page_cb receives one page of logs as NDJSON; returning nonzero
+// stops the fetch
+typedef int (*dogfetch_page_cb)(const char *ndjson, size_t len, void *userdata);
+
+// dogfetch_event_cb receives each progress update and diagnostic as a JSON
+// object
+typedef void (*dogfetch_event_cb)(const char *json, size_t len, void *userdata);
+
+static int call_page_cb(dogfetch_page_cb cb, const char *ndjson, size_t len, void *userdata) {
+	return cb(ndjson, len, userdata);
+}
+
+static void call_event_cb(dogfetch_event_cb cb, const char *json, size_t len, void *userdata) {
+	cb(json, len, userdata);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unsafe"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// errStopped is returned by the callback writer when the caller asks to stop
+var errStopped = errors.New("stopped by callback")
+
+// fetchOptions is the JSON configuration accepted by dogfetch_fetch
+type fetchOptions struct {
+	Query      string `json:"query"`
+	Index      string `json:"index"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Method     string `json:"method"`
+	Window     string `json:"window"`
+	Plan       string `json:"plan"`
+	WindowLogs int64  `json:"window_logs"`
+	PageSize   int32  `json:"page_size"`
+	APIURL     string `json:"api_url"`
+	APIKey     string `json:"api_key"`
+	AppKey     string `json:"app_key"`
+	Site       string `json:"site"`
+	Verbose    bool   `json:"verbose"`
+	Demo       int    `json:"demo"`
+}
+
+// config converts the options to a validated fetch config, falling back to
+// the same environment variables as the CLI
+func (o fetchOptions) config() (*config.Config, error) {
+	cfg := &config.Config{
+		Query:      o.Query,
+		Index:      o.Index,
+		Method:     o.Method,
+		Plan:       o.Plan,
+		WindowLogs: o.WindowLogs,
+		PageSize:   o.PageSize,
+		Format:     "ndjson",
+		APIKey:     firstNonEmpty(o.APIKey, os.Getenv("DD_API_KEY")),
+		AppKey:     firstNonEmpty(o.AppKey, os.Getenv("DD_APP_KEY")),
+		Site:       firstNonEmpty(o.Site, os.Getenv("DD_SITE")),
+		APIURL:     o.APIURL,
+		Demo:       o.Demo,
+		From:       config.DefaultFrom(),
+	}
+	if cfg.Index == "" {
+		cfg.Index = "main"
+	}
+	if cfg.PageSize == 0 {
+		cfg.PageSize = 1000
+	}
+	if cfg.WindowLogs == 0 {
+		cfg.WindowLogs = 1000000
+	}
+
+	site, err := config.ParseSite(cfg.Site)
+	if err != nil {
+		return nil, fmt.Errorf("invalid site: %w", err)
+	}
+	cfg.Site = site
+
+	if o.From != "" {
+		t, err := config.ParseTime(o.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %w", err)
+		}
+		cfg.From = t
+	}
+	if o.To != "" {
+		t, err := config.ParseTime(o.To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %w", err)
+		}
+		cfg.To = t
+	}
+
+	if err := cfg.SetWindow(o.Window, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// callbackWriter hands each page to a C callback as NDJSON
+type callbackWriter struct {
+	cb       C.dogfetch_page_cb
+	userdata unsafe.Pointer
+	buf      bytes.Buffer
+}
+
+// WritePage encodes the page and calls the callback once
+func (w *callbackWriter) WritePage(logs []datadogV2.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	w.buf.Reset()
+	enc := json.NewEncoder(&w.buf)
+	for _, log := range logs {
+		if err := enc.Encode(log); err != nil {
+			return err
+		}
+	}
+
+	data := C.CBytes(w.buf.Bytes())
+	defer C.free(data)
+	if C.call_page_cb(w.cb, (*C.char)(data), C.size_t(w.buf.Len()), w.userdata) != 0 {
+		return errStopped
+	}
+	return nil
+}
+
+// Finalize is a no-op for callbackWriter (already delivered)
+func (w *callbackWriter) Finalize() error {
+	return nil
+}
+
+// Close is a no-op for callbackWriter
+func (w *callbackWriter) Close() error {
+	return nil
+}
+
+// eventReporter hands progress and diagnostics to a C callback as JSON
+type eventReporter struct {
+	cb       C.dogfetch_event_cb
+	userdata unsafe.Pointer
+}
+
+// progressEvent is the JSON form of fetcher.Progress
+type progressEvent struct {
+	Type       string  `json:"type"`
+	Fetched    int     `json:"fetched"`
+	Written    int     `json:"written"`
+	Pages      int     `json:"pages"`
+	ElapsedMS  int64   `json:"elapsed_ms"`
+	LogsPerSec float64 `json:"logs_per_sec"`
+	Cursor     string  `json:"cursor,omitempty"`
+	Done       bool    `json:"done"`
+}
+
+// diagnosticEvent is the JSON form of fetcher.Diagnostic
+type diagnosticEvent struct {
+	Type        string       `json:"type"`
+	Kind        string       `json:"kind"`
+	Message     string       `json:"message"`
+	Error       string       `json:"error,omitempty"`
+	Attempt     int          `json:"attempt,omitempty"`
+	MaxAttempts int          `json:"max_attempts,omitempty"`
+	BackoffMS   int64        `json:"backoff_ms,omitempty"`
+	Cursor      string       `json:"cursor,omitempty"`
+	Window      *plan.Window `json:"window,omitempty"`
+}
+
+func (r *eventReporter) Progress(p fetcher.Progress) {
+	r.send(progressEvent{
+		Type:       "progress",
+		Fetched:    p.Fetched,
+		Written:    p.Written,
+		Pages:      p.Pages,
+		ElapsedMS:  p.Elapsed.Milliseconds(),
+		LogsPerSec: p.Rate(),
+		Cursor:     p.Cursor,
+		Done:       p.Done,
+	})
+}
+
+func (r *eventReporter) Diagnostic(d fetcher.Diagnostic) {
+	e := diagnosticEvent{
+		Type:        "diagnostic",
+		Kind:        string(d.Kind),
+		Message:     d.Message,
+		Attempt:     d.Attempt,
+		MaxAttempts: d.MaxAttempts,
+		BackoffMS:   d.Backoff.Milliseconds(),
+		Cursor:      d.Cursor,
+		Window:      d.Window,
+	}
+	if d.Err != nil {
+		e.Error = d.Err.Error()
+	}
+	r.send(e)
+}
+
+func (r *eventReporter) send(event interface{}) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	cdata := C.CBytes(data)
+	defer C.free(cdata)
+	C.call_event_cb(r.cb, (*C.char)(cdata), C.size_t(len(data)), r.userdata)
+}
+
+// dogfetch_fetch runs a fetch described by configJSON, calling cb with each
+// page. It returns NULL on success or an error message the caller must
+// release with dogfetch_free.
+//
+//export dogfetch_fetch
+func dogfetch_fetch(configJSON *C.char, cb C.dogfetch_page_cb, userdata unsafe.Pointer) *C.char {
+	return fetch(configJSON, cb, nil, userdata)
+}
+
+// dogfetch_fetch_events is dogfetch_fetch with progress and diagnostics
+// delivered to events as JSON objects instead of text on stderr; their
+// "type" is "progress" or "diagnostic".
+//
+//export dogfetch_fetch_events
+func dogfetch_fetch_events(configJSON *C.char, cb C.dogfetch_page_cb, events C.dogfetch_event_cb, userdata unsafe.Pointer) *C.char {
+	if events == nil {
+		return C.CString("event callback is required")
+	}
+	return fetch(configJSON, cb, events, userdata)
+}
+
+func fetch(configJSON *C.char, cb C.dogfetch_page_cb, events C.dogfetch_event_cb, userdata unsafe.Pointer) *C.char {
+	if cb == nil {
+		return C.CString("callback is required")
+	}
+
+	var opts fetchOptions
+	if err := json.Unmarshal([]byte(C.GoString(configJSON)), &opts); err != nil {
+		return C.CString(fmt.Sprintf("invalid config: %v", err))
+	}
+	cfg, err := opts.config()
+	if err != nil {
+		return C.CString(err.Error())
+	}
+
+	var errOut io.Writer = io.Discard
+	if opts.Verbose {
+		errOut = os.Stderr
+	}
+
+	f, err := fetcher.NewWithWriter(cfg, &callbackWriter{cb: cb, userdata: userdata}, errOut)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	if events != nil {
+		r := &eventReporter{cb: events, userdata: userdata}
+		f.SetProgressReporter(r)
+		f.SetDiagnosticReporter(r)
+	}
+	if err := f.Fetch(context.Background()); err != nil && !errors.Is(err, errStopped) {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// dogfetch_free releases a string returned by dogfetch_fetch or
+// dogfetch_fetch_events
+//
+//export dogfetch_free
+func dogfetch_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func main() {}
@@ -0,0 +1,189 @@
+"""Python bindings for libdogfetch.
+
+Build the shared library first:
+
+    make lib
+
+then point DOGFETCH_LIB at it (or keep libdogfetch.so next to this file):
+
+    import dogfetch
+    df = dogfetch.to_dataframe("service:web status:error", from_="2024-01-01T00:00:00Z")
+"""
+
+import ctypes
+import json
+import os
+import queue
+import sys
+import threading
+
+PAGE_CB = ctypes.CFUNCTYPE(
+    ctypes.c_int, ctypes.POINTER(ctypes.c_char), ctypes.c_size_t, ctypes.c_void_p
+)
+EVENT_CB = ctypes.CFUNCTYPE(
+    None, ctypes.POINTER(ctypes.c_char), ctypes.c_size_t, ctypes.c_void_p
+)
+
+
+def _default_lib_path():
+    name = {"darwin": "libdogfetch.dylib", "win32": "dogfetch.dll"}.get(
+        sys.platform, "libdogfetch.so"
+    )
+    return os.path.join(os.path.dirname(os.path.abspath(__file__)), name)
+
+
+_lib = None
+
+
+def _load():
+    global _lib
+    if _lib is None:
+        lib = ctypes.CDLL(os.environ.get("DOGFETCH_LIB", _default_lib_path()))
+        # Errors are returned as Go-allocated strings, so keep them as raw
+        # pointers until they have been copied and freed
+        lib.dogfetch_fetch.argtypes = [ctypes.c_char_p, PAGE_CB, ctypes.c_void_p]
+        lib.dogfetch_fetch.restype = ctypes.c_void_p
+        lib.dogfetch_fetch_events.argtypes = [ctypes.c_char_p, PAGE_CB, EVENT_CB, ctypes.c_void_p]
+        lib.dogfetch_fetch_events.restype = ctypes.c_void_p
+        lib.dogfetch_free.argtypes = [ctypes.c_void_p]
+        lib.dogfetch_free.restype = None
+        _lib = lib
+    return _lib
+
+
+class DogfetchError(Exception):
+    """Raised when a fetch fails."""
+
+
+class _Stop(Exception):
+    pass
+
+
+def fetch(on_page, query, index="main", from_=None, to=None, page_size=1000,
+          api_url=None, api_key=None, app_key=None, site=None, verbose=False,
+          on_progress=None, on_diagnostic=None, method="get", window="auto",
+          plan="slices", window_logs=1000000, demo=0):
+    """Fetch logs, calling on_page(records) with each page as a list of dicts.
+
+    Returning True from on_page stops the fetch early. Credentials fall back
+    to DD_API_KEY, DD_APP_KEY and DD_SITE like the CLI. method="post" uses the
+    Logs Search POST endpoint, for queries too long for a URL. window splits
+    the range into windows fetched oldest first: a duration such as "6h",
+    "off", or "auto" for 6h windows over ranges longer than a week.
+    plan="balanced" sizes windows to hold about window_logs logs each, using
+    counts from the aggregation API, with window capping their length.
+    demo=N fetches N synthetic logs generated locally instead of calling
+    Datadog, so no credentials are needed; it can't be split into windows.
+
+    on_progress(event) receives a dict with fetched, written, pages,
+    elapsed_ms, logs_per_sec, cursor and done after every page.
+    on_diagnostic(event) receives a dict with kind ("start", "retry",
+    "cancelled" or "window") and message, plus error, attempt, max_attempts
+    and backoff_ms for retries and the window's from and to for windows.
+    Passing either replaces the text that verbose prints to stderr.
+    """
+    options = {
+        "query": query,
+        "index": index,
+        "from": from_ or "",
+        "to": to or "",
+        "method": method,
+        "window": window,
+        "plan": plan,
+        "window_logs": window_logs,
+        "page_size": page_size,
+        "api_url": api_url or "",
+        "api_key": api_key or "",
+        "app_key": app_key or "",
+        "site": site or "",
+        "verbose": verbose,
+        "demo": demo,
+    }
+
+    failure = []
+
+    def callback(data, size, _userdata):
+        try:
+            lines = ctypes.string_at(data, size).decode("utf-8").splitlines()
+            return 1 if on_page([json.loads(line) for line in lines]) else 0
+        except BaseException as exc:  # surfaced after the fetch returns
+            failure.append(exc)
+            return 1
+
+    def event_callback(data, size, _userdata):
+        try:
+            event = json.loads(ctypes.string_at(data, size).decode("utf-8"))
+            handler = on_progress if event.pop("type") == "progress" else on_diagnostic
+            if handler is not None:
+                handler(event)
+        except BaseException as exc:  # surfaced after the fetch returns
+            failure.append(exc)
+
+    lib = _load()
+    config = json.dumps(options).encode("utf-8")
+    if on_progress is None and on_diagnostic is None:
+        err = lib.dogfetch_fetch(config, PAGE_CB(callback), None)
+    else:
+        err = lib.dogfetch_fetch_events(config, PAGE_CB(callback), EVENT_CB(event_callback), None)
+    if err:
+        message = ctypes.string_at(err).decode("utf-8")
+        lib.dogfetch_free(err)
+        raise DogfetchError(message)
+    if failure:
+        raise failure[0]
+
+
+def iter_records(query, **kwargs):
+    """Yield records one at a time while the fetch runs in the background."""
+    pages = queue.Queue(maxsize=4)
+    stop = threading.Event()
+    done = object()
+
+    def on_page(records):
+        pages.put(records)
+        return stop.is_set()
+
+    def run():
+        try:
+            fetch(on_page, query, **kwargs)
+            pages.put(done)
+        except BaseException as exc:
+            pages.put(exc)
+
+    worker = threading.Thread(target=run, daemon=True)
+    worker.start()
+    try:
+        while True:
+            item = pages.get()
+            if item is done:
+                return
+            if isinstance(item, BaseException):
+                raise item
+            yield from item
+    finally:
+        # Let a fetch abandoned mid-way finish its current page and stop
+        stop.set()
+        while worker.is_alive():
+            try:
+                pages.get(timeout=0.1)
+            except queue.Empty:
+                pass
+
+
+def to_dataframe(query, **kwargs):
+    """Fetch logs into a pandas DataFrame with one row per log."""
+    import pandas as pd
+
+    rows = []
+    fetch(lambda records: rows.extend(_flatten(r) for r in records), query, **kwargs)
+    return pd.json_normalize(rows)
+
+
+def _flatten(record):
+    """Lift the Datadog attributes envelope so columns are e.g. 'service'."""
+    attributes = dict(record.get("attributes") or {})
+    nested = attributes.pop("attributes", None) or {}
+    row = {"id": record.get("id")}
+    row.update(attributes)
+    row.update(nested)
+    return row
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jtzemp/dogfetch/internal/attach"
+)
+
+// attachExport uploads the compressed export to the --attach-to ticket
+func attachExport(errOut io.Writer, target attach.Target, path, maxSize string, limit int64) error {
+	file, err := attach.Prepare(path, limit)
+	if errors.Is(err, attach.ErrTooLarge) {
+		return fmt.Errorf("the compressed export is over --attach-max-size %s", maxSize)
+	}
+	if err != nil {
+		return err
+	}
+	if err := attach.Upload(context.Background(), http.DefaultClient, target, file); err != nil {
+		return err
+	}
+	fmt.Fprintf(errOut, "Attached %s (%d bytes) to %s %s\n", file.Name, len(file.Body), target.System, target.Key)
+	return nil
+}
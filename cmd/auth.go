@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jtzemp/dogfetch/internal/auth"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+)
+
+// runAuth dispatches the auth subcommands; check is the only one
+func runAuth(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintf(os.Stderr, "Usage: dogfetch auth check [options]\n")
+		return exitError
+	}
+	return runAuthCheck("auth check", args[1:])
+}
+
+// runWhoami is auth check under the name people reach for
+func runWhoami(args []string) int {
+	return runAuthCheck("whoami", args)
+}
+
+// runAuthCheck checks the keys in the environment before a long fetch finds
+// out they can't read logs
+func runAuthCheck(name string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	index := fs.String("index", "main", "Index to check the keys can read logs from")
+	apiURL := fs.String("api-url", "", "Override the Datadog API URL")
+	siteFlag := fs.String("site", "", "Datadog site, e.g. datadoghq.eu or us5 (default: DD_SITE, else datadoghq.com)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch %s - Check DD_API_KEY and DD_APP_KEY can read logs\n\n", name)
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch %s [--index main]\n\n", name)
+		fmt.Fprintf(os.Stderr, "Validates the API key, looks up who owns the application key and which\n")
+		fmt.Fprintf(os.Stderr, "organization the keys belong to, and searches --index for a log to confirm\n")
+		fmt.Fprintf(os.Stderr, "logs_read_data is granted. Exits 1 if any check fails.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	apiKey, appKey := os.Getenv("DD_API_KEY"), os.Getenv("DD_APP_KEY")
+	if apiKey == "" || appKey == "" {
+		fmt.Fprintf(os.Stderr, "DD_API_KEY and DD_APP_KEY must both be set\n")
+		return exitError
+	}
+
+	site, err := resolveSite(*siteFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	server := *apiURL
+	if server == "" {
+		if site == "" {
+			site = "datadoghq.com"
+		}
+		server = "https://api." + site
+	}
+
+	var opts []fetcher.ClientOption
+	if *apiURL != "" {
+		opts = append(opts, fetcher.WithBaseURL(*apiURL))
+	}
+	client := fetcher.NewClient(apiKey, appKey, site, opts...)
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	fmt.Printf("Checking keys against %s\n", server)
+	report := auth.Check(ctx, client, *index)
+	report.Write(os.Stdout)
+	if !report.OK() {
+		return exitError
+	}
+	return exitOK
+}
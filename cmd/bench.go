@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/benchmark"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// errBenchDone stops a benchmark fetch once it has its share of logs
+var errBenchDone = errors.New("benchmark fetch done")
+
+// runBench times bounded fetches of a query at several page sizes and
+// concurrency levels, to choose settings from measurements
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	ff := addFetchFlags(fs)
+	pageSizes := fs.String("page-sizes", "1000,2000,5000", "Comma-separated page sizes to try (max 5000 each)")
+	concurrency := fs.String("concurrency", "1,2", "Comma-separated numbers of fetches to run at once, each over its own slice of the range")
+	logs := fs.Int("logs", 10000, "Logs to fetch per case, shared between concurrent fetches")
+	output := fs.String("output", "", "Also save the results as JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch bench - Measure fetch throughput at several page sizes and concurrency levels\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch bench --query 'service:web' --from 2024-01-01T00:00:00Z --to 2024-01-02T00:00:00Z\n\n")
+		fmt.Fprintf(os.Stderr, "Each case fetches up to --logs logs of --query and discards them. With a\n")
+		fmt.Fprintf(os.Stderr, "concurrency of N, the range is cut into N adjacent slices fetched at once, as N\n")
+		fmt.Fprintf(os.Stderr, "dogfetch processes given those --from and --to ranges would. Every case calls the\n")
+		fmt.Fprintf(os.Stderr, "API, so mind its rate limits; --pageSize is ignored in favor of --page-sizes.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	sizes, err := parseIntList(*pageSizes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --page-sizes: %v\n", err)
+		return exitError
+	}
+	for _, size := range sizes {
+		if size > 5000 {
+			fmt.Fprintf(os.Stderr, "Invalid --page-sizes: %d is above the API's maximum of 5000\n", size)
+			return exitError
+		}
+	}
+	levels, err := parseIntList(*concurrency)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --concurrency: %v\n", err)
+		return exitError
+	}
+	if *logs < 1 {
+		fmt.Fprintf(os.Stderr, "--logs must be positive\n")
+		return exitError
+	}
+
+	cfg, err := ff.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	if cfg.Query == "" {
+		cfg.Query = "*"
+	}
+	// Slicing the range needs an end
+	if cfg.To.IsZero() {
+		cfg.To = time.Now().UTC()
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return exitError
+	}
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	// Fixed columns, so each case can be printed as soon as it finishes
+	row := "%9v  %11v  %8v  %6v  %8v  %8v  %7v  %12v\n"
+	fmt.Fprintf(os.Stdout, row, "page size", "concurrency", "logs", "pages", "seconds", "logs/s", "retries", "rate limited")
+
+	var results []benchmark.FetchResult
+	for _, c := range benchmark.FetchCases(sizes, levels) {
+		if ctx.Err() != nil {
+			break
+		}
+		r, err := benchFetch(ctx, *cfg, c, *logs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Benchmark failed at page size %d, concurrency %d: %v\n", c.PageSize, c.Concurrency, err)
+			return exitError
+		}
+		results = append(results, r)
+		fmt.Fprintf(os.Stdout, row, r.PageSize, r.Concurrency, r.Logs, r.Pages, fmt.Sprintf("%.1f", r.Seconds), fmt.Sprintf("%.0f", r.LogsPerSec), r.Retries, r.RateLimited)
+	}
+
+	if *output != "" {
+		if err := benchmark.WriteFetchFile(*output, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save results: %v\n", err)
+			return exitError
+		}
+	}
+	if best, ok := benchmark.Best(results); ok {
+		fmt.Fprintf(os.Stderr, "\nFastest: --pageSize %d with %d at once (%.0f logs/s)\n", best.PageSize, best.Concurrency, best.LogsPerSec)
+		if best.RateLimited > 0 {
+			fmt.Fprintf(os.Stderr, "It was rate limited %d times; a long export at this setting may spend more time waiting\n", best.RateLimited)
+		}
+	}
+	if ctx.Err() != nil {
+		return exitError
+	}
+	return exitOK
+}
+
+// benchFetch runs one case: c.Concurrency fetches of adjacent slices of the
+// range, together stopping after about limit logs
+func benchFetch(ctx context.Context, cfg config.Config, c benchmark.FetchCase, limit int) (benchmark.FetchResult, error) {
+	windows, err := benchmark.SplitRange(cfg.From, cfg.To, c.Concurrency)
+	if err != nil {
+		return benchmark.FetchResult{}, err
+	}
+	cfg.PageSize = int32(c.PageSize)
+	share := (limit + c.Concurrency - 1) / c.Concurrency
+
+	var (
+		mu                                sync.Mutex
+		logs, pages, retries, rateLimited int
+		firstErr                          error
+		wg                                sync.WaitGroup
+	)
+	started := time.Now()
+	for _, w := range windows {
+		wg.Add(1)
+		go func(w plan.Window) {
+			defer wg.Done()
+			n, p, status, err := benchWindow(ctx, cfg, w, share)
+			mu.Lock()
+			defer mu.Unlock()
+			logs += n
+			pages += p
+			retries += status.Retries
+			rateLimited += status.RateLimited
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(w)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return benchmark.FetchResult{}, firstErr
+	}
+	return benchmark.NewFetchResult(c, logs, pages, retries, rateLimited, time.Since(started)), nil
+}
+
+// benchWindow fetches up to limit logs of w, discarding them
+func benchWindow(ctx context.Context, cfg config.Config, w plan.Window, limit int) (logs, pages int, status fetcher.Status, err error) {
+	cfg.From, cfg.To = w.From, w.To
+	f, err := fetcher.NewWithWriter(&cfg, pageFuncWriter(func(page []datadogV2.Log) error {
+		logs += len(page)
+		pages++
+		if logs >= limit {
+			return errBenchDone
+		}
+		return nil
+	}), io.Discard)
+	if err != nil {
+		return 0, 0, fetcher.Status{}, err
+	}
+	tracker := fetcher.NewTracker()
+	f.SetProgressReporter(tracker)
+	f.SetDiagnosticReporter(tracker)
+	if err := f.Fetch(ctx); err != nil && !errors.Is(err, errBenchDone) {
+		return logs, pages, tracker.Status(), err
+	}
+	return logs, pages, tracker.Status(), nil
+}
+
+// parseIntList parses comma-separated positive integers
+func parseIntList(s string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("'%s' is not a positive integer", part)
+		}
+		values = append(values, n)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no values given")
+	}
+	return values, nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/benchmark"
+)
+
+// runBenchWriters measures writer throughput on a generated corpus and
+// optionally checks it against a saved baseline
+func runBenchWriters(args []string) int {
+	fs := flag.NewFlagSet("bench-writers", flag.ExitOnError)
+	count := fs.Int("count", 10000, "Number of synthetic logs in the corpus")
+	pageSize := fs.Int("pageSize", 1000, "Logs per page handed to each writer")
+	seed := fs.Uint64("seed", 1, "Random seed for the corpus")
+	duration := fs.Duration("duration", time.Second, "Minimum time to spend on each case")
+	var only stringSliceFlag
+	fs.Var(&only, "case", "Only run these cases (repeatable; default: all)")
+	output := fs.String("output", "", "Save results as JSON, e.g. to use as a baseline")
+	baseline := fs.String("baseline", "", "Compare against results saved with --output and fail on regressions")
+	maxRegression := fs.Float64("max-regression", 0.2, "Largest allowed drop in records/sec against --baseline, as a fraction")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch bench-writers - Benchmark output writers on a synthetic corpus\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch bench-writers --output baseline.json\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch bench-writers --baseline baseline.json --max-regression 0.1\n\n")
+		fmt.Fprintf(os.Stderr, "Cases: %s\n\n", strings.Join(benchCaseNames(), ", "))
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *count < 1 || *pageSize < 1 {
+		fmt.Fprintf(os.Stderr, "--count and --pageSize must be positive\n")
+		return exitError
+	}
+
+	cases := benchmark.DefaultCases
+	if len(only) > 0 {
+		cases = nil
+		for _, name := range only {
+			c, ok := findBenchCase(name)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Unknown case '%s'; choose from %s\n", name, strings.Join(benchCaseNames(), ", "))
+				return exitError
+			}
+			cases = append(cases, c)
+		}
+	}
+
+	var base []benchmark.Result
+	if *baseline != "" {
+		var err error
+		base, err = benchmark.ReadFile(*baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read --baseline: %v\n", err)
+			return exitError
+		}
+	}
+
+	corpus := benchmark.NewCorpus(*seed, *count, *pageSize)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "case\trecords/s\tallocs/record\talloc B/record\toutput B/record\t\n")
+
+	var results []benchmark.Result
+	for _, c := range cases {
+		r, err := benchmark.Run(c, corpus, *duration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Benchmark failed: %v\n", err)
+			return exitError
+		}
+		results = append(results, r)
+		fmt.Fprintf(tw, "%s\t%.0f\t%.1f\t%.0f\t%.0f\t\n", r.Name, r.RecordsPerSec, r.AllocsPerRecord, r.AllocBytesPerRecord, r.OutputBytesPerRecord)
+		tw.Flush()
+	}
+
+	if *output != "" {
+		if err := benchmark.WriteFile(*output, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save results: %v\n", err)
+			return exitError
+		}
+	}
+
+	if *baseline != "" {
+		regressions := benchmark.Compare(base, results, *maxRegression)
+		for _, r := range regressions {
+			fmt.Fprintf(os.Stderr, "REGRESSION %s: %.0f -> %.0f records/s (%+.1f%%)\n", r.Name, r.Baseline, r.Current, r.Change*100)
+		}
+		if len(regressions) > 0 {
+			return exitAssertionFailed
+		}
+		fmt.Fprintf(os.Stderr, "No regressions beyond %.0f%% against %s\n", *maxRegression*100, *baseline)
+	}
+	return exitOK
+}
+
+func findBenchCase(name string) (benchmark.Case, bool) {
+	for _, c := range benchmark.DefaultCases {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return benchmark.Case{}, false
+}
+
+func benchCaseNames() []string {
+	names := make([]string, len(benchmark.DefaultCases))
+	for i, c := range benchmark.DefaultCases {
+		names[i] = c.Name
+	}
+	return names
+}
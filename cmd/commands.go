@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// subcommand is an entry point that parses its own flags and returns an exit code
+type subcommand struct {
+	run     func(args []string) int
+	summary string
+}
+
+// subcommands maps names to subcommands; anything else runs the default fetch
+var subcommands = map[string]subcommand{
+	"auth":             {run: runAuth, summary: "Check the API and application keys can read logs (auth check)"},
+	"bench":            {run: runBench, summary: "Measure fetch throughput at several page sizes and concurrency levels"},
+	"bench-writers":    {run: runBenchWriters, summary: "Benchmark output writers and check for performance regressions"},
+	"context":          {run: runContext, summary: "Fetch the logs surrounding a log or a moment, like \"view in context\""},
+	"diff":             {run: runDiff, summary: "Compare log counts and samples between two queries or time ranges"},
+	"doctor":           {run: runDoctor, summary: "Diagnose keys, site, proxy, TLS, clock and permission problems"},
+	"explore":          {run: runExplore, summary: "Refine a query from samples and facet summaries, then fetch it"},
+	"facets":           {run: runFacets, summary: "List the fields a sample of logs carries and how to query them"},
+	"from-alert":       {run: runFromAlert, summary: "Fetch the logs around a monitor alert from its webhook payload"},
+	"hold":             {run: runHold, summary: "Export logs into a tamper-evident legal hold bundle"},
+	"indexes":          {run: runIndexes, summary: "List the log indexes --index can read from, with retention and filters"},
+	"merge":            {run: runMerge, summary: "Merge NDJSON exports into one, sorted by timestamp with duplicate logs dropped"},
+	"mock":             {run: runMock, summary: "Generate synthetic logs or serve a mock Logs API"},
+	"run":              {run: runDaemon, summary: "Fetch new logs on an interval as a long-lived process"},
+	"slice":            {run: runSlice, summary: "Extract a time range or a single log from a local NDJSON file"},
+	"slo-report":       {run: runSLOReport, summary: "Compute availability and error budget burn rates from log counts"},
+	"sql-gateway":      {run: runSQLGateway, summary: "Query logs with SQL over the Postgres wire protocol (experimental)"},
+	"stats":            {run: runStats, summary: "Summarize logs by status, service, host and time from a fetch or an NDJSON file"},
+	"verify-signature": {run: runVerifySignature, summary: "Verify a file signed with --sign-key or --sign-keyless"},
+	"whoami":           {run: runWhoami, summary: "Show who the keys belong to and whether they can read logs"},
+}
+
+// runSubcommand dispatches to a subcommand if args name one
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	sub, ok := subcommands[args[0]]
+	if !ok {
+		return false
+	}
+
+	os.Exit(sub.run(args[1:]))
+	return true
+}
+
+// printSubcommands lists the available subcommands for usage output
+func printSubcommands() {
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, name := range sortedSubcommandNames() {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, subcommands[name].summary)
+	}
+}
+
+func sortedSubcommandNames() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/lock"
+	"github.com/jtzemp/dogfetch/internal/query"
+	"github.com/jtzemp/dogfetch/internal/writer"
+)
+
+// queryFlags are the query-builder flags, ANDed into --query so common
+// fetches don't need Datadog query syntax
+type queryFlags struct {
+	services stringSliceFlag
+	envs     stringSliceFlag
+	statuses stringSliceFlag
+	hosts    stringSliceFlag
+	tags     stringSliceFlag
+}
+
+func addQueryFlags(fs *flag.FlagSet) *queryFlags {
+	qf := &queryFlags{}
+	fs.Var(&qf.services, "service", "Only logs from this service (repeatable or comma-separated; any of them)")
+	fs.Var(&qf.envs, "env", "Only logs tagged with this env (repeatable or comma-separated; any of them)")
+	fs.Var(&qf.statuses, "status", "Only logs with this status, e.g. error,warn (repeatable or comma-separated; any of them)")
+	fs.Var(&qf.hosts, "host", "Only logs from this host (repeatable or comma-separated; any of them)")
+	fs.Var(&qf.tags, "tag", "Only logs with this key:value tag, e.g. team:payments (repeatable or comma-separated; values of one key match any of them)")
+	return qf
+}
+
+// build ANDs the flags' terms into q
+func (qf *queryFlags) build(q string) (string, error) {
+	b := query.Builder{Services: qf.services, Envs: qf.envs, Statuses: qf.statuses, Hosts: qf.hosts, Tags: qf.tags}
+	return b.Build(q)
+}
+
+// checkQuery checks q's syntax for --validate-query, printing why it's
+// invalid with a pointer to the mistake
+func checkQuery(out io.Writer, q string) bool {
+	err := query.Validate(q)
+	if err == nil {
+		return true
+	}
+	fmt.Fprintf(out, "Invalid query: %v\n", err)
+	var syntaxErr *query.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		fmt.Fprintf(out, "  %s\n  %s^\n", q, strings.Repeat(" ", syntaxErr.Column-1))
+	}
+	return false
+}
+
+// fetchFlags are the query, time range and paging flags shared by
+// subcommands that fetch logs
+type fetchFlags struct {
+	*queryFlags
+	query     *string
+	index     *string
+	from      *string
+	to        *string
+	tz        *string
+	pageSize  *int
+	apiURL    *string
+	site      *string
+	userAgent *string
+	headers   repeatedFlag
+}
+
+func addFetchFlags(fs *flag.FlagSet) *fetchFlags {
+	ff := &fetchFlags{
+		queryFlags: addQueryFlags(fs),
+		query:      fs.String("query", "", "The filter query (search term)"),
+		index:      fs.String("index", "main", "Which index to read from"),
+		from:       fs.String("from", "", "Start date/time (default: 24 hours ago)"),
+		to:         fs.String("to", "", "End date/time (default: now)"),
+		tz:         fs.String("tz", "", "Time zone, e.g. Europe/Berlin or Local, for --from and --to without an offset and for times in progress and reports (default: UTC)"),
+		pageSize:   fs.Int("pageSize", 1000, "Results per page (max 5000)"),
+		apiURL:     fs.String("api-url", "", "Override the Datadog API URL"),
+		site:       fs.String("site", "", "Datadog site, e.g. datadoghq.eu or us5 (default: DD_SITE, else datadoghq.com)"),
+		userAgent:  fs.String("user-agent", "", "User-Agent sent with every API request (default: the Datadog API client's)"),
+	}
+	fs.Var(&ff.headers, "header", "Extra header sent with every API request as Key:value, e.g. X-Team:payments (repeatable)")
+	return ff
+}
+
+// config builds an ndjson fetch config from the flags and environment
+func (ff *fetchFlags) config() (*config.Config, error) {
+	q, err := ff.build(*ff.query)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config.Config{
+		Query:     q,
+		Index:     *ff.index,
+		PageSize:  int32(*ff.pageSize),
+		Format:    "ndjson",
+		APIKey:    os.Getenv("DD_API_KEY"),
+		AppKey:    os.Getenv("DD_APP_KEY"),
+		APIURL:    *ff.apiURL,
+		UserAgent: *ff.userAgent,
+	}
+	if cfg.Site, err = resolveSite(*ff.site); err != nil {
+		return nil, err
+	}
+	if cfg.Headers, err = config.ParseHeaders(ff.headers); err != nil {
+		return nil, fmt.Errorf("error parsing --header: %w", err)
+	}
+
+	if *ff.tz != "" {
+		if cfg.Location, err = loadTZ(*ff.tz); err != nil {
+			return nil, fmt.Errorf("error parsing --tz: %w", err)
+		}
+	}
+	loc := cfg.TimeLocation()
+
+	cfg.From = config.DefaultFrom()
+	if *ff.from != "" {
+		t, err := config.ParseTimeIn(*ff.from, loc)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing --from: %w", err)
+		}
+		cfg.From = t
+	}
+
+	if *ff.to != "" {
+		t, err := config.ParseTimeIn(*ff.to, loc)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing --to: %w", err)
+		}
+		cfg.To = t
+	}
+
+	return cfg, nil
+}
+
+// loadTZ loads the time zone --tz names
+func loadTZ(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone '%s'; use an IANA name such as Europe/Berlin", name)
+	}
+	return loc, nil
+}
+
+// interruptSignals stop a run gracefully: Ctrl+C, which os.Interrupt covers on
+// both Unix and Windows, and the SIGTERM service managers and orchestrators
+// send
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// signalName describes an interrupt signal for the shutdown message
+func signalName(sig os.Signal) string {
+	if sig == syscall.SIGTERM {
+		return "termination signal"
+	}
+	return "interrupt signal"
+}
+
+// signalContext returns a context that is cancelled on interrupt or SIGTERM
+func signalContext(errOut io.Writer) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, interruptSignals...)
+
+	go func() {
+		select {
+		case sig := <-sigChan:
+			fmt.Fprintf(errOut, "\nReceived %s, shutting down gracefully...\n", signalName(sig))
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigChan)
+	}()
+
+	return ctx, cancel
+}
+
+// reopenOnHangup reopens the output files on SIGHUP, as logrotate's postrotate
+// step expects, until ctx is done
+func reopenOnHangup(ctx context.Context, errOut io.Writer) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-sigChan:
+				fmt.Fprintf(errOut, "\nReceived hangup signal, reopening output files\n")
+				writer.Reopen()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// resolveSite returns the Datadog site --site names, else DD_SITE, checked
+// against the known sites; "" means the default
+func resolveSite(flagValue string) (string, error) {
+	value, source := flagValue, "--site"
+	if value == "" {
+		value, source = os.Getenv("DD_SITE"), "DD_SITE"
+	}
+	site, err := config.ParseSite(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", source, err)
+	}
+	return site, nil
+}
+
+// lockFiles locks a run's output and state files so a second dogfetch, such
+// as an overlapping cron job, fails instead of writing them at the same time.
+// Outputs that aren't regular files, like /dev/null or a FIFO, aren't locked.
+func lockFiles(cfg *config.Config) (release func(), err error) {
+	var paths []string
+	if cfg.FileOutput() {
+		if info, err := os.Stat(cfg.OutputPath); err != nil || info.Mode().IsRegular() {
+			paths = append(paths, cfg.OutputPath)
+		}
+	}
+	if cfg.StatePath != "" {
+		paths = append(paths, cfg.StatePath)
+	}
+
+	var locks []*lock.Lock
+	release = func() {
+		for _, l := range locks {
+			l.Release()
+		}
+	}
+	for _, path := range paths {
+		l, err := lock.Acquire(path)
+		if errors.Is(err, errors.ErrUnsupported) {
+			fmt.Fprintf(os.Stderr, "Not locking %s: %v; make sure no other run writes it at the same time\n", path, errors.Unwrap(err))
+			continue
+		}
+		if err != nil {
+			release()
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+	return release, nil
+}
+
+// runFetch runs dogfetch's default fetch with args in a child process,
+// printing the equivalent command line first, and returns its exit code
+func runFetch(args []string) int {
+	fmt.Fprintf(os.Stderr, "\nRunning: %s\n\n", commandLine(args))
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find the dogfetch binary: %v\n", err)
+		return exitError
+	}
+	fetch := exec.Command(self, args...)
+	fetch.Stdin, fetch.Stdout, fetch.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := fetch.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "Failed to run fetch: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// commandLine formats a dogfetch command line to copy and paste
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return "dogfetch " + strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s for display in a copy-pastable command line
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`*?[]{}()<>|&;!#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
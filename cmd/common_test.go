@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchFlagsTimeZone(t *testing.T) {
+	fs := flag.NewFlagSet("hold", flag.ContinueOnError)
+	ff := addFetchFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--from", "2024-05-01T09:00:00", "--to", "2024-05-01T10:00:00Z", "--tz", "Europe/Berlin"}))
+
+	cfg, err := ff.config()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC), cfg.From.UTC(), "no offset: taken in --tz")
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), cfg.To.UTC(), "an offset wins")
+	assert.Equal(t, "2024-05-01T09:00:00+02:00", cfg.FormatTime(cfg.From))
+
+	fs = flag.NewFlagSet("hold", flag.ContinueOnError)
+	ff = addFetchFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--tz", "Mars/Olympus"}))
+	_, err = ff.config()
+	assert.ErrorContains(t, err, "unknown time zone 'Mars/Olympus'")
+}
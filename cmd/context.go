@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/around"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/writer"
+)
+
+// runContext fetches the logs surrounding a log or a moment, like Datadog's
+// "view in context"
+func runContext(args []string) int {
+	fs := flag.NewFlagSet("context", flag.ExitOnError)
+	ff := addFetchFlags(fs)
+	logID := fs.String("log-id", "", "ID of the log to fetch context around")
+	at := fs.String("around", "", "Time to fetch context around, or with --log-id, near which to look for the log")
+	before := fs.Int("before", 200, "Logs to fetch before the log or time")
+	after := fs.Int("after", 200, "Logs to fetch after the log or time")
+	window := fs.String("window", "1h", "How far to look on each side for --before and --after logs, and for the --log-id log near --around")
+	match := fs.String("match", strings.Join(around.DefaultMatch, ","), "Fields the surrounding logs must share with the --log-id log (comma-separated; empty for all logs matching --query)")
+	format := fs.String("format", "", "Output format (default: from the --output extension, else ndjson)")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch context - Fetch the logs surrounding a log or a moment\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch context --log-id <id> [--before 200] [--after 200]\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch context --around 2024-01-02T15:04:05Z --query 'service:web'\n\n")
+		fmt.Fprintf(os.Stderr, "With --log-id, finds the log between --from and --to (or within --window of\n")
+		fmt.Fprintf(os.Stderr, "--around) and fetches the logs just before and after it from the same service\n")
+		fmt.Fprintf(os.Stderr, "and host. With only --around, fetches the logs matching --query either side of\n")
+		fmt.Fprintf(os.Stderr, "that time. The logs are written oldest first.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *logID == "" && *at == "" {
+		fmt.Fprintf(os.Stderr, "Nothing to fetch context around: set --log-id or --around\n")
+		fs.Usage()
+		return exitError
+	}
+	if *before < 0 || *after < 0 {
+		fmt.Fprintf(os.Stderr, "--before and --after can't be negative\n")
+		return exitError
+	}
+	span, err := config.ParseDuration(*window)
+	if err != nil || span <= 0 {
+		fmt.Fprintf(os.Stderr, "--window must be a positive duration, got '%s'\n", *window)
+		return exitError
+	}
+
+	cfg, err := ff.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	var t time.Time
+	if *at != "" {
+		if t, err = config.ParseTimeIn(*at, cfg.TimeLocation()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --around: %v\n", err)
+			return exitError
+		}
+		cfg.From, cfg.To = t.Add(-span), t.Add(span)
+	}
+	if cfg.To.IsZero() {
+		cfg.To = time.Now().UTC()
+	}
+	if cfg.Query == "" {
+		cfg.Query = "*"
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return exitError
+	}
+
+	client := fetcher.NewClient(cfg.APIKey, cfg.AppKey, cfg.Site, fetcher.ConfigOptions(cfg)...)
+	search := fetcher.SearchRequest{Query: cfg.Query, Indexes: []string{cfg.Index}, PageSize: cfg.PageSize}
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	var anchor *datadogV2.Log
+	if *logID != "" {
+		search.From, search.To = cfg.From, cfg.To
+		anchor, err = findLog(ctx, client, cfg, search, *logID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to find the log: %v\n", err)
+			return exitError
+		}
+		attrs := anchor.GetAttributes()
+		t = attrs.GetTimestamp()
+		var fields stringSliceFlag
+		fields.Set(*match)
+		var terms []string
+		search.Query, terms = around.Query(cfg.Query, *anchor, fields)
+		fmt.Fprintf(os.Stderr, "%s\n", around.Describe(*anchor))
+		if len(terms) > 0 {
+			fmt.Fprintf(os.Stderr, "Matching %s\n", strings.Join(terms, " "))
+		}
+	}
+
+	// Logs at the anchor's exact timestamp fall on the after side, with the
+	// anchor among them
+	search.From, search.To, search.Ascending = t.Add(-span), t, false
+	older, err := searchLogs(ctx, client, search, *before)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch the logs before: %v\n", err)
+		return exitError
+	}
+	search.From, search.To, search.Ascending = t, t.Add(span), true
+	n := *after
+	if anchor != nil {
+		n++
+	}
+	newer, err := searchLogs(ctx, client, search, n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch the logs after: %v\n", err)
+		return exitError
+	}
+	newer = around.Without(newer, anchor, *after)
+	logs := around.Assemble(older, anchor, newer)
+
+	w, err := writer.NewWithOptions(*format, *output, false, writer.Options{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create writer: %v\n", err)
+		return exitError
+	}
+	defer w.Close()
+	if err := w.WritePage(logs); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write logs: %v\n", err)
+		return exitError
+	}
+	if err := w.Finalize(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write logs: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %d logs before and %d after %s\n", len(older), len(newer), t.In(cfg.TimeLocation()).Format(time.RFC3339Nano))
+	return exitOK
+}
+
+// findLog looks for the log with id among those search matches, newest first
+func findLog(ctx context.Context, client *fetcher.Client, cfg *config.Config, search fetcher.SearchRequest, id string) (*datadogV2.Log, error) {
+	fmt.Fprintf(os.Stderr, "Looking for log %s between %s and %s ...\n", id, cfg.FormatTime(search.From), cfg.FormatTime(search.To))
+	var found *datadogV2.Log
+	err := client.Search(ctx, search, func(page []datadogV2.Log) bool {
+		for i := range page {
+			if page[i].GetId() == id {
+				found = &page[i]
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, errors.New("no log with that ID in range; set --around near its time, or widen --from and --to")
+	}
+	return found, nil
+}
+
+// searchLogs returns up to n logs search matches
+func searchLogs(ctx context.Context, client *fetcher.Client, search fetcher.SearchRequest, n int) ([]datadogV2.Log, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	var logs []datadogV2.Log
+	search.PageSize = int32(min(n, int(search.PageSize)))
+	err := client.Search(ctx, search, func(page []datadogV2.Log) bool {
+		logs = append(logs, page...)
+		return len(logs) < n
+	})
+	if len(logs) > n {
+		logs = logs[:n]
+	}
+	return logs, err
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/diff"
+	"github.com/jtzemp/dogfetch/internal/explore"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+)
+
+// runDiff fetches two queries or time ranges and reports how they differ
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	ff := addFetchFlags(fs)
+	shift := fs.String("shift", "", "Compare against the same range this long before, e.g. 1d for yesterday or 1w for last week")
+	baselineQuery := fs.String("baseline-query", "", "Query to compare against (default: --query)")
+	baselineIndex := fs.String("baseline-index", "", "Index to compare against (default: --index)")
+	baselineFrom := fs.String("baseline-from", "", "Start of the range to compare against (default: --from, moved back by --shift)")
+	baselineTo := fs.String("baseline-to", "", "End of the range to compare against (default: as long as the current range)")
+	limit := fs.Int("limit", 10000, "Logs to fetch from each side to compare; sides with more are counted with the aggregation API and sampled")
+	top := fs.Int("top", 5, "Values and messages to show per section")
+	var facets stringSliceFlag
+	fs.Var(&facets, "facet", "Fields to compare the values of (repeatable or comma-separated; default: status, service, host, env, http.status_code, error.kind)")
+	format := fs.String("format", "text", "Report format: text or json")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch diff - Compare the logs of two queries or time ranges\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch diff --query 'service:web' --from 2024-01-02T00:00:00Z --shift 1d\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch diff --query 'service:web-canary' --baseline-query 'service:web'\n\n")
+		fmt.Fprintf(os.Stderr, "The current side is --query over --from and --to; the baseline changes it with\n")
+		fmt.Fprintf(os.Stderr, "--shift or the --baseline-* options. Reports the change in log count, the facet\n")
+		fmt.Fprintf(os.Stderr, "values whose share moved most, and fields and message patterns only one side has.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *shift == "" && *baselineQuery == "" && *baselineIndex == "" && *baselineFrom == "" {
+		fmt.Fprintf(os.Stderr, "Nothing to compare: set --shift, --baseline-query, --baseline-index or --baseline-from\n")
+		fs.Usage()
+		return exitError
+	}
+	if *shift != "" && (*baselineFrom != "" || *baselineTo != "") {
+		fmt.Fprintf(os.Stderr, "--shift cannot be used with --baseline-from or --baseline-to\n")
+		return exitError
+	}
+	if *limit <= 0 {
+		fmt.Fprintf(os.Stderr, "--limit must be positive\n")
+		return exitError
+	}
+	if *top <= 0 {
+		fmt.Fprintf(os.Stderr, "--top must be positive\n")
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "--format must be text or json, got '%s'\n", *format)
+		return exitError
+	}
+	if len(facets) == 0 {
+		facets = append(stringSliceFlag(nil), explore.DefaultFacets...)
+	}
+
+	current, err := ff.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	// Both sides need a fixed end for --shift to line them up
+	if current.To.IsZero() {
+		current.To = time.Now().UTC()
+	}
+
+	baseline := *current
+	if *baselineQuery != "" {
+		baseline.Query = *baselineQuery
+	}
+	if *baselineIndex != "" {
+		baseline.Index = *baselineIndex
+	}
+	if *shift != "" {
+		d, err := config.ParseDuration(*shift)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(os.Stderr, "--shift must be a positive duration such as 1d, got '%s'\n", *shift)
+			return exitError
+		}
+		baseline.From, baseline.To = current.From.Add(-d), current.To.Add(-d)
+	}
+	if *baselineFrom != "" {
+		if baseline.From, err = config.ParseTimeIn(*baselineFrom, current.TimeLocation()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --baseline-from: %v\n", err)
+			return exitError
+		}
+		baseline.To = baseline.From.Add(current.To.Sub(current.From))
+	}
+	if *baselineTo != "" {
+		if baseline.To, err = config.ParseTimeIn(*baselineTo, current.TimeLocation()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --baseline-to: %v\n", err)
+			return exitError
+		}
+	}
+
+	for _, cfg := range []*config.Config{current, &baseline} {
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+			return exitError
+		}
+	}
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	before, err := fetchSide(ctx, baseline, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch the baseline: %v\n", err)
+		return exitError
+	}
+	after, err := fetchSide(ctx, *current, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch the current logs: %v\n", err)
+		return exitError
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	report := diff.Compare(before, after, facets, *top)
+	if *format == "json" {
+		err = report.WriteJSON(out)
+	} else {
+		report.WriteText(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// fetchSide fetches up to limit logs of one side of a diff. When there are
+// more, the side is counted with the aggregation API and the logs fetched
+// serve as a sample of the newest
+func fetchSide(ctx context.Context, cfg config.Config, limit int) (diff.Side, error) {
+	query := cfg.Query
+	if query == "" {
+		query = "*"
+	}
+	side := diff.Side{Label: fmt.Sprintf("%s in %s from %s to %s", query, cfg.Index, cfg.FormatTime(cfg.From), cfg.FormatTime(cfg.To))}
+	fmt.Fprintf(os.Stderr, "Fetching %s ...\n", side.Label)
+
+	logs, err := sampleLogs(ctx, cfg, cfg.Query, limit)
+	if err != nil {
+		return side, err
+	}
+	if len(logs) < limit {
+		side.Logs, side.Total = logs, int64(len(logs))
+		return side, nil
+	}
+
+	side.Logs = logs[:limit]
+	client := fetcher.NewClient(cfg.APIKey, cfg.AppKey, cfg.Site, fetcher.ConfigOptions(&cfg)...)
+	side.Total, err = client.Count(ctx, query, []string{cfg.Index}, cfg.From, cfg.To)
+	if err != nil {
+		return side, fmt.Errorf("counting: %w", err)
+	}
+	return side, nil
+}
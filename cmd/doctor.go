@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/auth"
+	"github.com/jtzemp/dogfetch/internal/doctor"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+)
+
+// runDoctor checks everything a fetch depends on, from the environment to the
+// keys' permissions, and says how to fix what's wrong
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	index := fs.String("index", "main", "Index to check the keys can read logs from")
+	apiURL := fs.String("api-url", "", "Override the Datadog API URL")
+	statePath := fs.String("state-file", "", "Also check this state file can be read")
+	redactRules := fs.String("redact-rules", "", "Also check this redaction rules file is valid")
+	timeout := fs.Duration("timeout", 10*time.Second, "How long to wait for Datadog to answer")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch doctor - Diagnose setup problems\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch doctor [--index main] [--state-file state.json] [--redact-rules rules.yaml]\n\n")
+		fmt.Fprintf(os.Stderr, "Checks DD_API_KEY, DD_APP_KEY and DD_SITE, any state file and redaction rules\n")
+		fmt.Fprintf(os.Stderr, "given, the proxy, connectivity and TLS to the site, the local clock against\n")
+		fmt.Fprintf(os.Stderr, "Datadog's, and finally what auth check does. Each problem comes with a hint\n")
+		fmt.Fprintf(os.Stderr, "on fixing it. Exits 1 if any check fails; warnings don't.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	site := os.Getenv("DD_SITE")
+	if site == "" {
+		site = doctor.Sites[0]
+	}
+	server := *apiURL
+	if server == "" {
+		server = "https://api." + site
+	}
+
+	var report doctor.Report
+	report.Add(doctor.Env(os.Getenv)...)
+	report.Add(doctor.Files(*statePath, *redactRules)...)
+	report.Add(doctor.Proxy(server, http.ProxyFromEnvironment))
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	fmt.Printf("Checking setup against %s\n", server)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	connected := doctor.Connect(ctx, &http.Client{Transport: transport, Timeout: *timeout}, server, time.Now)
+	report.Add(connected...)
+
+	// The keys can't be checked without them or without reaching Datadog
+	apiKey, appKey := os.Getenv("DD_API_KEY"), os.Getenv("DD_APP_KEY")
+	if apiKey != "" && appKey != "" && connected[0].Status != doctor.Fail {
+		var opts []fetcher.ClientOption
+		if *apiURL != "" {
+			opts = append(opts, fetcher.WithBaseURL(*apiURL))
+		}
+		client := fetcher.NewClient(apiKey, appKey, os.Getenv("DD_SITE"), opts...)
+		report.Add(doctor.FromAuth(auth.Check(ctx, client, *index))...)
+	}
+
+	report.Write(os.Stdout)
+	if report.Failed() {
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/explore"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+)
+
+// errSampleFull stops a fetch once the sample is collected
+var errSampleFull = errors.New("sample full")
+
+// runExplore refines a query interactively from samples, then hands it to a
+// full fetch
+func runExplore(args []string) int {
+	fs := flag.NewFlagSet("explore", flag.ExitOnError)
+	ff := addFetchFlags(fs)
+	sample := fs.Int("sample", 500, "Logs to sample for each summary (max 5000)")
+	top := fs.Int("top", 5, "Values to show per facet")
+	var facets stringSliceFlag
+	fs.Var(&facets, "facet", "Fields to summarize (repeatable or comma-separated; default: status, service, host, env, http.status_code, error.kind)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch explore - Refine a query from samples before fetching it\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch explore --query 'service:web' [-- <fetch options>]\n\n")
+		fmt.Fprintf(os.Stderr, "Samples the query, summarizes the most common values of each facet and reads\n")
+		fmt.Fprintf(os.Stderr, "commands from stdin to narrow it down. 'fetch' runs dogfetch with the refined\n")
+		fmt.Fprintf(os.Stderr, "query and the options after '--'; 'quit' prints the query. Type 'help' for all\n")
+		fmt.Fprintf(os.Stderr, "commands.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *sample <= 0 || *sample > 5000 {
+		fmt.Fprintf(os.Stderr, "--sample must be between 1 and 5000\n")
+		return exitError
+	}
+	if *top <= 0 {
+		fmt.Fprintf(os.Stderr, "--top must be positive\n")
+		return exitError
+	}
+
+	cfg, err := ff.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	cfg.PageSize = int32(*sample)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return exitError
+	}
+
+	session := &explore.Session{Base: cfg.Query, Facets: facets}
+	if len(session.Facets) == 0 {
+		session.Facets = append([]string(nil), explore.DefaultFacets...)
+	}
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	input := bufio.NewScanner(os.Stdin)
+	var summary explore.Summary
+	action := explore.Resample
+	for {
+		if action == explore.Resample {
+			fmt.Fprintf(os.Stderr, "\nSampling %s ...\n", session.Query())
+			logs, err := sampleLogs(ctx, *cfg, session.Query(), *sample)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Sample failed: %v\n", err)
+				if ctx.Err() != nil {
+					return exitError
+				}
+			} else {
+				summary = explore.Summarize(logs, session.Facets, *top)
+				summary.Write(os.Stderr)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "\nquery: %s\n> ", session.Query())
+		if !input.Scan() {
+			fmt.Fprintln(os.Stderr)
+			fmt.Println(session.Query())
+			return exitOK
+		}
+
+		var message string
+		action, message = session.Apply(input.Text(), summary)
+		if message != "" {
+			fmt.Fprintln(os.Stderr, message)
+		}
+		switch action {
+		case explore.Quit:
+			fmt.Println(session.Query())
+			return exitOK
+		case explore.Fetch:
+			cancel()
+			return handOff(ff, session.Query(), fs.Args())
+		}
+	}
+}
+
+// sampleLogs fetches the first page of logs matching query
+func sampleLogs(ctx context.Context, cfg config.Config, query string, n int) ([]datadogV2.Log, error) {
+	cfg.Query = query
+	var logs []datadogV2.Log
+	f, err := fetcher.NewWithWriter(&cfg, pageFuncWriter(func(page []datadogV2.Log) error {
+		logs = append(logs, page...)
+		if len(logs) >= n {
+			return errSampleFull
+		}
+		return nil
+	}), io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Fetch(ctx); err != nil && !errors.Is(err, errSampleFull) {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// handOff runs a full fetch of query over the explored time range, passing
+// extra through to it
+func handOff(ff *fetchFlags, query string, extra []string) int {
+	args := []string{"--query", query, "--index", *ff.index}
+	if *ff.from != "" {
+		args = append(args, "--from", *ff.from)
+	}
+	if *ff.to != "" {
+		args = append(args, "--to", *ff.to)
+	}
+	if *ff.apiURL != "" {
+		args = append(args, "--api-url", *ff.apiURL)
+	}
+	args = append(args, extra...)
+	return runFetch(args)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jtzemp/dogfetch/internal/facets"
+)
+
+// runFacets lists the fields a sample of logs carry and how to query them
+func runFacets(args []string) int {
+	fs := flag.NewFlagSet("facets", flag.ExitOnError)
+	ff := addFetchFlags(fs)
+	sample := fs.Int("sample", 1000, "Logs to sample (max 5000)")
+	examples := fs.Int("examples", 3, "Example values to show per field")
+	format := fs.String("format", "text", "Output format: text or json")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch facets - List the fields logs carry and how to query them\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch facets --query 'service:web' [--sample 1000]\n\n")
+		fmt.Fprintf(os.Stderr, "Datadog's API has no list of facets, so this samples the newest logs matching\n")
+		fmt.Fprintf(os.Stderr, "--query and lists every field they carry: the reserved attributes, tag keys and\n")
+		fmt.Fprintf(os.Stderr, "custom attributes, each as a query term with its types, the share of the\n")
+		fmt.Fprintf(os.Stderr, "sample carrying it and example values.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *sample < 1 || *sample > 5000 {
+		fmt.Fprintf(os.Stderr, "--sample must be between 1 and 5000\n")
+		return exitError
+	}
+	if *examples < 0 {
+		fmt.Fprintf(os.Stderr, "--examples can't be negative\n")
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "--format must be text or json, got '%s'\n", *format)
+		return exitError
+	}
+
+	cfg, err := ff.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	if cfg.Query == "" {
+		cfg.Query = "*"
+	}
+	cfg.PageSize = int32(*sample)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return exitError
+	}
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	logs, err := sampleLogs(ctx, *cfg, cfg.Query, *sample)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to sample logs: %v\n", err)
+		return exitError
+	}
+	if len(logs) > *sample {
+		logs = logs[:*sample]
+	}
+	collector := facets.NewCollector(*examples)
+	collector.Observe(logs)
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "json" {
+		err = facets.WriteJSON(out, collector.Logs(), collector.Facets())
+	} else {
+		err = facets.WriteText(out, collector.Logs(), collector.Facets())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write facets: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
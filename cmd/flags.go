@@ -0,0 +1,33 @@
+package cmd
+
+import "strings"
+
+// stringSliceFlag collects the values of a repeatable flag
+// Each value may also be a comma-separated list.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s = append(*s, v)
+		}
+	}
+	return nil
+}
+
+// repeatedFlag collects the values of a repeatable flag verbatim
+// Use it for values that may legitimately contain commas.
+type repeatedFlag []string
+
+func (r *repeatedFlag) String() string {
+	return strings.Join(*r, " ")
+}
+
+func (r *repeatedFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
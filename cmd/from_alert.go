@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/alert"
+	"github.com/jtzemp/dogfetch/internal/config"
+)
+
+// runFromAlert fetches the logs around a monitor alert from its webhook payload
+func runFromAlert(args []string) int {
+	fs := flag.NewFlagSet("from-alert", flag.ExitOnError)
+	payloadPath := fs.String("payload", "-", "File holding the monitor webhook payload, or - for stdin")
+	before := fs.String("before", "5m", "Extra time to fetch before the monitor's evaluation window")
+	after := fs.String("after", "5m", "Time to fetch after the alert triggered")
+	index := fs.String("index", "", "Which index to read from (default: the log monitor's index, or main)")
+	query := fs.String("query", "", "Extra query terms to narrow the alert's logs down, e.g. 'status:error'")
+	apiURL := fs.String("api-url", "", "Override the Datadog API URL")
+	dryRun := fs.Bool("dry-run", false, "Print the fetch command line without running it")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch from-alert - Fetch the logs around a monitor alert\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch from-alert --payload alert.json [-- <fetch options>]\n")
+		fmt.Fprintf(os.Stderr, "  curl ... | dogfetch from-alert -- --output evidence.ndjson\n\n")
+		fmt.Fprintf(os.Stderr, "Reads a Datadog monitor webhook payload, takes the query, the triggering group's\n")
+		fmt.Fprintf(os.Stderr, "scope and the evaluation window from it, and fetches those logs with the options\n")
+		fmt.Fprintf(os.Stderr, "after '--'. The payload needs at least \"date\": \"$DATE\" and\n")
+		fmt.Fprintf(os.Stderr, "\"alert_query\": \"$ALERT_QUERY\"; \"alert_scope\": \"$ALERT_SCOPE\" narrows grouped monitors.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	beforeDur, err := config.ParseDuration(*before)
+	if err != nil || beforeDur < 0 {
+		fmt.Fprintf(os.Stderr, "--before must be a duration, got '%s'\n", *before)
+		return exitError
+	}
+	afterDur, err := config.ParseDuration(*after)
+	if err != nil || afterDur < 0 {
+		fmt.Fprintf(os.Stderr, "--after must be a duration, got '%s'\n", *after)
+		return exitError
+	}
+
+	var in io.Reader = os.Stdin
+	if *payloadPath != "-" {
+		file, err := os.Open(*payloadPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open --payload: %v\n", err)
+			return exitError
+		}
+		defer file.Close()
+		in = file
+	}
+
+	a, err := alert.Parse(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+
+	search := strings.TrimSpace(a.SearchQuery() + " " + *query)
+	if search == "" {
+		fmt.Fprintf(os.Stderr, "The alert has no log query or scope to search for; pass --query\n")
+		return exitError
+	}
+	if *index == "" {
+		*index = a.Index
+	}
+	if *index == "" {
+		*index = "main"
+	}
+
+	from, to := a.Range(beforeDur, afterDur)
+	if now := time.Now().UTC(); to.After(now) {
+		to = now
+	}
+
+	label := a.Title
+	if label == "" {
+		label = strings.TrimSpace("Alert " + a.ID)
+	}
+	fmt.Fprintf(os.Stderr, "%s triggered at %s (window %s)\n", label, a.Time.Format(time.RFC3339), a.Window)
+
+	fetchArgs := []string{
+		"--query", search,
+		"--index", *index,
+		"--from", from.Format(time.RFC3339),
+		"--to", to.Format(time.RFC3339),
+	}
+	if *apiURL != "" {
+		fetchArgs = append(fetchArgs, "--api-url", *apiURL)
+	}
+	fetchArgs = append(fetchArgs, fs.Args()...)
+
+	if *dryRun {
+		fmt.Println(commandLine(fetchArgs))
+		return exitOK
+	}
+	return runFetch(fetchArgs)
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/anomaly"
+	"github.com/jtzemp/dogfetch/internal/assertion"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/ghactions"
+	"github.com/jtzemp/dogfetch/internal/topn"
+)
+
+// githubRun is what --annotate-github reports on
+type githubRun struct {
+	cfg        *config.Config
+	stats      fetcher.Stats
+	assertions *assertion.Set
+	topFields  []*topn.Field
+	anomalies  []anomaly.Window
+	err        error // why the run didn't complete, nil if it did
+	failed     bool  // the fetch failed rather than being interrupted
+}
+
+// annotateGitHub emits workflow commands for failures and notable findings
+// and appends a summary of the run to $GITHUB_STEP_SUMMARY
+// Workflow commands go to stderr even with --errors-out, since the runner
+// only reads them from the step's output and stdout may carry the logs.
+func annotateGitHub(errOut io.Writer, run githubRun) {
+	for _, a := range githubAnnotations(run) {
+		ghactions.WriteAnnotation(os.Stderr, a)
+	}
+
+	path := os.Getenv(ghactions.SummaryEnv)
+	if path == "" {
+		fmt.Fprintf(errOut, "%s is not set; skipping the job summary\n", ghactions.SummaryEnv)
+		return
+	}
+	if err := ghactions.AppendSummary(path, githubSummary(run)); err != nil {
+		fmt.Fprintf(errOut, "Failed to write job summary: %v\n", err)
+	}
+}
+
+func githubAnnotations(run githubRun) []ghactions.Annotation {
+	var annotations []ghactions.Annotation
+	switch {
+	case run.failed:
+		annotations = append(annotations, ghactions.Annotation{Level: "error", Title: "dogfetch failed", Message: run.err.Error()})
+	case run.err != nil:
+		annotations = append(annotations, ghactions.Annotation{Level: "warning", Title: "dogfetch incomplete", Message: run.err.Error()})
+	default:
+		// Assertions only make sense for a complete run
+		for _, failure := range run.assertions.Failures() {
+			annotations = append(annotations, ghactions.Annotation{
+				Level:   "error",
+				Title:   "Assertion failed: " + failure.Name,
+				Message: failure.Err.Error(),
+			})
+		}
+		if run.stats.Logs == 0 {
+			annotations = append(annotations, ghactions.Annotation{
+				Level:   "notice",
+				Title:   "No logs",
+				Message: fmt.Sprintf("No logs matched %q", run.cfg.Query),
+			})
+		}
+	}
+
+	for _, w := range run.anomalies {
+		annotations = append(annotations, ghactions.Annotation{
+			Level:   "warning",
+			Title:   "Anomalous log volume",
+			Message: fmt.Sprintf("%s: %d logs (expected ~%.0f, z=%+.1f)", run.cfg.FormatTime(w.Start), w.Count, w.Expected, w.ZScore),
+		})
+	}
+	return annotations
+}
+
+func githubSummary(run githubRun) string {
+	var s ghactions.Summary
+	s.Heading(2, "dogfetch")
+
+	switch {
+	case run.failed:
+		s.Paragraph(":x: Fetch failed")
+	case run.err != nil:
+		s.Paragraph(":warning: Fetch incomplete")
+	case len(run.assertions.Failures()) > 0:
+		s.Paragraph(":x: Assertions failed")
+	default:
+		s.Paragraph(":white_check_mark: Fetch complete")
+	}
+
+	rows := [][]string{
+		{"Query", run.cfg.Query},
+		{"Index", run.cfg.Index},
+		{"Time range", run.cfg.FormatTime(run.cfg.From) + " to " + run.cfg.FormatTime(run.cfg.To)},
+		{"Logs", fmt.Sprint(run.stats.Logs)},
+	}
+	if len(run.cfg.Filters) > 0 {
+		rows = append(rows, []string{"Dropped by --filter", fmt.Sprint(run.stats.Filtered)})
+	}
+	rows = append(rows,
+		[]string{"Pages", fmt.Sprint(run.stats.Pages)},
+		[]string{"Duration", run.stats.Duration.Round(time.Millisecond).String()},
+	)
+	if run.err != nil {
+		rows = append(rows, []string{"Error", run.err.Error()})
+	}
+	s.Table([]string{"Run", "Value"}, rows)
+
+	if run.assertions.Len() > 0 && run.err == nil {
+		s.Heading(3, "Assertions")
+		var rows [][]string
+		for _, r := range run.assertions.Results() {
+			if r.Passed() {
+				rows = append(rows, []string{r.Name, ":white_check_mark: passed", ""})
+			} else {
+				rows = append(rows, []string{r.Name, ":x: failed", r.Err.Error()})
+			}
+		}
+		s.Table([]string{"Assertion", "Result", "Details"}, rows)
+	}
+
+	for _, field := range run.topFields {
+		s.Heading(3, "Top "+field.Name())
+		var rows [][]string
+		for _, e := range field.Top() {
+			rows = append(rows, []string{e.Value, fmt.Sprint(e.Count)})
+		}
+		if len(rows) == 0 {
+			s.Paragraph("No values")
+			continue
+		}
+		s.Table([]string{"Value", "Count"}, rows)
+	}
+
+	if len(run.anomalies) > 0 {
+		s.Heading(3, "Anomalous minutes")
+		var rows [][]string
+		for _, w := range run.anomalies {
+			rows = append(rows, []string{run.cfg.FormatTime(w.Start), fmt.Sprint(w.Count), fmt.Sprintf("%.0f", w.Expected), fmt.Sprintf("%+.1f", w.ZScore)})
+		}
+		s.Table([]string{"Minute", "Logs", "Expected", "z"}, rows)
+	}
+	return s.String()
+}
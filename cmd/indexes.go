@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/indexes"
+)
+
+// runIndexes lists the organization's log indexes, the values --index takes
+func runIndexes(args []string) int {
+	fs := flag.NewFlagSet("indexes", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format: text or json")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+	apiURL := fs.String("api-url", "", "Override the Datadog API URL")
+	siteFlag := fs.String("site", "", "Datadog site, e.g. datadoghq.eu or us5 (default: DD_SITE, else datadoghq.com)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch indexes - List the log indexes --index can read from\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch indexes [--format json]\n\n")
+		fmt.Fprintf(os.Stderr, "Lists each index's name, retention, daily limit, filter and exclusion filters,\n")
+		fmt.Fprintf(os.Stderr, "in the order logs are routed to them. Needs the logs_read_config permission.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "--format must be text or json, got '%s'\n", *format)
+		return exitError
+	}
+	apiKey, appKey := os.Getenv("DD_API_KEY"), os.Getenv("DD_APP_KEY")
+	if apiKey == "" || appKey == "" {
+		fmt.Fprintf(os.Stderr, "DD_API_KEY and DD_APP_KEY must both be set\n")
+		return exitError
+	}
+
+	site, err := resolveSite(*siteFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	var opts []fetcher.ClientOption
+	if *apiURL != "" {
+		opts = append(opts, fetcher.WithBaseURL(*apiURL))
+	}
+	client := fetcher.NewClient(apiKey, appKey, site, opts...)
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	list, err := client.Indexes(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list indexes: %v\n", err)
+		return exitError
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "json" {
+		err = indexes.WriteJSON(out, indexes.FromAPI(list))
+	} else {
+		err = indexes.WriteText(out, indexes.FromAPI(list))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write indexes: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/merge"
+)
+
+// runMerge combines NDJSON exports into one, sorted and without duplicates
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("output", "", "Output file path (default: stdout)")
+	maxMemory := fs.String("max-memory", "256MB", "Logs sorted in memory at once; beyond it sorted runs spill to temp files")
+	tempDir := fs.String("temp-dir", "", "Directory for spilled runs (default: system temp directory)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch merge - Merge NDJSON exports, sorted by timestamp without duplicates\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch merge a.ndjson b.ndjson --output merged.ndjson\n\n")
+		fmt.Fprintf(os.Stderr, "Logs with the same ID are kept once, from the first file listed. Files larger\n")
+		fmt.Fprintf(os.Stderr, "than --max-memory are sorted in runs on disk and merged from there.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	// Files may come before, between or after the flags
+	var files []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "At least one file to merge is required\n")
+		fs.Usage()
+		return exitError
+	}
+
+	budget, err := config.ParseByteSize(*maxMemory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing --max-memory: %v\n", err)
+		return exitError
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		// Creating the output truncates it, so it can't also be an input
+		for _, file := range files {
+			if sameFile(file, *output) {
+				fmt.Fprintf(os.Stderr, "--output %s is also an input; write the merge somewhere else\n", *output)
+				return exitError
+			}
+		}
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+
+	stats, err := merge.Files(files, bw, merge.Options{MaxMemory: budget, TempDir: *tempDir})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Merge failed: %v\n", err)
+		return exitError
+	}
+
+	how := "in memory"
+	if stats.Runs > 0 {
+		how = fmt.Sprintf("%d runs on disk", stats.Runs)
+	}
+	fmt.Fprintf(os.Stderr, "Merged %d logs from %d files into %d (%d duplicates dropped, sorted %s)\n",
+		stats.Read, len(files), stats.Written, stats.Duplicates, how)
+	return exitOK
+}
+
+// sameFile reports whether two paths name the same existing file
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
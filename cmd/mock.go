@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/synth"
+	"github.com/jtzemp/dogfetch/internal/writer"
+)
+
+// mockBatchSize is how many synthetic logs are handed to the writer at once
+const mockBatchSize = 1000
+
+// runMock generates synthetic logs to a file, or serves them as a mock Logs API
+func runMock(args []string) int {
+	fs := flag.NewFlagSet("mock", flag.ExitOnError)
+	count := fs.Int("count", 1000, "Number of logs to generate")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+	format := fs.String("format", "ndjson", "Output format: json or ndjson")
+	seed := fs.Uint64("seed", 1, "Random seed; the same seed always produces the same logs")
+	from := fs.String("from", "", "Start of the generated time range (default: 24 hours ago)")
+	to := fs.String("to", "", "End of the generated time range (default: now)")
+	serve := fs.String("serve", "", "Serve a mock Logs API on this address (e.g. localhost:8080) instead of writing a file")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch mock - Generate synthetic Datadog logs\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch mock --count 10000 --output sample.ndjson\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch mock --count 10000 --serve localhost:8080\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *count < 0 {
+		fmt.Fprintf(os.Stderr, "--count must not be negative\n")
+		return exitError
+	}
+
+	start := config.DefaultFrom()
+	if *from != "" {
+		t, err := config.ParseTime(*from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --from: %v\n", err)
+			return exitError
+		}
+		start = t
+	}
+	end := time.Now()
+	if *to != "" {
+		t, err := config.ParseTime(*to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --to: %v\n", err)
+			return exitError
+		}
+		end = t
+	}
+	if !start.Before(end) {
+		fmt.Fprintf(os.Stderr, "--from must be before --to\n")
+		return exitError
+	}
+
+	gen := synth.New(*seed, *count, start, end)
+
+	if *serve != "" {
+		fmt.Fprintf(os.Stderr, "Serving %d synthetic logs on http://%s%s\n", *count, *serve, synth.LogsPath)
+		fmt.Fprintf(os.Stderr, "Fetch them with: dogfetch --api-url http://%s --query '*'\n", *serve)
+		if err := http.ListenAndServe(*serve, synth.Handler(gen)); err != nil {
+			fmt.Fprintf(os.Stderr, "Mock server failed: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	w, err := writer.New(*format, *output, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create writer: %v\n", err)
+		return exitError
+	}
+	defer w.Close()
+
+	for offset := 0; offset < *count; offset += mockBatchSize {
+		if err := w.WritePage(gen.Page(offset, mockBatchSize)); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write logs: %v\n", err)
+			return exitError
+		}
+	}
+	if err := w.Finalize(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write logs: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(os.Stderr, "Generated %d synthetic logs\n", *count)
+	return exitOK
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/notify"
+)
+
+// notifyRun posts the run summary to --notify-url. A webhook that can't be
+// reached is reported but doesn't change how the run exits
+func notifyRun(errOut io.Writer, url, format string, cfg *config.Config, stats fetcher.Stats, status string, runErr error) {
+	summary := notify.Summary{
+		Status:   status,
+		Query:    cfg.Query,
+		Index:    cfg.Index,
+		From:     cfg.From.UTC(),
+		To:       fetcher.FormatToTime(cfg.To),
+		Output:   cfg.OutputPath,
+		Logs:     stats.Logs,
+		Pages:    stats.Pages,
+		Duration: stats.Duration.Round(time.Millisecond).String(),
+	}
+	if runErr != nil {
+		summary.Error = runErr.Error()
+	}
+	// The run's own context is already cancelled when it was interrupted
+	if err := notify.Send(context.Background(), http.DefaultClient, url, format, summary); err != nil {
+		fmt.Fprintf(errOut, "Failed to send --notify-url notification: %v\n", err)
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+)
+
+// profiler records CPU and heap profiles of a fetch for go tool pprof
+type profiler struct {
+	cpu     *os.File
+	memPath string
+}
+
+// startProfile starts profiling the CPU to cpuPath, if set; the heap profile
+// is written to memPath, if set, when the profiler stops
+func startProfile(cpuPath, memPath string) (*profiler, error) {
+	p := &profiler{memPath: memPath}
+	if cpuPath == "" {
+		return p, nil
+	}
+	f, err := os.Create(cpuPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create --cpuprofile: %w", err)
+	}
+	if err := runtimepprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+	p.cpu = f
+	return p, nil
+}
+
+// stop ends the CPU profile and writes the heap profile
+func (p *profiler) stop() error {
+	if p.cpu != nil {
+		runtimepprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			return fmt.Errorf("failed to write --cpuprofile: %w", err)
+		}
+		p.cpu = nil
+	}
+	if p.memPath == "" {
+		return nil
+	}
+	f, err := os.Create(p.memPath)
+	if err != nil {
+		return fmt.Errorf("failed to create --memprofile: %w", err)
+	}
+	defer f.Close()
+	// Up-to-date allocation statistics
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write --memprofile: %w", err)
+	}
+	return nil
+}
+
+// pprofHandler serves the net/http/pprof endpoints under /debug/pprof/
+// without registering them on http.DefaultServeMux
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -2,43 +2,186 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // --tz works without zoneinfo installed, e.g. in scratch images
 
+	"github.com/jtzemp/dogfetch/internal/anomaly"
+	"github.com/jtzemp/dogfetch/internal/assertion"
+	"github.com/jtzemp/dogfetch/internal/attach"
 	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/encrypt"
 	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/filter"
+	"github.com/jtzemp/dogfetch/internal/grok"
+	"github.com/jtzemp/dogfetch/internal/manifest"
+	"github.com/jtzemp/dogfetch/internal/notify"
+	"github.com/jtzemp/dogfetch/internal/redact"
+	"github.com/jtzemp/dogfetch/internal/siem"
+	"github.com/jtzemp/dogfetch/internal/signing"
+	"github.com/jtzemp/dogfetch/internal/state"
+	"github.com/jtzemp/dogfetch/internal/term"
+	"github.com/jtzemp/dogfetch/internal/topn"
+	"github.com/jtzemp/dogfetch/internal/trace"
 	"github.com/jtzemp/dogfetch/internal/version"
+	"github.com/jtzemp/dogfetch/internal/writer"
+)
+
+// Exit codes
+const (
+	exitOK              = 0
+	exitError           = 1
+	exitAssertionFailed = 3
+	exitPartial         = 4
+	exitEmpty           = 5
 )
 
 // Execute runs the CLI
 func Execute() {
+	if runSubcommand(os.Args[1:]) {
+		return
+	}
+
 	// Define flags
 	versionFlag := flag.Bool("version", false, "Print version information")
 	query := flag.String("query", "", "The filter query (search term)")
+	qf := addQueryFlags(flag.CommandLine)
+	traceID := flag.String("trace-id", "", "Fetch the logs correlated with this trace across services: a decimal or hex trace ID, or a traceparent header (ANDed with --query)")
+	traceSpans := flag.String("trace-spans", "", "Also write the --trace-id trace's spans to this NDJSON file")
 	index := flag.String("index", "main", "Which index to read from")
 	from := flag.String("from", "", "Start date/time (default: 24 hours ago)")
 	to := flag.String("to", "", "End date/time (default: now)")
+	tz := flag.String("tz", "", "Time zone, e.g. Europe/Berlin or Local, for --from and --to without an offset and for times in progress and reports (default: UTC)")
+	last := flag.String("last", "", "Fetch the logs of this long up to now, e.g. 15m, 2h or 7d, instead of --from and --to")
+	window := flag.String("window", "auto", "Fetch the range in windows of this size, oldest first: a duration such as 6h, off, or auto to split ranges longer than a week into 6h windows")
+	planFlag := flag.String("plan", "slices", "How to split the range into windows: slices of --window, balanced to aim for --window-logs logs per window using counts from the aggregation API, or indexes to balance each index's logs separately")
+	windowLogs := flag.Int64("window-logs", 1000000, "Logs per window (balanced and indexes plans)")
+	method := flag.String("method", "get", "Logs Search endpoint: get, or post for queries too long for a URL")
+	validateQuery := flag.Bool("validate-query", false, "Check the query's syntax, then fetch one log with it, before starting, so a query the API rejects fails at once")
 	pageSize := flag.Int("pageSize", 1000, "Results per page (max 5000)")
-	output := flag.String("output", "", "Output file path (default: stdout)")
-	format := flag.String("format", "ndjson", "Output format: json or ndjson")
+	output := flag.String("output", "", "Output file path, a syslog collector URL such as syslog+tcp://siem:514, a webhook URL, or a Firehose delivery stream as firehose://<stream> (default: stdout)")
+	format := flag.String("format", "", "Output format: json, ndjson, msgpack, otlp, cef, leef, text, pretty, sarif, xlsx or aggregate (default: from the --output extension, else ndjson)")
 	cursor := flag.String("cursor", "", "Page cursor for resuming")
-	appendFlag := flag.Bool("append", false, "Append to output file (ndjson only)")
-	errorsOut := flag.String("errors-out", "", "Write errors to file (default: stderr)")
+	cursorDisplay := flag.String("cursor-display", "full", "How cursors appear in progress output and reports: full, hash or truncate")
+	statePath := flag.String("state-file", "", "Record the resume cursor and progress in this file after every page")
+	incremental := flag.Bool("incremental", false, "Fetch from where the last run recorded in --state-file left off up to now, skipping logs it already exported")
+	statusSocket := flag.String("status-socket", "", "Answer each connection to this unix socket with the fetch's status as JSON; SIGUSR1 prints it to stderr")
+	cancelFile := flag.String("cancel-file", "", "Stop gracefully, as on an interrupt, once this file exists (checked after every page)")
+	resume := flag.Bool("resume", false, "Continue the unfinished fetch recorded in --state-file, appending to its output")
+	appendFlag := flag.Bool("append", false, "Append to output file (streamable formats only)")
+	force := flag.Bool("force", false, "With --append, append even if the output doesn't end in a complete NDJSON record")
+	atomic := flag.Bool("atomic", false, "Write the output to <output>.tmp and rename it into place once the export is complete")
+	execRestarts := flag.Int("exec-restarts", 0, "Restart an exec:<command> --output up to this many times if it exits before the fetch is done")
+	webhookBatch := flag.Int("webhook-batch", 0, "Logs per request to an http:// or https:// --output (default 1000)")
+	var webhookHeaders repeatedFlag
+	flag.Var(&webhookHeaders, "webhook-header", "Header sent with every webhook request as Key:value, e.g. 'Authorization: Bearer <token>' (repeatable)")
+	split := flag.Int("split", 0, "Write numbered files of at most this many logs each (logs-00001.ndjson, ...) with a manifest listing them")
+	tee := flag.Bool("tee", false, "Also copy the logs written to --output to stdout, for piping while the file is kept")
+	skipErrors := flag.Bool("skip-errors", false, "When a page still fails after retries, log it and continue with the next window instead of aborting (windowed fetches; --window auto uses 1h windows)")
+	errorsOut := flag.String("errors-out", "", "Append retried requests and failures to this file as JSON records, one per line")
+	cpuProfile := flag.String("cpuprofile", "", "Write a CPU profile of the fetch to this file, for go tool pprof")
+	memProfile := flag.String("memprofile", "", "Write a heap profile to this file when the fetch ends, for go tool pprof")
+	logLevel := flag.String("log-level", "info", "Least severe progress and diagnostics to log: debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "Format of progress and diagnostics on stderr: text, or json for one structured record per line")
+	ttySafe := flag.Bool("tty-safe", false, "When writing to a terminal, show the output a screenful at a time instead of flooding it")
+	noColor := flag.Bool("no-color", false, "Don't color pretty output or progress on a terminal (also set by the NO_COLOR environment variable)")
+	var groupBy stringSliceFlag
+	flag.Var(&groupBy, "group-by", "Fields to group counts by (aggregate format, repeatable)")
+	bucket := flag.Duration("bucket", time.Hour, "Time bucket width (aggregate format)")
+	kThreshold := flag.Int("k-threshold", 5, "Suppress buckets with fewer logs than this (aggregate format)")
+	epsilon := flag.Float64("epsilon", 0, "Add Laplace noise with this privacy budget, 0 disables (aggregate format)")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "Send logs to this OTLP/HTTP endpoint, e.g. http://localhost:4318 (otlp format)")
+	var otlpHeaders repeatedFlag
+	flag.Var(&otlpHeaders, "otlp-header", "Extra header for OTLP export requests as Key=Value (repeatable)")
+	var siemFields repeatedFlag
+	flag.Var(&siemFields, "siem-field", "Map a CEF/LEEF key to a log field as key=field, or drop a default key with key= (repeatable)")
+	var scrub stringSliceFlag
+	flag.Var(&scrub, "scrub", "Scrub common PII from every string field before writing: "+strings.Join(redact.PresetNames(), ", ")+" (comma-separated or repeatable)")
+	var redactions repeatedFlag
+	flag.Var(&redactions, "redact", "Replace matches in a field before writing, as field:/regex/=replacement; omit field: to cover every string field (repeatable)")
+	redactRules := flag.String("redact-rules", "", "Load redaction rules from a YAML file")
+	var parsePatterns repeatedFlag
+	flag.Var(&parsePatterns, "parse", "Extract fields from each message with a grok pattern, e.g. '%{IP:client.ip} %{INT:took:int}ms', or a regex with named groups (repeatable; first match wins)")
+	var filters repeatedFlag
+	flag.Var(&filters, "filter", "Only write logs matching this expression, e.g. 'attributes.duration > 500 && status == \"error\"' (repeatable; all must match)")
+	var textFields stringSliceFlag
+	flag.Var(&textFields, "text-field", "Fields written on each line (text format, comma-separated or repeatable; default: timestamp, status, service, message)")
+	var sarifRuleFields stringSliceFlag
+	flag.Var(&sarifRuleFields, "sarif-rule-field", "Fields tried in order to name each result's rule (sarif format, repeatable; default: workflow.rule.id, rule.id, evt.name, error.kind, service)")
+	envelopeVersion := flag.String("envelope", "", "Wrap every record in a stable, versioned envelope independent of the Datadog API client: v1 (json, ndjson and msgpack)")
+	stitchBy := flag.String("stitch-by", "", "Group logs into one time-ordered document per value of this field, e.g. session_id (ndjson only)")
+	raw := flag.Bool("raw", false, "Write ndjson records exactly as the API sent them instead of encoding them again, which is faster")
+	sidecarIndex := flag.Bool("sidecar-index", false, "Maintain a seek index at <output>.idx for dogfetch slice (ndjson only)")
+	maxMemory := flag.String("max-memory", "", "Cap memory used by buffering output modes, e.g. 512MB; beyond it they spill to temp files")
+	apiURL := flag.String("api-url", "", "Override the Datadog API URL (e.g. a proxy or dogfetch mock --serve)")
+	site := flag.String("site", "", "Datadog site, e.g. datadoghq.eu or us5 (default: DD_SITE, else datadoghq.com)")
+	userAgent := flag.String("user-agent", "", "User-Agent sent with every API request (default: the Datadog API client's)")
+	var headers repeatedFlag
+	flag.Var(&headers, "header", "Extra header sent with every API request as Key:value, e.g. X-Team:payments (repeatable)")
+	debugHTTP := flag.Bool("debug-http", false, "Log every API request to stderr with its status, latency, rate-limit headers and request IDs (keys redacted)")
+	record := flag.String("record", "", "Record API responses to a cassette file")
+	replay := flag.String("replay", "", "Replay API responses from a cassette file instead of calling Datadog")
+	demo := flag.Bool("demo", false, "Fetch realistic synthetic logs generated locally instead of calling Datadog (no credentials needed)")
+	demoCount := flag.Int("demo-count", 1000, "Number of synthetic logs --demo generates over the time range")
+	detectAnomalies := flag.Bool("detect-anomalies", false, "Flag minutes whose log count deviates sharply from the trend in the summary")
+	anomaliesPath := flag.String("anomalies", "", "Write anomalous minutes to this JSON file (implies --detect-anomalies)")
+	anomalyThreshold := flag.Float64("anomaly-threshold", 3, "Z-score beyond which a minute is flagged as anomalous")
+	var topN repeatedFlag
+	flag.Var(&topN, "topn", "Report the N most frequent values of a field in the summary, as field=N (repeatable)")
+	manifestPath := flag.String("manifest", "", "Write a manifest with the SHA-256, record count and size of each output file")
+	var encryptRecipients repeatedFlag
+	flag.Var(&encryptRecipients, "encrypt-recipient", "Encrypt output files as they're written, to an age recipient or recipients file, or gpg:<key> (repeatable)")
+	signKey := flag.String("sign-key", "", "Sign the output file into <output>.sig with this Ed25519 or ECDSA private key (PEM)")
+	signKeyless := flag.Bool("sign-keyless", false, "Sign the output file into <output>.bundle with a Sigstore certificate for the OIDC identity of SIGSTORE_ID_TOKEN or GitHub Actions")
+	fulcioURL := flag.String("fulcio-url", signing.DefaultFulcioURL, "Fulcio instance that certifies --sign-keyless signatures")
+	rekorURL := flag.String("rekor-url", signing.DefaultRekorURL, "Rekor transparency log that records --sign-keyless signatures")
+	assertMinCount := flag.Int("assert-min-count", 0, "Fail the run if fewer logs than this are fetched")
+	var assertNullRates repeatedFlag
+	flag.Var(&assertNullRates, "assert-max-null-rate", "Fail the run if a field is missing on more than a fraction of logs, as field=rate (repeatable)")
+	var assertServices stringSliceFlag
+	flag.Var(&assertServices, "assert-service", "Fail the run if a service is absent from the results (repeatable)")
+	assertMaxCount := flag.Int("assert-max-count", -1, "Fail the run if more logs than this are fetched, e.g. 0 to gate on no matches (default: off)")
+	var assertNoMatch repeatedFlag
+	flag.Var(&assertNoMatch, "assert-no-match", "Fail the run if any log message matches this regular expression (repeatable)")
+	failOnEmpty := flag.Bool("fail-on-empty", false, "Exit with code 5 when no logs match, to catch broken queries or index filters")
+	report := flag.String("report", "", "Write --assert-* results as a report for CI: junit")
+	reportOutput := flag.String("report-output", "dogfetch-junit.xml", "Path of the --report file")
+	notifyURL := flag.String("notify-url", "", "POST a summary of the run to this webhook when the fetch completes, fails or is interrupted")
+	notifyFormat := flag.String("notify-format", "json", "Payload for --notify-url: json, or slack for a Slack incoming webhook")
+	attachTo := flag.String("attach-to", "", "Upload the gzipped export to a ticket once it completes: jira:PROJ-123 or linear:ENG-123")
+	attachMaxSize := flag.String("attach-max-size", "10MB", "Largest compressed export --attach-to uploads")
+	annotate := flag.Bool("annotate-github", false, "Emit GitHub Actions annotations for failures and findings and write a job summary to $GITHUB_STEP_SUMMARY")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "dogfetch - Fetch logs from Datadog\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
 		fmt.Fprintf(os.Stderr, "  dogfetch --query 'service:web status:error'\n")
 		fmt.Fprintf(os.Stderr, "  dogfetch --query 'service:web' --output logs.ndjson\n")
-		fmt.Fprintf(os.Stderr, "  dogfetch --version\n\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch --version\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch <command> --help\n\n")
+		printSubcommands()
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nEnvironment Variables:\n")
-		fmt.Fprintf(os.Stderr, "  DD_API_KEY   Datadog API key (required)\n")
-		fmt.Fprintf(os.Stderr, "  DD_APP_KEY   Datadog Application key (required)\n")
-		fmt.Fprintf(os.Stderr, "  DD_SITE      Datadog site (optional, default: datadoghq.com)\n")
+		fmt.Fprintf(os.Stderr, "  DD_API_KEY   Datadog API key (required unless --replay or --demo)\n")
+		fmt.Fprintf(os.Stderr, "  DD_APP_KEY   Datadog Application key (required unless --replay or --demo)\n")
+		fmt.Fprintf(os.Stderr, "  DD_SITE      Datadog site (optional, default: datadoghq.com; --site overrides it)\n")
+		fmt.Fprintf(os.Stderr, "\nExit Codes:\n")
+		fmt.Fprintf(os.Stderr, "  0  Success\n")
+		fmt.Fprintf(os.Stderr, "  1  Error\n")
+		fmt.Fprintf(os.Stderr, "  3  One or more --assert-* checks failed\n")
+		fmt.Fprintf(os.Stderr, "  4  --skip-errors skipped windows, so the export is incomplete\n")
+		fmt.Fprintf(os.Stderr, "  5  --fail-on-empty and no logs matched\n")
 	}
 
 	flag.Parse()
@@ -49,8 +192,10 @@ func Execute() {
 		os.Exit(0)
 	}
 
-	// Setup error output
+	// Setup error output: messages go to stderr, and --errors-out collects
+	// retries and failures as JSON records
 	errOut := os.Stderr
+	var errorLog *fetcher.ErrorLog
 	if *errorsOut != "" {
 		f, err := os.OpenFile(*errorsOut, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
@@ -58,43 +203,287 @@ func Execute() {
 			os.Exit(1)
 		}
 		defer f.Close()
-		errOut = f
+		errorLog = fetcher.NewErrorLog(f)
+	}
+
+	// Progress and diagnostics only ever go to errOut, keeping stdout for data
+	var logLevelValue slog.Level
+	if err := logLevelValue.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintf(errOut, "Configuration error: --log-level must be debug, info, warn or error, got '%s'\n", *logLevel)
+		os.Exit(exitError)
+	}
+	if *logFormat != "text" && *logFormat != "json" {
+		fmt.Fprintf(errOut, "Configuration error: --log-format must be text or json, got '%s'\n", *logFormat)
+		os.Exit(exitError)
 	}
 
 	// Build config
 	cfg := &config.Config{
-		Query:      *query,
-		Index:      *index,
-		PageSize:   int32(*pageSize),
-		OutputPath: *output,
-		Format:     *format,
-		Cursor:     *cursor,
-		Append:     *appendFlag,
-		APIKey:     os.Getenv("DD_API_KEY"),
-		AppKey:     os.Getenv("DD_APP_KEY"),
-		Site:       os.Getenv("DD_SITE"),
+		Query:            *query,
+		Index:            *index,
+		Method:           *method,
+		Plan:             *planFlag,
+		WindowLogs:       *windowLogs,
+		PageSize:         int32(*pageSize),
+		OutputPath:       *output,
+		Format:           *format,
+		Cursor:           *cursor,
+		CursorDisplay:    *cursorDisplay,
+		StatePath:        *statePath,
+		Append:           *appendFlag,
+		Force:            *force,
+		Atomic:           *atomic,
+		ExecRestarts:     *execRestarts,
+		WebhookBatch:     *webhookBatch,
+		Tee:              *tee,
+		Split:            *split,
+		SkipErrors:       *skipErrors,
+		AggregateBy:      groupBy,
+		AggregateBucket:  *bucket,
+		AggregateK:       *kThreshold,
+		AggregateEpsilon: *epsilon,
+		StitchBy:         *stitchBy,
+		TextFields:       textFields,
+		NoColor:          *noColor,
+		SARIFRuleFields:  sarifRuleFields,
+		Envelope:         *envelopeVersion,
+		SidecarIndex:     *sidecarIndex,
+		Raw:              *raw,
+		OTLPEndpoint:     *otlpEndpoint,
+		APIKey:           os.Getenv("DD_API_KEY"),
+		AppKey:           os.Getenv("DD_APP_KEY"),
+		APIURL:           *apiURL,
+		UserAgent:        *userAgent,
+		DebugHTTP:        *debugHTTP,
+		RecordPath:       *record,
+		ReplayPath:       *replay,
+	}
+
+	// The indexes plan covers every index unless --index is given
+	if cfg.Plan == "indexes" {
+		cfg.Index = ""
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "index" {
+				cfg.Index = *index
+			}
+		})
+	}
+
+	// An explicit --format wins over the extension of --output
+	if cfg.Format == "" {
+		inferred, err := writer.InferFormat(cfg.OutputPath)
+		if err != nil {
+			fmt.Fprintf(errOut, "Configuration error: %v; set --format\n", err)
+			os.Exit(exitError)
+		}
+		cfg.Format = inferred
+		if cfg.Format == "" {
+			cfg.Format = writer.DefaultFormat
+		}
+	}
+
+	builtQuery, err := qf.build(cfg.Query)
+	if err != nil {
+		fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg.Query = builtQuery
+
+	// --query only narrows the logs; the trace's spans are all exported
+	var spansQuery string
+	if *traceID != "" {
+		q, err := trace.Query(*traceID, cfg.Query)
+		if err != nil {
+			fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.Query = q
+		spansQuery, _ = trace.Query(*traceID, "")
+	}
+	if *traceSpans != "" {
+		if *traceID == "" {
+			fmt.Fprintf(errOut, "Configuration error: --trace-spans requires --trace-id\n")
+			os.Exit(exitError)
+		}
+		if *demo || cfg.ReplayPath != "" {
+			fmt.Fprintf(errOut, "Configuration error: --trace-spans can't be used with --demo or --replay\n")
+			os.Exit(exitError)
+		}
+	}
+
+	if *validateQuery && !checkQuery(errOut, cfg.Query) {
+		os.Exit(exitError)
+	}
+
+	if *demo {
+		if *demoCount < 1 {
+			fmt.Fprintf(errOut, "Configuration error: --demo-count must be positive, got %d\n", *demoCount)
+			os.Exit(exitError)
+		}
+		cfg.Demo = *demoCount
+		// The synthetic logs match any query
+		if cfg.Query == "" {
+			cfg.Query = "*"
+		}
+	}
+
+	size, err := config.ParseByteSize(*maxMemory)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error parsing --max-memory: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg.MaxMemory = size
+
+	if cfg.Site, err = resolveSite(*site); err != nil {
+		fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if cfg.Headers, err = config.ParseHeaders(headers); err != nil {
+		fmt.Fprintf(errOut, "Error parsing --header: %v\n", err)
+		os.Exit(exitError)
+	}
+	if cfg.WebhookHeaders, err = config.ParseWebhookHeaders(webhookHeaders); err != nil {
+		fmt.Fprintf(errOut, "Error parsing --webhook-header: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	if len(otlpHeaders) > 0 {
+		cfg.OTLPHeaders = make(map[string]string, len(otlpHeaders))
+		for _, h := range otlpHeaders {
+			key, value, ok := strings.Cut(h, "=")
+			if !ok || key == "" {
+				fmt.Fprintf(errOut, "Error parsing --otlp-header: expected Key=Value, got '%s'\n", h)
+				os.Exit(exitError)
+			}
+			cfg.OTLPHeaders[key] = value
+		}
+	}
+
+	for _, spec := range siemFields {
+		field, err := siem.ParseField(spec)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --siem-field: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.SIEMFields = append(cfg.SIEMFields, field)
+	}
+
+	scrubbers, err := redact.Presets(scrub)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error parsing --scrub: %v\n", err)
+		os.Exit(exitError)
+	}
+	cfg.Redactions = scrubbers
+	if *redactRules != "" {
+		rules, err := redact.LoadRules(*redactRules)
+		if err != nil {
+			fmt.Fprintf(errOut, "Failed to load --redact-rules: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.Redactions = append(cfg.Redactions, rules...)
+	}
+	for _, spec := range redactions {
+		rule, err := redact.ParseRule(spec)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --redact: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.Redactions = append(cfg.Redactions, rule)
+	}
+	for _, expr := range parsePatterns {
+		p, err := grok.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --parse: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.ParsePatterns = append(cfg.ParsePatterns, p)
+	}
+	for _, expr := range filters {
+		f, err := filter.Parse(expr)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --filter: %v\n", err)
+			os.Exit(exitError)
+		}
+		cfg.Filters = append(cfg.Filters, f)
+	}
+	if len(encryptRecipients) > 0 {
+		cfg.Encrypt, err = encrypt.NewEncrypter(encryptRecipients)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --encrypt-recipient: %v\n", err)
+			os.Exit(exitError)
+		}
 	}
 
 	// Parse time range
+	if *tz != "" {
+		if cfg.Location, err = loadTZ(*tz); err != nil {
+			fmt.Fprintf(errOut, "Error parsing --tz: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	loc := cfg.TimeLocation()
 	if *from != "" {
-		parsedFrom, err := config.ParseTime(*from)
+		parsedFrom, err := config.ParseTimeIn(*from, loc)
 		if err != nil {
 			fmt.Fprintf(errOut, "Error parsing --from: %v\n", err)
 			os.Exit(1)
 		}
 		cfg.From = parsedFrom
-	} else {
-		cfg.From = config.DefaultFrom()
 	}
 
 	if *to != "" {
-		parsedTo, err := config.ParseTime(*to)
+		parsedTo, err := config.ParseTimeIn(*to, loc)
 		if err != nil {
 			fmt.Fprintf(errOut, "Error parsing --to: %v\n", err)
 			os.Exit(1)
 		}
 		cfg.To = parsedTo
 	}
+	if *last != "" && *incremental {
+		fmt.Fprintf(errOut, "Configuration error: --last cannot be used with --incremental, which fetches from where the last run left off\n")
+		os.Exit(exitError)
+	}
+	if err := cfg.SetLast(*last, time.Now().UTC()); err != nil {
+		fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	// Check the run against the state file before it gets overwritten
+	if *resume && cfg.StatePath == "" {
+		fmt.Fprintf(errOut, "Configuration error: --resume requires --state-file\n")
+		os.Exit(exitError)
+	}
+	// The locks last until the process exits, however it exits
+	release, err := lockFiles(cfg)
+	if err != nil {
+		fmt.Fprintf(errOut, "%v\n", err)
+		os.Exit(exitError)
+	}
+	defer release()
+	var saved *state.State
+	if cfg.StatePath != "" {
+		saved, err = state.Read(cfg.StatePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(errOut, "Failed to read --state-file: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	if *incremental {
+		err = cfg.StartIncremental(saved, time.Now().UTC())
+	} else if cfg.StatePath != "" {
+		err = cfg.Resume(saved, *resume)
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if cfg.From.IsZero() {
+		cfg.From = config.DefaultFrom()
+	}
+	if err := cfg.SetWindow(*window, time.Now()); err != nil {
+		fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+		os.Exit(exitError)
+	}
 
 	// Validate config
 	if err := cfg.Validate(); err != nil {
@@ -103,30 +492,536 @@ func Execute() {
 		os.Exit(1)
 	}
 
+	if *signKey != "" && !cfg.FileOutput() {
+		fmt.Fprintf(errOut, "Configuration error: --sign-key requires --output to a file\n")
+		os.Exit(exitError)
+	}
+	if *signKeyless && !cfg.FileOutput() {
+		fmt.Fprintf(errOut, "Configuration error: --sign-keyless requires --output to a file\n")
+		os.Exit(exitError)
+	}
+	if *signKeyless && *signKey != "" {
+		fmt.Fprintf(errOut, "Configuration error: --sign-key and --sign-keyless cannot be used together\n")
+		os.Exit(exitError)
+	}
+	if *manifestPath != "" && !cfg.FileOutput() {
+		fmt.Fprintf(errOut, "Configuration error: --manifest requires --output to a file\n")
+		os.Exit(exitError)
+	}
+	// A loader needs to know which chunks make up the export
+	if cfg.Split > 0 && *manifestPath == "" {
+		*manifestPath = splitManifestPath(cfg.OutputPath)
+	}
+	if *notifyURL != "" {
+		if !strings.HasPrefix(*notifyURL, "http://") && !strings.HasPrefix(*notifyURL, "https://") {
+			fmt.Fprintf(errOut, "Configuration error: --notify-url must be an http or https URL, got '%s'\n", *notifyURL)
+			os.Exit(exitError)
+		}
+		if !slices.Contains(notify.Formats, *notifyFormat) {
+			fmt.Fprintf(errOut, "Configuration error: --notify-format must be one of %s, got '%s'\n", strings.Join(notify.Formats, ", "), *notifyFormat)
+			os.Exit(exitError)
+		}
+	}
+	var attachTarget *attach.Target
+	var attachLimit int64
+	if *attachTo != "" {
+		if !cfg.FileOutput() || cfg.Split > 0 {
+			fmt.Fprintf(errOut, "Configuration error: --attach-to requires --output to a single file\n")
+			os.Exit(exitError)
+		}
+		target, err := attach.FromEnv(*attachTo, os.Getenv)
+		if err != nil {
+			fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+			os.Exit(exitError)
+		}
+		attachTarget = &target
+		attachLimit, err = config.ParseByteSize(*attachMaxSize)
+		if err != nil || attachLimit <= 0 {
+			fmt.Fprintf(errOut, "Configuration error: --attach-max-size must be a size such as 10MB, got '%s'\n", *attachMaxSize)
+			os.Exit(exitError)
+		}
+	}
+	// One left over from an earlier stop blocks new runs until it's removed
+	if *cancelFile != "" && fetcher.NewCancelFile(*cancelFile, nil).Exists() {
+		fmt.Fprintf(errOut, "Cancel file %s exists; remove it to run\n", *cancelFile)
+		os.Exit(exitError)
+	}
+
+	// Load the key up front so a bad key fails before the export runs
+	var sign func(path string) error
+	if *signKey != "" {
+		signer, err := signing.LoadPrivateKey(*signKey)
+		if err != nil {
+			fmt.Fprintf(errOut, "Failed to load --sign-key: %v\n", err)
+			os.Exit(exitError)
+		}
+		sign = func(path string) error {
+			return signing.SignFile(signer, path, path+".sig")
+		}
+	}
+	if *signKeyless {
+		keyless, err := signing.NewKeyless(*fulcioURL, *rekorURL)
+		if err != nil {
+			fmt.Fprintf(errOut, "Configuration error: %v\n", err)
+			os.Exit(exitError)
+		}
+		sign = func(path string) error {
+			return keyless.SignFile(path, path+signing.BundleSuffix)
+		}
+	}
+
+	// Build data quality assertions
+	assertions := assertion.NewSet()
+	if *assertMinCount > 0 {
+		assertions.Add(assertion.NewMinCount(*assertMinCount))
+	}
+	for _, spec := range assertNullRates {
+		a, err := assertion.ParseMaxNullRate(spec)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --assert-max-null-rate: %v\n", err)
+			os.Exit(exitError)
+		}
+		assertions.Add(a)
+	}
+	if len(assertServices) > 0 {
+		assertions.Add(assertion.NewRequiredServices(assertServices...))
+	}
+	if *assertMaxCount >= 0 {
+		assertions.Add(assertion.NewMaxCount(*assertMaxCount))
+	}
+	for _, pattern := range assertNoMatch {
+		a, err := assertion.NewNoMatch(pattern)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --assert-no-match: %v\n", err)
+			os.Exit(exitError)
+		}
+		assertions.Add(a)
+	}
+	if *report != "" {
+		if *report != "junit" {
+			fmt.Fprintf(errOut, "Configuration error: --report must be junit, got '%s'\n", *report)
+			os.Exit(exitError)
+		}
+		if assertions.Len() == 0 {
+			fmt.Fprintf(errOut, "Configuration error: --report requires at least one --assert-* check\n")
+			os.Exit(exitError)
+		}
+	}
+
 	// Create fetcher
-	f, err := fetcher.New(cfg, errOut)
+	// Page output to a terminal; redirected output is written in full as usual
+	var stdout io.Writer = os.Stdout
+	var pager *term.Pager
+	if *ttySafe && cfg.OutputPath == "" && term.IsTerminal(os.Stdout) {
+		in := os.Stdin
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			defer tty.Close()
+			in = tty
+		}
+		if term.IsTerminal(in) {
+			pager = term.NewPager(os.Stdout, in, term.Width(os.Stdout), term.Height(os.Stdout))
+			stdout = pager
+		} else {
+			fmt.Fprintf(errOut, "No terminal to read answers from; --tty-safe won't page the output\n")
+		}
+	}
+
+	f, err := fetcher.NewWithStdout(cfg, stdout, errOut)
 	if err != nil {
 		fmt.Fprintf(errOut, "Failed to create fetcher: %v\n", err)
 		os.Exit(1)
 	}
+	if *logFormat == "json" {
+		logger := slog.New(slog.NewJSONHandler(errOut, &slog.HandlerOptions{Level: logLevelValue}))
+		slog.SetDefault(logger)
+		reporter := fetcher.NewLogReporter(logger)
+		f.SetProgressReporter(reporter)
+		f.SetDiagnosticReporter(reporter)
+	} else {
+		slog.SetDefault(slog.New(slog.NewTextHandler(errOut, &slog.HandlerOptions{Level: logLevelValue})))
+		reporter := fetcher.NewTextReporter(errOut)
+		reporter.SetLevel(logLevelValue)
+		// Piped and redirected stderr gets plain lines, one per page
+		if term.IsTerminal(os.Stderr) {
+			width := term.Width(os.Stderr)
+			if width == 0 {
+				width = 80
+			}
+			reporter.SetLive(width)
+			reporter.SetColor(term.Color(os.Stderr, *noColor))
+		}
+		f.SetProgressReporter(reporter)
+		f.SetDiagnosticReporter(reporter)
+	}
+	if errorLog != nil {
+		f.AddDiagnosticReporter(errorLog)
+	}
+	tracker := fetcher.NewTracker()
+	f.AddProgressReporter(tracker)
+	f.AddDiagnosticReporter(tracker)
+	if assertions.Len() > 0 {
+		f.AddObserver(assertions)
+	}
+	var topFields []*topn.Field
+	for _, spec := range topN {
+		field, err := topn.ParseField(spec)
+		if err != nil {
+			fmt.Fprintf(errOut, "Error parsing --topn: %v\n", err)
+			os.Exit(exitError)
+		}
+		topFields = append(topFields, field)
+		f.AddObserver(field)
+	}
+	var detector *anomaly.Detector
+	if *detectAnomalies || *anomaliesPath != "" {
+		detector = anomaly.NewDetector(*anomalyThreshold)
+		f.AddObserver(detector)
+	}
 
 	// Setup signal handling for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	sigChan := make(chan os.Signal, 1)
-	// os.Interrupt works on both Unix and Windows (Ctrl+C)
-	signal.Notify(sigChan, os.Interrupt)
+	signal.Notify(sigChan, interruptSignals...)
 
 	go func() {
-		<-sigChan
-		fmt.Fprintf(errOut, "\nReceived interrupt signal, shutting down gracefully...\n")
+		sig := <-sigChan
+		fmt.Fprintf(errOut, "\nReceived %s, shutting down gracefully...\n", signalName(sig))
 		cancel()
 	}()
+	if cfg.FileOutput() {
+		reopenOnHangup(ctx, errOut)
+	}
+	if err := serveStatus(ctx, errOut, tracker, *statusSocket); err != nil {
+		fmt.Fprintf(errOut, "Failed to listen on --status-socket: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	// A reader of the --tee copy exiting must not kill the fetch; writes to
+	// stdout fail instead, and the tee stops copying
+	if cfg.Tee {
+		signal.Ignore(syscall.SIGPIPE)
+	}
+
+	// Quitting the pager stops the fetch as an interrupt would
+	if pager != nil {
+		pager.OnQuit = func() {
+			fmt.Fprintf(errOut, "\nOutput stopped, shutting down gracefully...\n")
+			cancel()
+		}
+	}
+
+	// A cancel file works like an interrupt for orchestrators that can't
+	// deliver signals
+	if *cancelFile != "" {
+		f.AddObserver(fetcher.NewCancelFile(*cancelFile, func() {
+			fmt.Fprintf(errOut, "\nFound cancel file %s, shutting down gracefully...\n", *cancelFile)
+			cancel()
+		}))
+	}
+
+	// notifyOutcome reports how the run ended to --notify-url and, when it
+	// failed, to --errors-out
+	notifyOutcome := func(status string, err error) {
+		if errorLog != nil && status == notify.Failed {
+			errorLog.Failure(err)
+		}
+		if *notifyURL != "" {
+			notifyRun(errOut, *notifyURL, *notifyFormat, cfg, f.Stats(), status, err)
+		}
+	}
+
+	// A replay never asks the API, so there's nothing to probe
+	if *validateQuery && cfg.ReplayPath == "" {
+		if err := f.Probe(ctx); err != nil {
+			var probeErr *fetcher.ProbeError
+			if errors.As(err, &probeErr) && probeErr.Rejected() {
+				fmt.Fprintf(errOut, "Query check failed: %v\n", err)
+				os.Exit(exitError)
+			}
+			fmt.Fprintf(errOut, "Could not check the query: %v; fetching anyway\n", err)
+		}
+	}
 
 	// Execute fetch
-	if err := f.Fetch(ctx); err != nil {
+	prof, err := startProfile(*cpuProfile, *memProfile)
+	if err != nil {
+		fmt.Fprintf(errOut, "%v\n", err)
+		os.Exit(exitError)
+	}
+	started := time.Now()
+	err = f.Fetch(ctx)
+	if profErr := prof.stop(); profErr != nil {
+		fmt.Fprintf(errOut, "%v\n", profErr)
+	}
+	if err != nil {
 		fmt.Fprintf(errOut, "Fetch failed: %v\n", err)
+		notifyOutcome(notify.Failed, err)
+		if *report != "" {
+			writeReport(errOut, *reportOutput, cfg, started, f.Stats(), assertions, err)
+		}
+		if *annotate {
+			annotateGitHub(errOut, githubRun{cfg: cfg, stats: f.Stats(), assertions: assertions, topFields: topFields, err: err, failed: true})
+		}
 		os.Exit(1)
 	}
+
+	if *traceSpans != "" && ctx.Err() == nil {
+		if err := writeTraceSpans(ctx, errOut, cfg, spansQuery, *traceSpans); err != nil {
+			fmt.Fprintf(errOut, "Failed to export trace spans: %v\n", err)
+			notifyOutcome(notify.Failed, fmt.Errorf("failed to export trace spans: %w", err))
+			os.Exit(exitError)
+		}
+	}
+	if chunks := f.Stats().Chunks; len(chunks) > 0 {
+		fmt.Fprintf(errOut, "Wrote %d files of up to %d logs, %s to %s\n", len(chunks), cfg.Split, chunks[0].Path, chunks[len(chunks)-1].Path)
+	}
+	for _, field := range topFields {
+		reportTopN(errOut, field)
+	}
+	skipped := f.Stats().Skipped
+	reportSkipped(errOut, cfg, skipped)
+	var anomalies []anomaly.Window
+	if detector != nil {
+		anomalies = detector.Anomalies()
+		reportAnomalies(errOut, cfg, anomalies)
+		if *anomaliesPath != "" {
+			if err := detector.WriteFile(*anomaliesPath); err != nil {
+				fmt.Fprintf(errOut, "Failed to write anomalies: %v\n", err)
+				notifyOutcome(notify.Failed, fmt.Errorf("failed to write anomalies: %w", err))
+				os.Exit(exitError)
+			}
+		}
+	}
+
+	// Only a complete export is worth vouching for
+	if ctx.Err() == nil && len(skipped) == 0 {
+		if sign != nil {
+			for _, path := range outputFiles(cfg, f.Stats()) {
+				if err := sign(path); err != nil {
+					fmt.Fprintf(errOut, "Failed to sign output: %v\n", err)
+					notifyOutcome(notify.Failed, fmt.Errorf("failed to sign output: %w", err))
+					os.Exit(exitError)
+				}
+			}
+		}
+		if *manifestPath != "" {
+			if err := writeManifest(*manifestPath, cfg, f.Stats(), sign); err != nil {
+				fmt.Fprintf(errOut, "Failed to write manifest: %v\n", err)
+				notifyOutcome(notify.Failed, fmt.Errorf("failed to write manifest: %w", err))
+				os.Exit(exitError)
+			}
+		}
+		if attachTarget != nil {
+			if err := attachExport(errOut, *attachTarget, cfg.OutputPath, *attachMaxSize, attachLimit); err != nil {
+				fmt.Fprintf(errOut, "Failed to attach export to %s: %v\n", *attachTo, err)
+				notifyOutcome(notify.Failed, fmt.Errorf("failed to attach export: %w", err))
+				os.Exit(exitError)
+			}
+		}
+	}
+
+	var incomplete error
+	if ctx.Err() != nil {
+		incomplete = fmt.Errorf("interrupted; %s", cfg.ResumeHint(f.Stats().Cursor))
+	} else if len(skipped) > 0 {
+		incomplete = fmt.Errorf("skipped %d window(s) after pages failed", len(skipped))
+	}
+	if *report != "" {
+		writeReport(errOut, *reportOutput, cfg, started, f.Stats(), assertions, incomplete)
+	}
+	if *annotate {
+		annotateGitHub(errOut, githubRun{cfg: cfg, stats: f.Stats(), assertions: assertions, topFields: topFields, anomalies: anomalies, err: incomplete})
+	}
+
+	// Assertions only make sense for a complete run, and so does finding it
+	// empty
+	var failures []assertion.Result
+	if assertions.Len() > 0 && ctx.Err() == nil {
+		failures = assertions.Failures()
+	}
+	empty := *failOnEmpty && ctx.Err() == nil && len(skipped) == 0 && f.Stats().Logs == 0
+	switch {
+	case ctx.Err() != nil:
+		notifyOutcome(notify.Interrupted, incomplete)
+	case len(failures) > 0:
+		names := make([]string, len(failures))
+		for i, failure := range failures {
+			names[i] = failure.Name
+		}
+		notifyOutcome(notify.Failed, fmt.Errorf("data quality assertions failed: %s", strings.Join(names, ", ")))
+	case empty:
+		notifyOutcome(notify.Failed, errors.New("no logs matched"))
+	default:
+		notifyOutcome(notify.Completed, incomplete)
+	}
+	if len(failures) > 0 {
+		fmt.Fprintf(errOut, "\nData quality assertions failed:\n")
+		for _, failure := range failures {
+			fmt.Fprintf(errOut, "  - %s: %v\n", failure.Name, failure.Err)
+		}
+		os.Exit(exitAssertionFailed)
+	}
+	if empty {
+		fmt.Fprintf(errOut, "\nNo logs matched; check the query, --index and any --filter (--fail-on-empty)\n")
+		os.Exit(exitEmpty)
+	}
+	if len(skipped) > 0 {
+		os.Exit(exitPartial)
+	}
+}
+
+// writeTraceSpans exports the spans query finds over the fetch's time range
+// to path
+func writeTraceSpans(ctx context.Context, errOut io.Writer, cfg *config.Config, query, path string) error {
+	opts := fetcher.ConfigOptions(cfg)
+	if cfg.DebugHTTP {
+		opts = append(opts, fetcher.WithDebug(errOut))
+	}
+	client := fetcher.NewClient(cfg.APIKey, cfg.AppKey, cfg.Site, opts...)
+
+	to := cfg.To
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	spans, err := client.Spans(ctx, query, cfg.From, to)
+	if err != nil {
+		return err
+	}
+	if err := trace.WriteSpans(path, spans); err != nil {
+		return err
+	}
+	fmt.Fprintf(errOut, "Wrote %d spans to %s\n", len(spans), path)
+	return nil
+}
+
+// reportSkipped lists the windows --skip-errors gave up on in the run
+// summary, with the flags to fetch each again
+func reportSkipped(out io.Writer, cfg *config.Config, skipped []fetcher.Skipped) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nSkipped %d window(s) after pages failed; the export is missing their logs:\n", len(skipped))
+	for _, s := range skipped {
+		fmt.Fprintf(out, "  - %s (page %d: %v)\n", s.Window, s.Page, s.Err)
+		refetch := fmt.Sprintf("--from %s --to %s", cfg.FormatTime(s.Window.From.UTC()), cfg.FormatTime(s.Window.To.UTC()))
+		if s.Window.Index != "" {
+			refetch += " --index " + s.Window.Index
+		}
+		fmt.Fprintf(out, "    refetch with %s\n", refetch)
+	}
+}
+
+// writeReport saves assertion results as a JUnit report; fetchErr marks a run
+// that didn't complete
+func writeReport(errOut io.Writer, path string, cfg *config.Config, started time.Time, stats fetcher.Stats, assertions *assertion.Set, fetchErr error) {
+	suite := assertion.Suite{
+		Name:      "dogfetch",
+		Timestamp: started,
+		Duration:  stats.Duration,
+		Properties: map[string]string{
+			"query": cfg.Query,
+			"index": cfg.Index,
+			"from":  cfg.FormatTime(cfg.From),
+			"to":    cfg.FormatTime(cfg.To),
+			"logs":  fmt.Sprint(stats.Logs),
+
+			"bytes_transferred": fmt.Sprint(stats.Transferred),
+			"bytes_written":     fmt.Sprint(stats.Output),
+		},
+		Err: fetchErr,
+	}
+	if err := assertion.WriteJUnitFile(path, suite, assertions.Results()); err != nil {
+		fmt.Fprintf(errOut, "Failed to write --report: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// reportTopN lists the most frequent values of a field in the run summary
+func reportTopN(out io.Writer, field *topn.Field) {
+	entries := field.Top()
+	fmt.Fprintf(out, "\nTop %s:\n", field.Name())
+	if len(entries) == 0 {
+		fmt.Fprintf(out, "  (no values)\n")
+		return
+	}
+
+	for _, e := range entries {
+		fmt.Fprintf(out, "  %8d  %s", e.Count, e.Value)
+		if e.Error > 0 {
+			fmt.Fprintf(out, " (may be overcounted by up to %d)", e.Error)
+		}
+		fmt.Fprintf(out, "\n")
+	}
+}
+
+// reportAnomalies lists the anomalous minutes in the run summary
+func reportAnomalies(out io.Writer, cfg *config.Config, windows []anomaly.Window) {
+	if len(windows) == 0 {
+		fmt.Fprintf(out, "No anomalous minutes detected\n")
+		return
+	}
+
+	fmt.Fprintf(out, "\nAnomalous minutes:\n")
+	for _, w := range windows {
+		fmt.Fprintf(out, "  %s  %d logs (expected ~%.0f, z=%+.1f)\n", cfg.FormatTime(w.Start), w.Count, w.Expected, w.ZScore)
+	}
+}
+
+// outputFiles lists the files a fetch wrote: its --split chunks, or the
+// output file
+func outputFiles(cfg *config.Config, stats fetcher.Stats) []string {
+	if len(stats.Chunks) == 0 {
+		return []string{cfg.OutputPath}
+	}
+	paths := make([]string, len(stats.Chunks))
+	for i, chunk := range stats.Chunks {
+		paths[i] = chunk.Path
+	}
+	return paths
+}
+
+// splitManifestPath is where a --split export's manifest goes without
+// --manifest: next to the chunks, named after the output, e.g.
+// logs.manifest.json for logs.ndjson.gz
+func splitManifestPath(output string) string {
+	base := strings.TrimSuffix(output, ".gz")
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".manifest.json"
+}
+
+// writeManifest records the checksum, size and record count of the output
+// file, or of every --split chunk, signing the manifest itself when sign is
+// given
+func writeManifest(path string, cfg *config.Config, stats fetcher.Stats, sign func(path string) error) error {
+	m := manifest.New()
+	if len(stats.Chunks) > 0 {
+		for _, chunk := range stats.Chunks {
+			if err := m.AddFile(filepath.Dir(path), chunk.Path, chunk.Records); err != nil {
+				return err
+			}
+		}
+	} else {
+		// An appended NDJSON file holds more than this run fetched, so count
+		// it. Encrypted output can't be appended, and its lines aren't records
+		records := stats.Logs
+		if cfg.Format == "ndjson" && cfg.Encrypt == nil {
+			n, err := manifest.CountLines(cfg.OutputPath)
+			if err != nil {
+				return err
+			}
+			records = n
+		}
+		if err := m.AddFile(filepath.Dir(path), cfg.OutputPath, records); err != nil {
+			return err
+		}
+	}
+	if err := m.Write(path); err != nil {
+		return err
+	}
+
+	if sign != nil {
+		return sign(path)
+	}
+	return nil
 }
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/encrypt"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/manifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteManifestEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	e, err := encrypt.NewEncrypter([]string{identity.Recipient().String()})
+	require.NoError(t, err)
+
+	var logs strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&logs, "{\"id\":\"%d\"}\n", i)
+	}
+	output := filepath.Join(dir, "logs.ndjson.age")
+	f, err := os.Create(output)
+	require.NoError(t, err)
+	w, err := e.Encrypt(f)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(logs.String()))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	cfg := &config.Config{Format: "ndjson", OutputPath: output, Encrypt: e}
+	path := filepath.Join(dir, "manifest.json")
+	require.NoError(t, writeManifest(path, cfg, fetcher.Stats{Logs: 500}, nil))
+
+	m, err := manifest.Read(path)
+	require.NoError(t, err)
+	require.Len(t, m.Files, 1)
+	assert.Equal(t, "logs.ndjson.age", m.Files[0].Path)
+	assert.Equal(t, 500, m.Files[0].Records)
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/daemon"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+// runDaemon repeats incremental fetches on an interval until interrupted
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	ff := addFetchFlags(fs)
+	every := fs.String("every", "5m", "How often to fetch, measured from the start of each run, e.g. 30s, 5m or 1h")
+	output := fs.String("output", "", "File the logs are appended to (default: stdout)")
+	statePath := fs.String("state-file", "", "State file recording the watermark between runs (required)")
+	healthAddr := fs.String("health-addr", "", "Serve /healthz, /status and Prometheus /metrics on this address, e.g. 127.0.0.1:8080")
+	pprofAddr := fs.String("pprof-addr", "", "Serve Go's pprof profiles under /debug/pprof/ on this address, e.g. 127.0.0.1:6060 (keep it private)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch run - Fetch new logs on an interval as a long-lived process\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch run --every 5m --query 'service:web' --state-file web.state.json --output web.ndjson\n\n")
+		fmt.Fprintf(os.Stderr, "Each run is an --incremental fetch: it exports the logs since the last run and\n")
+		fmt.Fprintf(os.Stderr, "moves the watermark in --state-file. --from only sets where the first run starts.\n")
+		fmt.Fprintf(os.Stderr, "On interrupt the current run stops after its page and the next start resumes it.\n")
+		fmt.Fprintf(os.Stderr, "SIGHUP reopens --output before the next page, for logrotate.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	interval, err := config.ParseDuration(*every)
+	if err != nil || interval <= 0 {
+		fmt.Fprintf(os.Stderr, "--every must be a positive duration, got '%s'\n", *every)
+		return exitError
+	}
+	if *statePath == "" {
+		fmt.Fprintf(os.Stderr, "--state-file is required\n")
+		fs.Usage()
+		return exitError
+	}
+	if *ff.to != "" {
+		fmt.Fprintf(os.Stderr, "--to cannot be used; every run fetches up to now\n")
+		return exitError
+	}
+
+	base, err := ff.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	if *ff.from == "" {
+		base.From = time.Time{}
+	}
+	base.OutputPath = *output
+	base.StatePath = *statePath
+	base.Append = *output != ""
+
+	// One daemon per output and state file
+	release, err := lockFiles(base)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	defer release()
+
+	// Catch configuration mistakes before the first run rather than retrying them
+	if _, err := prepareRun(*base); err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return exitError
+	}
+
+	d := daemon.New(interval, func(ctx context.Context, rec daemon.Recorder) (daemon.Result, error) {
+		cfg, err := prepareRun(*base)
+		if err != nil {
+			return daemon.Result{}, err
+		}
+		f, err := fetcher.New(cfg, os.Stderr)
+		if err != nil {
+			return daemon.Result{}, err
+		}
+		reporter := newRunReporter(rec, cfg.OutputPath)
+		f.SetProgressReporter(reporter)
+		f.SetDiagnosticReporter(reporter)
+		err = f.Fetch(ctx)
+		stats := f.Stats()
+		return daemon.Result{Logs: stats.Logs, Pages: stats.Pages, From: cfg.From, To: cfg.To}, err
+	}, os.Stderr)
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+	if base.FileOutput() {
+		reopenOnHangup(ctx, os.Stderr)
+	}
+
+	if *healthAddr != "" {
+		ln, err := net.Listen("tcp", *healthAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to listen: %v\n", err)
+			return exitError
+		}
+		server := &http.Server{Handler: d.Handler()}
+		go func() {
+			if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Fprintf(os.Stderr, "Health server error: %v\n", err)
+			}
+		}()
+		defer server.Close()
+		fmt.Fprintf(os.Stderr, "Serving /healthz, /status and /metrics on %s\n", ln.Addr())
+	}
+
+	if *pprofAddr != "" {
+		ln, err := net.Listen("tcp", *pprofAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to listen on --pprof-addr: %v\n", err)
+			return exitError
+		}
+		server := &http.Server{Handler: pprofHandler()}
+		go func() {
+			if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Fprintf(os.Stderr, "pprof server error: %v\n", err)
+			}
+		}()
+		defer server.Close()
+		fmt.Fprintf(os.Stderr, "Serving pprof profiles under /debug/pprof/ on %s\n", ln.Addr())
+	}
+
+	fmt.Fprintf(os.Stderr, "Fetching every %s; interrupt to stop\n", interval)
+	if err := d.Loop(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// prepareRun sets up one incremental run from the state file. --from only
+// applies until the state records a watermark or an interrupted run, so the
+// same command line can restart the daemon
+func prepareRun(cfg config.Config) (*config.Config, error) {
+	saved, err := state.Read(cfg.StatePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read --state-file: %w", err)
+	}
+	if saved != nil && (saved.Watermark != nil || !saved.Complete) {
+		cfg.From = time.Time{}
+	}
+	if err := cfg.StartIncremental(saved, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if cfg.From.IsZero() {
+		cfg.From = config.DefaultFrom()
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// runReporter prints a run's progress as usual and counts it into the
+// daemon's metrics
+type runReporter struct {
+	*fetcher.TextReporter
+	rec    daemon.Recorder
+	output string
+	size   int64 // of the output file when last checked
+	pages  int
+	logs   int
+}
+
+func newRunReporter(rec daemon.Recorder, output string) *runReporter {
+	r := &runReporter{TextReporter: fetcher.NewTextReporter(os.Stderr), rec: rec, output: output}
+	r.size = r.outputSize()
+	return r
+}
+
+func (r *runReporter) Progress(p fetcher.Progress) {
+	if p.Pages > r.pages {
+		r.rec.Page(p.Fetched - r.logs)
+		r.pages, r.logs = p.Pages, p.Fetched
+	}
+	size := r.outputSize()
+	if size < r.size {
+		// Rotated away and reopened; count the new file from its start
+		r.size = 0
+	}
+	if size > r.size {
+		r.rec.Bytes(size - r.size)
+		r.size = size
+	}
+	r.TextReporter.Progress(p)
+}
+
+func (r *runReporter) Diagnostic(d fetcher.Diagnostic) {
+	if d.Kind == fetcher.DiagnosticRetry {
+		r.rec.Retry(d.RateLimited, d.Backoff)
+	}
+	r.TextReporter.Diagnostic(d)
+}
+
+// outputSize returns the size of the output file; bytes written to stdout
+// aren't counted
+func (r *runReporter) outputSize() int64 {
+	if r.output == "" {
+		return 0
+	}
+	info, err := os.Stat(r.output)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
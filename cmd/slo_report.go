@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/slo"
+)
+
+// runSLOReport computes availability and burn rates from log counts
+func runSLOReport(args []string) int {
+	fs := flag.NewFlagSet("slo-report", flag.ExitOnError)
+	query := fs.String("query", "", "Logs the SLO covers (default: all logs)")
+	good := fs.String("good", "", "Query for good events (default: every matching log that is not bad)")
+	bad := fs.String("bad", "", "Query for bad events (required)")
+	index := fs.String("index", "main", "Which index to count in")
+	window := fs.String("window", "28d", "SLO window, e.g. 28d or 7d")
+	target := fs.Float64("target", 0.999, "SLO target as a fraction, e.g. 0.999")
+	var burnWindows stringSliceFlag
+	fs.Var(&burnWindows, "burn-window", "Look-back windows to report burn rates for (default: 1h,6h,1d,3d)")
+	to := fs.String("to", "", "End of the SLO window (default: now)")
+	format := fs.String("format", "json", "Report format: json or csv")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+	apiURL := fs.String("api-url", "", "Override the Datadog API URL")
+	siteFlag := fs.String("site", "", "Datadog site, e.g. datadoghq.eu or us5 (default: DD_SITE, else datadoghq.com)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch slo-report - Compute an error budget report from log counts\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch slo-report --query 'service:web' --good 'status:ok' --bad 'status:error' --window 28d\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch slo-report --query 'service:web' --bad 'status:error' --target 0.995 --format csv\n\n")
+		fmt.Fprintf(os.Stderr, "A burn rate of 1 spends the error budget exactly over the SLO window.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *bad == "" {
+		fmt.Fprintf(os.Stderr, "--bad is required\n")
+		fs.Usage()
+		return exitError
+	}
+	if *format != "json" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "--format must be json or csv, got '%s'\n", *format)
+		return exitError
+	}
+
+	spec := slo.Spec{
+		Query:  *query,
+		Good:   *good,
+		Bad:    *bad,
+		Target: *target,
+	}
+
+	var err error
+	spec.Window, err = parsePeriod(*window)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing --window: %v\n", err)
+		return exitError
+	}
+	if len(burnWindows) == 0 {
+		burnWindows = stringSliceFlag{"1h", "6h", "1d", "3d"}
+	}
+	for _, w := range burnWindows {
+		p, err := parsePeriod(w)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --burn-window: %v\n", err)
+			return exitError
+		}
+		spec.BurnWindows = append(spec.BurnWindows, p)
+	}
+
+	end := time.Now()
+	if *to != "" {
+		end, err = config.ParseTime(*to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --to: %v\n", err)
+			return exitError
+		}
+	}
+
+	apiKey, appKey := os.Getenv("DD_API_KEY"), os.Getenv("DD_APP_KEY")
+	if apiKey == "" || appKey == "" {
+		fmt.Fprintf(os.Stderr, "DD_API_KEY and DD_APP_KEY environment variables are required\n")
+		return exitError
+	}
+
+	site, err := resolveSite(*siteFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	var opts []fetcher.ClientOption
+	if *apiURL != "" {
+		opts = append(opts, fetcher.WithBaseURL(*apiURL))
+	}
+	client := fetcher.NewClient(apiKey, appKey, site, opts...)
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	report, err := slo.Evaluate(ctx, indexCounter{client: client, indexes: []string{*index}}, spec, end)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "SLO report failed: %v\n", err)
+		return exitError
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "csv" {
+		err = report.WriteCSV(out)
+	} else {
+		err = report.WriteJSON(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(os.Stderr, "Availability %.4f%% over %s (target %.4f%%), %.1f%% of error budget remaining\n",
+		report.Availability*100, spec.Window.Name, spec.Target*100, report.ErrorBudgetRemaining*100)
+	return exitOK
+}
+
+// indexCounter counts logs in a fixed set of indexes
+type indexCounter struct {
+	client  *fetcher.Client
+	indexes []string
+}
+
+func (c indexCounter) Count(ctx context.Context, query string, from, to time.Time) (int64, error) {
+	return c.client.Count(ctx, query, c.indexes, from, to)
+}
+
+func parsePeriod(s string) (slo.Period, error) {
+	d, err := config.ParseDuration(s)
+	if err != nil {
+		return slo.Period{}, err
+	}
+	if d <= 0 {
+		return slo.Period{}, fmt.Errorf("duration must be positive, got '%s'", s)
+	}
+	return slo.Period{Name: s, Duration: d}, nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/sqlgw"
+)
+
+// runSQLGateway serves Datadog logs as a SQL table over the Postgres wire
+// protocol
+func runSQLGateway(args []string) int {
+	fs := flag.NewFlagSet("sql-gateway", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:5432", "Address to accept Postgres connections on")
+	index := fs.String("index", "main", "Which index the logs table reads from")
+	table := fs.String("table", "logs", "Table name queries select from")
+	defaultRange := fs.String("default-range", "24h", "How far back to search when a query has no lower timestamp bound, e.g. 24h or 7d")
+	apiURL := fs.String("api-url", "", "Override the Datadog API URL")
+	siteFlag := fs.String("site", "", "Datadog site, e.g. datadoghq.eu or us5 (default: DD_SITE, else datadoghq.com)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch sql-gateway - Query logs with SQL over the Postgres wire protocol (experimental)\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch sql-gateway --listen 127.0.0.1:5432\n")
+		fmt.Fprintf(os.Stderr, "  psql -h 127.0.0.1 -c \"SELECT timestamp, message FROM logs WHERE service = 'web' LIMIT 10\"\n\n")
+		fmt.Fprintf(os.Stderr, "Connections are not authenticated; keep the listener on a trusted interface.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	lookback, err := config.ParseDuration(*defaultRange)
+	if err != nil || lookback <= 0 {
+		fmt.Fprintf(os.Stderr, "--default-range must be a positive duration, got '%s'\n", *defaultRange)
+		return exitError
+	}
+
+	apiKey, appKey := os.Getenv("DD_API_KEY"), os.Getenv("DD_APP_KEY")
+	if apiKey == "" || appKey == "" {
+		fmt.Fprintf(os.Stderr, "DD_API_KEY and DD_APP_KEY environment variables are required\n")
+		return exitError
+	}
+
+	site, err := resolveSite(*siteFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to listen: %v\n", err)
+		return exitError
+	}
+
+	server := &sqlgw.Server{
+		Source: fetchSource{base: config.Config{
+			Index:    *index,
+			PageSize: 1000,
+			Format:   "ndjson",
+			APIKey:   apiKey,
+			AppKey:   appKey,
+			Site:     site,
+			APIURL:   *apiURL,
+		}},
+		Table:        *table,
+		DefaultRange: lookback,
+		Log:          os.Stderr,
+	}
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Serving table %q (index %s) on %s\n", *table, *index, ln.Addr())
+	if err := server.Serve(ctx, ln); err != nil {
+		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// fetchSource runs a fetch per query, streaming pages to the caller
+type fetchSource struct {
+	base config.Config
+}
+
+func (s fetchSource) Fetch(ctx context.Context, query string, from, to time.Time, fn func([]datadogV2.Log) error) error {
+	cfg := s.base
+	cfg.Query, cfg.From, cfg.To = query, from, to
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	f, err := fetcher.NewWithWriter(&cfg, pageFuncWriter(fn), io.Discard)
+	if err != nil {
+		return err
+	}
+	return f.Fetch(ctx)
+}
+
+// pageFuncWriter adapts a function to writer.Writer
+type pageFuncWriter func([]datadogV2.Log) error
+
+func (w pageFuncWriter) WritePage(logs []datadogV2.Log) error { return w(logs) }
+func (w pageFuncWriter) Finalize() error                      { return nil }
+func (w pageFuncWriter) Close() error                         { return nil }
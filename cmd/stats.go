@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+	"github.com/jtzemp/dogfetch/internal/stats"
+)
+
+// runStats summarizes logs from a fetch or a local NDJSON file
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	ff := addFetchFlags(fs)
+	file := fs.String("file", "", "Summarize this NDJSON file, or - for stdin, instead of fetching")
+	top := fs.Int("top", 10, "Services and hosts to show")
+	bucket := fs.Duration("bucket", time.Minute, "Width of each timeline bucket")
+	format := fs.String("format", "text", "Report format: text or json")
+	output := fs.String("output", "", "Output file path (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch stats - Summarize logs by status, service, host and time\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch stats --query 'service:web' --from 2024-01-01T10:00:00Z --to 2024-01-01T11:00:00Z\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch stats --file logs.ndjson --bucket 5m\n\n")
+		fmt.Fprintf(os.Stderr, "Prints a histogram of status levels, the top services and hosts, and a timeline\n")
+		fmt.Fprintf(os.Stderr, "of logs per bucket, from a fetch or from a file written with --format ndjson.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *top <= 0 {
+		fmt.Fprintf(os.Stderr, "--top must be positive\n")
+		return exitError
+	}
+	if *bucket <= 0 {
+		fmt.Fprintf(os.Stderr, "--bucket must be positive\n")
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "--format must be text or json, got '%s'\n", *format)
+		return exitError
+	}
+
+	collector := stats.NewCollector(*bucket, *top)
+	var err error
+	if *file != "" {
+		err = statsFromFile(*file, collector)
+	} else {
+		err = statsFromFetch(ff, collector)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	summary := collector.Summary()
+	if *format == "json" {
+		err = summary.WriteJSON(out)
+	} else {
+		summary.WriteText(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// statsFromFile reads logs for stats from an NDJSON file, or stdin for -
+func statsFromFile(path string, collector *stats.Collector) error {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if err := stats.ReadNDJSON(in, collector.Observe); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// statsFromFetch fetches logs for stats, splitting long ranges into windows
+// as a fetch does
+func statsFromFetch(ff *fetchFlags, collector *stats.Collector) error {
+	cfg, err := ff.config()
+	if err != nil {
+		return err
+	}
+	if err := cfg.SetWindow("auto", time.Now()); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	ctx, cancel := signalContext(os.Stderr)
+	defer cancel()
+
+	f, err := fetcher.NewWithWriter(cfg, pageFuncWriter(func(page []datadogV2.Log) error {
+		collector.Observe(page)
+		return nil
+	}), os.Stderr)
+	if err != nil {
+		return err
+	}
+	if err := f.Fetch(ctx); err != nil {
+		return fmt.Errorf("fetch failed: %w", err)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Interrupted; the stats cover the logs fetched so far\n")
+	}
+	return nil
+}
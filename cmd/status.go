@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+)
+
+// serveStatus prints the fetch's status to errOut on SIGUSR1 and, given a
+// socket path, answers each connection to it with the status as JSON, until
+// ctx is done
+func serveStatus(ctx context.Context, errOut io.Writer, tracker *fetcher.StatusTracker, socketPath string) error {
+	if len(statusSignals) > 0 {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, statusSignals...)
+		go func() {
+			defer signal.Stop(sigChan)
+			for {
+				select {
+				case <-sigChan:
+					fmt.Fprintln(errOut)
+					tracker.Status().WriteText(errOut)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	if socketPath == "" {
+		return nil
+	}
+
+	// A socket left behind by a run that didn't exit cleanly is replaced;
+	// anything else at the path is not
+	if info, err := os.Lstat(socketPath); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(socketPath)
+	}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			json.NewEncoder(conn).Encode(tracker.Status())
+			conn.Close()
+		}
+	}()
+	return nil
+}
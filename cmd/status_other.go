@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package cmd
+
+import "os"
+
+// statusSignals is empty where there's no SIGUSR1; --status-socket still
+// answers status requests
+var statusSignals []os.Signal
@@ -0,0 +1,11 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// statusSignals ask a running fetch to print its status
+var statusSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jtzemp/dogfetch/internal/signing"
+)
+
+// runVerifySignature checks a detached signature made with --sign-key, or a
+// bundle made with --sign-keyless
+func runVerifySignature(args []string) int {
+	fs := flag.NewFlagSet("verify-signature", flag.ExitOnError)
+	key := fs.String("key", "", "Ed25519 or ECDSA public key (PEM) (required unless --bundle)")
+	file := fs.String("file", "", "Signed file to verify (required)")
+	signature := fs.String("signature", "", "Signature file (default: <file>.sig)")
+	bundle := fs.String("bundle", "", "Verify this --sign-keyless bundle, e.g. <file>.bundle, instead of a signature made with a key")
+	identity := fs.String("certificate-identity", "", "Email or URI the --bundle certificate must be issued to")
+	issuer := fs.String("certificate-oidc-issuer", "", "OIDC issuer that must have vouched for the --bundle identity, e.g. https://token.actions.githubusercontent.com")
+	chain := fs.String("certificate-chain", "", "Fulcio's root and intermediate certificates (PEM), e.g. from https://fulcio.sigstore.dev/api/v1/rootCert")
+	rekorKey := fs.String("rekor-key", "", "Rekor's public key (PEM), e.g. from https://rekor.sigstore.dev/api/v1/log/publicKey")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "dogfetch verify-signature - Verify a signed export\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch verify-signature --key export.pub --file logs.ndjson\n")
+		fmt.Fprintf(os.Stderr, "  dogfetch verify-signature --file logs.ndjson --bundle logs.ndjson.bundle \\\n")
+		fmt.Fprintf(os.Stderr, "    --certificate-identity ci@example.com --certificate-oidc-issuer https://accounts.google.com \\\n")
+		fmt.Fprintf(os.Stderr, "    --certificate-chain fulcio.pem --rekor-key rekor.pub\n\n")
+		fmt.Fprintf(os.Stderr, "Signatures use the cosign sign-blob format, so files signed with an ECDSA\n")
+		fmt.Fprintf(os.Stderr, "P-256 key can also be checked with: cosign verify-blob --key export.pub --signature logs.ndjson.sig logs.ndjson\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *bundle != "" {
+		return verifyBundle(*file, *bundle, *identity, *issuer, *chain, *rekorKey)
+	}
+
+	if *key == "" || *file == "" {
+		fmt.Fprintf(os.Stderr, "--key and --file are required\n")
+		fs.Usage()
+		return exitError
+	}
+
+	sigPath := *signature
+	if sigPath == "" {
+		sigPath = *file + ".sig"
+	}
+
+	pub, err := signing.LoadPublicKey(*key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+
+	if err := signing.VerifyFile(pub, *file, sigPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(os.Stderr, "Signature OK: %s\n", *file)
+	return exitOK
+}
+
+// verifyBundle checks a keyless bundle against the identity it must be
+// issued to and the Sigstore instance it must come from
+func verifyBundle(file, bundle, identity, issuer, chainPath, rekorKeyPath string) int {
+	if file == "" || identity == "" || issuer == "" || chainPath == "" || rekorKeyPath == "" {
+		fmt.Fprintf(os.Stderr, "--bundle requires --file, --certificate-identity, --certificate-oidc-issuer, --certificate-chain and --rekor-key\n")
+		return exitError
+	}
+
+	chain, err := signing.LoadCertificates(chainPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+	rekorKey, err := signing.LoadPublicKey(rekorKeyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitError
+	}
+
+	policy := signing.KeylessPolicy{Identity: identity, Issuer: issuer, Chain: chain, RekorKey: rekorKey}
+	if err := signing.VerifyBundle(file, bundle, policy); err != nil {
+		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(os.Stderr, "Signature OK: %s, signed by %s\n", file, identity)
+	return exitOK
+}
@@ -0,0 +1,160 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/config"
+)
+
+// DefaultWindow is used when the monitor query doesn't say what it evaluates
+const DefaultWindow = 15 * time.Minute
+
+// Webhook payloads are templates the user writes, so each value is looked up
+// under the names the Datadog template variables suggest and a few common
+// alternatives
+var (
+	idKeys    = []string{"alert_id", "id", "monitor_id"}
+	titleKeys = []string{"alert_title", "title", "event_title"}
+	queryKeys = []string{"alert_query", "query", "monitor_query"}
+	scopeKeys = []string{"alert_scope", "scope"}
+	timeKeys  = []string{"date", "alert_date", "last_updated", "timestamp"}
+)
+
+var (
+	logsQuery   = regexp.MustCompile(`logs\("((?:[^"\\]|\\.)*)"\)`)
+	logsIndex   = regexp.MustCompile(`\.index\("([^"]*)"\)`)
+	logsWindow  = regexp.MustCompile(`\.last\("(\d+[smhdw])"\)`)
+	metricScope = regexp.MustCompile(`\{([^}]*)\}`)
+	metricLast  = regexp.MustCompile(`\blast_(\d+[smhdw])\b`)
+)
+
+// Alert is what a monitor notification says about the logs behind it
+type Alert struct {
+	ID     string
+	Title  string
+	Query  string   // the log search, empty for other monitor types
+	Index  string   // the log monitor's index, if it names one
+	Scope  []string // tag:value pairs of the group that triggered
+	Window time.Duration
+	Time   time.Time // when the monitor triggered
+}
+
+// Parse reads a monitor webhook payload
+func Parse(r io.Reader) (*Alert, error) {
+	var payload map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("invalid alert payload: %w", err)
+	}
+
+	a := &Alert{
+		ID:     lookup(payload, idKeys),
+		Title:  lookup(payload, titleKeys),
+		Window: DefaultWindow,
+	}
+
+	raw := lookup(payload, timeKeys)
+	if raw == "" {
+		return nil, fmt.Errorf("alert payload has no time; include \"date\": \"$DATE\" in the webhook payload")
+	}
+	t, err := parseTime(raw)
+	if err != nil {
+		return nil, err
+	}
+	a.Time = t
+
+	query := lookup(payload, queryKeys)
+	if m := logsQuery.FindStringSubmatch(query); m != nil {
+		a.Query = strings.ReplaceAll(m[1], `\"`, `"`)
+		if m := logsIndex.FindStringSubmatch(query); m != nil && m[1] != "*" {
+			a.Index = m[1]
+		}
+		if m := logsWindow.FindStringSubmatch(query); m != nil {
+			a.Window, _ = config.ParseDuration(m[1])
+		}
+	} else {
+		// A metric monitor: its scope is the best guess at which logs matter
+		if m := metricLast.FindStringSubmatch(query); m != nil {
+			a.Window, _ = config.ParseDuration(m[1])
+		}
+		if m := metricScope.FindStringSubmatch(query); m != nil {
+			a.Scope = append(a.Scope, splitScope(m[1])...)
+		}
+	}
+	a.Scope = append(a.Scope, splitScope(lookup(payload, scopeKeys))...)
+
+	return a, nil
+}
+
+// SearchQuery returns the log query matching the alert's logs: the monitor's
+// search narrowed to the group that triggered
+func (a *Alert) SearchQuery() string {
+	parts := make([]string, 0, len(a.Scope)+1)
+	if a.Query != "" && a.Query != "*" {
+		parts = append(parts, a.Query)
+	}
+	for _, tag := range a.Scope {
+		if !slices.Contains(parts, tag) && !strings.Contains(a.Query, tag) {
+			parts = append(parts, tag)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Range returns the time range around the alert: the monitor's evaluation
+// window plus before, up to after past the trigger
+func (a *Alert) Range(before, after time.Duration) (from, to time.Time) {
+	return a.Time.Add(-a.Window - before), a.Time.Add(after)
+}
+
+// splitScope splits a scope such as "service:web,env:prod" into tags,
+// dropping the wildcards of monitors that aren't grouped
+func splitScope(scope string) []string {
+	var tags []string
+	for _, tag := range strings.Split(scope, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" || !strings.Contains(tag, ":") {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// parseTime parses a payload's time: epoch milliseconds, as Datadog's $DATE
+// gives it, epoch seconds or RFC 3339. Relative times such as "today", which
+// config.ParseTime accepts, mean nothing in a payload.
+func parseTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e11 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("alert payload time: expected epoch milliseconds or seconds, or RFC 3339, got '%s'", s)
+	}
+	return t.UTC(), nil
+}
+
+// lookup returns the first of keys present in payload as a string
+func lookup(payload map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		switch v := payload[key].(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogMonitor(t *testing.T) {
+	payload := `{
+		"alert_id": "12345",
+		"alert_title": "Error spike on web",
+		"alert_query": "logs(\"status:error @http.url:\\\"/checkout\\\"\").index(\"retention-30\").rollup(\"count\").by(\"service,env\").last(\"10m\") > 50",
+		"alert_scope": "service:web,env:prod",
+		"date": "1760000000000"
+	}`
+
+	a, err := Parse(strings.NewReader(payload))
+	require.NoError(t, err)
+
+	assert.Equal(t, "12345", a.ID)
+	assert.Equal(t, "Error spike on web", a.Title)
+	assert.Equal(t, `status:error @http.url:"/checkout"`, a.Query)
+	assert.Equal(t, "retention-30", a.Index)
+	assert.Equal(t, []string{"service:web", "env:prod"}, a.Scope)
+	assert.Equal(t, 10*time.Minute, a.Window)
+	assert.Equal(t, time.UnixMilli(1760000000000).UTC(), a.Time)
+	assert.Equal(t, `status:error @http.url:"/checkout" service:web env:prod`, a.SearchQuery())
+
+	from, to := a.Range(5*time.Minute, 2*time.Minute)
+	assert.Equal(t, a.Time.Add(-15*time.Minute), from)
+	assert.Equal(t, a.Time.Add(2*time.Minute), to)
+}
+
+func TestParseMetricMonitorUsesScope(t *testing.T) {
+	payload := `{
+		"query": "avg(last_5m):avg:system.cpu.user{env:prod,host:web-1} > 90",
+		"scope": "host:web-1",
+		"date": 1760000000
+	}`
+
+	a, err := Parse(strings.NewReader(payload))
+	require.NoError(t, err)
+
+	assert.Empty(t, a.Query)
+	assert.Equal(t, 5*time.Minute, a.Window)
+	assert.Equal(t, time.Unix(1760000000, 0).UTC(), a.Time)
+	assert.Equal(t, "env:prod host:web-1", a.SearchQuery())
+}
+
+func TestParseDefaults(t *testing.T) {
+	a, err := Parse(strings.NewReader(`{"date": "2025-10-09T08:53:20Z", "alert_query": "logs(\"*\").last(\"bogus\") > 1", "alert_scope": "*"}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultWindow, a.Window)
+	assert.Empty(t, a.Index)
+	assert.Empty(t, a.SearchQuery())
+	assert.Equal(t, time.Date(2025, 10, 9, 8, 53, 20, 0, time.UTC), a.Time)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader(`not json`))
+	assert.ErrorContains(t, err, "invalid alert payload")
+
+	_, err = Parse(strings.NewReader(`{"alert_query": "logs(\"*\")"}`))
+	assert.ErrorContains(t, err, "$DATE")
+
+	_, err = Parse(strings.NewReader(`{"date": "yesterday"}`))
+	assert.ErrorContains(t, err, "alert payload time")
+}
@@ -0,0 +1,133 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+const (
+	// alpha is the EWMA smoothing factor; about the last 20 minutes dominate
+	alpha = 0.1
+	// warmup is how many minutes are observed before anything is flagged
+	warmup = 10
+)
+
+// Window is a minute whose log count deviated from the recent trend
+type Window struct {
+	Start    time.Time `json:"start"`
+	Count    int       `json:"count"`
+	Expected float64   `json:"expected"`
+	ZScore   float64   `json:"z_score"`
+}
+
+// Detector flags anomalous per-minute log counts while logs stream past
+// It keeps an exponentially weighted mean and variance of the counts and
+// flags minutes whose z-score exceeds the threshold. Logs must arrive in
+// time order, either ascending or descending, as the Logs API returns them.
+// Minutes without logs inside the stream count as zero, so drops are caught
+// as well as spikes.
+type Detector struct {
+	threshold float64
+	mean      float64
+	variance  float64
+	seen      int
+
+	current time.Time
+	count   int
+	started bool
+
+	anomalies []Window
+}
+
+// NewDetector creates a detector that flags minutes beyond threshold
+// standard deviations from the trend
+func NewDetector(threshold float64) *Detector {
+	return &Detector{threshold: threshold}
+}
+
+// Observe counts a page of logs into their minutes
+func (d *Detector) Observe(logs []datadogV2.Log) {
+	for _, log := range logs {
+		v, ok := logfield.Lookup(log, "timestamp")
+		if !ok {
+			continue
+		}
+		minute := v.(time.Time).UTC().Truncate(time.Minute)
+
+		if !d.started {
+			d.current = minute
+			d.started = true
+		}
+
+		if !minute.Equal(d.current) {
+			d.close(d.current, d.count)
+			// Fill the minutes between with zeros
+			step := time.Minute
+			if minute.Before(d.current) {
+				step = -time.Minute
+			}
+			for m := d.current.Add(step); !m.Equal(minute); m = m.Add(step) {
+				d.close(m, 0)
+			}
+			d.current = minute
+			d.count = 0
+		}
+		d.count++
+	}
+}
+
+// Anomalies returns the flagged minutes in the order they were seen
+// The minute still being counted is left out, since the edges of a time
+// range are usually partial.
+func (d *Detector) Anomalies() []Window {
+	return d.anomalies
+}
+
+// WriteFile saves the flagged minutes as a JSON array
+func (d *Detector) WriteFile(path string) error {
+	anomalies := d.anomalies
+	if anomalies == nil {
+		anomalies = []Window{}
+	}
+
+	data, err := json.MarshalIndent(anomalies, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// close scores a finished minute and folds it into the trend
+func (d *Detector) close(minute time.Time, count int) {
+	x := float64(count)
+
+	if d.seen >= warmup {
+		// Counts are at least Poisson-noisy, which keeps a flat history from
+		// turning every small wobble into an anomaly
+		std := math.Max(math.Sqrt(d.variance), math.Sqrt(math.Max(d.mean, 1)))
+		z := (x - d.mean) / std
+		if math.Abs(z) > d.threshold {
+			d.anomalies = append(d.anomalies, Window{
+				Start:    minute,
+				Count:    count,
+				Expected: math.Round(d.mean*10) / 10,
+				ZScore:   math.Round(z*100) / 100,
+			})
+		}
+	}
+
+	if d.seen == 0 {
+		d.mean = x
+	} else {
+		diff := x - d.mean
+		incr := alpha * diff
+		d.mean += incr
+		d.variance = (1 - alpha) * (d.variance + diff*incr)
+	}
+	d.seen++
+}
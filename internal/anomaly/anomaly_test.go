@@ -0,0 +1,96 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestDetectorFlagsSpike(t *testing.T) {
+	counts := steadyCounts(40, 100)
+	counts[30] = 400
+
+	d := NewDetector(3)
+	d.Observe(logsForCounts(counts, false))
+
+	anomalies := d.Anomalies()
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, base.Add(30*time.Minute), anomalies[0].Start)
+	assert.Equal(t, 400, anomalies[0].Count)
+	assert.Greater(t, anomalies[0].ZScore, 3.0)
+}
+
+func TestDetectorFlagsGapInDescendingStream(t *testing.T) {
+	counts := steadyCounts(40, 100)
+	counts[12] = 0
+
+	// The Logs API returns the newest logs first
+	d := NewDetector(3)
+	d.Observe(logsForCounts(counts, true))
+
+	anomalies := d.Anomalies()
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, base.Add(12*time.Minute), anomalies[0].Start)
+	assert.Equal(t, 0, anomalies[0].Count)
+	assert.Less(t, anomalies[0].ZScore, -3.0)
+}
+
+func TestDetectorIgnoresNoise(t *testing.T) {
+	counts := steadyCounts(60, 100)
+	for i := range counts {
+		counts[i] += i%3*5 - 5
+	}
+
+	d := NewDetector(3)
+	d.Observe(logsForCounts(counts, false))
+	assert.Empty(t, d.Anomalies())
+}
+
+func TestDetectorWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anomalies.json")
+
+	d := NewDetector(3)
+	require.NoError(t, d.WriteFile(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var windows []Window
+	require.NoError(t, json.Unmarshal(data, &windows))
+	assert.NotNil(t, windows, "an empty result is written as []")
+	assert.Empty(t, windows)
+}
+
+// Helper functions
+
+func steadyCounts(minutes, perMinute int) []int {
+	counts := make([]int, minutes)
+	for i := range counts {
+		counts[i] = perMinute
+	}
+	return counts
+}
+
+// logsForCounts spreads counts[i] logs over minute i
+func logsForCounts(counts []int, descending bool) []datadogV2.Log {
+	var logs []datadogV2.Log
+	for i, n := range counts {
+		for j := 0; j < n; j++ {
+			ts := base.Add(time.Duration(i)*time.Minute + time.Duration(j)*time.Millisecond)
+			logs = append(logs, datadogV2.Log{Attributes: &datadogV2.LogAttributes{Timestamp: &ts}})
+		}
+	}
+	if descending {
+		for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+			logs[i], logs[j] = logs[j], logs[i]
+		}
+	}
+	return logs
+}
@@ -0,0 +1,81 @@
+package around
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/explore"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// DefaultMatch are the fields surrounding logs share with the log they are
+// fetched around, as Datadog's "view in context" does
+var DefaultMatch = []string{"service", "host"}
+
+// Query narrows base to the logs sharing log's values of fields. Fields the
+// log has no value for are left out, and the terms added are returned for
+// display.
+func Query(base string, log datadogV2.Log, fields []string) (string, []string) {
+	if base == "*" {
+		base = ""
+	}
+	var terms []string
+	for _, field := range fields {
+		value, ok := logfield.LookupString(log, field)
+		if !ok || value == "" {
+			continue
+		}
+		terms = append(terms, explore.Term(field, value, false))
+	}
+	query := strings.TrimSpace(base + " " + strings.Join(terms, " "))
+	if query == "" {
+		query = "*"
+	}
+	return query, terms
+}
+
+// Without drops the anchor log, if any, from the logs after it, as logs at
+// its exact timestamp are fetched with it, and keeps at most n
+func Without(after []datadogV2.Log, anchor *datadogV2.Log, n int) []datadogV2.Log {
+	kept := make([]datadogV2.Log, 0, min(len(after), n))
+	for _, log := range after {
+		if len(kept) == n {
+			break
+		}
+		if anchor == nil || log.GetId() != anchor.GetId() {
+			kept = append(kept, log)
+		}
+	}
+	return kept
+}
+
+// Assemble puts context in time order: the logs before, fetched newest first,
+// then the anchor log, if any, then the logs after
+func Assemble(before []datadogV2.Log, anchor *datadogV2.Log, after []datadogV2.Log) []datadogV2.Log {
+	logs := make([]datadogV2.Log, 0, len(before)+len(after)+1)
+	for i := len(before) - 1; i >= 0; i-- {
+		logs = append(logs, before[i])
+	}
+	if anchor != nil {
+		logs = append(logs, *anchor)
+	}
+	return append(logs, after...)
+}
+
+// Describe summarizes the log context is fetched around, for the terminal
+func Describe(log datadogV2.Log) string {
+	attrs := log.GetAttributes()
+	desc := fmt.Sprintf("Log %s at %s", log.GetId(), attrs.GetTimestamp().UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	var scope []string
+	if service := attrs.GetService(); service != "" {
+		scope = append(scope, "service "+service)
+	}
+	if host := attrs.GetHost(); host != "" {
+		scope = append(scope, "host "+host)
+	}
+	if len(scope) > 0 {
+		desc += " (" + strings.Join(scope, ", ") + ")"
+	}
+	return desc
+}
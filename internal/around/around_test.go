@@ -0,0 +1,66 @@
+package around
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleLog(id, service, host string) datadogV2.Log {
+	a := datadogV2.NewLogAttributes()
+	a.SetService(service)
+	a.SetHost(host)
+	a.SetTimestamp(time.Date(2024, 1, 2, 15, 4, 5, 123e6, time.UTC))
+	log := datadogV2.NewLog()
+	log.SetId(id)
+	log.SetAttributes(*a)
+	return *log
+}
+
+func ids(logs []datadogV2.Log) []string {
+	var out []string
+	for _, log := range logs {
+		out = append(out, log.GetId())
+	}
+	return out
+}
+
+func TestQuery(t *testing.T) {
+	log := sampleLog("x", "web", "i-1")
+
+	query, terms := Query("*", log, DefaultMatch)
+	assert.Equal(t, "service:web host:i-1", query)
+	assert.Equal(t, []string{"service:web", "host:i-1"}, terms)
+
+	query, _ = Query("status:error", log, []string{"service", "env"})
+	assert.Equal(t, "status:error service:web", query, "fields the log lacks are left out")
+
+	query, terms = Query("*", log, nil)
+	assert.Equal(t, "*", query)
+	assert.Empty(t, terms)
+}
+
+func TestWithoutDropsTheAnchor(t *testing.T) {
+	anchor := sampleLog("b", "web", "i-1")
+	after := []datadogV2.Log{sampleLog("a", "", ""), anchor, sampleLog("c", "", ""), sampleLog("d", "", "")}
+
+	assert.Equal(t, []string{"a", "c"}, ids(Without(after, &anchor, 2)))
+	assert.Equal(t, []string{"a", "b"}, ids(Without(after, nil, 2)))
+	assert.Empty(t, Without(after, &anchor, 0))
+}
+
+func TestAssemble(t *testing.T) {
+	anchor := sampleLog("m", "web", "i-1")
+	before := []datadogV2.Log{sampleLog("l", "", ""), sampleLog("k", "", "")}
+	after := []datadogV2.Log{sampleLog("n", "", ""), sampleLog("o", "", "")}
+
+	assert.Equal(t, []string{"k", "l", "m", "n", "o"}, ids(Assemble(before, &anchor, after)))
+	assert.Equal(t, []string{"k", "l", "n", "o"}, ids(Assemble(before, nil, after)))
+}
+
+func TestDescribe(t *testing.T) {
+	assert.Equal(t, "Log x at 2024-01-02T15:04:05.123Z (service web, host i-1)", Describe(sampleLog("x", "web", "i-1")))
+	assert.Equal(t, "Log x at 2024-01-02T15:04:05.123Z", Describe(sampleLog("x", "", "")))
+}
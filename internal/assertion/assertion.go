@@ -0,0 +1,289 @@
+package assertion
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// Assertion is a data quality check evaluated after a fetch completes
+type Assertion interface {
+	// Name describes the assertion for reports
+	Name() string
+
+	// Observe is called with every page of logs written
+	Observe(logs []datadogV2.Log)
+
+	// Check returns an error describing the violation, or nil if the assertion holds
+	Check() error
+}
+
+// Result is the outcome of a single assertion
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Passed reports whether the assertion held
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Set evaluates a group of assertions over the same stream of logs
+type Set struct {
+	assertions []Assertion
+}
+
+// NewSet creates a new assertion set
+func NewSet(assertions ...Assertion) *Set {
+	return &Set{assertions: assertions}
+}
+
+// Add appends an assertion to the set
+func (s *Set) Add(a Assertion) {
+	s.assertions = append(s.assertions, a)
+}
+
+// Len returns the number of assertions in the set
+func (s *Set) Len() int {
+	return len(s.assertions)
+}
+
+// Observe forwards a page of logs to every assertion
+func (s *Set) Observe(logs []datadogV2.Log) {
+	for _, a := range s.assertions {
+		a.Observe(logs)
+	}
+}
+
+// Results evaluates every assertion
+func (s *Set) Results() []Result {
+	results := make([]Result, 0, len(s.assertions))
+	for _, a := range s.assertions {
+		results = append(results, Result{Name: a.Name(), Err: a.Check()})
+	}
+	return results
+}
+
+// Failures returns only the assertions that were violated
+func (s *Set) Failures() []Result {
+	var failures []Result
+	for _, r := range s.Results() {
+		if !r.Passed() {
+			failures = append(failures, r)
+		}
+	}
+	return failures
+}
+
+// MinCount asserts that at least a minimum number of logs were fetched
+type MinCount struct {
+	min   int
+	count int
+}
+
+// NewMinCount creates a minimum record count assertion
+func NewMinCount(min int) *MinCount {
+	return &MinCount{min: min}
+}
+
+// Name describes the assertion
+func (a *MinCount) Name() string {
+	return fmt.Sprintf("record count >= %d", a.min)
+}
+
+// Observe counts the logs in a page
+func (a *MinCount) Observe(logs []datadogV2.Log) {
+	a.count += len(logs)
+}
+
+// Check verifies the minimum was reached
+func (a *MinCount) Check() error {
+	if a.count < a.min {
+		return fmt.Errorf("fetched %d logs, expected at least %d", a.count, a.min)
+	}
+	return nil
+}
+
+// MaxCount asserts that at most a maximum number of logs were fetched, e.g.
+// zero to gate on the absence of critical errors
+type MaxCount struct {
+	max   int
+	count int
+}
+
+// NewMaxCount creates a maximum record count assertion
+func NewMaxCount(max int) *MaxCount {
+	return &MaxCount{max: max}
+}
+
+// Name describes the assertion
+func (a *MaxCount) Name() string {
+	return fmt.Sprintf("record count <= %d", a.max)
+}
+
+// Observe counts the logs in a page
+func (a *MaxCount) Observe(logs []datadogV2.Log) {
+	a.count += len(logs)
+}
+
+// Check verifies the maximum wasn't exceeded
+func (a *MaxCount) Check() error {
+	if a.count > a.max {
+		return fmt.Errorf("fetched %d logs, expected at most %d", a.count, a.max)
+	}
+	return nil
+}
+
+// maxExamples caps the matching messages quoted in a NoMatch failure
+const maxExamples = 3
+
+// NoMatch asserts that no log message matches a pattern, e.g. a known
+// critical error
+type NoMatch struct {
+	pattern  *regexp.Regexp
+	matches  int
+	examples []string
+}
+
+// NewNoMatch creates an assertion that no message matches pattern
+func NewNoMatch(pattern string) (*NoMatch, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	return &NoMatch{pattern: re}, nil
+}
+
+// Name describes the assertion
+func (a *NoMatch) Name() string {
+	return fmt.Sprintf("no messages matching /%s/", a.pattern)
+}
+
+// Observe counts matching messages, keeping the first few as examples
+func (a *NoMatch) Observe(logs []datadogV2.Log) {
+	for _, log := range logs {
+		message, ok := logfield.LookupString(log, "message")
+		if !ok || !a.pattern.MatchString(message) {
+			continue
+		}
+		a.matches++
+		if len(a.examples) < maxExamples {
+			a.examples = append(a.examples, message)
+		}
+	}
+}
+
+// Check verifies nothing matched
+func (a *NoMatch) Check() error {
+	if a.matches == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d logs matched, e.g. %q", a.matches, a.examples)
+}
+
+// MaxNullRate asserts that a field is missing on at most a fraction of logs
+type MaxNullRate struct {
+	field string
+	max   float64
+	total int
+	nulls int
+}
+
+// NewMaxNullRate creates a maximum null rate assertion
+func NewMaxNullRate(field string, max float64) *MaxNullRate {
+	return &MaxNullRate{field: field, max: max}
+}
+
+// ParseMaxNullRate parses a "field=rate" specification, e.g. "service=0.05"
+func ParseMaxNullRate(spec string) (*MaxNullRate, error) {
+	field, rate, ok := strings.Cut(spec, "=")
+	field = strings.TrimSpace(field)
+	if !ok || field == "" {
+		return nil, fmt.Errorf("invalid null rate assertion '%s': expected field=rate", spec)
+	}
+
+	max, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+	if err != nil || max < 0 || max > 1 {
+		return nil, fmt.Errorf("invalid null rate '%s': expected a number between 0 and 1", rate)
+	}
+
+	return NewMaxNullRate(field, max), nil
+}
+
+// Name describes the assertion
+func (a *MaxNullRate) Name() string {
+	return fmt.Sprintf("null rate of %s <= %g", a.field, a.max)
+}
+
+// Observe counts logs missing the field
+func (a *MaxNullRate) Observe(logs []datadogV2.Log) {
+	for _, log := range logs {
+		a.total++
+		if v, ok := logfield.Lookup(log, a.field); !ok || v == nil {
+			a.nulls++
+		}
+	}
+}
+
+// Check verifies the null rate stayed under the maximum
+func (a *MaxNullRate) Check() error {
+	if a.total == 0 {
+		return nil
+	}
+
+	rate := float64(a.nulls) / float64(a.total)
+	if rate > a.max {
+		return fmt.Errorf("%s missing on %d of %d logs (%.4f > %g)", a.field, a.nulls, a.total, rate, a.max)
+	}
+	return nil
+}
+
+// RequiredServices asserts that every listed service appears at least once
+type RequiredServices struct {
+	services []string
+	seen     map[string]bool
+}
+
+// NewRequiredServices creates a required services assertion
+func NewRequiredServices(services ...string) *RequiredServices {
+	return &RequiredServices{
+		services: services,
+		seen:     make(map[string]bool),
+	}
+}
+
+// Name describes the assertion
+func (a *RequiredServices) Name() string {
+	return fmt.Sprintf("services present: %s", strings.Join(a.services, ", "))
+}
+
+// Observe records the services seen in a page
+func (a *RequiredServices) Observe(logs []datadogV2.Log) {
+	for _, log := range logs {
+		if service, ok := logfield.LookupString(log, "service"); ok {
+			a.seen[service] = true
+		}
+	}
+}
+
+// Check verifies every required service was seen
+func (a *RequiredServices) Check() error {
+	var missing []string
+	for _, service := range a.services {
+		if !a.seen[service] {
+			missing = append(missing, service)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing services: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
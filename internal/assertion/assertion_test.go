@@ -0,0 +1,146 @@
+package assertion
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinCount(t *testing.T) {
+	a := NewMinCount(3)
+	a.Observe(createTestLogs("web", "api"))
+	assert.Error(t, a.Check())
+
+	a.Observe(createTestLogs("web"))
+	assert.NoError(t, a.Check())
+}
+
+func TestMaxCount(t *testing.T) {
+	a := NewMaxCount(0)
+	assert.NoError(t, a.Check())
+
+	a.Observe(createTestLogs("web", "api"))
+	err := a.Check()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fetched 2 logs, expected at most 0")
+	assert.Equal(t, "record count <= 0", a.Name())
+}
+
+func TestNoMatch(t *testing.T) {
+	a, err := NewNoMatch(`OutOfMemory|panic:`)
+	require.NoError(t, err)
+	assert.Equal(t, "no messages matching /OutOfMemory|panic:/", a.Name())
+
+	a.Observe([]datadogV2.Log{createMessageLog("all good"), {}})
+	assert.NoError(t, a.Check())
+
+	for i := 0; i < 5; i++ {
+		a.Observe([]datadogV2.Log{createMessageLog(fmt.Sprintf("panic: boom %d", i))})
+	}
+	err = a.Check()
+	require.Error(t, err)
+	assert.Equal(t, `5 logs matched, e.g. ["panic: boom 0" "panic: boom 1" "panic: boom 2"]`, err.Error())
+
+	_, err = NewNoMatch("(")
+	assert.Error(t, err)
+}
+
+func TestMaxNullRate(t *testing.T) {
+	a := NewMaxNullRate("service", 0.25)
+
+	logs := createTestLogs("web", "api", "db")
+	logs = append(logs, datadogV2.Log{Attributes: &datadogV2.LogAttributes{}})
+	a.Observe(logs)
+	assert.NoError(t, a.Check(), "1 of 4 missing is exactly the limit")
+
+	a.Observe([]datadogV2.Log{{}})
+	err := a.Check()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 of 5")
+}
+
+func TestMaxNullRateWithNoLogs(t *testing.T) {
+	a := NewMaxNullRate("service", 0)
+	assert.NoError(t, a.Check())
+}
+
+func TestParseMaxNullRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "valid", spec: "service=0.05", wantErr: false},
+		{name: "facet path", spec: "@http.status_code=0", wantErr: false},
+		{name: "missing rate", spec: "service", wantErr: true},
+		{name: "missing field", spec: "=0.1", wantErr: true},
+		{name: "rate out of range", spec: "service=1.5", wantErr: true},
+		{name: "rate not a number", spec: "service=lots", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMaxNullRate(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRequiredServices(t *testing.T) {
+	a := NewRequiredServices("web", "api", "db")
+	a.Observe(createTestLogs("web", "api"))
+
+	err := a.Check()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "db")
+	assert.NotContains(t, err.Error(), "web")
+
+	a.Observe(createTestLogs("db"))
+	assert.NoError(t, a.Check())
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet(NewMinCount(1), NewRequiredServices("db"))
+	assert.Equal(t, 2, s.Len())
+
+	s.Observe(createTestLogs("web"))
+
+	results := s.Results()
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Passed())
+	assert.False(t, results[1].Passed())
+
+	failures := s.Failures()
+	require.Len(t, failures, 1)
+	assert.Equal(t, "services present: db", failures[0].Name)
+}
+
+// Helper functions
+
+func createTestLogs(services ...string) []datadogV2.Log {
+	logs := make([]datadogV2.Log, len(services))
+	for i, service := range services {
+		service := service
+		logs[i] = datadogV2.Log{
+			Attributes: &datadogV2.LogAttributes{
+				Service: &service,
+			},
+		}
+	}
+	return logs
+}
+
+func createMessageLog(message string) datadogV2.Log {
+	return datadogV2.Log{
+		Attributes: &datadogV2.LogAttributes{
+			Message: &message,
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package assertion
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Suite describes the run a JUnit report covers
+type Suite struct {
+	Name       string
+	Timestamp  time.Time
+	Duration   time.Duration
+	Properties map[string]string // e.g. the query and time range
+
+	// Err, if set, means the fetch didn't complete, so every assertion is
+	// reported as an error rather than evaluated
+	Err error
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Tests   int              `xml:"tests,attr"`
+	Fail    int              `xml:"failures,attr"`
+	Errors  int              `xml:"errors,attr"`
+	Time    string           `xml:"time,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Fail       int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes assertion results as a JUnit XML report, one test case
+// per assertion, for CI systems to gate on
+func WriteJUnit(w io.Writer, suite Suite, results []Result) error {
+	ts := junitTestSuite{
+		Name:      suite.Name,
+		Tests:     len(results),
+		Time:      seconds(suite.Duration),
+		Timestamp: suite.Timestamp.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, name := range sortedKeys(suite.Properties) {
+		ts.Properties = append(ts.Properties, junitProperty{Name: name, Value: suite.Properties[name]})
+	}
+
+	for _, r := range results {
+		tc := junitTestCase{Name: r.Name, Classname: suite.Name, Time: seconds(0)}
+		switch {
+		case suite.Err != nil:
+			tc.Error = &junitProblem{Message: "fetch did not complete", Type: "FetchError", Text: suite.Err.Error()}
+			ts.Errors++
+		case !r.Passed():
+			tc.Failure = &junitProblem{Message: r.Err.Error(), Type: "AssertionFailed", Text: r.Err.Error()}
+			ts.Fail++
+		}
+		ts.Cases = append(ts.Cases, tc)
+	}
+
+	doc := junitTestSuites{
+		Tests:  ts.Tests,
+		Fail:   ts.Fail,
+		Errors: ts.Errors,
+		Time:   ts.Time,
+		Suites: []junitTestSuite{ts},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteJUnitFile writes a JUnit XML report to path
+func WriteJUnitFile(path string, suite Suite, results []Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteJUnit(f, suite, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
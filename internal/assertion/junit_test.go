@@ -0,0 +1,62 @@
+package assertion
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	suite := Suite{
+		Name:       "dogfetch",
+		Timestamp:  time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		Duration:   1500 * time.Millisecond,
+		Properties: map[string]string{"query": "status:critical", "from": "2024-01-01T09:00:00Z"},
+	}
+	results := []Result{
+		{Name: "record count <= 0", Err: errors.New("fetched 3 logs, expected at most 0")},
+		{Name: "services present: web"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnit(&buf, suite, results))
+
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, 2, doc.Tests)
+	assert.Equal(t, 1, doc.Fail)
+	assert.Equal(t, 0, doc.Errors)
+	require.Len(t, doc.Suites, 1)
+
+	s := doc.Suites[0]
+	assert.Equal(t, "dogfetch", s.Name)
+	assert.Equal(t, "1.500", s.Time)
+	assert.Equal(t, "2024-01-01T10:00:00", s.Timestamp)
+	assert.Equal(t, []junitProperty{{"from", "2024-01-01T09:00:00Z"}, {"query", "status:critical"}}, s.Properties)
+	require.Len(t, s.Cases, 2)
+	require.NotNil(t, s.Cases[0].Failure)
+	assert.Equal(t, "fetched 3 logs, expected at most 0", s.Cases[0].Failure.Message)
+	assert.Nil(t, s.Cases[1].Failure)
+	assert.Nil(t, s.Cases[1].Error)
+}
+
+func TestWriteJUnitIncompleteRun(t *testing.T) {
+	suite := Suite{Name: "dogfetch", Err: errors.New("rate limited")}
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	require.NoError(t, WriteJUnitFile(path, suite, []Result{{Name: "record count >= 1"}}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(data, &doc))
+	assert.Equal(t, 1, doc.Errors)
+	require.NotNil(t, doc.Suites[0].Cases[0].Error)
+	assert.Equal(t, "rate limited", doc.Suites[0].Cases[0].Error.Text)
+}
@@ -0,0 +1,274 @@
+package attach
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Systems are the ticket trackers --attach-to supports
+var Systems = []string{"jira", "linear"}
+
+// LinearURL is Linear's GraphQL API
+const LinearURL = "https://api.linear.app/graphql"
+
+// timeout bounds the whole upload
+const timeout = 2 * time.Minute
+
+var issueKey = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+// Target is a ticket to attach an export to, with the credentials to reach it
+type Target struct {
+	System string // jira or linear
+	Key    string // e.g. PROJ-123
+	URL    string // Jira site or Linear GraphQL API
+	User   string // Jira account email; empty for a personal access token
+	Token  string
+}
+
+// FromEnv parses a spec such as jira:PROJ-123 and reads the tracker's
+// credentials with getenv: JIRA_URL, JIRA_EMAIL and JIRA_API_TOKEN, or
+// LINEAR_API_KEY
+func FromEnv(spec string, getenv func(string) string) (Target, error) {
+	system, key, ok := strings.Cut(spec, ":")
+	if !ok || !issueKey.MatchString(key) {
+		return Target{}, fmt.Errorf("--attach-to must be system:ISSUE-123, got '%s'", spec)
+	}
+
+	t := Target{System: system, Key: key}
+	switch system {
+	case "jira":
+		t.URL = strings.TrimSuffix(getenv("JIRA_URL"), "/")
+		t.User = getenv("JIRA_EMAIL")
+		t.Token = getenv("JIRA_API_TOKEN")
+		if t.URL == "" || t.Token == "" {
+			return Target{}, errors.New("--attach-to jira needs JIRA_URL and JIRA_API_TOKEN (and JIRA_EMAIL for Jira Cloud)")
+		}
+	case "linear":
+		t.URL = LinearURL
+		t.Token = getenv("LINEAR_API_KEY")
+		if t.Token == "" {
+			return Target{}, errors.New("--attach-to linear needs LINEAR_API_KEY")
+		}
+	default:
+		return Target{}, fmt.Errorf("unknown ticket system '%s' (supported: %s)", system, strings.Join(Systems, ", "))
+	}
+	return t, nil
+}
+
+// File is an export prepared for upload
+type File struct {
+	Name        string
+	ContentType string
+	Body        []byte
+}
+
+// ErrTooLarge is returned when an export is over the size cap even after
+// compression
+var ErrTooLarge = errors.New("export is too large to attach")
+
+// Prepare gzips the file at path, unless it already is, refusing to hold more
+// than maxSize bytes of it
+func Prepare(path string, maxSize int64) (File, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer in.Close()
+
+	name := filepath.Base(path)
+	var body bytes.Buffer
+	capped := &capWriter{w: &body, left: maxSize}
+	if strings.HasSuffix(name, ".gz") {
+		if _, err := io.Copy(capped, in); err != nil {
+			return File{}, err
+		}
+	} else {
+		name += ".gz"
+		zw := gzip.NewWriter(capped)
+		if _, err := io.Copy(zw, in); err != nil {
+			return File{}, err
+		}
+		if err := zw.Close(); err != nil {
+			return File{}, err
+		}
+	}
+	return File{Name: name, ContentType: "application/gzip", Body: body.Bytes()}, nil
+}
+
+// capWriter fails once more than left bytes are written
+type capWriter struct {
+	w    io.Writer
+	left int64
+}
+
+func (c *capWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > c.left {
+		return 0, ErrTooLarge
+	}
+	c.left -= int64(len(p))
+	return c.w.Write(p)
+}
+
+// Upload attaches file to the target's ticket
+func Upload(ctx context.Context, client *http.Client, t Target, file File) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch t.System {
+	case "jira":
+		return uploadJira(ctx, client, t, file)
+	case "linear":
+		return uploadLinear(ctx, client, t, file)
+	default:
+		return fmt.Errorf("unknown ticket system '%s'", t.System)
+	}
+}
+
+// uploadJira posts the file to the issue's attachments
+func uploadJira(ctx context.Context, client *http.Client, t Target, file File) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", file.Name)
+	if err != nil {
+		return err
+	}
+	part.Write(file.Body)
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/rest/api/3/issue/%s/attachments", t.URL, t.Key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Atlassian-Token", "no-check")
+	if t.User != "" {
+		req.SetBasicAuth(t.User, t.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+t.Token)
+	}
+	_, err = do(client, req)
+	return err
+}
+
+// uploadLinear uploads the file to Linear's storage and links it from the
+// issue: Linear attachments are links rather than files
+func uploadLinear(ctx context.Context, client *http.Client, t Target, file File) error {
+	var upload struct {
+		FileUpload struct {
+			UploadFile struct {
+				UploadURL string `json:"uploadUrl"`
+				AssetURL  string `json:"assetUrl"`
+				Headers   []struct {
+					Key   string `json:"key"`
+					Value string `json:"value"`
+				} `json:"headers"`
+			} `json:"uploadFile"`
+		} `json:"fileUpload"`
+	}
+	err := graphQL(ctx, client, t, `mutation($contentType: String!, $filename: String!, $size: Int!) {
+  fileUpload(contentType: $contentType, filename: $filename, size: $size) {
+    uploadFile { uploadUrl assetUrl headers { key value } }
+  }
+}`, map[string]interface{}{"contentType": file.ContentType, "filename": file.Name, "size": len(file.Body)}, &upload)
+	if err != nil {
+		return fmt.Errorf("requesting upload URL: %w", err)
+	}
+
+	target := upload.FileUpload.UploadFile
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.UploadURL, bytes.NewReader(file.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", file.ContentType)
+	req.Header.Set("Cache-Control", "public, max-age=31536000")
+	for _, h := range target.Headers {
+		req.Header.Set(h.Key, h.Value)
+	}
+	if _, err := do(client, req); err != nil {
+		return fmt.Errorf("uploading: %w", err)
+	}
+
+	var created struct {
+		AttachmentCreate struct {
+			Success bool `json:"success"`
+		} `json:"attachmentCreate"`
+	}
+	err = graphQL(ctx, client, t, `mutation($input: AttachmentCreateInput!) {
+  attachmentCreate(input: $input) { success }
+}`, map[string]interface{}{"input": map[string]string{"issueId": t.Key, "title": file.Name, "url": target.AssetURL}}, &created)
+	if err != nil {
+		return fmt.Errorf("attaching: %w", err)
+	}
+	if !created.AttachmentCreate.Success {
+		return errors.New("attaching: Linear did not create the attachment")
+	}
+	return nil
+}
+
+// graphQL runs a Linear query, decoding its data into out
+func graphQL(ctx context.Context, client *http.Client, t Target, query string, variables map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", t.Token)
+
+	body, err := do(client, req)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return errors.New(resp.Errors[0].Message)
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+// do sends req and returns the response body, failing on non-2xx statuses
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		detail := strings.TrimSpace(string(body))
+		if len(detail) > 512 {
+			detail = detail[:512]
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, detail)
+	}
+	return body, nil
+}
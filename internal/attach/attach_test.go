@@ -0,0 +1,169 @@
+package attach
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestFromEnv(t *testing.T) {
+	target, err := FromEnv("jira:PROJ-123", env(map[string]string{
+		"JIRA_URL": "https://example.atlassian.net/", "JIRA_EMAIL": "me@example.com", "JIRA_API_TOKEN": "secret",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, Target{System: "jira", Key: "PROJ-123", URL: "https://example.atlassian.net", User: "me@example.com", Token: "secret"}, target)
+
+	target, err = FromEnv("linear:ENG-7", env(map[string]string{"LINEAR_API_KEY": "lin_api_x"}))
+	require.NoError(t, err)
+	assert.Equal(t, Target{System: "linear", Key: "ENG-7", URL: LinearURL, Token: "lin_api_x"}, target)
+
+	_, err = FromEnv("jira:PROJ-123", env(nil))
+	assert.ErrorContains(t, err, "JIRA_URL")
+	_, err = FromEnv("linear:ENG-7", env(nil))
+	assert.ErrorContains(t, err, "LINEAR_API_KEY")
+	_, err = FromEnv("github:ENG-7", env(nil))
+	assert.ErrorContains(t, err, "unknown ticket system")
+	for _, spec := range []string{"PROJ-123", "jira:proj-123", "jira:PROJ", "jira:"} {
+		_, err = FromEnv(spec, env(nil))
+		assert.ErrorContains(t, err, "system:ISSUE-123", spec)
+	}
+}
+
+func TestPrepareCompresses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	content := strings.Repeat(`{"id":"x","attributes":{"message":"hello"}}`+"\n", 1000)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	file, err := Prepare(path, 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, "logs.ndjson.gz", file.Name)
+	assert.Equal(t, "application/gzip", file.ContentType)
+	assert.Less(t, len(file.Body), len(content))
+
+	zr, err := gzip.NewReader(bytes.NewReader(file.Body))
+	require.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(plain))
+
+	_, err = Prepare(path, 64)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestPrepareKeepsCompressedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson.gz")
+	require.NoError(t, os.WriteFile(path, []byte("already compressed"), 0644))
+
+	file, err := Prepare(path, 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, "logs.ndjson.gz", file.Name)
+	assert.Equal(t, "already compressed", string(file.Body))
+}
+
+func TestUploadJira(t *testing.T) {
+	var got struct {
+		path, user, pass, token, name string
+		body                          []byte
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path = r.URL.Path
+		got.user, got.pass, _ = r.BasicAuth()
+		got.token = r.Header.Get("X-Atlassian-Token")
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		got.name = header.Filename
+		got.body, _ = io.ReadAll(file)
+		w.Write([]byte(`[{"id":"10001"}]`))
+	}))
+	defer server.Close()
+
+	target := Target{System: "jira", Key: "PROJ-123", URL: server.URL, User: "me@example.com", Token: "secret"}
+	err := Upload(context.Background(), server.Client(), target, File{Name: "logs.ndjson.gz", ContentType: "application/gzip", Body: []byte("gz")})
+	require.NoError(t, err)
+
+	assert.Equal(t, "/rest/api/3/issue/PROJ-123/attachments", got.path)
+	assert.Equal(t, "me@example.com", got.user)
+	assert.Equal(t, "secret", got.pass)
+	assert.Equal(t, "no-check", got.token)
+	assert.Equal(t, "logs.ndjson.gz", got.name)
+	assert.Equal(t, "gz", string(got.body))
+}
+
+func TestUploadJiraReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errorMessages":["Issue does not exist"]}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	target := Target{System: "jira", Key: "PROJ-404", URL: server.URL, Token: "pat"}
+	err := Upload(context.Background(), server.Client(), target, File{Name: "logs.ndjson.gz", Body: []byte("gz")})
+	assert.ErrorContains(t, err, "404")
+	assert.ErrorContains(t, err, "Issue does not exist")
+}
+
+func TestUploadLinear(t *testing.T) {
+	var uploaded []byte
+	var attachment map[string]string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			assert.Equal(t, "signed", r.Header.Get("X-Upload-Signature"))
+			uploaded, _ = io.ReadAll(r.Body)
+			return
+		}
+
+		assert.Equal(t, "lin_api_x", r.Header.Get("Authorization"))
+		var req struct {
+			Query     string                     `json:"query"`
+			Variables map[string]json.RawMessage `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case strings.Contains(req.Query, "fileUpload"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"fileUpload": map[string]interface{}{
+				"uploadFile": map[string]interface{}{
+					"uploadUrl": server.URL + "/upload",
+					"assetUrl":  "https://uploads.linear.app/logs.ndjson.gz",
+					"headers":   []map[string]string{{"key": "X-Upload-Signature", "value": "signed"}},
+				},
+			}}})
+		case strings.Contains(req.Query, "attachmentCreate"):
+			require.NoError(t, json.Unmarshal(req.Variables["input"], &attachment))
+			w.Write([]byte(`{"data":{"attachmentCreate":{"success":true}}}`))
+		}
+	}))
+	defer server.Close()
+
+	target := Target{System: "linear", Key: "ENG-7", URL: server.URL, Token: "lin_api_x"}
+	err := Upload(context.Background(), server.Client(), target, File{Name: "logs.ndjson.gz", ContentType: "application/gzip", Body: []byte("gz")})
+	require.NoError(t, err)
+
+	assert.Equal(t, "gz", string(uploaded))
+	assert.Equal(t, map[string]string{"issueId": "ENG-7", "title": "logs.ndjson.gz", "url": "https://uploads.linear.app/logs.ndjson.gz"}, attachment)
+}
+
+func TestUploadLinearReportsGraphQLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"Authentication required"}]}`))
+	}))
+	defer server.Close()
+
+	target := Target{System: "linear", Key: "ENG-7", URL: server.URL, Token: "bad"}
+	err := Upload(context.Background(), server.Client(), target, File{Name: "logs.ndjson.gz", Body: []byte("gz")})
+	assert.ErrorContains(t, err, "Authentication required")
+}
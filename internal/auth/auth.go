@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+)
+
+// Checker is the API the checks call; *fetcher.Client implements it
+type Checker interface {
+	ValidateAPIKey(ctx context.Context) error
+	KeyOwner(ctx context.Context) (fetcher.KeyOwner, error)
+	Org(ctx context.Context) (fetcher.Org, error)
+	ProbeLogs(ctx context.Context, index string) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name   string
+	OK     bool
+	Detail string // what was found, or what went wrong
+	Hint   string // how to fix a failure
+}
+
+// Report is the outcome of checking a pair of keys
+type Report struct {
+	Results []Result
+}
+
+// Check checks in turn that the API key is valid, that the application key
+// is, which organization they belong to, and that they can read logs from
+// index. Later checks are skipped once the keys are refused, as they would
+// fail the same way.
+func Check(ctx context.Context, c Checker, index string) Report {
+	var r Report
+
+	if err := c.ValidateAPIKey(ctx); err != nil {
+		r.add(Result{Name: "API key", Detail: describe(err), Hint: apiKeyHint(err)})
+		return r
+	}
+	r.add(Result{Name: "API key", OK: true, Detail: "valid"})
+
+	owner, err := c.KeyOwner(ctx)
+	if err != nil {
+		r.add(Result{Name: "Application key", Detail: describe(err), Hint: appKeyHint(err)})
+		return r
+	}
+	r.add(Result{Name: "Application key", OK: true, Detail: describeOwner(owner)})
+
+	// Reading the organization takes more than reading logs, so not
+	// knowing it doesn't fail the check
+	if org, err := c.Org(ctx); err != nil {
+		r.add(Result{Name: "Organization", OK: true, Detail: "unknown (" + describe(err) + ")"})
+	} else {
+		r.add(Result{Name: "Organization", OK: true, Detail: fmt.Sprintf("%s (public ID %s)", org.Name, org.PublicID)})
+	}
+
+	if err := c.ProbeLogs(ctx, index); err != nil {
+		r.add(Result{Name: "logs_read_data", Detail: describe(err), Hint: logsHint(err, index)})
+		return r
+	}
+	r.add(Result{Name: "logs_read_data", OK: true, Detail: "granted, can search index " + index})
+	return r
+}
+
+// OK reports whether every check passed
+func (r Report) OK() bool {
+	for _, res := range r.Results {
+		if !res.OK {
+			return false
+		}
+	}
+	return len(r.Results) > 0
+}
+
+// Write lists the results, with hints under failures
+func (r Report) Write(w io.Writer) {
+	for _, res := range r.Results {
+		mark := "ok"
+		if !res.OK {
+			mark = "FAILED"
+		}
+		fmt.Fprintf(w, "  %-16s %-6s %s\n", res.Name, mark, res.Detail)
+		if res.Hint != "" {
+			fmt.Fprintf(w, "  %-16s %-6s %s\n", "", "", res.Hint)
+		}
+	}
+}
+
+func (r *Report) add(res Result) {
+	r.Results = append(r.Results, res)
+}
+
+// status returns the HTTP status of a failed request, 0 if there was none
+func status(err error) int {
+	var reqErr *fetcher.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.StatusCode
+	}
+	return 0
+}
+
+// describe summarizes a failed check
+func describe(err error) string {
+	switch status(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Sprintf("refused (status %d)", status(err))
+	case 0:
+		return err.Error()
+	default:
+		return fmt.Sprintf("status %d: %v", status(err), err)
+	}
+}
+
+func describeOwner(owner fetcher.KeyOwner) string {
+	who := owner.Name
+	if who == "" {
+		who = owner.Handle
+	} else if owner.Handle != "" {
+		who += " <" + owner.Handle + ">"
+	}
+	if who == "" {
+		return "valid"
+	}
+	if owner.ServiceAccount {
+		return "valid, owned by service account " + who
+	}
+	return "valid, owned by " + who
+}
+
+func apiKeyHint(err error) string {
+	switch status(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "Check DD_API_KEY, and that DD_SITE is the site the key's organization is on"
+	case 0:
+		return "Check the network, and DD_SITE or --api-url"
+	}
+	return ""
+}
+
+func appKeyHint(err error) string {
+	switch status(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "Check DD_APP_KEY, and that it belongs to the same organization as DD_API_KEY"
+	}
+	return ""
+}
+
+func logsHint(err error, index string) string {
+	switch status(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Sprintf("The key's owner needs a role with logs_read_data, a scoped key needs the logs_read_data scope, and restricted indexes need access to %s", index)
+	case http.StatusBadRequest:
+		return fmt.Sprintf("Check that index %s exists", index)
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/fetcher"
+)
+
+type fakeChecker struct {
+	apiKeyErr error
+	owner     fetcher.KeyOwner
+	ownerErr  error
+	org       fetcher.Org
+	orgErr    error
+	logsErr   error
+	probed    string
+}
+
+func (f *fakeChecker) ValidateAPIKey(context.Context) error { return f.apiKeyErr }
+func (f *fakeChecker) KeyOwner(context.Context) (fetcher.KeyOwner, error) {
+	return f.owner, f.ownerErr
+}
+func (f *fakeChecker) Org(context.Context) (fetcher.Org, error) { return f.org, f.orgErr }
+func (f *fakeChecker) ProbeLogs(_ context.Context, index string) error {
+	f.probed = index
+	return f.logsErr
+}
+
+func refused(status int) error {
+	return &fetcher.RequestError{Err: errors.New("Forbidden"), StatusCode: status, Attempts: 1}
+}
+
+func TestCheckPasses(t *testing.T) {
+	c := &fakeChecker{
+		owner: fetcher.KeyOwner{Name: "Jane Doe", Handle: "jane@example.com"},
+		org:   fetcher.Org{Name: "Acme", PublicID: "abc123"},
+	}
+	r := Check(context.Background(), c, "main")
+	assert.True(t, r.OK())
+	assert.Equal(t, "main", c.probed)
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	assert.Equal(t, "  API key          ok     valid\n"+
+		"  Application key  ok     valid, owned by Jane Doe <jane@example.com>\n"+
+		"  Organization     ok     Acme (public ID abc123)\n"+
+		"  logs_read_data   ok     granted, can search index main\n", buf.String())
+}
+
+func TestCheckStopsAtARefusedAPIKey(t *testing.T) {
+	r := Check(context.Background(), &fakeChecker{apiKeyErr: refused(403)}, "main")
+	assert.False(t, r.OK())
+	require.Len(t, r.Results, 1)
+	assert.Equal(t, "refused (status 403)", r.Results[0].Detail)
+	assert.Contains(t, r.Results[0].Hint, "DD_SITE")
+}
+
+func TestCheckRefusedAppKey(t *testing.T) {
+	r := Check(context.Background(), &fakeChecker{ownerErr: refused(403)}, "main")
+	assert.False(t, r.OK())
+	require.Len(t, r.Results, 2)
+	assert.Contains(t, r.Results[1].Hint, "DD_APP_KEY")
+}
+
+func TestCheckWithoutLogsReadData(t *testing.T) {
+	c := &fakeChecker{
+		owner:   fetcher.KeyOwner{Handle: "ci-bot", ServiceAccount: true},
+		orgErr:  refused(403),
+		logsErr: refused(403),
+	}
+	r := Check(context.Background(), c, "audit")
+	assert.False(t, r.OK())
+	require.Len(t, r.Results, 4)
+	assert.Equal(t, "valid, owned by service account ci-bot", r.Results[1].Detail)
+	assert.True(t, r.Results[2].OK, "an unknown organization doesn't fail the check")
+	assert.Equal(t, "unknown (refused (status 403))", r.Results[2].Detail)
+	assert.False(t, r.Results[3].OK)
+	assert.Contains(t, r.Results[3].Hint, "logs_read_data")
+	assert.Contains(t, r.Results[3].Hint, "audit")
+}
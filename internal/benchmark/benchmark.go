@@ -0,0 +1,184 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/synth"
+	"github.com/jtzemp/dogfetch/internal/writer"
+)
+
+// corpusStart anchors generated corpora so every run sees identical logs
+var corpusStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Case is one writer configuration to benchmark
+type Case struct {
+	Name    string
+	Format  string
+	Options writer.Options
+}
+
+// DefaultCases covers every file format plus the buffering output modes
+var DefaultCases = []Case{
+	{Name: "json", Format: "json"},
+	{Name: "ndjson", Format: "ndjson"},
+	{Name: "ndjson-stitch", Format: "ndjson", Options: writer.Options{StitchBy: "session_id"}},
+	{Name: "msgpack", Format: "msgpack"},
+	{Name: "otlp", Format: "otlp"},
+	{Name: "cef", Format: "cef"},
+	{Name: "leef", Format: "leef"},
+	{Name: "text", Format: "text"},
+	{Name: "sarif", Format: "sarif"},
+	{Name: "xlsx", Format: "xlsx"},
+	{Name: "aggregate", Format: "aggregate", Options: writer.Options{
+		GroupBy:    []string{"service", "status"},
+		Bucket:     time.Hour,
+		KThreshold: 5,
+	}},
+}
+
+// Corpus is a reproducible set of synthetic pages
+type Corpus struct {
+	Pages   [][]datadogV2.Log
+	Records int
+}
+
+// NewCorpus generates count logs from seed, split into pages of pageSize
+func NewCorpus(seed uint64, count, pageSize int) *Corpus {
+	gen := synth.New(seed, count, corpusStart, corpusStart.Add(24*time.Hour))
+	c := &Corpus{Records: count}
+	for offset := 0; offset < count; offset += pageSize {
+		c.Pages = append(c.Pages, gen.Page(offset, pageSize))
+	}
+	return c
+}
+
+// Result is the measured cost of writing a corpus with one case
+type Result struct {
+	Name                 string  `json:"name"`
+	RecordsPerSec        float64 `json:"records_per_sec"`
+	AllocsPerRecord      float64 `json:"allocs_per_record"`
+	AllocBytesPerRecord  float64 `json:"alloc_bytes_per_record"`
+	OutputBytesPerRecord float64 `json:"output_bytes_per_record"`
+}
+
+// WriteCorpus writes every page of the corpus through a fresh writer
+func WriteCorpus(c Case, corpus *Corpus, out io.Writer) error {
+	w, err := writer.NewWithOutput(c.Format, out, c.Options)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for _, page := range corpus.Pages {
+		if err := w.WritePage(page); err != nil {
+			return err
+		}
+	}
+	return w.Finalize()
+}
+
+// Run writes the corpus repeatedly for at least minDuration (and at least
+// once) and reports throughput and allocations per record
+func Run(c Case, corpus *Corpus, minDuration time.Duration) (Result, error) {
+	// The first pass checks for errors and measures the output size
+	var counter countingWriter
+	if err := WriteCorpus(c, corpus, &counter); err != nil {
+		return Result{}, fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	passes := 0
+	start := time.Now()
+	for passes == 0 || time.Since(start) < minDuration {
+		if err := WriteCorpus(c, corpus, io.Discard); err != nil {
+			return Result{}, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		passes++
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	records := float64(passes * corpus.Records)
+	return Result{
+		Name:                 c.Name,
+		RecordsPerSec:        records / elapsed.Seconds(),
+		AllocsPerRecord:      float64(after.Mallocs-before.Mallocs) / records,
+		AllocBytesPerRecord:  float64(after.TotalAlloc-before.TotalAlloc) / records,
+		OutputBytesPerRecord: float64(counter) / float64(corpus.Records),
+	}, nil
+}
+
+// Regression is a case whose throughput fell beyond the allowed drop
+type Regression struct {
+	Name     string
+	Baseline float64 // records/sec
+	Current  float64 // records/sec
+	Change   float64 // fractional change, e.g. -0.25
+}
+
+// Compare returns the cases in current whose throughput dropped by more
+// than maxDrop (a fraction) against baseline; cases missing from either side
+// are ignored
+func Compare(baseline, current []Result, maxDrop float64) []Regression {
+	base := make(map[string]Result, len(baseline))
+	for _, r := range baseline {
+		base[r.Name] = r
+	}
+
+	var regressions []Regression
+	for _, r := range current {
+		b, ok := base[r.Name]
+		if !ok || b.RecordsPerSec <= 0 {
+			continue
+		}
+		change := r.RecordsPerSec/b.RecordsPerSec - 1
+		if change < -maxDrop {
+			regressions = append(regressions, Regression{
+				Name:     r.Name,
+				Baseline: b.RecordsPerSec,
+				Current:  r.RecordsPerSec,
+				Change:   change,
+			})
+		}
+	}
+	return regressions
+}
+
+// WriteFile saves results as JSON, e.g. to use as a baseline
+func WriteFile(path string, results []Result) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadFile loads results saved by WriteFile
+func ReadFile(path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("invalid benchmark results in %s: %w", path, err)
+	}
+	return results, nil
+}
+
+// countingWriter discards output but counts its size
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
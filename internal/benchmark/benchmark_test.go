@@ -0,0 +1,80 @@
+package benchmark
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BenchmarkWriters measures every default case; run with
+// go test -bench Writers -benchmem ./internal/benchmark
+func BenchmarkWriters(b *testing.B) {
+	corpus := NewCorpus(1, 10000, 1000)
+	for _, c := range DefaultCases {
+		b.Run(c.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := WriteCorpus(c, corpus, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*corpus.Records)/b.Elapsed().Seconds(), "records/s")
+		})
+	}
+}
+
+func TestNewCorpusIsReproducible(t *testing.T) {
+	a := NewCorpus(7, 25, 10)
+	b := NewCorpus(7, 25, 10)
+
+	require.Len(t, a.Pages, 3)
+	assert.Len(t, a.Pages[2], 5)
+	assert.Equal(t, 25, a.Records)
+	assert.Equal(t, a.Pages, b.Pages)
+}
+
+func TestRun(t *testing.T) {
+	corpus := NewCorpus(1, 200, 100)
+	for _, c := range DefaultCases {
+		r, err := Run(c, corpus, 0)
+		require.NoError(t, err, c.Name)
+		assert.Equal(t, c.Name, r.Name)
+		assert.Greater(t, r.RecordsPerSec, 0.0, c.Name)
+		assert.Greater(t, r.AllocsPerRecord, 0.0, c.Name)
+		assert.Greater(t, r.OutputBytesPerRecord, 0.0, c.Name)
+	}
+
+	_, err := Run(Case{Name: "bogus", Format: "bogus"}, corpus, 0)
+	assert.ErrorContains(t, err, "bogus")
+}
+
+func TestCompare(t *testing.T) {
+	baseline := []Result{
+		{Name: "ndjson", RecordsPerSec: 1000},
+		{Name: "json", RecordsPerSec: 1000},
+		{Name: "removed", RecordsPerSec: 1000},
+	}
+	current := []Result{
+		{Name: "ndjson", RecordsPerSec: 950},
+		{Name: "json", RecordsPerSec: 700},
+		{Name: "new", RecordsPerSec: 1},
+	}
+
+	regressions := Compare(baseline, current, 0.1)
+	require.Len(t, regressions, 1)
+	assert.Equal(t, "json", regressions[0].Name)
+	assert.InDelta(t, -0.3, regressions[0].Change, 1e-9)
+}
+
+func TestWriteAndReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	results := []Result{{Name: "ndjson", RecordsPerSec: 1234.5, AllocsPerRecord: 12}}
+
+	require.NoError(t, WriteFile(path, results))
+	loaded, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, results, loaded)
+}
@@ -0,0 +1,103 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// FetchCase is one fetch setting to time against the API
+type FetchCase struct {
+	PageSize    int
+	Concurrency int // fetches of adjacent slices of the range run at once
+}
+
+// FetchCases crosses every page size with every concurrency level
+func FetchCases(pageSizes, concurrency []int) []FetchCase {
+	var cases []FetchCase
+	for _, n := range concurrency {
+		for _, size := range pageSizes {
+			cases = append(cases, FetchCase{PageSize: size, Concurrency: n})
+		}
+	}
+	return cases
+}
+
+// FetchResult is the throughput of one bounded fetch case
+type FetchResult struct {
+	PageSize    int     `json:"page_size"`
+	Concurrency int     `json:"concurrency"`
+	Logs        int     `json:"logs"`
+	Pages       int     `json:"pages"`
+	Retries     int     `json:"retries"`
+	RateLimited int     `json:"rate_limited"` // retries that waited out a rate limit
+	Seconds     float64 `json:"seconds"`
+	LogsPerSec  float64 `json:"logs_per_sec"`
+}
+
+// NewFetchResult works out the throughput of logs fetched in elapsed
+func NewFetchResult(c FetchCase, logs, pages, retries, rateLimited int, elapsed time.Duration) FetchResult {
+	r := FetchResult{
+		PageSize:    c.PageSize,
+		Concurrency: c.Concurrency,
+		Logs:        logs,
+		Pages:       pages,
+		Retries:     retries,
+		RateLimited: rateLimited,
+		Seconds:     elapsed.Seconds(),
+	}
+	if elapsed > 0 {
+		r.LogsPerSec = float64(logs) / elapsed.Seconds()
+	}
+	return r
+}
+
+// WriteFetchFile saves fetch results as JSON
+func WriteFetchFile(path string, results []FetchResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Best returns the fastest result, preferring less concurrency and then
+// smaller pages when throughput ties, since they are gentler on the API
+func Best(results []FetchResult) (FetchResult, bool) {
+	if len(results) == 0 {
+		return FetchResult{}, false
+	}
+	best := results[0]
+	for _, r := range results[1:] {
+		switch {
+		case r.LogsPerSec > best.LogsPerSec:
+			best = r
+		case r.LogsPerSec == best.LogsPerSec && (r.Concurrency < best.Concurrency ||
+			r.Concurrency == best.Concurrency && r.PageSize < best.PageSize):
+			best = r
+		}
+	}
+	return best, true
+}
+
+// SplitRange divides [from, to) into n adjacent windows of equal length for
+// concurrent fetches
+func SplitRange(from, to time.Time, n int) ([]plan.Window, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("concurrency must be positive, got %d", n)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("range is empty: %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	step := to.Sub(from) / time.Duration(n)
+	windows := make([]plan.Window, n)
+	for i := range windows {
+		windows[i] = plan.Window{From: from.Add(time.Duration(i) * step), To: from.Add(time.Duration(i+1) * step)}
+	}
+	// Rounding the step down leaves the remainder to the last window
+	windows[n-1].To = to
+	return windows, nil
+}
@@ -0,0 +1,57 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+func TestFetchCases(t *testing.T) {
+	assert.Equal(t, []FetchCase{
+		{PageSize: 1000, Concurrency: 1},
+		{PageSize: 5000, Concurrency: 1},
+		{PageSize: 1000, Concurrency: 2},
+		{PageSize: 5000, Concurrency: 2},
+	}, FetchCases([]int{1000, 5000}, []int{1, 2}))
+}
+
+func TestNewFetchResult(t *testing.T) {
+	r := NewFetchResult(FetchCase{PageSize: 1000, Concurrency: 2}, 5000, 5, 1, 1, 2*time.Second)
+	assert.Equal(t, 2500.0, r.LogsPerSec)
+	assert.Equal(t, 2.0, r.Seconds)
+	assert.Equal(t, 0.0, NewFetchResult(FetchCase{}, 10, 1, 0, 0, 0).LogsPerSec)
+}
+
+func TestBest(t *testing.T) {
+	_, ok := Best(nil)
+	assert.False(t, ok)
+
+	best, ok := Best([]FetchResult{
+		{PageSize: 1000, Concurrency: 1, LogsPerSec: 800},
+		{PageSize: 5000, Concurrency: 2, LogsPerSec: 1200},
+		{PageSize: 2000, Concurrency: 1, LogsPerSec: 1200},
+		{PageSize: 1000, Concurrency: 1, LogsPerSec: 1200},
+	})
+	require.True(t, ok)
+	assert.Equal(t, FetchResult{PageSize: 1000, Concurrency: 1, LogsPerSec: 1200}, best, "ties go to less concurrency, then smaller pages")
+}
+
+func TestSplitRange(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	windows, err := SplitRange(from, from.Add(10*time.Nanosecond), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []plan.Window{
+		{From: from, To: from.Add(3)},
+		{From: from.Add(3), To: from.Add(6)},
+		{From: from.Add(6), To: from.Add(10)},
+	}, windows)
+
+	_, err = SplitRange(from, from.Add(time.Hour), 0)
+	assert.Error(t, err)
+	_, err = SplitRange(from, from, 1)
+	assert.Error(t, err)
+}
@@ -2,10 +2,37 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/jtzemp/dogfetch/internal/encrypt"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+	"github.com/jtzemp/dogfetch/internal/filter"
+	"github.com/jtzemp/dogfetch/internal/grok"
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/jtzemp/dogfetch/internal/redact"
+	"github.com/jtzemp/dogfetch/internal/siem"
+	"github.com/jtzemp/dogfetch/internal/state"
 )
 
+// Formats lists the supported output formats
+var Formats = []string{"json", "ndjson", "msgpack", "otlp", "cef", "leef", "text", "pretty", "sarif", "xlsx", "aggregate"}
+
+// streamableFormats write each page as it arrives, so they can be appended
+// to and resumed from a cursor
+var streamableFormats = []string{"ndjson", "msgpack", "otlp", "cef", "leef", "text", "pretty"}
+
+// Methods lists the Logs Search endpoints that can be used: the GET variant,
+// or POST, which takes the query in the body and so has no URL length limit
+var Methods = []string{"get", "post"}
+
+// Plans lists how a range can be split into windows: equal time slices,
+// slices balanced by log counts from the aggregation API, or slices balanced
+// separately for each index
+var Plans = []string{"slices", "balanced", "indexes"}
+
 // Config holds all configuration for the fetch operation
 type Config struct {
 	// Query parameters
@@ -14,19 +41,145 @@ type Config struct {
 	From  time.Time
 	To    time.Time
 
+	// Time zone of times given without an offset, and of the times progress
+	// and reports show (nil = UTC for parsing, times shown as given)
+	Location *time.Location
+
+	// Logs Search endpoint to use (see Methods); empty means get
+	Method string
+
+	// Split the range into windows of this size, fetched oldest first
+	// (0 = one cursor over the whole range); with the balanced plan it caps
+	// each window's length instead (0 = no cap)
+	Window time.Duration
+
+	// How windows are planned (see Plans); empty means slices. The balanced
+	// and indexes plans aim for WindowLogs logs per window
+	Plan       string
+	WindowLogs int64
+
+	// When a page fails for good, give up on the rest of its window and
+	// continue with the next instead of failing the fetch
+	SkipErrors bool
+
 	// Pagination
 	PageSize int32
 	Cursor   string
 
+	// The window Cursor belongs to when resuming a windowed fetch
+	CursorWindow *plan.Window
+
+	// The last log fetched under Cursor, where the fetch restarts if the
+	// cursor has expired
+	CursorLast *state.Watermark
+
+	// The size of the output when Cursor was saved; appending cuts off
+	// anything written after it, which the fetch writes again
+	CursorSize int64
+
+	// Incremental runs continue from where the last one in StatePath left
+	// off; Watermark is where this run starts and Pending what an
+	// interrupted run had exported when resuming it (see StartIncremental)
+	Incremental bool
+	Watermark   *state.Watermark
+	Pending     *state.Watermark
+
+	// How cursors appear in progress output and summaries (see
+	// CursorDisplays); StatePath, when set, keeps the full value
+	CursorDisplay string
+	StatePath     string
+
 	// Output
 	OutputPath string
-	Format     string // "json" or "ndjson"
+	Format     string // see Formats
 	Append     bool
+	Force      bool // append to output that doesn't end in a complete record
+	Tee        bool // also copy the output to stdout
+	Split      int  // write numbered files of at most this many logs each; 0 writes one
+
+	// Encrypt output files as they are written (nil = in the clear)
+	Encrypt *encrypt.Encrypter
+
+	// Write the output to <output>.tmp and rename it into place once the
+	// export is complete
+	Atomic bool
+
+	// Restart an exec:<command> output up to this many times if it exits
+	// before the fetch is done
+	ExecRestarts int
+
+	// Webhook output: logs per request (0 is writer.DefaultWebhookBatch) and
+	// extra request headers
+	WebhookBatch   int
+	WebhookHeaders map[string]string
+
+	// Aggregate format (anonymized bucketed counts)
+	AggregateBy      []string
+	AggregateBucket  time.Duration
+	AggregateK       int
+	AggregateEpsilon float64
+
+	// Group NDJSON output into per-session documents by this field
+	StitchBy string
+
+	// Maintain a sidecar seek index next to an NDJSON output file
+	SidecarIndex bool
+
+	// Write NDJSON records as the API sent them instead of encoding the
+	// decoded logs again
+	Raw bool
+
+	// OTLP format: export to an OTLP/HTTP endpoint instead of a file
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+
+	// CEF and LEEF formats: field mapping overrides
+	SIEMFields []siem.Field
+
+	// Message patterns tried in order; the first match's fields are merged
+	// into the log
+	ParsePatterns []*grok.Pattern
+
+	// Redaction rules applied to every log before it is written
+	Redactions []redact.Rule
+
+	// Client-side predicates a log must all satisfy to be written
+	Filters []*filter.Filter
+
+	// Text format: fields written on each line
+	TextFields []string
+
+	// Pretty format: don't color the output (--no-color)
+	NoColor bool
+
+	// SARIF format: fields naming each result's rule
+	SARIFRuleFields []string
+
+	// Memory cap for buffering writers in bytes (0 = unlimited)
+	MaxMemory int64
+
+	// Versioned envelope each record is wrapped in, e.g. "v1" ("" = none)
+	Envelope string
 
 	// Datadog credentials
 	APIKey string
 	AppKey string
 	Site   string
+	APIURL string // overrides Site, e.g. for proxies and mock servers
+
+	// Sent with every API request, e.g. for an egress gateway to route by
+	UserAgent string            // replaces the API client's default
+	Headers   map[string]string // extra headers
+
+	// Log every API request and response, with keys redacted
+	DebugHTTP bool
+
+	// HTTP record/replay
+	RecordPath string
+	ReplayPath string
+
+	// Synthetic logs served in-process instead of calling Datadog (0 = off)
+	Demo int
 }
 
 // Validate checks the configuration for errors
@@ -35,28 +188,267 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("query is required")
 	}
 
-	if c.APIKey == "" {
-		return fmt.Errorf("DD_API_KEY environment variable is required")
+	if c.RecordPath != "" && c.ReplayPath != "" {
+		return fmt.Errorf("--record and --replay cannot be used together")
 	}
 
-	if c.AppKey == "" {
-		return fmt.Errorf("DD_APP_KEY environment variable is required")
+	if c.Demo > 0 && (c.RecordPath != "" || c.ReplayPath != "") {
+		return fmt.Errorf("--demo cannot be used with --record or --replay")
+	}
+
+	// Replayed and demo responses never reach Datadog, so no credentials are
+	// needed
+	if c.ReplayPath == "" && c.Demo == 0 {
+		if c.APIKey == "" {
+			return fmt.Errorf("DD_API_KEY environment variable is required")
+		}
+
+		if c.AppKey == "" {
+			return fmt.Errorf("DD_APP_KEY environment variable is required")
+		}
 	}
 
 	if c.PageSize < 1 || c.PageSize > 5000 {
 		return fmt.Errorf("pageSize must be between 1 and 5000, got %d", c.PageSize)
 	}
 
-	if c.Format != "json" && c.Format != "ndjson" {
-		return fmt.Errorf("format must be 'json' or 'ndjson', got '%s'", c.Format)
+	if c.Method != "" && !contains(Methods, c.Method) {
+		return fmt.Errorf("--method must be one of %s, got '%s'", strings.Join(Methods, ", "), c.Method)
+	}
+
+	if c.CursorDisplay != "" && !contains(CursorDisplays, c.CursorDisplay) {
+		return fmt.Errorf("--cursor-display must be one of %s, got '%s'", strings.Join(CursorDisplays, ", "), c.CursorDisplay)
+	}
+
+	if !validFormat(c.Format) {
+		return fmt.Errorf("format must be one of %s, got '%s'", strings.Join(Formats, ", "), c.Format)
+	}
+
+	if c.Format == "aggregate" {
+		if c.AggregateBucket <= 0 {
+			return fmt.Errorf("--bucket must be positive, got %s", c.AggregateBucket)
+		}
+		if c.AggregateK < 1 {
+			return fmt.Errorf("--k-threshold must be at least 1, got %d", c.AggregateK)
+		}
+		if c.AggregateEpsilon < 0 {
+			return fmt.Errorf("--epsilon must not be negative, got %g", c.AggregateEpsilon)
+		}
+	}
+
+	if c.StitchBy != "" {
+		if c.Format != "ndjson" {
+			return fmt.Errorf("--stitch-by only works with --format ndjson")
+		}
+		// A resumed or appended run would split sessions across documents
+		if c.Append || c.Cursor != "" {
+			return fmt.Errorf("--stitch-by cannot be used with --append or --cursor")
+		}
+	}
+
+	if c.SidecarIndex {
+		if c.Format != "ndjson" || c.StitchBy != "" {
+			return fmt.Errorf("--sidecar-index only works with --format ndjson without --stitch-by")
+		}
+		if !c.FileOutput() {
+			return fmt.Errorf("--sidecar-index requires --output to a file")
+		}
+		// Offsets into a compressed file can't be seeked to
+		if c.GzipOutput() {
+			return fmt.Errorf("--sidecar-index cannot index gzipped output")
+		}
+	}
+
+	if c.Raw {
+		if c.Format != "ndjson" {
+			return fmt.Errorf("--raw only works with --format ndjson")
+		}
+		// Records are written as they arrived, so nothing may change them
+		if c.StitchBy != "" || c.Envelope != "" || len(c.ParsePatterns) > 0 || len(c.Redactions) > 0 {
+			return fmt.Errorf("--raw cannot be used with --stitch-by, --envelope, --parse, --redact or --scrub")
+		}
+		if c.OutputPath != "" && (!c.FileOutput() || c.GzipOutput() || c.Split > 0 || c.Tee || c.Encrypt != nil) {
+			return fmt.Errorf("--raw writes to stdout or a plain file; it cannot be used with gzip, socket, syslog, exec, webhook or firehose output, --split, --tee or --encrypt-recipient")
+		}
+	}
+
+	if c.Atomic {
+		if !c.FileOutput() {
+			return fmt.Errorf("--atomic requires --output to a file")
+		}
+		// Only a file written from scratch can be swapped in whole
+		if c.Append || c.Cursor != "" {
+			return fmt.Errorf("--atomic cannot be used with --append, --cursor or --resume")
+		}
+		if c.SidecarIndex {
+			return fmt.Errorf("--atomic cannot be used with --sidecar-index")
+		}
+	}
+
+	if c.Encrypt != nil {
+		if !c.FileOutput() {
+			return fmt.Errorf("--encrypt-recipient requires --output to a file")
+		}
+		// An encrypted stream can't be continued, only started again
+		if c.Append || c.Cursor != "" {
+			return fmt.Errorf("--encrypt-recipient cannot be used with --append, --cursor or --resume")
+		}
+		if c.SidecarIndex {
+			return fmt.Errorf("--sidecar-index cannot index encrypted output")
+		}
+		// Spill files would hold the logs in the clear
+		if c.MaxMemory > 0 {
+			return fmt.Errorf("--encrypt-recipient cannot be used with --max-memory")
+		}
+	}
+
+	if c.OTLPEndpoint != "" {
+		if c.Format != "otlp" {
+			return fmt.Errorf("--otlp-endpoint only works with --format otlp")
+		}
+		if c.OutputPath != "" {
+			return fmt.Errorf("--otlp-endpoint and --output cannot be used together")
+		}
+	}
+
+	if len(c.SIEMFields) > 0 && c.Format != "cef" && c.Format != "leef" {
+		return fmt.Errorf("--siem-field only works with --format cef or leef")
+	}
+
+	if len(c.TextFields) > 0 && c.Format != "text" {
+		return fmt.Errorf("--text-field only works with --format text")
+	}
+
+	if len(c.SARIFRuleFields) > 0 && c.Format != "sarif" {
+		return fmt.Errorf("--sarif-rule-field only works with --format sarif")
+	}
+
+	if c.Envelope != "" {
+		if !contains(envelope.Versions, c.Envelope) {
+			return fmt.Errorf("--envelope must be one of %s, got '%s'", strings.Join(envelope.Versions, ", "), c.Envelope)
+		}
+		if c.Format != "json" && c.Format != "ndjson" && c.Format != "msgpack" {
+			return fmt.Errorf("--envelope only works with --format json, ndjson or msgpack")
+		}
+		// Both read records back in the API's shape
+		if c.StitchBy != "" || c.SidecarIndex {
+			return fmt.Errorf("--envelope cannot be used with --stitch-by or --sidecar-index")
+		}
+	}
+
+	// Syslog messages carry the log message, not a serialized document
+	if c.SyslogOutput() {
+		if c.Format != "ndjson" {
+			return fmt.Errorf("syslog output cannot be used with --format %s", c.Format)
+		}
+		if c.Append || c.StitchBy != "" {
+			return fmt.Errorf("syslog output cannot be used with --append or --stitch-by")
+		}
+	}
+
+	if c.Split < 0 {
+		return fmt.Errorf("--split must be positive, got %d", c.Split)
+	}
+	if c.Split > 0 {
+		if !c.FileOutput() {
+			return fmt.Errorf("--split requires --output to a file")
+		}
+		// Each chunk starts fresh, so there is no file to continue
+		if c.Append || c.Cursor != "" {
+			return fmt.Errorf("--split cannot be used with --append, --cursor or --resume")
+		}
+		if c.Format == "aggregate" || c.StitchBy != "" {
+			return fmt.Errorf("--split counts logs, so it cannot be used with --format aggregate or --stitch-by")
+		}
+	}
+
+	if c.Tee && c.OutputPath == "" {
+		return fmt.Errorf("--tee requires --output; without it logs already go to stdout")
+	}
+
+	// A socket is a stream with nothing to append to
+	if c.SocketOutput() && c.Append {
+		return fmt.Errorf("socket output cannot be used with --append")
+	}
+
+	// A command reads a stream of ndjson pages, resent whole on a restart
+	if c.ExecOutput() {
+		if c.Format != "ndjson" {
+			return fmt.Errorf("exec output streams ndjson; it cannot be used with --format %s", c.Format)
+		}
+		if c.Append || c.StitchBy != "" {
+			return fmt.Errorf("exec output cannot be used with --append or --stitch-by")
+		}
+	}
+	if c.ExecRestarts < 0 {
+		return fmt.Errorf("--exec-restarts must be positive, got %d", c.ExecRestarts)
+	}
+	if c.ExecRestarts > 0 && !c.ExecOutput() {
+		return fmt.Errorf("--exec-restarts requires an exec:<command> --output")
+	}
+
+	// A webhook takes batches of documents, as a JSON array or ndjson
+	if c.WebhookOutput() {
+		if c.Format != "json" && c.Format != "ndjson" {
+			return fmt.Errorf("webhook output sends json or ndjson; it cannot be used with --format %s", c.Format)
+		}
+		if c.Append || c.StitchBy != "" {
+			return fmt.Errorf("webhook output cannot be used with --append or --stitch-by")
+		}
+	}
+	if c.WebhookBatch < 0 {
+		return fmt.Errorf("--webhook-batch must be positive, got %d", c.WebhookBatch)
+	}
+	if (c.WebhookBatch > 0 || len(c.WebhookHeaders) > 0) && !c.WebhookOutput() {
+		return fmt.Errorf("--webhook-batch and --webhook-header require an http:// or https:// --output")
+	}
+
+	// Each Firehose record is one ndjson line
+	if c.FirehoseOutput() {
+		if c.Format != "ndjson" {
+			return fmt.Errorf("firehose output sends ndjson; it cannot be used with --format %s", c.Format)
+		}
+		if c.Append || c.StitchBy != "" {
+			return fmt.Errorf("firehose output cannot be used with --append or --stitch-by")
+		}
 	}
 
-	if c.Append && c.Format != "ndjson" {
-		return fmt.Errorf("--append only works with --format ndjson")
+	if c.Append && !contains(streamableFormats, c.Format) {
+		return fmt.Errorf("--append only works with streamable formats (%s)", strings.Join(streamableFormats, ", "))
 	}
 
-	if c.Cursor != "" && c.Format != "ndjson" {
-		return fmt.Errorf("--cursor only works with --format ndjson")
+	if c.Cursor != "" && !contains(streamableFormats, c.Format) {
+		return fmt.Errorf("--cursor only works with streamable formats (%s)", strings.Join(streamableFormats, ", "))
+	}
+
+	if c.Window < 0 {
+		return fmt.Errorf("--window must be positive, got %s", c.Window)
+	}
+	if c.Plan != "" && !contains(Plans, c.Plan) {
+		return fmt.Errorf("--plan must be one of %s, got '%s'", strings.Join(Plans, ", "), c.Plan)
+	}
+	if c.Counted() && c.WindowLogs <= 0 {
+		return fmt.Errorf("--window-logs must be positive, got %d", c.WindowLogs)
+	}
+	// A windowed fetch's cursors only cover their own window
+	if c.Windowed() && c.Cursor != "" && c.CursorWindow == nil {
+		return fmt.Errorf("--cursor cannot resume a fetch split into windows; use --resume with its --state-file")
+	}
+	if !c.Windowed() && c.CursorWindow != nil {
+		return fmt.Errorf("the fetch being resumed was split into windows; resume it with --window")
+	}
+	// Skipping a failed page means moving on to the next window
+	if c.SkipErrors && !c.Windowed() {
+		return fmt.Errorf("--skip-errors needs the range split into windows to continue with the next one; use --window, e.g. --window 1h")
+	}
+	// The watermark would move past a skipped window and never revisit it
+	if c.SkipErrors && c.Incremental {
+		return fmt.Errorf("--skip-errors cannot be used with --incremental")
+	}
+	// Balanced windows are planned from the aggregation API, which demo runs
+	// don't serve
+	if c.Demo > 0 && c.Counted() {
+		return fmt.Errorf("--demo cannot be used with --plan %s; use --plan slices", c.Plan)
 	}
 
 	if !c.To.IsZero() && c.From.After(c.To) {
@@ -66,9 +458,98 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// SyslogOutput reports whether the output is a syslog collector URL
+// (syslog://, syslog+udp://, syslog+tcp:// or syslog+tls://) rather than a file
+func (c *Config) SyslogOutput() bool {
+	return strings.HasPrefix(c.OutputPath, "syslog://") || strings.HasPrefix(c.OutputPath, "syslog+")
+}
+
+// SocketOutput reports whether the output is a local socket or Windows named
+// pipe URL (unix:// or npipe://) rather than a file
+func (c *Config) SocketOutput() bool {
+	return strings.HasPrefix(c.OutputPath, "unix://") || strings.HasPrefix(c.OutputPath, "npipe://")
+}
+
+// ExecOutput reports whether the output is a command to pipe logs into
+// (exec:<command>) rather than a file
+func (c *Config) ExecOutput() bool {
+	return strings.HasPrefix(c.OutputPath, "exec:")
+}
+
+// WebhookOutput reports whether the output is an http:// or https:// URL to
+// post logs to rather than a file
+func (c *Config) WebhookOutput() bool {
+	return strings.HasPrefix(c.OutputPath, "http://") || strings.HasPrefix(c.OutputPath, "https://")
+}
+
+// FirehoseOutput reports whether the output is an Amazon Data Firehose
+// delivery stream (firehose://<stream>) rather than a file
+func (c *Config) FirehoseOutput() bool {
+	return strings.HasPrefix(c.OutputPath, "firehose://")
+}
+
+// FileOutput reports whether the output is a file, rather than stdout, a
+// syslog collector, a socket, a command, a webhook or a delivery stream
+func (c *Config) FileOutput() bool {
+	return c.OutputPath != "" && !c.SyslogOutput() && !c.SocketOutput() && !c.ExecOutput() && !c.WebhookOutput() && !c.FirehoseOutput()
+}
+
+// PageOutput reports whether the output is a plain file written in place a
+// page at a time, neither compressed nor encrypted, so its size after a page
+// marks how much of it is done
+func (c *Config) PageOutput() bool {
+	return c.FileOutput() && !c.GzipOutput() && c.Encrypt == nil && !c.Atomic && c.Split == 0 && c.StitchBy == "" && contains(streamableFormats, c.Format)
+}
+
+// GzipOutput reports whether the output is a file compressed with gzip, as
+// a .gz extension asks for
+func (c *Config) GzipOutput() bool {
+	return strings.HasSuffix(strings.ToLower(c.OutputPath), ".gz") && c.FileOutput()
+}
+
+func validFormat(format string) bool {
+	return contains(Formats, format)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // ParseTime parses a time string in various formats
-// Supports: RFC3339, Unix timestamp (seconds)
+// Supports: RFC3339, the same without an offset (taken as UTC), a date such
+// as 2024-05-01 (midnight UTC), Unix timestamps in seconds or milliseconds,
+// and today or yesterday (midnight UTC at the start of the day).
 func ParseTime(s string) (time.Time, error) {
+	return ParseTimeIn(s, time.UTC)
+}
+
+// ParseTimeIn is ParseTime with times that carry no offset, such as
+// 2024-05-01T09:00:00, dates, today and yesterday, taken in loc
+func ParseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	return parseTimeAt(s, time.Now(), loc)
+}
+
+// msEpochThreshold separates Unix timestamps in milliseconds from those in
+// seconds: as seconds it is the year 5138, as milliseconds 1973
+const msEpochThreshold = 100_000_000_000
+
+// localLayouts are the formats accepted without an offset
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// parseTimeAt is ParseTimeIn with today and yesterday relative to now
+func parseTimeAt(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
 	if s == "" {
 		return time.Time{}, nil
 	}
@@ -78,12 +559,169 @@ func ParseTime(s string) (time.Time, error) {
 		return t, nil
 	}
 
-	// Try Unix timestamp
+	// Try a date, or a date and time, without an offset
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	// Try Unix timestamp, as pasted from dashboards in either unit
 	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if ts >= msEpochThreshold || ts <= -msEpochThreshold {
+			return time.UnixMilli(ts), nil
+		}
 		return time.Unix(ts, 0), nil
 	}
 
-	return time.Time{}, fmt.Errorf("unable to parse time '%s': expected RFC3339 or Unix timestamp", s)
+	y, m, d := now.In(loc).Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	switch strings.ToLower(s) {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time '%s': expected RFC3339, a date such as 2024-05-01, a Unix timestamp in seconds or milliseconds, today or yesterday", s)
+}
+
+// ParseByteSize parses a human-readable size such as "512MB", "2GiB" or "1048576"
+// Decimal (KB, MB, GB) and binary (KiB, MiB, GiB) units are supported.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KiB", 1 << 10},
+		{"MiB", 1 << 20},
+		{"GiB", 1 << 30},
+		{"KB", 1000},
+		{"MB", 1000 * 1000},
+		{"GB", 1000 * 1000 * 1000},
+		{"B", 1},
+	}
+
+	multiplier := int64(1)
+	number := s
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(s), strings.ToUpper(u.suffix)) {
+			multiplier = u.multiplier
+			number = strings.TrimSpace(s[:len(s)-len(u.suffix)])
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("unable to parse size '%s': expected e.g. 512MB or 2GiB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ParseHeaders parses extra API request headers given as "Key: value"
+// Credentials and the User-Agent have their own settings, so they are refused.
+func ParseHeaders(specs []string) (map[string]string, error) {
+	headers, err := parseHeaders(specs)
+	for key := range headers {
+		switch key {
+		case "Dd-Api-Key", "Dd-Application-Key":
+			return nil, fmt.Errorf("header %s can't be set; use DD_API_KEY and DD_APP_KEY", key)
+		case "User-Agent":
+			return nil, fmt.Errorf("header %s can't be set; use --user-agent", key)
+		}
+	}
+	return headers, err
+}
+
+// ParseWebhookHeaders parses webhook request headers given as "Key: value",
+// such as "Authorization: Bearer <token>"
+func ParseWebhookHeaders(specs []string) (map[string]string, error) {
+	return parseHeaders(specs)
+}
+
+// parseHeaders parses headers given as "Key: value", canonicalizing keys
+func parseHeaders(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, ":")
+		key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("unable to parse header '%s': expected Key:value", spec)
+		}
+		headers[key] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// ParseDuration parses a Go duration, additionally accepting whole days and
+// weeks such as "28d" or "2w"
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("unable to parse duration '%s': expected e.g. 1h, 28d or 2w", s)
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse duration '%s': expected e.g. 1h, 28d or 2w", s)
+	}
+	return d, nil
+}
+
+// TimeLocation returns the time zone times without an offset are taken in:
+// Location when set, else UTC
+func (c *Config) TimeLocation() *time.Location {
+	if c.Location != nil {
+		return c.Location
+	}
+	return time.UTC
+}
+
+// FormatTime formats a time for progress and reports, in Location when set;
+// a zero time is an open end of the range, shown as now
+func (c *Config) FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return "now"
+	}
+	if c.Location != nil {
+		t = t.In(c.Location)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// SetLast applies --last: a range from the duration before now up to now,
+// in place of --from and --to
+func (c *Config) SetLast(last string, now time.Time) error {
+	if last == "" {
+		return nil
+	}
+	if !c.From.IsZero() || !c.To.IsZero() {
+		return fmt.Errorf("--last sets both ends of the range and cannot be used with --from or --to")
+	}
+	d, err := ParseDuration(last)
+	if err != nil {
+		return fmt.Errorf("--last: %w", err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("--last must be a positive duration, got '%s'", last)
+	}
+	c.From, c.To = now.Add(-d), now
+	return nil
 }
 
 // DefaultFrom returns the default "from" time (24 hours)
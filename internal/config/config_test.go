@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jtzemp/dogfetch/internal/encrypt"
+	"github.com/jtzemp/dogfetch/internal/siem"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -55,6 +57,96 @@ func TestParseTime(t *testing.T) {
 	}
 }
 
+func TestParseTimeConvenienceFormats(t *testing.T) {
+	now := time.Date(2024, 5, 2, 15, 4, 5, 0, time.FixedZone("PDT", -7*3600))
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{input: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "1714521600", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "1714521600123", want: time.Date(2024, 5, 1, 0, 0, 0, 123e6, time.UTC)},
+		{input: "today", want: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+		{input: " Yesterday ", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTimeAt(tt.input, now, time.UTC)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+
+	_, err := parseTimeAt("2024-13-01", now, time.UTC)
+	assert.Error(t, err)
+	_, err = parseTimeAt("tomorrow", now, time.UTC)
+	assert.Error(t, err)
+}
+
+func TestParseTimeIn(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC) // already May 2 in Berlin
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{input: "2024-05-01T09:00:00", want: time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)},
+		{input: "2024-05-01 09:00", want: time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)},
+		{input: "2024-05-01", want: time.Date(2024, 4, 30, 22, 0, 0, 0, time.UTC)},
+		{input: "2024-01-15T09:00:00", want: time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{input: "2024-05-01T09:00:00Z", want: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{input: "today", want: time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTimeAt(tt.input, now, berlin)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+
+	// Without a zone, times without an offset are UTC
+	got, err := ParseTime("2024-05-01T09:00:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), got)
+}
+
+func TestFormatTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	ts := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "2024-05-01T07:00:00Z", (&Config{}).FormatTime(ts))
+	assert.Equal(t, "2024-05-01T09:00:00+02:00", (&Config{Location: berlin}).FormatTime(ts))
+	assert.Equal(t, "now", (&Config{Location: berlin}).FormatTime(time.Time{}))
+}
+
+func TestSetLast(t *testing.T) {
+	now := time.Date(2024, 5, 2, 15, 0, 0, 0, time.UTC)
+
+	var cfg Config
+	require.NoError(t, cfg.SetLast("2h", now))
+	assert.Equal(t, now.Add(-2*time.Hour), cfg.From)
+	assert.Equal(t, now, cfg.To)
+
+	cfg = Config{}
+	require.NoError(t, cfg.SetLast("7d", now))
+	assert.Equal(t, now.AddDate(0, 0, -7), cfg.From)
+
+	cfg = Config{}
+	require.NoError(t, cfg.SetLast("", now))
+	assert.True(t, cfg.From.IsZero())
+
+	cfg = Config{From: now.Add(-time.Hour)}
+	assert.ErrorContains(t, cfg.SetLast("2h", now), "cannot be used with --from or --to")
+	for _, last := range []string{"soon", "0s", "-1h"} {
+		cfg = Config{}
+		assert.Error(t, cfg.SetLast(last, now), last)
+	}
+}
+
 func TestParseTimeRFC3339(t *testing.T) {
 	input := "2024-01-01T00:00:00Z"
 	got, err := ParseTime(input)
@@ -125,6 +217,111 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "DD_APP_KEY",
 		},
+		{
+			name: "replay without credentials",
+			config: Config{
+				Query:      "service:web",
+				PageSize:   1000,
+				Format:     "ndjson",
+				ReplayPath: "cassette.json",
+			},
+			wantErr: false,
+		},
+		{
+			name: "record and replay together",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				RecordPath: "out.json",
+				ReplayPath: "in.json",
+			},
+			wantErr: true,
+			errMsg:  "--record and --replay",
+		},
+		{
+			name: "demo without credentials",
+			config: Config{
+				Query:    "*",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Demo:     100,
+			},
+			wantErr: false,
+		},
+		{
+			name: "demo and replay together",
+			config: Config{
+				Query:      "*",
+				PageSize:   1000,
+				Format:     "ndjson",
+				Demo:       100,
+				ReplayPath: "in.json",
+			},
+			wantErr: true,
+			errMsg:  "--demo cannot be used with --record or --replay",
+		},
+		{
+			name: "demo in windows",
+			config: Config{
+				Query:    "*",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Demo:     100,
+				Window:   time.Hour,
+			},
+			wantErr: false,
+		},
+		{
+			name: "demo with a balanced plan",
+			config: Config{
+				Query:      "*",
+				PageSize:   1000,
+				Format:     "ndjson",
+				Demo:       100,
+				Plan:       "balanced",
+				WindowLogs: 1000,
+			},
+			wantErr: true,
+			errMsg:  "--demo cannot be used with --plan balanced",
+		},
+		{
+			name: "envelope with a document format",
+			config: Config{
+				Query:    "*",
+				PageSize: 1000,
+				Format:   "msgpack",
+				Demo:     100,
+				Envelope: "v1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown envelope",
+			config: Config{
+				Query:    "*",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Demo:     100,
+				Envelope: "v9",
+			},
+			wantErr: true,
+			errMsg:  "--envelope must be one of v1, got 'v9'",
+		},
+		{
+			name: "envelope with text format",
+			config: Config{
+				Query:    "*",
+				PageSize: 1000,
+				Format:   "text",
+				Demo:     100,
+				Envelope: "v1",
+			},
+			wantErr: true,
+			errMsg:  "--envelope only works with --format json, ndjson or msgpack",
+		},
 		{
 			name: "page size too small",
 			config: Config{
@@ -161,6 +358,33 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "format must be",
 		},
+		{
+			name: "aggregate format",
+			config: Config{
+				Query:           "service:web",
+				APIKey:          "test-api-key",
+				AppKey:          "test-app-key",
+				PageSize:        1000,
+				Format:          "aggregate",
+				AggregateBy:     []string{"service"},
+				AggregateBucket: time.Hour,
+				AggregateK:      5,
+			},
+			wantErr: false,
+		},
+		{
+			name: "aggregate format without k",
+			config: Config{
+				Query:           "service:web",
+				APIKey:          "test-api-key",
+				AppKey:          "test-app-key",
+				PageSize:        1000,
+				Format:          "aggregate",
+				AggregateBucket: time.Hour,
+			},
+			wantErr: true,
+			errMsg:  "--k-threshold",
+		},
 		{
 			name: "append without ndjson",
 			config: Config{
@@ -174,6 +398,31 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "--append only works with",
 		},
+		{
+			name: "append with msgpack",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "msgpack",
+				Append:   true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "otlp endpoint without otlp format",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				OTLPEndpoint: "http://localhost:4318",
+			},
+			wantErr: true,
+			errMsg:  "--otlp-endpoint only works with --format otlp",
+		},
 		{
 			name: "cursor without ndjson",
 			config: Config{
@@ -201,6 +450,528 @@ func TestValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "--from",
 		},
+		{
+			name: "stitch-by without ndjson",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "json",
+				StitchBy: "session_id",
+			},
+			wantErr: true,
+			errMsg:  "--stitch-by only works with --format ndjson",
+		},
+		{
+			name: "stitch-by with cursor",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "ndjson",
+				StitchBy: "session_id",
+				Cursor:   "abc",
+			},
+			wantErr: true,
+			errMsg:  "--stitch-by cannot be used with --append or --cursor",
+		},
+		{
+			name: "sidecar index with ndjson file",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				OutputPath:   "logs.ndjson",
+				SidecarIndex: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "sidecar index to stdout",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				SidecarIndex: true,
+			},
+			wantErr: true,
+			errMsg:  "--sidecar-index requires --output to a file",
+		},
+		{
+			name: "split to stdout",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Split:    1000,
+			},
+			wantErr: true,
+			errMsg:  "--split requires --output to a file",
+		},
+		{
+			name: "split with resume",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "logs.ndjson",
+				Split:      1000,
+				Cursor:     "abc",
+			},
+			wantErr: true,
+			errMsg:  "--split cannot be used with --append, --cursor or --resume",
+		},
+		{
+			name: "split aggregate",
+			config: Config{
+				Query:           "service:web",
+				APIKey:          "test-api-key",
+				AppKey:          "test-app-key",
+				PageSize:        1000,
+				Format:          "aggregate",
+				AggregateBucket: time.Hour,
+				AggregateK:      5,
+				OutputPath:      "counts.json",
+				Split:           1000,
+			},
+			wantErr: true,
+			errMsg:  "--split counts logs",
+		},
+		{
+			name: "tee without output",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Tee:      true,
+			},
+			wantErr: true,
+			errMsg:  "--tee requires --output",
+		},
+		{
+			name: "append to a socket",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "unix:///var/run/ingest.sock",
+				Append:     true,
+			},
+			wantErr: true,
+			errMsg:  "socket output cannot be used with --append",
+		},
+		{
+			name: "exec output",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				OutputPath:   "exec:./ingest --stdin",
+				ExecRestarts: 3,
+			},
+			wantErr: false,
+		},
+		{
+			name: "exec output with json",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "json",
+				OutputPath: "exec:./ingest --stdin",
+			},
+			wantErr: true,
+			errMsg:  "exec output streams ndjson; it cannot be used with --format json",
+		},
+		{
+			name: "webhook output",
+			config: Config{
+				Query:          "service:web",
+				APIKey:         "test-api-key",
+				AppKey:         "test-app-key",
+				PageSize:       1000,
+				Format:         "json",
+				OutputPath:     "https://ingest.internal/logs",
+				WebhookBatch:   100,
+				WebhookHeaders: map[string]string{"Authorization": "Bearer abc"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "webhook output with msgpack",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "msgpack",
+				OutputPath: "https://ingest.internal/logs",
+			},
+			wantErr: true,
+			errMsg:  "webhook output sends json or ndjson; it cannot be used with --format msgpack",
+		},
+		{
+			name: "webhook batch without webhook output",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				WebhookBatch: 100,
+			},
+			wantErr: true,
+			errMsg:  "--webhook-batch and --webhook-header require an http:// or https:// --output",
+		},
+		{
+			name: "firehose output",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "firehose://logs-to-s3?region=us-east-1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "firehose output with json",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "json",
+				OutputPath: "firehose://logs-to-s3",
+			},
+			wantErr: true,
+			errMsg:  "firehose output sends ndjson; it cannot be used with --format json",
+		},
+		{
+			name: "exec restarts without exec output",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				OutputPath:   "logs.ndjson",
+				ExecRestarts: 1,
+			},
+			wantErr: true,
+			errMsg:  "--exec-restarts requires an exec:<command> --output",
+		},
+		{
+			name: "sidecar index of a socket",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				OutputPath:   "unix:///var/run/ingest.sock",
+				SidecarIndex: true,
+			},
+			wantErr: true,
+			errMsg:  "--sidecar-index requires --output to a file",
+		},
+		{
+			name: "raw ndjson file",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				OutputPath:   "logs.ndjson",
+				SidecarIndex: true,
+				Raw:          true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "raw without ndjson",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "json",
+				Raw:      true,
+			},
+			wantErr: true,
+			errMsg:  "--raw only works with --format ndjson",
+		},
+		{
+			name: "raw with envelope",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Envelope: "v1",
+				Raw:      true,
+			},
+			wantErr: true,
+			errMsg:  "--raw cannot be used with --stitch-by, --envelope, --parse, --redact or --scrub",
+		},
+		{
+			name: "raw gzipped output",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "logs.ndjson.gz",
+				Raw:        true,
+			},
+			wantErr: true,
+			errMsg:  "--raw writes to stdout or a plain file; it cannot be used with gzip, socket, syslog, exec, webhook or firehose output, --split, --tee or --encrypt-recipient",
+		},
+		{
+			name: "encrypted gzipped output",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "logs.ndjson.gz.age",
+				Encrypt:    &encrypt.Encrypter{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "atomic append",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "logs.ndjson",
+				Append:     true,
+				Atomic:     true,
+			},
+			wantErr: true,
+			errMsg:  "--atomic cannot be used with --append, --cursor or --resume",
+		},
+		{
+			name: "encrypted stdout",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Encrypt:  &encrypt.Encrypter{},
+			},
+			wantErr: true,
+			errMsg:  "--encrypt-recipient requires --output to a file",
+		},
+		{
+			name: "encrypted append",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "logs.ndjson.age",
+				Append:     true,
+				Encrypt:    &encrypt.Encrypter{},
+			},
+			wantErr: true,
+			errMsg:  "--encrypt-recipient cannot be used with --append, --cursor or --resume",
+		},
+		{
+			name: "sidecar index of gzipped output",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "ndjson",
+				OutputPath:   "logs.ndjson.gz",
+				SidecarIndex: true,
+			},
+			wantErr: true,
+			errMsg:  "--sidecar-index cannot index gzipped output",
+		},
+		{
+			name: "sidecar index without ndjson",
+			config: Config{
+				Query:        "service:web",
+				APIKey:       "test-api-key",
+				AppKey:       "test-app-key",
+				PageSize:     1000,
+				Format:       "msgpack",
+				OutputPath:   "logs.msgpack",
+				SidecarIndex: true,
+			},
+			wantErr: true,
+			errMsg:  "--sidecar-index only works with --format ndjson",
+		},
+		{
+			name: "sarif rule field without sarif",
+			config: Config{
+				Query:           "service:web",
+				APIKey:          "test-api-key",
+				AppKey:          "test-app-key",
+				PageSize:        1000,
+				Format:          "ndjson",
+				SARIFRuleFields: []string{"rule.id"},
+			},
+			wantErr: true,
+			errMsg:  "--sarif-rule-field only works with --format sarif",
+		},
+		{
+			name: "post method",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Method:   "post",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid method",
+			config: Config{
+				Query:    "service:web",
+				APIKey:   "test-api-key",
+				AppKey:   "test-app-key",
+				PageSize: 1000,
+				Format:   "ndjson",
+				Method:   "PUT",
+			},
+			wantErr: true,
+			errMsg:  "--method must be one of get, post",
+		},
+		{
+			name: "invalid cursor display",
+			config: Config{
+				Query:         "service:web",
+				APIKey:        "test-api-key",
+				AppKey:        "test-app-key",
+				PageSize:      1000,
+				Format:        "ndjson",
+				CursorDisplay: "redact",
+			},
+			wantErr: true,
+			errMsg:  "--cursor-display must be one of full, hash, truncate",
+		},
+		{
+			name: "text fields with text",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "text",
+				TextFields: []string{"timestamp", "message"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "text fields without text",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				TextFields: []string{"message"},
+			},
+			wantErr: true,
+			errMsg:  "--text-field only works with --format text",
+		},
+		{
+			name: "siem fields with cef",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "cef",
+				SIEMFields: []siem.Field{{Key: "suser", Path: "usr.name"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "siem fields without cef or leef",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				SIEMFields: []siem.Field{{Key: "suser", Path: "usr.name"}},
+			},
+			wantErr: true,
+			errMsg:  "--siem-field only works with --format cef or leef",
+		},
+		{
+			name: "syslog output",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "syslog+tcp://siem:514",
+			},
+			wantErr: false,
+		},
+		{
+			name: "syslog output with json",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "json",
+				OutputPath: "syslog://siem",
+			},
+			wantErr: true,
+			errMsg:  "syslog output cannot be used with --format json",
+		},
+		{
+			name: "syslog output with append",
+			config: Config{
+				Query:      "service:web",
+				APIKey:     "test-api-key",
+				AppKey:     "test-app-key",
+				PageSize:   1000,
+				Format:     "ndjson",
+				OutputPath: "syslog://siem",
+				Append:     true,
+			},
+			wantErr: true,
+			errMsg:  "syslog output cannot be used with --append or --stitch-by",
+		},
 	}
 
 	for _, tt := range tests {
@@ -217,6 +988,88 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "", want: 0},
+		{input: "1048576", want: 1048576},
+		{input: "512MB", want: 512 * 1000 * 1000},
+		{input: "512mb", want: 512 * 1000 * 1000},
+		{input: "2GiB", want: 2 << 30},
+		{input: "1.5 KiB", want: 1536},
+		{input: "100B", want: 100},
+		{input: "lots", wantErr: true},
+		{input: "-1MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseByteSize(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"x-team: payments", "X-Route:batch:low"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Team": "payments", "X-Route": "batch:low"}, headers)
+
+	headers, err = ParseHeaders(nil)
+	require.NoError(t, err)
+	assert.Nil(t, headers)
+
+	for _, spec := range []string{"X-Team", ":value", "X Team:payments", "DD-API-KEY:abc", "User-Agent:me"} {
+		_, err := ParseHeaders([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParseWebhookHeaders(t *testing.T) {
+	headers, err := ParseWebhookHeaders([]string{"authorization: Bearer abc", "User-Agent: ingest"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer abc", "User-Agent": "ingest"}, headers)
+
+	_, err = ParseWebhookHeaders([]string{"Authorization"})
+	assert.Error(t, err)
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "1h", want: time.Hour},
+		{input: "90m", want: 90 * time.Minute},
+		{input: "28d", want: 28 * 24 * time.Hour},
+		{input: "2w", want: 14 * 24 * time.Hour},
+		{input: "1.5d", wantErr: true},
+		{input: "-1d", wantErr: true},
+		{input: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestDefaultFrom(t *testing.T) {
 	before := time.Now()
 	got := DefaultFrom()
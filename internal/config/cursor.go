@@ -0,0 +1,68 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// CursorDisplays lists how cursors can be shown in progress output and
+// summaries
+var CursorDisplays = []string{"full", "hash", "truncate"}
+
+// truncatedCursorLen is how much of a cursor --cursor-display truncate shows
+const truncatedCursorLen = 8
+
+// DisplayCursor formats a cursor for progress output, reports and other
+// places it may be logged: in full, as a short hash that still tells cursors
+// apart, or truncated
+func (c *Config) DisplayCursor(cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	switch c.CursorDisplay {
+	case "hash":
+		sum := sha256.Sum256([]byte(cursor))
+		return "sha256:" + hex.EncodeToString(sum[:6])
+	case "truncate":
+		if len(cursor) <= truncatedCursorLen {
+			return cursor
+		}
+		return cursor[:truncatedCursorLen] + "..."
+	}
+	return cursor
+}
+
+// ResumeStatePath is where an interrupted fetch saves the state to resume it
+// from: --state-file if set, else a .resume file next to the output, so
+// automation needn't read the cursor off stderr. It is empty when there is no
+// output file to resume into.
+func (c *Config) ResumeStatePath() string {
+	if c.StatePath != "" {
+		return c.StatePath
+	}
+	if !c.FileOutput() || c.Split > 0 || c.Atomic || c.Encrypt != nil || !contains(streamableFormats, c.Format) {
+		return ""
+	}
+	return c.OutputPath + ".resume"
+}
+
+// ResumeHint tells the user how to resume from cursor, without revealing it
+// when the cursor display hides it
+func (c *Config) ResumeHint(cursor string) string {
+	switch {
+	case c.Atomic || c.Encrypt != nil:
+		// Neither output can be continued
+		return fmt.Sprintf("stopped at cursor %s; --atomic and encrypted outputs can't be resumed, so fetch again", c.DisplayCursor(cursor))
+	case c.Windowed() && c.StatePath == "":
+		// The cursor alone can't say which window it belongs to
+		return fmt.Sprintf("stopped at cursor %s; set --state-file to resume fetches split into windows", c.DisplayCursor(cursor))
+	case c.Windowed():
+		return fmt.Sprintf("resume with --resume --state-file %s", c.StatePath)
+	case c.CursorDisplay == "" || c.CursorDisplay == "full":
+		return fmt.Sprintf("resume with --cursor '%s'", cursor)
+	case c.StatePath != "":
+		return fmt.Sprintf("resume with --resume --state-file %s", c.StatePath)
+	}
+	return fmt.Sprintf("stopped at cursor %s; set --state-file to keep the full cursor for resuming", c.DisplayCursor(cursor))
+}
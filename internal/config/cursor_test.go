@@ -0,0 +1,56 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplayCursor(t *testing.T) {
+	cursor := "eyJhZnRlciI6eyJxdWVyeSI6InNlY3JldCJ9fQ=="
+
+	assert.Equal(t, cursor, (&Config{}).DisplayCursor(cursor))
+	assert.Equal(t, cursor, (&Config{CursorDisplay: "full"}).DisplayCursor(cursor))
+	assert.Equal(t, "eyJhZnRl...", (&Config{CursorDisplay: "truncate"}).DisplayCursor(cursor))
+	assert.Equal(t, "short", (&Config{CursorDisplay: "truncate"}).DisplayCursor("short"))
+
+	hashed := (&Config{CursorDisplay: "hash"}).DisplayCursor(cursor)
+	assert.True(t, strings.HasPrefix(hashed, "sha256:"))
+	assert.Len(t, hashed, len("sha256:")+12)
+	assert.NotEqual(t, hashed, (&Config{CursorDisplay: "hash"}).DisplayCursor(cursor+"x"))
+
+	assert.Equal(t, "", (&Config{CursorDisplay: "hash"}).DisplayCursor(""))
+}
+
+func TestResumeHint(t *testing.T) {
+	assert.Equal(t, "resume with --cursor 'abc123456789'", (&Config{}).ResumeHint("abc123456789"))
+	assert.Equal(t, "resume with --resume --state-file state.json",
+		(&Config{CursorDisplay: "hash", StatePath: "state.json"}).ResumeHint("abc123456789"))
+
+	hint := (&Config{CursorDisplay: "truncate"}).ResumeHint("abc123456789")
+	assert.Contains(t, hint, "abc12345...")
+	assert.NotContains(t, hint, "abc123456789")
+	assert.Contains(t, hint, "--state-file")
+
+	// A windowed fetch's cursor can't be resumed on its own
+	assert.Equal(t, "resume with --resume --state-file state.json",
+		(&Config{Window: DefaultWindow, StatePath: "state.json"}).ResumeHint("abc123456789"))
+	assert.Contains(t, (&Config{Window: DefaultWindow}).ResumeHint("abc123456789"), "set --state-file to resume fetches split into windows")
+
+	// Outputs that can't be continued are fetched again
+	assert.Contains(t, (&Config{Atomic: true}).ResumeHint("abc123456789"), "can't be resumed")
+}
+
+func TestResumeStatePath(t *testing.T) {
+	assert.Equal(t, "state.json", (&Config{StatePath: "state.json", Format: "ndjson", OutputPath: "logs.ndjson"}).ResumeStatePath())
+	assert.Equal(t, "logs.ndjson.resume", (&Config{Format: "ndjson", OutputPath: "logs.ndjson"}).ResumeStatePath())
+	assert.Equal(t, "logs.ndjson.gz.resume", (&Config{Format: "ndjson", OutputPath: "logs.ndjson.gz"}).ResumeStatePath())
+
+	// Nothing to resume into
+	assert.Equal(t, "", (&Config{Format: "ndjson"}).ResumeStatePath())
+	assert.Equal(t, "", (&Config{Format: "json", OutputPath: "logs.json"}).ResumeStatePath())
+	assert.Equal(t, "", (&Config{Format: "ndjson", OutputPath: "logs.ndjson", Split: 1000}).ResumeStatePath())
+	assert.Equal(t, "", (&Config{Format: "ndjson", OutputPath: "unix:///tmp/logs.sock"}).ResumeStatePath())
+	assert.Equal(t, "", (&Config{Format: "ndjson", OutputPath: "logs.ndjson", Atomic: true}).ResumeStatePath())
+}
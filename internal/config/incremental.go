@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+// StartIncremental sets up an --incremental run from the state file. saved
+// is nil before the first run
+// An interrupted run is finished first, over its original range. Otherwise
+// the run fetches from the last run's watermark, or --from the first time,
+// up to now, and skips the logs the last run exported at the watermark.
+// It replaces Resume and must run before DefaultFrom is applied.
+func (c *Config) StartIncremental(saved *state.State, now time.Time) error {
+	if c.StatePath == "" {
+		return fmt.Errorf("--incremental requires --state-file")
+	}
+	if !c.To.IsZero() {
+		return fmt.Errorf("--incremental always fetches up to now and cannot be used with --to")
+	}
+	c.Incremental = true
+
+	if saved != nil && !saved.Complete {
+		if err := c.Resume(saved, true); err != nil {
+			return err
+		}
+		c.Watermark = saved.Watermark
+		c.Pending = saved.Pending
+		return nil
+	}
+
+	if saved != nil && saved.Watermark != nil {
+		if !c.From.IsZero() {
+			return fmt.Errorf("--from only sets where the first incremental run starts; %s continues from %s", c.StatePath, formatStateTime(saved.Watermark.Timestamp))
+		}
+		c.From = saved.Watermark.Timestamp
+		c.Watermark = saved.Watermark
+	}
+	c.To = now
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartIncrementalFirstRun(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &Config{Query: "service:web", StatePath: "state.json"}
+
+	require.NoError(t, cfg.StartIncremental(nil, now))
+	assert.True(t, cfg.Incremental)
+	assert.True(t, cfg.From.IsZero(), "the first run starts at --from or its default")
+	assert.Equal(t, now, cfg.To)
+	assert.Nil(t, cfg.Watermark)
+}
+
+func TestStartIncrementalFromWatermark(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	watermark := &state.Watermark{Timestamp: now.Add(-time.Hour), IDs: []string{"a"}}
+	saved := &state.State{Query: "service:web", Complete: true, Watermark: watermark}
+
+	cfg := &Config{Query: "service:web", StatePath: "state.json"}
+	require.NoError(t, cfg.StartIncremental(saved, now))
+	assert.Equal(t, watermark.Timestamp, cfg.From)
+	assert.Equal(t, now, cfg.To)
+	assert.Equal(t, watermark, cfg.Watermark)
+
+	cfg = &Config{Query: "service:web", StatePath: "state.json", From: now.Add(-48 * time.Hour)}
+	assert.ErrorContains(t, cfg.StartIncremental(saved, now), "--from only sets where the first incremental run starts")
+}
+
+func TestStartIncrementalFinishesInterruptedRun(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	saved := unfinishedState()
+	saved.Watermark = &state.Watermark{Timestamp: saved.From, IDs: []string{"a"}}
+	saved.Pending = &state.Watermark{Timestamp: saved.From.Add(time.Hour), IDs: []string{"b"}}
+
+	// Each run may write its own file
+	cfg := &Config{Index: "main", Format: "ndjson", OutputPath: "logs-2.ndjson", StatePath: "state.json"}
+	require.NoError(t, cfg.StartIncremental(saved, now))
+	assert.Equal(t, saved.From, cfg.From)
+	assert.Equal(t, *saved.To, cfg.To, "the interrupted run's range is kept")
+	assert.Equal(t, "abc", cfg.Cursor)
+	assert.Equal(t, saved.Watermark, cfg.Watermark)
+	assert.Equal(t, saved.Pending, cfg.Pending)
+}
+
+func TestStartIncrementalErrors(t *testing.T) {
+	now := time.Now()
+	assert.ErrorContains(t, (&Config{}).StartIncremental(nil, now), "--incremental requires --state-file")
+	assert.ErrorContains(t, (&Config{StatePath: "state.json", To: now}).StartIncremental(nil, now), "cannot be used with --to")
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+// NewState describes this run in the state file format, before any progress
+func (c *Config) NewState() *state.State {
+	s := &state.State{
+		Version: state.Version,
+		Query:   c.Query,
+		Index:   c.Index,
+		From:    c.From,
+		Format:  c.Format,
+		Output:  c.OutputPath,
+	}
+	if !c.To.IsZero() {
+		to := c.To
+		s.To = &to
+	}
+	return s
+}
+
+// Resume reconciles the run with the state file it will write to. saved is
+// nil when the file doesn't exist yet
+// A finished fetch can be started again, but an unfinished one is only
+// continued when asked to (resume, or --cursor with the saved cursor) and the
+// run matches the fetch that wrote it, since resuming a cursor against a
+// different query, range or output silently produces a wrong export. Query
+// and range flags left unset are taken from the state, and resume picks up the
+// saved cursor, and window if any, and appends to the output file.
+// It must run before DefaultFrom is applied.
+func (c *Config) Resume(saved *state.State, resume bool) error {
+	if saved == nil || saved.Complete {
+		if !resume {
+			return nil
+		}
+		if saved == nil {
+			return fmt.Errorf("--resume: %s does not exist, so there is nothing to resume", c.StatePath)
+		}
+		return fmt.Errorf("--resume: the fetch recorded in %s already completed; remove the file to fetch again", c.StatePath)
+	}
+
+	if resume {
+		if c.Cursor != "" {
+			return fmt.Errorf("--resume and --cursor cannot be used together; the cursor comes from %s", c.StatePath)
+		}
+		c.Cursor = saved.Cursor
+		if c.FileOutput() {
+			c.Append = true
+		}
+	} else if c.Cursor == "" {
+		return fmt.Errorf("%s records an unfinished fetch (%d logs in %d pages); pass --resume to continue it, or remove the file to start over", c.StatePath, saved.Logs, saved.Pages)
+	} else if c.Cursor != saved.Cursor {
+		return fmt.Errorf("--cursor does not match the cursor saved in %s; pass --resume to continue from the saved cursor", c.StatePath)
+	}
+
+	c.CursorWindow = saved.Window
+	c.CursorLast = saved.Last
+	c.CursorSize = saved.Size
+
+	if c.Query == "" {
+		c.Query = saved.Query
+	}
+	if c.From.IsZero() {
+		c.From = saved.From
+	}
+	if c.To.IsZero() && saved.To != nil {
+		c.To = *saved.To
+	}
+
+	if diffs := c.stateDiffs(saved); len(diffs) > 0 {
+		return fmt.Errorf("%s was written by a different fetch (%s); match the original flags or use another --state-file", c.StatePath, strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// stateDiffs lists how the run differs from the fetch that saved the state
+func (c *Config) stateDiffs(saved *state.State) []string {
+	var diffs []string
+	if c.Query != saved.Query {
+		diffs = append(diffs, fmt.Sprintf("--query was '%s', now '%s'", saved.Query, c.Query))
+	}
+	if c.Index != saved.Index {
+		diffs = append(diffs, fmt.Sprintf("--index was '%s', now '%s'", saved.Index, c.Index))
+	}
+	if !c.From.Equal(saved.From) {
+		diffs = append(diffs, fmt.Sprintf("--from was %s, now %s", formatStateTime(saved.From), formatStateTime(c.From)))
+	}
+	savedTo := time.Time{}
+	if saved.To != nil {
+		savedTo = *saved.To
+	}
+	if !c.To.Equal(savedTo) {
+		diffs = append(diffs, fmt.Sprintf("--to was %s, now %s", formatStateTime(savedTo), formatStateTime(c.To)))
+	}
+
+	// States migrated from before the format was versioned don't record
+	// where the logs went
+	if saved.Format != "" {
+		if c.Format != saved.Format {
+			diffs = append(diffs, fmt.Sprintf("--format was %s, now %s", saved.Format, c.Format))
+		}
+		// Incremental runs may write each run to its own file
+		if c.OutputPath != saved.Output && !c.Incremental {
+			diffs = append(diffs, fmt.Sprintf("--output was '%s', now '%s'", saved.Output, c.OutputPath))
+		}
+	}
+	return diffs
+}
+
+// formatStateTime shows a range bound, where zero means open-ended
+func formatStateTime(t time.Time) string {
+	if t.IsZero() {
+		return "unset"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/jtzemp/dogfetch/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unfinishedState() *state.State {
+	to := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	return &state.State{
+		Version: state.Version,
+		Query:   "service:web",
+		Index:   "main",
+		From:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:      &to,
+		Format:  "ndjson",
+		Output:  "logs.ndjson",
+		Cursor:  "abc",
+		Last:    &state.Watermark{Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), IDs: []string{"log-2000"}},
+		Logs:    2000,
+		Pages:   2,
+		Size:    512000,
+	}
+}
+
+func TestResumeFromState(t *testing.T) {
+	saved := unfinishedState()
+	cfg := &Config{Index: "main", Format: "ndjson", OutputPath: "logs.ndjson", StatePath: "state.json"}
+
+	require.NoError(t, cfg.Resume(saved, true))
+	assert.Equal(t, "service:web", cfg.Query)
+	assert.Equal(t, saved.From, cfg.From)
+	assert.Equal(t, *saved.To, cfg.To)
+	assert.Equal(t, "abc", cfg.Cursor)
+	assert.Nil(t, cfg.CursorWindow)
+	assert.Equal(t, saved.Last, cfg.CursorLast)
+	assert.Equal(t, int64(512000), cfg.CursorSize)
+	assert.True(t, cfg.Append)
+}
+
+func TestResumeWindowedState(t *testing.T) {
+	saved := unfinishedState()
+	saved.Window = &plan.Window{From: saved.From.Add(6 * time.Hour), To: saved.From.Add(12 * time.Hour)}
+	saved.Cursor = ""
+	cfg := &Config{Index: "main", Format: "ndjson", OutputPath: "logs.ndjson", StatePath: "state.json"}
+
+	require.NoError(t, cfg.Resume(saved, true))
+	assert.Equal(t, saved.Window, cfg.CursorWindow)
+	assert.Equal(t, "", cfg.Cursor)
+}
+
+func TestResumeWithSavedCursor(t *testing.T) {
+	cfg := &Config{Query: "service:web", Index: "main", Format: "ndjson", OutputPath: "logs.ndjson", StatePath: "state.json", Cursor: "abc", Append: true}
+	require.NoError(t, cfg.Resume(unfinishedState(), false))
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), cfg.From)
+}
+
+func TestResumeFreshOrFinished(t *testing.T) {
+	cfg := &Config{StatePath: "state.json"}
+	assert.NoError(t, cfg.Resume(nil, false))
+
+	err := cfg.Resume(nil, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nothing to resume")
+
+	done := unfinishedState()
+	done.Complete = true
+	done.Cursor = ""
+	assert.NoError(t, cfg.Resume(done, false))
+
+	err = cfg.Resume(done, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already completed")
+}
+
+func TestResumeRefusesAmbiguousRuns(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		resume bool
+		want   string
+	}{
+		{
+			name: "unfinished without resume",
+			cfg:  Config{Query: "service:web", Index: "main", Format: "ndjson", OutputPath: "logs.ndjson"},
+			want: "unfinished fetch (2000 logs in 2 pages)",
+		},
+		{
+			name: "different cursor",
+			cfg:  Config{Query: "service:web", Index: "main", Format: "ndjson", OutputPath: "logs.ndjson", Cursor: "xyz"},
+			want: "--cursor does not match",
+		},
+		{
+			name:   "resume and cursor",
+			cfg:    Config{Cursor: "abc"},
+			resume: true,
+			want:   "cannot be used together",
+		},
+		{
+			name:   "different query",
+			cfg:    Config{Query: "service:api", Index: "main", Format: "ndjson", OutputPath: "logs.ndjson"},
+			resume: true,
+			want:   "--query was 'service:web', now 'service:api'",
+		},
+		{
+			name:   "different range",
+			cfg:    Config{Index: "main", Format: "ndjson", OutputPath: "logs.ndjson", From: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+			resume: true,
+			want:   "--from was 2024-01-01T00:00:00Z, now 2024-01-01T12:00:00Z",
+		},
+		{
+			name:   "different output",
+			cfg:    Config{Index: "main", Format: "ndjson", OutputPath: "other.ndjson"},
+			resume: true,
+			want:   "--output was 'logs.ndjson', now 'other.ndjson'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.StatePath = "state.json"
+			err := cfg.Resume(unfinishedState(), tt.resume)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestResumeMigratedStateSkipsOutputCheck(t *testing.T) {
+	saved := unfinishedState()
+	saved.Format = ""
+	saved.Output = ""
+	cfg := &Config{Index: "main", Format: "ndjson", OutputPath: "renamed.ndjson", StatePath: "state.json"}
+	assert.NoError(t, cfg.Resume(saved, true))
+}
+
+func TestNewState(t *testing.T) {
+	cfg := &Config{Query: "service:web", Index: "main", From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Format: "text"}
+	s := cfg.NewState()
+	assert.Equal(t, state.Version, s.Version)
+	assert.Nil(t, s.To)
+	assert.Equal(t, "text", s.Format)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sites are the Datadog sites logs can be fetched from; the first is the
+// default
+var Sites = []string{
+	"datadoghq.com",
+	"us3.datadoghq.com",
+	"us5.datadoghq.com",
+	"datadoghq.eu",
+	"ap1.datadoghq.com",
+	"ap2.datadoghq.com",
+	"ddog-gov.com",
+}
+
+// siteAliases are the short names Datadog's docs use for sites
+var siteAliases = map[string]string{
+	"us1": "datadoghq.com",
+	"us":  "datadoghq.com",
+	"us3": "us3.datadoghq.com",
+	"us5": "us5.datadoghq.com",
+	"eu":  "datadoghq.eu",
+	"eu1": "datadoghq.eu",
+	"ap1": "ap1.datadoghq.com",
+	"ap2": "ap2.datadoghq.com",
+	"gov": "ddog-gov.com",
+}
+
+// ParseSite checks s names a known Datadog site, accepting short names such
+// as eu or us5, and returns the site. An empty s stays empty, for the
+// default site. Mistakes get an error suggesting what was probably meant.
+func ParseSite(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return "", nil
+	}
+	if site, ok := siteAliases[s]; ok {
+		return site, nil
+	}
+	if contains(Sites, s) {
+		return s, nil
+	}
+
+	// A URL or host copied from the browser or the docs
+	host := s
+	if _, rest, ok := strings.Cut(host, "://"); ok {
+		host = rest
+	}
+	host, _, _ = strings.Cut(host, "/")
+	for _, prefix := range []string{"api.", "app.", "http-intake.logs."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+	if host != s {
+		if contains(Sites, host) {
+			return "", fmt.Errorf("%q is a URL or host, not a site; use %s, or --api-url for a full URL", s, host)
+		}
+		return "", fmt.Errorf("%q is a URL or host, not a site; use --api-url for a full URL", s)
+	}
+
+	if guess := closestSite(s); guess != "" {
+		return "", fmt.Errorf("unknown Datadog site %q; did you mean %s?", s, guess)
+	}
+	return "", fmt.Errorf("unknown Datadog site %q; known sites: %s (or use --api-url)", s, strings.Join(Sites, ", "))
+}
+
+// closestSite returns the known site a typo most likely meant, or "" if
+// none is close
+func closestSite(s string) string {
+	best, bestDistance := "", 4
+	for _, site := range Sites {
+		if d := editDistance(s, site); d < bestDistance {
+			best, bestDistance = site, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSite(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "", want: ""},
+		{input: "datadoghq.eu", want: "datadoghq.eu"},
+		{input: " US5.datadoghq.com ", want: "us5.datadoghq.com"},
+		{input: "us3", want: "us3.datadoghq.com"},
+		{input: "eu", want: "datadoghq.eu"},
+		{input: "gov", want: "ddog-gov.com"},
+		{input: "datadoghq.co", wantErr: "did you mean datadoghq.com?"},
+		{input: "datadoqhq.eu", wantErr: "did you mean datadoghq.eu?"},
+		{input: "us4.datadoghq.com", wantErr: "did you mean"},
+		{input: "https://app.datadoghq.eu/logs", wantErr: "use datadoghq.eu, or --api-url"},
+		{input: "api.us5.datadoghq.com", wantErr: "use us5.datadoghq.com"},
+		{input: "https://proxy.internal", wantErr: "not a site; use --api-url"},
+		{input: "example.org", wantErr: "known sites: datadoghq.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSite(tt.input)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultWindow is the window size --window auto uses
+const DefaultWindow = 6 * time.Hour
+
+// skipErrorsWindow is the window size --window auto uses with --skip-errors,
+// so a page that fails loses at most the rest of an hour
+const skipErrorsWindow = time.Hour
+
+// autoWindowSpan is how long a range must be before --window auto splits it,
+// since cursors can expire before a fetch that long finishes
+const autoWindowSpan = 7 * 24 * time.Hour
+
+// Windowed reports whether the range is split into windows rather than
+// fetched under one cursor
+func (c *Config) Windowed() bool {
+	return c.Window > 0 || c.Counted()
+}
+
+// Counted reports whether windows are planned from log counts, which come
+// from the aggregation API
+func (c *Config) Counted() bool {
+	return c.Plan == "balanced" || c.Plan == "indexes"
+}
+
+// SetWindow applies --window: off, a duration, or auto, which splits ranges
+// longer than a week into DefaultWindow windows, leaves windows planned
+// from counts uncapped, doesn't split --demo runs and splits --skip-errors runs into
+// hourly windows
+// It must run after the range is set and any resume applied: auto keeps
+// resuming a windowed fetch in windows, and a bare --cursor in one.
+func (c *Config) SetWindow(setting string, now time.Time) error {
+	switch setting {
+	case "off":
+		c.Window = 0
+		return nil
+	case "", "auto":
+		to := c.To
+		if to.IsZero() {
+			to = now
+		}
+		switch {
+		case c.Demo > 0:
+			c.Window = 0
+		case c.Counted():
+			c.Window = 0
+		case c.CursorWindow != nil:
+			c.Window = DefaultWindow
+		case c.Cursor != "":
+			c.Window = 0
+		case c.SkipErrors:
+			c.Window = skipErrorsWindow
+		case to.Sub(c.From) > autoWindowSpan:
+			c.Window = DefaultWindow
+		default:
+			c.Window = 0
+		}
+		return nil
+	}
+
+	d, err := time.ParseDuration(setting)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("--window must be auto, off or a positive duration such as 6h, got '%s'", setting)
+	}
+	c.Window = d
+	return nil
+}
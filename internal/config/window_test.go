@@ -0,0 +1,111 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetWindow(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		setting string
+		want    time.Duration
+	}{
+		{name: "auto short range", cfg: Config{From: now.Add(-24 * time.Hour)}, setting: "auto", want: 0},
+		{name: "auto long open range", cfg: Config{From: now.Add(-2 * week)}, setting: "auto", want: DefaultWindow},
+		{name: "auto long closed range", cfg: Config{From: now.Add(-3 * week), To: now.Add(-week)}, setting: "", want: DefaultWindow},
+		{name: "auto bare cursor", cfg: Config{From: now.Add(-2 * week), Cursor: "abc"}, setting: "auto", want: 0},
+		{name: "auto skip errors", cfg: Config{From: now.Add(-24 * time.Hour), SkipErrors: true}, setting: "auto", want: time.Hour},
+		{name: "skip errors keeps duration", cfg: Config{From: now.Add(-24 * time.Hour), SkipErrors: true}, setting: "6h", want: 6 * time.Hour},
+		{name: "auto resumed window", cfg: Config{From: now.Add(-time.Hour), Cursor: "abc", CursorWindow: &plan.Window{}}, setting: "auto", want: DefaultWindow},
+		{name: "off", cfg: Config{From: now.Add(-2 * week)}, setting: "off", want: 0},
+		{name: "duration", cfg: Config{From: now.Add(-time.Hour)}, setting: "30m", want: 30 * time.Minute},
+		{name: "auto balanced is uncapped", cfg: Config{From: now.Add(-2 * week), Plan: "balanced"}, setting: "auto", want: 0},
+		{name: "balanced cap", cfg: Config{From: now.Add(-2 * week), Plan: "balanced"}, setting: "12h", want: 12 * time.Hour},
+		{name: "auto indexes is uncapped", cfg: Config{From: now.Add(-2 * week), Plan: "indexes"}, setting: "auto", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			require.NoError(t, cfg.SetWindow(tt.setting, now))
+			assert.Equal(t, tt.want, cfg.Window)
+		})
+	}
+}
+
+func TestSetWindowInvalid(t *testing.T) {
+	for _, setting := range []string{"soon", "0s", "-1h"} {
+		err := (&Config{}).SetWindow(setting, time.Now())
+		require.Error(t, err, setting)
+		assert.Contains(t, err.Error(), "--window must be auto, off or a positive duration")
+	}
+}
+
+func TestValidateWindowedCursor(t *testing.T) {
+	base := Config{Query: "service:web", APIKey: "k", AppKey: "a", PageSize: 1000, Format: "ndjson"}
+
+	cfg := base
+	cfg.Window = DefaultWindow
+	cfg.Cursor = "abc"
+	assert.ErrorContains(t, cfg.Validate(), "--cursor cannot resume a fetch split into windows")
+
+	cfg.CursorWindow = &plan.Window{}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Window = 0
+	assert.ErrorContains(t, cfg.Validate(), "resume it with --window")
+}
+
+func TestValidateSkipErrors(t *testing.T) {
+	cfg := Config{Query: "service:web", APIKey: "k", AppKey: "a", PageSize: 1000, Format: "ndjson", SkipErrors: true}
+	assert.ErrorContains(t, cfg.Validate(), "--skip-errors needs the range split into windows")
+
+	cfg.Window = time.Hour
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Incremental = true
+	assert.ErrorContains(t, cfg.Validate(), "--skip-errors cannot be used with --incremental")
+}
+
+func TestValidatePlan(t *testing.T) {
+	base := Config{Query: "service:web", APIKey: "k", AppKey: "a", PageSize: 1000, Format: "ndjson"}
+
+	cfg := base
+	cfg.Plan = "hourly"
+	assert.ErrorContains(t, cfg.Validate(), "--plan must be one of slices, balanced, indexes")
+
+	cfg.Plan = "balanced"
+	assert.ErrorContains(t, cfg.Validate(), "--window-logs must be positive")
+
+	cfg.WindowLogs = 1000
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Windowed())
+
+	cfg.Cursor = "abc"
+	assert.ErrorContains(t, cfg.Validate(), "--cursor cannot resume a fetch split into windows")
+
+	cfg = base
+	cfg.Plan = "indexes"
+	assert.ErrorContains(t, cfg.Validate(), "--window-logs must be positive")
+	cfg.WindowLogs = 1000
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Windowed())
+	cfg.Demo = 100
+	assert.ErrorContains(t, cfg.Validate(), "--demo cannot be used with --plan indexes")
+}
+
+func TestSetWindowAutoNeverSplitsDemo(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &Config{From: now.Add(-30 * 24 * time.Hour), Demo: 100}
+	require.NoError(t, cfg.SetWindow("auto", now))
+	assert.False(t, cfg.Windowed())
+}
@@ -0,0 +1,278 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UnhealthyAfter is how many runs in a row must fail before the health
+// check reports the daemon as unhealthy
+const UnhealthyAfter = 3
+
+// DefaultStatusEvery is how often a status line is printed while a run is
+// backing off
+const DefaultStatusEvery = 30 * time.Second
+
+// Run performs one scheduled fetch, counting its activity on rec as it
+// goes
+type Run func(ctx context.Context, rec Recorder) (Result, error)
+
+// Result summarizes a finished run
+type Result struct {
+	Logs  int
+	Pages int
+	From  time.Time
+	To    time.Time
+}
+
+// Status is a snapshot of the daemon for status output and health checks
+type Status struct {
+	State               string     `json:"state"` // starting, fetching, backing_off, rate_limited, waiting or stopped
+	Every               string     `json:"every"`
+	Runs                int        `json:"runs"`
+	Failures            int        `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Logs                int        `json:"logs"` // fetched over all runs
+	Pages               int        `json:"pages"`
+	Retries             int        `json:"retries"`
+	RateLimitWaits      int        `json:"rate_limit_waits"`
+	RateLimitWait       float64    `json:"rate_limit_wait_seconds"`
+	BackoffUntil        *time.Time `json:"backoff_until,omitempty"` // when the retry being waited for is sent
+	Bytes               int64      `json:"bytes"`                   // written to the output over all runs
+	LastStart           *time.Time `json:"last_start,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastLogs            int        `json:"last_logs"`
+	Watermark           *time.Time `json:"watermark,omitempty"` // end of the last successful run's range
+	NextRun             *time.Time `json:"next_run,omitempty"`
+}
+
+// BackingOff reports whether the current run is waiting to retry a request
+func (s Status) BackingOff() bool {
+	return s.State == "backing_off" || s.State == "rate_limited"
+}
+
+// Healthy reports whether recent runs are succeeding
+func (s Status) Healthy() bool {
+	return s.ConsecutiveFailures < UnhealthyAfter
+}
+
+// Daemon runs a fetch on a fixed interval until it is stopped
+type Daemon struct {
+	Every       time.Duration
+	StatusEvery time.Duration // between status lines while backing off
+	Run         Run
+	Log         io.Writer
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates a daemon that calls run every interval, logging to log
+func New(every time.Duration, run Run, log io.Writer) *Daemon {
+	return &Daemon{
+		Every:       every,
+		StatusEvery: DefaultStatusEvery,
+		Run:         run,
+		Log:         log,
+		status:      Status{State: "starting", Every: every.String()},
+	}
+}
+
+// Loop runs immediately and then every interval, measured from the start of
+// each run, until ctx is cancelled. A run that overruns the interval is
+// followed straight away by the next. Failed runs are logged and retried on
+// the next tick rather than stopping the loop.
+func (d *Daemon) Loop(ctx context.Context) error {
+	defer d.update(func(s *Status) {
+		s.State = "stopped"
+		s.NextRun = nil
+	})
+
+	for {
+		start := time.Now()
+		d.update(func(s *Status) {
+			s.State = "fetching"
+			s.LastStart = &start
+			s.NextRun = nil
+		})
+
+		result, err := d.run(ctx)
+		if ctx.Err() != nil {
+			fmt.Fprintf(d.Log, "Run interrupted after %d logs; the next start resumes it\n", result.Logs)
+			return nil
+		}
+		next := start.Add(d.Every)
+		d.finish(result, err, next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// run calls Run, printing a status line every StatusEvery while it backs
+// off so a throttled run can be told apart from one finding no new logs
+func (d *Daemon) run(ctx context.Context) (Result, error) {
+	logs := d.Status().Logs
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(d.StatusEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				d.printBackoff(d.Status(), logs)
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	return d.Run(ctx, Recorder{d: d})
+}
+
+// printBackoff prints a status line if s is backing off; logs is the
+// fetched count when the run started
+func (d *Daemon) printBackoff(s Status, logs int) {
+	if !s.BackingOff() {
+		return
+	}
+	reason := "backing off after an error"
+	if s.State == "rate_limited" {
+		reason = "rate limited by the API"
+	}
+	fmt.Fprintf(d.Log, "Run %s; retrying in %s with %d logs fetched so far\n",
+		reason, time.Until(*s.BackoffUntil).Round(time.Second), s.Logs-logs)
+}
+
+// finish records a run's outcome and prints a status line
+func (d *Daemon) finish(result Result, err error, next time.Time) {
+	d.update(func(s *Status) {
+		s.State = "waiting"
+		s.BackoffUntil = nil
+		s.Runs++
+		s.NextRun = &next
+		s.LastLogs = result.Logs
+		if err != nil {
+			s.Failures++
+			s.ConsecutiveFailures++
+			s.LastError = err.Error()
+			return
+		}
+		s.ConsecutiveFailures = 0
+		s.LastError = ""
+		now := time.Now()
+		s.LastSuccess = &now
+		s.Watermark = &result.To
+	})
+
+	wait := time.Until(next).Round(time.Second)
+	if err != nil {
+		fmt.Fprintf(d.Log, "Run failed: %v; retrying in %s\n", err, wait)
+		return
+	}
+	fmt.Fprintf(d.Log, "Run complete: %d logs in %d pages from %s to %s; next run in %s\n",
+		result.Logs, result.Pages, result.From.UTC().Format(time.RFC3339), result.To.UTC().Format(time.RFC3339), wait)
+}
+
+func (d *Daemon) update(fn func(*Status)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(&d.status)
+}
+
+// Recorder counts a run's activity into the daemon's status while it runs,
+// so metrics move during long runs rather than only after them
+type Recorder struct {
+	d *Daemon
+}
+
+// Page counts a page of logs
+func (r Recorder) Page(logs int) {
+	r.d.update(func(s *Status) {
+		s.State = "fetching"
+		s.BackoffUntil = nil
+		s.Pages++
+		s.Logs += logs
+	})
+}
+
+// Retry counts a retried request and, when the API rate limited it, the
+// wait. The run reports as backing off until the wait is over.
+func (r Recorder) Retry(rateLimited bool, wait time.Duration) {
+	until := time.Now().Add(wait)
+	r.d.update(func(s *Status) {
+		s.State = "backing_off"
+		if rateLimited {
+			s.State = "rate_limited"
+		}
+		s.BackoffUntil = &until
+		s.Retries++
+		if rateLimited {
+			s.RateLimitWaits++
+			s.RateLimitWait += wait.Seconds()
+		}
+	})
+}
+
+// Bytes counts bytes written to the output
+func (r Recorder) Bytes(n int64) {
+	r.d.update(func(s *Status) {
+		s.Bytes += n
+	})
+}
+
+// Status returns a snapshot of the daemon's progress. A run whose backoff
+// has run out is fetching again, whether or not its retry has answered yet.
+func (d *Daemon) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.status
+	if s.BackingOff() && !time.Now().Before(*s.BackoffUntil) {
+		s.State = "fetching"
+		s.BackoffUntil = nil
+	}
+	return s
+}
+
+// Handler serves /healthz, which answers 503 once runs keep failing,
+// /status, which reports the full status as JSON, and /metrics, which reports
+// it for Prometheus
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !d.Status().Healthy() {
+			http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		status := d.Status()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Healthy() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, d.Status())
+	})
+	return mux
+}
@@ -0,0 +1,190 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopRunsOnInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var log bytes.Buffer
+	runs := 0
+	d := New(10*time.Millisecond, func(ctx context.Context, rec Recorder) (Result, error) {
+		runs++
+		rec.Page(5)
+		if runs == 3 {
+			cancel()
+		}
+		return Result{Logs: 5, Pages: 1, To: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, nil
+	}, &log)
+
+	require.NoError(t, d.Loop(ctx))
+	assert.Equal(t, 3, runs)
+
+	status := d.Status()
+	assert.Equal(t, "stopped", status.State)
+	assert.Equal(t, 2, status.Runs, "the interrupted run isn't counted")
+	assert.Equal(t, 15, status.Logs, "the interrupted run's pages are counted")
+	assert.Equal(t, 3, status.Pages)
+	assert.Nil(t, status.NextRun)
+	require.NotNil(t, status.Watermark)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *status.Watermark)
+	assert.Contains(t, log.String(), "Run complete: 5 logs in 1 pages")
+	assert.Contains(t, log.String(), "Run interrupted after 5 logs")
+}
+
+func TestLoopKeepsGoingAfterFailures(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var log bytes.Buffer
+	runs := 0
+	d := New(time.Millisecond, func(ctx context.Context, rec Recorder) (Result, error) {
+		runs++
+		if runs > UnhealthyAfter {
+			cancel()
+			return Result{}, nil
+		}
+		return Result{}, errors.New("rate limited")
+	}, &log)
+
+	require.NoError(t, d.Loop(ctx))
+	status := d.Status()
+	assert.Equal(t, UnhealthyAfter, status.Failures)
+	assert.Equal(t, UnhealthyAfter, status.ConsecutiveFailures)
+	assert.Equal(t, "rate limited", status.LastError)
+	assert.False(t, status.Healthy())
+	assert.Contains(t, log.String(), "Run failed: rate limited; retrying in")
+}
+
+func TestHandler(t *testing.T) {
+	d := New(time.Minute, nil, &bytes.Buffer{})
+	d.finish(Result{Logs: 7}, nil, time.Now().Add(time.Minute))
+
+	rec := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var status Status
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, "waiting", status.State)
+	assert.Equal(t, "1m0s", status.Every)
+	assert.Equal(t, 7, status.LastLogs)
+
+	for range UnhealthyAfter {
+		d.finish(Result{}, errors.New("boom"), time.Now())
+	}
+	rec = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestMetrics(t *testing.T) {
+	d := New(time.Minute, nil, &bytes.Buffer{})
+	rec := Recorder{d: d}
+	rec.Page(1000)
+	rec.Page(500)
+	rec.Retry(false, time.Second)
+	rec.Retry(true, 30*time.Second)
+	rec.Bytes(4096)
+	d.finish(Result{Logs: 1500}, nil, time.Now().Add(time.Minute))
+
+	resp := httptest.NewRecorder()
+	d.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+
+	for _, line := range []string{
+		"# TYPE dogfetch_logs_fetched_total counter\ndogfetch_logs_fetched_total 1500\n",
+		"dogfetch_pages_fetched_total 2\n",
+		"dogfetch_retries_total 2\n",
+		"dogfetch_rate_limit_waits_total 1\n",
+		"dogfetch_rate_limit_wait_seconds_total 30\n",
+		"dogfetch_bytes_written_total 4096\n",
+		"dogfetch_runs_total 1\n",
+		"dogfetch_run_failures_total 0\n",
+		"dogfetch_fetching 0\n",
+		"dogfetch_last_run_start_timestamp_seconds 0\n",
+	} {
+		assert.Contains(t, body, line)
+	}
+	assert.Regexp(t, `dogfetch_last_success_timestamp_seconds \d{10}\.\d+\n`, body)
+}
+
+func TestBackoffStatus(t *testing.T) {
+	d := New(time.Minute, nil, &bytes.Buffer{})
+	rec := Recorder{d: d}
+	rec.Page(10)
+	rec.Retry(true, time.Minute)
+
+	status := d.Status()
+	assert.Equal(t, "rate_limited", status.State)
+	require.NotNil(t, status.BackoffUntil)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *status.BackoffUntil, time.Second)
+
+	resp := httptest.NewRecorder()
+	d.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Contains(t, resp.Body.String(), `"state":"rate_limited"`)
+	assert.Contains(t, resp.Body.String(), `"backoff_until":`)
+
+	resp = httptest.NewRecorder()
+	d.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := resp.Body.String()
+	assert.Regexp(t, `\ndogfetch_backoff_seconds 59\.\d+\n`, body)
+	assert.Contains(t, body, "\ndogfetch_rate_limited 1\n")
+	assert.Contains(t, body, "\ndogfetch_fetching 1\n")
+
+	rec.Page(10)
+	status = d.Status()
+	assert.Equal(t, "fetching", status.State, "a page ends the backoff")
+	assert.Nil(t, status.BackoffUntil)
+
+	rec.Retry(false, time.Millisecond)
+	assert.Equal(t, "backing_off", d.Status().State)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, "fetching", d.Status().State, "an expired backoff is waiting on the retry")
+
+	rec.Retry(true, time.Minute)
+	d.finish(Result{}, errors.New("rate limited"), time.Now().Add(time.Minute))
+	status = d.Status()
+	assert.Equal(t, "waiting", status.State)
+	assert.Nil(t, status.BackoffUntil)
+}
+
+func TestLoopPrintsBackoffStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var log bytes.Buffer
+	d := New(time.Minute, func(ctx context.Context, rec Recorder) (Result, error) {
+		rec.Page(25)
+		rec.Retry(true, time.Minute)
+		time.Sleep(50 * time.Millisecond)
+		rec.Page(25)
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		return Result{Logs: 50}, nil
+	}, &log)
+	d.StatusEvery = 5 * time.Millisecond
+
+	require.NoError(t, d.Loop(ctx))
+	lines := strings.Count(log.String(), "Run rate limited by the API; retrying in")
+	assert.Greater(t, lines, 1, "a line per tick while backing off")
+	assert.Contains(t, log.String(), "with 25 logs fetched so far")
+	assert.NotContains(t, log.String(), "with 50 logs fetched so far", "no lines once fetching again")
+}
@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// metric is one sample in the Prometheus text exposition format
+type metric struct {
+	name  string
+	kind  string // counter or gauge
+	help  string
+	value float64
+}
+
+// writeMetrics reports the status in the Prometheus text exposition format
+func writeMetrics(w io.Writer, s Status) error {
+	fetching, rateLimited, backoff := 0.0, 0.0, 0.0
+	if s.State == "fetching" || s.BackingOff() {
+		fetching = 1
+	}
+	if s.State == "rate_limited" {
+		rateLimited = 1
+	}
+	if s.BackoffUntil != nil {
+		backoff = max(time.Until(*s.BackoffUntil).Seconds(), 0)
+	}
+	metrics := []metric{
+		{"dogfetch_logs_fetched_total", "counter", "Logs fetched from the API.", float64(s.Logs)},
+		{"dogfetch_pages_fetched_total", "counter", "Pages fetched from the API.", float64(s.Pages)},
+		{"dogfetch_retries_total", "counter", "API requests retried after an error.", float64(s.Retries)},
+		{"dogfetch_rate_limit_waits_total", "counter", "Retries that waited out an API rate limit.", float64(s.RateLimitWaits)},
+		{"dogfetch_rate_limit_wait_seconds_total", "counter", "Time spent waiting out API rate limits.", s.RateLimitWait},
+		{"dogfetch_bytes_written_total", "counter", "Bytes written to the output file.", float64(s.Bytes)},
+		{"dogfetch_runs_total", "counter", "Runs finished, successfully or not.", float64(s.Runs)},
+		{"dogfetch_run_failures_total", "counter", "Runs that failed.", float64(s.Failures)},
+		{"dogfetch_consecutive_failures", "gauge", "Runs that have failed in a row.", float64(s.ConsecutiveFailures)},
+		{"dogfetch_fetching", "gauge", "Whether a run is in progress.", fetching},
+		{"dogfetch_backoff_seconds", "gauge", "Time left before the run retries a failed request, or 0 when it isn't backing off.", backoff},
+		{"dogfetch_rate_limited", "gauge", "Whether the run is waiting out an API rate limit.", rateLimited},
+		{"dogfetch_last_run_start_timestamp_seconds", "gauge", "When the latest run started, or 0 before the first.", unixSeconds(s.LastStart)},
+		{"dogfetch_last_success_timestamp_seconds", "gauge", "When the latest successful run finished, or 0 before the first.", unixSeconds(s.LastSuccess)},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", m.name, m.help, m.name, m.kind, m.name, strconv.FormatFloat(m.value, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unixSeconds(t *time.Time) float64 {
+	if t == nil {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
@@ -0,0 +1,308 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// patternLength caps how much of a message identifies its pattern
+const patternLength = 120
+
+// variable matches the parts of a message that change from log to log:
+// numbers, and hex IDs such as trace IDs and UUIDs
+var variable = regexp.MustCompile(`\b[0-9a-fA-F]{8,}(-[0-9a-fA-F]+)*\b|[0-9]+`)
+
+// Side is one of the two fetches being compared
+type Side struct {
+	Label string          // the query and range, for the report
+	Total int64           // logs matching
+	Logs  []datadogV2.Log // all of them, or a sample
+}
+
+// Totals are the log counts of both sides
+type Totals struct {
+	Baseline int64    `json:"baseline"`
+	Current  int64    `json:"current"`
+	Delta    int64    `json:"delta"`
+	Change   *float64 `json:"change,omitempty"` // fraction of the baseline, nil if it is 0
+}
+
+// Value is a facet value's count and share of each side's sample
+type Value struct {
+	Value         string  `json:"value"`
+	Baseline      int     `json:"baseline"`
+	Current       int     `json:"current"`
+	BaselineShare float64 `json:"baseline_share"`
+	CurrentShare  float64 `json:"current_share"`
+}
+
+// Facet lists the values of a field whose share moved the most
+type Facet struct {
+	Field  string  `json:"field"`
+	Values []Value `json:"values"`
+}
+
+// Pattern is a message shape found on only one side, with an example
+type Pattern struct {
+	Pattern string `json:"pattern"`
+	Count   int    `json:"count"`
+	Example string `json:"example"`
+}
+
+// Report describes how the current side differs from the baseline
+type Report struct {
+	Baseline        string    `json:"baseline"`
+	Current         string    `json:"current"`
+	BaselineSampled int       `json:"baseline_sampled"`
+	CurrentSampled  int       `json:"current_sampled"`
+	Totals          Totals    `json:"totals"`
+	Facets          []Facet   `json:"facets"`
+	AddedFields     []string  `json:"added_fields"`
+	RemovedFields   []string  `json:"removed_fields"`
+	NewPatterns     []Pattern `json:"new_patterns"`
+	GonePatterns    []Pattern `json:"gone_patterns"`
+}
+
+// Compare reports the count deltas between two sides and how their samples
+// differ: the n facet values whose share moved most, fields only one side
+// has, and the n most common message patterns only one side has
+func Compare(baseline, current Side, facets []string, n int) Report {
+	r := Report{
+		Baseline:        baseline.Label,
+		Current:         current.Label,
+		BaselineSampled: len(baseline.Logs),
+		CurrentSampled:  len(current.Logs),
+		Totals: Totals{
+			Baseline: baseline.Total,
+			Current:  current.Total,
+			Delta:    current.Total - baseline.Total,
+		},
+		Facets:        []Facet{},
+		AddedFields:   []string{},
+		RemovedFields: []string{},
+	}
+	if baseline.Total > 0 {
+		change := float64(r.Totals.Delta) / float64(baseline.Total)
+		r.Totals.Change = &change
+	}
+
+	for _, field := range facets {
+		if f := compareFacet(field, baseline.Logs, current.Logs, n); len(f.Values) > 0 {
+			r.Facets = append(r.Facets, f)
+		}
+	}
+
+	before, after := fields(baseline.Logs), fields(current.Logs)
+	for field := range after {
+		if !before[field] {
+			r.AddedFields = append(r.AddedFields, field)
+		}
+	}
+	for field := range before {
+		if !after[field] {
+			r.RemovedFields = append(r.RemovedFields, field)
+		}
+	}
+	sort.Strings(r.AddedFields)
+	sort.Strings(r.RemovedFields)
+
+	oldPatterns, newPatterns := patterns(baseline.Logs), patterns(current.Logs)
+	r.NewPatterns = onlyIn(newPatterns, oldPatterns, n)
+	r.GonePatterns = onlyIn(oldPatterns, newPatterns, n)
+	return r
+}
+
+// compareFacet counts field's values on both sides, keeping the n whose
+// share moved the most and leaving out those that didn't move
+func compareFacet(field string, baseline, current []datadogV2.Log, n int) Facet {
+	before, after := countValues(field, baseline), countValues(field, current)
+	values := make(map[string]*Value)
+	for v, count := range before {
+		values[v] = &Value{Value: v, Baseline: count}
+	}
+	for v, count := range after {
+		if values[v] == nil {
+			values[v] = &Value{Value: v}
+		}
+		values[v].Current = count
+	}
+
+	facet := Facet{Field: field, Values: []Value{}}
+	for _, v := range values {
+		v.BaselineShare = share(v.Baseline, len(baseline))
+		v.CurrentShare = share(v.Current, len(current))
+		if v.BaselineShare != v.CurrentShare {
+			facet.Values = append(facet.Values, *v)
+		}
+	}
+	sort.Slice(facet.Values, func(i, j int) bool {
+		a, b := facet.Values[i], facet.Values[j]
+		da, db := moved(a), moved(b)
+		if da != db {
+			return da > db
+		}
+		return a.Value < b.Value
+	})
+	if len(facet.Values) > n {
+		facet.Values = facet.Values[:n]
+	}
+	return facet
+}
+
+// moved is how far a value's share moved, rounded to millionths so
+// floating point noise doesn't order values that moved as much
+func moved(v Value) float64 {
+	return math.Round(math.Abs(v.CurrentShare-v.BaselineShare) * 1e6)
+}
+
+func countValues(field string, logs []datadogV2.Log) map[string]int {
+	counts := make(map[string]int)
+	for _, log := range logs {
+		if v, ok := logfield.LookupString(log, field); ok {
+			counts[v]++
+		}
+	}
+	return counts
+}
+
+func share(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+// fields collects the attribute paths present in any of logs
+func fields(logs []datadogV2.Log) map[string]bool {
+	seen := make(map[string]bool)
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		collectFields(seen, "", attrs.GetAttributes())
+	}
+	return seen
+}
+
+func collectFields(seen map[string]bool, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		path := prefix + k
+		if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+			collectFields(seen, path+".", nested)
+			continue
+		}
+		seen[path] = true
+	}
+}
+
+// patterns counts the logs of each message pattern, keeping an example
+func patterns(logs []datadogV2.Log) map[string]*Pattern {
+	found := make(map[string]*Pattern)
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		message := attrs.GetMessage()
+		if message == "" {
+			continue
+		}
+		key := Shape(message)
+		if found[key] == nil {
+			found[key] = &Pattern{Pattern: key, Example: message}
+		}
+		found[key].Count++
+	}
+	return found
+}
+
+// onlyIn returns the n most common patterns of a that b lacks
+func onlyIn(a, b map[string]*Pattern, n int) []Pattern {
+	only := []Pattern{}
+	for key, p := range a {
+		if b[key] == nil {
+			only = append(only, *p)
+		}
+	}
+	sort.Slice(only, func(i, j int) bool {
+		if only[i].Count != only[j].Count {
+			return only[i].Count > only[j].Count
+		}
+		return only[i].Pattern < only[j].Pattern
+	})
+	if len(only) > n {
+		only = only[:n]
+	}
+	return only
+}
+
+// Shape reduces a message to its pattern, replacing numbers and IDs with #
+// so logs from the same line of code match
+func Shape(message string) string {
+	shape := variable.ReplaceAllString(message, "#")
+	shape = strings.Join(strings.Fields(shape), " ")
+	if len(shape) > patternLength {
+		shape = shape[:patternLength]
+	}
+	return shape
+}
+
+// WriteText writes the report for reading in a terminal
+func (r Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "baseline: %s\n", r.Baseline)
+	fmt.Fprintf(w, "current:  %s\n\n", r.Current)
+
+	fmt.Fprintf(w, "Logs: %d -> %d (%+d", r.Totals.Baseline, r.Totals.Current, r.Totals.Delta)
+	if r.Totals.Change != nil {
+		fmt.Fprintf(w, ", %+.1f%%", *r.Totals.Change*100)
+	}
+	fmt.Fprintf(w, ")\n")
+	if int64(r.BaselineSampled) < r.Totals.Baseline || int64(r.CurrentSampled) < r.Totals.Current {
+		fmt.Fprintf(w, "Differences below are from samples of %d and %d logs\n", r.BaselineSampled, r.CurrentSampled)
+	}
+
+	for _, f := range r.Facets {
+		fmt.Fprintf(w, "\n%s:\n", f.Field)
+		for _, v := range f.Values {
+			fmt.Fprintf(w, "  %-40s %5.1f%% -> %5.1f%%  (%+.1f pts)\n", v.Value, v.BaselineShare*100, v.CurrentShare*100, (v.CurrentShare-v.BaselineShare)*100)
+		}
+	}
+
+	writeList(w, "New fields", r.AddedFields)
+	writeList(w, "Removed fields", r.RemovedFields)
+	writePatterns(w, "New messages", r.NewPatterns)
+	writePatterns(w, "Gone messages", r.GonePatterns)
+}
+
+func writeList(w io.Writer, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, item := range items {
+		fmt.Fprintf(w, "  %s\n", item)
+	}
+}
+
+func writePatterns(w io.Writer, title string, patterns []Pattern) {
+	if len(patterns) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, p := range patterns {
+		fmt.Fprintf(w, "  %6d  %s\n", p.Count, p.Pattern)
+		if p.Example != p.Pattern {
+			fmt.Fprintf(w, "          e.g. %s\n", p.Example)
+		}
+	}
+}
+
+// WriteJSON writes the report as a JSON document
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLog(status, message string, attrs map[string]interface{}) datadogV2.Log {
+	id := "id"
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Status:     &status,
+			Message:    &message,
+			Timestamp:  &ts,
+			Attributes: attrs,
+		},
+	}
+}
+
+func TestShape(t *testing.T) {
+	assert.Equal(t, "GET /users/# took #ms", Shape("GET /users/42 took 125ms"))
+	assert.Equal(t, "trace # failed for #", Shape("trace 4bf92f3577b34da6a3ce929d0e0e4736 failed for 3f1c0a9b-2e7d-4c55-8a0e-6b1f2d3c4e5f"))
+	assert.Equal(t, "connection accepted", Shape("connection   accepted"))
+}
+
+func TestCompare(t *testing.T) {
+	baseline := Side{Label: "yesterday", Total: 4, Logs: []datadogV2.Log{
+		testLog("info", "request 1 served", map[string]interface{}{"http": map[string]interface{}{"status_code": "200"}}),
+		testLog("info", "request 2 served", map[string]interface{}{"http": map[string]interface{}{"status_code": "200"}}),
+		testLog("info", "cache warmed", map[string]interface{}{"legacy_id": "x"}),
+		testLog("error", "request 3 failed", nil),
+	}}
+	current := Side{Label: "today", Total: 6, Logs: []datadogV2.Log{
+		testLog("info", "request 4 served", map[string]interface{}{"http": map[string]interface{}{"status_code": "200"}}),
+		testLog("error", "request 5 failed", nil),
+		testLog("error", "request 6 failed", nil),
+		testLog("error", "db timeout after 30s", map[string]interface{}{"db": map[string]interface{}{"host": "db-1"}}),
+		testLog("error", "db timeout after 31s", nil),
+		testLog("info", "request 7 served", nil),
+	}}
+
+	r := Compare(baseline, current, []string{"status", "service"}, 5)
+	assert.Equal(t, int64(2), r.Totals.Delta)
+	require.NotNil(t, r.Totals.Change)
+	assert.InDelta(t, 0.5, *r.Totals.Change, 1e-9)
+
+	// Services are missing from every log, so only status is compared
+	require.Len(t, r.Facets, 1)
+	assert.Equal(t, "status", r.Facets[0].Field)
+	assert.Equal(t, []Value{
+		{Value: "error", Baseline: 1, Current: 4, BaselineShare: 0.25, CurrentShare: 4.0 / 6},
+		{Value: "info", Baseline: 3, Current: 2, BaselineShare: 0.75, CurrentShare: 2.0 / 6},
+	}, r.Facets[0].Values)
+
+	assert.Equal(t, []string{"db.host"}, r.AddedFields)
+	assert.Equal(t, []string{"legacy_id"}, r.RemovedFields)
+	assert.Equal(t, []Pattern{{Pattern: "db timeout after #s", Count: 2, Example: "db timeout after 30s"}}, r.NewPatterns)
+	assert.Equal(t, []Pattern{{Pattern: "cache warmed", Count: 1, Example: "cache warmed"}}, r.GonePatterns)
+}
+
+func TestCompareEmptyBaseline(t *testing.T) {
+	r := Compare(Side{}, Side{Total: 1, Logs: []datadogV2.Log{testLog("info", "hello", nil)}}, []string{"status"}, 5)
+	assert.Nil(t, r.Totals.Change)
+
+	var out bytes.Buffer
+	r.WriteText(&out)
+	assert.Contains(t, out.String(), "Logs: 0 -> 1 (+1)\n")
+}
+
+func TestWriteText(t *testing.T) {
+	r := Report{
+		Baseline: "service:web yesterday", Current: "service:web today",
+		BaselineSampled: 100, CurrentSampled: 100,
+		Totals:       Totals{Baseline: 1000, Current: 1500, Delta: 500, Change: func() *float64 { c := 0.5; return &c }()},
+		Facets:       []Facet{{Field: "status", Values: []Value{{Value: "error", BaselineShare: 0.1, CurrentShare: 0.3}}}},
+		AddedFields:  []string{"db.host"},
+		NewPatterns:  []Pattern{{Pattern: "db timeout after #s", Count: 12, Example: "db timeout after 30s"}},
+		GonePatterns: []Pattern{{Pattern: "cache warmed", Count: 3, Example: "cache warmed"}},
+	}
+
+	var out bytes.Buffer
+	r.WriteText(&out)
+	assert.Equal(t, `baseline: service:web yesterday
+current:  service:web today
+
+Logs: 1000 -> 1500 (+500, +50.0%)
+Differences below are from samples of 100 and 100 logs
+
+status:
+  error                                     10.0% ->  30.0%  (+20.0 pts)
+
+New fields:
+  db.host
+
+New messages:
+      12  db timeout after #s
+          e.g. db timeout after 30s
+
+Gone messages:
+       3  cache warmed
+`, out.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := Compare(Side{Label: "a", Total: 1}, Side{Label: "b", Total: 1}, nil, 5)
+	var out bytes.Buffer
+	require.NoError(t, r.WriteJSON(&out))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, "a", decoded["baseline"])
+	assert.Equal(t, []interface{}{}, decoded["added_fields"])
+	assert.Equal(t, map[string]interface{}{"baseline": 1.0, "current": 1.0, "delta": 0.0, "change": 0.0}, decoded["totals"])
+}
@@ -0,0 +1,344 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/auth"
+	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/redact"
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+// Status is how a check went
+type Status int
+
+const (
+	OK   Status = iota
+	Warn        // works, but likely to cause trouble
+	Fail        // dogfetch won't work until it is fixed
+)
+
+// Sites are the Datadog sites DD_SITE can name
+var Sites = config.Sites
+
+// MaxSkew is how far the local clock can drift from Datadog's before it is
+// flagged
+const MaxSkew = time.Minute
+
+// Result is the outcome of one check
+type Result struct {
+	Name   string
+	Status Status
+	Detail string // what was found, or what went wrong
+	Hint   string // how to fix a problem
+}
+
+// Report collects the results of the checks run
+type Report struct {
+	Results []Result
+}
+
+// Add appends results to the report
+func (r *Report) Add(results ...Result) {
+	r.Results = append(r.Results, results...)
+}
+
+// Failed reports whether any check failed
+func (r Report) Failed() bool {
+	return r.count(Fail) > 0
+}
+
+// Write lists the results, with hints under problems, and a closing tally
+func (r Report) Write(w io.Writer) {
+	for _, res := range r.Results {
+		mark := "ok"
+		switch res.Status {
+		case Warn:
+			mark = "WARN"
+		case Fail:
+			mark = "FAILED"
+		}
+		fmt.Fprintf(w, "  %-16s %-6s %s\n", res.Name, mark, res.Detail)
+		if res.Hint != "" {
+			fmt.Fprintf(w, "  %-16s %-6s %s\n", "", "", res.Hint)
+		}
+	}
+
+	failed, warned := r.count(Fail), r.count(Warn)
+	switch {
+	case failed == 0 && warned == 0:
+		fmt.Fprintf(w, "\nEverything looks good\n")
+	case failed == 0:
+		fmt.Fprintf(w, "\n%d warning(s); dogfetch should work, but see the hints above\n", warned)
+	default:
+		fmt.Fprintf(w, "\n%d problem(s) and %d warning(s); fix the problems above before fetching\n", failed, warned)
+	}
+}
+
+func (r Report) count(s Status) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Env checks the environment variables dogfetch reads its keys and site from
+func Env(getenv func(string) string) []Result {
+	apiKey, appKey := getenv("DD_API_KEY"), getenv("DD_APP_KEY")
+	results := []Result{
+		key("DD_API_KEY", apiKey, 32),
+		key("DD_APP_KEY", appKey, 40),
+	}
+	if len(apiKey) == 40 && len(appKey) == 32 && isHex(apiKey) && isHex(appKey) {
+		results = append(results, Result{Name: "Keys", Status: Fail,
+			Detail: "DD_API_KEY looks like an application key and DD_APP_KEY like an API key",
+			Hint:   "Swap the values of DD_API_KEY and DD_APP_KEY"})
+	}
+	return append(results, site(getenv("DD_SITE")))
+}
+
+// key checks a key is set and shaped like a Datadog key of length n
+func key(name, value string, n int) Result {
+	r := Result{Name: name}
+	switch {
+	case value == "":
+		r.Status, r.Detail = Fail, "not set"
+		r.Hint = fmt.Sprintf("export %s=... with a key from Organization Settings in Datadog", name)
+	case strings.TrimSpace(value) != value || strings.Trim(value, `"'`) != value:
+		r.Status, r.Detail = Fail, "has surrounding whitespace or quotes"
+		r.Hint = fmt.Sprintf("Set %s to the key alone; a trailing newline often comes from a file or a secret store", name)
+	case len(value) != n || !isHex(value):
+		r.Status, r.Detail = Warn, fmt.Sprintf("set (%s), but %d characters; Datadog's are %d hex characters", mask(value), len(value), n)
+		r.Hint = fmt.Sprintf("Check %s holds the whole key, not its name or ID", name)
+	default:
+		r.Detail = fmt.Sprintf("set (%s)", mask(value))
+	}
+	return r
+}
+
+// site checks DD_SITE names a Datadog site rather than a URL
+func site(value string) Result {
+	r := Result{Name: "DD_SITE"}
+	switch {
+	case value == "":
+		r.Detail = "not set, using " + Sites[0]
+	case strings.Contains(value, "://") || strings.HasPrefix(value, "api.") || strings.ContainsAny(value, "/ "):
+		r.Status, r.Detail = Fail, fmt.Sprintf("%q is a URL or host, not a site", value)
+		r.Hint = "Set DD_SITE to the site alone, e.g. datadoghq.eu; use --api-url for a full URL"
+	case !known(value):
+		r.Status, r.Detail = Warn, fmt.Sprintf("%s is not a known Datadog site", value)
+		r.Hint = "Known sites: " + strings.Join(Sites, ", ")
+	default:
+		r.Detail = value
+	}
+	return r
+}
+
+// Files checks the syntax of the state file and redaction rules a fetch
+// would load, skipping those not given
+func Files(statePath, rulesPath string) []Result {
+	var results []Result
+	if statePath != "" {
+		r := Result{Name: "State file"}
+		if s, err := state.Read(statePath); errors.Is(err, os.ErrNotExist) {
+			r.Detail = statePath + " doesn't exist yet; the first fetch creates it"
+		} else if err != nil {
+			r.Status, r.Detail = Fail, err.Error()
+			r.Hint = "Fix or remove the file; removing it starts the next fetch over"
+		} else {
+			r.Detail = fmt.Sprintf("%s is valid (format version %d)", statePath, s.Version)
+		}
+		results = append(results, r)
+	}
+	if rulesPath != "" {
+		r := Result{Name: "Redaction rules"}
+		if rules, err := redact.LoadRules(rulesPath); err != nil {
+			r.Status, r.Detail = Fail, err.Error()
+			r.Hint = "Rules are a YAML list under rules:, each with field, pattern and replace"
+		} else {
+			r.Detail = fmt.Sprintf("%s is valid (%d rules)", rulesPath, len(rules))
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// Proxy reports the proxy, if any, requests to server go through
+func Proxy(server string, proxy func(*http.Request) (*url.URL, error)) Result {
+	r := Result{Name: "Proxy"}
+	req, err := http.NewRequest(http.MethodGet, server, nil)
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+	u, err := proxy(req)
+	switch {
+	case err != nil:
+		r.Status, r.Detail = Fail, err.Error()
+		r.Hint = "Check HTTPS_PROXY and HTTP_PROXY are URLs such as http://proxy:3128"
+	case u == nil:
+		r.Detail = "none"
+	default:
+		r.Detail = "via " + u.Redacted()
+	}
+	return r
+}
+
+// Connect requests server without keys, reporting whether it answers, the
+// TLS connection and how far the local clock is from the server's
+func Connect(ctx context.Context, client *http.Client, server string, now func() time.Time) []Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(server, "/")+"/api/v1/validate", nil)
+	if err != nil {
+		return []Result{{Name: "Connectivity", Status: Fail, Detail: err.Error()}}
+	}
+	host := req.URL.Host
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return []Result{connectError(host, err)}
+	}
+	defer resp.Body.Close()
+	elapsed := time.Since(start)
+
+	results := []Result{{Name: "Connectivity", Detail: fmt.Sprintf("reached %s (status %d in %s)", host, resp.StatusCode, elapsed.Round(time.Millisecond))}}
+	if resp.TLS != nil {
+		results = append(results, tlsResult(resp.TLS))
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		results = append(results, clock(now().Sub(date)))
+	}
+	return results
+}
+
+// connectError explains why a request couldn't reach host
+func connectError(host string, err error) Result {
+	r := Result{Name: "Connectivity", Status: Fail, Detail: err.Error()}
+
+	var dnsErr *net.DNSError
+	var unknownCA x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var urlErr *url.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &unknownCA):
+		r.Name, r.Detail = "TLS", "certificate not trusted: "+unknownCA.Error()
+		r.Hint = "A TLS-inspecting proxy's CA certificate must be in the system trust store, or in SSL_CERT_FILE"
+	case errors.As(err, &hostErr):
+		r.Name, r.Detail = "TLS", hostErr.Error()
+		r.Hint = "Something between here and Datadog is answering for " + host + "; check the proxy and DNS"
+	case errors.As(err, &invalid):
+		r.Name, r.Detail = "TLS", invalid.Error()
+		r.Hint = "Check the system clock, and the proxy's certificate if there is one"
+	case errors.As(err, &dnsErr):
+		r.Detail = "can't resolve " + dnsErr.Name
+		r.Hint = "Check DD_SITE and DNS; networks without direct access need HTTPS_PROXY"
+	case errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		r.Detail = "can't reach the proxy: " + opErr.Err.Error()
+		r.Hint = "Check HTTPS_PROXY"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		r.Detail = host + " refused the connection"
+		r.Hint = "Check --api-url, or the firewall"
+	case errors.As(err, &urlErr) && urlErr.Timeout():
+		r.Detail = "timed out connecting to " + host
+		r.Hint = "A firewall may be dropping traffic to " + host + "; networks without direct access need HTTPS_PROXY"
+	}
+	return r
+}
+
+func tlsResult(state *tls.ConnectionState) Result {
+	r := Result{Name: "TLS", Detail: tls.VersionName(state.Version)}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		r.Detail += ", certificate issued by " + cert.Issuer.CommonName
+		if left := time.Until(cert.NotAfter); left < 14*24*time.Hour {
+			r.Status = Warn
+			r.Detail += fmt.Sprintf(", expiring %s", cert.NotAfter.UTC().Format(time.DateOnly))
+		}
+	}
+	if state.Version < tls.VersionTLS12 {
+		r.Status = Warn
+		r.Hint = "Datadog requires TLS 1.2 or later; an intercepting proxy may be downgrading the connection"
+	}
+	return r
+}
+
+// clock reports the local clock's skew from a server's
+func clock(skew time.Duration) Result {
+	r := Result{Name: "Clock"}
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	// Date headers have whole seconds
+	abs = abs.Round(time.Second)
+	switch {
+	case abs <= time.Second:
+		r.Detail = "in sync with Datadog"
+	case abs <= MaxSkew:
+		r.Detail = fmt.Sprintf("within %s of Datadog", abs)
+	default:
+		way := "ahead of"
+		if skew < 0 {
+			way = "behind"
+		}
+		r.Status, r.Detail = Warn, fmt.Sprintf("%s %s Datadog", abs, way)
+		r.Hint = "Sync the system clock (e.g. enable NTP); default and incremental time ranges come from it"
+	}
+	return r
+}
+
+// FromAuth turns the results of an auth check into doctor results
+func FromAuth(report auth.Report) []Result {
+	results := make([]Result, 0, len(report.Results))
+	for _, res := range report.Results {
+		r := Result{Name: res.Name, Detail: res.Detail, Hint: res.Hint}
+		if !res.OK {
+			r.Status = Fail
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func known(site string) bool {
+	for _, s := range Sites {
+		if s == site {
+			return true
+		}
+	}
+	return false
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// mask shows enough of a key to tell which it is
+func mask(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return "..." + key[len(key)-4:]
+}
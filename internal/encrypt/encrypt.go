@@ -0,0 +1,163 @@
+package encrypt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ParseRecipients parses age recipients given as "age1..." public keys or as
+// paths to files containing one recipient per line
+func ParseRecipients(specs []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, spec := range specs {
+		if strings.HasPrefix(spec, "age1") {
+			r, err := age.ParseX25519Recipient(spec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipient %q: %w", spec, err)
+			}
+			recipients = append(recipients, r)
+			continue
+		}
+
+		f, err := os.Open(spec)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q is neither an age public key nor a readable file: %w", spec, err)
+		}
+		parsed, err := age.ParseRecipients(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipients file %s: %w", spec, err)
+		}
+		recipients = append(recipients, parsed...)
+	}
+	return recipients, nil
+}
+
+// gpgPrefix marks a recipient as a key in the local GPG keyring rather than
+// an age recipient
+const gpgPrefix = "gpg:"
+
+// Encrypter encrypts streams as they are written, to age recipients or to
+// GPG keys, but not a mix of both: a file has one format to decrypt
+type Encrypter struct {
+	age []age.Recipient
+	gpg []string // key IDs, fingerprints or emails
+}
+
+// NewEncrypter parses recipients given as age public keys, paths to age
+// recipients files, or gpg:<key> for a key in the local GPG keyring, which
+// the gpg command encrypts to
+func NewEncrypter(specs []string) (*Encrypter, error) {
+	var e Encrypter
+	var ageSpecs []string
+	for _, spec := range specs {
+		if key, ok := strings.CutPrefix(spec, gpgPrefix); ok {
+			if key == "" {
+				return nil, fmt.Errorf("invalid recipient %q: expected gpg:<key id or email>", spec)
+			}
+			e.gpg = append(e.gpg, key)
+			continue
+		}
+		ageSpecs = append(ageSpecs, spec)
+	}
+	if len(e.gpg) > 0 && len(ageSpecs) > 0 {
+		return nil, fmt.Errorf("age and gpg: recipients cannot be mixed")
+	}
+	if len(e.gpg) > 0 {
+		if _, err := exec.LookPath("gpg"); err != nil {
+			return nil, fmt.Errorf("gpg: recipients need the gpg command: %w", err)
+		}
+		// Found now rather than when the finished file fails to encrypt
+		for _, key := range e.gpg {
+			if exec.Command("gpg", "--batch", "--list-keys", key).Run() != nil {
+				return nil, fmt.Errorf("no public key for %q in the GPG keyring", key)
+			}
+		}
+		return &e, nil
+	}
+
+	recipients, err := ParseRecipients(ageSpecs)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients given")
+	}
+	e.age = recipients
+	return &e, nil
+}
+
+// Encrypt returns a writer encrypting what is written to it into w. Closing
+// it finishes the encrypted stream, without which it can't be decrypted, but
+// doesn't close w.
+func (e *Encrypter) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	if len(e.gpg) > 0 {
+		return newGPGWriter(w, e.gpg)
+	}
+	return age.Encrypt(w, e.age...)
+}
+
+// Extension returns the extension of the files e writes: .gpg for GPG
+// keys, .age otherwise
+func (e *Encrypter) Extension() string {
+	if len(e.gpg) > 0 {
+		return ".gpg"
+	}
+	return ".age"
+}
+
+// gpgWriter encrypts through a gpg process
+type gpgWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+}
+
+func newGPGWriter(w io.Writer, keys []string) (*gpgWriter, error) {
+	// Naming a key is trusting it; batch mode would otherwise refuse keys
+	// nobody has signed
+	args := []string{"--batch", "--yes", "--quiet", "--trust-model", "always", "--encrypt", "--output", "-"}
+	for _, key := range keys {
+		args = append(args, "--recipient", key)
+	}
+	g := &gpgWriter{cmd: exec.Command("gpg", args...)}
+	g.cmd.Stdout = w
+	g.cmd.Stderr = &g.stderr
+	stdin, err := g.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	g.stdin = stdin
+	if err := g.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start gpg: %w", err)
+	}
+	return g, nil
+}
+
+func (g *gpgWriter) Write(p []byte) (int, error) {
+	n, err := g.stdin.Write(p)
+	if err != nil {
+		// gpg has exited; Close says why
+		return n, fmt.Errorf("gpg stopped reading: %w", err)
+	}
+	return n, nil
+}
+
+// Close ends gpg's input and waits for it to write the rest of the file
+func (g *gpgWriter) Close() error {
+	g.stdin.Close()
+	if err := g.cmd.Wait(); err != nil {
+		// What gpg said, e.g. that a key is unknown, explains it best
+		if msg := strings.TrimSpace(g.stderr.String()); msg != "" {
+			return fmt.Errorf("gpg: %s", msg)
+		}
+		return fmt.Errorf("gpg: %w", err)
+	}
+	return nil
+}
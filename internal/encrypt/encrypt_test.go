@@ -0,0 +1,105 @@
+package encrypt
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncrypterExtension(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	e, err := NewEncrypter([]string{identity.Recipient().String()})
+	require.NoError(t, err)
+	assert.Equal(t, ".age", e.Extension())
+	assert.Equal(t, ".gpg", (&Encrypter{gpg: []string{"security@example.com"}}).Extension())
+}
+
+func TestParseRecipients(t *testing.T) {
+	dir := t.TempDir()
+	a, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	b, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	file := filepath.Join(dir, "recipients.txt")
+	require.NoError(t, os.WriteFile(file, []byte("# team\n"+b.Recipient().String()+"\n"), 0644))
+
+	recipients, err := ParseRecipients([]string{a.Recipient().String(), file})
+	require.NoError(t, err)
+	assert.Len(t, recipients, 2)
+
+	_, err = ParseRecipients([]string{"age1notakey"})
+	assert.Error(t, err)
+
+	_, err = ParseRecipients([]string{filepath.Join(dir, "missing.txt")})
+	assert.Error(t, err)
+}
+
+func TestEncrypterAge(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	e, err := NewEncrypter([]string{identity.Recipient().String()})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	w, err := e.Encrypt(&out)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "{\"id\":\"1\"}\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := age.Decrypt(&out, identity)
+	require.NoError(t, err)
+	plaintext, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"1\"}\n", string(plaintext))
+}
+
+func TestNewEncrypterErrors(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	_, err = NewEncrypter([]string{identity.Recipient().String(), "gpg:ops@example.com"})
+	assert.ErrorContains(t, err, "cannot be mixed")
+	_, err = NewEncrypter([]string{"gpg:"})
+	assert.Error(t, err)
+	_, err = NewEncrypter(nil)
+	assert.Error(t, err)
+}
+
+func TestEncrypterGPG(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	t.Setenv("GNUPGHOME", t.TempDir())
+	gen := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "dogfetch-test@example.com", "default", "default", "never")
+	if out, err := gen.CombinedOutput(); err != nil {
+		t.Skipf("can't generate a gpg key: %v: %s", err, out)
+	}
+
+	e, err := NewEncrypter([]string{"gpg:dogfetch-test@example.com"})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	w, err := e.Encrypt(&out)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "{\"id\":\"1\"}\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	dec := exec.Command("gpg", "--batch", "--quiet", "--decrypt")
+	dec.Stdin = &out
+	plaintext, err := dec.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"1\"}\n", string(plaintext))
+
+	_, err = NewEncrypter([]string{"gpg:nobody@example.com"})
+	assert.ErrorContains(t, err, "no public key")
+}
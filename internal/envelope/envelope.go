@@ -0,0 +1,80 @@
+package envelope
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Versions are the envelope versions --envelope accepts
+var Versions = []string{"v1"}
+
+// Envelope is the v1 schema: a log's identity and time, where it came from,
+// and its content
+// Fields are only ever added to a version; renaming or removing one needs a
+// new version, so parsers can rely on the schema name.
+type Envelope struct {
+	Schema string    `json:"schema"` // "dogfetch/v1"
+	ID     string    `json:"id"`
+	TS     time.Time `json:"ts"`
+	Source Source    `json:"source"`
+	Record Record    `json:"record"`
+}
+
+// Source describes where a record was exported from
+type Source struct {
+	System string `json:"system"` // always "datadog"
+	Site   string `json:"site,omitempty"`
+	Index  string `json:"index,omitempty"` // as queried; may list several
+}
+
+// Record is the log's content, read field by field from the API response
+// rather than serialized from the API client's types
+type Record struct {
+	Status     string                 `json:"status,omitempty"`
+	Service    string                 `json:"service,omitempty"`
+	Host       string                 `json:"host,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Tags       []string               `json:"tags,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Wrapper wraps logs in a versioned envelope
+type Wrapper struct {
+	schema string
+	source Source
+}
+
+// New creates a wrapper for an envelope version
+func New(version string, source Source) (*Wrapper, error) {
+	switch version {
+	case "v1":
+	default:
+		return nil, fmt.Errorf("unknown envelope version '%s' (supported: %s)", version, strings.Join(Versions, ", "))
+	}
+	if source.System == "" {
+		source.System = "datadog"
+	}
+	return &Wrapper{schema: "dogfetch/" + version, source: source}, nil
+}
+
+// Wrap puts a log in an envelope
+func (w *Wrapper) Wrap(log datadogV2.Log) Envelope {
+	attrs := log.GetAttributes()
+	return Envelope{
+		Schema: w.schema,
+		ID:     log.GetId(),
+		TS:     attrs.GetTimestamp().UTC(),
+		Source: w.source,
+		Record: Record{
+			Status:     attrs.GetStatus(),
+			Service:    attrs.GetService(),
+			Host:       attrs.GetHost(),
+			Message:    attrs.GetMessage(),
+			Tags:       attrs.GetTags(),
+			Attributes: attrs.GetAttributes(),
+		},
+	}
+}
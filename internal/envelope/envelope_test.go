@@ -0,0 +1,61 @@
+package envelope
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapV1(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600))
+	id, status, service, host, message := "AQAAAY1", "error", "web", "i-01", "boom"
+	log := datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Timestamp:  &ts,
+			Status:     &status,
+			Service:    &service,
+			Host:       &host,
+			Message:    &message,
+			Tags:       []string{"env:prod"},
+			Attributes: map[string]interface{}{"http": map[string]interface{}{"status_code": 500}},
+		},
+	}
+
+	w, err := New("v1", Source{Index: "main"})
+	require.NoError(t, err)
+	data, err := json.Marshal(w.Wrap(log))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"schema": "dogfetch/v1",
+		"id": "AQAAAY1",
+		"ts": "2024-01-01T17:00:00Z",
+		"source": {"system": "datadog", "index": "main"},
+		"record": {
+			"status": "error",
+			"service": "web",
+			"host": "i-01",
+			"message": "boom",
+			"tags": ["env:prod"],
+			"attributes": {"http": {"status_code": 500}}
+		}
+	}`, string(data))
+}
+
+func TestWrapSparseLog(t *testing.T) {
+	w, err := New("v1", Source{})
+	require.NoError(t, err)
+	data, err := json.Marshal(w.Wrap(datadogV2.Log{}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"schema": "dogfetch/v1", "id": "", "ts": "0001-01-01T00:00:00Z", "source": {"system": "datadog"}, "record": {}}`, string(data))
+}
+
+func TestNewRejectsUnknownVersion(t *testing.T) {
+	_, err := New("v2", Source{})
+	assert.ErrorContains(t, err, "unknown envelope version 'v2' (supported: v1)")
+}
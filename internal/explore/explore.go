@@ -0,0 +1,181 @@
+package explore
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/query"
+	"github.com/jtzemp/dogfetch/internal/topn"
+)
+
+// DefaultFacets are summarized when no facets are given
+var DefaultFacets = []string{"status", "service", "host", "env", "http.status_code", "error.kind"}
+
+// Facet is a field's most frequent values in a sample
+type Facet struct {
+	Field  string
+	Values []topn.Entry
+}
+
+// Summary describes a sample of logs
+type Summary struct {
+	Logs   int
+	Facets []Facet
+}
+
+// Summarize counts the top values of each facet over a sample
+func Summarize(logs []datadogV2.Log, facets []string, n int) Summary {
+	summary := Summary{Logs: len(logs)}
+	for _, name := range facets {
+		field := topn.NewField(name, n)
+		field.Observe(logs)
+		summary.Facets = append(summary.Facets, Facet{Field: name, Values: field.Top()})
+	}
+	return summary
+}
+
+// Choice returns the field and value numbered n in the summary's listing
+func (s Summary) Choice(n int) (field, value string, ok bool) {
+	for _, f := range s.Facets {
+		if n <= len(f.Values) {
+			if n < 1 {
+				return "", "", false
+			}
+			return f.Field, f.Values[n-1].Value, true
+		}
+		n -= len(f.Values)
+	}
+	return "", "", false
+}
+
+// Write lists the facets, numbering every value so it can be picked
+func (s Summary) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n%d logs sampled\n", s.Logs)
+	n := 0
+	for _, f := range s.Facets {
+		if len(f.Values) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", f.Field)
+		for _, v := range f.Values {
+			n++
+			pct := 0.0
+			if s.Logs > 0 {
+				pct = 100 * float64(v.Count) / float64(s.Logs)
+			}
+			fmt.Fprintf(w, "  %-5s %-40s %6d  %5.1f%%\n", fmt.Sprintf("[%d]", n), v.Value, v.Count, pct)
+		}
+	}
+	if n == 0 {
+		fmt.Fprintf(w, "\n(no facet values; try another query or add a facet)\n")
+	}
+}
+
+// Term builds a query term matching, or with exclude excluding, a field's
+// value
+func Term(field, value string, exclude bool) string {
+	field = strings.TrimPrefix(strings.TrimPrefix(field, "@"), "attributes.")
+	if !query.Reserved(field) {
+		field = "@" + field
+	}
+	if strings.ContainsAny(value, " \t\"():*?\\") {
+		value = strconv.Quote(value)
+	}
+	term := field + ":" + value
+	if exclude {
+		term = "-" + term
+	}
+	return term
+}
+
+// Action is what the loop does after a line of input
+type Action int
+
+const (
+	Resample Action = iota // fetch a new sample with the refined query
+	Stay                   // show the message and prompt again
+	Fetch                  // hand the query to a full fetch
+	Quit                   // stop, printing the query
+)
+
+// Session is a query being refined one step at a time
+type Session struct {
+	Base   string   // the query exploring started from
+	Terms  []string // terms added since, ANDed onto Base
+	Facets []string
+}
+
+// Query returns the refined query
+func (s *Session) Query() string {
+	parts := make([]string, 0, len(s.Terms)+1)
+	if s.Base != "" && s.Base != "*" {
+		parts = append(parts, s.Base)
+	}
+	parts = append(parts, s.Terms...)
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Help lists the commands Apply understands
+const Help = `Commands:
+  N             keep only logs with value N
+  -N            exclude logs with value N
+  <term>        add a query term, e.g. @http.url:*checkout* or -env:staging
+  undo          remove the last term
+  reset         remove every added term
+  facet <field> also summarize field, e.g. facet usr.id
+  fetch         run the full fetch with the refined query
+  quit          print the refined query and stop
+  (empty line)  take a new sample`
+
+// Apply runs one line of input against the session; summary is what the
+// user picked numbered values from
+func (s *Session) Apply(line string, summary Summary) (Action, string) {
+	line = strings.TrimSpace(line)
+	command, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch command {
+	case "":
+		return Resample, ""
+	case "help", "?":
+		return Stay, Help
+	case "quit", "q", "exit":
+		return Quit, ""
+	case "fetch":
+		return Fetch, ""
+	case "undo":
+		if len(s.Terms) == 0 {
+			return Stay, "nothing to undo"
+		}
+		s.Terms = s.Terms[:len(s.Terms)-1]
+		return Resample, ""
+	case "reset":
+		s.Terms = nil
+		return Resample, ""
+	case "facet":
+		if arg == "" {
+			return Stay, "usage: facet <field>"
+		}
+		s.Facets = append(s.Facets, arg)
+		return Resample, ""
+	}
+
+	exclude := strings.HasPrefix(line, "-")
+	if n, err := strconv.Atoi(strings.TrimPrefix(line, "-")); err == nil {
+		field, value, ok := summary.Choice(n)
+		if !ok {
+			return Stay, fmt.Sprintf("no value numbered %d", n)
+		}
+		s.Terms = append(s.Terms, Term(field, value, exclude))
+		return Resample, ""
+	}
+
+	s.Terms = append(s.Terms, line)
+	return Resample, ""
+}
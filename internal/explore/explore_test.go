@@ -0,0 +1,111 @@
+package explore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleLog(service, status string, attrs map[string]interface{}) datadogV2.Log {
+	a := datadogV2.NewLogAttributes()
+	a.SetService(service)
+	a.SetStatus(status)
+	a.SetAttributes(attrs)
+	log := datadogV2.NewLog()
+	log.SetAttributes(*a)
+	return *log
+}
+
+func testSummary() Summary {
+	logs := []datadogV2.Log{
+		sampleLog("web", "error", map[string]interface{}{"error": map[string]interface{}{"kind": "Timeout"}}),
+		sampleLog("web", "info", nil),
+		sampleLog("api", "error", map[string]interface{}{"error": map[string]interface{}{"kind": "Connection reset"}}),
+	}
+	return Summarize(logs, []string{"service", "error.kind"}, 5)
+}
+
+func TestSummarizeNumbersValuesAcrossFacets(t *testing.T) {
+	s := testSummary()
+	assert.Equal(t, 3, s.Logs)
+
+	field, value, ok := s.Choice(1)
+	require.True(t, ok)
+	assert.Equal(t, "service", field)
+	assert.Equal(t, "web", value)
+
+	field, value, ok = s.Choice(3)
+	require.True(t, ok)
+	assert.Equal(t, "error.kind", field)
+
+	_, _, ok = s.Choice(0)
+	assert.False(t, ok)
+	_, _, ok = s.Choice(5)
+	assert.False(t, ok)
+
+	var out bytes.Buffer
+	s.Write(&out)
+	assert.Contains(t, out.String(), "[1]")
+	assert.Contains(t, out.String(), "error.kind:")
+	assert.Contains(t, out.String(), "66.7%")
+}
+
+func TestTerm(t *testing.T) {
+	assert.Equal(t, "service:web", Term("service", "web", false))
+	assert.Equal(t, "-status:error", Term("status", "error", true))
+	assert.Equal(t, "@http.status_code:500", Term("http.status_code", "500", false))
+	assert.Equal(t, "@usr.id:42", Term("@usr.id", "42", false))
+	assert.Equal(t, "@error.kind:42", Term("attributes.error.kind", "42", false))
+	assert.Equal(t, `-@error.kind:"Connection reset"`, Term("error.kind", "Connection reset", true))
+}
+
+func TestSessionQuery(t *testing.T) {
+	assert.Equal(t, "*", (&Session{}).Query())
+	assert.Equal(t, "*", (&Session{Base: "*"}).Query())
+	assert.Equal(t, "env:prod", (&Session{Base: "*", Terms: []string{"env:prod"}}).Query())
+	assert.Equal(t, "status:error env:prod", (&Session{Base: "status:error", Terms: []string{"env:prod"}}).Query())
+}
+
+func TestSessionApply(t *testing.T) {
+	summary := testSummary()
+	s := &Session{Base: "status:error"}
+
+	action, _ := s.Apply("1", summary)
+	assert.Equal(t, Resample, action)
+	action, _ = s.Apply(" -3 ", summary)
+	assert.Equal(t, Resample, action)
+	action, _ = s.Apply("-@http.url:*health*", summary)
+	assert.Equal(t, Resample, action)
+	assert.Equal(t, `status:error service:web -@error.kind:"Connection reset" -@http.url:*health*`, s.Query())
+
+	action, message := s.Apply("9", summary)
+	assert.Equal(t, Stay, action)
+	assert.Contains(t, message, "9")
+
+	s.Apply("undo", summary)
+	assert.Equal(t, `status:error service:web -@error.kind:"Connection reset"`, s.Query())
+	s.Apply("reset", summary)
+	assert.Equal(t, "status:error", s.Query())
+	action, message = s.Apply("undo", summary)
+	assert.Equal(t, Stay, action)
+	assert.Equal(t, "nothing to undo", message)
+
+	s.Apply("facet usr.id", summary)
+	assert.Equal(t, []string{"usr.id"}, s.Facets)
+	action, _ = s.Apply("facet", summary)
+	assert.Equal(t, Stay, action)
+
+	action, message = s.Apply("help", summary)
+	assert.Equal(t, Stay, action)
+	assert.Equal(t, Help, message)
+
+	action, _ = s.Apply("", summary)
+	assert.Equal(t, Resample, action)
+	action, _ = s.Apply("fetch", summary)
+	assert.Equal(t, Fetch, action)
+	action, _ = s.Apply("quit", summary)
+	assert.Equal(t, Quit, action)
+}
@@ -0,0 +1,235 @@
+package facets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/query"
+)
+
+// Where a field comes from, which decides how a query addresses it
+const (
+	Reserved  = "reserved"  // service, host, status and source, queried without an @
+	Tag       = "tag"       // a tag key, queried without an @
+	Attribute = "attribute" // a custom attribute, queried with an @
+)
+
+// exampleLength caps how much of an example value is kept
+const exampleLength = 40
+
+// Facet is a field found in a sample of logs
+type Facet struct {
+	Path     string   `json:"path"`
+	Query    string   `json:"query"` // the field as a query term, e.g. @http.status_code:
+	Source   string   `json:"source"`
+	Types    []string `json:"types"`
+	Logs     int      `json:"logs"` // sampled logs carrying the field
+	Examples []string `json:"examples"`
+}
+
+// field accumulates what the sample says about one field
+type field struct {
+	source   string
+	types    map[string]bool
+	logs     int
+	examples []string
+}
+
+// Collector gathers the fields a sample of logs carry
+type Collector struct {
+	examples int
+	logs     int
+	fields   map[string]*field
+}
+
+// NewCollector creates a collector keeping up to examples distinct example
+// values per field
+func NewCollector(examples int) *Collector {
+	return &Collector{examples: examples, fields: map[string]*field{}}
+}
+
+// Observe records the fields of a page of logs
+func (c *Collector) Observe(logs []datadogV2.Log) {
+	for _, log := range logs {
+		c.logs++
+		seen := map[string]bool{}
+		attrs := log.GetAttributes()
+
+		for _, r := range []struct{ name, value string }{
+			{"service", attrs.GetService()},
+			{"host", attrs.GetHost()},
+			{"status", attrs.GetStatus()},
+		} {
+			if r.value != "" {
+				c.add(seen, r.name, Reserved, r.value)
+			}
+		}
+		// Tags of reserved attributes, like source, are counted as them
+		for _, tag := range attrs.GetTags() {
+			key, value, ok := strings.Cut(tag, ":")
+			if !ok || key == "" {
+				continue
+			}
+			if query.Reserved(key) {
+				c.add(seen, key, Reserved, value)
+			} else {
+				c.add(seen, key, Tag, value)
+			}
+		}
+		c.walk(seen, "", attrs.GetAttributes())
+	}
+}
+
+// walk records the leaves of a nested attribute object under dotted paths
+func (c *Collector) walk(seen map[string]bool, prefix string, attrs map[string]interface{}) {
+	for key, value := range attrs {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			c.walk(seen, path, nested)
+			continue
+		}
+		c.add(seen, path, Attribute, value)
+	}
+}
+
+// add counts a field once per log, noting its type and an example value
+func (c *Collector) add(seen map[string]bool, path, source string, value interface{}) {
+	key := source + ":" + path
+	f := c.fields[key]
+	if f == nil {
+		f = &field{source: source, types: map[string]bool{}}
+		c.fields[key] = f
+	}
+	f.types[typeOf(value)] = true
+	if !seen[key] {
+		seen[key] = true
+		f.logs++
+	}
+
+	if value == nil || len(f.examples) >= c.examples {
+		return
+	}
+	example := exampleOf(value)
+	for _, e := range f.examples {
+		if e == example {
+			return
+		}
+	}
+	f.examples = append(f.examples, example)
+}
+
+// Logs returns how many logs were sampled
+func (c *Collector) Logs() int {
+	return c.logs
+}
+
+// Facets lists the fields found: reserved attributes, then tags, then custom
+// attributes, the most common first within each
+func (c *Collector) Facets() []Facet {
+	out := make([]Facet, 0, len(c.fields))
+	for key, f := range c.fields {
+		path := strings.TrimPrefix(key, f.source+":")
+		facet := Facet{Path: path, Source: f.source, Logs: f.logs, Examples: f.examples}
+		facet.Query = path + ":"
+		if f.source == Attribute {
+			facet.Query = "@" + facet.Query
+		}
+		for t := range f.types {
+			facet.Types = append(facet.Types, t)
+		}
+		sort.Strings(facet.Types)
+		out = append(out, facet)
+	}
+
+	rank := map[string]int{Reserved: 0, Tag: 1, Attribute: 2}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Source != out[j].Source {
+			return rank[out[i].Source] < rank[out[j].Source]
+		}
+		if out[i].Logs != out[j].Logs {
+			return out[i].Logs > out[j].Logs
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// WriteText lists the facets as a table, with the share of the sample that
+// carries each
+func WriteText(w io.Writer, sampled int, list []Facet) error {
+	fmt.Fprintf(w, "%d logs sampled\n\n", sampled)
+	if len(list) == 0 {
+		_, err := fmt.Fprintf(w, "No fields found; try another query or time range\n")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "QUERY AS\tTYPE\tLOGS\tEXAMPLES\n")
+	for _, f := range list {
+		share := 0
+		if sampled > 0 {
+			share = f.Logs * 100 / sampled
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d%%\t%s\n", f.Query, strings.Join(f.Types, "|"), share, strings.Join(f.Examples, ", "))
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the facets as a JSON document
+func WriteJSON(w io.Writer, sampled int, list []Facet) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Sampled int     `json:"sampled"`
+		Facets  []Facet `json:"facets"`
+	}{sampled, list})
+}
+
+// typeOf names the JSON type of an attribute value
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, float32, int, int64, json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// exampleOf formats a value for the examples column, cut short if long
+func exampleOf(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		data, _ := json.Marshal(v)
+		s = string(data)
+	default:
+		s = fmt.Sprint(v)
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > exampleLength {
+		s = string([]rune(s)[:exampleLength-1]) + "…"
+	}
+	return s
+}
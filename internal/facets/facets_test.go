@@ -0,0 +1,100 @@
+package facets
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleLog(service string, tags []string, attrs map[string]interface{}) datadogV2.Log {
+	a := datadogV2.NewLogAttributes()
+	a.SetService(service)
+	a.SetTags(tags)
+	a.SetAttributes(attrs)
+	log := datadogV2.NewLog()
+	log.SetAttributes(*a)
+	return *log
+}
+
+func testCollector() *Collector {
+	c := NewCollector(2)
+	c.Observe([]datadogV2.Log{
+		sampleLog("web", []string{"env:prod", "service:web"}, map[string]interface{}{
+			"http":     map[string]interface{}{"status_code": float64(500), "method": "GET"},
+			"duration": float64(30107934),
+		}),
+		sampleLog("api", []string{"env:staging"}, map[string]interface{}{
+			"http":  map[string]interface{}{"status_code": "n/a"},
+			"roles": []interface{}{"admin", "dev"},
+		}),
+		sampleLog("web", nil, map[string]interface{}{"http": map[string]interface{}{"status_code": float64(200)}}),
+	})
+	return c
+}
+
+func TestFacets(t *testing.T) {
+	c := testCollector()
+	assert.Equal(t, 3, c.Logs())
+
+	assert.Equal(t, []Facet{
+		{Path: "service", Query: "service:", Source: Reserved, Types: []string{"string"}, Logs: 3, Examples: []string{"web", "api"}},
+		{Path: "env", Query: "env:", Source: Tag, Types: []string{"string"}, Logs: 2, Examples: []string{"prod", "staging"}},
+		{Path: "http.status_code", Query: "@http.status_code:", Source: Attribute, Types: []string{"number", "string"}, Logs: 3, Examples: []string{"500", "n/a"}},
+		{Path: "duration", Query: "@duration:", Source: Attribute, Types: []string{"number"}, Logs: 1, Examples: []string{"30107934"}},
+		{Path: "http.method", Query: "@http.method:", Source: Attribute, Types: []string{"string"}, Logs: 1, Examples: []string{"GET"}},
+		{Path: "roles", Query: "@roles:", Source: Attribute, Types: []string{"array"}, Logs: 1, Examples: []string{`["admin","dev"]`}},
+	}, c.Facets())
+}
+
+func TestFacetsReservedTags(t *testing.T) {
+	c := NewCollector(2)
+	c.Observe([]datadogV2.Log{sampleLog("web", []string{"source:nginx", "service:web"}, nil)})
+
+	// source is queried without an @, like the reserved attributes it is
+	assert.Equal(t, []Facet{
+		{Path: "service", Query: "service:", Source: Reserved, Types: []string{"string"}, Logs: 1, Examples: []string{"web"}},
+		{Path: "source", Query: "source:", Source: Reserved, Types: []string{"string"}, Logs: 1, Examples: []string{"nginx"}},
+	}, c.Facets())
+}
+
+func TestWriteText(t *testing.T) {
+	c := testCollector()
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, c.Logs(), c.Facets()[:3]))
+	assert.Equal(t, "3 logs sampled\n\n"+
+		"QUERY AS            TYPE           LOGS  EXAMPLES\n"+
+		"service:            string         100%  web, api\n"+
+		"env:                string         66%   prod, staging\n"+
+		"@http.status_code:  number|string  100%  500, n/a\n", buf.String())
+}
+
+func TestWriteTextEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, 0, nil))
+	assert.Equal(t, "0 logs sampled\n\nNo fields found; try another query or time range\n", buf.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	c := testCollector()
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, c.Logs(), c.Facets()))
+
+	var got struct {
+		Sampled int     `json:"sampled"`
+		Facets  []Facet `json:"facets"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, 3, got.Sampled)
+	assert.Equal(t, c.Facets(), got.Facets)
+}
+
+func TestExampleOf(t *testing.T) {
+	assert.Equal(t, "a b", exampleOf("a\n  b"))
+	assert.Equal(t, "true", exampleOf(true))
+	long := exampleOf("0123456789012345678901234567890123456789-and-more")
+	assert.Equal(t, "012345678901234567890123456789012345678…", long)
+}
@@ -0,0 +1,63 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// appendCheckSize is how much of the end of a file checkAppend reads to find
+// its last record
+const appendCheckSize = 1 << 20
+
+// checkAppend makes the NDJSON file at path safe to append to. A run killed
+// mid-write leaves a partial record without its newline, and the first
+// record appended would be glued to it, so a file whose last record isn't a
+// JSON object is refused unless force. A missing final newline is added,
+// which with force keeps the damage to the partial record's own line.
+// It reports whether it added the newline.
+func checkAppend(path string, force bool) (bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 {
+		return false, nil
+	}
+
+	start := max(info.Size()-appendCheckSize, 0)
+	tail := make([]byte, info.Size()-start)
+	if _, err := f.ReadAt(tail, start); err != nil && err != io.EOF {
+		return false, err
+	}
+	terminated := tail[len(tail)-1] == '\n'
+	last := bytes.TrimRight(tail, "\r\n")
+	i := bytes.LastIndexByte(last, '\n')
+	// A record longer than the tail read can't be checked
+	if i >= 0 || start == 0 {
+		last = bytes.TrimSpace(last[i+1:])
+		if (len(last) == 0 || last[0] != '{' || !json.Valid(last)) && !force {
+			return false, fmt.Errorf("%s doesn't end in a complete NDJSON record, as a run killed mid-write leaves it; fix the file or append anyway with --force", path)
+		}
+	}
+
+	if terminated {
+		return false, nil
+	}
+	if _, err := f.Write([]byte("\n")); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAppend(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	added, err := checkAppend(filepath.Join(dir, "missing.ndjson"), false)
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	path := write("complete.ndjson", "{\"id\":\"1\"}\n{\"id\":\"2\"}\n")
+	added, err = checkAppend(path, false)
+	require.NoError(t, err)
+	assert.False(t, added)
+	assert.Equal(t, "{\"id\":\"1\"}\n{\"id\":\"2\"}\n", read(path))
+
+	path = write("unterminated.ndjson", "{\"id\":\"1\"}\n{\"id\":\"2\"}")
+	added, err = checkAppend(path, false)
+	require.NoError(t, err)
+	assert.True(t, added)
+	assert.Equal(t, "{\"id\":\"1\"}\n{\"id\":\"2\"}\n", read(path))
+
+	// Half a record is refused, or fenced off with force
+	path = write("killed.ndjson", "{\"id\":\"1\"}\n{\"id\":\"2\",\"attri")
+	_, err = checkAppend(path, false)
+	assert.ErrorContains(t, err, "--force")
+	assert.Equal(t, "{\"id\":\"1\"}\n{\"id\":\"2\",\"attri", read(path))
+	added, err = checkAppend(path, true)
+	require.NoError(t, err)
+	assert.True(t, added)
+	assert.Equal(t, "{\"id\":\"1\"}\n{\"id\":\"2\",\"attri\n", read(path))
+
+	// Not NDJSON at all
+	path = write("array.json", "[\n  {\"id\":\"1\"}\n]\n")
+	_, err = checkAppend(path, false)
+	assert.Error(t, err)
+
+	// A last record too long to read whole isn't judged
+	path = write("long.ndjson", "{\"message\":\""+strings.Repeat("x", appendCheckSize)+"\"}\n")
+	_, err = checkAppend(path, false)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,96 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// KeyOwner is the user, or service account, an application key belongs to
+type KeyOwner struct {
+	Name           string
+	Handle         string
+	ServiceAccount bool
+}
+
+// Org is the organization the keys belong to
+type Org struct {
+	Name     string
+	PublicID string
+}
+
+// ValidateAPIKey checks the API key alone with the validation endpoint
+func (c *Client) ValidateAPIKey(ctx context.Context) error {
+	resp, httpResp, err := c.validate.Validate(c.GetContext(ctx))
+	if err != nil {
+		return &RequestError{Err: err, StatusCode: statusCode(httpResp), Attempts: 1}
+	}
+	if !resp.GetValid() {
+		return &RequestError{Err: fmt.Errorf("the API key is not valid"), StatusCode: http.StatusForbidden, Attempts: 1}
+	}
+	return nil
+}
+
+// KeyOwner looks up who owns the application key, which any valid key may
+// read; a refusal means the application key is not valid
+func (c *Client) KeyOwner(ctx context.Context) (KeyOwner, error) {
+	size, include := int64(1), "owned_by"
+	resp, httpResp, err := c.keys.ListCurrentUserApplicationKeys(c.GetContext(ctx), datadogV2.ListCurrentUserApplicationKeysOptionalParameters{
+		PageSize: &size,
+		Include:  &include,
+	})
+	if err != nil {
+		return KeyOwner{}, &RequestError{Err: err, StatusCode: statusCode(httpResp), Attempts: 1}
+	}
+
+	for _, item := range resp.Included {
+		if item.User == nil {
+			continue
+		}
+		attrs := item.User.GetAttributes()
+		return KeyOwner{
+			Name:           attrs.GetName(),
+			Handle:         attrs.GetHandle(),
+			ServiceAccount: attrs.GetServiceAccount(),
+		}, nil
+	}
+	return KeyOwner{}, nil
+}
+
+// Org returns the organization the keys belong to. Reading it takes more
+// than reading logs, so a refusal here says little about the keys.
+func (c *Client) Org(ctx context.Context) (Org, error) {
+	resp, httpResp, err := c.orgs.ListOrgs(c.GetContext(ctx))
+	if err != nil {
+		return Org{}, &RequestError{Err: err, StatusCode: statusCode(httpResp), Attempts: 1}
+	}
+	if len(resp.Orgs) == 0 {
+		return Org{}, fmt.Errorf("no organization in the response")
+	}
+	return Org{Name: resp.Orgs[0].GetName(), PublicID: resp.Orgs[0].GetPublicId()}, nil
+}
+
+// ProbeLogs searches index for a single recent log, which needs the
+// logs_read_data permission and, if the index is restricted, access to it
+func (c *Client) ProbeLogs(ctx context.Context, index string) error {
+	to := time.Now().UTC()
+	from := to.Add(-15 * time.Minute)
+	limit := int32(1)
+	opts := datadogV2.ListLogsGetOptionalParameters{
+		FilterFrom: &from,
+		FilterTo:   &to,
+		PageLimit:  &limit,
+	}
+	if index != "" {
+		opts.FilterIndexes = &[]string{index}
+	}
+
+	_, httpResp, err := c.api.ListLogsGet(c.GetContext(ctx), opts)
+	if err != nil {
+		return &RequestError{Err: err, StatusCode: statusCode(httpResp), Attempts: 1}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func authServer(t *testing.T, logsStatus int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/validate":
+			w.Write([]byte(`{"valid":true}`))
+		case "/api/v2/current_user/application_keys":
+			assert.Equal(t, "owned_by", r.URL.Query().Get("include"))
+			w.Write([]byte(`{"data":[{"id":"k","type":"application_keys"}],"included":[{"id":"u","type":"users","attributes":{"name":"Jane Doe","handle":"jane@example.com","service_account":false}}]}`))
+		case "/api/v1/org":
+			w.Write([]byte(`{"orgs":[{"name":"Acme","public_id":"abc123"}]}`))
+		case "/api/v2/logs/events":
+			assert.Equal(t, "audit", r.URL.Query().Get("filter[indexes]"))
+			w.WriteHeader(logsStatus)
+			w.Write([]byte(`{"data":[]}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientAuthChecks(t *testing.T) {
+	client := NewClient("key", "app", "", WithBaseURL(authServer(t, http.StatusOK).URL))
+	ctx := context.Background()
+
+	require.NoError(t, client.ValidateAPIKey(ctx))
+
+	owner, err := client.KeyOwner(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, KeyOwner{Name: "Jane Doe", Handle: "jane@example.com"}, owner)
+
+	org, err := client.Org(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Org{Name: "Acme", PublicID: "abc123"}, org)
+
+	assert.NoError(t, client.ProbeLogs(ctx, "audit"))
+}
+
+func TestClientProbeLogsRefused(t *testing.T) {
+	client := NewClient("key", "app", "", WithBaseURL(authServer(t, http.StatusForbidden).URL))
+
+	err := client.ProbeLogs(context.Background(), "audit")
+	var reqErr *RequestError
+	require.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, http.StatusForbidden, reqErr.StatusCode)
+}
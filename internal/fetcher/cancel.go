@@ -0,0 +1,36 @@
+package fetcher
+
+import (
+	"os"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// CancelFile is an observer that calls Cancel once a file exists, so an
+// operator or another job can stop a fetch by creating it. It is checked
+// after every page, and the fetcher then stops as it does on an interrupt
+type CancelFile struct {
+	Path   string
+	Cancel func()
+
+	cancelled bool
+}
+
+// NewCancelFile creates an observer watching for path
+func NewCancelFile(path string, cancel func()) *CancelFile {
+	return &CancelFile{Path: path, Cancel: cancel}
+}
+
+// Exists reports whether the cancel file is present
+func (c *CancelFile) Exists() bool {
+	_, err := os.Stat(c.Path)
+	return err == nil
+}
+
+// Observe checks for the cancel file
+func (c *CancelFile) Observe([]datadogV2.Log) {
+	if !c.cancelled && c.Exists() {
+		c.cancelled = true
+		c.Cancel()
+	}
+}
@@ -0,0 +1,56 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+func TestCancelFileStopsBetweenPages(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one")},
+		[]datadogV2.Log{createMockLog("log-2", "two")},
+		[]datadogV2.Log{createMockLog("log-3", "three")},
+	)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.StatePath = filepath.Join(dir, "state.json")
+	stop := filepath.Join(dir, "stop")
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	cancelFile := NewCancelFile(stop, func() {
+		calls++
+		cancel()
+	})
+	assert.False(t, cancelFile.Exists())
+
+	// The operator creates the file while the first page is written
+	f.AddObserver(observerFunc(func([]datadogV2.Log) {
+		require.NoError(t, os.WriteFile(stop, nil, 0644))
+	}))
+	f.AddObserver(cancelFile)
+	require.NoError(t, f.Fetch(ctx))
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 1, f.Stats().Logs)
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.False(t, s.Complete)
+	assert.Equal(t, "page-1", s.Cursor)
+}
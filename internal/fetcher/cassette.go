@@ -0,0 +1,197 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Cassette is a recording of API interactions that can be replayed offline
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request/response pair
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest identifies a request (credentials are never recorded)
+type RecordedRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+}
+
+// RecordedResponse holds everything needed to replay a response
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// LoadCassette reads a cassette from disk
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to disk atomically
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cassette-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RecordingTransport captures every API response into a cassette file
+// The cassette is rewritten after each interaction so an interrupted run
+// still leaves a usable recording behind.
+type RecordingTransport struct {
+	next     http.RoundTripper
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecordingTransport creates a transport that records to path
+// If next is nil, http.DefaultTransport is used.
+func NewRecordingTransport(path string, next http.RoundTripper) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RecordingTransport{next: next, path: path}
+}
+
+// RoundTrip performs the request and records the response
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Pages are requested gzipped; the cassette keeps them readable
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cassette.Interactions = append(t.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  req.URL.RawQuery,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       string(body),
+		},
+	})
+
+	if err := t.cassette.Save(t.path); err != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return resp, nil
+}
+
+// ReplayTransport serves responses from a cassette without touching the network
+// Interactions are replayed in recorded order, matched on method and path.
+// Query strings are ignored since default time ranges change between runs.
+type ReplayTransport struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayTransport creates a transport that replays a cassette
+func NewReplayTransport(c *Cassette) *ReplayTransport {
+	return &ReplayTransport{
+		cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}
+}
+
+// RoundTrip returns the next matching recorded response
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, interaction := range t.cassette.Interactions {
+		if t.used[i] {
+			continue
+		}
+		if interaction.Request.Method != req.Method || interaction.Request.Path != req.URL.Path {
+			continue
+		}
+
+		t.used[i] = true
+		header := interaction.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewBufferString(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("cassette has no recorded response left for %s %s", req.Method, req.URL.Path)
+}
@@ -0,0 +1,94 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	cassettePath := filepath.Join(dir, "cassette.json")
+
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "first"), createMockLog("log-2", "second")},
+		[]datadogV2.Log{createMockLog("log-3", "third")},
+	)
+
+	// Record against the mock API
+	recordCfg := newTestConfig(filepath.Join(dir, "recorded.ndjson"))
+	recordCfg.RecordPath = cassettePath
+	f, err := New(recordCfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	f.client = NewClient("test-key", "test-app-key", "",
+		WithBaseURL(server.URL),
+		WithTransport(NewRecordingTransport(cassettePath, nil)),
+	)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	cassette, err := LoadCassette(cassettePath)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, 2)
+	assert.Equal(t, "GET", cassette.Interactions[0].Request.Method)
+
+	// Replay without the server and without credentials
+	server.Close()
+	replayCfg := newTestConfig(filepath.Join(dir, "replayed.ndjson"))
+	replayCfg.ReplayPath = cassettePath
+	replayCfg.APIKey = ""
+	replayCfg.AppKey = ""
+	f, err = New(replayCfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	recorded, err := os.ReadFile(recordCfg.OutputPath)
+	require.NoError(t, err)
+	replayed, err := os.ReadFile(replayCfg.OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(recorded), string(replayed))
+	assert.Len(t, strings.Split(strings.TrimSpace(string(replayed)), "\n"), 3)
+}
+
+func TestCassetteDoesNotRecordCredentials(t *testing.T) {
+	dir := t.TempDir()
+	cassettePath := filepath.Join(dir, "cassette.json")
+	server := newMockLogsServer(t, []datadogV2.Log{createMockLog("log-1", "first")})
+
+	f, err := New(newTestConfig(filepath.Join(dir, "out.ndjson")), &bytes.Buffer{})
+	require.NoError(t, err)
+	f.client = NewClient("secret-api-key", "secret-app-key", "",
+		WithBaseURL(server.URL),
+		WithTransport(NewRecordingTransport(cassettePath, nil)),
+	)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	data, err := os.ReadFile(cassettePath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-api-key")
+	assert.NotContains(t, string(data), "secret-app-key")
+}
+
+func TestReplayTransportExhausted(t *testing.T) {
+	rt := NewReplayTransport(&Cassette{})
+	req, err := http.NewRequest("GET", "https://api.datadoghq.com/api/v2/logs/events", nil)
+	require.NoError(t, err)
+
+	_, err = rt.RoundTrip(req)
+	assert.Error(t, err)
+}
+
+func TestLoadCassetteInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0644))
+
+	_, err := LoadCassette(path)
+	assert.Error(t, err)
+}
@@ -2,22 +2,109 @@ package fetcher
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/config"
 )
 
 // Client wraps the Datadog API client
 type Client struct {
-	api    *datadogV2.LogsApi
-	apiKey string
-	appKey string
+	api      *datadogV2.LogsApi
+	spans    *datadogV2.SpansApi
+	keys     *datadogV2.KeyManagementApi
+	validate *datadogV1.AuthenticationApi
+	orgs     *datadogV1.OrganizationsApi
+	indexes  *datadogV1.LogsIndexesApi
+	apiKey   string
+	appKey   string
+
+	transferred atomic.Int64 // response bytes received by listLogs and listLogsGet
+	decoded     atomic.Int64 // the same bytes decompressed
+}
+
+// ClientOption customizes a Client
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	transport http.RoundTripper
+	baseURL   string
+	userAgent string
+	headers   map[string]string
+	debug     io.Writer
+}
+
+// WithTransport sets the HTTP transport used for API requests
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = rt
+	}
+}
+
+// WithBaseURL overrides the API server URL (takes precedence over site)
+func WithBaseURL(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.baseURL = url
+	}
+}
+
+// WithUserAgent replaces the API client's User-Agent on every request
+func WithUserAgent(ua string) ClientOption {
+	return func(o *clientOptions) {
+		o.userAgent = ua
+	}
+}
+
+// WithHeaders adds headers to every request
+func WithHeaders(headers map[string]string) ClientOption {
+	return func(o *clientOptions) {
+		o.headers = headers
+	}
+}
+
+// WithDebug logs every request and its response to w (see DebugTransport)
+func WithDebug(w io.Writer) ClientOption {
+	return func(o *clientOptions) {
+		o.debug = w
+	}
+}
+
+// ConfigOptions returns the options a client for cfg needs: its API URL,
+// User-Agent and extra headers
+func ConfigOptions(cfg *config.Config) []ClientOption {
+	var opts []ClientOption
+	if cfg.APIURL != "" {
+		opts = append(opts, WithBaseURL(cfg.APIURL))
+	}
+	if cfg.UserAgent != "" {
+		opts = append(opts, WithUserAgent(cfg.UserAgent))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, WithHeaders(cfg.Headers))
+	}
+	return opts
 }
 
 // NewClient creates a new Datadog client
-func NewClient(apiKey, appKey, site string) *Client {
+func NewClient(apiKey, appKey, site string, opts ...ClientOption) *Client {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	config := datadog.NewConfiguration()
-	if site != "" {
+	if o.baseURL != "" {
+		config.Servers = datadog.ServerConfigurations{
+			{
+				URL:         o.baseURL,
+				Description: "Custom server",
+			},
+		}
+	} else if site != "" {
 		config.SetUnstableOperationEnabled("v2.ListLogsGet", true)
 		// Set the server based on site
 		config.Servers = datadog.ServerConfigurations{
@@ -28,12 +115,30 @@ func NewClient(apiKey, appKey, site string) *Client {
 		}
 	}
 
+	if o.userAgent != "" {
+		config.UserAgent = o.userAgent
+	}
+	for key, value := range o.headers {
+		config.AddDefaultHeader(key, value)
+	}
+	if o.debug != nil {
+		o.transport = NewDebugTransport(o.debug, o.transport)
+	}
+	if o.transport != nil {
+		config.HTTPClient = &http.Client{Transport: o.transport}
+	}
+
 	apiClient := datadog.NewAPIClient(config)
 
 	return &Client{
-		api:    datadogV2.NewLogsApi(apiClient),
-		apiKey: apiKey,
-		appKey: appKey,
+		api:      datadogV2.NewLogsApi(apiClient),
+		spans:    datadogV2.NewSpansApi(apiClient),
+		keys:     datadogV2.NewKeyManagementApi(apiClient),
+		validate: datadogV1.NewAuthenticationApi(apiClient),
+		orgs:     datadogV1.NewOrganizationsApi(apiClient),
+		indexes:  datadogV1.NewLogsIndexesApi(apiClient),
+		apiKey:   apiKey,
+		appKey:   appKey,
 	}
 }
 
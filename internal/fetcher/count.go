@@ -0,0 +1,211 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// Count returns the number of logs matching query in [from, to) using the
+// aggregation API
+func (c *Client) Count(ctx context.Context, query string, indexes []string, from, to time.Time) (int64, error) {
+	fromStr := strconv.FormatInt(from.UnixMilli(), 10)
+	toStr := strconv.FormatInt(to.UnixMilli(), 10)
+
+	body := datadogV2.LogsAggregateRequest{
+		Compute: []datadogV2.LogsCompute{
+			{Aggregation: datadogV2.LOGSAGGREGATIONFUNCTION_COUNT},
+		},
+		Filter: &datadogV2.LogsQueryFilter{
+			Query:   &query,
+			Indexes: indexes,
+			From:    &fromStr,
+			To:      &toStr,
+		},
+	}
+
+	resp, err := c.aggregate(ctx, body)
+	if err != nil {
+		return 0, err
+	}
+	return countFromResponse(resp)
+}
+
+// Histogram returns the number of logs matching query in each interval of
+// [from, to), oldest first; intervals without logs may be left out
+func (c *Client) Histogram(ctx context.Context, query string, indexes []string, from, to time.Time, interval time.Duration) ([]plan.Bucket, error) {
+	fromStr := strconv.FormatInt(from.UnixMilli(), 10)
+	toStr := strconv.FormatInt(to.UnixMilli(), 10)
+	intervalStr := strconv.FormatInt(int64(interval/time.Minute), 10) + "m"
+
+	body := datadogV2.LogsAggregateRequest{
+		Compute: []datadogV2.LogsCompute{
+			{
+				Aggregation: datadogV2.LOGSAGGREGATIONFUNCTION_COUNT,
+				Type:        datadogV2.LOGSCOMPUTETYPE_TIMESERIES.Ptr(),
+				Interval:    &intervalStr,
+			},
+		},
+		Filter: &datadogV2.LogsQueryFilter{
+			Query:   &query,
+			Indexes: indexes,
+			From:    &fromStr,
+			To:      &toStr,
+		},
+	}
+
+	resp, err := c.aggregate(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	return histogramFromResponse(resp)
+}
+
+// maxIndexes is how many indexes IndexHistogram counts; an organization with
+// more can't be planned index by index without missing some
+const maxIndexes = 1000
+
+// IndexHistogram returns the number of logs matching query in each interval
+// of [from, to) for each index that has any, oldest first
+func (c *Client) IndexHistogram(ctx context.Context, query string, indexes []string, from, to time.Time, interval time.Duration) (map[string][]plan.Bucket, error) {
+	fromStr := strconv.FormatInt(from.UnixMilli(), 10)
+	toStr := strconv.FormatInt(to.UnixMilli(), 10)
+	intervalStr := strconv.FormatInt(int64(interval/time.Minute), 10) + "m"
+
+	body := datadogV2.LogsAggregateRequest{
+		Compute: []datadogV2.LogsCompute{
+			{
+				Aggregation: datadogV2.LOGSAGGREGATIONFUNCTION_COUNT,
+				Type:        datadogV2.LOGSCOMPUTETYPE_TIMESERIES.Ptr(),
+				Interval:    &intervalStr,
+			},
+		},
+		Filter: &datadogV2.LogsQueryFilter{
+			Query:   &query,
+			Indexes: indexes,
+			From:    &fromStr,
+			To:      &toStr,
+		},
+		GroupBy: []datadogV2.LogsGroupBy{
+			{Facet: "index", Limit: datadog.PtrInt64(maxIndexes)},
+		},
+	}
+
+	resp, err := c.aggregate(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := resp.GetDataOk()
+	if !ok {
+		return nil, nil
+	}
+	if len(data.Buckets) >= maxIndexes {
+		return nil, fmt.Errorf("logs are spread over more than %d indexes", maxIndexes)
+	}
+
+	counts := make(map[string][]plan.Bucket, len(data.Buckets))
+	for _, bucket := range data.Buckets {
+		index, ok := bucket.By["index"].(string)
+		if !ok {
+			return nil, fmt.Errorf("aggregate response has a bucket without an index")
+		}
+		buckets, err := bucketHistogram(bucket)
+		if err != nil {
+			return nil, err
+		}
+		counts[index] = buckets
+	}
+	return counts, nil
+}
+
+// aggregate runs an aggregation, retrying transient errors like page
+// fetches do
+func (c *Client) aggregate(ctx context.Context, body datadogV2.LogsAggregateRequest) (datadogV2.LogsAggregateResponse, error) {
+	attempt := 0
+	for {
+		resp, httpResp, err := c.api.AggregateLogs(c.GetContext(ctx), body)
+
+		retryErr := ClassifyError(err, httpResp)
+		if retryErr == nil {
+			return resp, nil
+		}
+
+		shouldRetry, backoff := ShouldRetry(attempt, retryErr)
+		if !shouldRetry {
+			return resp, FormatRetryError(err, httpResp)
+		}
+		attempt++
+
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// countFromResponse extracts the total of an ungrouped count aggregation
+func countFromResponse(resp datadogV2.LogsAggregateResponse) (int64, error) {
+	data, ok := resp.GetDataOk()
+	if !ok || len(data.Buckets) == 0 {
+		// No matching logs means no bucket at all
+		return 0, nil
+	}
+
+	for _, value := range data.Buckets[0].Computes {
+		if value.LogsAggregateBucketValueSingleNumber != nil {
+			return int64(*value.LogsAggregateBucketValueSingleNumber), nil
+		}
+	}
+	return 0, fmt.Errorf("aggregate response has no count")
+}
+
+// histogramFromResponse extracts the points of an ungrouped count timeseries
+func histogramFromResponse(resp datadogV2.LogsAggregateResponse) ([]plan.Bucket, error) {
+	data, ok := resp.GetDataOk()
+	if !ok || len(data.Buckets) == 0 {
+		return nil, nil
+	}
+	return bucketHistogram(data.Buckets[0])
+}
+
+// bucketHistogram extracts the points of a bucket's count timeseries
+func bucketHistogram(bucket datadogV2.LogsAggregateBucket) ([]plan.Bucket, error) {
+	for _, value := range bucket.Computes {
+		if value.LogsAggregateBucketValueTimeseries == nil {
+			continue
+		}
+		var buckets []plan.Bucket
+		for _, point := range value.LogsAggregateBucketValueTimeseries.Items {
+			start, err := time.Parse(time.RFC3339Nano, point.GetTime())
+			if err != nil {
+				return nil, fmt.Errorf("aggregate response has an invalid time: %w", err)
+			}
+			buckets = append(buckets, plan.Bucket{Start: start, Count: int64(point.GetValue())})
+		}
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+		return buckets, nil
+	}
+	return nil, fmt.Errorf("aggregate response has no timeseries")
+}
+
+// queryHistogram counts one query's logs for a planner
+type queryHistogram struct {
+	client  *Client
+	query   string
+	indexes []string
+}
+
+func (h queryHistogram) Histogram(ctx context.Context, from, to time.Time, interval time.Duration) ([]plan.Bucket, error) {
+	return h.client.Histogram(ctx, h.query, h.indexes, from, to, interval)
+}
+
+func (h queryHistogram) IndexHistogram(ctx context.Context, from, to time.Time, interval time.Duration) (map[string][]plan.Bucket, error) {
+	return h.client.IndexHistogram(ctx, h.query, h.indexes, from, to, interval)
+}
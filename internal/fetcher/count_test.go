@@ -0,0 +1,112 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+func TestClientCount(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/logs/analytics/aggregate", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"buckets":[{"by":{},"computes":{"c0":42}}]},"meta":{"status":"done"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	n, err := client.Count(context.Background(), "service:web status:error", []string{"main"}, from, from.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	filter := got["filter"].(map[string]interface{})
+	assert.Equal(t, "service:web status:error", filter["query"])
+	assert.Equal(t, "1704067200000", filter["from"])
+	assert.Equal(t, "1704070800000", filter["to"])
+}
+
+func TestClientCountNoBuckets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"buckets":[]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	n, err := client.Count(context.Background(), "service:none", nil, time.Now().Add(-time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestClientHistogram(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"buckets":[{"by":{},"computes":{"c0":[
+			{"time":"2024-01-01T01:00:00.000Z","value":7},
+			{"time":"2024-01-01T00:00:00.000Z","value":3}
+		]}}]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	buckets, err := client.Histogram(context.Background(), "service:web", []string{"main"}, from, from.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, []plan.Bucket{
+		{Start: from, Count: 3},
+		{Start: from.Add(time.Hour), Count: 7},
+	}, buckets)
+
+	compute := got["compute"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "timeseries", compute["type"])
+	assert.Equal(t, "60m", compute["interval"])
+}
+
+func TestClientIndexHistogram(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"buckets":[
+			{"by":{"index":"main"},"computes":{"c0":[
+				{"time":"2024-01-01T01:00:00.000Z","value":7},
+				{"time":"2024-01-01T00:00:00.000Z","value":3}
+			]}},
+			{"by":{"index":"audit"},"computes":{"c0":[
+				{"time":"2024-01-01T00:00:00.000Z","value":1}
+			]}}
+		]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	counts, err := client.IndexHistogram(context.Background(), "service:web", nil, from, from.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]plan.Bucket{
+		"main":  {{Start: from, Count: 3}, {Start: from.Add(time.Hour), Count: 7}},
+		"audit": {{Start: from, Count: 1}},
+	}, counts)
+
+	groupBy := got["group_by"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "index", groupBy["facet"])
+	assert.EqualValues(t, maxIndexes, groupBy["limit"])
+}
@@ -0,0 +1,124 @@
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces the value of anything that looks like a credential
+const redacted = "[redacted]"
+
+// DebugTransport logs each API request and the response it got: method and
+// URL, status, latency, rate-limit headers and request IDs. It's meant for
+// output pasted into a support ticket, so keys and other secrets in headers
+// and query strings are redacted.
+type DebugTransport struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+// NewDebugTransport creates a transport that logs to w
+// If next is nil, http.DefaultTransport is used.
+func NewDebugTransport(w io.Writer, next http.RoundTripper) *DebugTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &DebugTransport{next: next, w: w}
+}
+
+// RoundTrip performs the request and logs it with its response
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	took := time.Since(start).Round(time.Millisecond)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--> %s %s", req.Method, redactURL(req.URL))
+	if headers := formatHeaders(req.Header, nil); headers != "" {
+		fmt.Fprintf(&b, " (%s)", headers)
+	}
+	b.WriteString("\n")
+	if err != nil {
+		fmt.Fprintf(&b, "<-- failed in %s: %v\n", took, err)
+	} else {
+		fmt.Fprintf(&b, "<-- %s in %s", resp.Status, took)
+		if headers := formatHeaders(resp.Header, debugResponseHeader); headers != "" {
+			fmt.Fprintf(&b, " (%s)", headers)
+		}
+		b.WriteString("\n")
+	}
+
+	// Requests run concurrently; each exchange stays together
+	t.mu.Lock()
+	io.WriteString(t.w, b.String())
+	t.mu.Unlock()
+	return resp, err
+}
+
+// debugResponseHeader picks the response headers worth logging: rate limits
+// and the IDs Datadog support can look a request up by
+func debugResponseHeader(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "x-ratelimit-") || strings.Contains(name, "request-id") ||
+		name == "retry-after" || name == "content-encoding"
+}
+
+// formatHeaders lists headers as "Name: value", sorted by name, with secrets
+// redacted; keep, if set, chooses which headers to list
+func formatHeaders(header http.Header, keep func(name string) bool) string {
+	var names []string
+	for name := range header {
+		if keep == nil || keep(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header.Values(name), ", ")
+		if secret(name) {
+			value = redacted
+		}
+		parts = append(parts, name+": "+value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// redactURL returns u with secret-looking query parameters and any user
+// info redacted
+func redactURL(u *url.URL) string {
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(redacted)
+	}
+	query := clean.Query()
+	for name := range query {
+		if secret(name) {
+			query.Set(name, redacted)
+		}
+	}
+	if len(query) > 0 {
+		clean.RawQuery = query.Encode()
+	}
+	return clean.String()
+}
+
+// secret reports whether a header or parameter name suggests a credential,
+// such as DD-API-KEY, DD-APPLICATION-KEY or Authorization
+func secret(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"key", "token", "secret", "auth", "cookie", "password"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
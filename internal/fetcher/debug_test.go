@@ -0,0 +1,53 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "300")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "42")
+		w.Header().Set("X-Request-Id", "req-123")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	client := &http.Client{Transport: NewDebugTransport(&out, nil)}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/api/v2/logs/events?filter[query]=*&api_key=abc", nil)
+	require.NoError(t, err)
+	req.Header.Set("DD-API-KEY", "abc")
+	req.Header.Set("X-Team", "payments")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "--> GET "+server.URL+"/api/v2/logs/events?")
+	assert.Contains(t, lines[0], "api_key=%5Bredacted%5D")
+	assert.Contains(t, lines[0], "Dd-Api-Key: [redacted]")
+	assert.Contains(t, lines[0], "X-Team: payments")
+	assert.Contains(t, lines[1], "<-- 429 Too Many Requests in ")
+	assert.Contains(t, lines[1], "X-Ratelimit-Limit: 300, X-Ratelimit-Remaining: 0, X-Ratelimit-Reset: 42, X-Request-Id: req-123")
+	assert.NotContains(t, out.String(), "abc")
+}
+
+func TestDebugTransportFailure(t *testing.T) {
+	var out bytes.Buffer
+	client := &http.Client{Transport: NewDebugTransport(&out, nil)}
+	_, err := client.Get("http://127.0.0.1:1/api/v2/logs/events")
+	require.Error(t, err)
+	assert.Contains(t, out.String(), "<-- failed in ")
+}
@@ -0,0 +1,134 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestError is an API request that failed for good, after any retries
+type RequestError struct {
+	Err        error
+	StatusCode int // 0 for network errors
+	Attempts   int
+	Page       int    // the page being fetched, counting from 1
+	Cursor     string // the page's cursor as --cursor-display shows it
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// ErrorRecord is one line of an error log
+type ErrorRecord struct {
+	Time           time.Time  `json:"ts"`
+	Kind           string     `json:"kind"` // retry, skipped for a window --skip-errors gave up on, or failed when the run gave up
+	Error          string     `json:"error"`
+	Attempt        int        `json:"attempt,omitempty"`
+	MaxAttempts    int        `json:"max_attempts,omitempty"`
+	StatusCode     int        `json:"status_code,omitempty"`
+	RateLimited    bool       `json:"rate_limited,omitempty"`
+	BackoffSeconds float64    `json:"backoff_seconds,omitempty"`
+	Page           int        `json:"page,omitempty"`
+	Cursor         string     `json:"cursor,omitempty"`
+	WindowFrom     *time.Time `json:"window_from,omitempty"`
+	WindowTo       *time.Time `json:"window_to,omitempty"`
+}
+
+// ErrorLog writes retried requests and failures as JSON records, one per
+// line, so they can be triaged by machine
+type ErrorLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// NewErrorLog creates an error log writing to w
+func NewErrorLog(w io.Writer) *ErrorLog {
+	return &ErrorLog{enc: json.NewEncoder(w), now: time.Now}
+}
+
+// Diagnostic records retried requests and skipped windows and ignores
+// everything else
+func (l *ErrorLog) Diagnostic(d Diagnostic) {
+	switch d.Kind {
+	case DiagnosticRetry:
+		l.write(ErrorRecord{
+			Kind:           "retry",
+			Error:          errString(d.Err),
+			Attempt:        d.Attempt,
+			MaxAttempts:    d.MaxAttempts,
+			StatusCode:     d.StatusCode,
+			RateLimited:    d.RateLimited,
+			BackoffSeconds: d.Backoff.Seconds(),
+			Page:           d.Page,
+			Cursor:         d.Cursor,
+		})
+	case DiagnosticSkipped:
+		record := ErrorRecord{
+			Kind:       "skipped",
+			Error:      errString(d.Err),
+			StatusCode: d.StatusCode,
+			Page:       d.Page,
+			Cursor:     d.Cursor,
+		}
+		if d.Window != nil {
+			from, to := d.Window.From.UTC(), d.Window.To.UTC()
+			record.WindowFrom, record.WindowTo = &from, &to
+		}
+		l.write(record)
+	}
+}
+
+// Failure records why a run failed, with the request's details when an API
+// request was the cause
+func (l *ErrorLog) Failure(err error) {
+	record := ErrorRecord{Kind: "failed", Error: errString(err)}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		record.Attempt = reqErr.Attempts
+		record.StatusCode = reqErr.StatusCode
+		record.Page = reqErr.Page
+		record.Cursor = reqErr.Cursor
+	}
+	l.write(record)
+}
+
+func (l *ErrorLog) write(record ErrorRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record.Time = l.now().UTC()
+	l.enc.Encode(record)
+}
+
+// teeProgress hands progress to several reporters
+type teeProgress []ProgressReporter
+
+func (t teeProgress) Progress(p Progress) {
+	for _, r := range t {
+		r.Progress(p)
+	}
+}
+
+// teeDiagnostics hands diagnostics to several reporters
+type teeDiagnostics []DiagnosticReporter
+
+func (t teeDiagnostics) Diagnostic(d Diagnostic) {
+	for _, r := range t {
+		r.Diagnostic(d)
+	}
+}
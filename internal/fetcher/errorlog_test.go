@@ -0,0 +1,170 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorLogRecords(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewErrorLog(&buf)
+	l.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.FixedZone("", 3600)) }
+
+	l.Diagnostic(Diagnostic{Kind: DiagnosticStart, Message: "Starting fetch"})
+	l.Diagnostic(Diagnostic{
+		Kind: DiagnosticRetry, Err: errors.New("429 Too Many Requests"), Attempt: 1, MaxAttempts: 3,
+		Backoff: 30 * time.Second, RateLimited: true, StatusCode: 429, Page: 4, Cursor: "abc",
+	})
+	w := plan.Window{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)}
+	l.Diagnostic(Diagnostic{Kind: DiagnosticSkipped, Err: errors.New("bad request"), StatusCode: 400, Page: 4, Cursor: "abc", Window: &w})
+	l.Failure(errors.New("failed to write page: disk full"))
+	l.Failure(&RequestError{Err: errors.New("authentication failed"), StatusCode: 401, Attempts: 1, Page: 5, Cursor: "def"})
+
+	assert.Equal(t,
+		`{"ts":"2024-01-01T11:00:00Z","kind":"retry","error":"429 Too Many Requests","attempt":1,"max_attempts":3,"status_code":429,"rate_limited":true,"backoff_seconds":30,"page":4,"cursor":"abc"}`+"\n"+
+			`{"ts":"2024-01-01T11:00:00Z","kind":"skipped","error":"bad request","status_code":400,"page":4,"cursor":"abc","window_from":"2024-01-01T00:00:00Z","window_to":"2024-01-01T01:00:00Z"}`+"\n"+
+			`{"ts":"2024-01-01T11:00:00Z","kind":"failed","error":"failed to write page: disk full"}`+"\n"+
+			`{"ts":"2024-01-01T11:00:00Z","kind":"failed","error":"authentication failed","attempt":1,"status_code":401,"page":5,"cursor":"def"}`+"\n",
+		buf.String())
+}
+
+func TestFetchReportsRequestErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case requests == 1:
+			// A first page, so the failure is on the second
+			w.Write([]byte(`{"data":[{"id":"log-1","type":"log","attributes":{}}],"meta":{"page":{"after":"page-1"}}}`))
+		case requests == 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"errors":["unavailable"]}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["forbidden"]}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	reporter := &recordingReporter{}
+	f.SetDiagnosticReporter(reporter)
+	var log bytes.Buffer
+	f.AddDiagnosticReporter(NewErrorLog(&log))
+
+	err = f.Fetch(context.Background())
+	var reqErr *RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusForbidden, reqErr.StatusCode)
+	assert.Equal(t, 2, reqErr.Attempts)
+	assert.Equal(t, 2, reqErr.Page)
+	assert.Equal(t, "page-1", reqErr.Cursor)
+	assert.Contains(t, err.Error(), "permission denied")
+
+	var retries []Diagnostic
+	for _, d := range reporter.diagnostics {
+		if d.Kind == DiagnosticRetry {
+			retries = append(retries, d)
+		}
+	}
+	require.Len(t, retries, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, retries[0].StatusCode)
+	assert.Equal(t, 2, retries[0].Page)
+	assert.Equal(t, "page-1", retries[0].Cursor)
+	assert.Contains(t, log.String(), `"kind":"retry"`)
+	assert.Contains(t, log.String(), `"status_code":503`)
+}
+
+func TestFetchSkipsFailedWindows(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var windows []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("filter[from]"))
+		require.NoError(t, err)
+		windows = append(windows, from)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case from.Equal(base.Add(time.Hour)) && r.URL.Query().Get("page[cursor]") == "":
+			// The window's first page, so the failure is on the second
+			w.Write([]byte(`{"data":[{"id":"log-2","type":"log","attributes":{}}],"meta":{"page":{"after":"page-1"}}}`))
+		case from.Equal(base.Add(time.Hour)):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["bad request"]}`))
+		default:
+			w.Write([]byte(`{"data":[{"id":"log","type":"log","attributes":{}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = base
+	cfg.To = base.Add(3 * time.Hour)
+	cfg.Window = time.Hour
+	cfg.SkipErrors = true
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	reporter := &recordingReporter{}
+	f.SetDiagnosticReporter(reporter)
+
+	require.NoError(t, f.Fetch(context.Background()))
+	assert.Equal(t, []time.Time{base, base.Add(time.Hour), base.Add(time.Hour), base.Add(2 * time.Hour)}, windows)
+	assert.Equal(t, 3, f.Stats().Logs)
+
+	skipped := f.Stats().Skipped
+	require.Len(t, skipped, 1)
+	assert.Equal(t, plan.Window{From: base.Add(time.Hour), To: base.Add(2 * time.Hour)}, skipped[0].Window)
+	assert.Equal(t, 3, skipped[0].Page)
+	assert.Equal(t, "page-1", skipped[0].Cursor)
+
+	reported := 0
+	for _, d := range reporter.diagnostics {
+		if d.Kind == DiagnosticSkipped {
+			reported++
+			assert.Equal(t, http.StatusBadRequest, d.StatusCode)
+			assert.Contains(t, d.Message, "Skipping the rest of window 2024-01-01T01:00:00Z to 2024-01-01T02:00:00Z: page 3 failed")
+		}
+	}
+	assert.Equal(t, 1, reported)
+}
+
+func TestFetchDoesNotSkipRefusedCredentials(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["forbidden"]}`))
+	}))
+	defer server.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = base
+	cfg.To = base.Add(3 * time.Hour)
+	cfg.Window = time.Hour
+	cfg.SkipErrors = true
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	var reqErr *RequestError
+	require.ErrorAs(t, f.Fetch(context.Background()), &reqErr)
+	assert.Equal(t, 1, requests)
+	assert.Empty(t, f.Stats().Skipped)
+}
@@ -0,0 +1,72 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+// expiredCursor reports whether err is the API rejecting cursor: the first
+// page of the query was accepted, so a bad request for a later one means the
+// cursor itself is no longer valid, usually because it expired
+func expiredCursor(err error, cursor string) bool {
+	var reqErr *RequestError
+	return cursor != "" && errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusBadRequest
+}
+
+// lastFetched moves w to the last of logs, which arrive sorted, collecting
+// the IDs of every log at its timestamp
+func lastFetched(w *state.Watermark, logs []datadogV2.Log) *state.Watermark {
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		ts, ok := attrs.GetTimestampOk()
+		if !ok {
+			continue
+		}
+		switch {
+		case w == nil || !ts.Equal(w.Timestamp):
+			w = &state.Watermark{Timestamp: ts.UTC(), IDs: []string{log.GetId()}}
+		case !slices.Contains(w.IDs, log.GetId()):
+			w.IDs = append(w.IDs, log.GetId())
+		}
+	}
+	return w
+}
+
+// restartWindow narrows w to the logs not yet fetched when its cursor
+// expired after last. The narrowed window still includes last's timestamp,
+// as more logs may share it; skipFetched drops the ones already written.
+func restartWindow(w plan.Window, last *state.Watermark, ascending bool) plan.Window {
+	if ascending {
+		w.From = last.Timestamp
+		return w
+	}
+	// Timestamps are whole milliseconds, so this keeps last's whether or not
+	// the end of the range is inclusive
+	w.To = last.Timestamp.Add(time.Millisecond)
+	return w
+}
+
+// skipFetched drops the logs a restarted window fetches again: those at
+// last's timestamp that were already fetched, and any beyond it
+func skipFetched(last *state.Watermark, ascending bool, logs []datadogV2.Log) []datadogV2.Log {
+	kept := logs[:0]
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		ts := attrs.GetTimestamp()
+		beyond := ts.Before(last.Timestamp)
+		if !ascending {
+			beyond = ts.After(last.Timestamp)
+		}
+		if beyond || ts.Equal(last.Timestamp) && slices.Contains(last.IDs, log.GetId()) {
+			continue
+		}
+		kept = append(kept, log)
+	}
+	return kept
+}
@@ -0,0 +1,185 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+func TestLastFetched(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Pages newest first end at their oldest log
+	w := lastFetched(nil, []datadogV2.Log{logAt("a", t0.Add(time.Minute)), logAt("b", t0), logAt("c", t0)})
+	assert.Equal(t, &state.Watermark{Timestamp: t0, IDs: []string{"b", "c"}}, w)
+
+	w = lastFetched(w, []datadogV2.Log{logAt("c", t0), logAt("d", t0)})
+	assert.Equal(t, &state.Watermark{Timestamp: t0, IDs: []string{"b", "c", "d"}}, w)
+
+	w = lastFetched(w, []datadogV2.Log{logAt("e", t0.Add(-time.Second))})
+	assert.Equal(t, &state.Watermark{Timestamp: t0.Add(-time.Second), IDs: []string{"e"}}, w)
+}
+
+func TestRestartWindow(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := plan.Window{From: t0, To: t0.Add(time.Hour)}
+	last := &state.Watermark{Timestamp: t0.Add(10 * time.Minute), IDs: []string{"a"}}
+
+	assert.Equal(t, plan.Window{From: last.Timestamp, To: w.To}, restartWindow(w, last, true))
+	assert.Equal(t, plan.Window{From: w.From, To: last.Timestamp.Add(time.Millisecond)}, restartWindow(w, last, false))
+}
+
+func TestSkipFetched(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := &state.Watermark{Timestamp: t0, IDs: []string{"a"}}
+	ids := func(logs []datadogV2.Log) []string {
+		var ids []string
+		for _, log := range logs {
+			ids = append(ids, log.GetId())
+		}
+		return ids
+	}
+
+	logs := []datadogV2.Log{logAt("old", t0.Add(-time.Second)), logAt("a", t0), logAt("b", t0), logAt("new", t0.Add(time.Second))}
+	assert.Equal(t, []string{"b", "new"}, ids(skipFetched(last, true, logs)))
+
+	logs = []datadogV2.Log{logAt("new", t0.Add(time.Second)), logAt("a", t0), logAt("b", t0), logAt("old", t0.Add(-time.Second))}
+	assert.Equal(t, []string{"b", "old"}, ids(skipFetched(last, false, logs)))
+}
+
+// newExpiringLogsServer serves two pages, newest first, but rejects the
+// cursor to the second as expired; a request restarted at the first page's
+// last log gets the rest, starting with that log again
+func newExpiringLogsServer(t *testing.T, t0 time.Time, requests *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		*requests = append(*requests, q.Get("filter[to]")+" "+q.Get("page[cursor]"))
+		w.Header().Set("Content-Type", "application/json")
+
+		var response datadogV2.LogsListResponse
+		switch {
+		case q.Get("page[cursor]") != "":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["Invalid cursor"]}`))
+			return
+		case q.Get("filter[to]") == "":
+			response.Data = []datadogV2.Log{logAt("c", t0.Add(2*time.Second)), logAt("b1", t0.Add(time.Second))}
+			response.Meta = &datadogV2.LogsResponseMetadata{Page: &datadogV2.LogsResponseMetadataPage{After: strPtr("expired")}}
+		default:
+			response.Data = []datadogV2.Log{logAt("b1", t0.Add(time.Second)), logAt("b2", t0.Add(time.Second)), logAt("a", t0)}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchRestartsExpiredCursor(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []string
+	server := newExpiringLogsServer(t, t0, &requests)
+
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(path)
+	cfg.APIURL = server.URL
+	cfg.From = t0.Add(-time.Hour)
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	reporter := &recordingReporter{}
+	f.SetDiagnosticReporter(reporter)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []string{" ", " expired", "2024-01-01T00:00:01.001Z "}, requests)
+	assert.Equal(t, []string{"c", "b1", "b2", "a"}, writtenIDs(t, path))
+	assert.Equal(t, 4, f.Stats().Logs)
+
+	var restarts []Diagnostic
+	for _, d := range reporter.diagnostics {
+		if d.Kind == DiagnosticRestarted {
+			restarts = append(restarts, d)
+		}
+	}
+	require.Len(t, restarts, 1)
+	assert.Equal(t, "Cursor expired; restarting from the last log fetched, at 2024-01-01T00:00:01Z", restarts[0].Message)
+	assert.Equal(t, "expired", restarts[0].Cursor)
+}
+
+func TestFetchRestartsExpiredCursorOnResume(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []string
+	server := newExpiringLogsServer(t, t0, &requests)
+
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(path)
+	cfg.APIURL = server.URL
+	cfg.From = t0.Add(-time.Hour)
+	cfg.Cursor = "expired"
+	cfg.CursorLast = &state.Watermark{Timestamp: t0.Add(time.Second), IDs: []string{"b1"}}
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []string{"b2", "a"}, writtenIDs(t, path))
+
+	// Without the last log fetched there is nothing to restart from
+	cfg.CursorLast = nil
+	f, err = New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.ErrorContains(t, f.Fetch(context.Background()), "the cursor may have expired")
+}
+
+func TestFetchSavesLastFetched(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{logAt("b", t0.Add(time.Second)), logAt("a", t0)},
+		[]datadogV2.Log{logAt("z", t0.Add(-time.Second))},
+	)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.StatePath = filepath.Join(dir, "state.json")
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	// Stop after the first page, whose last log is where a restart begins
+	ctx, cancel := context.WithCancel(context.Background())
+	f.AddObserver(observerFunc(func([]datadogV2.Log) { cancel() }))
+	require.NoError(t, f.Fetch(ctx))
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.Equal(t, "page-1", s.Cursor)
+	assert.Equal(t, &state.Watermark{Timestamp: t0, IDs: []string{"a"}}, s.Last)
+}
+
+// writtenIDs returns the IDs of the logs in an NDJSON file, in order
+func writtenIDs(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var log struct {
+			ID string `json:"id"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &log))
+		ids = append(ids, log.ID)
+	}
+	return ids
+}
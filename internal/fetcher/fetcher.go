@@ -1,84 +1,513 @@
 package fetcher
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
 	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+	"github.com/jtzemp/dogfetch/internal/filter"
+	"github.com/jtzemp/dogfetch/internal/grok"
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/jtzemp/dogfetch/internal/redact"
+	"github.com/jtzemp/dogfetch/internal/state"
+	"github.com/jtzemp/dogfetch/internal/synth"
 	"github.com/jtzemp/dogfetch/internal/writer"
 )
 
+// Observer is notified of every page of logs after it has been written
+type Observer interface {
+	Observe(logs []datadogV2.Log)
+}
+
+// Stats summarizes a fetch
+type Stats struct {
+	Logs     int // logs written
+	Filtered int // logs dropped by --filter
+	Pages    int
+	Duration time.Duration
+	Cursor   string // last cursor seen, empty once all pages are fetched
+	Skipped  []Skipped
+	Chunks   []writer.Chunk // files written by --split, in order
+
+	Transferred int64 // bytes of pages received from the API, compressed
+	Decoded     int64 // bytes of pages once decompressed
+
+	Duplicates int // logs received again, by ID
+	OutOfOrder int // logs whose timestamp went back against the sort order
+
+	Output int64 // bytes of output written, in the output format
+}
+
+// Skipped is the rest of a window --skip-errors gave up on after a page
+// failed
+type Skipped struct {
+	Window plan.Window
+	Page   int    // the page that failed, counting from 1
+	Cursor string // its cursor as --cursor-display shows it; empty for a window's first page
+	Err    error
+}
+
 // Fetcher orchestrates the log fetching process
 type Fetcher struct {
-	client *Client
-	config *config.Config
-	writer writer.Writer
-	errOut io.Writer
+	client    *Client
+	config    *config.Config
+	writer    writer.Writer
+	parser    *grok.Parser
+	redactor  *redact.Redactor
+	observers []Observer
+	stats     Stats
+
+	planner     plan.Planner     // nil fetches the whole range under one cursor
+	pending     *state.Watermark // newest log exported by an incremental run
+	last        *state.Watermark // last log fetched under the current cursor
+	order       *orderCheck
+	output      *outputCounter // nil when pages go to a caller's writer
+	pageBytes   int64          // received for the latest page
+	progress    ProgressReporter
+	diagnostics DiagnosticReporter
 }
 
 // New creates a new Fetcher
+// Progress and diagnostics are written to errOut as text until replaced with
+// SetProgressReporter and SetDiagnosticReporter.
 func New(cfg *config.Config, errOut io.Writer) (*Fetcher, error) {
-	if errOut == nil {
-		errOut = os.Stderr
+	return NewWithStdout(cfg, os.Stdout, errOut)
+}
+
+// NewWithStdout creates a new Fetcher that writes to stdout instead of
+// os.Stdout when cfg has no output path, e.g. to page it
+func NewWithStdout(cfg *config.Config, stdout io.Writer, errOut io.Writer) (*Fetcher, error) {
+	var wrapper *envelope.Wrapper
+	if cfg.Envelope != "" {
+		var err error
+		wrapper, err = envelope.New(cfg.Envelope, envelope.Source{Site: cfg.Site, Index: cfg.Index})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	client := NewClient(cfg.APIKey, cfg.AppKey, cfg.Site)
+	// Count the bytes written to stdout; pretty output needs to see the
+	// terminal itself, and there's no storage to estimate for it
+	output := &outputCounter{}
+	if cfg.Format != "pretty" {
+		output.stdout = &countingWriter{w: stdout}
+		stdout = output.stdout
+	}
 
-	w, err := writer.New(cfg.Format, cfg.OutputPath, cfg.Append)
+	opts := writer.Options{
+		GroupBy:         cfg.AggregateBy,
+		Bucket:          cfg.AggregateBucket,
+		KThreshold:      cfg.AggregateK,
+		Epsilon:         cfg.AggregateEpsilon,
+		StitchBy:        cfg.StitchBy,
+		SidecarIndex:    cfg.SidecarIndex,
+		OTLPEndpoint:    cfg.OTLPEndpoint,
+		OTLPHeaders:     cfg.OTLPHeaders,
+		SIEMFields:      cfg.SIEMFields,
+		TextFields:      cfg.TextFields,
+		NoColor:         cfg.NoColor,
+		SARIFRuleFields: cfg.SARIFRuleFields,
+		MaxMemory:       cfg.MaxMemory,
+		Split:           cfg.Split,
+		Envelope:        wrapper,
+		Stdout:          stdout,
+		Encrypt:         cfg.Encrypt,
+		Atomic:          cfg.Atomic,
+		ExecRestarts:    cfg.ExecRestarts,
+		WebhookBatch:    cfg.WebhookBatch,
+		WebhookHeaders:  cfg.WebhookHeaders,
+	}
+	var f *Fetcher
+	opts.OnExecRestart = func(err error) {
+		f.diagnostics.Diagnostic(Diagnostic{
+			Kind:    DiagnosticExecRestarted,
+			Message: fmt.Sprintf("Output command exited early: %v; restarting it and sending the page again", err),
+			Err:     err,
+		})
+	}
+	// Pages written after the state was last saved are fetched again, so
+	// they are cut off rather than left in the file twice
+	if cfg.Append && cfg.CursorSize > 0 && cfg.PageOutput() {
+		dropped, err := trimOutput(cfg.OutputPath, cfg.CursorSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to trim output to the state saved in %s: %w", cfg.StatePath, err)
+		}
+		if dropped > 0 && errOut != nil {
+			fmt.Fprintf(errOut, "Dropped %d bytes written to %s after the state was saved; their logs are fetched again\n", dropped, cfg.OutputPath)
+		}
+	}
+	if cfg.Append && cfg.PageOutput() && cfg.Format == "ndjson" {
+		added, err := checkAppend(cfg.OutputPath, cfg.Force)
+		if err != nil {
+			return nil, err
+		}
+		if added && errOut != nil {
+			fmt.Fprintf(errOut, "Added the missing newline at the end of %s before appending\n", cfg.OutputPath)
+		}
+	}
+	if cfg.FileOutput() {
+		output.path, output.atomic = cfg.OutputPath, cfg.Atomic && !cfg.Append
+		if cfg.Append {
+			output.start = fileSize(cfg.OutputPath)
+		}
+	}
+	w, err := writer.NewWithOptions(cfg.Format, cfg.OutputPath, cfg.Append, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create writer: %w", err)
 	}
 
-	return &Fetcher{
-		client: client,
+	// With --tee, stdout gets a copy that may stop without failing the fetch
+	if cfg.Tee {
+		mirror, err := writer.NewWithOutput(cfg.Format, stdout, opts)
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to create writer: %w", err)
+		}
+		w = writer.NewTeeWriter(w, mirror, func(err error) {
+			f.diagnostics.Diagnostic(Diagnostic{
+				Kind:    DiagnosticTeeStopped,
+				Message: fmt.Sprintf("Stopped copying logs to stdout: %v; still writing to %s", err, cfg.OutputPath),
+				Err:     err,
+			})
+		})
+	}
+
+	f, err = NewWithWriter(cfg, w, errOut)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	f.output = output
+	return f, nil
+}
+
+// NewWithWriter creates a new Fetcher that hands pages to w instead of the
+// writer for cfg.Format, e.g. to stream them to a caller
+func NewWithWriter(cfg *config.Config, w writer.Writer, errOut io.Writer) (*Fetcher, error) {
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+	if _, ok := w.(writer.RawWriter); cfg.Raw && !ok {
+		return nil, fmt.Errorf("--raw can't write to this output; it writes ndjson to stdout or a plain file")
+	}
+	text := NewTextReporter(errOut)
+
+	opts := ConfigOptions(cfg)
+	if cfg.DebugHTTP {
+		opts = append(opts, WithDebug(errOut))
+	}
+	switch {
+	case cfg.ReplayPath != "":
+		cassette, err := LoadCassette(cfg.ReplayPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cassette: %w", err)
+		}
+		opts = append(opts, WithTransport(NewReplayTransport(cassette)))
+	case cfg.RecordPath != "":
+		opts = append(opts, WithTransport(NewRecordingTransport(cfg.RecordPath, nil)))
+	case cfg.Demo > 0:
+		to := cfg.To
+		if to.IsZero() {
+			to = time.Now()
+		}
+		opts = append(opts, WithTransport(synth.Transport(synth.New(1, cfg.Demo, cfg.From, to))))
+	}
+
+	f := &Fetcher{
+		client: NewClient(cfg.APIKey, cfg.AppKey, cfg.Site, opts...),
 		config: cfg,
 		writer: w,
-		errOut: errOut,
-	}, nil
+
+		progress:    text,
+		diagnostics: text,
+	}
+	var indexes []string
+	if cfg.Index != "" {
+		indexes = []string{cfg.Index}
+	}
+	counts := queryHistogram{client: f.client, query: cfg.Query, indexes: indexes}
+	switch {
+	case cfg.Plan == "balanced":
+		f.planner = plan.Balanced{Counts: counts, Logs: cfg.WindowLogs, MaxSize: cfg.Window}
+	case cfg.Plan == "indexes":
+		f.planner = plan.Indexes{Counts: counts, Logs: cfg.WindowLogs, MaxSize: cfg.Window}
+	case cfg.Window > 0:
+		f.planner = plan.Slices{Size: cfg.Window}
+	}
+	// Start from the last run's watermark so it never moves back, and
+	// logs at the same timestamp add to its IDs
+	if w := cmp.Or(cfg.Pending, cfg.Watermark); w != nil {
+		f.pending = &state.Watermark{Timestamp: w.Timestamp, IDs: slices.Clone(w.IDs)}
+	}
+	if len(cfg.ParsePatterns) > 0 {
+		f.parser = grok.NewParser(cfg.ParsePatterns)
+	}
+	if len(cfg.Redactions) > 0 {
+		f.redactor = redact.New(cfg.Redactions)
+	}
+	return f, nil
+}
+
+// AddObserver registers an observer for written pages
+func (f *Fetcher) AddObserver(o Observer) {
+	f.observers = append(f.observers, o)
+}
+
+// SetProgressReporter routes progress snapshots to r instead of the text
+// written to errOut; nil discards them
+func (f *Fetcher) SetProgressReporter(r ProgressReporter) {
+	if r == nil {
+		r = discard{}
+	}
+	f.progress = r
+}
+
+// SetDiagnosticReporter routes diagnostics to r instead of the text written
+// to errOut; nil discards them
+func (f *Fetcher) SetDiagnosticReporter(r DiagnosticReporter) {
+	if r == nil {
+		r = discard{}
+	}
+	f.diagnostics = r
+}
+
+// AddProgressReporter sends progress to r as well as to the current
+// reporter, e.g. to answer status requests
+func (f *Fetcher) AddProgressReporter(r ProgressReporter) {
+	f.progress = teeProgress{f.progress, r}
+}
+
+// AddDiagnosticReporter sends diagnostics to r as well as to the current
+// reporter, e.g. to log errors to a file
+func (f *Fetcher) AddDiagnosticReporter(r DiagnosticReporter) {
+	f.diagnostics = teeDiagnostics{f.diagnostics, r}
+}
+
+// Stats returns the progress of the current or last fetch
+func (f *Fetcher) Stats() Stats {
+	s := f.stats
+	s.Chunks = writer.Chunks(f.writer)
+	s.Transferred, s.Decoded = f.client.Transfer()
+	s.Output = f.written()
+	return s
 }
 
 // Fetch retrieves logs from Datadog
-func (f *Fetcher) Fetch(ctx context.Context) error {
-	defer f.writer.Close()
+func (f *Fetcher) Fetch(ctx context.Context) (err error) {
+	defer func() {
+		// Streams finished on close, such as encryption, are unreadable if
+		// closing failed
+		if closeErr := f.writer.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close output: %w", closeErr)
+		}
+	}()
 
-	cursor := f.config.Cursor
-	totalLogs := 0
-	pageCount := 0
 	startTime := time.Now()
 
-	fmt.Fprintf(f.errOut, "Starting fetch with query: %s\n", f.config.Query)
-	fmt.Fprintf(f.errOut, "Time range: %s to %s\n", f.config.From.Format(time.RFC3339), formatToTime(f.config.To))
-	fmt.Fprintf(f.errOut, "Page size: %d\n", f.config.PageSize)
-	fmt.Fprintf(f.errOut, "\n")
+	f.diagnostics.Diagnostic(Diagnostic{
+		Kind: DiagnosticStart,
+		Message: fmt.Sprintf("Starting fetch with query: %s\nTime range: %s to %s\nPage size: %d",
+			f.config.Query, f.config.FormatTime(f.config.From), f.config.FormatTime(f.config.To), f.config.PageSize),
+		Query:    f.config.Query,
+		From:     f.config.From,
+		To:       f.config.To,
+		PageSize: f.config.PageSize,
+	})
+
+	windows, err := f.plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	cursor := f.config.Cursor
+	f.last = f.config.CursorLast
+	f.order = newOrderCheck()
+	fetched := 0
+	for i, w := range windows {
+		if f.planner != nil {
+			f.diagnostics.Diagnostic(Diagnostic{
+				Kind:    DiagnosticWindow,
+				Message: fmt.Sprintf("Window %d/%d: %s", i+1, len(windows), w),
+				Window:  &windows[i],
+			})
+		}
+		f.order.window()
+
+		// Once a window is done the state points at the next one, so a
+		// resume doesn't refetch it
+		next := w
+		if i+1 < len(windows) {
+			next = windows[i+1]
+		}
+
+		cancelled, err := f.fetchWindow(ctx, w, next, cursor, &fetched, startTime)
+		var reqErr *RequestError
+		if err != nil && f.config.SkipErrors && ctx.Err() == nil && errors.As(err, &reqErr) && skippable(reqErr) {
+			if err := f.skip(w, next, reqErr); err != nil {
+				return err
+			}
+			cursor, f.last = "", nil
+			continue
+		}
+		if err != nil || cancelled {
+			return err
+		}
+		cursor, f.last = "", nil
+	}
+
+	if err := f.saveState(nil, "", true); err != nil {
+		return err
+	}
+	f.progress.Progress(f.snapshot(fetched, "", startTime, true))
+
+	return f.writer.Finalize()
+}
+
+// skippable reports whether --skip-errors can move past a failed request:
+// credentials that are refused would fail every window the same way
+func skippable(err *RequestError) bool {
+	return err.StatusCode != http.StatusUnauthorized && err.StatusCode != http.StatusForbidden
+}
+
+// skip records giving up on the rest of window w, moving the state on to
+// the next window
+func (f *Fetcher) skip(w, next plan.Window, err *RequestError) error {
+	f.stats.Skipped = append(f.stats.Skipped, Skipped{Window: w, Page: err.Page, Cursor: err.Cursor, Err: err.Err})
+	f.diagnostics.Diagnostic(Diagnostic{
+		Kind:       DiagnosticSkipped,
+		Message:    fmt.Sprintf("Skipping the rest of window %s: page %d failed: %v", w, err.Page, err.Err),
+		Err:        err.Err,
+		StatusCode: err.StatusCode,
+		Page:       err.Page,
+		Cursor:     err.Cursor,
+		Window:     &w,
+	})
+	return f.saveState(&next, "", false)
+}
+
+// plan lists the windows to fetch: the whole range under one cursor, unless
+// a planner splits it. A resumed windowed fetch finishes the cursor's window
+// before planning the rest of the range; one planned index by index plans
+// the whole range again and carries on after the cursor's window
+func (f *Fetcher) plan(ctx context.Context) ([]plan.Window, error) {
+	if f.planner == nil {
+		return []plan.Window{{From: f.config.From, To: f.config.To}}, nil
+	}
+
+	to := f.config.To
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+
+	var windows []plan.Window
+	from := f.config.From
+	w := f.config.CursorWindow
+	if w != nil {
+		windows = append(windows, *w)
+		if w.Index == "" {
+			from = w.To
+		}
+	}
+
+	rest, err := f.planner.Plan(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan windows: %w", err)
+	}
+	if w != nil && w.Index != "" {
+		rest = plan.After(rest, *w)
+	}
+	return append(windows, rest...), nil
+}
 
+// fetchWindow pages through one window from cursor, adding to the running
+// totals, and reports whether the fetch was cancelled part way. If the cursor
+// expires, the window is restarted from the last log fetched.
+func (f *Fetcher) fetchWindow(ctx context.Context, w, next plan.Window, cursor string, fetched *int, startTime time.Time) (bool, error) {
+	// Windows are fetched oldest first, a single range newest first
+	ascending := f.planner != nil
+	var restarted *state.Watermark
 	for {
 		// Check for cancellation
 		select {
 		case <-ctx.Done():
-			fmt.Fprintf(f.errOut, "\nOperation cancelled. Resume with --cursor '%s'\n", cursor)
-			return f.writer.Finalize()
+			return true, f.cancel(w, cursor)
 		default:
 		}
 
 		// Fetch page with retry
-		resp, _, err := f.fetchPageWithRetry(ctx, cursor)
+		before, _ := f.client.Transfer()
+		resp, records, err := f.fetchPageWithRetry(ctx, w, cursor)
+		if err != nil && expiredCursor(err, cursor) {
+			if f.last == nil {
+				return false, fmt.Errorf("%w; the cursor may have expired, and fetches resumed with --resume --state-file restart from the last log fetched instead", err)
+			}
+			expired := f.config.DisplayCursor(cursor)
+			w, restarted, cursor = restartWindow(w, f.last, ascending), f.last, ""
+			f.diagnostics.Diagnostic(Diagnostic{
+				Kind:    DiagnosticRestarted,
+				Message: fmt.Sprintf("Cursor expired; restarting from the last log fetched, at %s", restarted.Timestamp.Format(time.RFC3339Nano)),
+				Err:     err,
+				Cursor:  expired,
+				Window:  &w,
+			})
+			resp, records, err = f.fetchPageWithRetry(ctx, w, cursor)
+		}
+		// Cancelled mid-request, the page is fetched again on resume
+		if err != nil && ctx.Err() != nil {
+			return true, f.cancel(w, cursor)
+		}
 		if err != nil {
-			return err
+			return false, err
 		}
 
-		// Write logs
-		logs := resp.GetData()
-		if err := f.writer.WritePage(logs); err != nil {
-			return fmt.Errorf("failed to write page: %w", err)
+		// Write logs, parsed first so filters and redaction cover the
+		// extracted fields, then filtered and redacted so neither writers
+		// nor observers see dropped logs or the original values
+		received := resp.GetData()
+		last := len(received) == 0
+		if restarted != nil {
+			received = skipFetched(restarted, ascending, received)
+		}
+		if f.config.Incremental {
+			received = skipExported(f.config.Watermark, received)
+			f.pending = advanceWatermark(f.pending, received)
+		}
+		f.last = lastFetched(f.last, received)
+		f.order.page(received, ascending)
+		if f.parser != nil {
+			f.parser.Page(received)
+		}
+		logs := filter.Page(f.config.Filters, received)
+		if f.redactor != nil {
+			f.redactor.Page(logs)
+		}
+		if err := f.writePage(logs, resp.Data, records); err != nil {
+			return false, fmt.Errorf("failed to write page: %w", err)
 		}
 
-		pageCount++
-		totalLogs += len(logs)
+		for _, o := range f.observers {
+			o.Observe(logs)
+		}
+
+		*fetched += len(received)
+		f.stats.Logs += len(logs)
+		f.stats.Filtered = *fetched - f.stats.Logs
+		f.stats.Pages++
+		f.stats.Duplicates, f.stats.OutOfOrder = f.order.duplicates, f.order.outOfOrder
+		f.stats.Duration = time.Since(startTime)
+		transferred, _ := f.client.Transfer()
+		f.pageBytes = transferred - before
 
 		// Update cursor
 		newCursor := ""
@@ -89,67 +518,208 @@ func (f *Fetcher) Fetch(ctx context.Context) error {
 				}
 			}
 		}
+		done := newCursor == "" || last
 
-		// Progress update
-		elapsed := time.Since(startTime)
-		rate := float64(totalLogs) / elapsed.Seconds()
-		fmt.Fprintf(f.errOut, "Fetched %d logs (%d pages, %.1f logs/sec)", totalLogs, pageCount, rate)
-		if newCursor != "" {
-			fmt.Fprintf(f.errOut, " - cursor: %s", newCursor)
+		f.stats.Cursor = newCursor
+		saved := &w
+		if done {
+			saved = &next
 		}
-		fmt.Fprintf(f.errOut, "\n")
-
-		// Check if we're done
-		if newCursor == "" || len(logs) == 0 {
-			break
+		if err := f.saveState(saved, newCursor, false); err != nil {
+			return false, err
 		}
 
+		// Progress update
+		f.progress.Progress(f.snapshot(*fetched, newCursor, startTime, false))
+
+		if done {
+			return false, nil
+		}
 		cursor = newCursor
 	}
+}
 
-	fmt.Fprintf(f.errOut, "\nCompleted! Fetched %d logs in %d pages (%.1fs)\n", totalLogs, pageCount, time.Since(startTime).Seconds())
+// writePage hands logs to the writer; with --raw, as the records the API
+// sent for the page's data rather than encoded again
+func (f *Fetcher) writePage(logs, data []datadogV2.Log, records []json.RawMessage) error {
+	if !f.config.Raw {
+		return f.writer.WritePage(logs)
+	}
+	return f.writer.(writer.RawWriter).WriteRawPage(logs, rawRecords(logs, data, records))
+}
 
+// cancel stops the fetch before the page at cursor in w, saving the state to
+// resume from and finalizing what was written. Without --state-file the state
+// goes next to the output file.
+func (f *Fetcher) cancel(w plan.Window, cursor string) error {
+	hint := f.config.ResumeHint(cursor)
+	if f.config.StatePath == "" && f.config.ResumeStatePath() != "" {
+		f.config.StatePath = f.config.ResumeStatePath()
+		hint = fmt.Sprintf("saved the state to %s; resume with --resume --state-file %s", f.config.StatePath, f.config.StatePath)
+	}
+	if err := f.saveState(&w, cursor, false); err != nil {
+		return err
+	}
+	f.diagnostics.Diagnostic(Diagnostic{
+		Kind:    DiagnosticCancelled,
+		Message: "Operation cancelled; " + hint,
+		Cursor:  f.config.DisplayCursor(cursor),
+	})
+	writer.Abandon(f.writer)
 	return f.writer.Finalize()
 }
 
-// fetchPageWithRetry fetches a single page with retry logic
-func (f *Fetcher) fetchPageWithRetry(ctx context.Context, cursor string) (datadogV2.LogsListResponse, *http.Response, error) {
+// snapshot builds a progress report from the running totals
+func (f *Fetcher) snapshot(fetched int, cursor string, started time.Time, done bool) Progress {
+	p := Progress{
+		Fetched:   fetched,
+		Written:   f.stats.Logs,
+		Filtering: len(f.config.Filters) > 0,
+		Pages:     f.stats.Pages,
+		Elapsed:   time.Since(started),
+		Cursor:    f.config.DisplayCursor(cursor),
+		Done:      done,
+
+		Duplicates: f.stats.Duplicates,
+		OutOfOrder: f.stats.OutOfOrder,
+	}
+	p.Transferred, p.Decoded = f.client.Transfer()
+	p.Page = f.pageBytes
+	p.Output, p.Format = f.written(), f.config.Format
+	return p
+}
+
+// written returns the bytes of output written so far, or 0 if the pages go
+// to a caller's writer
+func (f *Fetcher) written() int64 {
+	if f.output == nil {
+		return 0
+	}
+	return f.output.written(writer.Chunks(f.writer))
+}
+
+// saveState records the cursor to resume from in the state file, if any,
+// along with its window when the range is split into windows
+func (f *Fetcher) saveState(w *plan.Window, cursor string, complete bool) error {
+	if f.config.StatePath == "" {
+		return nil
+	}
+	s := f.config.NewState()
+	if f.planner != nil && !complete {
+		s.Window = w
+	}
+	s.Cursor = cursor
+	if cursor != "" {
+		s.Last = f.last
+	}
+	s.Logs = f.stats.Logs
+	s.Pages = f.stats.Pages
+	if f.config.PageOutput() {
+		// Pages are committed to the file whole, so its size is where the
+		// logs counted end
+		if info, err := os.Stat(f.config.OutputPath); err == nil {
+			s.Size = info.Size()
+		}
+	}
+	s.Complete = complete
+	if f.config.Incremental {
+		// The watermark only moves once the run is complete; until then a
+		// resumed run still has to skip what the last run exported
+		s.Watermark = f.config.Watermark
+		s.Pending = f.pending
+		if complete {
+			if f.pending != nil {
+				s.Watermark = f.pending
+			}
+			s.Pending = nil
+		}
+	}
+	s.UpdatedAt = time.Now().UTC()
+	if err := s.Write(f.config.StatePath); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// trimOutput cuts the file at path back to size, returning how many bytes
+// were dropped. A file already no bigger is left alone.
+func trimOutput(path string, size int64) (int64, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() || info.Size() <= size {
+		return 0, nil
+	}
+	if err := os.Truncate(path, size); err != nil {
+		return 0, err
+	}
+	return info.Size() - size, nil
+}
+
+// fetchPageWithRetry fetches a single page with retry logic; with --raw it
+// also returns the JSON record of each of the page's logs
+func (f *Fetcher) fetchPageWithRetry(ctx context.Context, w plan.Window, cursor string) (datadogV2.LogsListResponse, []json.RawMessage, error) {
 	var resp datadogV2.LogsListResponse
+	var records []json.RawMessage
 	var httpResp *http.Response
 	var err error
 
 	attempt := 0
 	for {
-		resp, httpResp, err = f.fetchPage(ctx, cursor)
+		resp, records, httpResp, err = f.fetchPage(ctx, w, cursor)
 
 		retryErr := ClassifyError(err, httpResp)
 		if retryErr == nil {
-			// Success
-			return resp, httpResp, nil
+			return resp, records, nil
 		}
 
 		shouldRetry, backoff := ShouldRetry(attempt, retryErr)
 		if !shouldRetry {
-			return resp, httpResp, FormatRetryError(err, httpResp)
+			return resp, nil, &RequestError{
+				Err:        FormatRetryError(err, httpResp),
+				StatusCode: statusCode(httpResp),
+				Attempts:   attempt + 1,
+				Page:       f.stats.Pages + 1,
+				Cursor:     f.config.DisplayCursor(cursor),
+			}
 		}
 
 		attempt++
-		fmt.Fprintf(f.errOut, "Error (attempt %d/%d): %v - retrying in %v...\n", attempt, maxRetries, err, backoff)
+		f.diagnostics.Diagnostic(Diagnostic{
+			Kind:        DiagnosticRetry,
+			Message:     fmt.Sprintf("Error (attempt %d/%d): %v - retrying in %v...", attempt, maxRetries, err, backoff),
+			Err:         err,
+			Attempt:     attempt,
+			MaxAttempts: maxRetries,
+			Backoff:     backoff,
+			RateLimited: retryErr.RetryAfter > 0,
+			StatusCode:  statusCode(httpResp),
+			Page:        f.stats.Pages + 1,
+			Cursor:      f.config.DisplayCursor(cursor),
+		})
 
 		select {
 		case <-ctx.Done():
-			return resp, httpResp, ctx.Err()
+			return resp, nil, ctx.Err()
 		case <-time.After(backoff):
 			// Continue to retry
 		}
 	}
 }
 
-// fetchPage fetches a single page from the API
-func (f *Fetcher) fetchPage(ctx context.Context, cursor string) (datadogV2.LogsListResponse, *http.Response, error) {
+// fetchPage fetches a single page of window w from the API
+func (f *Fetcher) fetchPage(ctx context.Context, w plan.Window, cursor string) (datadogV2.LogsListResponse, []json.RawMessage, *http.Response, error) {
 	// Add API keys to context
 	ctx = f.client.GetContext(ctx)
 
+	if f.config.Method == "post" {
+		return f.client.listLogs(ctx, f.searchRequest(w, cursor), f.config.Raw)
+	}
+
 	// Build a single optional parameters struct
 	opts := datadogV2.ListLogsGetOptionalParameters{}
 
@@ -159,18 +729,23 @@ func (f *Fetcher) fetchPage(ctx context.Context, cursor string) (datadogV2.LogsL
 	}
 
 	// Index
-	if f.config.Index != "" {
-		indexes := []string{f.config.Index}
+	if index := cmp.Or(w.Index, f.config.Index); index != "" {
+		indexes := []string{index}
 		opts.FilterIndexes = &indexes
 	}
 
 	// Time range
-	if !f.config.From.IsZero() {
-		opts.FilterFrom = &f.config.From
+	if !w.From.IsZero() {
+		opts.FilterFrom = &w.From
+	}
+
+	if !w.To.IsZero() {
+		opts.FilterTo = &w.To
 	}
 
-	if !f.config.To.IsZero() {
-		opts.FilterTo = &f.config.To
+	// Windows are fetched oldest first, so pages must be too
+	if f.planner != nil {
+		opts.Sort = datadogV2.LOGSSORT_TIMESTAMP_ASCENDING.Ptr()
 	}
 
 	// Page size
@@ -181,11 +756,42 @@ func (f *Fetcher) fetchPage(ctx context.Context, cursor string) (datadogV2.LogsL
 		opts.PageCursor = &cursor
 	}
 
-	return f.client.GetAPI().ListLogsGet(ctx, opts)
+	return f.client.listLogsGet(ctx, opts, f.config.Raw)
+}
+
+// searchRequest builds the body of a POST search for a single page, with the
+// same parameters the GET variant puts in the URL
+func (f *Fetcher) searchRequest(w plan.Window, cursor string) *datadogV2.LogsListRequest {
+	query := datadogV2.LogsQueryFilter{}
+	if f.config.Query != "" {
+		query.Query = &f.config.Query
+	}
+	if index := cmp.Or(w.Index, f.config.Index); index != "" {
+		query.Indexes = []string{index}
+	}
+	if !w.From.IsZero() {
+		from := strconv.FormatInt(w.From.UnixMilli(), 10)
+		query.From = &from
+	}
+	if !w.To.IsZero() {
+		to := strconv.FormatInt(w.To.UnixMilli(), 10)
+		query.To = &to
+	}
+
+	page := datadogV2.LogsListRequestPage{Limit: &f.config.PageSize}
+	if cursor != "" {
+		page.Cursor = &cursor
+	}
+
+	req := &datadogV2.LogsListRequest{Filter: &query, Page: &page}
+	if f.planner != nil {
+		req.Sort = datadogV2.LOGSSORT_TIMESTAMP_ASCENDING.Ptr()
+	}
+	return req
 }
 
-// formatToTime formats the "to" time for display
-func formatToTime(t time.Time) string {
+// FormatToTime formats the "to" time for display, "now" when it is open
+func FormatToTime(t time.Time) string {
 	if t.IsZero() {
 		return "now"
 	}
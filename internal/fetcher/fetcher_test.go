@@ -4,8 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +21,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/jtzemp/dogfetch/internal/config"
+	"github.com/jtzemp/dogfetch/internal/filter"
+	"github.com/jtzemp/dogfetch/internal/grok"
+	"github.com/jtzemp/dogfetch/internal/plan"
+	"github.com/jtzemp/dogfetch/internal/redact"
+	"github.com/jtzemp/dogfetch/internal/state"
 )
 
 func TestFetcherWithMockAPI(t *testing.T) {
@@ -73,7 +85,7 @@ func TestFormatToTime(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := formatToTime(tt.t)
+			got := FormatToTime(tt.t)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -143,7 +155,8 @@ func TestFetcherErrorOutput(t *testing.T) {
 			require.NotNil(t, fetcher)
 
 			if tt.errOut != nil {
-				assert.Equal(t, tt.errOut, fetcher.errOut)
+				assert.Equal(t, NewTextReporter(tt.errOut), fetcher.progress)
+				assert.Equal(t, NewTextReporter(tt.errOut), fetcher.diagnostics)
 			}
 		})
 	}
@@ -325,6 +338,571 @@ func TestJSONOutputFormat(t *testing.T) {
 	assert.Equal(t, "json", fetcher.config.Format)
 }
 
+func TestNewWithWriter(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "first"), createMockLog("log-2", "second")},
+		[]datadogV2.Log{createMockLog("log-3", "third")},
+	)
+
+	cfg := newTestConfig("")
+	cfg.APIURL = server.URL
+	w := &pageRecorder{}
+	f, err := NewWithWriter(cfg, w, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []int{2, 1}, w.pages)
+	assert.True(t, w.finalized)
+	assert.True(t, w.closed)
+}
+
+func TestFetchWithPostMethod(t *testing.T) {
+	var requests []datadogV2.LogsListRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v2/logs/events/search", r.URL.Path)
+
+		var body datadogV2.LogsListRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+
+		response := datadogV2.LogsListResponse{Data: []datadogV2.Log{createMockLog("log-2", "second")}}
+		if body.GetPage().Cursor == nil {
+			response.Data = []datadogV2.Log{createMockLog("log-1", "first")}
+			response.Meta = &datadogV2.LogsResponseMetadata{
+				Page: &datadogV2.LogsResponseMetadataPage{After: strPtr("page-1")},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(output)
+	cfg.APIURL = server.URL
+	cfg.Method = "post"
+	cfg.From = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+	assert.Equal(t, 2, f.Stats().Logs)
+
+	require.Len(t, requests, 2)
+	query := requests[0].GetFilter()
+	assert.Equal(t, "service:test", query.GetQuery())
+	assert.Equal(t, "1704067200000", query.GetFrom())
+	first, second := requests[0].GetPage(), requests[1].GetPage()
+	assert.Equal(t, cfg.PageSize, first.GetLimit())
+	assert.Equal(t, "page-1", second.GetCursor())
+}
+
+func TestFetchDemo(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "demo.ndjson"))
+	cfg.APIKey, cfg.AppKey = "", ""
+	cfg.Demo = 2500
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+	assert.Equal(t, 2500, f.Stats().Logs)
+	assert.Equal(t, 3, f.Stats().Pages)
+
+	data, err := os.ReadFile(cfg.OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, 2500, bytes.Count(data, []byte("\n")))
+	assert.Contains(t, string(data), `"source:synth"`)
+}
+
+func TestFetchRedacts(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "card 4111111111111111 declined")},
+	)
+
+	output := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(output)
+	cfg.APIURL = server.URL
+	rule, err := redact.ParseRule(`message:/\b\d{16}\b/=[PAN]`)
+	require.NoError(t, err)
+	cfg.Redactions = []redact.Rule{rule}
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "card [PAN] declined")
+	assert.NotContains(t, string(data), "4111111111111111")
+}
+
+func TestFetchFilters(t *testing.T) {
+	slow := createMockLog("log-1", "slow")
+	slow.Attributes.Attributes = map[string]interface{}{"duration": 900.0}
+	fast := createMockLog("log-2", "fast")
+	fast.Attributes.Attributes = map[string]interface{}{"duration": 20.0}
+	// The whole second page is dropped, which must not end the fetch
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{slow, fast},
+		[]datadogV2.Log{createMockLog("log-3", "no duration")},
+		[]datadogV2.Log{createMockLog("log-4", "slow too")},
+	)
+
+	output := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(output)
+	cfg.APIURL = server.URL
+	expr, err := filter.Parse(`attributes.duration > 500 || message =~ "too"`)
+	require.NoError(t, err)
+	cfg.Filters = []*filter.Filter{expr}
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"log-1"`)
+	assert.Contains(t, string(data), `"log-4"`)
+	assert.NotContains(t, string(data), `"log-2"`)
+	assert.NotContains(t, string(data), `"log-3"`)
+	assert.Equal(t, 2, f.Stats().Logs)
+	assert.Equal(t, 2, f.Stats().Filtered)
+	assert.Equal(t, 3, f.Stats().Pages)
+}
+
+func TestFetchParsesBeforeFiltering(t *testing.T) {
+	server := newMockLogsServer(t, []datadogV2.Log{
+		createMockLog("log-1", "GET /cart took 812ms"),
+		createMockLog("log-2", "GET /health took 2ms"),
+		createMockLog("log-3", "shutting down"),
+	})
+
+	output := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(output)
+	cfg.APIURL = server.URL
+	pattern, err := grok.Compile(`%{WORD:http.method} %{URIPATH:http.url} took %{INT:duration:int}ms`)
+	require.NoError(t, err)
+	cfg.ParsePatterns = []*grok.Pattern{pattern}
+	expr, err := filter.Parse(`duration > 500`)
+	require.NoError(t, err)
+	cfg.Filters = []*filter.Filter{expr}
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	var log datadogV2.Log
+	require.NoError(t, json.Unmarshal(bytes.TrimSpace(data), &log))
+	assert.Equal(t, "log-1", log.GetId())
+	assert.Equal(t, 812.0, log.Attributes.Attributes["duration"])
+	assert.Equal(t, map[string]interface{}{"method": "GET", "url": "/cart"}, log.Attributes.Attributes["http"])
+}
+
+// recordingReporter collects everything a fetch reports
+type recordingReporter struct {
+	progress    []Progress
+	diagnostics []Diagnostic
+}
+
+func (r *recordingReporter) Progress(p Progress)     { r.progress = append(r.progress, p) }
+func (r *recordingReporter) Diagnostic(d Diagnostic) { r.diagnostics = append(r.diagnostics, d) }
+
+func TestFetchReporters(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one"), createMockLog("log-2", "two")},
+		[]datadogV2.Log{createMockLog("log-3", "three")},
+	)
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	var r recordingReporter
+	f.SetProgressReporter(&r)
+	f.SetDiagnosticReporter(&r)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Empty(t, errOut.String(), "nothing should be written as text")
+	require.Len(t, r.diagnostics, 1)
+	assert.Equal(t, DiagnosticStart, r.diagnostics[0].Kind)
+	assert.Contains(t, r.diagnostics[0].Message, "Starting fetch with query: "+cfg.Query)
+
+	require.Len(t, r.progress, 3)
+	assert.Equal(t, 2, r.progress[0].Fetched)
+	assert.Equal(t, "page-1", r.progress[0].Cursor)
+	assert.False(t, r.progress[0].Done)
+	done := r.progress[2]
+	assert.True(t, done.Done)
+	assert.Equal(t, 3, done.Fetched)
+	assert.Equal(t, 3, done.Written)
+	assert.Equal(t, 2, done.Pages)
+}
+
+func TestFetchReportsCancellation(t *testing.T) {
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.Cursor = "resume-here"
+	f, err := New(cfg, io.Discard)
+	require.NoError(t, err)
+	var r recordingReporter
+	f.SetDiagnosticReporter(&r)
+	f.SetProgressReporter(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, f.Fetch(ctx))
+
+	require.Len(t, r.diagnostics, 2)
+	assert.Equal(t, DiagnosticCancelled, r.diagnostics[1].Kind)
+	assert.Equal(t, "resume-here", r.diagnostics[1].Cursor)
+}
+
+func TestFetchHidesCursorsAndSavesState(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one")},
+		[]datadogV2.Log{createMockLog("log-2", "two")},
+	)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.CursorDisplay = "hash"
+	cfg.StatePath = filepath.Join(dir, "state.json")
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.NotContains(t, errOut.String(), "page-1")
+	assert.Contains(t, errOut.String(), "cursor: "+cfg.DisplayCursor("page-1"))
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.True(t, s.Complete)
+	assert.Equal(t, "", s.Cursor)
+	assert.Equal(t, 2, s.Logs)
+	assert.Equal(t, cfg.Query, s.Query)
+}
+
+func TestFetchSavesOutputSize(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one")},
+		[]datadogV2.Log{createMockLog("log-2", "two")},
+	)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.StatePath = filepath.Join(dir, "state.json")
+
+	f, err := New(cfg, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	info, err := os.Stat(cfg.OutputPath)
+	require.NoError(t, err)
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), s.Size)
+}
+
+func TestResumeTrimsOutputWrittenAfterState(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.StatePath = filepath.Join(dir, "state.json")
+	cfg.Append = true
+	cfg.Cursor = "page-1"
+	cfg.CursorSize = int64(len("{\"id\":\"log-1\"}\n"))
+	require.NoError(t, os.WriteFile(cfg.OutputPath, []byte("{\"id\":\"log-1\"}\n{\"id\":\"log-2\"}\n{\"id\""), 0o644))
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	require.NoError(t, f.writer.Close())
+
+	data, err := os.ReadFile(cfg.OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"log-1\"}\n", string(data))
+	assert.Contains(t, errOut.String(), "Dropped 20 bytes written to "+cfg.OutputPath)
+}
+
+func TestFetchInWindows(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []windowRequest
+	server := newWindowedLogsServer(t, base, &requests)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = base
+	cfg.To = base.Add(15 * time.Hour)
+	cfg.Window = 6 * time.Hour
+	cfg.StatePath = filepath.Join(dir, "state.json")
+
+	f, err := New(cfg, io.Discard)
+	require.NoError(t, err)
+	var r recordingReporter
+	f.SetDiagnosticReporter(&r)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []windowRequest{
+		{from: base, to: base.Add(6 * time.Hour), sort: "timestamp"},
+		{from: base, to: base.Add(6 * time.Hour), sort: "timestamp", cursor: "next"},
+		{from: base.Add(6 * time.Hour), to: base.Add(12 * time.Hour), sort: "timestamp"},
+		{from: base.Add(12 * time.Hour), to: base.Add(15 * time.Hour), sort: "timestamp"},
+	}, requests)
+	assert.Equal(t, 4, f.Stats().Logs)
+	assert.Equal(t, 4, f.Stats().Pages)
+
+	var windows []string
+	for _, d := range r.diagnostics {
+		if d.Kind == DiagnosticWindow {
+			windows = append(windows, d.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		"Window 1/3: 2024-01-01T00:00:00Z to 2024-01-01T06:00:00Z",
+		"Window 2/3: 2024-01-01T06:00:00Z to 2024-01-01T12:00:00Z",
+		"Window 3/3: 2024-01-01T12:00:00Z to 2024-01-01T15:00:00Z",
+	}, windows)
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.True(t, s.Complete)
+	assert.Nil(t, s.Window)
+}
+
+func TestFetchResumesWindow(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []windowRequest
+	server := newWindowedLogsServer(t, base, &requests)
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = base
+	cfg.To = base.Add(15 * time.Hour)
+	cfg.Window = 6 * time.Hour
+	cfg.Cursor = "resume-here"
+	cfg.CursorWindow = &plan.Window{From: base.Add(6 * time.Hour), To: base.Add(12 * time.Hour)}
+
+	f, err := New(cfg, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []windowRequest{
+		{from: base.Add(6 * time.Hour), to: base.Add(12 * time.Hour), sort: "timestamp", cursor: "resume-here"},
+		{from: base.Add(12 * time.Hour), to: base.Add(15 * time.Hour), sort: "timestamp"},
+	}, requests)
+}
+
+func TestFetchBalancedWindows(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []windowRequest
+	logs := newWindowedLogsServer(t, time.Time{}, &requests)
+
+	// Most of the traffic falls in the second hour
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/logs/analytics/aggregate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"buckets":[{"by":{},"computes":{"c0":[
+			{"time":"2024-01-01T00:00:00Z","value":10},
+			{"time":"2024-01-01T01:00:00Z","value":900},
+			{"time":"2024-01-01T02:00:00Z","value":10},
+			{"time":"2024-01-01T03:00:00Z","value":10}
+		]}}]}}`))
+	})
+	mux.Handle("/", logs.Config.Handler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = base
+	cfg.To = base.Add(4 * time.Hour)
+	cfg.Plan = "balanced"
+	cfg.WindowLogs = 100
+
+	f, err := New(cfg, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []windowRequest{
+		{from: base, to: base.Add(time.Hour), sort: "timestamp"},
+		{from: base.Add(time.Hour), to: base.Add(2 * time.Hour), sort: "timestamp"},
+		{from: base.Add(2 * time.Hour), to: base.Add(4 * time.Hour), sort: "timestamp"},
+	}, requests)
+}
+
+func TestFetchIndexWindows(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []windowRequest
+	logs := newWindowedLogsServer(t, time.Time{}, &requests)
+
+	// main is busy in the second hour, audit quiet throughout
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/logs/analytics/aggregate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"buckets":[
+			{"by":{"index":"main"},"computes":{"c0":[
+				{"time":"2024-01-01T00:00:00Z","value":10},
+				{"time":"2024-01-01T01:00:00Z","value":900},
+				{"time":"2024-01-01T02:00:00Z","value":10}
+			]}},
+			{"by":{"index":"audit"},"computes":{"c0":[
+				{"time":"2024-01-01T01:00:00Z","value":5}
+			]}}
+		]}}`))
+	})
+	var indexes []string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		indexes = append(indexes, r.URL.Query().Get("filter[indexes]"))
+		logs.Config.Handler.ServeHTTP(w, r)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.Index = ""
+	cfg.From = base
+	cfg.To = base.Add(3 * time.Hour)
+	cfg.Plan = "indexes"
+	cfg.WindowLogs = 100
+
+	f, err := New(cfg, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []windowRequest{
+		{from: base, to: base.Add(3 * time.Hour), sort: "timestamp"},
+		{from: base, to: base.Add(time.Hour), sort: "timestamp"},
+		{from: base.Add(time.Hour), to: base.Add(2 * time.Hour), sort: "timestamp"},
+		{from: base.Add(2 * time.Hour), to: base.Add(3 * time.Hour), sort: "timestamp"},
+	}, requests)
+	assert.Equal(t, []string{"audit", "main", "main", "main"}, indexes)
+
+	// Resumed in main's second window, audit is done and so is main's first
+	requests, indexes = nil, nil
+	cfg.Cursor = "resume-here"
+	cfg.CursorWindow = &plan.Window{From: base.Add(time.Hour), To: base.Add(2 * time.Hour), Index: "main"}
+	f, err = New(cfg, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, []windowRequest{
+		{from: base.Add(time.Hour), to: base.Add(2 * time.Hour), sort: "timestamp", cursor: "resume-here"},
+		{from: base.Add(2 * time.Hour), to: base.Add(3 * time.Hour), sort: "timestamp"},
+	}, requests)
+	assert.Equal(t, []string{"main", "main"}, indexes)
+}
+
+func TestFetchSavesNextWindowWhenCancelled(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests []windowRequest
+	server := newWindowedLogsServer(t, base, &requests)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = base
+	cfg.To = base.Add(15 * time.Hour)
+	cfg.Window = 6 * time.Hour
+	cfg.StatePath = filepath.Join(dir, "state.json")
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+
+	// Cancel once the first window's two pages are written
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.AddObserver(observerFunc(func([]datadogV2.Log) {
+		if len(requests) == 2 {
+			cancel()
+		}
+	}))
+	require.NoError(t, f.Fetch(ctx))
+
+	assert.Len(t, requests, 2)
+	assert.Contains(t, errOut.String(), "resume with --resume --state-file "+cfg.StatePath)
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.False(t, s.Complete)
+	assert.Equal(t, "", s.Cursor)
+	assert.Equal(t, &plan.Window{From: base.Add(6 * time.Hour), To: base.Add(12 * time.Hour)}, s.Window)
+}
+
+// observerFunc adapts a function to the Observer interface
+type observerFunc func(logs []datadogV2.Log)
+
+func (o observerFunc) Observe(logs []datadogV2.Log) { o(logs) }
+
+func TestFetchSavesStateWhenCancelled(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.Cursor = "resume-here"
+	cfg.CursorDisplay = "truncate"
+	cfg.StatePath = filepath.Join(dir, "state.json")
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, f.Fetch(ctx))
+
+	assert.NotContains(t, errOut.String(), "resume-here")
+	assert.Contains(t, errOut.String(), "resume with --resume --state-file "+cfg.StatePath)
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.False(t, s.Complete)
+	assert.Equal(t, "resume-here", s.Cursor)
+}
+
+func TestFetchSavesResumeFileWhenCancelledMidRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pages := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one")},
+		[]datadogV2.Log{createMockLog("log-2", "two")},
+	)
+	// The second page's request is interrupted before it is answered
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page[cursor]") == "page-1" {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		pages.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(ctx))
+
+	resume := cfg.OutputPath + ".resume"
+	assert.Equal(t, resume, cfg.StatePath)
+	assert.Contains(t, errOut.String(), "saved the state to "+resume+"; resume with --resume --state-file "+resume)
+
+	s, err := state.Read(resume)
+	require.NoError(t, err)
+	assert.False(t, s.Complete)
+	assert.Equal(t, "page-1", s.Cursor)
+	assert.Equal(t, 1, s.Logs)
+	assert.Equal(t, cfg.OutputPath, s.Output)
+}
+
 // Helper functions
 
 func createMockLog(id, message string) datadogV2.Log {
@@ -339,6 +917,153 @@ func createMockLog(id, message string) datadogV2.Log {
 	}
 }
 
+// windowRequest is what a windowed fetch asked the mock API for
+type windowRequest struct {
+	from, to time.Time
+	sort     string
+	cursor   string
+}
+
+// newWindowedLogsServer serves one log per page and records each request;
+// the first page of the window starting at paged has a second page after it
+func newWindowedLogsServer(t *testing.T, paged time.Time, requests *[]windowRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, err := time.Parse(time.RFC3339Nano, q.Get("filter[from]"))
+		require.NoError(t, err)
+		to, err := time.Parse(time.RFC3339Nano, q.Get("filter[to]"))
+		require.NoError(t, err)
+		req := windowRequest{from: from, to: to, sort: q.Get("sort"), cursor: q.Get("page[cursor]")}
+		*requests = append(*requests, req)
+
+		response := datadogV2.LogsListResponse{
+			Data: []datadogV2.Log{createMockLog(fmt.Sprintf("log-%d", len(*requests)), "message")},
+		}
+		if from.Equal(paged) && req.cursor == "" {
+			response.Meta = &datadogV2.LogsResponseMetadata{
+				Page: &datadogV2.LogsResponseMetadataPage{After: strPtr("next")},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newMockLogsServer serves the given pages from a fake Logs API, chaining
+// them together with "page-N" cursors
+// closingPipe accepts one write, then fails as a pipe whose reader exited
+type closingPipe struct {
+	bytes.Buffer
+	writes int
+}
+
+func (p *closingPipe) Write(b []byte) (int, error) {
+	p.writes++
+	if p.writes > 1 {
+		return 0, errors.New("write |1: broken pipe")
+	}
+	return p.Buffer.Write(b)
+}
+
+func TestFetchTee(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one")},
+		[]datadogV2.Log{createMockLog("log-2", "two")},
+	)
+
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(path)
+	cfg.APIURL = server.URL
+	cfg.Tee = true
+	stdout := &closingPipe{}
+	f, err := NewWithStdout(cfg, stdout, &bytes.Buffer{})
+	require.NoError(t, err)
+	reporter := &recordingReporter{}
+	f.SetDiagnosticReporter(reporter)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	// stdout got the first page before its reader went away; the file got both
+	assert.Equal(t, 1, strings.Count(stdout.String(), "\n"))
+	assert.Equal(t, []string{"log-1", "log-2"}, writtenIDs(t, path))
+
+	var stopped []Diagnostic
+	for _, d := range reporter.diagnostics {
+		if d.Kind == DiagnosticTeeStopped {
+			stopped = append(stopped, d)
+		}
+	}
+	require.Len(t, stopped, 1)
+	assert.Contains(t, stopped[0].Message, "broken pipe; still writing to "+path)
+}
+
+func newMockLogsServer(t *testing.T, pages ...[]datadogV2.Log) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := 0
+		if cursor := r.URL.Query().Get("page[cursor]"); cursor != "" {
+			n, err := strconv.Atoi(cursor[len("page-"):])
+			require.NoError(t, err)
+			index = n
+		}
+
+		response := datadogV2.LogsListResponse{Data: []datadogV2.Log{}}
+		if index < len(pages) {
+			response.Data = pages[index]
+		}
+		if index+1 < len(pages) {
+			response.Meta = &datadogV2.LogsResponseMetadata{
+				Page: &datadogV2.LogsResponseMetadataPage{
+					After: strPtr(fmt.Sprintf("page-%d", index+1)),
+				},
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestConfig returns a valid ndjson config writing to path
+func newTestConfig(path string) *config.Config {
+	return &config.Config{
+		Query:      "service:test",
+		Index:      "main",
+		PageSize:   1000,
+		Format:     "ndjson",
+		OutputPath: path,
+		APIKey:     "test-key",
+		AppKey:     "test-app-key",
+		From:       time.Now().Add(-1 * time.Hour),
+	}
+}
+
+// pageRecorder is a writer.Writer that records page sizes
+type pageRecorder struct {
+	pages     []int
+	finalized bool
+	closed    bool
+}
+
+func (w *pageRecorder) WritePage(logs []datadogV2.Log) error {
+	w.pages = append(w.pages, len(logs))
+	return nil
+}
+
+func (w *pageRecorder) Finalize() error {
+	w.finalized = true
+	return nil
+}
+
+func (w *pageRecorder) Close() error {
+	w.closed = true
+	return nil
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -0,0 +1,44 @@
+package fetcher
+
+import (
+	"slices"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+// skipExported drops the logs an earlier incremental run exported at its
+// watermark, which this run's range starts at and so fetches again
+func skipExported(w *state.Watermark, logs []datadogV2.Log) []datadogV2.Log {
+	if w == nil || len(w.IDs) == 0 {
+		return logs
+	}
+	kept := logs[:0]
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		if attrs.GetTimestamp().Equal(w.Timestamp) && slices.Contains(w.IDs, log.GetId()) {
+			continue
+		}
+		kept = append(kept, log)
+	}
+	return kept
+}
+
+// advanceWatermark moves w up to the newest of logs, collecting the IDs of
+// every log at that timestamp
+func advanceWatermark(w *state.Watermark, logs []datadogV2.Log) *state.Watermark {
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		ts, ok := attrs.GetTimestampOk()
+		if !ok {
+			continue
+		}
+		switch {
+		case w == nil || ts.After(w.Timestamp):
+			w = &state.Watermark{Timestamp: ts.UTC(), IDs: []string{log.GetId()}}
+		case ts.Equal(w.Timestamp) && !slices.Contains(w.IDs, log.GetId()):
+			w.IDs = append(w.IDs, log.GetId())
+		}
+	}
+	return w
+}
@@ -0,0 +1,106 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/state"
+)
+
+// logAt returns a mock log with a fixed timestamp
+func logAt(id string, ts time.Time) datadogV2.Log {
+	log := createMockLog(id, "message "+id)
+	log.Attributes.Timestamp = &ts
+	return log
+}
+
+func TestAdvanceWatermark(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	w := advanceWatermark(nil, []datadogV2.Log{logAt("a", t0), logAt("b", t0.Add(time.Second)), logAt("c", t0.Add(time.Second))})
+	assert.Equal(t, &state.Watermark{Timestamp: t0.Add(time.Second), IDs: []string{"b", "c"}}, w)
+
+	// Older logs leave it alone and repeats aren't added twice
+	w = advanceWatermark(w, []datadogV2.Log{logAt("a", t0), logAt("c", t0.Add(time.Second)), logAt("d", t0.Add(time.Second))})
+	assert.Equal(t, &state.Watermark{Timestamp: t0.Add(time.Second), IDs: []string{"b", "c", "d"}}, w)
+
+	w = advanceWatermark(w, []datadogV2.Log{logAt("e", t0.Add(time.Minute))})
+	assert.Equal(t, &state.Watermark{Timestamp: t0.Add(time.Minute), IDs: []string{"e"}}, w)
+}
+
+func TestSkipExported(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logs := []datadogV2.Log{logAt("a", t0), logAt("b", t0), logAt("c", t0.Add(time.Second))}
+
+	assert.Len(t, skipExported(nil, logs), 3)
+
+	kept := skipExported(&state.Watermark{Timestamp: t0, IDs: []string{"a", "c"}}, logs)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "b", kept[0].GetId())
+	assert.Equal(t, "c", kept[1].GetId(), "only logs at the watermark are skipped")
+}
+
+func TestFetchIncremental(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{logAt("old", t0), logAt("new-1", t0)},
+		[]datadogV2.Log{logAt("new-2", t0.Add(time.Minute))},
+	)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = t0
+	cfg.To = t0.Add(time.Hour)
+	cfg.StatePath = filepath.Join(dir, "state.json")
+	cfg.Incremental = true
+	cfg.Watermark = &state.Watermark{Timestamp: t0, IDs: []string{"old"}}
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+	assert.Equal(t, 2, f.Stats().Logs, "the log exported by the last run is skipped")
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.True(t, s.Complete)
+	assert.Nil(t, s.Pending)
+	assert.Equal(t, &state.Watermark{Timestamp: t0.Add(time.Minute), IDs: []string{"new-2"}}, s.Watermark)
+}
+
+func TestFetchIncrementalKeepsWatermarkWhenCancelled(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{logAt("new-1", t0.Add(time.Second))},
+		[]datadogV2.Log{logAt("new-2", t0.Add(time.Minute))},
+	)
+
+	dir := t.TempDir()
+	cfg := newTestConfig(filepath.Join(dir, "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.From = t0
+	cfg.To = t0.Add(time.Hour)
+	cfg.StatePath = filepath.Join(dir, "state.json")
+	cfg.Incremental = true
+	cfg.Watermark = &state.Watermark{Timestamp: t0, IDs: []string{"old"}}
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f.AddObserver(observerFunc(func([]datadogV2.Log) { cancel() }))
+	require.NoError(t, f.Fetch(ctx))
+
+	s, err := state.Read(cfg.StatePath)
+	require.NoError(t, err)
+	assert.False(t, s.Complete)
+	assert.Equal(t, cfg.Watermark, s.Watermark, "the watermark only moves once the run completes")
+	assert.Equal(t, &state.Watermark{Timestamp: t0.Add(time.Second), IDs: []string{"new-1"}}, s.Pending)
+}
@@ -0,0 +1,39 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// Indexes lists the organization's log indexes in the order logs are routed
+// to them
+func (c *Client) Indexes(ctx context.Context) ([]datadogV1.LogsIndex, error) {
+	attempt := 0
+	for {
+		resp, httpResp, err := c.indexes.ListLogIndexes(c.GetContext(ctx))
+
+		retryErr := ClassifyError(err, httpResp)
+		if retryErr == nil {
+			return resp.Indexes, nil
+		}
+
+		shouldRetry, backoff := ShouldRetry(attempt, retryErr)
+		if !shouldRetry {
+			if statusCode(httpResp) == http.StatusForbidden {
+				return nil, errors.New("permission denied: listing indexes needs the logs_read_config permission")
+			}
+			return nil, FormatRetryError(err, httpResp)
+		}
+		attempt++
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIndexes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/logs/config/indexes", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"indexes":[{"name":"main","filter":{"query":"*"},"num_retention_days":15},{"name":"audit","filter":{"query":"source:audit"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	list, err := client.Indexes(context.Background())
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "main", list[0].GetName())
+	assert.Equal(t, int64(15), list[0].GetNumRetentionDays())
+	assert.Equal(t, "audit", list[1].GetName())
+}
+
+func TestClientIndexesForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	_, err := client.Indexes(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "logs_read_config")
+}
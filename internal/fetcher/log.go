@@ -0,0 +1,112 @@
+package fetcher
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// LogReporter reports progress and diagnostics as structured log records,
+// for --log-format json
+type LogReporter struct {
+	logger *slog.Logger
+}
+
+// NewLogReporter creates a reporter logging to logger
+func NewLogReporter(logger *slog.Logger) *LogReporter {
+	return &LogReporter{logger: logger}
+}
+
+// Progress logs a page, or the completed fetch
+func (r *LogReporter) Progress(p Progress) {
+	msg := "page fetched"
+	if p.Done {
+		msg = "fetch completed"
+	}
+	attrs := []slog.Attr{
+		slog.Int("fetched", p.Fetched),
+		slog.Int("pages", p.Pages),
+		slog.Float64("elapsed_seconds", p.Elapsed.Seconds()),
+		slog.Float64("logs_per_second", p.Rate()),
+	}
+	if p.Filtering {
+		attrs = append(attrs, slog.Int("written", p.Written))
+	}
+	if p.Transferred > 0 {
+		attrs = append(attrs, slog.Int64("bytes_transferred", p.Transferred), slog.Int64("page_bytes", p.Page))
+	}
+	if p.Output > 0 {
+		attrs = append(attrs, slog.Int64("bytes_written", p.Output))
+	}
+	if p.Duplicates > 0 || p.OutOfOrder > 0 {
+		attrs = append(attrs, slog.Int("duplicates", p.Duplicates), slog.Int("out_of_order", p.OutOfOrder))
+	}
+	if p.Cursor != "" {
+		attrs = append(attrs, slog.String("cursor", p.Cursor))
+	}
+	r.logger.LogAttrs(context.Background(), slog.LevelInfo, msg, attrs...)
+}
+
+// Diagnostic logs a diagnostic with its details as attributes
+func (r *LogReporter) Diagnostic(d Diagnostic) {
+	var msg string
+	var attrs []slog.Attr
+	switch d.Kind {
+	case DiagnosticStart:
+		msg = "fetch starting"
+		attrs = append(attrs,
+			slog.String("query", d.Query),
+			slog.Time("from", d.From.UTC()),
+			slog.Int("page_size", int(d.PageSize)))
+		if !d.To.IsZero() {
+			attrs = append(attrs, slog.Time("to", d.To.UTC()))
+		}
+	case DiagnosticRetry:
+		msg = "request failed; retrying"
+		attrs = append(attrs,
+			slog.String("error", errString(d.Err)),
+			slog.Int("attempt", d.Attempt),
+			slog.Int("max_attempts", d.MaxAttempts),
+			slog.Float64("backoff_seconds", d.Backoff.Round(time.Millisecond).Seconds()),
+			slog.Bool("rate_limited", d.RateLimited))
+	case DiagnosticCancelled:
+		msg = "fetch cancelled"
+		attrs = append(attrs, slog.String("cursor", d.Cursor), slog.String("hint", d.Message))
+	case DiagnosticSkipped:
+		msg = "window skipped"
+		attrs = append(attrs,
+			slog.String("error", errString(d.Err)),
+			slog.Int("page", d.Page),
+			slog.String("cursor", d.Cursor))
+		if d.Window != nil {
+			attrs = append(attrs, slog.Time("from", d.Window.From.UTC()), slog.Time("to", d.Window.To.UTC()))
+		}
+	case DiagnosticRestarted:
+		msg = "cursor expired; restarting"
+		attrs = append(attrs, slog.String("error", errString(d.Err)), slog.String("cursor", d.Cursor))
+		if d.Window != nil {
+			attrs = append(attrs, slog.Time("from", d.Window.From.UTC()), slog.Time("to", d.Window.To.UTC()))
+		}
+	case DiagnosticTeeStopped:
+		msg = "stopped copying to stdout"
+		attrs = append(attrs, slog.String("error", errString(d.Err)))
+	case DiagnosticExecRestarted:
+		msg = "output command restarted"
+		attrs = append(attrs, slog.String("error", errString(d.Err)))
+	case DiagnosticWindow:
+		msg = "fetching window"
+		if d.Window != nil {
+			attrs = append(attrs, slog.Time("from", d.Window.From.UTC()), slog.Time("to", d.Window.To.UTC()))
+		}
+	default:
+		msg = d.Message
+	}
+	r.logger.LogAttrs(context.Background(), d.Level(), msg, attrs...)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
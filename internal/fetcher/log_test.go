@@ -0,0 +1,84 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeRecords(t *testing.T, data []byte) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var record map[string]interface{}
+		require.NoError(t, dec.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewLogReporter(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.Diagnostic(Diagnostic{Kind: DiagnosticStart, Query: "service:web", From: from, PageSize: 1000})
+	r.Progress(Progress{Fetched: 1000, Written: 1000, Pages: 1, Elapsed: time.Second, Cursor: "abc"})
+	r.Diagnostic(Diagnostic{Kind: DiagnosticRetry, Err: errors.New("429 Too Many Requests"), Attempt: 1, MaxAttempts: 5, Backoff: 2 * time.Second, RateLimited: true})
+	r.Progress(Progress{Fetched: 1500, Written: 40, Filtering: true, Pages: 2, Elapsed: 2 * time.Second, Done: true})
+
+	records := decodeRecords(t, buf.Bytes())
+	require.Len(t, records, 4)
+
+	assert.Equal(t, "fetch starting", records[0]["msg"])
+	assert.Equal(t, "service:web", records[0]["query"])
+	assert.Equal(t, "2024-01-01T00:00:00Z", records[0]["from"])
+	assert.NotContains(t, records[0], "to")
+
+	assert.Equal(t, "page fetched", records[1]["msg"])
+	assert.Equal(t, 1000.0, records[1]["fetched"])
+	assert.Equal(t, "abc", records[1]["cursor"])
+	assert.NotContains(t, records[1], "written")
+
+	assert.Equal(t, "WARN", records[2]["level"])
+	assert.Equal(t, "429 Too Many Requests", records[2]["error"])
+	assert.Equal(t, 2.0, records[2]["backoff_seconds"])
+	assert.Equal(t, true, records[2]["rate_limited"])
+
+	assert.Equal(t, "fetch completed", records[3]["msg"])
+	assert.Equal(t, 40.0, records[3]["written"])
+}
+
+func TestFetchWritesNothingToStdout(t *testing.T) {
+	read, write, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = write
+	defer func() { os.Stdout = stdout }()
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "demo.ndjson"))
+	cfg.APIKey, cfg.AppKey = "", ""
+	cfg.Demo = 100
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	os.Stdout = stdout
+	write.Close()
+	leaked, err := io.ReadAll(read)
+	require.NoError(t, err)
+	assert.Empty(t, string(leaked))
+	assert.Contains(t, errOut.String(), "Completed!")
+}
@@ -0,0 +1,71 @@
+package fetcher
+
+import (
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// recentIDs is how many of the latest log IDs are remembered to spot
+// duplicates. Pagination repeats logs across nearby pages, so a bounded
+// window catches them without holding every ID of a long export.
+const recentIDs = 100_000
+
+// orderCheck counts logs the API sends twice or out of order: an ID among
+// the recent ones, or a timestamp behind the previous log's in the sort
+// order. Either points at a pagination problem on Datadog's side that
+// would otherwise only show up downstream.
+type orderCheck struct {
+	duplicates int
+	outOfOrder int
+
+	prev   time.Time           // timestamp of the previous log in the window
+	seen   map[string]struct{} // IDs in recent
+	recent []string            // ring of the latest IDs
+	next   int                 // where the next ID goes in recent
+}
+
+func newOrderCheck() *orderCheck {
+	return &orderCheck{seen: make(map[string]struct{})}
+}
+
+// window starts a new window, whose first log may go back in time
+func (c *orderCheck) window() {
+	c.prev = time.Time{}
+}
+
+// page counts the duplicates and regressions in a page fetched oldest
+// first if ascending, newest first otherwise
+func (c *orderCheck) page(logs []datadogV2.Log, ascending bool) {
+	for _, log := range logs {
+		if id := log.GetId(); id != "" {
+			if _, ok := c.seen[id]; ok {
+				c.duplicates++
+			} else {
+				c.remember(id)
+			}
+		}
+
+		attrs := log.GetAttributes()
+		ts, ok := attrs.GetTimestampOk()
+		if !ok {
+			continue
+		}
+		if !c.prev.IsZero() && (ascending && ts.Before(c.prev) || !ascending && ts.After(c.prev)) {
+			c.outOfOrder++
+		}
+		c.prev = *ts
+	}
+}
+
+// remember adds id to the recent IDs, forgetting the oldest once full
+func (c *orderCheck) remember(id string) {
+	if len(c.recent) < recentIDs {
+		c.recent = append(c.recent, id)
+	} else {
+		delete(c.seen, c.recent[c.next])
+		c.recent[c.next] = id
+		c.next = (c.next + 1) % recentIDs
+	}
+	c.seen[id] = struct{}{}
+}
@@ -0,0 +1,65 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderCheck(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Newest first: equal timestamps are fine, a newer one is a regression
+	c := newOrderCheck()
+	c.page([]datadogV2.Log{logAt("a", t0.Add(2*time.Second)), logAt("b", t0.Add(time.Second)), logAt("c", t0.Add(time.Second))}, false)
+	c.page([]datadogV2.Log{logAt("c", t0.Add(time.Second)), logAt("d", t0.Add(3*time.Second)), logAt("e", t0)}, false)
+	assert.Equal(t, 1, c.duplicates)
+	assert.Equal(t, 1, c.outOfOrder)
+
+	// Oldest first, and a new window may start anywhere
+	c = newOrderCheck()
+	c.page([]datadogV2.Log{logAt("a", t0), logAt("b", t0.Add(time.Second))}, true)
+	c.window()
+	c.page([]datadogV2.Log{logAt("c", t0.Add(-time.Hour)), logAt("d", t0.Add(-2*time.Hour))}, true)
+	assert.Equal(t, 0, c.duplicates)
+	assert.Equal(t, 1, c.outOfOrder)
+}
+
+func TestOrderCheckForgetsOldIDs(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newOrderCheck()
+	for i := range recentIDs + 1 {
+		c.remember(strconv.Itoa(i))
+	}
+	assert.Len(t, c.seen, recentIDs)
+
+	// The first ID has been forgotten, the second not
+	c.page([]datadogV2.Log{logAt("1", t0), logAt("0", t0)}, false)
+	assert.Equal(t, 1, c.duplicates)
+}
+
+func TestFetchCountsPaginationAnomalies(t *testing.T) {
+	t0 := time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond)
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{logAt("log-1", t0), logAt("log-2", t0.Add(-time.Second))},
+		[]datadogV2.Log{logAt("log-2", t0.Add(-time.Second)), logAt("log-3", t0.Add(time.Second))},
+	)
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, 1, f.Stats().Duplicates)
+	assert.Equal(t, 1, f.Stats().OutOfOrder)
+	assert.Contains(t, errOut.String(), "Pagination anomalies: 1 duplicate (25.00%), 1 out of order (25.00%)")
+}
@@ -0,0 +1,66 @@
+package fetcher
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/jtzemp/dogfetch/internal/writer"
+)
+
+// outputCounter measures the bytes of output a fetch writes: to stdout as
+// they pass through, and to files by how much they have grown. Files count
+// as their writers flush them, so formats written whole at the end, such as
+// json or xlsx, only count once finalized.
+type outputCounter struct {
+	stdout *countingWriter // nil if nothing goes to stdout
+	path   string          // output file, empty if there isn't one
+	atomic bool            // the file is written under a temporary name until finalized
+	start  int64           // size of the file before an appending fetch
+}
+
+// written returns the bytes written so far; chunks are the files --split
+// has written, which replace path
+func (c *outputCounter) written(chunks []writer.Chunk) int64 {
+	var n int64
+	if c.stdout != nil {
+		n = c.stdout.n.Load()
+	}
+	if c.path == "" {
+		return n
+	}
+	if len(chunks) > 0 {
+		for _, chunk := range chunks {
+			n += fileSize(chunk.Path)
+		}
+		return n
+	}
+	size := fileSize(c.path)
+	if c.atomic {
+		if tmp := fileSize(c.path + ".tmp"); tmp > 0 {
+			size = tmp
+		}
+	}
+	return n + max(size-c.start, 0)
+}
+
+// fileSize returns the size of the file at path, or 0 if there isn't one
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// countingWriter adds the bytes written through it to n
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
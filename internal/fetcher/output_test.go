@@ -0,0 +1,75 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/writer"
+)
+
+func TestOutputCounter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte("0123456789"), 0644))
+
+	// An appending fetch counts only what it added
+	c := &outputCounter{path: path, start: 10}
+	assert.Equal(t, int64(0), c.written(nil))
+	require.NoError(t, os.WriteFile(path, []byte("0123456789abcde"), 0644))
+	assert.Equal(t, int64(5), c.written(nil))
+
+	// Until finalized, an atomic output is the temporary file
+	c = &outputCounter{path: path, atomic: true}
+	require.NoError(t, os.WriteFile(path+".tmp", []byte("abc"), 0644))
+	assert.Equal(t, int64(3), c.written(nil))
+
+	// --split chunks replace the output file
+	chunk := filepath.Join(dir, "out-00001.ndjson")
+	require.NoError(t, os.WriteFile(chunk, []byte("abcdefg"), 0644))
+	assert.Equal(t, int64(7), c.written([]writer.Chunk{{Path: chunk, Records: 1}}))
+
+	// Stdout counts as it passes through
+	var buf bytes.Buffer
+	c = &outputCounter{stdout: &countingWriter{w: &buf}}
+	_, err := c.stdout.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), c.written(nil))
+}
+
+func TestFetchCountsBytes(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one"), createMockLog("log-2", "two")},
+		[]datadogV2.Log{createMockLog("log-3", "three")},
+	)
+	output := filepath.Join(t.TempDir(), "out.ndjson")
+	cfg := newTestConfig(output)
+	cfg.APIURL = server.URL
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	info, err := os.Stat(output)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), f.Stats().Output)
+	assert.Greater(t, f.Stats().Transferred, int64(0))
+	assert.Contains(t, errOut.String(), ", last page ")
+	assert.Contains(t, errOut.String(), " per page)\n")
+	assert.Contains(t, errOut.String(), "B of ndjson\n")
+
+	// Written to stdout instead
+	cfg.OutputPath = ""
+	var stdout bytes.Buffer
+	f, err = NewWithStdout(cfg, &stdout, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+	assert.Equal(t, int64(stdout.Len()), f.Stats().Output)
+}
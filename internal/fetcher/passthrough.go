@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"encoding/json"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// rawRecords returns the JSON the API sent for each of logs, given the data of
+// the page they came from and its records in the same order. When filters
+// left fewer logs than the page held, records are matched up by log ID; a
+// log whose record can't be found gets nil.
+func rawRecords(logs, data []datadogV2.Log, records []json.RawMessage) []json.RawMessage {
+	if len(records) == len(logs) {
+		return records
+	}
+	byID := make(map[string]json.RawMessage, len(records))
+	for i, log := range data {
+		if i < len(records) && log.GetId() != "" {
+			byID[log.GetId()] = records[i]
+		}
+	}
+	matched := make([]json.RawMessage, len(logs))
+	for i, log := range logs {
+		matched[i] = byID[log.GetId()]
+	}
+	return matched
+}
+
+// preserveLog puts the fields of a log that the API client doesn't model back
+// exactly as Datadog sent them in raw.
+// The client keeps fields it doesn't know in AdditionalProperties, or the
+// whole object in UnparsedObject when it can't decode it, but as decoded
+// values: large integers are rounded and numbers reformatted. Swapping in the
+// raw JSON writes them out byte for byte instead.
+func preserveLog(log *datadogV2.Log, raw json.RawMessage) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(raw, &fields) != nil {
+		return
+	}
+	if log.UnparsedObject != nil {
+		restoreRaw(log.UnparsedObject, fields)
+		return
+	}
+	restoreRaw(log.AdditionalProperties, fields)
+
+	if log.Attributes == nil {
+		return
+	}
+	var attrs map[string]json.RawMessage
+	if json.Unmarshal(fields["attributes"], &attrs) != nil {
+		return
+	}
+	if log.Attributes.UnparsedObject != nil {
+		restoreRaw(log.Attributes.UnparsedObject, attrs)
+		return
+	}
+	restoreRaw(log.Attributes.AdditionalProperties, attrs)
+}
+
+// restoreRaw swaps decoded values for the raw JSON they were decoded from
+func restoreRaw(values map[string]interface{}, raw map[string]json.RawMessage) {
+	for key := range values {
+		if r, ok := raw[key]; ok {
+			values[key] = r
+		}
+	}
+}
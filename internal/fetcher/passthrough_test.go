@@ -0,0 +1,113 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// driftedPage is a response with fields the API client doesn't model, at
+// every level of a log, holding values that don't survive decoding into
+// interface{}: large integers, trailing zeros and exponents
+const driftedPage = `{"data":[
+	{"id":"a","type":"log","new_top":{"ratio":1.50,"seq":12345678901234567890},
+	 "attributes":{"message":"m","timestamp":"2024-01-01T00:00:00.123Z","new_attr":[1e3,2.0],"attributes":{"env":"prod"}}},
+	{"id":"b","type":"archived_log","attributes":{"status":5,"id64":9007199254740993}}
+],"meta":{"page":{}}}`
+
+// rawFields decodes one level of a JSON object without touching its values
+func rawFields(t *testing.T, data []byte) map[string]json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &fields))
+	return fields
+}
+
+func TestFetchPreservesUnknownFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(driftedPage))
+	}))
+	defer server.Close()
+
+	for _, method := range []string{"get", "post"} {
+		t.Run(method, func(t *testing.T) {
+			cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+			cfg.APIURL = server.URL
+			cfg.Method = method
+
+			f, err := New(cfg, &bytes.Buffer{})
+			require.NoError(t, err)
+			require.NoError(t, f.Fetch(context.Background()))
+
+			data, err := os.ReadFile(cfg.OutputPath)
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			require.Len(t, lines, 2)
+
+			first := rawFields(t, []byte(lines[0]))
+			assert.Equal(t, `{"ratio":1.50,"seq":12345678901234567890}`, string(first["new_top"]))
+			attrs := rawFields(t, first["attributes"])
+			assert.Equal(t, `[1e3,2.0]`, string(attrs["new_attr"]))
+			assert.Equal(t, `"m"`, string(attrs["message"]))
+
+			// A log the client can't decode at all is kept whole
+			second := rawFields(t, []byte(lines[1]))
+			assert.Equal(t, `"archived_log"`, string(second["type"]))
+			assert.Equal(t, `{"status":5,"id64":9007199254740993}`, string(second["attributes"]))
+		})
+	}
+}
+
+func TestFetchRawWritesRecordsAsSent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(driftedPage))
+	}))
+	defer server.Close()
+
+	var page struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(driftedPage), &page))
+	var want []string
+	for _, record := range page.Data {
+		var line bytes.Buffer
+		require.NoError(t, json.Compact(&line, record))
+		want = append(want, line.String())
+	}
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.Raw = true
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	data, err := os.ReadFile(cfg.OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(want, "\n")+"\n", string(data))
+}
+
+func TestRawRecordsMatchesFilteredLogsByID(t *testing.T) {
+	body := `{"data":[{"id":"a","attributes":{"n":1.0}},{"id":"b","attributes":{"n":2.0}},{"id":"d","attributes":{"n":4.0}}]}`
+	page, records, err := decodeLogsPage(strings.NewReader(body), true)
+	require.NoError(t, err)
+	logs := []datadogV2.Log{createMockLog("b", "second"), createMockLog("c", "unknown")}
+
+	matched := rawRecords(logs, page.Data, records)
+	require.Len(t, matched, 2)
+	assert.Equal(t, `{"id":"b","attributes":{"n":2.0}}`, string(matched[0]))
+	assert.Nil(t, matched[1])
+}
@@ -0,0 +1,59 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// ProbeError is a probe that failed, with the status the API answered it
+// with, or 0 if it didn't
+type ProbeError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *ProbeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// Rejected reports whether the API refused the request itself, with a 4xx
+// other than a 429, rather than failing to answer it
+func (e *ProbeError) Rejected() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusTooManyRequests
+}
+
+// Probe asks the API for a single log of the query over the fetch's range,
+// without retrying, so a query the API rejects fails before anything is
+// written. A 400 is reported with the API's reasons.
+func (f *Fetcher) Probe(ctx context.Context) error {
+	cfg := *f.config
+	cfg.PageSize = 1
+	probe := &Fetcher{config: &cfg, client: f.client}
+	_, _, httpResp, err := probe.fetchPage(ctx, plan.Window{From: cfg.From, To: cfg.To}, "")
+	if err == nil {
+		return nil
+	}
+
+	probeErr := &ProbeError{Err: FormatRetryError(err, httpResp)}
+	if httpResp != nil {
+		probeErr.StatusCode = httpResp.StatusCode
+	}
+	var apiErr datadog.GenericOpenAPIError
+	if probeErr.StatusCode == http.StatusBadRequest && errors.As(err, &apiErr) {
+		if model, ok := apiErr.Model().(datadogV2.APIErrorResponse); ok && len(model.Errors) > 0 {
+			probeErr.Err = fmt.Errorf("the API rejected the query: %s", strings.Join(model.Errors, "; "))
+		}
+	}
+	return probeErr
+}
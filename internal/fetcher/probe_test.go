@@ -0,0 +1,53 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe(t *testing.T) {
+	var limits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits = append(limits, r.URL.Query().Get("page[limit]"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("filter[query]") == "service:(web" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["Invalid query: unbalanced parentheses"]}`))
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(datadogV2.LogsListResponse{
+			Data: []datadogV2.Log{createMockLog("log-1", "one")},
+		}))
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig("")
+	cfg.APIURL = server.URL
+	w := &pageRecorder{}
+	f, err := NewWithWriter(cfg, w, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Probe(context.Background()))
+	assert.Equal(t, []string{"1"}, limits)
+	assert.Empty(t, w.pages, "the probe isn't written")
+	assert.Equal(t, int32(1000), cfg.PageSize)
+
+	cfg.Query = "service:(web"
+	err = f.Probe(context.Background())
+	var probeErr *ProbeError
+	require.ErrorAs(t, err, &probeErr)
+	assert.Equal(t, http.StatusBadRequest, probeErr.StatusCode)
+	assert.True(t, probeErr.Rejected())
+	assert.EqualError(t, err, "the API rejected the query: Invalid query: unbalanced parentheses")
+	assert.Len(t, limits, 2, "a rejected probe isn't retried")
+
+	assert.False(t, (&ProbeError{StatusCode: http.StatusTooManyRequests}).Rejected())
+	assert.False(t, (&ProbeError{}).Rejected(), "network errors aren't the query's fault")
+}
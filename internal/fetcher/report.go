@@ -0,0 +1,269 @@
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// Progress is a snapshot of a fetch, reported after every page and once more
+// when the fetch completes
+type Progress struct {
+	Fetched   int           // logs received from the API
+	Written   int           // logs written, after --filter
+	Filtering bool          // whether --filter is dropping logs
+	Pages     int           // pages received
+	Elapsed   time.Duration // time since the fetch started
+	Cursor    string        // next page's cursor as --cursor-display shows it, empty after the last
+	Done      bool          // the fetch completed
+
+	Transferred int64 // bytes of pages received from the API, compressed
+	Decoded     int64 // bytes of pages once decompressed
+	Page        int64 // bytes received for the latest page, compressed
+	Output      int64 // bytes of output written
+	Format      string
+
+	Duplicates int // logs received again, by ID
+	OutOfOrder int // logs whose timestamp went back against the sort order
+}
+
+// Rate returns the logs fetched per second
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Fetched) / p.Elapsed.Seconds()
+}
+
+// anomalies describes the duplicate and out-of-order logs with their share
+// of those fetched, or returns "" if there were none
+func anomalies(fetched, duplicates, outOfOrder int) string {
+	if duplicates == 0 && outOfOrder == 0 || fetched == 0 {
+		return ""
+	}
+	percent := func(n int) float64 { return 100 * float64(n) / float64(fetched) }
+	return fmt.Sprintf("%d duplicate (%.2f%%), %d out of order (%.2f%%)",
+		duplicates, percent(duplicates), outOfOrder, percent(outOfOrder))
+}
+
+// DiagnosticKind classifies a diagnostic
+type DiagnosticKind string
+
+const (
+	DiagnosticStart      DiagnosticKind = "start"       // the fetch is starting
+	DiagnosticRetry      DiagnosticKind = "retry"       // a request failed and will be retried
+	DiagnosticCancelled  DiagnosticKind = "cancelled"   // the context was cancelled between pages
+	DiagnosticWindow     DiagnosticKind = "window"      // fetching the next window of a split range
+	DiagnosticSkipped    DiagnosticKind = "skipped"     // --skip-errors gave up on the rest of a window
+	DiagnosticRestarted  DiagnosticKind = "restarted"   // the cursor expired, so the window restarted from the last log fetched
+	DiagnosticTeeStopped DiagnosticKind = "tee-stopped" // --tee stopped copying to stdout, e.g. because its reader exited
+
+	DiagnosticExecRestarted DiagnosticKind = "exec-restarted" // an exec:<command> output exited early and was restarted
+)
+
+// Diagnostic is something that happened during a fetch other than progress,
+// such as a retried request
+type Diagnostic struct {
+	Kind    DiagnosticKind
+	Message string // human-readable, as the CLI prints it; may span lines
+
+	Err         error         // retry, skipped, restarted: why the request failed; tee-stopped: why stdout was given up on; exec-restarted: why the command exited
+	Attempt     int           // retry: attempts failed so far
+	MaxAttempts int           // retry: attempts allowed
+	Backoff     time.Duration // retry: delay before the next attempt
+	RateLimited bool          // retry: the API rate limited the request
+	StatusCode  int           // retry, skipped: the response's HTTP status, 0 for network errors
+	Page        int           // retry, skipped: the page being fetched, counting from 1
+	Cursor      string        // retry, skipped: the page's cursor; cancelled: cursor to resume from; restarted: the expired cursor; as --cursor-display shows them
+	Window      *plan.Window  // window: the window being fetched; skipped: the window given up on; restarted: what is left of it
+	Query       string        // start: the query
+	From        time.Time     // start: start of the range
+	To          time.Time     // start: end of the range, zero when it's open
+	PageSize    int32         // start: results per page
+}
+
+// Level returns how severe a diagnostic is
+func (d Diagnostic) Level() slog.Level {
+	switch d.Kind {
+	case DiagnosticRetry, DiagnosticCancelled, DiagnosticSkipped, DiagnosticRestarted, DiagnosticTeeStopped, DiagnosticExecRestarted:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ProgressReporter receives progress snapshots
+type ProgressReporter interface {
+	Progress(p Progress)
+}
+
+// DiagnosticReporter receives diagnostics
+type DiagnosticReporter interface {
+	Diagnostic(d Diagnostic)
+}
+
+// ANSI codes the text reporter uses on a terminal
+const (
+	ansiReset     = "\x1b[0m"
+	ansiYellow    = "\x1b[33m"
+	ansiGreen     = "\x1b[32m"
+	ansiClearLine = "\r\x1b[2K"
+)
+
+// TextReporter writes progress and diagnostics as the human-readable lines
+// the CLI prints to stderr
+type TextReporter struct {
+	w     io.Writer
+	level slog.Level
+	width int  // live progress: the terminal's width; 0 writes a line per page
+	color bool // color warnings and the completion summary
+	drawn bool // a live progress line is showing
+}
+
+// NewTextReporter creates a reporter writing to w
+func NewTextReporter(w io.Writer) *TextReporter {
+	return &TextReporter{w: w}
+}
+
+// SetLevel hides diagnostics, and progress, less severe than level; progress
+// is reported at info
+func (r *TextReporter) SetLevel(level slog.Level) {
+	r.level = level
+}
+
+// SetLive redraws progress in place on one line of a terminal width columns
+// wide, rather than writing a line per page; output that isn't a terminal
+// should keep a line per page, so logs and pipes get no escape codes
+func (r *TextReporter) SetLive(width int) {
+	r.width = width
+}
+
+// SetColor colors warnings and the completion summary
+func (r *TextReporter) SetColor(color bool) {
+	r.color = color
+}
+
+// Progress writes a progress line, or the completion summary
+func (r *TextReporter) Progress(p Progress) {
+	if r.level > slog.LevelInfo {
+		return
+	}
+	if p.Done {
+		r.clear()
+		fmt.Fprintf(r.w, "\n%s\n", r.paint(ansiGreen, fmt.Sprintf("Completed! Fetched %d logs in %d pages (%.1fs)", p.Fetched, p.Pages, p.Elapsed.Seconds())))
+		if p.Filtering {
+			fmt.Fprintf(r.w, "%d logs matched filter, %d dropped\n", p.Written, p.Fetched-p.Written)
+		}
+		if p.Transferred > 0 {
+			fmt.Fprintf(r.w, "Received %s from the API (%s decompressed, %s per page)\n", formatBytes(p.Transferred), formatBytes(p.Decoded), formatBytes(p.Transferred/int64(max(p.Pages, 1))))
+		}
+		if p.Output > 0 {
+			fmt.Fprintf(r.w, "Wrote %s%s\n", formatBytes(p.Output), asFormat(p.Format))
+		}
+		if found := anomalies(p.Fetched, p.Duplicates, p.OutOfOrder); found != "" {
+			fmt.Fprintf(r.w, "%s\n", r.paint(ansiYellow, "Pagination anomalies: "+found+"; Datadog may have repeated or skipped logs"))
+		}
+		return
+	}
+
+	line := fmt.Sprintf("Fetched %d logs (%d pages, %.1f logs/sec)", p.Fetched, p.Pages, p.Rate())
+	if p.Filtering {
+		line += fmt.Sprintf(", %d matched filter", p.Written)
+	}
+	if found := anomalies(p.Fetched, p.Duplicates, p.OutOfOrder); found != "" {
+		line += ", " + found
+	}
+	if p.Page > 0 {
+		line += fmt.Sprintf(", last page %s", formatBytes(p.Page))
+	}
+	if p.Output > 0 {
+		line += fmt.Sprintf(", %s written", formatBytes(p.Output))
+	}
+	if p.Cursor != "" {
+		line += fmt.Sprintf(" - cursor: %s", p.Cursor)
+	}
+	if r.width == 0 {
+		fmt.Fprintf(r.w, "%s\n", line)
+		return
+	}
+
+	// A line that wrapped couldn't be cleared by the next
+	if len(line) >= r.width {
+		line = line[:max(r.width-1, 0)]
+	}
+	fmt.Fprintf(r.w, "%s%s", ansiClearLine, line)
+	r.drawn = true
+}
+
+// Diagnostic writes a diagnostic's message
+func (r *TextReporter) Diagnostic(d Diagnostic) {
+	if d.Level() < r.level {
+		return
+	}
+	r.clear()
+	message := d.Message
+	if d.Level() >= slog.LevelWarn {
+		message = r.paint(ansiYellow, message)
+	}
+	switch d.Kind {
+	case DiagnosticStart:
+		fmt.Fprintf(r.w, "%s\n\n", message)
+	case DiagnosticCancelled:
+		fmt.Fprintf(r.w, "\n%s\n", message)
+	default:
+		fmt.Fprintf(r.w, "%s\n", message)
+	}
+}
+
+// clear erases the live progress line so a message can take its place; the
+// next page draws it again
+func (r *TextReporter) clear() {
+	if r.drawn {
+		fmt.Fprint(r.w, ansiClearLine)
+		r.drawn = false
+	}
+}
+
+// paint wraps s in an ANSI color, unless color is off
+func (r *TextReporter) paint(color, s string) string {
+	if !r.color {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// formatMB formats a byte count in megabytes
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+}
+
+// formatBytes formats a byte count in the largest unit it has one of
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return formatMB(n)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1f KB", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// asFormat names the output format for the summary, if known
+func asFormat(format string) string {
+	if format == "" {
+		return ""
+	}
+	return " of " + format
+}
+
+// discard drops everything reported to it
+type discard struct{}
+
+func (discard) Progress(Progress)     {}
+func (discard) Diagnostic(Diagnostic) {}
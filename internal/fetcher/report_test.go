@@ -0,0 +1,106 @@
+package fetcher
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextReporterProgress(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTextReporter(&buf)
+
+	r.Progress(Progress{Fetched: 2000, Written: 2000, Pages: 2, Elapsed: 2 * time.Second, Cursor: "abc"})
+	r.Progress(Progress{Fetched: 2500, Written: 40, Filtering: true, Pages: 3, Elapsed: 2500 * time.Millisecond})
+	r.Progress(Progress{Fetched: 2500, Written: 40, Filtering: true, Pages: 3, Elapsed: 2500 * time.Millisecond, Done: true})
+
+	assert.Equal(t, "Fetched 2000 logs (2 pages, 1000.0 logs/sec) - cursor: abc\n"+
+		"Fetched 2500 logs (3 pages, 1000.0 logs/sec), 40 matched filter\n"+
+		"\nCompleted! Fetched 2500 logs in 3 pages (2.5s)\n"+
+		"40 logs matched filter, 2460 dropped\n", buf.String())
+}
+
+func TestTextReporterAnomalies(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTextReporter(&buf)
+
+	r.Progress(Progress{Fetched: 2000, Pages: 2, Elapsed: 2 * time.Second, Duplicates: 10, OutOfOrder: 1})
+	r.Progress(Progress{Fetched: 2000, Pages: 2, Elapsed: 2 * time.Second, Duplicates: 10, OutOfOrder: 1, Done: true})
+
+	assert.Equal(t, "Fetched 2000 logs (2 pages, 1000.0 logs/sec), 10 duplicate (0.50%), 1 out of order (0.05%)\n"+
+		"\nCompleted! Fetched 2000 logs in 2 pages (2.0s)\n"+
+		"Pagination anomalies: 10 duplicate (0.50%), 1 out of order (0.05%); Datadog may have repeated or skipped logs\n", buf.String())
+}
+
+func TestTextReporterBytes(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTextReporter(&buf)
+
+	r.Progress(Progress{Fetched: 1000, Pages: 1, Elapsed: time.Second, Transferred: 120_000, Page: 120_000, Output: 2_500_000})
+	r.Progress(Progress{Fetched: 2000, Pages: 2, Elapsed: 2 * time.Second, Transferred: 240_000, Decoded: 960_000, Output: 5_000_000, Format: "ndjson", Done: true})
+
+	assert.Equal(t, "Fetched 1000 logs (1 pages, 1000.0 logs/sec), last page 120.0 KB, 2.5 MB written\n"+
+		"\nCompleted! Fetched 2000 logs in 2 pages (2.0s)\n"+
+		"Received 240.0 KB from the API (960.0 KB decompressed, 120.0 KB per page)\n"+
+		"Wrote 5.0 MB of ndjson\n", buf.String())
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KB", formatBytes(1500))
+	assert.Equal(t, "2.3 MB", formatBytes(2_300_000))
+	assert.Equal(t, "4.0 GB", formatBytes(4e9))
+}
+
+func TestTextReporterDiagnostic(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTextReporter(&buf)
+
+	r.Diagnostic(Diagnostic{Kind: DiagnosticStart, Message: "Starting fetch with query: *\nPage size: 1000"})
+	r.Diagnostic(Diagnostic{Kind: DiagnosticRetry, Message: "Error (attempt 1/3): boom - retrying in 1s...", Err: errors.New("boom")})
+	r.Diagnostic(Diagnostic{Kind: DiagnosticCancelled, Message: "Operation cancelled; resume with --cursor 'abc'"})
+
+	assert.Equal(t, "Starting fetch with query: *\nPage size: 1000\n\n"+
+		"Error (attempt 1/3): boom - retrying in 1s...\n"+
+		"\nOperation cancelled; resume with --cursor 'abc'\n", buf.String())
+}
+
+func TestProgressRate(t *testing.T) {
+	assert.Equal(t, 0.0, Progress{Fetched: 10}.Rate())
+	assert.Equal(t, 5.0, Progress{Fetched: 10, Elapsed: 2 * time.Second}.Rate())
+}
+
+func TestTextReporterLevel(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTextReporter(&buf)
+	r.SetLevel(slog.LevelWarn)
+
+	r.Diagnostic(Diagnostic{Kind: DiagnosticStart, Message: "Starting fetch with query: *"})
+	r.Progress(Progress{Fetched: 1000, Pages: 1, Elapsed: time.Second})
+	r.Diagnostic(Diagnostic{Kind: DiagnosticRetry, Message: "Error (attempt 1/3): boom - retrying in 1s..."})
+	r.Progress(Progress{Fetched: 1000, Pages: 1, Elapsed: time.Second, Done: true})
+
+	assert.Equal(t, "Error (attempt 1/3): boom - retrying in 1s...\n", buf.String())
+}
+
+func TestTextReporterLive(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTextReporter(&buf)
+	r.SetLive(40)
+	r.SetColor(true)
+
+	r.Progress(Progress{Fetched: 1000, Pages: 1, Elapsed: time.Second})
+	r.Progress(Progress{Fetched: 2000, Pages: 2, Elapsed: time.Second, Cursor: "a-very-long-cursor"})
+	r.Diagnostic(Diagnostic{Kind: DiagnosticRetry, Message: "Error (attempt 1/3): boom"})
+	r.Progress(Progress{Fetched: 2000, Pages: 2, Elapsed: time.Second, Done: true})
+
+	// Progress redraws one line, cut to the width; a diagnostic clears it
+	assert.Equal(t, "\r\x1b[2KFetched 1000 logs (1 pages, 1000.0 logs"+
+		"\r\x1b[2KFetched 2000 logs (2 pages, 2000.0 logs"+
+		"\r\x1b[2K\x1b[33mError (attempt 1/3): boom\x1b[0m\n"+
+		"\n\x1b[32mCompleted! Fetched 2000 logs in 2 pages (1.0s)\x1b[0m\n", buf.String())
+}
@@ -46,6 +46,9 @@ func ClassifyError(err error, httpResp *http.Response) *RetryableError {
 	case 429: // Rate limit
 		re.Retryable = true
 		re.RetryAfter = parseRetryAfter(httpResp)
+		if re.RetryAfter == 0 {
+			re.RetryAfter = parseRateLimitReset(httpResp)
+		}
 		if re.RetryAfter == 0 {
 			re.RetryAfter = rateLimitWait
 		}
@@ -82,6 +85,26 @@ func parseRetryAfter(resp *http.Response) time.Duration {
 	return 0
 }
 
+// parseRateLimitReset extracts Datadog's X-RateLimit-Reset header, the
+// seconds until the rate limit period ends. A reset due now still waits a
+// second, so the retry lands in the new period.
+func parseRateLimitReset(resp *http.Response) time.Duration {
+	header := resp.Header.Get("X-RateLimit-Reset")
+	if header == "" {
+		return 0
+	}
+
+	seconds, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	// Some proxies rewrite it as the Unix time of the reset
+	if seconds > 1e9 {
+		return max(time.Until(time.Unix(seconds, 0)), time.Second)
+	}
+	return max(time.Duration(seconds)*time.Second, time.Second)
+}
+
 // ExponentialBackoff calculates backoff duration
 func ExponentialBackoff(attempt int) time.Duration {
 	backoff := float64(baseBackoff) * math.Pow(2, float64(attempt))
@@ -113,9 +136,9 @@ func FormatRetryError(err error, httpResp *http.Response) error {
 
 	switch httpResp.StatusCode {
 	case 401:
-		return fmt.Errorf("authentication failed: check DD_API_KEY and DD_APP_KEY")
+		return fmt.Errorf("authentication failed: check DD_API_KEY and DD_APP_KEY (dogfetch auth check diagnoses this)")
 	case 403:
-		return fmt.Errorf("permission denied: check your API key has logs_read_data permission")
+		return fmt.Errorf("permission denied: check your API key has logs_read_data permission (dogfetch auth check diagnoses this)")
 	case 429:
 		return fmt.Errorf("rate limit exceeded: %w", err)
 	default:
@@ -3,6 +3,7 @@ package fetcher
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
@@ -139,6 +140,57 @@ func TestParseRetryAfter(t *testing.T) {
 	}
 }
 
+func TestParseRateLimitReset(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "no header", header: "", want: 0},
+		{name: "seconds until reset", header: "10", want: 10 * time.Second},
+		{name: "reset due now", header: "0", want: time.Second},
+		{name: "invalid format", header: "soon", want: 0},
+		{name: "negative", header: "-5", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("X-RateLimit-Reset", tt.header)
+			}
+			assert.Equal(t, tt.want, parseRateLimitReset(resp))
+		})
+	}
+
+	t.Run("unix time", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10))
+		got := parseRateLimitReset(resp)
+		assert.InDelta(t, float64(30*time.Second), float64(got), float64(2*time.Second))
+	})
+}
+
+func TestClassifyErrorRateLimitWait(t *testing.T) {
+	limited := func(headers map[string]string) *http.Response {
+		resp := &http.Response{StatusCode: 429, Header: http.Header{}}
+		for k, v := range headers {
+			resp.Header.Set(k, v)
+		}
+		return resp
+	}
+	err := errors.New("429 Too Many Requests")
+
+	got := ClassifyError(err, limited(map[string]string{"Retry-After": "5", "X-RateLimit-Reset": "10"}))
+	assert.Equal(t, 5*time.Second, got.RetryAfter, "Retry-After wins")
+
+	got = ClassifyError(err, limited(map[string]string{"X-RateLimit-Reset": "10", "X-RateLimit-Period": "60"}))
+	assert.Equal(t, 10*time.Second, got.RetryAfter)
+
+	got = ClassifyError(err, limited(nil))
+	assert.Equal(t, rateLimitWait, got.RetryAfter)
+}
+
 func TestExponentialBackoff(t *testing.T) {
 	tests := []struct {
 		attempt int
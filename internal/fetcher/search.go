@@ -0,0 +1,80 @@
+package fetcher
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// SearchRequest is a search for logs outside a full fetch
+type SearchRequest struct {
+	Query     string
+	Indexes   []string
+	From, To  time.Time
+	Ascending bool // oldest first rather than newest first
+	PageSize  int32
+}
+
+// Search pages through the logs matching req, passing each page to fn until
+// it returns false or there are no more. Pages are retried like a fetch's.
+func (c *Client) Search(ctx context.Context, req SearchRequest, fn func([]datadogV2.Log) bool) error {
+	from := strconv.FormatInt(req.From.UnixMilli(), 10)
+	to := strconv.FormatInt(req.To.UnixMilli(), 10)
+	body := datadogV2.LogsListRequest{
+		Filter: &datadogV2.LogsQueryFilter{
+			Query:   &req.Query,
+			Indexes: req.Indexes,
+			From:    &from,
+			To:      &to,
+		},
+		Page: &datadogV2.LogsListRequestPage{Limit: &req.PageSize},
+		Sort: datadogV2.LOGSSORT_TIMESTAMP_DESCENDING.Ptr(),
+	}
+	if req.Ascending {
+		body.Sort = datadogV2.LOGSSORT_TIMESTAMP_ASCENDING.Ptr()
+	}
+
+	for {
+		resp, err := c.list(ctx, body)
+		if err != nil {
+			return err
+		}
+		data := resp.GetData()
+		if len(data) == 0 || !fn(data) {
+			return nil
+		}
+
+		cursor := resp.GetMeta().Page.GetAfter()
+		if cursor == "" {
+			return nil
+		}
+		body.Page.Cursor = &cursor
+	}
+}
+
+// list fetches one page of a search, retrying transient errors
+func (c *Client) list(ctx context.Context, body datadogV2.LogsListRequest) (datadogV2.LogsListResponse, error) {
+	attempt := 0
+	for {
+		resp, _, httpResp, err := c.listLogs(c.GetContext(ctx), &body, false)
+
+		retryErr := ClassifyError(err, httpResp)
+		if retryErr == nil {
+			return resp, nil
+		}
+
+		shouldRetry, backoff := ShouldRetry(attempt, retryErr)
+		if !shouldRetry {
+			return resp, FormatRetryError(err, httpResp)
+		}
+		attempt++
+
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
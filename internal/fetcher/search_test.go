@@ -0,0 +1,72 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSearch(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/logs/events/search", r.URL.Path)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			w.Write([]byte(`{"data":[{"id":"a"},{"id":"b"}],"meta":{"page":{"after":"next"}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"c"}],"meta":{}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	req := SearchRequest{Query: "service:web", Indexes: []string{"main"}, From: from, To: from.Add(time.Hour), Ascending: true, PageSize: 2}
+
+	var ids []string
+	err := client.Search(context.Background(), req, func(page []datadogV2.Log) bool {
+		for _, log := range page {
+			ids = append(ids, log.GetId())
+		}
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+
+	require.Len(t, bodies, 2)
+	filter := bodies[0]["filter"].(map[string]interface{})
+	assert.Equal(t, "service:web", filter["query"])
+	assert.Equal(t, "1704067200000", filter["from"])
+	assert.Equal(t, "1704070800000", filter["to"])
+	assert.Equal(t, "timestamp", bodies[0]["sort"])
+	assert.Nil(t, bodies[0]["page"].(map[string]interface{})["cursor"])
+	assert.Equal(t, "next", bodies[1]["page"].(map[string]interface{})["cursor"])
+}
+
+func TestClientSearchStops(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"id":"a"}],"meta":{"page":{"after":"next"}}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	err := client.Search(context.Background(), SearchRequest{Query: "*", PageSize: 1}, func([]datadogV2.Log) bool {
+		return false
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,77 @@
+package fetcher
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// spansPageSize is the most spans the Spans API returns per page
+const spansPageSize = 1000
+
+// Spans returns the spans matching query in [from, to), oldest first
+func (c *Client) Spans(ctx context.Context, query string, from, to time.Time) ([]datadogV2.Span, error) {
+	fromStr := strconv.FormatInt(from.UnixMilli(), 10)
+	toStr := strconv.FormatInt(to.UnixMilli(), 10)
+	limit := int32(spansPageSize)
+
+	page := &datadogV2.SpansListRequestPage{Limit: &limit}
+	body := datadogV2.SpansListRequest{
+		Data: &datadogV2.SpansListRequestData{
+			Attributes: &datadogV2.SpansListRequestAttributes{
+				Filter: &datadogV2.SpansQueryFilter{
+					Query: &query,
+					From:  &fromStr,
+					To:    &toStr,
+				},
+				Page: page,
+				Sort: datadogV2.SPANSSORT_TIMESTAMP_ASCENDING.Ptr(),
+			},
+			Type: datadogV2.SPANSLISTREQUESTTYPE_SEARCH_REQUEST.Ptr(),
+		},
+	}
+
+	var spans []datadogV2.Span
+	for {
+		resp, err := c.listSpans(ctx, body)
+		if err != nil {
+			return spans, err
+		}
+		spans = append(spans, resp.Data...)
+
+		meta := resp.GetMeta()
+		cursor := meta.GetPage().After
+		if len(resp.Data) == 0 || cursor == nil || *cursor == "" {
+			return spans, nil
+		}
+		page.Cursor = cursor
+	}
+}
+
+// listSpans fetches one page of spans, retrying transient errors like page
+// fetches do
+func (c *Client) listSpans(ctx context.Context, body datadogV2.SpansListRequest) (datadogV2.SpansListResponse, error) {
+	attempt := 0
+	for {
+		resp, httpResp, err := c.spans.ListSpans(c.GetContext(ctx), body)
+
+		retryErr := ClassifyError(err, httpResp)
+		if retryErr == nil {
+			return resp, nil
+		}
+
+		shouldRetry, backoff := ShouldRetry(attempt, retryErr)
+		if !shouldRetry {
+			return resp, FormatRetryError(err, httpResp)
+		}
+		attempt++
+
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSpans(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/spans/events/search", r.URL.Path)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(bodies) == 1 {
+			w.Write([]byte(`{"data":[{"id":"a","type":"spans"}],"meta":{"page":{"after":"next"}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"b","type":"spans"}],"meta":{}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "app", "", WithBaseURL(server.URL))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	spans, err := client.Spans(context.Background(), "trace_id:1234", from, from.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, spans, 2)
+	assert.Equal(t, "a", spans[0].GetId())
+	assert.Equal(t, "b", spans[1].GetId())
+
+	require.Len(t, bodies, 2)
+	attrs := bodies[0]["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+	filter := attrs["filter"].(map[string]interface{})
+	assert.Equal(t, "trace_id:1234", filter["query"])
+	assert.Equal(t, "1704067200000", filter["from"])
+	attrs = bodies[1]["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+	assert.Equal(t, "next", attrs["page"].(map[string]interface{})["cursor"])
+}
@@ -0,0 +1,123 @@
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// Status is an instantaneous view of a running fetch, for checking on an
+// hours-long export without restarting it
+type Status struct {
+	Fetched     int          `json:"fetched"` // logs received from the API
+	Written     int          `json:"written"` // logs written, after --filter
+	Pages       int          `json:"pages"`
+	Elapsed     float64      `json:"elapsed_seconds"`
+	Rate        float64      `json:"logs_per_second"`
+	Window      *plan.Window `json:"window,omitempty"` // being fetched: the current window, or the whole range
+	Cursor      string       `json:"cursor,omitempty"` // next page's cursor as --cursor-display shows it
+	Retries     int          `json:"retries"`
+	RateLimited int          `json:"rate_limited"` // retries that waited out a rate limit
+	LastError   string       `json:"last_error,omitempty"`
+	Transferred int64        `json:"bytes_transferred"` // bytes of pages received, compressed
+	Decoded     int64        `json:"bytes_decoded"`     // the same once decompressed
+	Output      int64        `json:"bytes_written"`     // bytes of output written
+	Duplicates  int          `json:"duplicates"`        // logs received again, by ID
+	OutOfOrder  int          `json:"out_of_order"`      // logs whose timestamp went back against the sort order
+	Done        bool         `json:"done"`
+}
+
+// StatusTracker follows a fetch's progress and diagnostics so its status can
+// be read at any moment from another goroutine, e.g. a signal handler
+type StatusTracker struct {
+	mu      sync.Mutex
+	started time.Time
+	status  Status
+}
+
+// NewTracker creates a tracker for a fetch starting now
+func NewTracker() *StatusTracker {
+	return &StatusTracker{started: time.Now()}
+}
+
+// Progress records a progress snapshot
+func (t *StatusTracker) Progress(p Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Fetched = p.Fetched
+	t.status.Written = p.Written
+	t.status.Pages = p.Pages
+	t.status.Cursor = p.Cursor
+	t.status.Transferred = p.Transferred
+	t.status.Decoded = p.Decoded
+	t.status.Output = p.Output
+	t.status.Duplicates = p.Duplicates
+	t.status.OutOfOrder = p.OutOfOrder
+	t.status.Done = p.Done
+}
+
+// Diagnostic records the window being fetched and counts retries
+func (t *StatusTracker) Diagnostic(d Diagnostic) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch d.Kind {
+	case DiagnosticStart:
+		t.status.Window = &plan.Window{From: d.From, To: d.To}
+	case DiagnosticWindow:
+		if d.Window != nil {
+			w := *d.Window
+			t.status.Window = &w
+		}
+	case DiagnosticRetry:
+		t.status.Retries++
+		if d.RateLimited {
+			t.status.RateLimited++
+		}
+		if d.Err != nil {
+			t.status.LastError = d.Err.Error()
+		}
+	}
+}
+
+// Status returns the fetch's status as of now
+func (t *StatusTracker) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.status
+	elapsed := time.Since(t.started)
+	s.Elapsed = elapsed.Seconds()
+	if elapsed > 0 {
+		s.Rate = float64(s.Fetched) / elapsed.Seconds()
+	}
+	return s
+}
+
+// WriteText writes the status as the lines printed on SIGUSR1
+func (s Status) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Status: fetched %d logs (%d pages, %.1f logs/sec) in %.1fs\n", s.Fetched, s.Pages, s.Rate, s.Elapsed)
+	if s.Written != s.Fetched {
+		fmt.Fprintf(w, "  written: %d\n", s.Written)
+	}
+	if s.Window != nil {
+		fmt.Fprintf(w, "  window: %s to %s\n", s.Window.From.Format(time.RFC3339), FormatToTime(s.Window.To))
+	}
+	if s.Cursor != "" {
+		fmt.Fprintf(w, "  cursor: %s\n", s.Cursor)
+	}
+	if s.Transferred > 0 {
+		fmt.Fprintf(w, "  received: %s (%s decompressed)\n", formatMB(s.Transferred), formatMB(s.Decoded))
+	}
+	if s.Output > 0 {
+		fmt.Fprintf(w, "  output: %s written\n", formatBytes(s.Output))
+	}
+	if found := anomalies(s.Fetched, s.Duplicates, s.OutOfOrder); found != "" {
+		fmt.Fprintf(w, "  anomalies: %s\n", found)
+	}
+	fmt.Fprintf(w, "  retries: %d (%d rate limited)\n", s.Retries, s.RateLimited)
+	if s.LastError != "" {
+		fmt.Fprintf(w, "  last error: %s\n", s.LastError)
+	}
+}
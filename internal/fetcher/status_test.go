@@ -0,0 +1,77 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+func TestStatusTracker(t *testing.T) {
+	tracker := NewTracker()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := plan.Window{From: from, To: from.Add(6 * time.Hour)}
+
+	tracker.Diagnostic(Diagnostic{Kind: DiagnosticStart, From: from})
+	assert.Equal(t, &plan.Window{From: from}, tracker.Status().Window, "the whole range until windows start")
+
+	tracker.Diagnostic(Diagnostic{Kind: DiagnosticWindow, Window: &window})
+	tracker.Progress(Progress{Fetched: 10, Written: 8, Pages: 2, Cursor: "abc", Duplicates: 1, Output: 2048})
+	tracker.Diagnostic(Diagnostic{Kind: DiagnosticRetry, Err: errors.New("server error")})
+	tracker.Diagnostic(Diagnostic{Kind: DiagnosticRetry, RateLimited: true, Err: errors.New("rate limited")})
+
+	s := tracker.Status()
+	assert.Equal(t, 10, s.Fetched)
+	assert.Equal(t, 8, s.Written)
+	assert.Equal(t, 2, s.Pages)
+	assert.Equal(t, "abc", s.Cursor)
+	assert.Equal(t, &window, s.Window)
+	assert.Equal(t, 2, s.Retries)
+	assert.Equal(t, 1, s.RateLimited)
+	assert.Equal(t, "rate limited", s.LastError)
+	assert.Greater(t, s.Rate, 0.0)
+
+	var out bytes.Buffer
+	s.WriteText(&out)
+	assert.Contains(t, out.String(), "Status: fetched 10 logs (2 pages, ")
+	assert.Contains(t, out.String(), "written: 8\n")
+	assert.Contains(t, out.String(), "window: 2024-01-01T00:00:00Z to 2024-01-01T06:00:00Z\n")
+	assert.Contains(t, out.String(), "cursor: abc\n")
+	assert.Contains(t, out.String(), "anomalies: 1 duplicate (10.00%), 0 out of order (0.00%)\n")
+	assert.Contains(t, out.String(), "output: 2.0 KB written\n")
+	assert.Contains(t, out.String(), "retries: 2 (1 rate limited)\n")
+	assert.Contains(t, out.String(), "last error: rate limited\n")
+}
+
+func TestFetchReportsToTracker(t *testing.T) {
+	server := newMockLogsServer(t,
+		[]datadogV2.Log{createMockLog("log-1", "one")},
+		[]datadogV2.Log{createMockLog("log-2", "two")},
+	)
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+
+	var errOut bytes.Buffer
+	f, err := New(cfg, &errOut)
+	require.NoError(t, err)
+	tracker := NewTracker()
+	f.AddProgressReporter(tracker)
+	f.AddDiagnosticReporter(tracker)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	s := tracker.Status()
+	assert.Equal(t, 2, s.Fetched)
+	assert.Equal(t, 2, s.Pages)
+	assert.True(t, s.Done)
+	require.NotNil(t, s.Window)
+	assert.Equal(t, cfg.From, s.Window.From)
+	assert.Contains(t, errOut.String(), "Completed! Fetched 2 logs", "the current reporter still gets progress")
+}
@@ -0,0 +1,251 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// The API client's ListLogs and ListLogsGet read a whole response body into
+// memory and then decode all of it, so a page of 5000 logs with large
+// attributes briefly costs several times its size. The methods here send the
+// same requests but decode the body as it arrives, one log at a time.
+//
+// They also ask for gzip themselves rather than leave it to the transport,
+// which would hide how many bytes crossed the wire.
+
+// listLogs is ListLogs (POST /api/v2/logs/events/search) with a streaming
+// decode; with keepRaw it also returns the JSON record of each log
+func (c *Client) listLogs(ctx context.Context, body *datadogV2.LogsListRequest, keepRaw bool) (datadogV2.LogsListResponse, []json.RawMessage, *http.Response, error) {
+	base, err := c.api.Client.Cfg.ServerURLWithContext(ctx, "v2.LogsApi.ListLogs")
+	if err != nil {
+		return datadogV2.LogsListResponse{}, nil, nil, datadog.GenericOpenAPIError{ErrorMessage: err.Error()}
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	var postBody interface{}
+	if body != nil {
+		postBody = body
+	}
+	return c.streamLogs(ctx, base+"/api/v2/logs/events/search", http.MethodPost, postBody, headers, url.Values{}, keepRaw)
+}
+
+// listLogsGet is ListLogsGet (GET /api/v2/logs/events) with a streaming
+// decode; with keepRaw it also returns the JSON record of each log
+func (c *Client) listLogsGet(ctx context.Context, opts datadogV2.ListLogsGetOptionalParameters, keepRaw bool) (datadogV2.LogsListResponse, []json.RawMessage, *http.Response, error) {
+	base, err := c.api.Client.Cfg.ServerURLWithContext(ctx, "v2.LogsApi.ListLogsGet")
+	if err != nil {
+		return datadogV2.LogsListResponse{}, nil, nil, datadog.GenericOpenAPIError{ErrorMessage: err.Error()}
+	}
+
+	// Parameters are formatted as the API client formats them
+	query := url.Values{}
+	if opts.FilterQuery != nil {
+		query.Add("filter[query]", datadog.ParameterToString(*opts.FilterQuery, ""))
+	}
+	if opts.FilterIndexes != nil {
+		query.Add("filter[indexes]", datadog.ParameterToString(*opts.FilterIndexes, "csv"))
+	}
+	if opts.FilterFrom != nil {
+		query.Add("filter[from]", datadog.ParameterToString(*opts.FilterFrom, ""))
+	}
+	if opts.FilterTo != nil {
+		query.Add("filter[to]", datadog.ParameterToString(*opts.FilterTo, ""))
+	}
+	if opts.FilterStorageTier != nil {
+		query.Add("filter[storage_tier]", datadog.ParameterToString(*opts.FilterStorageTier, ""))
+	}
+	if opts.Sort != nil {
+		query.Add("sort", datadog.ParameterToString(*opts.Sort, ""))
+	}
+	if opts.PageCursor != nil {
+		query.Add("page[cursor]", datadog.ParameterToString(*opts.PageCursor, ""))
+	}
+	if opts.PageLimit != nil {
+		query.Add("page[limit]", datadog.ParameterToString(*opts.PageLimit, ""))
+	}
+	return c.streamLogs(ctx, base+"/api/v2/logs/events", http.MethodGet, nil, map[string]string{}, query, keepRaw)
+}
+
+// streamLogs sends a logs request and decodes the page it returns. Errors
+// come back as the API client returns them, so retries classify them the
+// same way; a body that breaks off part way is reported without its
+// response, like the dropped connection it usually is, so it is retried.
+func (c *Client) streamLogs(ctx context.Context, path, method string, body interface{}, headers map[string]string, query url.Values, keepRaw bool) (datadogV2.LogsListResponse, []json.RawMessage, *http.Response, error) {
+	var page datadogV2.LogsListResponse
+	headers["Accept"] = "application/json"
+	if c.api.Client.Cfg.Compress {
+		headers["Accept-Encoding"] = "gzip"
+	}
+	datadog.SetAuthKeys(ctx, &headers,
+		[2]string{"apiKeyAuth", "DD-API-KEY"},
+		[2]string{"appKeyAuth", "DD-APPLICATION-KEY"},
+	)
+	req, err := c.api.Client.PrepareRequest(ctx, path, method, body, headers, query, url.Values{}, nil)
+	if err != nil {
+		return page, nil, nil, err
+	}
+	httpResp, err := c.api.Client.CallAPI(req)
+	if err != nil || httpResp == nil {
+		return page, nil, httpResp, err
+	}
+	if err := c.decompress(httpResp); err != nil {
+		httpResp.Body.Close()
+		return page, nil, nil, fmt.Errorf("failed to read the page: %w", err)
+	}
+
+	if httpResp.StatusCode >= 300 {
+		// Error bodies are small; read them whole as the client does
+		errBody, err := datadog.ReadBody(httpResp)
+		if err != nil {
+			return page, nil, httpResp, err
+		}
+		apiErr := datadog.GenericOpenAPIError{ErrorBody: errBody, ErrorMessage: httpResp.Status}
+		switch httpResp.StatusCode {
+		case http.StatusBadRequest, http.StatusForbidden, http.StatusTooManyRequests:
+			var model datadogV2.APIErrorResponse
+			if json.Unmarshal(errBody, &model) == nil {
+				apiErr.ErrorModel = model
+			}
+		}
+		return page, nil, httpResp, apiErr
+	}
+
+	defer httpResp.Body.Close()
+	page, records, err := decodeLogsPage(httpResp.Body, keepRaw)
+	if err != nil {
+		return page, nil, nil, fmt.Errorf("failed to read the page: %w", err)
+	}
+	return page, records, httpResp, nil
+}
+
+// decompress swaps the body of a response for one decompressed as it is
+// read, counting the bytes received and the bytes they decompress to
+func (c *Client) decompress(httpResp *http.Response) error {
+	wire := httpResp.Body
+	var body io.Reader = &countingReader{r: wire, n: &c.transferred}
+	if strings.EqualFold(httpResp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return err
+		}
+		body = gz
+		httpResp.Header.Del("Content-Encoding")
+		httpResp.Header.Del("Content-Length")
+		httpResp.ContentLength = -1
+		httpResp.Uncompressed = true
+	}
+	httpResp.Body = struct {
+		io.Reader
+		io.Closer
+	}{&countingReader{r: body, n: &c.decoded}, wire}
+	return nil
+}
+
+// Transfer returns the bytes of API responses received so far, as they
+// crossed the wire and once decompressed
+func (c *Client) Transfer() (transferred, decoded int64) {
+	return c.transferred.Load(), c.decoded.Load()
+}
+
+// countingReader adds the bytes read through it to n
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// decodeLogsPage decodes a page of logs from r a log at a time, so only one
+// log's JSON is held besides the logs decoded so far. Fields of each log the
+// client doesn't model are put back as they were sent (see preserveLog);
+// with keepRaw the records themselves are returned instead, in order.
+func decodeLogsPage(r io.Reader, keepRaw bool) (datadogV2.LogsListResponse, []json.RawMessage, error) {
+	var page datadogV2.LogsListResponse
+	var records []json.RawMessage
+	dec := json.NewDecoder(r)
+	if _, err := openDelim(dec, '{'); err != nil {
+		return page, nil, err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return page, nil, err
+		}
+		switch token {
+		case "data":
+			open, err := openDelim(dec, '[')
+			if err != nil {
+				return page, nil, err
+			}
+			if !open {
+				continue
+			}
+			page.Data = []datadogV2.Log{}
+			for dec.More() {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return page, nil, err
+				}
+				var log datadogV2.Log
+				if err := json.Unmarshal(raw, &log); err != nil {
+					return page, nil, err
+				}
+				if keepRaw {
+					records = append(records, raw)
+				} else {
+					preserveLog(&log, raw)
+				}
+				page.Data = append(page.Data, log)
+			}
+			if _, err := dec.Token(); err != nil {
+				return page, nil, err
+			}
+		case "meta":
+			if err := dec.Decode(&page.Meta); err != nil {
+				return page, nil, err
+			}
+		case "links":
+			if err := dec.Decode(&page.Links); err != nil {
+				return page, nil, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return page, nil, err
+			}
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return page, nil, err
+	}
+	return page, records, nil
+}
+
+// openDelim reads the opening delimiter of an object or array; a null in its
+// place reads as absent
+func openDelim(dec *json.Decoder, want json.Delim) (bool, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if token == nil {
+		return false, nil
+	}
+	if token != want {
+		return false, fmt.Errorf("expected %s, got %v", want, token)
+	}
+	return true, nil
+}
@@ -0,0 +1,131 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLogsPage(t *testing.T) {
+	body := `{"data":[{"id":"a","type":"log","attributes":{"message":"m","extra":1.50}}],
+		"unknown":{"nested":[1,2]},"links":{"next":"https://example.com"},"meta":{"page":{"after":"next"},"status":"done"}}`
+
+	page, records, err := decodeLogsPage(strings.NewReader(body), false)
+	require.NoError(t, err)
+	assert.Nil(t, records)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "a", page.Data[0].GetId())
+	assert.Equal(t, "next", page.GetMeta().Page.GetAfter())
+	assert.Equal(t, "https://example.com", *page.Links.Next)
+	// Unknown fields keep their JSON, as preserveLog leaves them
+	assert.Equal(t, `1.50`, string(page.Data[0].Attributes.AdditionalProperties["extra"].(json.RawMessage)))
+
+	page, records, err = decodeLogsPage(strings.NewReader(body), true)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, `{"id":"a","type":"log","attributes":{"message":"m","extra":1.50}}`, string(records[0]))
+	assert.Equal(t, 1.5, page.Data[0].Attributes.AdditionalProperties["extra"])
+
+	page, _, err = decodeLogsPage(strings.NewReader(`{"data":null,"meta":null}`), false)
+	require.NoError(t, err)
+	assert.Empty(t, page.Data)
+}
+
+func TestDecodeLogsPageTruncated(t *testing.T) {
+	_, _, err := decodeLogsPage(strings.NewReader(`{"data":[{"id":"a"},{"id":"b","attri`), false)
+	assert.Error(t, err)
+	_, _, err = decodeLogsPage(strings.NewReader(`[]`), false)
+	assert.Error(t, err)
+}
+
+func TestFetchRetriesTruncatedPage(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requests.Add(1) == 1 {
+			w.Write([]byte(`{"data":[{"id":"a","type":"log","attri`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"a","type":"log","attributes":{"message":"m"}}],"meta":{"page":{}}}`))
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+
+	var diag bytes.Buffer
+	f, err := New(cfg, &diag)
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, 1, f.Stats().Logs)
+}
+
+func TestFetchDecompressesGzipPages(t *testing.T) {
+	var page bytes.Buffer
+	page.WriteString(`{"data":[`)
+	for i := 0; i < 50; i++ {
+		if i > 0 {
+			page.WriteString(",")
+		}
+		page.WriteString(`{"id":"a","type":"log","attributes":{"message":"the same message compresses well"}}`)
+	}
+	page.WriteString(`],"meta":{"page":{}}}`)
+
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(page.Bytes())
+		gz.Close()
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+
+	assert.Equal(t, "gzip", acceptEncoding)
+	stats := f.Stats()
+	assert.Equal(t, 50, stats.Logs)
+	assert.Equal(t, int64(page.Len()), stats.Decoded)
+	assert.Positive(t, stats.Transferred)
+	assert.Less(t, stats.Transferred, stats.Decoded)
+}
+
+func TestFetchSendsUserAgentAndHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[],"meta":{"page":{}}}`))
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(filepath.Join(t.TempDir(), "out.ndjson"))
+	cfg.APIURL = server.URL
+	cfg.UserAgent = "dogfetch-nightly/1.0"
+	cfg.Headers = map[string]string{"X-Team": "payments"}
+
+	f, err := New(cfg, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, f.Fetch(context.Background()))
+	assert.Equal(t, []string{"dogfetch-nightly/1.0"}, got.Values("User-Agent"))
+	assert.Equal(t, "payments", got.Get("X-Team"))
+	assert.Equal(t, "test-key", got.Get("DD-API-KEY"))
+}
@@ -0,0 +1,271 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// Filter is a compiled client-side predicate, for conditions the Datadog
+// query language can't express, such as
+//
+//	attributes.duration > 500 && status == "error"
+//
+// Operands are field paths, resolved like logfield.Lookup, or string,
+// number, true, false and null literals. Operators are == != < <= > >=,
+// =~ and !~ for regular expressions, && || ! and parentheses.
+type Filter struct {
+	src  string
+	root node
+}
+
+// Parse compiles a filter expression
+func Parse(src string) (*Filter, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected input")
+	}
+	return &Filter{src: src, root: root}, nil
+}
+
+// String returns the expression the filter was parsed from
+func (f *Filter) String() string {
+	return f.src
+}
+
+// Match reports whether a log satisfies the filter
+func (f *Filter) Match(log datadogV2.Log) bool {
+	return eval(f.root, log)
+}
+
+// Page keeps the logs matching every filter, reusing the backing array of
+// logs
+func Page(filters []*Filter, logs []datadogV2.Log) []datadogV2.Log {
+	if len(filters) == 0 {
+		return logs
+	}
+	kept := logs[:0]
+next:
+	for _, log := range logs {
+		for _, f := range filters {
+			if !f.Match(log) {
+				continue next
+			}
+		}
+		kept = append(kept, log)
+	}
+	return kept
+}
+
+func eval(n node, log datadogV2.Log) bool {
+	switch n := n.(type) {
+	case and:
+		return eval(n.left, log) && eval(n.right, log)
+	case or:
+		return eval(n.left, log) || eval(n.right, log)
+	case not:
+		return !eval(n.expr, log)
+	case operand:
+		v, ok := resolve(n, log)
+		return ok && v != nil && v != false
+	case comparison:
+		return compare(n, log)
+	}
+	return false
+}
+
+// resolve returns an operand's value and whether it is present
+func resolve(o operand, log datadogV2.Log) (interface{}, bool) {
+	if o.field == "" {
+		return o.literal, true
+	}
+	return logfield.Lookup(log, o.field)
+}
+
+// compare evaluates a comparison. != and !~ are the negations of == and =~,
+// so they hold for missing fields; ordering comparisons on a missing field
+// or on values that can't be ordered are false. A list such as tags
+// satisfies == and =~ when any element does.
+func compare(cmp comparison, log datadogV2.Log) bool {
+	l, lok := resolve(cmp.left, log)
+	r, rok := resolve(cmp.right, log)
+	if !lok {
+		l = nil
+	}
+	if !rok {
+		r = nil
+	}
+
+	switch cmp.op {
+	case "==":
+		return anyOf(l, func(v interface{}) bool { return equal(v, r) })
+	case "!=":
+		return !anyOf(l, func(v interface{}) bool { return equal(v, r) })
+	case "=~":
+		return anyOf(l, func(v interface{}) bool { return v != nil && cmp.re.MatchString(text(v)) })
+	case "!~":
+		return !anyOf(l, func(v interface{}) bool { return v != nil && cmp.re.MatchString(text(v)) })
+	}
+
+	o, ok := order(l, r)
+	if !ok {
+		return false
+	}
+	switch cmp.op {
+	case "<":
+		return o < 0
+	case "<=":
+		return o <= 0
+	case ">":
+		return o > 0
+	case ">=":
+		return o >= 0
+	}
+	return false
+}
+
+// anyOf applies fn to v, or to each element when v is a list
+func anyOf(v interface{}, fn func(interface{}) bool) bool {
+	switch list := v.(type) {
+	case []string:
+		for _, s := range list {
+			if fn(s) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, e := range list {
+			if fn(e) {
+				return true
+			}
+		}
+		return false
+	}
+	return fn(v)
+}
+
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		return ok && ab == bb
+	}
+	if o, ok := order(a, b); ok {
+		return o == 0
+	}
+	return text(a) == text(b)
+}
+
+// order compares two values: as times when either is a timestamp, as
+// numbers when either is a number and as text when both are strings
+func order(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	_, at := a.(time.Time)
+	_, bt := b.(time.Time)
+	if at || bt {
+		ta, ok1 := toTime(a)
+		tb, ok2 := toTime(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return ta.Compare(tb), true
+	}
+
+	if isNumber(a) || isNumber(b) {
+		fa, ok1 := toFloat(a)
+		fb, ok2 := toFloat(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	sa, ok1 := a.(string)
+	sb, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, int64, json.Number:
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	case float64:
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
+
+// text renders a value for regular expression matching
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLog() datadogV2.Log {
+	id := "log-1"
+	message := "GET /checkout timed out"
+	status := "error"
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Message:   &message,
+			Status:    &status,
+			Timestamp: &ts,
+			Tags:      []string{"env:prod", "team:payments"},
+			Attributes: map[string]interface{}{
+				"duration": 750.0,
+				"retries":  "3",
+				"cached":   false,
+				"http":     map[string]interface{}{"status_code": 504.0},
+			},
+		},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	log := testLog()
+	for expr, want := range map[string]bool{
+		`attributes.duration > 500 && status == "error"`: true,
+		`attributes.duration > 500 && status == "info"`:  false,
+		`duration <= 750`:                    true,
+		`duration < 750`:                     false,
+		`500 < duration`:                     true,
+		`@http.status_code >= 500`:           true,
+		`http.status_code == 504`:            true,
+		`retries == 3`:                       true,
+		`retries > 2`:                        true,
+		`status > "debug"`:                   true,
+		`status > 1`:                         false,
+		`missing > 1`:                        false,
+		`missing == null`:                    true,
+		`missing != "x"`:                     true,
+		`status != null`:                     true,
+		`cached == false`:                    true,
+		`cached`:                             false,
+		`duration`:                           true,
+		`missing`:                            false,
+		`!missing`:                           true,
+		`message =~ "timed? out"`:            true,
+		`message !~ "^POST"`:                 true,
+		`missing !~ "x"`:                     true,
+		`missing =~ ".*"`:                    false,
+		`tags == "env:prod"`:                 true,
+		`tags != "env:staging"`:              true,
+		`tags =~ "^team:"`:                   true,
+		`timestamp > "2024-01-15"`:           true,
+		`timestamp < "2024-01-15T10:00:00Z"`: false,
+		`(status == "info" || duration > 700) && !(tags == "env:dev")`: true,
+	} {
+		f, err := Parse(expr)
+		require.NoError(t, err, expr)
+		assert.Equal(t, want, f.Match(log), expr)
+	}
+}
+
+func TestPage(t *testing.T) {
+	slow := testLog()
+	fast := testLog()
+	fast.Attributes.Attributes = map[string]interface{}{"duration": 10.0}
+
+	byDuration, err := Parse(`duration > 500`)
+	require.NoError(t, err)
+	byStatus, err := Parse(`status == "error"`)
+	require.NoError(t, err)
+
+	kept := Page([]*Filter{byDuration, byStatus}, []datadogV2.Log{fast, slow, fast})
+	require.Len(t, kept, 1)
+	assert.Equal(t, 750.0, kept[0].Attributes.Attributes["duration"])
+
+	logs := []datadogV2.Log{fast, slow}
+	assert.Len(t, Page(nil, logs), 2)
+	assert.Equal(t, `duration > 500`, byDuration.String())
+}
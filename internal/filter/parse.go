@@ -0,0 +1,274 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// node is a parsed expression
+type node interface {
+	node()
+}
+
+// and is true when both sides are
+type and struct{ left, right node }
+
+// or is true when either side is
+type or struct{ left, right node }
+
+// not negates an expression
+type not struct{ expr node }
+
+// comparison applies a binary operator to two operands
+type comparison struct {
+	op          string
+	left, right operand
+	re          *regexp.Regexp // compiled right operand of =~ and !~
+}
+
+// operand is a field path or a literal; a bare operand used as a predicate
+// is true when it is present and not null or false
+type operand struct {
+	field   string      // empty for literals
+	literal interface{} // string, float64, bool or nil
+}
+
+func (and) node()        {}
+func (or) node()         {}
+func (not) node()        {}
+func (comparison) node() {}
+func (operand) node()    {}
+
+// token kinds
+const (
+	tokEOF = iota
+	tokField
+	tokString
+	tokNumber
+	tokSymbol
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// literals are the reserved words that stand for values, not fields
+var literals = map[string]interface{}{"true": true, "false": false, "null": nil}
+
+// symbols lists operators and punctuation, longest first
+var symbols = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+// comparisonOps are the binary comparison operators
+var comparisonOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "=~": true, "!~": true}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	r := []rune(src)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(r) {
+					return nil, fmt.Errorf("unterminated string at offset %d", start)
+				}
+				if r[i] == '\\' && i+1 < len(r) {
+					b.WriteRune(r[i+1])
+					i += 2
+					continue
+				}
+				if r[i] == c {
+					i++
+					break
+				}
+				b.WriteRune(r[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start})
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(r) && unicode.IsDigit(r[i+1])):
+			start := i
+			i++
+			for i < len(r) && (unicode.IsDigit(r[i]) || r[i] == '.' || r[i] == 'e' || r[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(r[start:i]), pos: start})
+		case isFieldRune(c):
+			start := i
+			for i < len(r) && (isFieldRune(r[i]) || unicode.IsDigit(r[i]) || r[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokField, text: string(r[start:i]), pos: start})
+		default:
+			sym := matchSymbol(r[i:])
+			if sym == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokSymbol, text: sym, pos: i})
+			i += len(sym)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(r)}), nil
+}
+
+func matchSymbol(r []rune) string {
+	head := string(r[:min(len(r), 2)])
+	for _, sym := range symbols {
+		if strings.HasPrefix(head, sym) {
+			return sym
+		}
+	}
+	return ""
+}
+
+// isFieldRune reports whether c can start a field path; dots and @ allow
+// attribute paths such as @http.status_code
+func isFieldRune(c rune) bool {
+	return unicode.IsLetter(c) || c == '_' || c == '.' || c == '@'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given symbol
+func (p *parser) accept(sym string) bool {
+	t := p.peek()
+	if t.kind == tokSymbol && t.text == sym {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parses the lowest precedence level: a || b
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses a && b, which binds tighter than ||
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+// parseUnary parses negation, parentheses and comparisons
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{expr}, nil
+	}
+	if p.accept("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected )")
+		}
+		return expr, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokSymbol || !comparisonOps[t.text] {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	cmp := comparison{op: t.text, left: left, right: right}
+	if cmp.op == "=~" || cmp.op == "!~" {
+		pattern, ok := right.literal.(string)
+		if right.field != "" || !ok {
+			return nil, fmt.Errorf("%s needs a quoted regular expression at offset %d", cmp.op, t.pos)
+		}
+		if cmp.re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+	}
+	return cmp, nil
+}
+
+// parseOperand parses a field path or literal
+func (p *parser) parseOperand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return operand{literal: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return operand{literal: f}, nil
+	case tokField:
+		if v, ok := literals[t.text]; ok {
+			return operand{literal: v}, nil
+		}
+		return operand{field: t.text}, nil
+	case tokEOF:
+		return operand{}, fmt.Errorf("unexpected end of expression")
+	}
+	return operand{}, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+func (p *parser) errorf(msg string) error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return fmt.Errorf("%s at end of expression", msg)
+	}
+	return fmt.Errorf("%s at offset %d, found %q", msg, t.pos, t.text)
+}
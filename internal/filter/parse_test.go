@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	f, err := Parse(`a == 1 || b == 2 && !c`)
+	require.NoError(t, err)
+
+	root, ok := f.root.(or)
+	require.True(t, ok, "|| should bind loosest")
+	assert.Equal(t, comparison{op: "==", left: operand{field: "a"}, right: operand{literal: 1.0}}, root.left)
+	right, ok := root.right.(and)
+	require.True(t, ok)
+	assert.Equal(t, not{operand{field: "c"}}, right.right)
+}
+
+func TestParseOperands(t *testing.T) {
+	f, err := Parse(`(@http.status_code >= -1.5e2) && x != null && y == 'it\'s' && z == true`)
+	require.NoError(t, err)
+
+	var cmps []comparison
+	var collect func(node)
+	collect = func(n node) {
+		switch n := n.(type) {
+		case and:
+			collect(n.left)
+			collect(n.right)
+		case comparison:
+			cmps = append(cmps, n)
+		}
+	}
+	collect(f.root)
+
+	require.Len(t, cmps, 4)
+	assert.Equal(t, operand{field: "@http.status_code"}, cmps[0].left)
+	assert.Equal(t, operand{literal: -150.0}, cmps[0].right)
+	assert.Equal(t, operand{literal: nil}, cmps[1].right)
+	assert.Equal(t, operand{literal: "it's"}, cmps[2].right)
+	assert.Equal(t, operand{literal: true}, cmps[3].right)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`duration >`,
+		`(status == "error"`,
+		`status == "error`,
+		`status = "error"`,
+		`status == "a" "b"`,
+		`message =~ 5`,
+		`message =~ "("`,
+		`a && || b`,
+	} {
+		_, err := Parse(src)
+		assert.Error(t, err, src)
+	}
+}
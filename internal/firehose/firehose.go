@@ -0,0 +1,200 @@
+// Package firehose is a minimal client for Amazon Data Firehose's
+// PutRecordBatch API, signing requests with AWS Signature Version 4, so
+// logs can be delivered to a stream without the AWS SDK
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Limits of a PutRecordBatch request
+const (
+	MaxBatchRecords = 500
+	MaxBatchBytes   = 4 << 20    // record data across the request
+	MaxRecordBytes  = 1000 << 10 // one record's data
+)
+
+// Client puts records to one delivery stream
+type Client struct {
+	stream   string
+	region   string
+	endpoint string
+	creds    Credentials
+	http     *http.Client
+	now      func() time.Time
+}
+
+// NewClient creates a client for stream in region, sending to endpoint, or
+// to the region's Firehose endpoint if empty
+func NewClient(stream, region, endpoint string, creds Credentials) *Client {
+	if endpoint == "" {
+		endpoint = "https://firehose." + region + ".amazonaws.com"
+	}
+	return &Client{
+		stream:   stream,
+		region:   region,
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/",
+		creds:    creds,
+		http:     &http.Client{Timeout: 30 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// CredentialsFromEnv reads credentials from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+func CredentialsFromEnv() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("firehose output requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return creds, nil
+}
+
+// RegionFromEnv returns AWS_REGION, or AWS_DEFAULT_REGION if it's unset
+func RegionFromEnv() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// Error is a request Firehose failed, with the exception it named
+type Error struct {
+	Status  int
+	Type    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("firehose request failed: %d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("firehose request failed: %s: %s", e.Type, e.Message)
+}
+
+// Retryable reports whether the request may succeed if sent again: the
+// stream was throttled or Firehose was unavailable
+func (e *Error) Retryable() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500 ||
+		e.Type == "ServiceUnavailableException" || e.Type == "ThrottlingException"
+}
+
+// Retryable reports whether a failed PutRecordBatch may succeed if sent
+// again: Firehose throttled or was unavailable, or the request never got a
+// complete response
+func Retryable(err error) bool {
+	var fhErr *Error
+	if errors.As(err, &fhErr) {
+		return fhErr.Retryable()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+type record struct {
+	Data []byte // base64 encoded by encoding/json
+}
+
+type putRecordBatchRequest struct {
+	DeliveryStreamName string
+	Records            []record
+}
+
+type putRecordBatchResponse struct {
+	FailedPutCount   int
+	RequestResponses []struct {
+		RecordId     string
+		ErrorCode    string
+		ErrorMessage string
+	}
+}
+
+// PutRecordBatch puts records to the stream, returning the indexes of any
+// Firehose failed to accept, and the error code of the first of them. The
+// batch must be within MaxBatchRecords and MaxBatchBytes.
+func (c *Client) PutRecordBatch(records [][]byte) ([]int, string, error) {
+	req := putRecordBatchRequest{DeliveryStreamName: c.stream, Records: make([]record, len(records))}
+	for i, data := range records {
+		req.Records[i] = record{Data: data}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	httpReq.Header.Set("X-Amz-Target", "Firehose_20150804.PutRecordBatch")
+	sign(httpReq, body, c.creds, c.region, "firehose", c.now())
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, "", fmt.Errorf("firehose request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("firehose request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", responseError(resp.StatusCode, respBody)
+	}
+
+	var out putRecordBatchResponse
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return nil, "", fmt.Errorf("failed to parse firehose response: %w", err)
+	}
+	if out.FailedPutCount == 0 {
+		return nil, "", nil
+	}
+	var failed []int
+	var code string
+	for i, r := range out.RequestResponses {
+		if r.ErrorCode == "" {
+			continue
+		}
+		if code == "" {
+			code = r.ErrorCode
+		}
+		failed = append(failed, i)
+	}
+	return failed, code, nil
+}
+
+// responseError builds an Error from a failed response, whose body names
+// the exception as "__type" (prefixed with its namespace, sometimes) and
+// describes it as "message" or "Message"
+func responseError(status int, body []byte) *Error {
+	var out struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	json.Unmarshal(body, &out)
+	typ := out.Type
+	if i := strings.LastIndex(typ, "#"); i >= 0 {
+		typ = typ[i+1:]
+	}
+	msg := out.Message
+	if msg == "" {
+		msg = out.MessageUpper
+	}
+	return &Error{Status: status, Type: typ, Message: msg}
+}
@@ -0,0 +1,34 @@
+package firehose
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutRecordBatchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.firehose#ResourceNotFoundException","message":"Stream logs not found"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("logs", "us-east-1", server.URL, Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	_, _, err := c.PutRecordBatch([][]byte{[]byte("{}\n")})
+	var fhErr *Error
+	require.ErrorAs(t, err, &fhErr)
+	assert.Equal(t, "ResourceNotFoundException", fhErr.Type)
+	assert.False(t, fhErr.Retryable())
+	assert.EqualError(t, err, "firehose request failed: ResourceNotFoundException: Stream logs not found")
+
+	assert.True(t, (&Error{Status: http.StatusBadRequest, Type: "ServiceUnavailableException"}).Retryable())
+	assert.True(t, (&Error{Status: http.StatusInternalServerError}).Retryable())
+
+	assert.False(t, Retryable(err))
+	_, _, err = NewClient("logs", "us-east-1", "http://127.0.0.1:1", Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}).PutRecordBatch([][]byte{[]byte("{}\n")})
+	require.Error(t, err)
+	assert.True(t, Retryable(err), "network errors are retried")
+}
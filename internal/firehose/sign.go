@@ -0,0 +1,103 @@
+package firehose
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials are the AWS keys requests are signed with
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // for temporary credentials; empty otherwise
+}
+
+// sign adds AWS Signature Version 4 headers to req, whose body is body, for
+// service in region at now
+func sign(req *http.Request, body []byte, creds Credentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	// Every header set so far is signed, along with the host
+	names := []string{"host"}
+	values := map[string]string{"host": req.Host}
+	for name, v := range req.Header {
+		name = strings.ToLower(name)
+		names = append(names, name)
+		values[name] = strings.Join(v, ",")
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + strings.TrimSpace(values[name]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		signed,
+		hashHex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonical))
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signed, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, then value
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, v := range values {
+			params = append(params, escape(name)+"="+escape(v))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// escape percent-encodes s as SigV4 expects: everything but unreserved
+// characters, with spaces as %20
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
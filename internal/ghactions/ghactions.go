@@ -0,0 +1,99 @@
+package ghactions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SummaryEnv names the file GitHub Actions renders as the job summary
+const SummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// Annotation is an ::error, ::warning or ::notice workflow command, shown on
+// the workflow run and in the checks of the commit it ran on
+type Annotation struct {
+	Level   string // "error", "warning" or "notice"
+	Title   string
+	Message string
+}
+
+// WriteAnnotation writes a workflow command, escaping it so that newlines,
+// colons and commas in the title or message can't break out of it
+func WriteAnnotation(w io.Writer, a Annotation) error {
+	cmd := "::" + a.Level
+	if a.Title != "" {
+		cmd += " title=" + escapeProperty(a.Title)
+	}
+	_, err := fmt.Fprintf(w, "%s::%s\n", cmd, escapeData(a.Message))
+	return err
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// Summary builds the Markdown for a job summary
+type Summary struct {
+	b strings.Builder
+}
+
+// Heading adds a heading at the given level, 1 to 6
+func (s *Summary) Heading(level int, text string) {
+	fmt.Fprintf(&s.b, "%s %s\n\n", strings.Repeat("#", level), text)
+}
+
+// Paragraph adds a paragraph of Markdown
+func (s *Summary) Paragraph(text string) {
+	fmt.Fprintf(&s.b, "%s\n\n", text)
+}
+
+// Table adds a table; cells are plain text, escaped so pipes and newlines
+// don't break the layout
+func (s *Summary) Table(header []string, rows [][]string) {
+	s.row(header)
+	s.b.WriteString("|")
+	for range header {
+		s.b.WriteString(" --- |")
+	}
+	s.b.WriteString("\n")
+	for _, r := range rows {
+		s.row(r)
+	}
+	s.b.WriteString("\n")
+}
+
+func (s *Summary) row(cells []string) {
+	s.b.WriteString("|")
+	for _, c := range cells {
+		s.b.WriteString(" " + escapeCell(c) + " |")
+	}
+	s.b.WriteString("\n")
+}
+
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(s)
+}
+
+// String returns the Markdown built so far
+func (s *Summary) String() string {
+	return s.b.String()
+}
+
+// AppendSummary appends Markdown to the job summary file at path; steps
+// share the file, so it is never truncated
+func AppendSummary(path, markdown string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(markdown); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,50 @@
+package ghactions
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAnnotation(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteAnnotation(&buf, Annotation{
+		Level:   "error",
+		Title:   "Assertion failed: no-match, 100%",
+		Message: "3 logs matched\n  - first: a::b",
+	}))
+	assert.Equal(t, "::error title=Assertion failed%3A no-match%2C 100%25::3 logs matched%0A  - first: a::b\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteAnnotation(&buf, Annotation{Level: "notice", Message: "plain"}))
+	assert.Equal(t, "::notice::plain\n", buf.String())
+}
+
+func TestSummary(t *testing.T) {
+	var s Summary
+	s.Heading(2, "dogfetch")
+	s.Paragraph("done")
+	s.Table([]string{"Field", "Value"}, [][]string{
+		{"query", "status:error | service:web"},
+		{"error", "line one\nline two"},
+	})
+
+	assert.Equal(t, "## dogfetch\n\ndone\n\n"+
+		"| Field | Value |\n| --- | --- |\n"+
+		"| query | status:error \\| service:web |\n"+
+		"| error | line one<br>line two |\n\n", s.String())
+}
+
+func TestAppendSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	require.NoError(t, os.WriteFile(path, []byte("earlier step\n"), 0644))
+
+	require.NoError(t, AppendSummary(path, "## dogfetch\n"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier step\n## dogfetch\n", string(data))
+}
@@ -0,0 +1,187 @@
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// maxDepth bounds pattern expansion, catching patterns that refer to
+// themselves
+const maxDepth = 20
+
+// reference matches %{PATTERN}, %{PATTERN:field} and %{PATTERN:field:type}
+var reference = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(int|float|bool|string))?\}`)
+
+// Pattern extracts fields from a message with a grok expression or a
+// regular expression with named groups
+type Pattern struct {
+	src    string
+	re     *regexp.Regexp
+	fields []field // indexed by subexpression; unnamed groups aren't captured
+}
+
+// field is a named capture and the type its text is converted to
+type field struct {
+	name string
+	typ  string
+}
+
+// Compile compiles an expression; it is treated as grok when it contains a
+// %{...} reference and as a regular expression with named groups otherwise
+// Named groups written directly in a grok expression are captured as strings.
+// At least one field must be captured.
+func Compile(expr string) (*Pattern, error) {
+	named := make(map[string]field)
+	src, err := expand(expr, named, 0)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", expr, err)
+	}
+
+	p := &Pattern{src: expr, re: re, fields: make([]field, re.NumSubexp()+1)}
+	captures := false
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		f, ok := named[name]
+		if !ok {
+			f = field{name: name, typ: "string"}
+		}
+		p.fields[i] = f
+		captures = true
+	}
+	if !captures {
+		return nil, fmt.Errorf("pattern %q captures no fields; name them with %%{PATTERN:field} or (?P<field>...)", expr)
+	}
+	return p, nil
+}
+
+// expand replaces grok references with their definitions, turning named
+// references into groups whose generated names are mapped to fields
+func expand(expr string, named map[string]field, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("grok patterns nest more than %d deep; is one recursive?", maxDepth)
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, m := range reference.FindAllStringSubmatchIndex(expr, -1) {
+		out.WriteString(expr[last:m[0]])
+		last = m[1]
+
+		name := expr[m[2]:m[3]]
+		def, ok := patterns[name]
+		if !ok {
+			return "", fmt.Errorf("unknown grok pattern %%{%s}", name)
+		}
+		inner, err := expand(def, named, depth+1)
+		if err != nil {
+			return "", err
+		}
+		if m[4] < 0 {
+			out.WriteString("(?:" + inner + ")")
+			continue
+		}
+
+		f := field{name: expr[m[4]:m[5]], typ: "string"}
+		if m[6] >= 0 {
+			f.typ = expr[m[6]:m[7]]
+		}
+		group := fmt.Sprintf("grok%d", len(named))
+		named[group] = f
+		fmt.Fprintf(&out, "(?P<%s>%s)", group, inner)
+	}
+	out.WriteString(expr[last:])
+	return out.String(), nil
+}
+
+// String returns the expression the pattern was compiled from
+func (p *Pattern) String() string {
+	return p.src
+}
+
+// Match extracts the captured fields from a message; groups that took no
+// part in the match are left out
+func (p *Pattern) Match(message string) (map[string]interface{}, bool) {
+	m := p.re.FindStringSubmatchIndex(message)
+	if m == nil {
+		return nil, false
+	}
+
+	values := make(map[string]interface{})
+	for i, f := range p.fields {
+		if f.name == "" || m[2*i] < 0 {
+			continue
+		}
+		values[f.name] = convert(message[m[2*i]:m[2*i+1]], f.typ)
+	}
+	return values, true
+}
+
+// convert returns text as the requested type, or unchanged if it doesn't
+// parse
+func convert(text, typ string) interface{} {
+	switch typ {
+	case "int":
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	}
+	return text
+}
+
+// Parser merges the fields of the first matching pattern into each log
+type Parser struct {
+	patterns []*Pattern
+}
+
+// NewParser creates a parser that tries patterns in order
+func NewParser(patterns []*Pattern) *Parser {
+	return &Parser{patterns: patterns}
+}
+
+// Page parses every log of a page in place
+func (p *Parser) Page(logs []datadogV2.Log) {
+	for i := range logs {
+		p.Log(&logs[i])
+	}
+}
+
+// Log parses a log's message, reporting whether a pattern matched
+// Fields are set like logfield.Set, so dotted names nest and message,
+// status, service and host replace the reserved attributes.
+func (p *Parser) Log(log *datadogV2.Log) bool {
+	message, ok := logfield.LookupString(*log, "message")
+	if !ok {
+		return false
+	}
+
+	for _, pattern := range p.patterns {
+		values, ok := pattern.Match(message)
+		if !ok {
+			continue
+		}
+		for name, v := range values {
+			logfield.Set(log, name, v)
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package grok
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileGrok(t *testing.T) {
+	p, err := Compile(`%{TIMESTAMP_ISO8601:time} %{LOGLEVEL:level} \[(%{WORD:thread})\] took %{NUMBER:duration:float}ms (user=%{USERNAME:usr.name})?`)
+	require.NoError(t, err)
+
+	values, ok := p.Match("2024-01-15T10:30:00Z WARN [main] took 512.5ms user=ada")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		"time":     "2024-01-15T10:30:00Z",
+		"level":    "WARN",
+		"thread":   "main",
+		"duration": 512.5,
+		"usr.name": "ada",
+	}, values)
+
+	// Optional groups that didn't take part are left out
+	values, ok = p.Match("2024-01-15 10:30:00 info [worker1] took 3ms ")
+	require.True(t, ok)
+	assert.NotContains(t, values, "usr.name")
+
+	_, ok = p.Match("not a log line")
+	assert.False(t, ok)
+}
+
+func TestCompileRegex(t *testing.T) {
+	p, err := Compile(`order (?P<order_id>\d+) failed: (?<reason>.+)`)
+	require.NoError(t, err)
+
+	values, ok := p.Match("order 42 failed: card declined")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"order_id": "42", "reason": "card declined"}, values)
+	assert.Equal(t, `order (?P<order_id>\d+) failed: (?<reason>.+)`, p.String())
+}
+
+func TestCompileApacheLog(t *testing.T) {
+	p, err := Compile(`%{COMBINEDAPACHELOG}`)
+	require.NoError(t, err)
+
+	values, ok := p.Match(`203.0.113.9 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://example.com/start" "Mozilla/4.08"`)
+	require.True(t, ok)
+	assert.Equal(t, "203.0.113.9", values["clientip"])
+	assert.Equal(t, "frank", values["auth"])
+	assert.Equal(t, "10/Oct/2000:13:55:36 -0700", values["request_time"])
+	assert.Equal(t, "GET", values["verb"])
+	assert.Equal(t, "/apache_pb.gif", values["request"])
+	assert.Equal(t, int64(200), values["response"])
+	assert.Equal(t, int64(2326), values["bytes"])
+	assert.Equal(t, `"Mozilla/4.08"`, values["agent"])
+}
+
+func TestConvertKeepsUnparsableText(t *testing.T) {
+	p, err := Compile(`code=%{NOTSPACE:code:int} ok=%{WORD:ok:bool}`)
+	require.NoError(t, err)
+
+	values, ok := p.Match("code=n/a ok=true")
+	require.True(t, ok)
+	assert.Equal(t, "n/a", values["code"])
+	assert.Equal(t, true, values["ok"])
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		`%{NOPE:x}`,
+		`%{WORD:x} (`,
+		`%{WORD} %{INT}`,
+		`no named groups (\d+)`,
+	} {
+		_, err := Compile(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestLibraryPatternsCompile(t *testing.T) {
+	for name := range patterns {
+		_, err := Compile("%{" + name + ":value}")
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestParser(t *testing.T) {
+	access, err := Compile(`^%{IP:client.ip} %{WORD:http.method} %{URIPATHPARAM:http.url} %{INT:http.status_code:int}$`)
+	require.NoError(t, err)
+	level, err := Compile(`^(?P<status>[a-z]+): `)
+	require.NoError(t, err)
+	parser := NewParser([]*Pattern{access, level})
+
+	msg1 := "10.0.0.1 GET /cart?id=7 503"
+	msg2 := "error: disk full"
+	msg3 := "unstructured"
+	logs := []datadogV2.Log{
+		{Attributes: &datadogV2.LogAttributes{Message: &msg1, Attributes: map[string]interface{}{"kept": true}}},
+		{Attributes: &datadogV2.LogAttributes{Message: &msg2}},
+		{Attributes: &datadogV2.LogAttributes{Message: &msg3}},
+		{},
+	}
+	parser.Page(logs)
+
+	assert.Equal(t, map[string]interface{}{
+		"kept":   true,
+		"client": map[string]interface{}{"ip": "10.0.0.1"},
+		"http": map[string]interface{}{
+			"method":      "GET",
+			"url":         "/cart?id=7",
+			"status_code": int64(503),
+		},
+	}, logs[0].Attributes.Attributes)
+	assert.Equal(t, "error", logs[1].Attributes.GetStatus())
+	assert.Nil(t, logs[2].Attributes.Attributes)
+	assert.False(t, parser.Log(&logs[3]))
+}
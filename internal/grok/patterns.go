@@ -0,0 +1,61 @@
+package grok
+
+// patterns is the built-in library, a subset of the Logstash grok patterns
+// rewritten for RE2 (no lookarounds or atomic groups)
+// The Apache log patterns name the request time request_time rather than
+// timestamp, which is reserved for the log's own timestamp.
+var patterns = map[string]string{
+	// Words and numbers
+	"WORD":         `\b\w+\b`,
+	"NOTSPACE":     `\S+`,
+	"SPACE":        `\s*`,
+	"DATA":         `.*?`,
+	"GREEDYDATA":   `.*`,
+	"INT":          `[+-]?\d+`,
+	"POSINT":       `\b[1-9]\d*\b`,
+	"NONNEGINT":    `\b\d+\b`,
+	"BASE10NUM":    `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+	"NUMBER":       `%{BASE10NUM}`,
+	"BASE16NUM":    `(?:0[xX])?[0-9A-Fa-f]+`,
+	"QUOTEDSTRING": `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"QS":           `%{QUOTEDSTRING}`,
+	"UUID":         `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"LOGLEVEL":     `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Ee]merg(?:ency)?|EMERG(?:ENCY)?)`,
+
+	// Users and hosts
+	"USERNAME":     `[a-zA-Z0-9._-]+`,
+	"USER":         `%{USERNAME}`,
+	"EMAILADDRESS": `[a-zA-Z0-9._%+-]+@%{HOSTNAME}`,
+	"IPV4":         `(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)`,
+	"IPV6":         `(?:[0-9A-Fa-f]{0,4}:){2,7}(?:%{IPV4}|[0-9A-Fa-f]{0,4})`,
+	"IP":           `(?:%{IPV4}|%{IPV6})`,
+	"HOSTNAME":     `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":     `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":     `%{IPORHOST}:%{POSINT}`,
+
+	// Paths and URIs
+	"PATH":         `(?:/[^\s?#]*)+`,
+	"URIPATH":      `(?:/[A-Za-z0-9$.+!*'(){},~:;=@%&_\-]*)+`,
+	"URIPARAM":     `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM": `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":          `[A-Za-z][A-Za-z0-9+\-.]*://\S+`,
+
+	// Dates and times
+	"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]un(?:e)?|[Jj]ul(?:y)?|[Aa]ug(?:ust)?|[Ss]ep(?:t(?:ember)?)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:0[1-9]|[12]\d|3[01]|[1-9])`,
+	"YEAR":              `\d\d(?:\d\d)?`,
+	"HOUR":              `(?:2[0123]|[01]?\d)`,
+	"MINUTE":            `[0-5]\d`,
+	"SECOND":            `(?:[0-5]?\d|60)(?:[:.,]\d+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+
+	// Common formats
+	"COMMONAPACHELOG":   `%{IPORHOST:clientip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:request_time}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response:int} (?:%{NUMBER:bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+	"SYSLOGLINE":        `%{SYSLOGTIMESTAMP:syslog_time} %{IPORHOST:logsource} %{DATA:program}(?:\[%{POSINT:pid:int}\])?: %{GREEDYDATA:syslog_message}`,
+}
@@ -0,0 +1,147 @@
+package hold
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/encrypt"
+	"github.com/jtzemp/dogfetch/internal/manifest"
+	"github.com/jtzemp/dogfetch/internal/signing"
+	"github.com/jtzemp/dogfetch/internal/version"
+)
+
+// Bundle file names
+const (
+	LogsFile        = "logs.ndjson"
+	ManifestFile    = "manifest.json"
+	AttestationFile = "attestation.json"
+	SignatureFile   = "attestation.json.sig"
+)
+
+// Attestation records who exported what, and when, for a legal hold
+type Attestation struct {
+	Case           string    `json:"case"`
+	Query          string    `json:"query"`
+	Index          string    `json:"index"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Operator       string    `json:"operator"`
+	Hostname       string    `json:"hostname"`
+	CreatedAt      time.Time `json:"created_at"`
+	Tool           string    `json:"tool"`
+	Records        int       `json:"records"`
+	Encrypted      bool      `json:"encrypted"`
+	ManifestSHA256 string    `json:"manifest_sha256"`
+}
+
+// SealOptions controls how a bundle is sealed
+type SealOptions struct {
+	// Logs is the name of the logs file in the bundle, as LogsName returns
+	// (default LogsFile)
+	Logs string
+
+	// SigningKey, if set, signs the attestation
+	SigningKey crypto.Signer
+}
+
+// LogsName returns the name of a bundle's logs file: LogsFile, or with the
+// extension of e when the logs are encrypted by e as they're written, so
+// they never reach the disk in the clear
+func LogsName(e *encrypt.Encrypter) string {
+	if e == nil {
+		return LogsFile
+	}
+	return LogsFile + e.Extension()
+}
+
+// Seal finishes a bundle whose logs have been written to dir: it writes the
+// manifest, the attestation (which pins the manifest's checksum) and an
+// optional signature
+func Seal(dir string, a Attestation, opts SealOptions) error {
+	logs := opts.Logs
+	if logs == "" {
+		logs = LogsFile
+	}
+	logsPath := filepath.Join(dir, logs)
+	a.Encrypted = logs != LogsFile
+
+	m := manifest.New()
+	if err := m.AddFile(dir, logsPath, a.Records); err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(dir, ManifestFile)
+	if err := m.Write(manifestPath); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	sum, _, err := manifest.Checksum(manifestPath)
+	if err != nil {
+		return err
+	}
+	a.ManifestSHA256 = sum
+	a.CreatedAt = time.Now().UTC()
+	a.Tool = version.Info()
+	if a.Hostname == "" {
+		a.Hostname, _ = os.Hostname()
+	}
+
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	attestationPath := filepath.Join(dir, AttestationFile)
+	if err := os.WriteFile(attestationPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write attestation: %w", err)
+	}
+
+	if opts.SigningKey != nil {
+		if err := signing.SignFile(opts.SigningKey, attestationPath, filepath.Join(dir, SignatureFile)); err != nil {
+			return fmt.Errorf("failed to sign attestation: %w", err)
+		}
+	}
+	return nil
+}
+
+// Verify checks a sealed bundle: the attestation signature (if a public key
+// is given), the manifest checksum pinned by the attestation, and every file
+// listed in the manifest
+func Verify(dir string, pub crypto.PublicKey) (*Attestation, error) {
+	attestationPath := filepath.Join(dir, AttestationFile)
+
+	if pub != nil {
+		if err := signing.VerifyFile(pub, attestationPath, filepath.Join(dir, SignatureFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(attestationPath)
+	if err != nil {
+		return nil, err
+	}
+	var a Attestation
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("invalid attestation: %w", err)
+	}
+
+	manifestPath := filepath.Join(dir, ManifestFile)
+	sum, _, err := manifest.Checksum(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if sum != a.ManifestSHA256 {
+		return nil, fmt.Errorf("manifest checksum does not match the attestation")
+	}
+
+	m, err := manifest.Read(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Verify(dir); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,107 @@
+package hold
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/jtzemp/dogfetch/internal/encrypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeLogs(t, dir)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	require.NoError(t, Seal(dir, testAttestation(), SealOptions{SigningKey: priv}))
+
+	assert.FileExists(t, filepath.Join(dir, ManifestFile))
+	assert.FileExists(t, filepath.Join(dir, AttestationFile))
+	assert.FileExists(t, filepath.Join(dir, SignatureFile))
+
+	a, err := Verify(dir, pub)
+	require.NoError(t, err)
+	assert.Equal(t, "INC-123", a.Case)
+	assert.Equal(t, 2, a.Records)
+	assert.NotEmpty(t, a.ManifestSHA256)
+	assert.False(t, a.Encrypted)
+}
+
+func TestSealWithEncryption(t *testing.T) {
+	dir := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	e, err := encrypt.NewEncrypter([]string{identity.Recipient().String()})
+	require.NoError(t, err)
+
+	// The logs are encrypted as they're written, as the hold command does
+	logs := LogsName(e)
+	assert.Equal(t, LogsFile+".age", logs)
+	f, err := os.Create(filepath.Join(dir, logs))
+	require.NoError(t, err)
+	w, err := e.Encrypt(f)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("{\"id\":\"1\"}\n{\"id\":\"2\"}\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	require.NoError(t, Seal(dir, testAttestation(), SealOptions{Logs: logs}))
+	assert.NoFileExists(t, filepath.Join(dir, LogsFile))
+
+	a, err := Verify(dir, nil)
+	require.NoError(t, err)
+	assert.True(t, a.Encrypted)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	writeLogs(t, dir)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, Seal(dir, testAttestation(), SealOptions{SigningKey: priv}))
+
+	// Edited logs no longer match the manifest
+	require.NoError(t, os.WriteFile(filepath.Join(dir, LogsFile), []byte("{\"id\":\"forged\"}\n"), 0644))
+	_, err = Verify(dir, nil)
+	assert.Error(t, err)
+
+	// Regenerating the manifest doesn't help, the attestation pins it
+	writeLogs(t, dir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ManifestFile), []byte(`{"files":[]}`), 0644))
+	_, err = Verify(dir, nil)
+	assert.Error(t, err)
+
+	// And editing the attestation breaks the signature
+	require.NoError(t, os.WriteFile(filepath.Join(dir, AttestationFile), []byte(`{"case":"other"}`), 0644))
+	_, err = Verify(dir, pub)
+	assert.Error(t, err)
+}
+
+// Helper functions
+
+func writeLogs(t *testing.T, dir string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, LogsFile), []byte("{\"id\":\"1\"}\n{\"id\":\"2\"}\n"), 0644))
+}
+
+func testAttestation() Attestation {
+	return Attestation{
+		Case:     "INC-123",
+		Query:    "service:web",
+		Index:    "main",
+		From:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Operator: "alice",
+		Records:  2,
+	}
+}
@@ -0,0 +1,121 @@
+package indexes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+)
+
+// Index describes a log index: which logs it takes, how long it keeps them
+// and which it drops
+type Index struct {
+	Name              string      `json:"name"`
+	Filter            string      `json:"filter"`
+	RetentionDays     int64       `json:"retention_days,omitempty"`
+	FlexRetentionDays int64       `json:"flex_retention_days,omitempty"` // total, Standard and Flex Tier
+	DailyLimit        int64       `json:"daily_limit,omitempty"`
+	RateLimited       bool        `json:"rate_limited"`
+	Exclusions        []Exclusion `json:"exclusions,omitempty"`
+}
+
+// Exclusion is a filter dropping a share of the logs an index would take
+type Exclusion struct {
+	Name       string  `json:"name"`
+	Query      string  `json:"query"`
+	SampleRate float64 `json:"sample_rate"` // share of matching logs dropped
+	Enabled    bool    `json:"enabled"`
+}
+
+// FromAPI converts the Logs Indexes API's indexes, keeping their order: a
+// log goes to the first index whose filter it matches
+func FromAPI(list []datadogV1.LogsIndex) []Index {
+	out := make([]Index, 0, len(list))
+	for _, idx := range list {
+		index := Index{
+			Name:              idx.GetName(),
+			Filter:            idx.Filter.GetQuery(),
+			RetentionDays:     idx.GetNumRetentionDays(),
+			FlexRetentionDays: idx.GetNumFlexLogsRetentionDays(),
+			DailyLimit:        idx.GetDailyLimit(),
+			RateLimited:       idx.GetIsRateLimited(),
+		}
+		if index.Filter == "" {
+			index.Filter = "*"
+		}
+		for _, ex := range idx.ExclusionFilters {
+			exclusion := Exclusion{Name: ex.GetName(), Query: "*", Enabled: ex.GetIsEnabled()}
+			if ex.Filter != nil {
+				exclusion.SampleRate = ex.Filter.SampleRate
+				if q := ex.Filter.GetQuery(); q != "" {
+					exclusion.Query = q
+				}
+			}
+			index.Exclusions = append(index.Exclusions, exclusion)
+		}
+		out = append(out, index)
+	}
+	return out
+}
+
+// WriteText lists the indexes as a table, with each index's exclusion
+// filters under it
+func WriteText(w io.Writer, list []Index) error {
+	if len(list) == 0 {
+		_, err := fmt.Fprintf(w, "No log indexes\n")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tRETENTION\tDAILY LIMIT\tFILTER\n")
+	for _, index := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", index.Name, retention(index), dailyLimit(index), index.Filter)
+		for _, ex := range index.Exclusions {
+			state := ""
+			if !ex.Enabled {
+				state = ", disabled"
+			}
+			fmt.Fprintf(tw, "  excludes %s\t\t\t%s (%s of matches%s)\n", ex.Name, ex.Query, percent(ex.SampleRate), state)
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the indexes as a JSON document
+func WriteJSON(w io.Writer, list []Index) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+func retention(index Index) string {
+	if index.RetentionDays == 0 {
+		if index.FlexRetentionDays > 0 {
+			return fmt.Sprintf("%dd flex", index.FlexRetentionDays)
+		}
+		return "-"
+	}
+	s := fmt.Sprintf("%dd", index.RetentionDays)
+	if index.FlexRetentionDays > index.RetentionDays {
+		s += fmt.Sprintf(" (+%dd flex)", index.FlexRetentionDays-index.RetentionDays)
+	}
+	return s
+}
+
+func dailyLimit(index Index) string {
+	if index.DailyLimit == 0 {
+		return "none"
+	}
+	s := fmt.Sprint(index.DailyLimit)
+	if index.RateLimited {
+		s += " (reached)"
+	}
+	return s
+}
+
+func percent(rate float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", rate*100), "0"), ".") + "%"
+}
@@ -0,0 +1,69 @@
+package indexes
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func apiIndexes(t *testing.T) []datadogV1.LogsIndex {
+	var resp datadogV1.LogsIndexListResponse
+	require.NoError(t, json.Unmarshal([]byte(`{"indexes":[
+		{"name":"audit","filter":{"query":"source:audit"},"num_retention_days":30,"num_flex_logs_retention_days":360,"daily_limit":1000000,"is_rate_limited":true,
+		 "exclusion_filters":[{"name":"debug","is_enabled":true,"filter":{"query":"status:debug","sample_rate":0.9}},{"name":"all","is_enabled":false,"filter":{"sample_rate":1}}]},
+		{"name":"main","filter":{"query":""},"num_retention_days":15}
+	]}`), &resp))
+	return resp.Indexes
+}
+
+func TestFromAPI(t *testing.T) {
+	list := FromAPI(apiIndexes(t))
+	require.Len(t, list, 2)
+
+	assert.Equal(t, Index{
+		Name:              "audit",
+		Filter:            "source:audit",
+		RetentionDays:     30,
+		FlexRetentionDays: 360,
+		DailyLimit:        1000000,
+		RateLimited:       true,
+		Exclusions: []Exclusion{
+			{Name: "debug", Query: "status:debug", SampleRate: 0.9, Enabled: true},
+			{Name: "all", Query: "*", SampleRate: 1},
+		},
+	}, list[0])
+	assert.Equal(t, Index{Name: "main", Filter: "*", RetentionDays: 15}, list[1])
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, FromAPI(apiIndexes(t))))
+	assert.Equal(t, ""+
+		"NAME              RETENTION         DAILY LIMIT        FILTER\n"+
+		"audit             30d (+330d flex)  1000000 (reached)  source:audit\n"+
+		"  excludes debug                                       status:debug (90% of matches)\n"+
+		"  excludes all                                         * (100% of matches, disabled)\n"+
+		"main              15d               none               *\n", buf.String())
+}
+
+func TestWriteTextEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, nil))
+	assert.Equal(t, "No log indexes\n", buf.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, FromAPI(apiIndexes(t))))
+
+	var got []map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	require.Len(t, got, 2)
+	assert.Equal(t, "audit", got[0]["name"])
+	assert.Equal(t, float64(360), got[0]["flex_retention_days"])
+	assert.NotContains(t, got[1], "exclusions")
+}
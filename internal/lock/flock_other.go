@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+var errHeld = errors.New("locked")
+
+// tryLock fails where there's no file locking to take; the error wraps
+// errors.ErrUnsupported so callers can decide to run unlocked
+func tryLock(f *os.File) error {
+	return fmt.Errorf("file locking is not supported on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
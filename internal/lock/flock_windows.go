@@ -0,0 +1,28 @@
+//go:build windows
+
+package lock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+var errHeld = windows.ERROR_LOCK_VIOLATION
+
+// lockOffsetHigh places the locked byte at 1<<62, well past the PID the
+// lock file records: Windows locks are mandatory, and locking the PID itself
+// would stop whoever runs into the lock from reading it
+const lockOffsetHigh = 1 << 30
+
+// tryLock takes an exclusive LockFileEx lock, which Windows releases when
+// the file is closed or the process exits
+func tryLock(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) || errors.Is(err, windows.ERROR_IO_PENDING) {
+		return errHeld
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Suffix is appended to a file's path to name its lock file
+const Suffix = ".lock"
+
+// HeldError reports a file locked by another process
+type HeldError struct {
+	Path string
+	PID  int // of the holder, or zero if it didn't record one
+}
+
+func (e *HeldError) Error() string {
+	holder := "another dogfetch"
+	if e.PID > 0 {
+		holder = fmt.Sprintf("another dogfetch (pid %d)", e.PID)
+	}
+	return fmt.Sprintf("%s is in use by %s; two runs writing it at once would corrupt it", e.Path, holder)
+}
+
+// Lock is an exclusive hold on a file, taken through a lock file beside it
+// The hold belongs to the process: it ends when the process does, however
+// it exits, so a crashed run never leaves a file locked. The lock file itself
+// stays behind, since removing it could race with the next run taking it.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire locks path for this process, failing with a *HeldError at once
+// rather than waiting if another process holds it
+func Acquire(path string) (*Lock, error) {
+	lockPath := path + Suffix
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	if err := tryLock(f); err != nil {
+		f.Close()
+		if errors.Is(err, errHeld) {
+			return nil, &HeldError{Path: path, PID: readPID(lockPath)}
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", lockPath, err)
+	}
+
+	// The holder's PID helps whoever runs into the lock find it
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return &Lock{f: f}, nil
+}
+
+// Release gives up the lock
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// readPID returns the process ID a lock file records, or zero
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
@@ -0,0 +1,38 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+
+	held, err := Acquire(path)
+	require.NoError(t, err)
+
+	// Locks are per open file, so a second hold conflicts even in-process
+	_, err = Acquire(path)
+	var heldErr *HeldError
+	require.True(t, errors.As(err, &heldErr), "got %v", err)
+	assert.Equal(t, path, heldErr.Path)
+	assert.Equal(t, os.Getpid(), heldErr.PID)
+	assert.Contains(t, err.Error(), "in use by another dogfetch (pid")
+
+	require.NoError(t, held.Release())
+	again, err := Acquire(path)
+	require.NoError(t, err)
+	require.NoError(t, again.Release())
+}
+
+func TestAcquireMissingDirectory(t *testing.T) {
+	_, err := Acquire(filepath.Join(t.TempDir(), "missing", "logs.ndjson"))
+	var heldErr *HeldError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &heldErr))
+}
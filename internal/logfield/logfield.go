@@ -0,0 +1,158 @@
+package logfield
+
+import (
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Lookup resolves a dotted field path against a log
+//
+// Paths follow Datadog's conventions: "id", "message", "status", "service",
+// "host", "timestamp" and "tags" address the reserved attributes, anything
+// else is looked up in the custom attributes. An optional "@" or
+// "attributes." prefix is accepted, so "service", "attributes.service" and
+// "@error.kind" all work.
+func Lookup(log datadogV2.Log, path string) (interface{}, bool) {
+	path = normalize(path)
+	if path == "" {
+		return nil, false
+	}
+
+	if path == "id" {
+		if log.Id == nil {
+			return nil, false
+		}
+		return *log.Id, true
+	}
+
+	attrs, ok := log.GetAttributesOk()
+	if !ok {
+		return nil, false
+	}
+
+	switch path {
+	case "message":
+		return derefString(attrs.Message)
+	case "status":
+		return derefString(attrs.Status)
+	case "service":
+		return derefString(attrs.Service)
+	case "host":
+		return derefString(attrs.Host)
+	case "timestamp":
+		if attrs.Timestamp == nil {
+			return nil, false
+		}
+		return *attrs.Timestamp, true
+	case "tags":
+		if attrs.Tags == nil {
+			return nil, false
+		}
+		return attrs.Tags, true
+	}
+
+	return lookupMap(attrs.Attributes, strings.Split(path, "."))
+}
+
+// LookupString resolves a field path and returns it as a string
+// Non-string values are reported as missing.
+func LookupString(log datadogV2.Log, path string) (string, bool) {
+	v, ok := Lookup(log, path)
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// Set assigns a value to the field at path, creating intermediate custom
+// attribute objects as needed
+// Reserved string attributes only accept string values.
+func Set(log *datadogV2.Log, path string, value interface{}) bool {
+	path = normalize(path)
+	if path == "" || path == "id" || path == "timestamp" || path == "tags" {
+		return false
+	}
+
+	if log.Attributes == nil {
+		log.Attributes = &datadogV2.LogAttributes{}
+	}
+	attrs := log.Attributes
+
+	switch path {
+	case "message", "status", "service", "host":
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		switch path {
+		case "message":
+			attrs.Message = &s
+		case "status":
+			attrs.Status = &s
+		case "service":
+			attrs.Service = &s
+		case "host":
+			attrs.Host = &s
+		}
+		return true
+	}
+
+	if attrs.Attributes == nil {
+		attrs.Attributes = make(map[string]interface{})
+	}
+
+	parts := strings.Split(path, ".")
+	m := attrs.Attributes
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+	return true
+}
+
+// normalize strips the optional "@" and "attributes." prefixes
+func normalize(path string) string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "@")
+	path = strings.TrimPrefix(path, "attributes.")
+	return path
+}
+
+func lookupMap(m map[string]interface{}, parts []string) (interface{}, bool) {
+	if m == nil {
+		return nil, false
+	}
+
+	// Datadog allows dotted keys at the top level, so prefer an exact match
+	if v, ok := m[strings.Join(parts, ".")]; ok {
+		return v, true
+	}
+
+	v, ok := m[parts[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(parts) == 1 {
+		return v, true
+	}
+
+	child, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupMap(child, parts[1:])
+}
+
+func derefString(s *string) (interface{}, bool) {
+	if s == nil {
+		return nil, false
+	}
+	return *s, true
+}
@@ -0,0 +1,106 @@
+package logfield
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	log := createTestLog()
+
+	tests := []struct {
+		name   string
+		path   string
+		want   interface{}
+		wantOk bool
+	}{
+		{name: "id", path: "id", want: "log-1", wantOk: true},
+		{name: "reserved attribute", path: "service", want: "web", wantOk: true},
+		{name: "attributes prefix", path: "attributes.status", want: "error", wantOk: true},
+		{name: "facet prefix", path: "@error.kind", want: "Timeout", wantOk: true},
+		{name: "nested attribute", path: "attributes.error.kind", want: "Timeout", wantOk: true},
+		{name: "dotted key", path: "http.status_code", want: float64(500), wantOk: true},
+		{name: "missing attribute", path: "missing", wantOk: false},
+		{name: "missing nested attribute", path: "error.missing", wantOk: false},
+		{name: "empty path", path: "", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Lookup(log, tt.path)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLookupWithoutAttributes(t *testing.T) {
+	_, ok := Lookup(datadogV2.Log{}, "service")
+	assert.False(t, ok)
+}
+
+func TestLookupString(t *testing.T) {
+	log := createTestLog()
+
+	got, ok := LookupString(log, "message")
+	require.True(t, ok)
+	assert.Equal(t, "request timed out", got)
+
+	_, ok = LookupString(log, "http.status_code")
+	assert.False(t, ok, "numeric values should not be returned as strings")
+}
+
+func TestSet(t *testing.T) {
+	log := createTestLog()
+
+	require.True(t, Set(&log, "message", "redacted"))
+	assert.Equal(t, "redacted", log.Attributes.GetMessage())
+
+	require.True(t, Set(&log, "@user.email", "x@example.com"))
+	got, ok := Lookup(log, "user.email")
+	require.True(t, ok)
+	assert.Equal(t, "x@example.com", got)
+
+	assert.False(t, Set(&log, "status", 42), "reserved attributes only accept strings")
+	assert.False(t, Set(&log, "id", "other"))
+}
+
+func TestSetWithoutAttributes(t *testing.T) {
+	var log datadogV2.Log
+	require.True(t, Set(&log, "duration", float64(12)))
+
+	got, ok := Lookup(log, "duration")
+	require.True(t, ok)
+	assert.Equal(t, float64(12), got)
+}
+
+// Helper functions
+
+func createTestLog() datadogV2.Log {
+	id := "log-1"
+	message := "request timed out"
+	status := "error"
+	service := "web"
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Message:   &message,
+			Status:    &status,
+			Service:   &service,
+			Timestamp: &ts,
+			Attributes: map[string]interface{}{
+				"error": map[string]interface{}{
+					"kind": "Timeout",
+				},
+				"http.status_code": float64(500),
+			},
+		},
+	}
+}
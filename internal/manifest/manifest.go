@@ -0,0 +1,192 @@
+package manifest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Version is the manifest format this build writes. Read migrates older
+// manifests and refuses newer ones
+const Version = 1
+
+// Manifest lists output files with their checksums so consumers can verify
+// an export's integrity
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+}
+
+// File describes a single output file
+type File struct {
+	Path    string `json:"path"`
+	SHA256  string `json:"sha256"`
+	Bytes   int64  `json:"bytes"`
+	Records int    `json:"records"`
+}
+
+// New creates an empty manifest
+func New() *Manifest {
+	return &Manifest{
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		Files:     []File{},
+	}
+}
+
+// AddFile checksums the file at path and adds it to the manifest
+// The entry's path is stored relative to baseDir when possible so the
+// manifest stays valid when the export directory is moved.
+func (m *Manifest) AddFile(baseDir, path string, records int) error {
+	sum, size, err := Checksum(path)
+	if err != nil {
+		return err
+	}
+
+	name := path
+	if baseDir != "" {
+		if rel, err := filepath.Rel(baseDir, path); err == nil {
+			name = rel
+		}
+	}
+
+	m.Files = append(m.Files, File{
+		Path:    filepath.ToSlash(name),
+		SHA256:  sum,
+		Bytes:   size,
+		Records: records,
+	})
+	return nil
+}
+
+// Write saves the manifest as indented JSON
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Read loads a manifest from disk, migrating it to the current format
+func Read(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the version before decoding the rest, whose layout may differ
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if header.Version < 0 {
+		return nil, fmt.Errorf("invalid manifest %s: unknown format version %d", path, header.Version)
+	}
+	if header.Version > Version {
+		return nil, fmt.Errorf("manifest %s uses format version %d, but this dogfetch only reads up to version %d", path, header.Version, Version)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	m.migrate()
+	return &m, nil
+}
+
+// migrate upgrades a manifest read from an older format one version at a
+// time
+func (m *Manifest) migrate() {
+	for m.Version < Version {
+		switch m.Version {
+		case 0:
+			// Manifests from before the format was versioned have the
+			// same fields
+		}
+		m.Version++
+	}
+}
+
+// Verify checks every file listed in the manifest, resolving relative paths
+// against baseDir
+func (m *Manifest) Verify(baseDir string) error {
+	for _, f := range m.Files {
+		path := filepath.FromSlash(f.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		sum, size, err := Checksum(path)
+		if err != nil {
+			return err
+		}
+		if size != f.Bytes {
+			return fmt.Errorf("%s: size is %d bytes, manifest says %d", f.Path, size, f.Bytes)
+		}
+		if sum != f.SHA256 {
+			return fmt.Errorf("%s: checksum mismatch", f.Path)
+		}
+	}
+	return nil
+}
+
+// CountLines returns the number of newline-terminated records in a file,
+// which for NDJSON is the record count; a .gz file's lines are counted
+// uncompressed
+func CountLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, err
+		}
+		r = zr
+	}
+
+	count := 0
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Checksum returns the hex SHA-256 and size of a file
+func Checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,107 @@
+package manifest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "logs.ndjson")
+	require.NoError(t, os.WriteFile(logs, []byte("{\"id\":\"1\"}\n{\"id\":\"2\"}\n"), 0644))
+
+	m := New()
+	require.NoError(t, m.AddFile(dir, logs, 2))
+	require.Len(t, m.Files, 1)
+	assert.Equal(t, "logs.ndjson", m.Files[0].Path)
+	assert.Equal(t, int64(22), m.Files[0].Bytes)
+	assert.Equal(t, 2, m.Files[0].Records)
+	assert.Len(t, m.Files[0].SHA256, 64)
+
+	path := filepath.Join(dir, "manifest.json")
+	require.NoError(t, m.Write(path))
+
+	read, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, m.Files, read.Files)
+	assert.NoError(t, read.Verify(dir))
+}
+
+func TestManifestVerifyDetectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "logs.ndjson")
+	require.NoError(t, os.WriteFile(logs, []byte("{\"id\":\"1\"}\n"), 0644))
+
+	m := New()
+	require.NoError(t, m.AddFile(dir, logs, 1))
+
+	require.NoError(t, os.WriteFile(logs, []byte("{\"id\":\"2\"}\n"), 0644))
+	err := m.Verify(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+
+	require.NoError(t, os.WriteFile(logs, []byte("{}\n"), 0644))
+	err = m.Verify(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "size")
+}
+
+func TestReadInvalidManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, os.WriteFile(path, []byte("nope"), 0644))
+
+	_, err := Read(path)
+	assert.Error(t, err)
+}
+
+func TestCountLines(t *testing.T) {
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "logs.ndjson")
+	require.NoError(t, os.WriteFile(logs, []byte("{\"id\":\"1\"}\n{\"id\":\"2\"}\n{\"id\":\"3\"}\n"), 0644))
+
+	n, err := CountLines(logs)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	empty := filepath.Join(dir, "empty.ndjson")
+	require.NoError(t, os.WriteFile(empty, nil, 0644))
+	n, err = CountLines(empty)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Appended gzip output is a series of members, all counted
+	gzipped := filepath.Join(dir, "logs.ndjson.gz")
+	var buf bytes.Buffer
+	for _, lines := range []string{"{\"id\":\"1\"}\n{\"id\":\"2\"}\n", "{\"id\":\"3\"}\n"} {
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(lines))
+		require.NoError(t, zw.Close())
+	}
+	require.NoError(t, os.WriteFile(gzipped, buf.Bytes(), 0644))
+	n, err = CountLines(gzipped)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestReadManifestVersions(t *testing.T) {
+	dir := t.TempDir()
+
+	legacy := filepath.Join(dir, "legacy.json")
+	require.NoError(t, os.WriteFile(legacy, []byte(`{"created_at":"2024-01-01T00:00:00Z","files":[{"path":"logs.ndjson","sha256":"00","bytes":1,"records":1}]}`), 0644))
+	m, err := Read(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, Version, m.Version)
+	assert.Len(t, m.Files, 1)
+
+	newer := filepath.Join(dir, "newer.json")
+	require.NoError(t, os.WriteFile(newer, []byte(`{"version":99,"files":{}}`), 0644))
+	_, err = Read(newer)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "format version 99")
+}
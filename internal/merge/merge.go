@@ -0,0 +1,281 @@
+package merge
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/sidecar"
+)
+
+// DefaultMaxMemory is the memory budget used when none is given
+const DefaultMaxMemory = 256 << 20
+
+// recordOverhead is what a record costs in memory beside its line, on a
+// 64-bit platform: its time.Time (24 bytes), the id's string header (16)
+// and the line's slice header (24). The id's own bytes, a few dozen at
+// most, are left out of the estimate.
+const recordOverhead = 64
+
+// Options configures a merge
+type Options struct {
+	// MaxMemory caps the bytes of logs sorted in memory at once; beyond it
+	// sorted runs are written to temporary files and merged from disk
+	MaxMemory int64
+	// TempDir holds the sorted runs (default: the system temp directory)
+	TempDir string
+}
+
+// Stats describes a finished merge
+type Stats struct {
+	Read       int // log lines read from the inputs
+	Written    int // log lines written
+	Duplicates int // lines dropped because an earlier line had the same ID
+	Runs       int // sorted runs spilled to disk; zero if it all fit in memory
+}
+
+// record is a log line with the keys it sorts by
+type record struct {
+	ts   time.Time
+	id   string
+	line []byte
+}
+
+func newRecord(line []byte) record {
+	ts, id := sidecar.ParseLine(line)
+	return record{ts: ts, id: id, line: line}
+}
+
+// compare orders records by timestamp, then ID. Copies of a log share both,
+// so they always end up next to each other.
+func compare(a, b record) int {
+	if c := a.ts.Compare(b.ts); c != 0 {
+		return c
+	}
+	return strings.Compare(a.id, b.id)
+}
+
+// Files merges NDJSON log files into w, sorted by timestamp with repeated
+// log IDs dropped. Lines without a timestamp sort first and lines without
+// an ID are always kept. The first copy of a log, in input order, wins.
+//
+// Inputs are read in runs that fit in opts.MaxMemory; each run is sorted and
+// written to a temporary file, then the runs are merged a line at a time, so
+// the inputs can be far larger than memory.
+func Files(paths []string, w io.Writer, opts Options) (Stats, error) {
+	var stats Stats
+	maxMemory := opts.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = DefaultMaxMemory
+	}
+
+	var runs []*os.File
+	defer func() {
+		for _, f := range runs {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	var mem []record
+	var used int64
+	spill := func() error {
+		f, err := writeRun(mem, opts.TempDir)
+		if f != nil {
+			runs = append(runs, f)
+		}
+		mem, used = nil, 0
+		return err
+	}
+
+	for _, path := range paths {
+		err := eachLine(path, func(line []byte) error {
+			stats.Read++
+			mem = append(mem, newRecord(line))
+			used += int64(len(line)) + recordOverhead
+			if used > maxMemory {
+				return spill()
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+	stats.Runs = len(runs)
+
+	// The last run never needs to touch disk
+	slices.SortStableFunc(mem, compare)
+	sources := []source{&memSource{records: mem}}
+	for _, f := range runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return stats, err
+		}
+		sources = append(sources, &fileSource{r: bufio.NewReaderSize(f, 1<<20)})
+	}
+	// Earlier inputs are in earlier runs; the in-memory one holds the last
+	sources = append(sources[1:], sources[0])
+
+	err := mergeSources(sources, func(r record, duplicate bool) error {
+		if duplicate {
+			stats.Duplicates++
+			return nil
+		}
+		stats.Written++
+		if _, err := w.Write(r.line); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte{'\n'})
+		return err
+	})
+	return stats, err
+}
+
+// eachLine calls fn with every non-blank line of a file, without its newline
+func eachLine(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	for {
+		line, err := r.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if err := fn(trimmed); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+}
+
+// writeRun sorts records and writes them to a temporary file, one per line
+func writeRun(records []record, dir string) (*os.File, error) {
+	slices.SortStableFunc(records, compare)
+
+	f, err := os.CreateTemp(dir, "dogfetch-merge-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	bw := bufio.NewWriterSize(f, 1<<20)
+	for _, r := range records {
+		bw.Write(r.line)
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return f, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return f, nil
+}
+
+// source yields sorted records; ok is false once it is exhausted
+type source interface {
+	next() (r record, ok bool, err error)
+}
+
+type memSource struct {
+	records []record
+}
+
+func (s *memSource) next() (record, bool, error) {
+	if len(s.records) == 0 {
+		return record{}, false, nil
+	}
+	r := s.records[0]
+	s.records = s.records[1:]
+	return r, true, nil
+}
+
+type fileSource struct {
+	r *bufio.Reader
+}
+
+func (s *fileSource) next() (record, bool, error) {
+	line, err := s.r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) && len(line) == 0 {
+		return record{}, false, nil
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return record{}, false, err
+	}
+	return newRecord(bytes.TrimSuffix(line, []byte{'\n'})), true, nil
+}
+
+// head is the current record of a source; order breaks ties between sources
+// so earlier inputs come first
+type head struct {
+	record
+	order int
+}
+
+type heads []head
+
+func (h heads) Len() int { return len(h) }
+func (h heads) Less(i, j int) bool {
+	if c := compare(h[i].record, h[j].record); c != 0 {
+		return c < 0
+	}
+	return h[i].order < h[j].order
+}
+func (h heads) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *heads) Push(x interface{}) { *h = append(*h, x.(head)) }
+func (h *heads) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// mergeSources calls emit with every record of the sources in sorted order,
+// flagging records whose ID matches the one emitted before them
+func mergeSources(sources []source, emit func(r record, duplicate bool) error) error {
+	h := &heads{}
+	for i, s := range sources {
+		r, ok, err := s.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			*h = append(*h, head{record: r, order: i})
+		}
+	}
+	heap.Init(h)
+
+	var last record
+	for h.Len() > 0 {
+		top := (*h)[0]
+		duplicate := top.id != "" && compare(top.record, last) == 0
+		if err := emit(top.record, duplicate); err != nil {
+			return err
+		}
+		if !duplicate {
+			last = top.record
+		}
+
+		r, ok, err := sources[top.order].next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			(*h)[0] = head{record: r, order: top.order}
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/jtzemp/dogfetch/internal/sidecar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func logLine(i int, source string) string {
+	ts := base.Add(time.Duration(i) * time.Second)
+	return fmt.Sprintf(`{"id":"log-%04d","attributes":{"timestamp":"%s","message":"from %s"}}`, i, ts.Format(time.RFC3339), source)
+}
+
+func writeFile(t *testing.T, dir, name string, lines []string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	return path
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Two overlapping exports, one newest first
+	var a, b []string
+	for i := 0; i < 60; i++ {
+		a = append(a, logLine(i, "a"))
+	}
+	for i := 99; i >= 40; i-- {
+		b = append(b, logLine(i, "b"))
+	}
+	paths := []string{writeFile(t, dir, "a.ndjson", a), writeFile(t, dir, "b.ndjson", b)}
+
+	for name, maxMemory := range map[string]int64{"in memory": 0, "external": 2048} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			stats, err := Files(paths, &out, Options{MaxMemory: maxMemory, TempDir: t.TempDir()})
+			require.NoError(t, err)
+
+			assert.Equal(t, 120, stats.Read)
+			assert.Equal(t, 100, stats.Written)
+			assert.Equal(t, 20, stats.Duplicates)
+			if maxMemory > 0 {
+				assert.Greater(t, stats.Runs, 1)
+			} else {
+				assert.Zero(t, stats.Runs)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			require.Len(t, lines, 100)
+			for i, line := range lines {
+				ts, id := sidecar.ParseLine([]byte(line))
+				assert.Equal(t, fmt.Sprintf("log-%04d", i), id)
+				assert.Equal(t, base.Add(time.Duration(i)*time.Second), ts)
+			}
+			// The copy from the first input is kept
+			assert.Contains(t, lines[50], "from a")
+		})
+	}
+}
+
+func TestFilesKeepsLinesWithoutID(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.ndjson", []string{
+		logLine(2, "a"),
+		`{"message":"no id"}`,
+		"",
+		`{"message":"no id"}`,
+		logLine(1, "a"),
+	})
+
+	var out bytes.Buffer
+	stats, err := Files([]string{path}, &out, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Written)
+	assert.Zero(t, stats.Duplicates)
+	assert.True(t, strings.HasPrefix(out.String(), `{"message":"no id"}`+"\n"+`{"message":"no id"}`+"\n"))
+}
+
+func TestRecordOverhead(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("the estimate is for 64-bit platforms")
+	}
+	assert.EqualValues(t, recordOverhead, unsafe.Sizeof(record{}))
+}
+
+func TestFilesMissingInput(t *testing.T) {
+	_, err := Files([]string{filepath.Join(t.TempDir(), "missing.ndjson")}, &bytes.Buffer{}, Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Encoder writes values in the MessagePack format
+// It handles the types produced by decoding JSON (nil, bool, string,
+// float64, json.Number, []interface{} and map[string]interface{}) plus
+// integers. Map keys are written in sorted order so output is stable.
+type Encoder struct {
+	w       *bufio.Writer
+	scratch [8]byte
+}
+
+// NewEncoder creates an encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes a single value; call Flush to push it to the underlying writer
+func (e *Encoder) Encode(v interface{}) error {
+	return e.encode(v)
+}
+
+// Flush writes any buffered data to the underlying writer
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// EncodeJSON re-encodes a JSON document as MessagePack, keeping integers
+// as integers rather than floats
+func (e *Encoder) EncodeJSON(data []byte) error {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	return e.Encode(v)
+}
+
+func (e *Encoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return e.w.WriteByte(0xc0)
+	case bool:
+		if v {
+			return e.w.WriteByte(0xc3)
+		}
+		return e.w.WriteByte(0xc2)
+	case string:
+		return e.encodeString(v)
+	case int:
+		return e.encodeInt(int64(v))
+	case int64:
+		return e.encodeInt(v)
+	case float64:
+		return e.encodeFloat(v)
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return e.encodeInt(i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return e.encodeFloat(f)
+	case []interface{}:
+		if err := e.encodeHeader(len(v), 0x90, 15, 0xdc, 0xdd); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		if err := e.encodeHeader(len(v), 0x80, 15, 0xde, 0xdf); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := e.encodeString(k); err != nil {
+				return err
+			}
+			if err := e.encode(v[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
+
+func (e *Encoder) encodeString(s string) error {
+	n := len(s)
+	var err error
+	switch {
+	case n <= 31:
+		err = e.w.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		err = e.write(0xd9, 1, uint64(n))
+	default:
+		err = e.encodeHeader(n, 0, -1, 0xda, 0xdb)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = e.w.WriteString(s)
+	return err
+}
+
+// encodeHeader writes a length prefix: the fix form if n fits in fixMax,
+// otherwise the 16- or 32-bit form
+func (e *Encoder) encodeHeader(n int, fix byte, fixMax int, code16, code32 byte) error {
+	switch {
+	case n <= fixMax:
+		return e.w.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		return e.write(code16, 2, uint64(n))
+	case uint64(n) <= math.MaxUint32:
+		return e.write(code32, 4, uint64(n))
+	default:
+		return fmt.Errorf("msgpack: length %d too large", n)
+	}
+}
+
+func (e *Encoder) encodeInt(i int64) error {
+	switch {
+	case i >= 0 && i <= 127:
+		return e.w.WriteByte(byte(i))
+	case i >= -32 && i < 0:
+		return e.w.WriteByte(byte(i))
+	case i >= 0:
+		switch {
+		case i <= math.MaxUint8:
+			return e.write(0xcc, 1, uint64(i))
+		case i <= math.MaxUint16:
+			return e.write(0xcd, 2, uint64(i))
+		case i <= math.MaxUint32:
+			return e.write(0xce, 4, uint64(i))
+		default:
+			return e.write(0xcf, 8, uint64(i))
+		}
+	default:
+		switch {
+		case i >= math.MinInt8:
+			return e.write(0xd0, 1, uint64(i))
+		case i >= math.MinInt16:
+			return e.write(0xd1, 2, uint64(i))
+		case i >= math.MinInt32:
+			return e.write(0xd2, 4, uint64(i))
+		default:
+			return e.write(0xd3, 8, uint64(i))
+		}
+	}
+}
+
+func (e *Encoder) encodeFloat(f float64) error {
+	return e.write(0xcb, 8, math.Float64bits(f))
+}
+
+// write emits a type code followed by the low size bytes of v, big-endian
+func (e *Encoder) write(code byte, size int, v uint64) error {
+	binary.BigEndian.PutUint64(e.scratch[:], v)
+	if err := e.w.WriteByte(code); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.scratch[8-size:])
+	return err
+}
@@ -0,0 +1,87 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []byte
+	}{
+		{name: "nil", value: nil, want: []byte{0xc0}},
+		{name: "true", value: true, want: []byte{0xc3}},
+		{name: "false", value: false, want: []byte{0xc2}},
+		{name: "positive fixint", value: 5, want: []byte{0x05}},
+		{name: "negative fixint", value: -3, want: []byte{0xfd}},
+		{name: "uint8", value: 200, want: []byte{0xcc, 0xc8}},
+		{name: "uint16", value: 1000, want: []byte{0xcd, 0x03, 0xe8}},
+		{name: "uint32", value: int64(70000), want: []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{name: "int8", value: -100, want: []byte{0xd0, 0x9c}},
+		{name: "int16", value: -1000, want: []byte{0xd1, 0xfc, 0x18}},
+		{name: "float64", value: 1.5, want: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{name: "json integer", value: json.Number("42"), want: []byte{0x2a}},
+		{name: "json float", value: json.Number("0.5"), want: []byte{0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0}},
+		{name: "fixstr", value: "hi", want: []byte{0xa2, 'h', 'i'}},
+		{name: "fixarray", value: []interface{}{1, "a"}, want: []byte{0x92, 0x01, 0xa1, 'a'}},
+		{
+			name:  "fixmap with sorted keys",
+			value: map[string]interface{}{"b": 2, "a": 1},
+			want:  []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			require.NoError(t, e.Encode(tt.value))
+			require.NoError(t, e.Flush())
+			assert.Equal(t, tt.want, buf.Bytes())
+		})
+	}
+}
+
+func TestEncodeLongValues(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+
+	str8 := strings.Repeat("x", 100)
+	require.NoError(t, e.Encode(str8))
+	require.NoError(t, e.Flush())
+	assert.Equal(t, []byte{0xd9, 100}, buf.Bytes()[:2])
+	assert.Len(t, buf.Bytes(), 102)
+
+	buf.Reset()
+	str16 := strings.Repeat("x", 300)
+	require.NoError(t, e.Encode(str16))
+	require.NoError(t, e.Flush())
+	assert.Equal(t, []byte{0xda, 0x01, 0x2c}, buf.Bytes()[:3])
+
+	buf.Reset()
+	array16 := make([]interface{}, 20)
+	require.NoError(t, e.Encode(array16))
+	require.NoError(t, e.Flush())
+	assert.Equal(t, []byte{0xdc, 0x00, 0x14}, buf.Bytes()[:3])
+}
+
+func TestEncodeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	require.NoError(t, e.EncodeJSON([]byte(`{"n":1,"tags":["a"]}`)))
+	require.NoError(t, e.Flush())
+
+	assert.Equal(t, []byte{0x82, 0xa1, 'n', 0x01, 0xa4, 't', 'a', 'g', 's', 0x91, 0xa1, 'a'}, buf.Bytes())
+}
+
+func TestEncodeUnsupportedType(t *testing.T) {
+	e := NewEncoder(&bytes.Buffer{})
+	assert.Error(t, e.Encode(struct{}{}))
+}
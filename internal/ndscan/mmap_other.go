@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package ndscan
+
+import (
+	"io"
+	"os"
+)
+
+// mmap falls back to reading the whole file where mmap isn't available
+func mmap(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(f, data)
+	return data, err
+}
+
+func munmap(data []byte) error {
+	return nil
+}
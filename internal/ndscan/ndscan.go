@@ -0,0 +1,207 @@
+package ndscan
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// DefaultChunkSize is the target size of the chunks a file is split into
+const DefaultChunkSize = 4 << 20
+
+// File is a read-only, memory-mapped NDJSON file
+type File struct {
+	data []byte
+}
+
+// Open maps a file into memory
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return &File{}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("%s is too large to map", path)
+	}
+
+	data, err := mmap(f, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s: %w", path, err)
+	}
+	return &File{data: data}, nil
+}
+
+// Bytes returns the file contents; they are only valid until Close
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+// Close unmaps the file
+func (f *File) Close() error {
+	if f.data == nil {
+		return nil
+	}
+	data := f.data
+	f.data = nil
+	return munmap(data)
+}
+
+// Chunk is a run of whole lines
+type Chunk struct {
+	Index  int
+	Offset int64 // byte offset of Data in the file
+	Data   []byte
+}
+
+// Options control how data is split and scanned
+type Options struct {
+	Workers   int // defaults to GOMAXPROCS
+	ChunkSize int // defaults to DefaultChunkSize
+}
+
+func (o Options) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// Split cuts data into chunks of roughly size bytes, each ending after a
+// newline (or at the end of data), so no line spans two chunks
+func Split(data []byte, size int) []Chunk {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(data); {
+		end := start + size
+		if end >= len(data) {
+			end = len(data)
+		} else if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+			end += i + 1
+		} else {
+			end = len(data)
+		}
+		chunks = append(chunks, Chunk{Index: len(chunks), Offset: int64(start), Data: data[start:end]})
+		start = end
+	}
+	return chunks
+}
+
+// Lines calls fn for each non-blank line of a chunk with its file offset,
+// without the trailing newline or carriage return
+func (c Chunk) Lines(fn func(offset int64, line []byte) error) error {
+	data := c.Data
+	pos := 0
+	for pos < len(data) {
+		end := bytes.IndexByte(data[pos:], '\n')
+		next := pos + end + 1
+		if end < 0 {
+			end = len(data) - pos
+			next = len(data)
+		}
+		line := bytes.TrimSuffix(data[pos:pos+end], []byte{'\r'})
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := fn(c.Offset+int64(pos), line); err != nil {
+				return err
+			}
+		}
+		pos = next
+	}
+	return nil
+}
+
+// Scan calls fn for every chunk of data on a pool of workers
+// Chunks are processed concurrently and in no particular order; the first
+// error stops the scan and is returned.
+func Scan(data []byte, opts Options, fn func(Chunk) error) error {
+	return Map(data, opts, func(c Chunk) ([]byte, error) {
+		return nil, fn(c)
+	}, nil)
+}
+
+// Map calls fn for every chunk of data on a pool of workers and writes what
+// it returns to out in file order, so output matches a sequential scan
+// out may be nil when fn's results aren't needed.
+func Map(data []byte, opts Options, fn func(Chunk) ([]byte, error), out io.Writer) error {
+	chunks := Split(data, opts.ChunkSize)
+	workers := opts.workers()
+	if workers > len(chunks) {
+		workers = len(chunks)
+	}
+
+	type result struct {
+		data []byte
+		err  error
+		done chan struct{}
+	}
+	results := make([]result, len(chunks))
+	for i := range results {
+		results[i].done = make(chan struct{})
+	}
+
+	jobs := make(chan Chunk)
+	stop := make(chan struct{})
+	// window bounds how far workers run ahead of the writer, so pending
+	// output stays proportional to the worker count
+	window := make(chan struct{}, 2*workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				r := &results[c.Index]
+				r.data, r.err = fn(c)
+				close(r.done)
+			}
+		}()
+	}
+
+	// Feed chunks in order; stop early once the writer below gives up
+	go func() {
+		defer close(jobs)
+		for _, c := range chunks {
+			select {
+			case window <- struct{}{}:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- c:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var err error
+	for i := range results {
+		<-results[i].done
+		if err = results[i].err; err == nil && out != nil && len(results[i].data) > 0 {
+			_, err = out.Write(results[i].data)
+		}
+		results[i].data = nil
+		<-window
+		if err != nil {
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,150 @@
+package ndscan
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	path := writeFile(t, "{\"a\":1}\n{\"a\":2}\n")
+
+	f, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"a\":1}\n{\"a\":2}\n", string(f.Bytes()))
+	require.NoError(t, f.Close())
+	require.NoError(t, f.Close())
+
+	empty, err := Open(writeFile(t, ""))
+	require.NoError(t, err)
+	assert.Empty(t, empty.Bytes())
+	require.NoError(t, empty.Close())
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestSplit(t *testing.T) {
+	data := []byte("aaaa\nbb\ncccccc\nd")
+	chunks := Split(data, 3)
+
+	var joined []byte
+	for i, c := range chunks {
+		assert.Equal(t, i, c.Index)
+		assert.Equal(t, int64(len(joined)), c.Offset)
+		if i < len(chunks)-1 {
+			assert.True(t, bytes.HasSuffix(c.Data, []byte("\n")), "chunk %d splits a line", i)
+		}
+		joined = append(joined, c.Data...)
+	}
+	assert.Equal(t, data, joined)
+	assert.Len(t, chunks, 3)
+
+	assert.Empty(t, Split(nil, 10))
+}
+
+func TestChunkLines(t *testing.T) {
+	c := Chunk{Offset: 100, Data: []byte("one\r\n\n  \ntwo\nthree")}
+
+	var offsets []int64
+	var lines []string
+	require.NoError(t, c.Lines(func(offset int64, line []byte) error {
+		offsets = append(offsets, offset)
+		lines = append(lines, string(line))
+		return nil
+	}))
+	assert.Equal(t, []string{"one", "two", "three"}, lines)
+	assert.Equal(t, []int64{100, 109, 113}, offsets)
+
+	stop := errors.New("stop")
+	assert.Equal(t, stop, c.Lines(func(int64, []byte) error { return stop }))
+}
+
+func TestScan(t *testing.T) {
+	data := corpus(10000)
+
+	var count atomic.Int64
+	err := Scan(data, Options{Workers: 4, ChunkSize: 1024}, func(c Chunk) error {
+		return c.Lines(func(int64, []byte) error {
+			count.Add(1)
+			return nil
+		})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), count.Load())
+}
+
+func TestMapPreservesOrder(t *testing.T) {
+	data := corpus(5000)
+
+	var out bytes.Buffer
+	err := Map(data, Options{Workers: 8, ChunkSize: 512}, func(c Chunk) ([]byte, error) {
+		var buf []byte
+		err := c.Lines(func(_ int64, line []byte) error {
+			if bytes.Contains(line, []byte(`"n":7`)) {
+				buf = append(append(buf, line...), '\n')
+			}
+			return nil
+		})
+		return buf, err
+	}, &out)
+	require.NoError(t, err)
+
+	var want strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.Contains(line, `"n":7`) {
+			want.WriteString(line)
+		}
+	}
+	assert.Equal(t, want.String(), out.String())
+}
+
+func TestMapStopsOnError(t *testing.T) {
+	boom := errors.New("boom")
+	var calls atomic.Int64
+
+	err := Map(corpus(10000), Options{Workers: 2, ChunkSize: 64}, func(c Chunk) ([]byte, error) {
+		calls.Add(1)
+		if c.Index == 3 {
+			return nil, boom
+		}
+		return nil, nil
+	}, nil)
+	assert.Equal(t, boom, err)
+	assert.Less(t, calls.Load(), int64(len(Split(corpus(10000), 64))))
+}
+
+func BenchmarkScan(b *testing.B) {
+	data := corpus(200000)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Scan(data, Options{}, func(c Chunk) error {
+			return c.Lines(func(int64, []byte) error { return nil })
+		})
+	}
+}
+
+// Helper functions
+
+func corpus(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "{\"id\":\"log-%d\",\"n\":%d,\"message\":\"request handled\"}\n", i, i%10)
+	}
+	return b.Bytes()
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
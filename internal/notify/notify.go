@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Formats are the payload formats --notify-format accepts
+var Formats = []string{"json", "slack"}
+
+// Outcomes of a run
+const (
+	Completed   = "completed"
+	Failed      = "failed"
+	Interrupted = "interrupted"
+)
+
+// timeout bounds how long a notification can hold up the end of a run
+const timeout = 10 * time.Second
+
+// Summary describes a finished run
+type Summary struct {
+	Status   string    `json:"status"` // completed, failed or interrupted
+	Query    string    `json:"query"`
+	Index    string    `json:"index"`
+	From     time.Time `json:"from"`
+	To       string    `json:"to"` // RFC 3339, or "now" for an open range
+	Output   string    `json:"output,omitempty"`
+	Logs     int       `json:"logs"`
+	Pages    int       `json:"pages"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"` // why it failed or how to resume it
+}
+
+// Payload encodes a summary as a request body: the summary itself as JSON,
+// or a Slack incoming webhook message
+func Payload(format string, s Summary) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.Marshal(s)
+	case "slack":
+		return json.Marshal(map[string]string{"text": slackText(s)})
+	default:
+		return nil, fmt.Errorf("unknown notification format '%s' (supported: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func slackText(s Summary) string {
+	icon := ":white_check_mark:"
+	switch s.Status {
+	case Failed:
+		icon = ":x:"
+	case Interrupted:
+		icon = ":warning:"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s dogfetch %s: %d logs in %d pages (%s)\n", icon, s.Status, s.Logs, s.Pages, s.Duration)
+	fmt.Fprintf(&b, "Query `%s` on %s from %s to %s", s.Query, s.Index, s.From.Format(time.RFC3339), s.To)
+	if s.Output != "" {
+		fmt.Fprintf(&b, "\nOutput: %s", s.Output)
+	}
+	if s.Error != "" {
+		fmt.Fprintf(&b, "\n%s", s.Error)
+	}
+	return b.String()
+}
+
+// Send posts a summary to url
+func Send(ctx context.Context, client *http.Client, url, format string, s Summary) error {
+	body, err := Payload(format, s)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
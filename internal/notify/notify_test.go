@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSummary() Summary {
+	return Summary{
+		Status:   Interrupted,
+		Query:    "service:web",
+		Index:    "main",
+		From:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:       "now",
+		Output:   "logs.ndjson",
+		Logs:     2000,
+		Pages:    2,
+		Duration: "1.5s",
+		Error:    "interrupted; resume with --cursor 'abc'",
+	}
+}
+
+func TestPayloadJSON(t *testing.T) {
+	body, err := Payload("json", testSummary())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"status": "interrupted",
+		"query": "service:web",
+		"index": "main",
+		"from": "2024-01-01T00:00:00Z",
+		"to": "now",
+		"output": "logs.ndjson",
+		"logs": 2000,
+		"pages": 2,
+		"duration": "1.5s",
+		"error": "interrupted; resume with --cursor 'abc'"
+	}`, string(body))
+}
+
+func TestPayloadSlack(t *testing.T) {
+	body, err := Payload("slack", testSummary())
+	require.NoError(t, err)
+
+	var message map[string]string
+	require.NoError(t, json.Unmarshal(body, &message))
+	assert.Equal(t, ":warning: dogfetch interrupted: 2000 logs in 2 pages (1.5s)\n"+
+		"Query `service:web` on main from 2024-01-01T00:00:00Z to now\n"+
+		"Output: logs.ndjson\n"+
+		"interrupted; resume with --cursor 'abc'", message["text"])
+
+	_, err = Payload("teams", testSummary())
+	assert.ErrorContains(t, err, "unknown notification format 'teams'")
+}
+
+func TestSend(t *testing.T) {
+	var got []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		got, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	require.NoError(t, Send(context.Background(), server.Client(), server.URL, "json", testSummary()))
+	assert.Contains(t, string(got), `"status":"interrupted"`)
+}
+
+func TestSendReportsRejection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Send(context.Background(), server.Client(), server.URL, "slack", testSummary())
+	assert.EqualError(t, err, "403 Forbidden: invalid_token")
+}
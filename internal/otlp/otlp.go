@@ -0,0 +1,246 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// The types below mirror the OTLP logs protobuf messages (opentelemetry-proto
+// v1) closely enough to encode both the protobuf and the JSON form. Only the
+// fields dogfetch fills in are modelled.
+
+// ExportLogsServiceRequest is the body of an OTLP logs export
+type ExportLogsServiceRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+// ResourceLogs groups the logs of one resource (service and host)
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+}
+
+// Resource describes the entity that produced the logs
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// ScopeLogs groups the logs of one instrumentation scope
+type ScopeLogs struct {
+	Scope      InstrumentationScope `json:"scope"`
+	LogRecords []LogRecord          `json:"logRecords"`
+}
+
+// InstrumentationScope names what produced the records; here, dogfetch
+type InstrumentationScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// LogRecord is a single OTLP log
+type LogRecord struct {
+	TimeUnixNano         uint64     `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64     `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int32      `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 AnyValue   `json:"body"`
+	Attributes           []KeyValue `json:"attributes,omitempty"`
+	TraceID              HexBytes   `json:"traceId,omitempty"`
+	SpanID               HexBytes   `json:"spanId,omitempty"`
+}
+
+// KeyValue is an attribute
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue holds exactly one of its fields, or none for an empty value
+type AnyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *int64        `json:"intValue,string,omitempty"`
+	DoubleValue *float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *KeyValueList `json:"kvlistValue,omitempty"`
+}
+
+// ArrayValue is a list of values
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+// KeyValueList is a nested map of values
+type KeyValueList struct {
+	Values []KeyValue `json:"values"`
+}
+
+// HexBytes is a byte string that OTLP/JSON encodes as hex, as trace and span IDs are
+type HexBytes []byte
+
+// MarshalJSON encodes the bytes as a hex string
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + hex.EncodeToString(h) + `"`), nil
+}
+
+// Severity numbers for Datadog statuses, per the OTel log data model
+var severities = map[string]int32{
+	"trace":     1,
+	"debug":     5,
+	"info":      9,
+	"ok":        9,
+	"notice":    10,
+	"warn":      13,
+	"warning":   13,
+	"error":     17,
+	"critical":  18,
+	"alert":     19,
+	"emergency": 21,
+}
+
+// FromDatadog converts a page of Datadog logs into an export request, with
+// one resource per service and host
+// Custom attributes become log attributes, tags become a "ddtags" array and
+// dd.trace_id / dd.span_id fill the record's trace context.
+func FromDatadog(logs []datadogV2.Log, scope InstrumentationScope, observed time.Time) ExportLogsServiceRequest {
+	var req ExportLogsServiceRequest
+	index := make(map[string]int)
+
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		service, host := attrs.GetService(), attrs.GetHost()
+
+		key := service + "\x00" + host
+		i, ok := index[key]
+		if !ok {
+			var resource Resource
+			if service != "" {
+				resource.Attributes = append(resource.Attributes, stringKV("service.name", service))
+			}
+			if host != "" {
+				resource.Attributes = append(resource.Attributes, stringKV("host.name", host))
+			}
+			i = len(req.ResourceLogs)
+			index[key] = i
+			req.ResourceLogs = append(req.ResourceLogs, ResourceLogs{
+				Resource:  resource,
+				ScopeLogs: []ScopeLogs{{Scope: scope}},
+			})
+		}
+
+		scopeLogs := &req.ResourceLogs[i].ScopeLogs[0]
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, convertLog(log, observed))
+	}
+	return req
+}
+
+func convertLog(log datadogV2.Log, observed time.Time) LogRecord {
+	attrs := log.GetAttributes()
+
+	record := LogRecord{
+		ObservedTimeUnixNano: uint64(observed.UnixNano()),
+		SeverityText:         attrs.GetStatus(),
+		SeverityNumber:       severities[strings.ToLower(attrs.GetStatus())],
+		Body:                 stringValue(attrs.GetMessage()),
+	}
+	if ts, ok := attrs.GetTimestampOk(); ok {
+		record.TimeUnixNano = uint64(ts.UnixNano())
+	}
+
+	if id := log.GetId(); id != "" {
+		record.Attributes = append(record.Attributes, stringKV("datadog.log.id", id))
+	}
+	if tags := attrs.GetTags(); len(tags) > 0 {
+		values := make([]AnyValue, len(tags))
+		for i, tag := range tags {
+			values[i] = stringValue(tag)
+		}
+		record.Attributes = append(record.Attributes, KeyValue{Key: "ddtags", Value: AnyValue{ArrayValue: &ArrayValue{Values: values}}})
+	}
+
+	custom := attrs.GetAttributes()
+	record.Attributes = append(record.Attributes, keyValues(custom)...)
+
+	if dd, ok := custom["dd"].(map[string]interface{}); ok {
+		if id, ok := parseID(dd["trace_id"]); ok {
+			record.TraceID = make(HexBytes, 16)
+			binary.BigEndian.PutUint64(record.TraceID[8:], id)
+		}
+		if id, ok := parseID(dd["span_id"]); ok {
+			record.SpanID = make(HexBytes, 8)
+			binary.BigEndian.PutUint64(record.SpanID, id)
+		}
+	}
+	return record
+}
+
+// parseID reads a Datadog 64-bit trace or span ID, sent as a decimal string
+func parseID(v interface{}) (uint64, bool) {
+	var id uint64
+	var err error
+	switch v := v.(type) {
+	case string:
+		id, err = strconv.ParseUint(v, 10, 64)
+	case float64:
+		id = uint64(v)
+	default:
+		return 0, false
+	}
+	return id, err == nil && id != 0
+}
+
+// keyValues converts a map to attributes in key order
+func keyValues(m map[string]interface{}) []KeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]KeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, KeyValue{Key: k, Value: toAnyValue(m[k])})
+	}
+	return kvs
+}
+
+// toAnyValue converts a decoded JSON value; whole numbers become integers
+func toAnyValue(v interface{}) AnyValue {
+	switch v := v.(type) {
+	case string:
+		return stringValue(v)
+	case bool:
+		return AnyValue{BoolValue: &v}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			i := int64(v)
+			return AnyValue{IntValue: &i}
+		}
+		return AnyValue{DoubleValue: &v}
+	case []interface{}:
+		values := make([]AnyValue, len(v))
+		for i, item := range v {
+			values[i] = toAnyValue(item)
+		}
+		return AnyValue{ArrayValue: &ArrayValue{Values: values}}
+	case map[string]interface{}:
+		return AnyValue{KvlistValue: &KeyValueList{Values: keyValues(v)}}
+	default:
+		return AnyValue{}
+	}
+}
+
+func stringValue(s string) AnyValue {
+	return AnyValue{StringValue: &s}
+}
+
+func stringKV(key, value string) KeyValue {
+	return KeyValue{Key: key, Value: stringValue(value)}
+}
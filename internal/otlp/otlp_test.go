@@ -0,0 +1,178 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var scope = InstrumentationScope{Name: "dogfetch", Version: "test"}
+
+func TestFromDatadog(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	observed := ts.Add(time.Hour)
+	logs := []datadogV2.Log{
+		createLog("web", "host-1", "error", "boom", ts, map[string]interface{}{
+			"dd":          map[string]interface{}{"trace_id": "1234", "span_id": "5678"},
+			"duration":    float64(42),
+			"ratio":       0.5,
+			"error.kind":  "Timeout",
+			"retryable":   true,
+			"http.routes": []interface{}{"/a"},
+		}),
+		createLog("web", "host-1", "info", "ok", ts, nil),
+		createLog("api", "host-2", "warn", "slow", ts, nil),
+	}
+
+	req := FromDatadog(logs, scope, observed)
+	require.Len(t, req.ResourceLogs, 2, "one resource per service and host")
+
+	web := req.ResourceLogs[0]
+	assert.Equal(t, []KeyValue{stringKV("service.name", "web"), stringKV("host.name", "host-1")}, web.Resource.Attributes)
+	require.Len(t, web.ScopeLogs, 1)
+	assert.Equal(t, scope, web.ScopeLogs[0].Scope)
+	require.Len(t, web.ScopeLogs[0].LogRecords, 2)
+
+	record := web.ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, uint64(ts.UnixNano()), record.TimeUnixNano)
+	assert.Equal(t, uint64(observed.UnixNano()), record.ObservedTimeUnixNano)
+	assert.Equal(t, int32(17), record.SeverityNumber)
+	assert.Equal(t, "error", record.SeverityText)
+	assert.Equal(t, "boom", *record.Body.StringValue)
+
+	traceID := make([]byte, 16)
+	binary.BigEndian.PutUint64(traceID[8:], 1234)
+	assert.Equal(t, HexBytes(traceID), record.TraceID)
+	assert.Len(t, record.SpanID, 8)
+
+	attrs := make(map[string]AnyValue)
+	for _, kv := range record.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, int64(42), *attrs["duration"].IntValue)
+	assert.Equal(t, 0.5, *attrs["ratio"].DoubleValue)
+	assert.Equal(t, "Timeout", *attrs["error.kind"].StringValue)
+	assert.True(t, *attrs["retryable"].BoolValue)
+	assert.Len(t, attrs["http.routes"].ArrayValue.Values, 1)
+	assert.Equal(t, "trace_id", attrs["dd"].KvlistValue.Values[1].Key)
+
+	assert.Equal(t, int32(13), req.ResourceLogs[1].ScopeLogs[0].LogRecords[0].SeverityNumber)
+}
+
+func TestJSONEncoding(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	req := FromDatadog([]datadogV2.Log{
+		createLog("web", "", "info", "hello", ts, map[string]interface{}{
+			"dd":    map[string]interface{}{"trace_id": "1"},
+			"count": float64(3),
+		}),
+	}, scope, ts)
+
+	data, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	record := doc["resourceLogs"].([]interface{})[0].(map[string]interface{})["scopeLogs"].([]interface{})[0].(map[string]interface{})["logRecords"].([]interface{})[0].(map[string]interface{})
+
+	// OTLP/JSON encodes 64-bit integers as strings and IDs as hex
+	assert.Equal(t, "1704103200000000000", record["timeUnixNano"])
+	assert.Equal(t, "00000000000000000000000000000001", record["traceId"])
+	assert.NotContains(t, record, "spanId")
+	assert.Equal(t, map[string]interface{}{"stringValue": "hello"}, record["body"])
+
+	attrs := record["attributes"].([]interface{})
+	assert.Contains(t, attrs, map[string]interface{}{"key": "count", "value": map[string]interface{}{"intValue": "3"}})
+}
+
+func TestMarshalProto(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	req := FromDatadog([]datadogV2.Log{
+		createLog("web", "host-1", "error", "boom", ts, map[string]interface{}{"n": float64(-1)}),
+	}, scope, ts)
+
+	// ExportLogsServiceRequest.resource_logs
+	resourceLogs := fields(t, req.MarshalProto())
+	require.Len(t, resourceLogs[1], 1)
+
+	rl := fields(t, resourceLogs[1][0].([]byte))
+	resource := fields(t, rl[1][0].([]byte))
+	serviceName := fields(t, resource[1][0].([]byte))
+	assert.Equal(t, "service.name", string(serviceName[1][0].([]byte)))
+
+	scopeLogs := fields(t, rl[2][0].([]byte))
+	scopeFields := fields(t, scopeLogs[1][0].([]byte))
+	assert.Equal(t, "dogfetch", string(scopeFields[1][0].([]byte)))
+
+	record := fields(t, scopeLogs[2][0].([]byte))
+	assert.Equal(t, uint64(ts.UnixNano()), record[1][0])
+	assert.Equal(t, uint64(17), record[2][0])
+	assert.Equal(t, "error", string(record[3][0].([]byte)))
+	body := fields(t, record[5][0].([]byte))
+	assert.Equal(t, "boom", string(body[1][0].([]byte)))
+
+	// Negative integers use the ten-byte two's complement varint
+	var attr map[int][]interface{}
+	for _, raw := range record[6] {
+		kv := fields(t, raw.([]byte))
+		if string(kv[1][0].([]byte)) == "n" {
+			attr = fields(t, kv[2][0].([]byte))
+		}
+	}
+	require.NotNil(t, attr)
+	assert.Equal(t, uint64(1<<64-1), attr[3][0])
+}
+
+// Helper functions
+
+// fields decodes one level of a protobuf message into field number -> values;
+// varints and fixed64 decode to uint64, length-delimited fields to []byte
+func fields(t *testing.T, b []byte) map[int][]interface{} {
+	t.Helper()
+	out := make(map[int][]interface{})
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		require.Greater(t, n, 0)
+		b = b[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			require.Greater(t, n, 0)
+			out[field] = append(out[field], v)
+			b = b[n:]
+		case wireFixed64:
+			out[field] = append(out[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			require.Greater(t, n, 0)
+			out[field] = append(out[field], b[n:n+int(l)])
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return out
+}
+
+func createLog(service, host, status, message string, ts time.Time, attrs map[string]interface{}) datadogV2.Log {
+	id := "log-id"
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Service:    &service,
+			Host:       &host,
+			Status:     &status,
+			Message:    &message,
+			Timestamp:  &ts,
+			Attributes: attrs,
+		},
+	}
+}
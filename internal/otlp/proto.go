@@ -0,0 +1,146 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// MarshalProto encodes the request in the protobuf wire format used by
+// OTLP/HTTP and OTLP/gRPC
+func (r ExportLogsServiceRequest) MarshalProto() []byte {
+	var b []byte
+	for _, rl := range r.ResourceLogs {
+		b = appendMessage(b, 1, rl.appendProto(nil))
+	}
+	return b
+}
+
+func (rl ResourceLogs) appendProto(b []byte) []byte {
+	b = appendMessage(b, 1, rl.Resource.appendProto(nil))
+	for _, sl := range rl.ScopeLogs {
+		b = appendMessage(b, 2, sl.appendProto(nil))
+	}
+	return b
+}
+
+func (r Resource) appendProto(b []byte) []byte {
+	for _, kv := range r.Attributes {
+		b = appendMessage(b, 1, kv.appendProto(nil))
+	}
+	return b
+}
+
+func (sl ScopeLogs) appendProto(b []byte) []byte {
+	b = appendMessage(b, 1, sl.Scope.appendProto(nil))
+	for _, lr := range sl.LogRecords {
+		b = appendMessage(b, 2, lr.appendProto(nil))
+	}
+	return b
+}
+
+func (s InstrumentationScope) appendProto(b []byte) []byte {
+	b = appendString(b, 1, s.Name)
+	return appendString(b, 2, s.Version)
+}
+
+func (lr LogRecord) appendProto(b []byte) []byte {
+	if lr.TimeUnixNano != 0 {
+		b = appendTag(b, 1, wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, lr.TimeUnixNano)
+	}
+	if lr.SeverityNumber != 0 {
+		b = appendTag(b, 2, wireVarint)
+		b = binary.AppendUvarint(b, uint64(lr.SeverityNumber))
+	}
+	b = appendString(b, 3, lr.SeverityText)
+	b = appendMessage(b, 5, lr.Body.appendProto(nil))
+	for _, kv := range lr.Attributes {
+		b = appendMessage(b, 6, kv.appendProto(nil))
+	}
+	if len(lr.TraceID) > 0 {
+		b = appendMessage(b, 9, lr.TraceID)
+	}
+	if len(lr.SpanID) > 0 {
+		b = appendMessage(b, 10, lr.SpanID)
+	}
+	if lr.ObservedTimeUnixNano != 0 {
+		b = appendTag(b, 11, wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, lr.ObservedTimeUnixNano)
+	}
+	return b
+}
+
+func (kv KeyValue) appendProto(b []byte) []byte {
+	b = appendString(b, 1, kv.Key)
+	return appendMessage(b, 2, kv.Value.appendProto(nil))
+}
+
+func (v AnyValue) appendProto(b []byte) []byte {
+	// Oneof fields are written even when they hold the zero value
+	switch {
+	case v.StringValue != nil:
+		b = appendTag(b, 1, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(*v.StringValue)))
+		b = append(b, *v.StringValue...)
+	case v.BoolValue != nil:
+		b = appendTag(b, 2, wireVarint)
+		if *v.BoolValue {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	case v.IntValue != nil:
+		b = appendTag(b, 3, wireVarint)
+		b = binary.AppendUvarint(b, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		b = appendTag(b, 4, wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(*v.DoubleValue))
+	case v.ArrayValue != nil:
+		var inner []byte
+		for _, item := range v.ArrayValue.Values {
+			inner = appendMessage(inner, 1, item.appendProto(nil))
+		}
+		b = appendTag(b, 5, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(inner)))
+		b = append(b, inner...)
+	case v.KvlistValue != nil:
+		var inner []byte
+		for _, kv := range v.KvlistValue.Values {
+			inner = appendMessage(inner, 1, kv.appendProto(nil))
+		}
+		b = appendTag(b, 6, wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(inner)))
+		b = append(b, inner...)
+	}
+	return b
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendMessage writes a length-delimited field, which covers embedded
+// messages and bytes alike
+func appendMessage(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendString writes a string field, skipping it when empty as proto3 does
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,92 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxBuckets bounds how many intervals Balanced asks the Counter for
+const maxBuckets = 1000
+
+// Bucket is the number of logs in the interval starting at Start
+type Bucket struct {
+	Start time.Time
+	Count int64
+}
+
+// Histogram counts the logs being fetched in each interval of a range
+type Histogram interface {
+	Histogram(ctx context.Context, from, to time.Time, interval time.Duration) ([]Bucket, error)
+}
+
+// Balanced splits a range into windows holding roughly Logs logs each, using
+// per-interval counts so a burst of traffic gets narrow windows and quiet
+// periods wide ones. A window never holds less than one interval, so a single
+// hot interval can exceed Logs; MaxSize, when set, caps each window's length.
+type Balanced struct {
+	Counts  Histogram
+	Logs    int64
+	MaxSize time.Duration
+}
+
+// Plan counts the logs in [from, to) and splits it at interval boundaries
+func (b Balanced) Plan(ctx context.Context, from, to time.Time) ([]Window, error) {
+	if b.Logs <= 0 {
+		return nil, fmt.Errorf("logs per window must be positive, got %d", b.Logs)
+	}
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	interval := bucketInterval(to.Sub(from))
+	buckets, err := b.Counts.Histogram(ctx, from, to, interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count logs: %w", err)
+	}
+
+	return b.split(ctx, buckets, from, to)
+}
+
+// split cuts [from, to) at the bucket boundaries where the running count
+// would pass b.Logs, capping each window at b.MaxSize
+func (b Balanced) split(ctx context.Context, buckets []Bucket, from, to time.Time) ([]Window, error) {
+	var windows []Window
+	start := from
+	var n int64
+	for _, bucket := range buckets {
+		// Buckets are aligned to the interval, so the first may start
+		// before the range
+		boundary := bucket.Start
+		if n > 0 && n+bucket.Count > b.Logs && boundary.After(start) && boundary.Before(to) {
+			windows = append(windows, Window{From: start, To: boundary})
+			start = boundary
+			n = 0
+		}
+		n += bucket.Count
+	}
+	windows = append(windows, Window{From: start, To: to})
+
+	if b.MaxSize <= 0 {
+		return windows, nil
+	}
+	var capped []Window
+	for _, w := range windows {
+		parts, err := Slices{Size: b.MaxSize}.Plan(ctx, w.From, w.To)
+		if err != nil {
+			return nil, err
+		}
+		capped = append(capped, parts...)
+	}
+	return capped, nil
+}
+
+// bucketInterval picks whole minutes that divide span into at most
+// maxBuckets intervals
+func bucketInterval(span time.Duration) time.Duration {
+	interval := (span + maxBuckets - 1) / maxBuckets
+	if rem := interval % time.Minute; rem != 0 || interval == 0 {
+		interval += time.Minute - rem
+	}
+	return interval
+}
@@ -0,0 +1,79 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedHistogram returns hourly buckets with the given counts
+type fixedHistogram struct {
+	counts   []int64
+	interval time.Duration
+	err      error
+}
+
+func (h *fixedHistogram) Histogram(_ context.Context, from, to time.Time, interval time.Duration) ([]Bucket, error) {
+	h.interval = interval
+	if h.err != nil {
+		return nil, h.err
+	}
+	var buckets []Bucket
+	for i, n := range h.counts {
+		buckets = append(buckets, Bucket{Start: from.Add(time.Duration(i) * time.Hour), Count: n})
+	}
+	return buckets, nil
+}
+
+func TestBalancedPlan(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(n int) time.Time { return from.Add(time.Duration(n) * time.Hour) }
+
+	// A quiet morning, a burst at 04:00 and a quiet evening
+	counts := &fixedHistogram{counts: []int64{10, 10, 10, 10, 500, 20, 10, 10}}
+	windows, err := Balanced{Counts: counts, Logs: 100}.Plan(context.Background(), from, hour(8))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Window{
+		{From: from, To: hour(4)},
+		{From: hour(4), To: hour(5)},
+		{From: hour(5), To: hour(8)},
+	}, windows)
+	assert.Equal(t, time.Minute, counts.interval)
+}
+
+func TestBalancedPlanMaxSize(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	counts := &fixedHistogram{counts: []int64{1, 1, 1, 1}}
+	windows, err := Balanced{Counts: counts, Logs: 100, MaxSize: 3 * time.Hour}.Plan(context.Background(), from, from.Add(4*time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Window{
+		{From: from, To: from.Add(3 * time.Hour)},
+		{From: from.Add(3 * time.Hour), To: from.Add(4 * time.Hour)},
+	}, windows)
+}
+
+func TestBalancedPlanErrors(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := Balanced{Counts: &fixedHistogram{err: errors.New("rate limited")}, Logs: 100}.Plan(context.Background(), from, from.Add(time.Hour))
+	assert.ErrorContains(t, err, "failed to count logs: rate limited")
+
+	_, err = Balanced{Counts: &fixedHistogram{}}.Plan(context.Background(), from, from.Add(time.Hour))
+	assert.Error(t, err)
+
+	windows, err := Balanced{Counts: &fixedHistogram{}, Logs: 100}.Plan(context.Background(), from, from)
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+}
+
+func TestBucketInterval(t *testing.T) {
+	assert.Equal(t, time.Minute, bucketInterval(time.Hour))
+	assert.Equal(t, 11*time.Minute, bucketInterval(7*24*time.Hour))
+	assert.Equal(t, time.Minute, bucketInterval(0))
+}
@@ -0,0 +1,78 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IndexHistogram counts the logs being fetched in each interval of a range,
+// separately for each index that has any
+type IndexHistogram interface {
+	IndexHistogram(ctx context.Context, from, to time.Time, interval time.Duration) (map[string][]Bucket, error)
+}
+
+// Indexes balances each index's logs on their own, so a busy index gets
+// narrow windows without cutting a quiet one into as many. Windows are
+// limited to their index and fetched index by index in name order, oldest
+// first within each; indexes without logs in the range get none.
+type Indexes struct {
+	Counts  IndexHistogram
+	Logs    int64
+	MaxSize time.Duration
+}
+
+// Plan counts the logs in [from, to) per index and splits each index's
+// range at interval boundaries
+func (x Indexes) Plan(ctx context.Context, from, to time.Time) ([]Window, error) {
+	if x.Logs <= 0 {
+		return nil, fmt.Errorf("logs per window must be positive, got %d", x.Logs)
+	}
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	counts, err := x.Counts.IndexHistogram(ctx, from, to, bucketInterval(to.Sub(from)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count logs by index: %w", err)
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := Balanced{Logs: x.Logs, MaxSize: x.MaxSize}
+	var windows []Window
+	for _, name := range names {
+		parts, err := b.split(ctx, counts[name], from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range parts {
+			w.Index = name
+			windows = append(windows, w)
+		}
+	}
+	return windows, nil
+}
+
+// After returns the windows of a plan that come after w: the rest of w's
+// index from where w ends, then the indexes after it. A fetch resuming in w
+// carries on with these.
+func After(windows []Window, w Window) []Window {
+	var rest []Window
+	for _, next := range windows {
+		switch {
+		case next.Index > w.Index:
+			rest = append(rest, next)
+		case next.Index == w.Index && next.To.After(w.To):
+			if next.From.Before(w.To) {
+				next.From = w.To
+			}
+			rest = append(rest, next)
+		}
+	}
+	return rest
+}
@@ -0,0 +1,92 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedIndexHistogram returns hourly buckets with the given counts per index
+type fixedIndexHistogram struct {
+	counts map[string][]int64
+	err    error
+}
+
+func (h fixedIndexHistogram) IndexHistogram(ctx context.Context, from, to time.Time, interval time.Duration) (map[string][]Bucket, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	buckets := make(map[string][]Bucket)
+	for index, counts := range h.counts {
+		buckets[index], _ = (&fixedHistogram{counts: counts}).Histogram(ctx, from, to, interval)
+	}
+	return buckets, nil
+}
+
+func TestIndexesPlan(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(n int) time.Time { return from.Add(time.Duration(n) * time.Hour) }
+
+	// A busy main index and a quiet audit index
+	counts := fixedIndexHistogram{counts: map[string][]int64{
+		"main":  {90, 90, 90, 90},
+		"audit": {1, 1, 1, 1},
+	}}
+	windows, err := Indexes{Counts: counts, Logs: 100}.Plan(context.Background(), from, hour(4))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Window{
+		{From: from, To: hour(4), Index: "audit"},
+		{From: from, To: hour(1), Index: "main"},
+		{From: hour(1), To: hour(2), Index: "main"},
+		{From: hour(2), To: hour(3), Index: "main"},
+		{From: hour(3), To: hour(4), Index: "main"},
+	}, windows)
+
+	windows, err = Indexes{Counts: counts, Logs: 1000, MaxSize: 3 * time.Hour}.Plan(context.Background(), from, hour(4))
+	require.NoError(t, err)
+	assert.Equal(t, []Window{
+		{From: from, To: hour(3), Index: "audit"},
+		{From: hour(3), To: hour(4), Index: "audit"},
+		{From: from, To: hour(3), Index: "main"},
+		{From: hour(3), To: hour(4), Index: "main"},
+	}, windows)
+}
+
+func TestIndexesPlanErrors(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := Indexes{Counts: fixedIndexHistogram{err: errors.New("rate limited")}, Logs: 100}.Plan(context.Background(), from, from.Add(time.Hour))
+	assert.ErrorContains(t, err, "failed to count logs by index: rate limited")
+
+	_, err = Indexes{Counts: fixedIndexHistogram{}}.Plan(context.Background(), from, from.Add(time.Hour))
+	assert.Error(t, err)
+
+	windows, err := Indexes{Counts: fixedIndexHistogram{}, Logs: 100}.Plan(context.Background(), from, from.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, windows, "no indexes with logs, nothing to fetch")
+}
+
+func TestAfter(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(n int) time.Time { return from.Add(time.Duration(n) * time.Hour) }
+	windows := []Window{
+		{From: from, To: hour(4), Index: "audit"},
+		{From: from, To: hour(2), Index: "main"},
+		{From: hour(2), To: hour(4), Index: "main"},
+		{From: from, To: hour(4), Index: "web"},
+	}
+
+	// Planned again after the counts moved, the windows of the index being
+	// resumed may not line up with the saved one
+	assert.Equal(t, []Window{
+		{From: hour(3), To: hour(4), Index: "main"},
+		{From: from, To: hour(4), Index: "web"},
+	}, After(windows, Window{From: hour(1), To: hour(3), Index: "main"}))
+	assert.Equal(t, windows[1:], After(windows, Window{From: from, To: hour(4), Index: "audit"}))
+	assert.Empty(t, After(windows, Window{From: from, To: hour(4), Index: "web"}))
+}
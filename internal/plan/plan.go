@@ -0,0 +1,53 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Window is the slice [From, To) of a fetch's time range, limited to Index
+// when it is set
+type Window struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Index string    `json:"index,omitempty"`
+}
+
+// String formats the window for progress output
+func (w Window) String() string {
+	s := fmt.Sprintf("%s to %s", w.From.UTC().Format(time.RFC3339), w.To.UTC().Format(time.RFC3339))
+	if w.Index != "" {
+		s += " in index " + w.Index
+	}
+	return s
+}
+
+// Planner splits a time range into windows that are fetched one after
+// another, oldest first
+type Planner interface {
+	Plan(ctx context.Context, from, to time.Time) ([]Window, error)
+}
+
+// Slices splits a range into windows of equal length; the last one is
+// shorter when the range doesn't divide evenly
+type Slices struct {
+	Size time.Duration
+}
+
+// Plan splits [from, to) into windows of s.Size
+func (s Slices) Plan(_ context.Context, from, to time.Time) ([]Window, error) {
+	if s.Size <= 0 {
+		return nil, fmt.Errorf("window size must be positive, got %s", s.Size)
+	}
+
+	var windows []Window
+	for start := from; start.Before(to); start = start.Add(s.Size) {
+		end := start.Add(s.Size)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, Window{From: start, To: end})
+	}
+	return windows, nil
+}
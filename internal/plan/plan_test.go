@@ -0,0 +1,37 @@
+package plan
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlicesPlan(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	windows, err := Slices{Size: 6 * time.Hour}.Plan(context.Background(), from, from.Add(15*time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Window{
+		{From: from, To: from.Add(6 * time.Hour)},
+		{From: from.Add(6 * time.Hour), To: from.Add(12 * time.Hour)},
+		{From: from.Add(12 * time.Hour), To: from.Add(15 * time.Hour)},
+	}, windows)
+}
+
+func TestSlicesPlanEmptyRange(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	windows, err := Slices{Size: time.Hour}.Plan(context.Background(), from, from)
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+
+	_, err = Slices{}.Plan(context.Background(), from, from.Add(time.Hour))
+	assert.Error(t, err)
+}
+
+func TestWindowString(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2024-01-01T00:00:00Z to 2024-01-01T06:00:00Z", Window{From: from, To: from.Add(6 * time.Hour)}.String())
+}
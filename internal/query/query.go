@@ -0,0 +1,100 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reserved are the fields Datadog queries address without an "@"
+var reserved = map[string]bool{"status": true, "service": true, "host": true, "source": true}
+
+// Reserved reports whether field is a reserved attribute, which queries
+// address without an "@", like service:web
+func Reserved(field string) bool {
+	return reserved[field]
+}
+
+// Builder collects the values of the query-builder flags, each ANDed into the
+// query. Several values of one flag match any of them.
+type Builder struct {
+	Services []string
+	Envs     []string
+	Statuses []string
+	Hosts    []string
+	Tags     []string // key:value
+}
+
+// Empty reports whether no values were given
+func (b Builder) Empty() bool {
+	return len(b.Services)+len(b.Envs)+len(b.Statuses)+len(b.Hosts)+len(b.Tags) == 0
+}
+
+// Build ANDs the builder's terms onto base, which is wrapped in parentheses
+// when it has terms of its own so an OR in it can't swallow theirs
+func (b Builder) Build(base string) (string, error) {
+	terms := []string{
+		term("service", b.Services),
+		term("env", b.Envs),
+		term("status", b.Statuses),
+		term("host", b.Hosts),
+	}
+
+	// Tags with the same key match any of their values, like the other flags
+	var keys []string
+	values := map[string][]string{}
+	for _, tag := range b.Tags {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok || key == "" || value == "" {
+			return "", fmt.Errorf("invalid --tag %q: expected key:value", tag)
+		}
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], value)
+	}
+	for _, key := range keys {
+		terms = append(terms, term(key, values[key]))
+	}
+
+	var parts []string
+	if base = strings.TrimSpace(base); base != "" && base != "*" {
+		if b.Empty() {
+			return base, nil
+		}
+		parts = append(parts, "("+base+")")
+	}
+	for _, t := range terms {
+		if t != "" {
+			parts = append(parts, t)
+		}
+	}
+	if len(parts) == 0 {
+		return base, nil
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// term matches field against any of values; "" without values
+func term(field string, values []string) string {
+	switch len(values) {
+	case 0:
+		return ""
+	case 1:
+		return field + ":" + quote(values[0])
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quote(v)
+	}
+	return field + ":(" + strings.Join(quoted, " OR ") + ")"
+}
+
+// quote quotes values with characters the query syntax would read as
+// operators, leaving * and ? so wildcards such as web-* still work
+func quote(value string) string {
+	if strings.ContainsAny(value, " \t\"():\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,50 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		builder Builder
+		want    string
+	}{
+		{"nothing", "service:web", Builder{}, "service:web"},
+		{"flags only", "", Builder{Services: []string{"web"}, Envs: []string{"prod"}}, "service:web env:prod"},
+		{"star base", "*", Builder{Hosts: []string{"i-0abc"}}, "host:i-0abc"},
+		{"any of", "", Builder{Statuses: []string{"error", "warn"}}, "status:(error OR warn)"},
+		{"with query", "@http.status_code:500 OR @error.kind:Timeout", Builder{Services: []string{"web"}},
+			"(@http.status_code:500 OR @error.kind:Timeout) service:web"},
+		{"tags", "", Builder{Tags: []string{"team:payments", "region:us-east-1", "team:billing"}},
+			"team:(payments OR billing) region:us-east-1"},
+		{"quoting", "", Builder{Services: []string{"web app", "web-*"}}, `service:("web app" OR web-*)`},
+		{"tag value with colon", "", Builder{Tags: []string{"image:repo:tag"}}, `image:"repo:tag"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.builder.Build(tt.base)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReserved(t *testing.T) {
+	for _, field := range []string{"status", "service", "host", "source"} {
+		assert.True(t, Reserved(field), field)
+	}
+	assert.False(t, Reserved("env"))
+	assert.False(t, Reserved("http.status_code"))
+}
+
+func TestBuildInvalidTag(t *testing.T) {
+	for _, tag := range []string{"payments", ":payments", "team:"} {
+		_, err := Builder{Tags: []string{tag}}.Build("")
+		assert.Error(t, err, tag)
+	}
+}
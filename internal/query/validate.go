@@ -0,0 +1,185 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError is a mistake in a query, at a column counted in characters
+// from 1
+type SyntaxError struct {
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Message, e.Column)
+}
+
+// closes maps each opening bracket to the one that closes it; square and
+// curly brackets hold ranges, [400 TO 499] or {400 TO 499}
+var closes = map[string]string{"(": ")", "[": "]", "{": "}"}
+
+// token is a word of a query, quoted phrases included, or a bracket
+type token struct {
+	text string
+	col  int
+	word bool
+}
+
+// Validate checks a query's syntax without sending it: quotes and brackets
+// must balance, AND and OR need a term on each side and NOT one after it,
+// facets need a name and a value, and ranges need TO. It catches the
+// mistakes the API rejects with a 400, not queries that match nothing.
+func Validate(q string) error {
+	tokens, err := tokenize(q)
+	if err != nil {
+		return err
+	}
+
+	type group struct {
+		open  token
+		terms int
+		to    bool
+	}
+	stack := []*group{{}}
+	var op *token // the operator awaiting a term, if any
+	needTerm := func() error {
+		if op == nil {
+			return nil
+		}
+		return &SyntaxError{Column: op.col, Message: fmt.Sprintf("%q needs a term after it", op.text)}
+	}
+
+	for i, t := range tokens {
+		top := stack[len(stack)-1]
+		switch {
+		case !t.word && closes[t.text] != "":
+			stack = append(stack, &group{open: t})
+			continue
+
+		case !t.word:
+			if err := needTerm(); err != nil {
+				return err
+			}
+			if len(stack) == 1 {
+				return &SyntaxError{Column: t.col, Message: fmt.Sprintf("unexpected %q", t.text)}
+			}
+			if closes[top.open.text] != t.text {
+				return &SyntaxError{Column: t.col, Message: fmt.Sprintf("%q doesn't close %q from column %d", t.text, top.open.text, top.open.col)}
+			}
+			if top.terms == 0 {
+				return &SyntaxError{Column: top.open.col, Message: fmt.Sprintf("empty %s%s", top.open.text, t.text)}
+			}
+			if top.open.text != "(" && !top.to {
+				return &SyntaxError{Column: top.open.col, Message: fmt.Sprintf("range needs %slow TO high%s", top.open.text, t.text)}
+			}
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1].terms++
+
+		case t.text == "AND" || t.text == "OR":
+			if top.terms == 0 || op != nil {
+				return &SyntaxError{Column: t.col, Message: fmt.Sprintf("%q needs a term before it", t.text)}
+			}
+			op = &tokens[i]
+
+		case t.text == "NOT":
+			op = &tokens[i]
+
+		default:
+			if t.text == "TO" && top.open.text != "(" && top.open.text != "" {
+				top.to = true
+			} else if err := checkFacet(t, tokens[i+1:]); err != nil {
+				return err
+			}
+			top.terms++
+			op = nil
+		}
+	}
+
+	if err := needTerm(); err != nil {
+		return err
+	}
+	if len(stack) > 1 {
+		open := stack[len(stack)-1].open
+		return &SyntaxError{Column: open.col, Message: fmt.Sprintf("unclosed %q", open.text)}
+	}
+	return nil
+}
+
+// checkFacet checks a word of the form facet:value, or -facet:value to
+// exclude it, has a name and a value. The value can be the group or range
+// that follows straight after the colon.
+func checkFacet(t token, rest []token) error {
+	word := strings.TrimLeft(t.text, "-+")
+	if strings.HasPrefix(word, `"`) {
+		return nil
+	}
+	name, value, ok := strings.Cut(word, ":")
+	if name == "@" || (ok && name == "") {
+		return &SyntaxError{Column: t.col, Message: "facet has no name"}
+	}
+	if !ok || value != "" {
+		return nil
+	}
+	if len(rest) > 0 && !rest[0].word && closes[rest[0].text] != "" && rest[0].col == t.col+utf8.RuneCountInString(t.text) {
+		return nil
+	}
+	return &SyntaxError{Column: t.col, Message: fmt.Sprintf("facet %q has no value", strings.TrimPrefix(name, "@"))}
+}
+
+// tokenize splits a query into words and brackets. A backslash escapes the
+// character after it, and quoted phrases are part of the word they're in,
+// e.g. service:"web app".
+func tokenize(q string) ([]token, error) {
+	var tokens []token
+	var word strings.Builder
+	start, quote := 0, 0 // columns of the word and of an open quote
+	add := func(r rune, col int) {
+		if word.Len() == 0 {
+			start = col
+		}
+		word.WriteRune(r)
+	}
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, token{text: word.String(), col: start, word: true})
+			word.Reset()
+		}
+	}
+
+	runes := []rune(q)
+	for i := 0; i < len(runes); i++ {
+		r, col := runes[i], i+1
+		switch {
+		case r == '\\':
+			add(r, col)
+			if i+1 < len(runes) {
+				i++
+				word.WriteRune(runes[i])
+			}
+		case quote > 0:
+			word.WriteRune(r)
+			if r == '"' {
+				quote = 0
+			}
+		case r == '"':
+			add(r, col)
+			quote = col
+		case unicode.IsSpace(r):
+			flush()
+		case strings.ContainsRune("()[]{}", r):
+			flush()
+			tokens = append(tokens, token{text: string(r), col: col})
+		default:
+			add(r, col)
+		}
+	}
+	if quote > 0 {
+		return nil, &SyntaxError{Column: quote, Message: "unterminated quote"}
+	}
+	flush()
+	return tokens, nil
+}
@@ -0,0 +1,58 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []string{
+		"",
+		"*",
+		"service:web status:error",
+		`service:("web app" OR api) -env:staging`,
+		"@http.status_code:[400 TO 499] @duration:>1000000",
+		"@http.status_code:{400 TO *}",
+		`"connection reset" AND NOT host:i-0abc`,
+		`@error.message:"unexpected \"(\""`,
+		`image:repo:tag url:https\://example.com`,
+		"-(service:web OR service:api)",
+	}
+	for _, q := range valid {
+		assert.NoError(t, Validate(q), q)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+		want   string
+	}{
+		{`service:"web app`, 9, "unterminated quote"},
+		{"service:(web OR api", 9, `unclosed "("`},
+		{"service:web)", 12, `unexpected ")"`},
+		{"service:(web OR api]", 20, `"]" doesn't close "(" from column 9`},
+		{"service:web ()", 13, "empty ()"},
+		{"@http.status_code:[400 499]", 19, "range needs [low TO high]"},
+		{"OR service:web", 1, `"OR" needs a term before it`},
+		{"service:web AND OR env:prod", 17, `"OR" needs a term before it`},
+		{"service:web AND", 13, `"AND" needs a term after it`},
+		{"(service:web NOT)", 14, `"NOT" needs a term after it`},
+		{"service: web", 1, `facet "service" has no value`},
+		{"@http.method:", 1, `facet "http.method" has no value`},
+		{":web", 1, "facet has no name"},
+		{"@:web", 1, "facet has no name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			err := Validate(tt.query)
+			var syntaxErr *SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Equal(t, tt.want, syntaxErr.Message)
+			assert.Equal(t, tt.column, syntaxErr.Column)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package redact
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// presets are the built-in scrubbers, applied to every string field
+var presets = map[string][]Rule{
+	"emails": {{
+		Pattern:     regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+		Replacement: "[EMAIL]",
+	}},
+	"ips": {
+		{
+			Pattern:     regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+			Replacement: "[IP]",
+		},
+		{
+			// Candidates are confirmed by parsing, so times like 10:00:00
+			// and MAC addresses are left alone
+			Pattern:     regexp.MustCompile(`\b[0-9A-Fa-f]{1,4}(?::[0-9A-Fa-f]{0,4}){2,7}\b`),
+			Replacement: "[IP]",
+			Check: func(match string) bool {
+				return net.ParseIP(match) != nil
+			},
+		},
+	},
+	"credit-cards": {{
+		// 13 to 19 digits, optionally grouped with spaces or dashes, that
+		// pass the Luhn check
+		Pattern:     regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Replacement: "[CARD]",
+		Check:       luhn,
+	}},
+	"jwt": {{
+		Pattern:     regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		Replacement: "[JWT]",
+	}},
+}
+
+// PresetNames lists the built-in scrubbers
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Presets returns the rules for the named built-in scrubbers
+func Presets(names []string) ([]Rule, error) {
+	var rules []Rule
+	for _, name := range names {
+		preset, ok := presets[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown scrubber '%s': choose from %s", name, strings.Join(PresetNames(), ", "))
+		}
+		rules = append(rules, preset...)
+	}
+	return rules, nil
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
@@ -0,0 +1,196 @@
+package redact
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule replaces matches of a pattern in one field, or in every string field
+// when Field is empty
+type Rule struct {
+	Field       string
+	Pattern     *regexp.Regexp
+	Replacement string // may refer to capture groups as $1 or ${name}
+
+	// Check, if set, must accept a match for it to be replaced; it lets
+	// presets reject lookalikes a regexp can't rule out
+	Check func(match string) bool
+}
+
+// ParseRule parses a "field:/pattern/=replacement" rule, e.g.
+// attributes.message:/\b\d{16}\b/=<PAN>
+// The field may be omitted ("/pattern/=replacement") to redact every string
+// field. A "/" inside the pattern is written as "\/".
+func ParseRule(spec string) (Rule, error) {
+	start := strings.IndexByte(spec, '/')
+	if start < 0 || (start > 0 && spec[start-1] != ':') {
+		return Rule{}, fmt.Errorf("invalid redaction rule '%s': expected field:/pattern/=replacement", spec)
+	}
+	field := ""
+	if start > 0 {
+		field = strings.TrimSpace(spec[:start-1])
+	}
+
+	end := patternEnd(spec, start+1)
+	if end < 0 {
+		return Rule{}, fmt.Errorf("invalid redaction rule '%s': expected field:/pattern/=replacement", spec)
+	}
+	return NewRule(field, spec[start+1:end], spec[end+2:])
+}
+
+// patternEnd finds the unescaped "/=" closing a pattern that starts at i
+func patternEnd(spec string, i int) int {
+	for ; i < len(spec)-1; i++ {
+		switch {
+		case spec[i] == '\\':
+			i++
+		case spec[i] == '/' && spec[i+1] == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+// NewRule compiles a rule
+func NewRule(field, pattern, replacement string) (Rule, error) {
+	if pattern == "" {
+		return Rule{}, fmt.Errorf("redaction rule for '%s' has an empty pattern", field)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid redaction pattern /%s/: %w", pattern, err)
+	}
+	return Rule{Field: field, Pattern: re, Replacement: replacement}, nil
+}
+
+// rulesFile is the YAML layout LoadRules reads
+type rulesFile struct {
+	Rules []struct {
+		Field   string `yaml:"field"`
+		Pattern string `yaml:"pattern"`
+		Replace string `yaml:"replace"`
+	} `yaml:"rules"`
+}
+
+// LoadRules reads rules from a YAML file of the form
+//
+//	rules:
+//	  - field: attributes.message
+//	    pattern: '\b\d{16}\b'
+//	    replace: '<PAN>'
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file rulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid redaction rules in %s: %w", path, err)
+	}
+
+	rules := make([]Rule, 0, len(file.Rules))
+	for i, r := range file.Rules {
+		rule, err := NewRule(r.Field, r.Pattern, r.Replace)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Redactor applies rules to logs in order
+type Redactor struct {
+	rules []Rule
+}
+
+// New creates a redactor
+func New(rules []Rule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// Page redacts every log of a page in place
+func (r *Redactor) Page(logs []datadogV2.Log) {
+	for i := range logs {
+		r.Log(&logs[i])
+	}
+}
+
+// Log redacts a log in place
+// Strings nested in objects and arrays under a field are redacted too. The
+// id and timestamp are never changed.
+func (r *Redactor) Log(log *datadogV2.Log) {
+	attrs := log.Attributes
+	if attrs == nil {
+		return
+	}
+
+	for _, rule := range r.rules {
+		if rule.Field == "" {
+			for _, s := range []*string{attrs.Message, attrs.Service, attrs.Host, attrs.Status} {
+				if s != nil {
+					*s = rule.replace(*s)
+				}
+			}
+			rule.replaceStrings(attrs.Tags)
+			rule.replaceValue(attrs.Attributes)
+			continue
+		}
+
+		v, ok := logfield.Lookup(*log, rule.Field)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			logfield.Set(log, rule.Field, rule.replace(v))
+		case []string:
+			rule.replaceStrings(v)
+		default:
+			logfield.Set(log, rule.Field, rule.replaceValue(v))
+		}
+	}
+}
+
+func (rule Rule) replace(s string) string {
+	if rule.Check == nil {
+		return rule.Pattern.ReplaceAllString(s, rule.Replacement)
+	}
+	return rule.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+		if !rule.Check(match) {
+			return match
+		}
+		return rule.Pattern.ReplaceAllString(match, rule.Replacement)
+	})
+}
+
+func (rule Rule) replaceStrings(values []string) {
+	for i, s := range values {
+		values[i] = rule.replace(s)
+	}
+}
+
+// replaceValue redacts the strings in a decoded JSON value, updating
+// objects and arrays in place
+func (rule Rule) replaceValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return rule.replace(v)
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = rule.replaceValue(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = rule.replaceValue(child)
+		}
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package redact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule(`attributes.message:/\b\d{16}\b/=<PAN>`)
+	require.NoError(t, err)
+	assert.Equal(t, "attributes.message", rule.Field)
+	assert.Equal(t, `\b\d{16}\b`, rule.Pattern.String())
+	assert.Equal(t, "<PAN>", rule.Replacement)
+
+	// No field, an escaped slash and an empty replacement
+	rule, err = ParseRule(`/token=\w+\/\w+/=`)
+	require.NoError(t, err)
+	assert.Equal(t, "", rule.Field)
+	assert.Equal(t, `token=\w+\/\w+`, rule.Pattern.String())
+	assert.Equal(t, "", rule.Replacement)
+
+	for _, spec := range []string{
+		"message",
+		"message=x",
+		"message/abc/=x",
+		"message:/abc/",
+		"message://=x",
+		"message:/(/=x",
+	} {
+		_, err := ParseRule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRedactorLog(t *testing.T) {
+	log := createLog("paid with 4111111111111111, thanks", map[string]interface{}{
+		"card":  "4111111111111111",
+		"items": []interface{}{"4111111111111111", float64(3)},
+		"usr":   map[string]interface{}{"email": "jane@example.com", "id": float64(7)},
+	})
+	log.Attributes.Tags = []string{"email:jane@example.com", "env:prod"}
+
+	New([]Rule{
+		mustRule(t, `message:/\b\d{16}\b/=<PAN>`),
+		mustRule(t, `usr:/[\w.]+@([\w.]+)/=<email at $1>`),
+		mustRule(t, `tags:/^email:.*/=email:<redacted>`),
+	}).Log(&log)
+
+	attrs := log.Attributes
+	assert.Equal(t, "paid with <PAN>, thanks", *attrs.Message)
+	assert.Equal(t, "4111111111111111", attrs.Attributes["card"], "only the named field is redacted")
+	assert.Equal(t, map[string]interface{}{"email": "<email at example.com>", "id": float64(7)}, attrs.Attributes["usr"])
+	assert.Equal(t, []string{"email:<redacted>", "env:prod"}, attrs.Tags)
+	assert.Equal(t, "log-1", *log.Id)
+}
+
+func TestRedactorEveryField(t *testing.T) {
+	log := createLog("card 4111111111111111", map[string]interface{}{
+		"card":  "4111111111111111",
+		"items": []interface{}{"4111111111111111", float64(3)},
+	})
+	log.Attributes.Tags = []string{"pan:4111111111111111"}
+
+	New([]Rule{mustRule(t, `/\d{16}/=X`)}).Page([]datadogV2.Log{log})
+
+	attrs := log.Attributes
+	assert.Equal(t, "card X", *attrs.Message)
+	assert.Equal(t, "X", attrs.Attributes["card"])
+	assert.Equal(t, []interface{}{"X", float64(3)}, attrs.Attributes["items"])
+	assert.Equal(t, []string{"pan:X"}, attrs.Tags)
+}
+
+func TestRedactorMissingField(t *testing.T) {
+	log := createLog("hello", nil)
+	New([]Rule{mustRule(t, `secret:/.+/=x`)}).Log(&log)
+
+	assert.Equal(t, "hello", *log.Attributes.Message)
+	assert.Nil(t, log.Attributes.Attributes)
+
+	empty := datadogV2.Log{}
+	New([]Rule{mustRule(t, `/.+/=x`)}).Log(&empty)
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`rules:
+  - field: attributes.message
+    pattern: '\b\d{16}\b'
+    replace: '<PAN>'
+  - pattern: 'Bearer \S+'
+    replace: 'Bearer <token>'
+`), 0644))
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "attributes.message", rules[0].Field)
+	assert.Equal(t, "<PAN>", rules[0].Replacement)
+	assert.Equal(t, "", rules[1].Field)
+	assert.Equal(t, `Bearer \S+`, rules[1].Pattern.String())
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - field: message\n    pattern: '('\n"), 0644))
+	_, err = LoadRules(path)
+	assert.ErrorContains(t, err, "rule 1")
+
+	require.NoError(t, os.WriteFile(path, []byte("rules: [\n"), 0644))
+	_, err = LoadRules(path)
+	assert.Error(t, err)
+}
+
+// Helper functions
+
+func mustRule(t *testing.T, spec string) Rule {
+	t.Helper()
+	rule, err := ParseRule(spec)
+	require.NoError(t, err)
+	return rule
+}
+
+func createLog(message string, attrs map[string]interface{}) datadogV2.Log {
+	id := "log-1"
+	service := "web"
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Message:    &message,
+			Service:    &service,
+			Attributes: attrs,
+		},
+	}
+}
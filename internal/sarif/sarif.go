@@ -0,0 +1,281 @@
+package sarif
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// Version and Schema identify the SARIF format written
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// InformationURI is reported as the tool's home page
+const InformationURI = "https://github.com/jtzemp/dogfetch"
+
+// DefaultRuleFields are tried in order to name a log's rule; security
+// signals carry their detection rule, plain logs fall back to the error
+// kind or service
+var DefaultRuleFields = []string{"workflow.rule.id", "rule.id", "evt.name", "error.kind", "service"}
+
+// ruleNameFields hold a human-readable rule name, if any
+var ruleNameFields = []string{"workflow.rule.name", "rule.name", "title"}
+
+// levels maps Datadog statuses to SARIF result levels
+var levels = map[string]string{
+	"emerg":     "error",
+	"emergency": "error",
+	"alert":     "error",
+	"crit":      "error",
+	"critical":  "error",
+	"err":       "error",
+	"error":     "error",
+	"warn":      "warning",
+	"warning":   "warning",
+	"notice":    "note",
+	"info":      "note",
+	"ok":        "note",
+	"success":   "note",
+	"debug":     "none",
+	"trace":     "none",
+}
+
+// severities maps security signal severities to a SARIF level and the
+// CVSS-like score GitHub uses to rank security alerts
+var severities = map[string]struct {
+	level string
+	score float64
+}{
+	"critical": {"error", 9.5},
+	"high":     {"error", 8.0},
+	"medium":   {"warning", 5.5},
+	"low":      {"note", 2.0},
+	"info":     {"note", 0.0},
+}
+
+// Result is a SARIF result
+type Result struct {
+	RuleID              string                 `json:"ruleId"`
+	Level               string                 `json:"level"`
+	Message             Message                `json:"message"`
+	Locations           []Location             `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Message is a SARIF message
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location is a SARIF location
+type Location struct {
+	PhysicalLocation PhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []LogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+// PhysicalLocation is a SARIF physical location
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is a SARIF artifact location
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a SARIF region
+type Region struct {
+	StartLine int `json:"startLine"`
+}
+
+// LogicalLocation is a SARIF logical location
+type LogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// Rule is a SARIF reporting descriptor
+type Rule struct {
+	ID               string                 `json:"id"`
+	Name             string                 `json:"name,omitempty"`
+	ShortDescription Message                `json:"shortDescription"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Driver is the SARIF tool component describing dogfetch
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	InformationURI string `json:"informationUri"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Converter turns logs into SARIF results, collecting the rules they refer
+// to for the tool driver
+type Converter struct {
+	ruleFields []string
+	rules      map[string]*Rule
+}
+
+// NewConverter creates a converter that names rules after the first of
+// ruleFields present on a log (DefaultRuleFields if empty)
+func NewConverter(ruleFields []string) *Converter {
+	if len(ruleFields) == 0 {
+		ruleFields = DefaultRuleFields
+	}
+	return &Converter{ruleFields: ruleFields, rules: make(map[string]*Rule)}
+}
+
+// Result converts a log
+// Logs without a source file (code.filepath) are located at their service,
+// since code-scanning dashboards require every result to have a location.
+func (c *Converter) Result(log datadogV2.Log) Result {
+	ruleID := first(log, c.ruleFields)
+	if ruleID == "" {
+		ruleID = "log"
+	}
+
+	level := "note"
+	if status, ok := logfield.LookupString(log, "status"); ok {
+		if l, ok := levels[strings.ToLower(status)]; ok {
+			level = l
+		}
+	}
+	severity, hasSeverity := severities[strings.ToLower(first(log, []string{"severity"}))]
+	if hasSeverity {
+		level = severity.level
+	}
+
+	rule := c.rule(ruleID, first(log, ruleNameFields))
+	if hasSeverity {
+		if score, _ := rule.Properties["security-severity"].(string); score == "" || parseScore(score) < severity.score {
+			rule.Properties["security-severity"] = strconv.FormatFloat(severity.score, 'f', 1, 64)
+			rule.Properties["tags"] = []string{"security"}
+		}
+	}
+
+	message, _ := logfield.LookupString(log, "message")
+	if message == "" {
+		message = rule.ShortDescription.Text
+	}
+
+	r := Result{
+		RuleID:    ruleID,
+		Level:     level,
+		Message:   Message{Text: message},
+		Locations: []Location{location(log)},
+	}
+	if id := log.GetId(); id != "" {
+		r.PartialFingerprints = map[string]string{"datadogLogId/v1": id}
+	}
+
+	props := make(map[string]interface{})
+	if ts, ok := logfield.Lookup(log, "timestamp"); ok {
+		props["timestamp"] = ts.(time.Time).UTC().Format(time.RFC3339Nano)
+	}
+	for _, f := range []string{"service", "host", "status"} {
+		if v, ok := logfield.LookupString(log, f); ok {
+			props[f] = v
+		}
+	}
+	if tags, ok := logfield.Lookup(log, "tags"); ok {
+		props["tags"] = tags
+	}
+	if len(props) > 0 {
+		r.Properties = props
+	}
+	return r
+}
+
+// Rules returns the rules seen so far, sorted by ID
+func (c *Converter) Rules() []Rule {
+	rules := make([]Rule, 0, len(c.rules))
+	for _, r := range c.rules {
+		rule := *r
+		if len(rule.Properties) == 0 {
+			rule.Properties = nil
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+func (c *Converter) rule(id, name string) *Rule {
+	r, ok := c.rules[id]
+	if !ok {
+		r = &Rule{ID: id, ShortDescription: Message{Text: id}, Properties: make(map[string]interface{})}
+		c.rules[id] = r
+	}
+	if name != "" && r.Name == "" {
+		r.Name = name
+		r.ShortDescription.Text = name
+	}
+	return r
+}
+
+func location(log datadogV2.Log) Location {
+	var loc Location
+	if path, ok := logfield.LookupString(log, "code.filepath"); ok && path != "" {
+		loc.PhysicalLocation.ArtifactLocation.URI = path
+		if line := lineNumber(log); line > 0 {
+			loc.PhysicalLocation.Region = &Region{StartLine: line}
+		}
+	} else {
+		service, _ := logfield.LookupString(log, "service")
+		if service == "" {
+			service = "unknown"
+		}
+		loc.PhysicalLocation.ArtifactLocation.URI = service
+	}
+
+	for _, f := range []struct{ field, kind string }{{"service", "module"}, {"host", "resource"}} {
+		if v, ok := logfield.LookupString(log, f.field); ok && v != "" {
+			loc.LogicalLocations = append(loc.LogicalLocations, LogicalLocation{Name: v, Kind: f.kind})
+		}
+	}
+	return loc
+}
+
+func lineNumber(log datadogV2.Log) int {
+	v, ok := logfield.Lookup(log, "code.lineno")
+	if !ok {
+		return 0
+	}
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+// first returns the first of fields with a non-empty value, as text
+func first(log datadogV2.Log, fields []string) string {
+	for _, f := range fields {
+		v, ok := logfield.Lookup(log, f)
+		if !ok || v == nil {
+			continue
+		}
+		if s := fmt.Sprint(v); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func parseScore(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,106 @@
+package sarif
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ts = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestResultFromSecuritySignal(t *testing.T) {
+	c := NewConverter(nil)
+	r := c.Result(createLog("log-1", "info", "Brute force on admin", map[string]interface{}{
+		"severity": "high",
+		"workflow": map[string]interface{}{
+			"rule": map[string]interface{}{"id": "abc-123", "name": "Credential stuffing"},
+		},
+		"code": map[string]interface{}{"filepath": "src/auth/login.go", "lineno": float64(42)},
+	}))
+
+	assert.Equal(t, "abc-123", r.RuleID)
+	assert.Equal(t, "error", r.Level, "severity takes precedence over status")
+	assert.Equal(t, "Brute force on admin", r.Message.Text)
+	require.Len(t, r.Locations, 1)
+	loc := r.Locations[0]
+	assert.Equal(t, "src/auth/login.go", loc.PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, &Region{StartLine: 42}, loc.PhysicalLocation.Region)
+	assert.Equal(t, []LogicalLocation{{Name: "auth", Kind: "module"}, {Name: "web-1", Kind: "resource"}}, loc.LogicalLocations)
+	assert.Equal(t, map[string]string{"datadogLogId/v1": "log-1"}, r.PartialFingerprints)
+	assert.Equal(t, "2024-01-01T10:00:00Z", r.Properties["timestamp"])
+	assert.Equal(t, "auth", r.Properties["service"])
+
+	rules := c.Rules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "Credential stuffing", rules[0].Name)
+	assert.Equal(t, "Credential stuffing", rules[0].ShortDescription.Text)
+	assert.Equal(t, "8.0", rules[0].Properties["security-severity"])
+}
+
+func TestResultFromLog(t *testing.T) {
+	c := NewConverter(nil)
+	r := c.Result(createLog("log-2", "warn", "", map[string]interface{}{
+		"error": map[string]interface{}{"kind": "Timeout"},
+	}))
+
+	assert.Equal(t, "Timeout", r.RuleID)
+	assert.Equal(t, "warning", r.Level)
+	assert.Equal(t, "Timeout", r.Message.Text, "an empty message falls back to the rule")
+	assert.Equal(t, "auth", r.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Nil(t, r.Locations[0].PhysicalLocation.Region)
+
+	rules := c.Rules()
+	require.Len(t, rules, 1)
+	assert.Nil(t, rules[0].Properties)
+}
+
+func TestRuleFields(t *testing.T) {
+	c := NewConverter([]string{"missing", "http.status_code"})
+	r := c.Result(createLog("log-3", "error", "boom", map[string]interface{}{
+		"http": map[string]interface{}{"status_code": float64(503)},
+	}))
+	assert.Equal(t, "503", r.RuleID)
+
+	r = c.Result(datadogV2.Log{})
+	assert.Equal(t, "log", r.RuleID)
+	assert.Equal(t, "note", r.Level)
+	assert.Equal(t, "unknown", r.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+}
+
+func TestRulesKeepHighestSeverity(t *testing.T) {
+	c := NewConverter([]string{"rule.id"})
+	for _, severity := range []string{"low", "critical", "medium"} {
+		c.Result(createLog("x", "info", "m", map[string]interface{}{
+			"severity": severity,
+			"rule":     map[string]interface{}{"id": "r1"},
+		}))
+	}
+	c.Result(createLog("y", "info", "m", map[string]interface{}{"rule": map[string]interface{}{"id": "r0"}}))
+
+	rules := c.Rules()
+	require.Len(t, rules, 2)
+	assert.Equal(t, "r0", rules[0].ID)
+	assert.Equal(t, "9.5", rules[1].Properties["security-severity"])
+}
+
+// Helper functions
+
+func createLog(id, status, message string, attrs map[string]interface{}) datadogV2.Log {
+	service := "auth"
+	host := "web-1"
+	timestamp := ts
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Service:    &service,
+			Host:       &host,
+			Status:     &status,
+			Message:    &message,
+			Timestamp:  &timestamp,
+			Attributes: attrs,
+		},
+	}
+}
@@ -0,0 +1,297 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/ndscan"
+)
+
+// Version is the index format version
+const Version = 1
+
+// Extension is appended to an NDJSON file's path to name its index
+const Extension = ".idx"
+
+// DefaultBucket is the time bucket width blocks are split on
+const DefaultBucket = time.Minute
+
+// maxBlockRecords caps a block's size so ID lookups stay cheap within a
+// busy bucket
+const maxBlockRecords = 4096
+
+// Index maps time buckets and log ID ranges of an NDJSON file to byte
+// ranges, so readers can seek instead of scanning
+type Index struct {
+	Version int     `json:"version"`
+	Bucket  string  `json:"bucket"`
+	Size    int64   `json:"size"` // bytes of the NDJSON file covered
+	Records int     `json:"records"`
+	Blocks  []Block `json:"blocks"`
+}
+
+// Block is a contiguous run of lines from one time bucket
+// Lines without a timestamp have zero MinTime and MaxTime.
+type Block struct {
+	Offset  int64     `json:"offset"`
+	Length  int64     `json:"length"`
+	Records int       `json:"records"`
+	MinTime time.Time `json:"min_time"`
+	MaxTime time.Time `json:"max_time"`
+	MinID   string    `json:"min_id,omitempty"`
+	MaxID   string    `json:"max_id,omitempty"`
+}
+
+// Path returns the index path for an NDJSON file
+func Path(dataPath string) string {
+	return dataPath + Extension
+}
+
+// Read loads an index from disk
+func Read(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("invalid index %s: %w", path, err)
+	}
+	if idx.Version != Version {
+		return nil, fmt.Errorf("index %s has unsupported version %d", path, idx.Version)
+	}
+	return &idx, nil
+}
+
+// Write saves the index, replacing any previous one atomically
+func (idx *Index) Write(path string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Fresh reports whether the index covers exactly size bytes, i.e. the file
+// hasn't changed since it was indexed
+func (idx *Index) Fresh(size int64) bool {
+	return idx.Size == size
+}
+
+// Select returns the blocks that may hold logs from [from, to); a zero
+// bound is open
+func (idx *Index) Select(from, to time.Time) []Block {
+	var blocks []Block
+	for _, b := range idx.Blocks {
+		if b.MaxTime.IsZero() {
+			continue
+		}
+		if !from.IsZero() && b.MaxTime.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !b.MinTime.Before(to) {
+			continue
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// SelectID returns the blocks whose ID range includes id
+func (idx *Index) SelectID(id string) []Block {
+	var blocks []Block
+	for _, b := range idx.Blocks {
+		if b.MinID <= id && id <= b.MaxID {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// Builder accumulates an index from lines in file order
+type Builder struct {
+	idx    Index
+	bucket time.Duration
+	cur    *Block
+	curKey time.Time
+}
+
+// NewBuilder starts an empty index with the given bucket width
+func NewBuilder(bucket time.Duration) *Builder {
+	if bucket <= 0 {
+		bucket = DefaultBucket
+	}
+	return &Builder{
+		idx:    Index{Version: Version, Bucket: bucket.String(), Blocks: []Block{}},
+		bucket: bucket,
+	}
+}
+
+// Resume continues an existing index, e.g. for a file being appended to
+func Resume(idx *Index) (*Builder, error) {
+	bucket, err := time.ParseDuration(idx.Bucket)
+	if err != nil || bucket <= 0 {
+		return nil, fmt.Errorf("index has invalid bucket '%s'", idx.Bucket)
+	}
+	b := NewBuilder(bucket)
+	b.idx.Size = idx.Size
+	b.idx.Records = idx.Records
+	b.idx.Blocks = append(b.idx.Blocks, idx.Blocks...)
+
+	// Reopen the last block so appended logs from the same bucket join it
+	if n := len(b.idx.Blocks); n > 0 {
+		last := b.idx.Blocks[n-1]
+		b.idx.Blocks = b.idx.Blocks[:n-1]
+		b.cur = &last
+		if !last.MinTime.IsZero() {
+			b.curKey = last.MinTime.Truncate(bucket)
+		}
+	}
+	return b, nil
+}
+
+// Size returns the number of bytes indexed so far
+func (b *Builder) Size() int64 {
+	return b.idx.Size
+}
+
+// Add records a line of length bytes (including its newline) starting at
+// the end of the indexed data
+func (b *Builder) Add(length int64, ts time.Time, id string) {
+	key := time.Time{}
+	if !ts.IsZero() {
+		key = ts.Truncate(b.bucket)
+	}
+	if b.cur == nil || !key.Equal(b.curKey) || b.cur.Records >= maxBlockRecords {
+		b.flush()
+		b.cur = &Block{Offset: b.idx.Size, MinTime: ts, MaxTime: ts, MinID: id, MaxID: id}
+		b.curKey = key
+	}
+
+	c := b.cur
+	c.Length += length
+	c.Records++
+	if ts.Before(c.MinTime) {
+		c.MinTime = ts
+	}
+	if ts.After(c.MaxTime) {
+		c.MaxTime = ts
+	}
+	if id != "" && (c.MinID == "" || id < c.MinID) {
+		c.MinID = id
+	}
+	if id > c.MaxID {
+		c.MaxID = id
+	}
+	b.idx.Size += length
+	b.idx.Records++
+}
+
+// Index returns the index built so far
+func (b *Builder) Index() *Index {
+	b.flush()
+	idx := b.idx
+	idx.Blocks = append([]Block(nil), b.idx.Blocks...)
+	return &idx
+}
+
+func (b *Builder) flush() {
+	if b.cur != nil {
+		b.idx.Blocks = append(b.idx.Blocks, *b.cur)
+		b.cur = nil
+	}
+}
+
+// Build indexes an existing NDJSON file, scanning it in parallel
+// Lines that aren't logs are indexed without a timestamp or ID.
+func Build(path string, bucket time.Duration) (*Index, error) {
+	f, err := ndscan.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return BuildBytes(f.Bytes(), bucket)
+}
+
+// BuildBytes indexes NDJSON data held in memory
+func BuildBytes(data []byte, bucket time.Duration) (*Index, error) {
+	type entry struct {
+		offset int64
+		ts     time.Time
+		id     string
+	}
+
+	var mu sync.Mutex
+	chunks := make(map[int][]entry)
+	err := ndscan.Scan(data, ndscan.Options{}, func(c ndscan.Chunk) error {
+		var entries []entry
+		err := c.Lines(func(offset int64, line []byte) error {
+			ts, id := ParseLine(line)
+			entries = append(entries, entry{offset: offset, ts: ts, id: id})
+			return nil
+		})
+		mu.Lock()
+		chunks[c.Index] = entries
+		mu.Unlock()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]int, 0, len(chunks))
+	for i := range chunks {
+		order = append(order, i)
+	}
+	sort.Ints(order)
+	var entries []entry
+	for _, i := range order {
+		entries = append(entries, chunks[i]...)
+	}
+
+	// Each line owns the bytes up to the next one, so blank lines are
+	// folded into their predecessor and the blocks cover the whole file
+	b := NewBuilder(bucket)
+	if len(entries) == 0 {
+		b.idx.Size = int64(len(data))
+	}
+	for i, e := range entries {
+		start, end := e.offset, int64(len(data))
+		if i == 0 {
+			start = 0
+		}
+		if i+1 < len(entries) {
+			end = entries[i+1].offset
+		}
+		b.Add(end-start, e.ts, e.id)
+	}
+	return b.Index(), nil
+}
+
+// lineKeys is the part of a log line the index needs
+type lineKeys struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"attributes"`
+}
+
+// ParseLine extracts the timestamp and ID of an NDJSON log line; either is
+// zero when missing or invalid
+func ParseLine(line []byte) (time.Time, string) {
+	var keys lineKeys
+	if err := json.Unmarshal(line, &keys); err != nil {
+		return time.Time{}, ""
+	}
+	return keys.Attributes.Timestamp, keys.ID
+}
@@ -0,0 +1,129 @@
+package sidecar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder(time.Minute)
+	b.Add(10, base, "b")
+	b.Add(20, base.Add(20*time.Second), "a")
+	b.Add(30, base.Add(time.Minute), "c")
+	b.Add(5, time.Time{}, "")
+
+	idx := b.Index()
+	assert.Equal(t, Version, idx.Version)
+	assert.Equal(t, "1m0s", idx.Bucket)
+	assert.Equal(t, int64(65), idx.Size)
+	assert.Equal(t, 4, idx.Records)
+	assert.Equal(t, []Block{
+		{Offset: 0, Length: 30, Records: 2, MinTime: base, MaxTime: base.Add(20 * time.Second), MinID: "a", MaxID: "b"},
+		{Offset: 30, Length: 30, Records: 1, MinTime: base.Add(time.Minute), MaxTime: base.Add(time.Minute), MinID: "c", MaxID: "c"},
+		{Offset: 60, Length: 5, Records: 1},
+	}, idx.Blocks)
+
+	// Resuming continues where the index left off
+	r, err := Resume(idx)
+	require.NoError(t, err)
+	r.Add(7, time.Time{}, "")
+	r.Add(8, base, "d")
+	resumed := r.Index()
+	assert.Equal(t, int64(80), resumed.Size)
+	require.Len(t, resumed.Blocks, 4)
+	assert.Equal(t, 2, resumed.Blocks[2].Records, "the last block is reopened")
+	assert.Equal(t, int64(72), resumed.Blocks[3].Offset)
+	assert.Len(t, idx.Blocks, 3, "the original index is unchanged")
+}
+
+func TestBuilderCapsBlockSize(t *testing.T) {
+	b := NewBuilder(time.Hour)
+	for i := 0; i < maxBlockRecords+1; i++ {
+		b.Add(1, base, "")
+	}
+	blocks := b.Index().Blocks
+	require.Len(t, blocks, 2)
+	assert.Equal(t, maxBlockRecords, blocks[0].Records)
+}
+
+func TestSelect(t *testing.T) {
+	b := NewBuilder(time.Minute)
+	for i := 0; i < 5; i++ {
+		b.Add(10, base.Add(time.Duration(i)*time.Minute), fmt.Sprintf("id-%d", i))
+	}
+	b.Add(10, time.Time{}, "")
+	idx := b.Index()
+
+	assert.Len(t, idx.Select(time.Time{}, time.Time{}), 5)
+	blocks := idx.Select(base.Add(time.Minute), base.Add(3*time.Minute))
+	require.Len(t, blocks, 2)
+	assert.Equal(t, int64(10), blocks[0].Offset)
+	assert.Equal(t, int64(20), blocks[1].Offset)
+	assert.Empty(t, idx.Select(base.Add(time.Hour), time.Time{}))
+
+	blocks = idx.SelectID("id-3")
+	require.Len(t, blocks, 1)
+	assert.Equal(t, int64(30), blocks[0].Offset)
+	assert.Empty(t, idx.SelectID("zzz"))
+}
+
+func TestBuildMatchesBuilder(t *testing.T) {
+	var lines []string
+	b := NewBuilder(time.Minute)
+	for i := 0; i < 500; i++ {
+		ts := base.Add(time.Duration(i) * 7 * time.Second)
+		line := fmt.Sprintf(`{"id":"log-%04d","attributes":{"timestamp":"%s","message":"m"}}`+"\n", i, ts.Format(time.RFC3339Nano))
+		lines = append(lines, line)
+		b.Add(int64(len(line)), ts, fmt.Sprintf("log-%04d", i))
+	}
+	data := strings.Join(lines, "")
+
+	idx, err := BuildBytes([]byte(data), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, b.Index(), idx)
+}
+
+func TestBuildFoldsBlankLines(t *testing.T) {
+	data := "\n" + `{"id":"a","attributes":{"timestamp":"2024-01-01T10:00:00Z"}}` + "\n\n" + `not json` + "\n\n"
+
+	idx, err := BuildBytes([]byte(data), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), idx.Size)
+	assert.Equal(t, 2, idx.Records)
+	require.Len(t, idx.Blocks, 2)
+	assert.Equal(t, "a", idx.Blocks[0].MinID)
+	assert.True(t, idx.Blocks[1].MaxTime.IsZero())
+
+	idx, err = BuildBytes([]byte("\n\n"), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), idx.Size)
+	assert.Equal(t, 0, idx.Records)
+}
+
+func TestWriteAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":"a","attributes":{"timestamp":"2024-01-01T10:00:00Z"}}`+"\n"), 0644))
+
+	idx, err := Build(path, DefaultBucket)
+	require.NoError(t, err)
+	require.NoError(t, idx.Write(Path(path)))
+
+	loaded, err := Read(Path(path))
+	require.NoError(t, err)
+	assert.Equal(t, idx, loaded)
+	assert.True(t, loaded.Fresh(idx.Size))
+	assert.False(t, loaded.Fresh(idx.Size+1))
+
+	require.NoError(t, os.WriteFile(Path(path), []byte(`{"version":99}`), 0644))
+	_, err = Read(Path(path))
+	assert.ErrorContains(t, err, "unsupported version")
+}
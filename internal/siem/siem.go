@@ -0,0 +1,250 @@
+package siem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// Vendor and product reported in event headers
+const (
+	Vendor  = "Datadog"
+	Product = "dogfetch"
+)
+
+// leefTimeLayout and leefTimeFormat are the same devTime layout in Go and
+// in the Java notation LEEF consumers expect
+const (
+	leefTimeLayout = "Jan 02 2006 15:04:05.000 MST"
+	leefTimeFormat = "MMM dd yyyy HH:mm:ss.SSS z"
+)
+
+// maxNameLen caps the CEF Name header, which ArcSight truncates at 512
+const maxNameLen = 512
+
+// severities maps Datadog statuses to the 0-10 scale used by both formats
+var severities = map[string]int{
+	"emerg":     10,
+	"emergency": 10,
+	"alert":     9,
+	"crit":      8,
+	"critical":  8,
+	"err":       7,
+	"error":     7,
+	"warn":      5,
+	"warning":   5,
+	"notice":    3,
+	"info":      2,
+	"ok":        2,
+	"success":   2,
+	"debug":     1,
+	"trace":     1,
+}
+
+// Field maps an extension key to a log field, e.g. suser=usr.name
+// An empty Path drops the key from the defaults.
+type Field struct {
+	Key  string
+	Path string
+}
+
+// ParseField parses a "key=field" mapping, e.g. "src=network.client.ip"
+func ParseField(spec string) (Field, error) {
+	key, path, ok := strings.Cut(spec, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return Field{}, fmt.Errorf("invalid field mapping '%s': expected key=field", spec)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return Field{}, fmt.Errorf("invalid key in field mapping '%s': keys are letters, digits and underscores", spec)
+		}
+	}
+	return Field{Key: key, Path: strings.TrimSpace(path)}, nil
+}
+
+// Formatter renders logs as CEF or LEEF lines
+type Formatter struct {
+	leef    bool
+	version string
+	fields  []Field
+}
+
+// NewCEF creates a formatter for ArcSight Common Event Format. Extensions
+// default to rt, dvchost, externalId and msg; fields add to or override them.
+func NewCEF(version string, fields []Field) *Formatter {
+	return &Formatter{
+		version: version,
+		fields: merge([]Field{
+			{Key: "rt", Path: "timestamp"},
+			{Key: "dvchost", Path: "host"},
+			{Key: "externalId", Path: "id"},
+			{Key: "msg", Path: "message"},
+		}, fields),
+	}
+}
+
+// NewLEEF creates a formatter for QRadar Log Event Extended Format 1.0.
+// Attributes default to devTime, sev, cat, identHostName and msg; fields add
+// to or override them.
+func NewLEEF(version string, fields []Field) *Formatter {
+	return &Formatter{
+		leef:    true,
+		version: version,
+		fields: merge([]Field{
+			{Key: "devTime", Path: "timestamp"},
+			{Key: "sev", Path: "status"},
+			{Key: "cat", Path: "service"},
+			{Key: "identHostName", Path: "host"},
+			{Key: "msg", Path: "message"},
+		}, fields),
+	}
+}
+
+// Format renders one log as a single line, without a trailing newline
+func (f *Formatter) Format(log datadogV2.Log) string {
+	status, _ := logfield.LookupString(log, "status")
+	service, _ := logfield.LookupString(log, "service")
+	if service == "" {
+		service = "log"
+	}
+
+	var b strings.Builder
+	if f.leef {
+		// LEEF:Version|Vendor|Product|Version|EventID|
+		b.WriteString("LEEF:1.0|")
+		for _, h := range []string{Vendor, Product, f.version, service} {
+			b.WriteString(escapeHeader(h))
+			b.WriteByte('|')
+		}
+	} else {
+		// CEF:Version|Vendor|Product|Version|SignatureID|Name|Severity|
+		message, _ := logfield.LookupString(log, "message")
+		if message == "" {
+			message = service
+		}
+		if len(message) > maxNameLen {
+			message = strings.ToValidUTF8(message[:maxNameLen], "")
+		}
+		b.WriteString("CEF:0|")
+		for _, h := range []string{Vendor, Product, f.version, service, message, strconv.Itoa(severity(status))} {
+			b.WriteString(escapeHeader(h))
+			b.WriteByte('|')
+		}
+	}
+
+	first := true
+	for _, field := range f.fields {
+		v, ok := logfield.Lookup(log, field.Path)
+		if !ok || v == nil {
+			continue
+		}
+
+		value := f.value(v)
+		if f.leef && field.Key == "sev" {
+			value = strconv.Itoa(severity(value))
+		}
+
+		if !first {
+			if f.leef {
+				b.WriteByte('\t')
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		first = false
+
+		if f.leef && field.Key == "devTime" {
+			if _, ok := v.(time.Time); ok {
+				b.WriteString("devTimeFormat=" + leefTimeFormat + "\t")
+			}
+		}
+		b.WriteString(field.Key)
+		b.WriteByte('=')
+		if f.leef {
+			b.WriteString(escapeLEEF(value))
+		} else {
+			b.WriteString(escapeCEF(value))
+		}
+	}
+	return b.String()
+}
+
+// value renders a field value; timestamps use the format's date convention
+func (f *Formatter) value(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		if f.leef {
+			return v.UTC().Format(leefTimeLayout)
+		}
+		// CEF accepts milliseconds since the epoch for date fields
+		return strconv.FormatInt(v.UnixMilli(), 10)
+	case []string:
+		return strings.Join(v, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// severity converts a Datadog status to the 0-10 scale, defaulting to info
+func severity(status string) int {
+	if s, ok := severities[strings.ToLower(status)]; ok {
+		return s
+	}
+	return severities["info"]
+}
+
+// merge applies overrides to defaults, keeping default order and appending
+// new keys; an override with an empty path removes the key
+func merge(defaults, overrides []Field) []Field {
+	fields := append([]Field(nil), defaults...)
+	for _, o := range overrides {
+		i := 0
+		for i < len(fields) && fields[i].Key != o.Key {
+			i++
+		}
+		switch {
+		case i == len(fields) && o.Path != "":
+			fields = append(fields, o)
+		case i < len(fields) && o.Path != "":
+			fields[i] = o
+		case i < len(fields):
+			fields = append(fields[:i], fields[i+1:]...)
+		}
+	}
+	return fields
+}
+
+// Replacers for the escaping rules below; building one is costly, so they
+// are shared
+var (
+	lineBreakReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+	headerReplacer    = strings.NewReplacer(`\`, `\\`, "|", `\|`)
+	cefReplacer       = strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r", `\r`, "\n", `\n`)
+	leefReplacer      = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+)
+
+// escapeHeader escapes a header value, where pipes separate fields
+func escapeHeader(s string) string {
+	return lineBreakReplacer.Replace(headerReplacer.Replace(s))
+}
+
+// escapeCEF escapes a CEF extension value, where '=' separates keys from
+// values and line breaks are written as \n and \r
+func escapeCEF(s string) string {
+	return cefReplacer.Replace(s)
+}
+
+// escapeLEEF makes a value safe for tab-delimited LEEF, which has no escape
+// syntax, by replacing tabs and line breaks with spaces
+func escapeLEEF(s string) string {
+	return leefReplacer.Replace(s)
+}
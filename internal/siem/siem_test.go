@@ -0,0 +1,84 @@
+package siem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ts = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestCEF(t *testing.T) {
+	f := NewCEF("1.2.3", nil)
+	line := f.Format(createLog("error", "web|api", "host-1", "a=b\nc\\d"))
+
+	assert.Equal(t, `CEF:0|Datadog|dogfetch|1.2.3|web\|api|a=b c\\d|7|`+
+		`rt=1704103200000 dvchost=host-1 externalId=log-1 msg=a\=b\nc\\d`, line)
+}
+
+func TestLEEF(t *testing.T) {
+	f := NewLEEF("1.2.3", nil)
+	line := f.Format(createLog("warn", "web", "host-1", "slow\trequest"))
+
+	assert.Equal(t, "LEEF:1.0|Datadog|dogfetch|1.2.3|web|"+
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z\tdevTime=Jan 01 2024 10:00:00.000 UTC\t"+
+		"sev=5\tcat=web\tidentHostName=host-1\tmsg=slow request", line)
+}
+
+func TestFieldMapping(t *testing.T) {
+	fields := []Field{
+		{Key: "suser", Path: "usr.name"},
+		{Key: "dvchost", Path: "network.client.ip"},
+		{Key: "externalId"},
+		{Key: "cnt", Path: "retries"},
+	}
+	f := NewCEF("1.0", fields)
+
+	line := f.Format(createLog("info", "web", "host-1", "login"))
+	assert.Equal(t, "CEF:0|Datadog|dogfetch|1.0|web|login|2|"+
+		"rt=1704103200000 dvchost=10.0.0.1 msg=login suser=alice cnt=3", line)
+
+	// Missing fields are left out rather than written empty
+	line = f.Format(datadogV2.Log{})
+	assert.Equal(t, "CEF:0|Datadog|dogfetch|1.0|log|log|2|", line)
+}
+
+func TestParseField(t *testing.T) {
+	f, err := ParseField("src=network.client.ip")
+	require.NoError(t, err)
+	assert.Equal(t, Field{Key: "src", Path: "network.client.ip"}, f)
+
+	f, err = ParseField("externalId=")
+	require.NoError(t, err)
+	assert.Equal(t, Field{Key: "externalId"}, f)
+
+	for _, spec := range []string{"src", "=host", "bad key=host", "a|b=host"} {
+		_, err := ParseField(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// Helper functions
+
+func createLog(status, service, host, message string) datadogV2.Log {
+	id := "log-1"
+	timestamp := ts
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Status:    &status,
+			Service:   &service,
+			Host:      &host,
+			Message:   &message,
+			Timestamp: &timestamp,
+			Attributes: map[string]interface{}{
+				"usr":     map[string]interface{}{"name": "alice"},
+				"network": map[string]interface{}{"client": map[string]interface{}{"ip": "10.0.0.1"}},
+				"retries": float64(3),
+			},
+		},
+	}
+}
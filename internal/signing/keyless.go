@@ -0,0 +1,540 @@
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Keyless signatures follow `cosign sign-blob --bundle`: the file is signed
+// with a short-lived key that Fulcio certifies for an OIDC identity, the
+// signature is recorded in Rekor's transparency log, and the signature,
+// certificate and log entry are written to a bundle that
+// `cosign verify-blob --bundle` can check.
+
+// Sigstore's public-good instance, used unless other URLs are given
+const (
+	DefaultFulcioURL = "https://fulcio.sigstore.dev"
+	DefaultRekorURL  = "https://rekor.sigstore.dev"
+)
+
+// BundleSuffix is appended to a file's path to name its keyless bundle
+const BundleSuffix = ".bundle"
+
+// The OIDC issuer extension of Fulcio certificates: v1 holds the issuer as
+// raw bytes, v2 as a DER UTF8String
+var (
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+var errNoIdentity = errors.New("keyless signing needs an OIDC identity token: set SIGSTORE_ID_TOKEN, or run in GitHub Actions with the id-token: write permission")
+
+// Bundle is a keyless signature of a file, as cosign writes it
+type Bundle struct {
+	Base64Signature string       `json:"base64Signature"`
+	Cert            string       `json:"cert"` // base64 of the PEM certificate
+	RekorBundle     *RekorBundle `json:"rekorBundle"`
+}
+
+// RekorBundle is Rekor's promise to include a signature in its log
+type RekorBundle struct {
+	SignedEntryTimestamp []byte       `json:"SignedEntryTimestamp"`
+	Payload              RekorPayload `json:"Payload"`
+}
+
+// RekorPayload is the log entry the SignedEntryTimestamp signs
+type RekorPayload struct {
+	Body           string `json:"body"` // base64 of the hashedrekord entry
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+	LogID          string `json:"logID"`
+}
+
+// Keyless signs files keylessly for the identity of the environment's OIDC
+// token. The certificate is requested on the first signature, since it's
+// only valid for a few minutes, and reused for the rest.
+type Keyless struct {
+	fulcioURL string
+	rekorURL  string
+	client    *http.Client
+	key       *ecdsa.PrivateKey
+	cert      []byte // PEM, once requested
+}
+
+// NewKeyless creates a keyless signer using the given Fulcio and Rekor
+// instances, failing at once if there's no OIDC identity to sign as
+func NewKeyless(fulcioURL, rekorURL string) (*Keyless, error) {
+	if !HasIdentity() {
+		return nil, errNoIdentity
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Keyless{
+		fulcioURL: strings.TrimSuffix(fulcioURL, "/"),
+		rekorURL:  strings.TrimSuffix(rekorURL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		key:       key,
+	}, nil
+}
+
+// HasIdentity reports whether IdentityToken has somewhere to get a token
+func HasIdentity() bool {
+	return os.Getenv("SIGSTORE_ID_TOKEN") != "" ||
+		(os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL") != "" && os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN") != "")
+}
+
+// IdentityToken returns an OIDC identity token for Fulcio: SIGSTORE_ID_TOKEN,
+// or one GitHub Actions issues for the sigstore audience
+func IdentityToken(client *http.Client) (string, error) {
+	if token := os.Getenv("SIGSTORE_ID_TOKEN"); token != "" {
+		return token, nil
+	}
+	requestURL, requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", errNoIdentity
+	}
+
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("audience", "sigstore")
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	var out struct {
+		Value string `json:"value"`
+	}
+	if err := do(client, req, http.StatusOK, &out); err != nil {
+		return "", fmt.Errorf("failed to get a GitHub Actions identity token: %w", err)
+	}
+	return out.Value, nil
+}
+
+// SignFile signs the contents of path and writes a bundle to bundlePath
+func (k *Keyless) SignFile(path, bundlePath string) error {
+	digest, err := digestFile(path)
+	if err != nil {
+		return err
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, k.key, digest)
+	if err != nil {
+		return err
+	}
+	cert, err := k.certificate()
+	if err != nil {
+		return err
+	}
+	entry, err := k.record(digest, sig, cert)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(Bundle{
+		Base64Signature: base64.StdEncoding.EncodeToString(sig),
+		Cert:            base64.StdEncoding.EncodeToString(cert),
+		RekorBundle:     entry,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(bundlePath, append(data, '\n'), 0644)
+}
+
+type fulcioRequest struct {
+	Credentials struct {
+		OIDCIdentityToken string `json:"oidcIdentityToken"`
+	} `json:"credentials"`
+	PublicKeyRequest struct {
+		PublicKey struct {
+			Algorithm string `json:"algorithm"`
+			Content   string `json:"content"`
+		} `json:"publicKey"`
+		ProofOfPossession []byte `json:"proofOfPossession"`
+	} `json:"publicKeyRequest"`
+}
+
+type fulcioChain struct {
+	Chain struct {
+		Certificates []string `json:"certificates"`
+	} `json:"chain"`
+}
+
+type fulcioResponse struct {
+	SignedCertificateEmbeddedSCT *fulcioChain `json:"signedCertificateEmbeddedSct"`
+	SignedCertificateDetachedSCT *fulcioChain `json:"signedCertificateDetachedSct"`
+}
+
+// certificate returns Fulcio's certificate for the signing key, requesting
+// it the first time. Fulcio wants the token's subject signed, to prove the
+// key is ours.
+func (k *Keyless) certificate() ([]byte, error) {
+	if k.cert != nil {
+		return k.cert, nil
+	}
+
+	token, err := IdentityToken(k.client)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(subject))
+	proof, err := ecdsa.SignASN1(rand.Reader, k.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&k.key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var body fulcioRequest
+	body.Credentials.OIDCIdentityToken = token
+	body.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	body.PublicKeyRequest.PublicKey.Content = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	body.PublicKeyRequest.ProofOfPossession = proof
+
+	var out fulcioResponse
+	if err := k.post(k.fulcioURL+"/api/v2/signingCert", body, http.StatusOK, &out); err != nil {
+		return nil, fmt.Errorf("fulcio: %w", err)
+	}
+	chain := out.SignedCertificateEmbeddedSCT
+	if chain == nil {
+		chain = out.SignedCertificateDetachedSCT
+	}
+	if chain == nil || len(chain.Chain.Certificates) == 0 {
+		return nil, errors.New("fulcio: no certificate in the response")
+	}
+	k.cert = []byte(chain.Chain.Certificates[0])
+	return k.cert, nil
+}
+
+// hashedRekord is the Rekor entry for a signature over a digest
+type hashedRekord struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+	} `json:"spec"`
+}
+
+type rekorEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// record adds a signature to Rekor's log
+func (k *Keyless) record(digest, sig, cert []byte) (*RekorBundle, error) {
+	entry := newHashedRekord(digest, base64.StdEncoding.EncodeToString(sig), base64.StdEncoding.EncodeToString(cert))
+
+	var out map[string]rekorEntry
+	if err := k.post(k.rekorURL+"/api/v1/log/entries", entry, http.StatusCreated, &out); err != nil {
+		return nil, fmt.Errorf("rekor: %w", err)
+	}
+	for _, e := range out {
+		return &RekorBundle{
+			SignedEntryTimestamp: e.Verification.SignedEntryTimestamp,
+			Payload: RekorPayload{
+				Body:           e.Body,
+				IntegratedTime: e.IntegratedTime,
+				LogIndex:       e.LogIndex,
+				LogID:          e.LogID,
+			},
+		}, nil
+	}
+	return nil, errors.New("rekor: no entry in the response")
+}
+
+func newHashedRekord(digest []byte, sig, cert string) hashedRekord {
+	var entry hashedRekord
+	entry.APIVersion = "0.0.1"
+	entry.Kind = "hashedrekord"
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(digest)
+	entry.Spec.Signature.Content = sig
+	entry.Spec.Signature.PublicKey.Content = cert
+	return entry
+}
+
+func (k *Keyless) post(url string, in interface{}, status int, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(k.client, req, status, out)
+}
+
+// do sends req and decodes the response into out if it has the expected
+// status
+func do(client *http.Client, req *http.Request, status int, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// tokenSubject returns the identity Fulcio certifies for a token: its email,
+// or its subject if it has none. The token is Fulcio's to verify.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("the OIDC identity token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid OIDC identity token: %w", err)
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("invalid OIDC identity token: %w", err)
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", errors.New("the OIDC identity token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// KeylessPolicy is what a keyless signature must show to be trusted
+type KeylessPolicy struct {
+	// Identity is the email or URI the certificate must be issued to
+	Identity string
+	// Issuer is the OIDC issuer that must have vouched for the identity
+	Issuer string
+	// Chain holds Fulcio's root and intermediate certificates
+	Chain []*x509.Certificate
+	// RekorKey is the public key of the Rekor log
+	RekorKey crypto.PublicKey
+}
+
+// VerifyBundle checks a keyless bundle against the contents of path: the
+// certificate chains to Fulcio and was issued to the policy's identity, Rekor
+// promised to log the signature while the certificate was valid, and the
+// signature matches the file
+func VerifyBundle(path, bundlePath string, policy KeylessPolicy) error {
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		return err
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("invalid bundle %s: %w", bundlePath, err)
+	}
+	if bundle.RekorBundle == nil {
+		return fmt.Errorf("bundle %s has no Rekor entry", bundlePath)
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(bundle.Cert)
+	if err != nil {
+		return fmt.Errorf("invalid certificate in %s: %w", bundlePath, err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return fmt.Errorf("invalid certificate in %s", bundlePath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid certificate in %s: %w", bundlePath, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(bundle.Base64Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature in %s: %w", bundlePath, err)
+	}
+
+	payload := bundle.RekorBundle.Payload
+	if err := verifyRekor(bundle.RekorBundle, policy.RekorKey); err != nil {
+		return err
+	}
+	if err := verifyCertificate(cert, time.Unix(payload.IntegratedTime, 0), policy); err != nil {
+		return err
+	}
+
+	digest, err := digestFile(path)
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !ecdsa.VerifyASN1(pub, digest, sig) {
+		return errors.New("signature verification failed")
+	}
+
+	// The log entry must be for this signature, not just any
+	body, err := base64.StdEncoding.DecodeString(payload.Body)
+	if err != nil {
+		return fmt.Errorf("invalid Rekor entry: %w", err)
+	}
+	var logged hashedRekord
+	if err := json.Unmarshal(body, &logged); err != nil {
+		return fmt.Errorf("invalid Rekor entry: %w", err)
+	}
+	want := newHashedRekord(digest, bundle.Base64Signature, bundle.Cert)
+	if logged.Kind != want.Kind || logged.Spec != want.Spec {
+		return errors.New("the Rekor entry is for a different signature")
+	}
+	return nil
+}
+
+// verifyRekor checks Rekor's signed entry timestamp
+func verifyRekor(bundle *RekorBundle, key crypto.PublicKey) error {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("the Rekor public key must be an ECDSA key")
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	logID := sha256.Sum256(der)
+	if bundle.Payload.LogID != hex.EncodeToString(logID[:]) {
+		return errors.New("the bundle was logged by a different Rekor")
+	}
+
+	// Rekor signs the entry as canonical JSON: keys sorted, no whitespace
+	canonical, err := json.Marshal(map[string]interface{}{
+		"body":           bundle.Payload.Body,
+		"integratedTime": bundle.Payload.IntegratedTime,
+		"logID":          bundle.Payload.LogID,
+		"logIndex":       bundle.Payload.LogIndex,
+	})
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(canonical)
+	if !ecdsa.VerifyASN1(pub, digest[:], bundle.SignedEntryTimestamp) {
+		return errors.New("invalid Rekor signed entry timestamp")
+	}
+	return nil
+}
+
+// verifyCertificate checks cert chains to the policy's Fulcio certificates
+// at the time it was used, and names the policy's identity and issuer
+func verifyCertificate(cert *x509.Certificate, at time.Time, policy KeylessPolicy) error {
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, c := range policy.Chain {
+		if bytes.Equal(c.RawIssuer, c.RawSubject) {
+			roots.AddCert(c)
+		} else {
+			intermediates.AddCert(c)
+		}
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("certificate not issued by Fulcio: %w", err)
+	}
+
+	identities := slices.Clone(cert.EmailAddresses)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+	if !slices.Contains(identities, policy.Identity) {
+		return fmt.Errorf("certificate was issued to %s, not %s", strings.Join(identities, ", "), policy.Identity)
+	}
+
+	issuer := ""
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			asn1.Unmarshal(ext.Value, &issuer)
+		case ext.Id.Equal(oidIssuerV1) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	if issuer != policy.Issuer {
+		return fmt.Errorf("certificate identity was vouched for by %q, not %q", issuer, policy.Issuer)
+	}
+	return nil
+}
+
+// LoadCertificates reads every PEM certificate in a file, such as Fulcio's
+// chain from /api/v1/rootCert
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s contains no certificates", path)
+	}
+	return certs, nil
+}
@@ -0,0 +1,210 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIdentity = "ci@example.com"
+	testIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+// fakeSigstore serves just enough of Fulcio and Rekor to sign keylessly
+type fakeSigstore struct {
+	fulcio   *httptest.Server
+	rekor    *httptest.Server
+	root     *x509.Certificate
+	rekorKey *ecdsa.PrivateKey
+	issued   int
+}
+
+func newFakeSigstore(t *testing.T) *fakeSigstore {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sigstore"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, root, root, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	root, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	s := &fakeSigstore{root: root, rekorKey: rekorKey}
+	s.fulcio = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/signingCert", r.URL.Path)
+		var req fulcioRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		block, _ := pem.Decode([]byte(req.PublicKeyRequest.PublicKey.Content))
+		require.NotNil(t, block)
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		require.NoError(t, err)
+		subject := sha256.Sum256([]byte(testIdentity))
+		assert.True(t, ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), subject[:], req.PublicKeyRequest.ProofOfPossession), "proof of possession")
+
+		issuer, err := asn1.MarshalWithParams(testIssuer, "utf8")
+		require.NoError(t, err)
+		leaf := &x509.Certificate{
+			SerialNumber:    big.NewInt(2),
+			NotBefore:       time.Now().Add(-time.Minute),
+			NotAfter:        time.Now().Add(10 * time.Minute),
+			EmailAddresses:  []string{testIdentity},
+			KeyUsage:        x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuer}},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, leaf, root, pub, caKey)
+		require.NoError(t, err)
+		s.issued++
+
+		var resp fulcioResponse
+		resp.SignedCertificateEmbeddedSCT = &fulcioChain{}
+		resp.SignedCertificateEmbeddedSCT.Chain.Certificates = []string{
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})),
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	s.rekor = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/log/entries", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		pub, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+		require.NoError(t, err)
+		logID := sha256.Sum256(pub)
+		entry := rekorEntry{
+			Body:           base64.StdEncoding.EncodeToString(body),
+			IntegratedTime: time.Now().Unix(),
+			LogID:          hex.EncodeToString(logID[:]),
+			LogIndex:       42,
+		}
+		canonical, err := json.Marshal(map[string]interface{}{
+			"body": entry.Body, "integratedTime": entry.IntegratedTime, "logID": entry.LogID, "logIndex": entry.LogIndex,
+		})
+		require.NoError(t, err)
+		digest := sha256.Sum256(canonical)
+		entry.Verification.SignedEntryTimestamp, err = ecdsa.SignASN1(rand.Reader, rekorKey, digest[:])
+		require.NoError(t, err)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]rekorEntry{"uuid": entry})
+	}))
+	t.Cleanup(s.fulcio.Close)
+	t.Cleanup(s.rekor.Close)
+	return s
+}
+
+func (s *fakeSigstore) policy() KeylessPolicy {
+	return KeylessPolicy{Identity: testIdentity, Issuer: testIssuer, Chain: []*x509.Certificate{s.root}, RekorKey: &s.rekorKey.PublicKey}
+}
+
+// testToken is an unsigned JWT with the given claims; only Fulcio checks
+// the signature
+func testToken(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".sig"
+}
+
+func TestKeylessSignAndVerify(t *testing.T) {
+	s := newFakeSigstore(t)
+	t.Setenv("SIGSTORE_ID_TOKEN", testToken(`{"sub":"123","email":"`+testIdentity+`"}`))
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "logs.ndjson")
+	manifest := filepath.Join(dir, "manifest.json")
+	require.NoError(t, os.WriteFile(logs, []byte("{\"id\":\"1\"}\n"), 0644))
+	require.NoError(t, os.WriteFile(manifest, []byte(`{"files":[]}`), 0644))
+
+	k, err := NewKeyless(s.fulcio.URL, s.rekor.URL)
+	require.NoError(t, err)
+	require.NoError(t, k.SignFile(logs, logs+BundleSuffix))
+	require.NoError(t, k.SignFile(manifest, manifest+BundleSuffix))
+	assert.Equal(t, 1, s.issued, "one certificate covers the run")
+
+	assert.NoError(t, VerifyBundle(logs, logs+BundleSuffix, s.policy()))
+	assert.NoError(t, VerifyBundle(manifest, manifest+BundleSuffix, s.policy()))
+
+	policy := s.policy()
+	policy.Identity = "someone@example.com"
+	assert.ErrorContains(t, VerifyBundle(logs, logs+BundleSuffix, policy), "issued to ci@example.com")
+
+	policy = s.policy()
+	policy.Issuer = "https://accounts.google.com"
+	assert.ErrorContains(t, VerifyBundle(logs, logs+BundleSuffix, policy), "vouched for")
+
+	other := newFakeSigstore(t)
+	policy = s.policy()
+	policy.Chain = []*x509.Certificate{other.root}
+	assert.ErrorContains(t, VerifyBundle(logs, logs+BundleSuffix, policy), "not issued by Fulcio")
+	policy = s.policy()
+	policy.RekorKey = &other.rekorKey.PublicKey
+	assert.ErrorContains(t, VerifyBundle(logs, logs+BundleSuffix, policy), "different Rekor")
+
+	// A bundle vouches for its own file only
+	assert.Error(t, VerifyBundle(manifest, logs+BundleSuffix, s.policy()))
+	require.NoError(t, os.WriteFile(logs, []byte("{\"id\":\"2\"}\n"), 0644))
+	assert.ErrorContains(t, VerifyBundle(logs, logs+BundleSuffix, s.policy()), "signature verification failed")
+}
+
+func TestKeylessNeedsIdentity(t *testing.T) {
+	t.Setenv("SIGSTORE_ID_TOKEN", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "")
+	_, err := NewKeyless(DefaultFulcioURL, DefaultRekorURL)
+	assert.ErrorContains(t, err, "SIGSTORE_ID_TOKEN")
+}
+
+func TestIdentityTokenGitHubActions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "sigstore", r.URL.Query().Get("audience"))
+		assert.Equal(t, "v1", r.URL.Query().Get("api-version"))
+		assert.Equal(t, "Bearer request-token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"value":"id-token"}`))
+	}))
+	defer server.Close()
+	t.Setenv("SIGSTORE_ID_TOKEN", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL+"?api-version=v1")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+
+	assert.True(t, HasIdentity())
+	token, err := IdentityToken(http.DefaultClient)
+	require.NoError(t, err)
+	assert.Equal(t, "id-token", token)
+}
+
+func TestTokenSubject(t *testing.T) {
+	subject, err := tokenSubject(testToken(`{"sub":"repo:jtzemp/dogfetch:ref:refs/heads/main"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "repo:jtzemp/dogfetch:ref:refs/heads/main", subject)
+
+	_, err = tokenSubject("not-a-jwt")
+	assert.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Signatures are base64-encoded, one per file, in the same format as
+// `cosign sign-blob --key`: ECDSA signatures are ASN.1 over the SHA-256 digest
+// and Ed25519 signatures are over the raw content. Files signed with an ECDSA
+// P-256 key can therefore be checked with `cosign verify-blob --key`.
+
+// LoadPrivateKey reads a PEM-encoded Ed25519 or ECDSA private key, as
+// produced by `openssl genpkey -algorithm ed25519` or
+// `openssl ecparam -name prime256v1 -genkey`
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	if block.Type == "EC PRIVATE KEY" {
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key %s: %w", path, err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("private key %s is not an Ed25519 or ECDSA key", path)
+	}
+}
+
+// LoadPublicKey reads a PEM-encoded PKIX Ed25519 or ECDSA public key, as
+// produced by `openssl pkey -pubout` or `cosign generate-key-pair`
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %s: %w", path, err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return k, nil
+	case *ecdsa.PublicKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("public key %s is not an Ed25519 or ECDSA key", path)
+	}
+}
+
+// Sign signs data with an Ed25519 or ECDSA key
+func Sign(key crypto.Signer, data []byte) ([]byte, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, data), nil
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.SignASN1(rand.Reader, k, digest[:])
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// Verify checks a signature produced by Sign
+func Verify(key crypto.PublicKey, data, sig []byte) error {
+	ok := false
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, data, sig)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		ok = ecdsa.VerifyASN1(k, digest[:], sig)
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if !ok {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// SignFile signs the contents of path and writes a base64 signature to sigPath
+// ECDSA keys hash the file as a stream; Ed25519 signs the whole content and
+// so needs it in memory, which makes ECDSA the better choice for big exports.
+func SignFile(key crypto.Signer, path, sigPath string) error {
+	var sig []byte
+	if k, ok := key.(*ecdsa.PrivateKey); ok {
+		digest, err := digestFile(path)
+		if err != nil {
+			return err
+		}
+		sig, err = ecdsa.SignASN1(rand.Reader, k, digest)
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sig, err = Sign(key, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(sigPath, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644)
+}
+
+// VerifyFile checks a base64 signature in sigPath against the contents of path
+func VerifyFile(key crypto.PublicKey, path, sigPath string) error {
+	encoded, err := os.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("invalid signature %s: %w", sigPath, err)
+	}
+
+	if k, ok := key.(*ecdsa.PublicKey); ok {
+		digest, err := digestFile(path)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.VerifyASN1(k, digest, sig) {
+			return errors.New("signature verification failed")
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return Verify(key, data, sig)
+}
+
+// digestFile returns the SHA-256 digest of a file without loading it into memory
+func digestFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,109 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyFile(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := writeTestKeys(t, dir)
+
+	priv, err := LoadPrivateKey(privPath)
+	require.NoError(t, err)
+	pub, err := LoadPublicKey(pubPath)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "manifest.json")
+	sigPath := path + ".sig"
+	require.NoError(t, os.WriteFile(path, []byte(`{"files":[]}`), 0644))
+	require.NoError(t, SignFile(priv, path, sigPath))
+
+	assert.NoError(t, VerifyFile(pub, path, sigPath))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"files":[1]}`), 0644))
+	assert.Error(t, VerifyFile(pub, path, sigPath))
+}
+
+func TestSignAndVerifyFileECDSA(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	// SEC 1 "EC PRIVATE KEY" as written by openssl ecparam
+	privDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	privPath := filepath.Join(dir, "ec.pem")
+	pubPath := filepath.Join(dir, "ec.pub.pem")
+	require.NoError(t, os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privDER}), 0600))
+	require.NoError(t, os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644))
+
+	priv, err := LoadPrivateKey(privPath)
+	require.NoError(t, err)
+	pub, err := LoadPublicKey(pubPath)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "logs.ndjson")
+	sigPath := path + ".sig"
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\":\"1\"}\n"), 0644))
+	require.NoError(t, SignFile(priv, path, sigPath))
+	assert.NoError(t, VerifyFile(pub, path, sigPath))
+
+	// A file signature must match an in-memory one over the same content
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	sig, err := Sign(priv, data)
+	require.NoError(t, err)
+	assert.NoError(t, Verify(pub, data, sig))
+
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\":\"2\"}\n"), 0644))
+	assert.Error(t, VerifyFile(pub, path, sigPath))
+}
+
+func TestLoadKeyErrors(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := writeTestKeys(t, dir)
+
+	_, err := LoadPrivateKey(pubPath)
+	assert.Error(t, err, "a public key is not a private key")
+
+	_, err = LoadPublicKey(privPath)
+	assert.Error(t, err, "a private key is not a public key")
+
+	notPEM := filepath.Join(dir, "not.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("hello"), 0644))
+	_, err = LoadPrivateKey(notPEM)
+	assert.Error(t, err)
+}
+
+// Helper functions
+
+func writeTestKeys(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	privPath := filepath.Join(dir, "key.pem")
+	pubPath := filepath.Join(dir, "key.pub.pem")
+	require.NoError(t, os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600))
+	require.NoError(t, os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644))
+	return privPath, pubPath
+}
@@ -0,0 +1,125 @@
+package slice
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/ndscan"
+	"github.com/jtzemp/dogfetch/internal/sidecar"
+)
+
+// Options select the logs to extract
+type Options struct {
+	From    time.Time // inclusive; zero for no lower bound
+	To      time.Time // exclusive; zero for no upper bound
+	ID      string    // only the log with this ID, if set
+	Workers int       // scan workers; defaults to GOMAXPROCS
+}
+
+// Stats describes how a slice was extracted
+type Stats struct {
+	Records int   // logs written
+	Scanned int64 // bytes read
+	Indexed bool  // whether a sidecar index narrowed the scan
+}
+
+// File writes the logs of an NDJSON file matching opts to out, in file order
+// A fresh sidecar index limits the scan to the blocks that can match; without
+// one the whole file is scanned in parallel.
+func File(path string, opts Options, out io.Writer) (Stats, error) {
+	f, err := ndscan.Open(path)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer f.Close()
+	data := f.Bytes()
+
+	regions := [][2]int64{{0, int64(len(data))}}
+	var stats Stats
+	idx, err := sidecar.Read(sidecar.Path(path))
+	switch {
+	case err == nil && idx.Fresh(int64(len(data))):
+		regions = selectRegions(idx, opts)
+		stats.Indexed = true
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return Stats{}, err
+	}
+
+	scan := ndscan.Options{Workers: opts.Workers}
+	for _, r := range regions {
+		stats.Scanned += r[1] - r[0]
+		counter := &countingWriter{w: out}
+		err := ndscan.Map(data[r[0]:r[1]], scan, func(c ndscan.Chunk) ([]byte, error) {
+			var buf []byte
+			err := c.Lines(func(_ int64, line []byte) error {
+				if opts.match(line) {
+					buf = append(append(buf, line...), '\n')
+				}
+				return nil
+			})
+			return buf, err
+		}, counter)
+		stats.Records += counter.lines
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// selectRegions returns the byte ranges of the blocks that may match,
+// merging adjacent blocks
+func selectRegions(idx *sidecar.Index, opts Options) [][2]int64 {
+	var blocks []sidecar.Block
+	if opts.ID != "" {
+		blocks = idx.SelectID(opts.ID)
+	} else {
+		blocks = idx.Select(opts.From, opts.To)
+	}
+
+	var regions [][2]int64
+	for _, b := range blocks {
+		if n := len(regions); n > 0 && regions[n-1][1] == b.Offset {
+			regions[n-1][1] += b.Length
+			continue
+		}
+		regions = append(regions, [2]int64{b.Offset, b.Offset + b.Length})
+	}
+	return regions
+}
+
+func (o Options) match(line []byte) bool {
+	ts, id := sidecar.ParseLine(line)
+	if o.ID != "" && id != o.ID {
+		return false
+	}
+	if !o.From.IsZero() || !o.To.IsZero() {
+		if ts.IsZero() {
+			return false
+		}
+		if !o.From.IsZero() && ts.Before(o.From) {
+			return false
+		}
+		if !o.To.IsZero() && !ts.Before(o.To) {
+			return false
+		}
+	}
+	return true
+}
+
+// countingWriter counts the lines written through it
+type countingWriter struct {
+	w     io.Writer
+	lines int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' {
+			c.lines++
+		}
+	}
+	return c.w.Write(p)
+}
@@ -0,0 +1,90 @@
+package slice
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/sidecar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestFile(t *testing.T) {
+	path := writeLogs(t, 600)
+	opts := Options{From: base.Add(10 * time.Minute), To: base.Add(20 * time.Minute), Workers: 3}
+
+	var scanned bytes.Buffer
+	stats, err := File(path, opts, &scanned)
+	require.NoError(t, err)
+	assert.False(t, stats.Indexed)
+	assert.Equal(t, 60, stats.Records)
+	assert.Contains(t, scanned.String(), `"log-0060"`)
+	assert.NotContains(t, scanned.String(), `"log-0120"`)
+
+	// With an index the output is the same but far less is read
+	idx, err := sidecar.Build(path, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, idx.Write(sidecar.Path(path)))
+
+	var indexed bytes.Buffer
+	stats, err = File(path, opts, &indexed)
+	require.NoError(t, err)
+	assert.True(t, stats.Indexed)
+	assert.Equal(t, 60, stats.Records)
+	assert.Equal(t, scanned.String(), indexed.String())
+	assert.Less(t, stats.Scanned, idx.Size/5)
+}
+
+func TestFileByID(t *testing.T) {
+	path := writeLogs(t, 300)
+	idx, err := sidecar.Build(path, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, idx.Write(sidecar.Path(path)))
+
+	var out bytes.Buffer
+	stats, err := File(path, Options{ID: "log-0123"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Records)
+	assert.True(t, strings.HasPrefix(out.String(), `{"id":"log-0123"`))
+}
+
+func TestFileIgnoresStaleIndex(t *testing.T) {
+	path := writeLogs(t, 100)
+	idx, err := sidecar.Build(path, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, idx.Write(sidecar.Path(path)))
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(f, `{"id":"late","attributes":{"timestamp":"%s"}}`+"\n", base.Format(time.RFC3339))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	var out bytes.Buffer
+	stats, err := File(path, Options{ID: "late"}, &out)
+	require.NoError(t, err)
+	assert.False(t, stats.Indexed)
+	assert.Equal(t, 1, stats.Records)
+}
+
+// Helper functions
+
+// writeLogs writes n logs ten seconds apart, starting at base
+func writeLogs(t *testing.T, n int) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		ts := base.Add(time.Duration(i) * 10 * time.Second)
+		fmt.Fprintf(&b, `{"id":"log-%04d","attributes":{"timestamp":"%s","message":"request %d"}}`+"\n", i, ts.Format(time.RFC3339), i)
+	}
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0644))
+	return path
+}
@@ -0,0 +1,182 @@
+package slo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Counter counts logs matching a query in [from, to)
+type Counter interface {
+	Count(ctx context.Context, query string, from, to time.Time) (int64, error)
+}
+
+// Spec describes a log-based SLO
+// Good and bad events are the logs matching Query combined with the Good and
+// Bad queries. If Good is empty, every matching log that is not bad is good.
+type Spec struct {
+	Query  string
+	Good   string
+	Bad    string
+	Target float64 // e.g. 0.999
+
+	Window Period
+	// BurnWindows are the shorter look-back windows burn rates are reported for
+	BurnWindows []Period
+}
+
+// Period is a named look-back duration, e.g. "28d"
+type Period struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Report is the result of evaluating a Spec
+type Report struct {
+	Query  string    `json:"query"`
+	Good   string    `json:"good,omitempty"`
+	Bad    string    `json:"bad"`
+	Target float64   `json:"target"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+
+	// Availability and ErrorBudgetRemaining cover the whole SLO window
+	Availability         float64 `json:"availability"`
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+
+	// Windows holds the SLO window first, then each burn window
+	Windows []Window `json:"windows"`
+}
+
+// Window holds the counts and rates of one look-back window
+type Window struct {
+	Name         string    `json:"window"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Good         int64     `json:"good"`
+	Bad          int64     `json:"bad"`
+	Availability float64   `json:"availability"`
+	// BurnRate is the error rate relative to the rate the target allows;
+	// 1 exhausts the budget exactly at the end of the SLO window
+	BurnRate float64 `json:"burn_rate"`
+	// BudgetConsumed is the fraction of the SLO window's error budget spent
+	// by this window's bad events
+	BudgetConsumed float64 `json:"budget_consumed"`
+}
+
+// Evaluate counts good and bad events for the SLO window ending at `to` and
+// for each burn window
+func Evaluate(ctx context.Context, counter Counter, spec Spec, to time.Time) (*Report, error) {
+	if spec.Target <= 0 || spec.Target >= 1 {
+		return nil, fmt.Errorf("target must be between 0 and 1 exclusive, got %g", spec.Target)
+	}
+	if spec.Window.Duration <= 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+
+	report := &Report{
+		Query:  spec.Query,
+		Good:   spec.Good,
+		Bad:    spec.Bad,
+		Target: spec.Target,
+		From:   to.Add(-spec.Window.Duration),
+		To:     to,
+	}
+
+	for _, p := range append([]Period{spec.Window}, spec.BurnWindows...) {
+		w := Window{Name: p.Name, From: to.Add(-p.Duration), To: to}
+		var err error
+		w.Good, w.Bad, err = countWindow(ctx, counter, spec, w.From, w.To)
+		if err != nil {
+			return nil, fmt.Errorf("counting %s window: %w", p.Name, err)
+		}
+		report.Windows = append(report.Windows, w)
+	}
+
+	// Budget is measured against the SLO window's total volume
+	allowedBad := (1 - spec.Target) * float64(report.Windows[0].Good+report.Windows[0].Bad)
+	for i := range report.Windows {
+		w := &report.Windows[i]
+		total := w.Good + w.Bad
+		w.Availability = 1
+		if total > 0 {
+			w.Availability = float64(w.Good) / float64(total)
+			w.BurnRate = (1 - w.Availability) / (1 - spec.Target)
+		}
+		if allowedBad > 0 {
+			w.BudgetConsumed = float64(w.Bad) / allowedBad
+		}
+	}
+
+	report.Availability = report.Windows[0].Availability
+	report.ErrorBudgetRemaining = 1 - report.Windows[0].BudgetConsumed
+	return report, nil
+}
+
+func countWindow(ctx context.Context, counter Counter, spec Spec, from, to time.Time) (int64, int64, error) {
+	bad, err := counter.Count(ctx, Combine(spec.Query, spec.Bad), from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if spec.Good != "" {
+		good, err := counter.Count(ctx, Combine(spec.Query, spec.Good), from, to)
+		return good, bad, err
+	}
+
+	total, err := counter.Count(ctx, spec.Query, from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	return max(total-bad, 0), bad, nil
+}
+
+// Combine ANDs two log queries, either of which may be empty
+func Combine(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	default:
+		return "(" + a + ") (" + b + ")"
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes one row per window
+func (r *Report) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"window", "from", "to", "good", "bad", "availability", "burn_rate", "budget_consumed"}); err != nil {
+		return err
+	}
+
+	for _, win := range r.Windows {
+		err := w.Write([]string{
+			win.Name,
+			win.From.UTC().Format(time.RFC3339),
+			win.To.UTC().Format(time.RFC3339),
+			strconv.FormatInt(win.Good, 10),
+			strconv.FormatInt(win.Bad, 10),
+			strconv.FormatFloat(win.Availability, 'f', 6, 64),
+			strconv.FormatFloat(win.BurnRate, 'f', 4, 64),
+			strconv.FormatFloat(win.BudgetConsumed, 'f', 4, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
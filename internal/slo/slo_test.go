@@ -0,0 +1,132 @@
+package slo
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCounter returns canned counts keyed by query and window length
+type fakeCounter struct {
+	counts map[string]map[time.Duration]int64
+}
+
+func (f *fakeCounter) Count(ctx context.Context, query string, from, to time.Time) (int64, error) {
+	byWindow, ok := f.counts[query]
+	if !ok {
+		return 0, errors.New("unexpected query " + query)
+	}
+	return byWindow[to.Sub(from)], nil
+}
+
+func TestEvaluate(t *testing.T) {
+	window := 28 * 24 * time.Hour
+	counter := &fakeCounter{counts: map[string]map[time.Duration]int64{
+		"(service:web) (status:ok)":    {window: 99950, time.Hour: 90},
+		"(service:web) (status:error)": {window: 50, time.Hour: 10},
+	}}
+
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	report, err := Evaluate(context.Background(), counter, Spec{
+		Query:       "service:web",
+		Good:        "status:ok",
+		Bad:         "status:error",
+		Target:      0.999,
+		Window:      Period{Name: "28d", Duration: window},
+		BurnWindows: []Period{{Name: "1h", Duration: time.Hour}},
+	}, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, to.Add(-window), report.From)
+	assert.InDelta(t, 0.9995, report.Availability, 1e-9)
+	// 50 bad out of 100 allowed
+	assert.InDelta(t, 0.5, report.ErrorBudgetRemaining, 1e-9)
+
+	require.Len(t, report.Windows, 2)
+	assert.Equal(t, "28d", report.Windows[0].Name)
+	assert.InDelta(t, 0.5, report.Windows[0].BurnRate, 1e-9)
+
+	hour := report.Windows[1]
+	assert.Equal(t, "1h", hour.Name)
+	assert.Equal(t, int64(90), hour.Good)
+	assert.Equal(t, int64(10), hour.Bad)
+	// A 10% error rate burns a 0.1% budget 100 times too fast
+	assert.InDelta(t, 100, hour.BurnRate, 1e-9)
+	assert.InDelta(t, 0.1, hour.BudgetConsumed, 1e-9)
+}
+
+func TestEvaluateWithoutGoodQuery(t *testing.T) {
+	window := 24 * time.Hour
+	counter := &fakeCounter{counts: map[string]map[time.Duration]int64{
+		"service:web":                  {window: 1000},
+		"(service:web) (status:error)": {window: 20},
+	}}
+
+	report, err := Evaluate(context.Background(), counter, Spec{
+		Query:  "service:web",
+		Bad:    "status:error",
+		Target: 0.99,
+		Window: Period{Name: "1d", Duration: window},
+	}, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(980), report.Windows[0].Good)
+	assert.InDelta(t, 0.98, report.Availability, 1e-9)
+	assert.InDelta(t, -1, report.ErrorBudgetRemaining, 1e-9, "an overspent budget goes negative")
+}
+
+func TestEvaluateNoTraffic(t *testing.T) {
+	counter := &fakeCounter{counts: map[string]map[time.Duration]int64{
+		"service:web":                  {},
+		"(service:web) (status:error)": {},
+	}}
+
+	report, err := Evaluate(context.Background(), counter, Spec{
+		Query:  "service:web",
+		Bad:    "status:error",
+		Target: 0.999,
+		Window: Period{Name: "1h", Duration: time.Hour},
+	}, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, report.Availability)
+	assert.Equal(t, 1.0, report.ErrorBudgetRemaining)
+	assert.Zero(t, report.Windows[0].BurnRate)
+}
+
+func TestEvaluateRejectsBadTarget(t *testing.T) {
+	_, err := Evaluate(context.Background(), &fakeCounter{}, Spec{
+		Bad:    "status:error",
+		Target: 99.9,
+		Window: Period{Name: "1h", Duration: time.Hour},
+	}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestWriteCSV(t *testing.T) {
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	report := &Report{Windows: []Window{
+		{Name: "1h", From: to.Add(-time.Hour), To: to, Good: 90, Bad: 10, Availability: 0.9, BurnRate: 100, BudgetConsumed: 0.1},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "window", rows[0][0])
+	assert.Equal(t, []string{"1h", "2024-01-31T23:00:00Z", "2024-02-01T00:00:00Z", "90", "10", "0.900000", "100.0000", "0.1000"}, rows[1])
+}
+
+func TestCombine(t *testing.T) {
+	assert.Equal(t, "status:error", Combine("", "status:error"))
+	assert.Equal(t, "service:web", Combine("service:web", ""))
+	assert.Equal(t, "(service:web) (status:error)", Combine("service:web", "status:error"))
+}
@@ -0,0 +1,176 @@
+package spill
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+)
+
+// partitionCount is the number of temporary files records are spread over
+// once the buffer spills. Each partition must fit in memory on its own.
+const partitionCount = 64
+
+// recordOverhead approximates the bookkeeping cost of a record in memory
+const recordOverhead = 64
+
+// Record is a keyed blob of data
+type Record struct {
+	Key  string
+	Data []byte
+}
+
+// Buffer holds keyed records in memory up to a byte budget and spills them
+// to temporary partition files beyond it
+// Records sharing a key always land in the same partition, so callers that
+// group or merge by key can process one partition at a time.
+type Buffer struct {
+	maxMemory int64
+	used      int64
+	mem       []Record
+	files     []*os.File
+	writers   []*bufio.Writer
+}
+
+// New creates a buffer with the given memory budget in bytes
+// A budget of zero or less never spills.
+func New(maxMemory int64) *Buffer {
+	return &Buffer{maxMemory: maxMemory}
+}
+
+// Add buffers a record, spilling to disk if the budget is exceeded
+func (b *Buffer) Add(key string, data []byte) error {
+	if b.files != nil {
+		return b.write(Record{Key: key, Data: data})
+	}
+
+	b.mem = append(b.mem, Record{Key: key, Data: data})
+	b.used += int64(len(key)+len(data)) + recordOverhead
+
+	if b.maxMemory > 0 && b.used > b.maxMemory {
+		return b.spill()
+	}
+	return nil
+}
+
+// Flush spills every in-memory record to disk regardless of the budget
+func (b *Buffer) Flush() error {
+	if b.files != nil {
+		return nil
+	}
+	return b.spill()
+}
+
+// Spilled reports whether records have been written to disk
+func (b *Buffer) Spilled() bool {
+	return b.files != nil
+}
+
+// Each calls fn once per partition with all records of that partition, in
+// insertion order. If nothing was spilled there is a single partition.
+func (b *Buffer) Each(fn func(records []Record) error) error {
+	if b.files == nil {
+		return fn(b.mem)
+	}
+
+	for i, f := range b.files {
+		if err := b.writers[i].Flush(); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		records, err := readRecords(bufio.NewReader(f))
+		if err != nil {
+			return fmt.Errorf("failed to read spill partition: %w", err)
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close removes the temporary files
+func (b *Buffer) Close() error {
+	var errs []error
+	for _, f := range b.files {
+		errs = append(errs, f.Close(), os.Remove(f.Name()))
+	}
+	b.files = nil
+	b.writers = nil
+	b.mem = nil
+	return errors.Join(errs...)
+}
+
+// spill moves every in-memory record to the partition files
+func (b *Buffer) spill() error {
+	b.files = make([]*os.File, partitionCount)
+	b.writers = make([]*bufio.Writer, partitionCount)
+	for i := range b.files {
+		f, err := os.CreateTemp("", "dogfetch-spill-*")
+		if err != nil {
+			b.Close()
+			return fmt.Errorf("failed to create spill file: %w", err)
+		}
+		b.files[i] = f
+		b.writers[i] = bufio.NewWriter(f)
+	}
+
+	for _, r := range b.mem {
+		if err := b.write(r); err != nil {
+			return err
+		}
+	}
+	b.mem = nil
+	b.used = 0
+	return nil
+}
+
+func (b *Buffer) write(r Record) error {
+	h := fnv.New32a()
+	h.Write([]byte(r.Key))
+	w := b.writers[h.Sum32()%partitionCount]
+
+	var lens [2 * binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lens[:], uint64(len(r.Key)))
+	n += binary.PutUvarint(lens[n:], uint64(len(r.Data)))
+	if _, err := w.Write(lens[:n]); err != nil {
+		return err
+	}
+	if _, err := w.WriteString(r.Key); err != nil {
+		return err
+	}
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func readRecords(r *bufio.Reader) ([]Record, error) {
+	var records []Record
+	for {
+		keyLen, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		dataLen, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, keyLen+dataLen)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		records = append(records, Record{Key: string(buf[:keyLen]), Data: buf[keyLen:]})
+	}
+}
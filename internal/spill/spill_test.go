@@ -0,0 +1,86 @@
+package spill
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferInMemory(t *testing.T) {
+	b := New(0)
+	defer b.Close()
+
+	require.NoError(t, b.Add("a", []byte("1")))
+	require.NoError(t, b.Add("b", []byte("2")))
+	assert.False(t, b.Spilled())
+
+	calls := 0
+	require.NoError(t, b.Each(func(records []Record) error {
+		calls++
+		assert.Equal(t, []Record{{Key: "a", Data: []byte("1")}, {Key: "b", Data: []byte("2")}}, records)
+		return nil
+	}))
+	assert.Equal(t, 1, calls)
+}
+
+func TestBufferSpillsAndGroupsKeys(t *testing.T) {
+	b := New(1024)
+
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key-%d", i%20)
+		require.NoError(t, b.Add(key, []byte(fmt.Sprintf("%d", i))))
+	}
+	require.True(t, b.Spilled())
+
+	total := 0
+	seen := make(map[string]int)
+	require.NoError(t, b.Each(func(records []Record) error {
+		keys := make(map[string]bool)
+		for _, r := range records {
+			keys[r.Key] = true
+			total++
+		}
+		// A key must never be split across partitions
+		for key := range keys {
+			seen[key]++
+		}
+		return nil
+	}))
+
+	assert.Equal(t, 500, total)
+	assert.Len(t, seen, 20)
+	for key, partitions := range seen {
+		assert.Equal(t, 1, partitions, "key %s appeared in several partitions", key)
+	}
+
+	var names []string
+	for _, f := range b.files {
+		names = append(names, f.Name())
+	}
+	require.NoError(t, b.Close())
+	for _, name := range names {
+		_, err := os.Stat(name)
+		assert.True(t, os.IsNotExist(err), "spill file %s should be removed", name)
+	}
+}
+
+func TestBufferPreservesInsertionOrderWithinKey(t *testing.T) {
+	b := New(1)
+	defer b.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, b.Add("same", []byte{byte(i)}))
+	}
+
+	var got []byte
+	require.NoError(t, b.Each(func(records []Record) error {
+		for _, r := range records {
+			got = append(got, r.Data...)
+		}
+		return nil
+	}))
+	assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, got)
+}
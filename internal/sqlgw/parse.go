@@ -0,0 +1,410 @@
+package sqlgw
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Select is a parsed "SELECT ... FROM logs [WHERE ...] [LIMIT n]" statement
+type Select struct {
+	Columns []Column // nil for SELECT *
+	Table   string
+	Where   Expr // nil when there is no WHERE clause
+	Limit   int  // -1 when there is no LIMIT clause
+}
+
+// Column is a selected log field and the name it is returned under
+type Column struct {
+	Field string
+	Name  string
+}
+
+// Expr is a boolean WHERE clause expression
+type Expr interface {
+	expr()
+}
+
+// And is true when both sides are
+type And struct{ Left, Right Expr }
+
+// Or is true when either side is
+type Or struct{ Left, Right Expr }
+
+// Not negates an expression
+type Not struct{ Expr Expr }
+
+// Comparison tests a log field, e.g. service = 'web' or duration > 100
+// Op is one of =, !=, <, <=, >, >=, LIKE, IN or IS NULL; Values holds one
+// literal, or the list for IN.
+type Comparison struct {
+	Field  string
+	Op     string
+	Values []interface{} // string, float64 or bool
+}
+
+func (And) expr()        {}
+func (Or) expr()         {}
+func (Not) expr()        {}
+func (Comparison) expr() {}
+
+// token kinds
+const (
+	tokEOF = iota
+	tokIdent
+	tokKeyword
+	tokString
+	tokNumber
+	tokSymbol
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// keywords are reserved words, matched case-insensitively
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "IN": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+}
+
+// Parse parses a single SELECT statement
+func Parse(sql string) (*Select, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.parseSelect()
+}
+
+func lex(sql string) ([]token, error) {
+	var tokens []token
+	r := []rune(sql)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(r) && r[i+1] == '-':
+			// Comment to end of line
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '\'' || c == '"':
+			// Quotes are escaped by doubling them, as in standard SQL
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(r) {
+					return nil, fmt.Errorf("unterminated quoted string")
+				}
+				if r[i] == c {
+					if i+1 < len(r) && r[i+1] == c {
+						b.WriteRune(c)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(r[i])
+				i++
+			}
+			kind := tokString
+			if c == '"' {
+				kind = tokIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: b.String()})
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(r) && unicode.IsDigit(r[i+1])):
+			start := i
+			i++
+			for i < len(r) && (unicode.IsDigit(r[i]) || r[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(r[start:i])})
+		case isIdentRune(c):
+			start := i
+			for i < len(r) && (isIdentRune(r[i]) || unicode.IsDigit(r[i])) {
+				i++
+			}
+			word := string(r[start:i])
+			if keywords[strings.ToUpper(word)] {
+				tokens = append(tokens, token{kind: tokKeyword, text: strings.ToUpper(word)})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word})
+			}
+		default:
+			sym := matchSymbol(r[i:])
+			if sym == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokSymbol, text: sym})
+			i += len(sym)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// symbols lists operators and punctuation, longest first
+var symbols = []string{"<=", ">=", "<>", "!=", "=", "<", ">", "(", ")", ",", "*", ";"}
+
+func matchSymbol(r []rune) string {
+	for _, sym := range symbols {
+		if strings.HasPrefix(string(r[:min(len(r), 2)]), sym) {
+			return sym
+		}
+	}
+	return ""
+}
+
+// comparisonOps are the binary comparison operators
+var comparisonOps = map[string]bool{"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// isIdentRune reports whether c can start an identifier; dots and @ allow
+// attribute paths such as @http.status_code
+func isIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || c == '_' || c == '.' || c == '@'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given keyword or symbol
+func (p *parser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokKeyword || t.kind == tokSymbol) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %s, got %s", text, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) parseSelect() (*Select, error) {
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+
+	stmt := &Select{Limit: -1}
+	if !p.accept("*") {
+		for {
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, fmt.Errorf("expected a column name, got %s", describe(t))
+			}
+			col := Column{Field: t.text, Name: t.text}
+			if p.accept("AS") {
+				alias := p.next()
+				if alias.kind != tokIdent {
+					return nil, fmt.Errorf("expected an alias after AS, got %s", describe(alias))
+				}
+				col.Name = alias.text
+			}
+			stmt.Columns = append(stmt.Columns, col)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	table := p.next()
+	if table.kind != tokIdent {
+		return nil, fmt.Errorf("expected a table name, got %s", describe(table))
+	}
+	stmt.Table = table.text
+
+	if p.accept("WHERE") {
+		where, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where = where
+	}
+
+	if p.accept("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil || n < 0 {
+			return nil, fmt.Errorf("LIMIT must be a non-negative integer, got %s", describe(t))
+		}
+		stmt.Limit = n
+	}
+
+	p.accept(";")
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s", describe(t))
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.accept("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: e}, nil
+	}
+	if p.accept("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, fmt.Errorf("expected a column name, got %s", describe(t))
+	}
+	field := t.text
+
+	if p.accept("IS") {
+		negate := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return maybeNot(Comparison{Field: field, Op: "IS NULL"}, negate), nil
+	}
+
+	negate := p.accept("NOT")
+	switch {
+	case p.accept("LIKE"):
+		v := p.next()
+		if v.kind != tokString {
+			return nil, fmt.Errorf("LIKE needs a string pattern, got %s", describe(v))
+		}
+		return maybeNot(Comparison{Field: field, Op: "LIKE", Values: []interface{}{v.text}}, negate), nil
+	case p.accept("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for {
+			v, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return maybeNot(Comparison{Field: field, Op: "IN", Values: values}, negate), nil
+	case negate:
+		return nil, fmt.Errorf("expected LIKE or IN after NOT, got %s", describe(p.peek()))
+	}
+
+	op := p.next()
+	if op.kind != tokSymbol || !comparisonOps[op.text] {
+		return nil, fmt.Errorf("expected a comparison operator, got %s", describe(op))
+	}
+	v, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	opText := op.text
+	if opText == "<>" {
+		opText = "!="
+	}
+	return Comparison{Field: field, Op: opText, Values: []interface{}{v}}, nil
+}
+
+func (p *parser) parseLiteral() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokString:
+		return t.text, nil
+	case t.kind == tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t.text)
+		}
+		return f, nil
+	case t.kind == tokKeyword && (t.text == "TRUE" || t.text == "FALSE"):
+		return t.text == "TRUE", nil
+	default:
+		return nil, fmt.Errorf("expected a value, got %s", describe(t))
+	}
+}
+
+func maybeNot(e Expr, negate bool) Expr {
+	if negate {
+		return Not{Expr: e}
+	}
+	return e
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return fmt.Sprintf("'%s'", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
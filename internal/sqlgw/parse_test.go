@@ -0,0 +1,63 @@
+package sqlgw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	stmt, err := Parse(`SELECT timestamp, @http.status_code AS code, "error.kind" FROM logs
+		WHERE service = 'web' AND (status IN ('error', 'warn') OR duration >= 1.5)
+		AND NOT message LIKE '%health%' AND usr.id IS NOT NULL LIMIT 10;`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Column{
+		{Field: "timestamp", Name: "timestamp"},
+		{Field: "@http.status_code", Name: "code"},
+		{Field: "error.kind", Name: "error.kind"},
+	}, stmt.Columns)
+	assert.Equal(t, "logs", stmt.Table)
+	assert.Equal(t, 10, stmt.Limit)
+
+	assert.Equal(t, And{
+		Left: And{
+			Left: And{
+				Left: Comparison{Field: "service", Op: "=", Values: []interface{}{"web"}},
+				Right: Or{
+					Left:  Comparison{Field: "status", Op: "IN", Values: []interface{}{"error", "warn"}},
+					Right: Comparison{Field: "duration", Op: ">=", Values: []interface{}{1.5}},
+				},
+			},
+			Right: Not{Expr: Comparison{Field: "message", Op: "LIKE", Values: []interface{}{"%health%"}}},
+		},
+		Right: Not{Expr: Comparison{Field: "usr.id", Op: "IS NULL"}},
+	}, stmt.Where)
+}
+
+func TestParseSelectStar(t *testing.T) {
+	stmt, err := Parse("select * from logs where message <> 'it''s'")
+	require.NoError(t, err)
+	assert.Nil(t, stmt.Columns)
+	assert.Equal(t, -1, stmt.Limit)
+	assert.Equal(t, Comparison{Field: "message", Op: "!=", Values: []interface{}{"it's"}}, stmt.Where)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, sql := range []string{
+		"DELETE FROM logs",
+		"SELECT FROM logs",
+		"SELECT * FROM",
+		"SELECT * FROM logs WHERE service",
+		"SELECT * FROM logs WHERE service = ",
+		"SELECT * FROM logs WHERE service = 'web",
+		"SELECT * FROM logs WHERE (service = 'web'",
+		"SELECT * FROM logs LIMIT -1",
+		"SELECT * FROM logs ORDER BY timestamp",
+		"SELECT * FROM logs WHERE service NOT = 'web'",
+	} {
+		_, err := Parse(sql)
+		assert.Error(t, err, sql)
+	}
+}
@@ -0,0 +1,336 @@
+package sqlgw
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// DefaultColumns are returned for SELECT *
+var DefaultColumns = []Column{
+	{Field: "id", Name: "id"},
+	{Field: "timestamp", Name: "timestamp"},
+	{Field: "status", Name: "status"},
+	{Field: "service", Name: "service"},
+	{Field: "host", Name: "host"},
+	{Field: "message", Name: "message"},
+}
+
+// reservedFields are searched without the "@" attribute prefix
+var reservedFields = map[string]bool{"service": true, "host": true, "status": true, "source": true}
+
+// Plan is a statement translated into a Datadog search plus the parts that
+// have to be evaluated on the fetched logs
+type Plan struct {
+	Query   string    // Datadog search query; "*" when nothing is pushed down
+	From    time.Time // zero when the statement has no lower time bound
+	To      time.Time // zero when the statement has no upper time bound
+	Columns []Column
+	Limit   int // -1 for no limit
+
+	where Expr
+	likes map[string]*regexp.Regexp // compiled LIKE patterns
+}
+
+// NewPlan translates a statement. Equality tests and timestamp bounds that
+// must hold for every row are pushed into the Datadog query and time range;
+// the whole WHERE clause is still checked client-side, so pushdown only
+// narrows what is fetched.
+func NewPlan(stmt *Select) *Plan {
+	p := &Plan{
+		Columns: stmt.Columns,
+		Limit:   stmt.Limit,
+		where:   stmt.Where,
+		likes:   make(map[string]*regexp.Regexp),
+	}
+	if p.Columns == nil {
+		p.Columns = DefaultColumns
+	}
+
+	walk(stmt.Where, func(cmp Comparison) {
+		if cmp.Op == "LIKE" {
+			pattern := cmp.Values[0].(string)
+			p.likes[pattern] = likeRegexp(pattern)
+		}
+	})
+
+	var terms []string
+	for _, c := range conjuncts(stmt.Where) {
+		cmp, ok := c.(Comparison)
+		if !ok {
+			continue
+		}
+		if normalizeField(cmp.Field) == "timestamp" {
+			p.pushTime(cmp)
+			continue
+		}
+		if term := searchTerm(cmp); term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	p.Query = strings.Join(terms, " ")
+	if p.Query == "" {
+		p.Query = "*"
+	}
+	return p
+}
+
+// Match reports whether a log satisfies the WHERE clause
+func (p *Plan) Match(log datadogV2.Log) bool {
+	return p.where == nil || p.eval(p.where, log)
+}
+
+// Row renders the selected columns of a log as text; missing fields are nil
+func (p *Plan) Row(log datadogV2.Log) []*string {
+	row := make([]*string, len(p.Columns))
+	for i, col := range p.Columns {
+		v, ok := logfield.Lookup(log, col.Field)
+		if !ok || v == nil {
+			continue
+		}
+		s := text(v)
+		row[i] = &s
+	}
+	return row
+}
+
+// pushTime narrows the time range from a timestamp comparison
+func (p *Plan) pushTime(cmp Comparison) {
+	if len(cmp.Values) != 1 {
+		return
+	}
+	t, ok := toTime(cmp.Values[0])
+	if !ok {
+		return
+	}
+	switch cmp.Op {
+	case ">", ">=":
+		if t.After(p.From) {
+			p.From = t
+		}
+	case "<", "<=":
+		// The API's upper bound is exclusive, so widen <= by a millisecond
+		if cmp.Op == "<=" {
+			t = t.Add(time.Millisecond)
+		}
+		if p.To.IsZero() || t.Before(p.To) {
+			p.To = t
+		}
+	case "=":
+		p.From, p.To = t, t.Add(time.Millisecond)
+	}
+}
+
+// conjuncts splits an expression into the terms that must all hold
+func conjuncts(e Expr) []Expr {
+	if and, ok := e.(And); ok {
+		return append(conjuncts(and.Left), conjuncts(and.Right)...)
+	}
+	if e == nil {
+		return nil
+	}
+	return []Expr{e}
+}
+
+// searchTerm translates an equality or IN test into Datadog search syntax,
+// or returns "" when it can't be expressed exactly
+func searchTerm(cmp Comparison) string {
+	if cmp.Op != "=" && cmp.Op != "IN" {
+		return ""
+	}
+
+	field := normalizeField(cmp.Field)
+	if field == "message" || field == "id" || field == "tags" {
+		return ""
+	}
+	if !reservedFields[field] {
+		field = "@" + field
+	}
+
+	values := make([]string, 0, len(cmp.Values))
+	for _, v := range cmp.Values {
+		switch v := v.(type) {
+		case string:
+			values = append(values, `"`+strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v)+`"`)
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return ""
+		}
+	}
+	if len(values) == 1 {
+		return field + ":" + values[0]
+	}
+	return field + ":(" + strings.Join(values, " OR ") + ")"
+}
+
+// walk calls fn for every comparison in an expression
+func walk(e Expr, fn func(Comparison)) {
+	switch e := e.(type) {
+	case And:
+		walk(e.Left, fn)
+		walk(e.Right, fn)
+	case Or:
+		walk(e.Left, fn)
+		walk(e.Right, fn)
+	case Not:
+		walk(e.Expr, fn)
+	case Comparison:
+		fn(e)
+	}
+}
+
+func (p *Plan) eval(e Expr, log datadogV2.Log) bool {
+	switch e := e.(type) {
+	case And:
+		return p.eval(e.Left, log) && p.eval(e.Right, log)
+	case Or:
+		return p.eval(e.Left, log) || p.eval(e.Right, log)
+	case Not:
+		return !p.eval(e.Expr, log)
+	case Comparison:
+		return p.compare(e, log)
+	}
+	return false
+}
+
+// compare evaluates a comparison; like SQL, tests on a missing field are
+// false except IS NULL
+func (p *Plan) compare(cmp Comparison, log datadogV2.Log) bool {
+	v, ok := logfield.Lookup(log, cmp.Field)
+	if !ok || v == nil {
+		return cmp.Op == "IS NULL"
+	}
+
+	switch cmp.Op {
+	case "IS NULL":
+		return false
+	case "IN":
+		for _, want := range cmp.Values {
+			if order(v, want) == 0 {
+				return true
+			}
+		}
+		return false
+	case "LIKE":
+		return p.likes[cmp.Values[0].(string)].MatchString(text(v))
+	}
+
+	o := order(v, cmp.Values[0])
+	switch cmp.Op {
+	case "=":
+		return o == 0
+	case "!=":
+		return o != 0
+	case "<":
+		return o < 0
+	case "<=":
+		return o <= 0
+	case ">":
+		return o > 0
+	case ">=":
+		return o >= 0
+	}
+	return false
+}
+
+// order compares a field value with a literal: timestamps as times,
+// numbers numerically and everything else as text
+func order(v, literal interface{}) int {
+	if t, ok := v.(time.Time); ok {
+		if lt, ok := toTime(literal); ok {
+			return t.Compare(lt)
+		}
+	}
+
+	if lf, ok := literal.(float64); ok {
+		if f, ok := toFloat(v); ok {
+			switch {
+			case f < lf:
+				return -1
+			case f > lf:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(text(v), text(literal))
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	case float64:
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// text renders a value the way it is returned to SQL clients
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []string, map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// likeRegexp converts a LIKE pattern, where % matches any run of characters
+// and _ any single character
+func likeRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// normalizeField strips the attribute prefixes logfield accepts
+func normalizeField(field string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(field, "@"), "attributes.")
+}
@@ -0,0 +1,91 @@
+package sqlgw
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ts = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestPlanPushdown(t *testing.T) {
+	plan := mustPlan(t, `SELECT * FROM logs WHERE service = 'web' AND error.kind IN ('Timeout', 'Reset')
+		AND http.status_code = 500 AND message LIKE '%x%' AND (host = 'a' OR host = 'b')
+		AND timestamp >= '2024-01-01T00:00:00Z' AND timestamp < '2024-01-02'`)
+
+	// Only conditions every row must meet are pushed down
+	assert.Equal(t, `service:"web" @error.kind:("Timeout" OR "Reset") @http.status_code:500`, plan.Query)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), plan.From)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), plan.To)
+	assert.Equal(t, DefaultColumns, plan.Columns)
+
+	assert.Equal(t, "*", mustPlan(t, "SELECT * FROM logs WHERE service != 'web'").Query)
+	assert.Equal(t, `service:"a \"b\""`, mustPlan(t, `SELECT * FROM logs WHERE service = 'a "b"'`).Query)
+}
+
+func TestPlanMatch(t *testing.T) {
+	log := createLog("web", "error", "GET /health failed", map[string]interface{}{
+		"duration": float64(250),
+		"usr":      map[string]interface{}{"id": "u1"},
+	})
+
+	for sql, want := range map[string]bool{
+		"SELECT * FROM logs":                                          true,
+		"SELECT * FROM logs WHERE service = 'web'":                    true,
+		"SELECT * FROM logs WHERE service = 'api'":                    false,
+		"SELECT * FROM logs WHERE duration > 100 AND duration <= 250": true,
+		"SELECT * FROM logs WHERE duration > 250":                     false,
+		"SELECT * FROM logs WHERE message LIKE 'GET /health%'":        true,
+		"SELECT * FROM logs WHERE message LIKE 'GET _health%'":        true,
+		"SELECT * FROM logs WHERE message LIKE 'GET _/health%'":       false,
+		"SELECT * FROM logs WHERE NOT status IN ('error', 'warn')":    false,
+		"SELECT * FROM logs WHERE usr.id IS NOT NULL":                 true,
+		"SELECT * FROM logs WHERE missing IS NULL":                    true,
+		"SELECT * FROM logs WHERE missing = 'x' OR service = 'web'":   true,
+		"SELECT * FROM logs WHERE timestamp >= '2024-01-01 10:00:00'": true,
+		"SELECT * FROM logs WHERE timestamp < '2024-01-01T09:00:00Z'": false,
+	} {
+		assert.Equal(t, want, mustPlan(t, sql).Match(log), sql)
+	}
+}
+
+func TestPlanRow(t *testing.T) {
+	plan := mustPlan(t, "SELECT timestamp, duration, usr, missing FROM logs")
+	row := plan.Row(createLog("web", "info", "ok", map[string]interface{}{
+		"duration": float64(1.5),
+		"usr":      map[string]interface{}{"id": "u1"},
+	}))
+
+	require.Len(t, row, 4)
+	assert.Equal(t, "2024-01-01T10:00:00Z", *row[0])
+	assert.Equal(t, "1.5", *row[1])
+	assert.Equal(t, `{"id":"u1"}`, *row[2])
+	assert.Nil(t, row[3])
+}
+
+// Helper functions
+
+func mustPlan(t *testing.T, sql string) *Plan {
+	t.Helper()
+	stmt, err := Parse(sql)
+	require.NoError(t, err)
+	return NewPlan(stmt)
+}
+
+func createLog(service, status, message string, attrs map[string]interface{}) datadogV2.Log {
+	id := "log-1"
+	timestamp := ts
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Service:    &service,
+			Status:     &status,
+			Message:    &message,
+			Timestamp:  &timestamp,
+			Attributes: attrs,
+		},
+	}
+}
@@ -0,0 +1,413 @@
+package sqlgw
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Startup request codes
+const (
+	protocolVersion3 = 196608
+	sslRequestCode   = 80877103
+	gssRequestCode   = 80877104
+	cancelCode       = 80877102
+)
+
+// textOID is the Postgres type every column is reported as
+const textOID = 25
+
+// maxMessageSize bounds a single client message
+const maxMessageSize = 1 << 20
+
+// SQLSTATE codes used in error responses
+const (
+	codeSyntaxError    = "42601"
+	codeUndefinedTable = "42P01"
+	codeNotSupported   = "0A000"
+	codeInternal       = "XX000"
+)
+
+// errLimitReached stops a fetch once a LIMIT is satisfied
+var errLimitReached = errors.New("limit reached")
+
+// Source fetches logs matching a Datadog query, handing each page to fn
+// It stops and returns fn's error if fn fails.
+type Source interface {
+	Fetch(ctx context.Context, query string, from, to time.Time, fn func(logs []datadogV2.Log) error) error
+}
+
+// Server answers SQL queries over the Postgres wire protocol
+// Only the simple query protocol is implemented, which psql uses and which
+// JDBC (preferQueryMode=simple) and ODBC drivers can be configured to use.
+type Server struct {
+	Source Source
+
+	// Table is the name queries must select from
+	Table string
+
+	// DefaultRange is how far back queries without a lower timestamp bound
+	// search
+	DefaultRange time.Duration
+
+	// Log receives one line per query, if set
+	Log io.Writer
+}
+
+// Serve accepts connections until ctx is cancelled or the listener fails
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.ServeConn(ctx, conn); err != nil && s.Log != nil {
+				fmt.Fprintf(s.Log, "%s: %v\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ServeConn runs one client session
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) error {
+	c := &session{
+		server: s,
+		r:      bufio.NewReader(conn),
+		w:      bufio.NewWriter(conn),
+	}
+	if ok, err := c.startup(); !ok || err != nil {
+		return err
+	}
+
+	for {
+		typ, body, err := c.readMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case 'Q':
+			c.query(ctx, strings.TrimRight(string(body), "\x00"))
+		case 'X':
+			return nil
+		case 'S':
+			c.readyForQuery()
+		case 'P', 'B', 'D', 'E', 'C', 'H', 'F':
+			// Extended protocol: reject once, then skip to the next Sync
+			c.error(codeNotSupported, "the extended query protocol is not supported; use simple query mode (e.g. preferQueryMode=simple for JDBC)")
+			if err := c.skipToSync(); err != nil {
+				return err
+			}
+			c.readyForQuery()
+		default:
+			c.error(codeNotSupported, fmt.Sprintf("unsupported message type %q", typ))
+			c.readyForQuery()
+		}
+
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+type session struct {
+	server *Server
+	r      *bufio.Reader
+	w      *bufio.Writer
+	buf    []byte
+}
+
+// startup negotiates the connection, declining SSL and GSS encryption, and
+// reports whether a session was established
+func (c *session) startup() (bool, error) {
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(c.r, header[:]); err != nil {
+			return false, err
+		}
+		size := binary.BigEndian.Uint32(header[:4])
+		code := binary.BigEndian.Uint32(header[4:])
+		if size < 8 || size > maxMessageSize {
+			return false, fmt.Errorf("invalid startup message length %d", size)
+		}
+		if _, err := io.CopyN(io.Discard, c.r, int64(size-8)); err != nil {
+			return false, err
+		}
+
+		switch code {
+		case sslRequestCode, gssRequestCode:
+			if err := c.w.WriteByte('N'); err != nil {
+				return false, err
+			}
+			if err := c.w.Flush(); err != nil {
+				return false, err
+			}
+		case cancelCode:
+			return false, nil
+		case protocolVersion3:
+			c.begin('R')
+			c.int32(0) // AuthenticationOk
+			c.end()
+			for _, p := range [][2]string{
+				{"server_version", "14.0"},
+				{"server_encoding", "UTF8"},
+				{"client_encoding", "UTF8"},
+				{"DateStyle", "ISO, MDY"},
+				{"integer_datetimes", "on"},
+				{"standard_conforming_strings", "on"},
+			} {
+				c.begin('S')
+				c.cstring(p[0])
+				c.cstring(p[1])
+				c.end()
+			}
+			c.readyForQuery()
+			return true, c.w.Flush()
+		default:
+			return false, fmt.Errorf("unsupported protocol version %d.%d", code>>16, code&0xffff)
+		}
+	}
+}
+
+func (c *session) readMessage() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size < 4 || size > maxMessageSize {
+		return 0, nil, fmt.Errorf("invalid message length %d", size)
+	}
+	body := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+func (c *session) skipToSync() error {
+	for {
+		typ, _, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		if typ == 'S' {
+			return nil
+		}
+	}
+}
+
+// query runs each statement of a simple query, stopping at the first error
+func (c *session) query(ctx context.Context, sql string) {
+	defer c.readyForQuery()
+
+	statements := splitStatements(sql)
+	if len(statements) == 0 {
+		c.begin('I') // EmptyQueryResponse
+		c.end()
+		return
+	}
+
+	for _, stmt := range statements {
+		if c.server.Log != nil {
+			fmt.Fprintf(c.server.Log, "query: %s\n", stmt)
+		}
+
+		// Session setup commands drivers send on connect are accepted as
+		// no-ops
+		word := strings.ToUpper(strings.Fields(stmt)[0])
+		switch word {
+		case "SET", "BEGIN", "COMMIT", "ROLLBACK", "DISCARD", "RESET":
+			c.commandComplete(word)
+			continue
+		case "SELECT":
+		default:
+			c.error(codeNotSupported, "only SELECT statements are supported")
+			return
+		}
+
+		if !c.selectLogs(ctx, stmt) {
+			return
+		}
+	}
+}
+
+// selectLogs runs a SELECT and reports whether it succeeded
+func (c *session) selectLogs(ctx context.Context, sql string) bool {
+	stmt, err := Parse(sql)
+	if err != nil {
+		c.error(codeSyntaxError, err.Error())
+		return false
+	}
+	if !strings.EqualFold(stmt.Table, c.server.Table) {
+		c.error(codeUndefinedTable, fmt.Sprintf("relation \"%s\" does not exist; query %s", stmt.Table, c.server.Table))
+		return false
+	}
+
+	plan := NewPlan(stmt)
+	from, to := plan.From, plan.To
+	if from.IsZero() {
+		from = time.Now().Add(-c.server.DefaultRange)
+		if !to.IsZero() && !from.Before(to) {
+			from = to.Add(-c.server.DefaultRange)
+		}
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	c.rowDescription(plan.Columns)
+	rows := 0
+	if plan.Limit != 0 {
+		err = c.server.Source.Fetch(ctx, plan.Query, from, to, func(logs []datadogV2.Log) error {
+			for _, log := range logs {
+				if !plan.Match(log) {
+					continue
+				}
+				c.dataRow(plan.Row(log))
+				rows++
+				if rows == plan.Limit {
+					return errLimitReached
+				}
+			}
+			// Stream each page to the client as it arrives
+			return c.w.Flush()
+		})
+	}
+	if err != nil && !errors.Is(err, errLimitReached) {
+		c.error(codeInternal, err.Error())
+		return false
+	}
+
+	c.commandComplete("SELECT " + strconv.Itoa(rows))
+	return true
+}
+
+func (c *session) rowDescription(columns []Column) {
+	c.begin('T')
+	c.int16(len(columns))
+	for _, col := range columns {
+		c.cstring(col.Name)
+		c.int32(0) // table OID
+		c.int16(0) // column number
+		c.int32(textOID)
+		c.int16(-1) // variable length
+		c.int32(-1) // type modifier
+		c.int16(0)  // text format
+	}
+	c.end()
+}
+
+func (c *session) dataRow(values []*string) {
+	c.begin('D')
+	c.int16(len(values))
+	for _, v := range values {
+		if v == nil {
+			c.int32(-1)
+			continue
+		}
+		c.int32(len(*v))
+		c.buf = append(c.buf, *v...)
+	}
+	c.end()
+}
+
+func (c *session) commandComplete(tag string) {
+	c.begin('C')
+	c.cstring(tag)
+	c.end()
+}
+
+func (c *session) error(code, message string) {
+	c.begin('E')
+	for _, f := range []struct {
+		typ   byte
+		value string
+	}{{'S', "ERROR"}, {'V', "ERROR"}, {'C', code}, {'M', message}} {
+		c.buf = append(c.buf, f.typ)
+		c.cstring(f.value)
+	}
+	c.buf = append(c.buf, 0)
+	c.end()
+}
+
+func (c *session) readyForQuery() {
+	c.begin('Z')
+	c.buf = append(c.buf, 'I')
+	c.end()
+}
+
+// begin starts a message; end fills in its length and queues it
+func (c *session) begin(typ byte) {
+	c.buf = append(c.buf[:0], typ, 0, 0, 0, 0)
+}
+
+func (c *session) end() {
+	binary.BigEndian.PutUint32(c.buf[1:5], uint32(len(c.buf)-1))
+	c.w.Write(c.buf)
+}
+
+func (c *session) int16(v int) {
+	c.buf = binary.BigEndian.AppendUint16(c.buf, uint16(v))
+}
+
+func (c *session) int32(v int) {
+	c.buf = binary.BigEndian.AppendUint32(c.buf, uint32(v))
+}
+
+func (c *session) cstring(s string) {
+	c.buf = append(c.buf, s...)
+	c.buf = append(c.buf, 0)
+}
+
+// splitStatements splits a query string on semicolons outside quotes,
+// dropping empty statements
+func splitStatements(sql string) []string {
+	var statements []string
+	var quote rune
+	start := 0
+	for i, r := range sql {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ';':
+			statements = append(statements, sql[start:i])
+			start = i + 1
+		}
+	}
+	statements = append(statements, sql[start:])
+
+	out := statements[:0]
+	for _, s := range statements {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package sqlgw
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSelect(t *testing.T) {
+	source := &fakeSource{pages: [][]datadogV2.Log{
+		{createLog("web", "error", "boom", nil), createLog("api", "error", "bad", nil)},
+		{createLog("web", "info", "ok", nil)},
+	}}
+	client := startSession(t, source)
+
+	msgs := client.query(t, "SET extra_float_digits = 3; SELECT service, message FROM logs WHERE status = 'error'")
+	assert.Equal(t, []string{"C:SET", "T:service,message", "D:web,boom", "D:api,bad", "C:SELECT 2", "Z"}, msgs)
+
+	assert.Equal(t, `status:"error"`, source.query)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), source.from, time.Minute, "default range applies")
+
+	// LIMIT stops the fetch early
+	msgs = client.query(t, "SELECT message FROM logs LIMIT 1")
+	assert.Equal(t, []string{"T:message", "D:boom", "C:SELECT 1", "Z"}, msgs)
+	assert.Equal(t, 1, source.pagesServed)
+}
+
+func TestServerErrors(t *testing.T) {
+	client := startSession(t, &fakeSource{})
+
+	assert.Equal(t, []string{"E:42P01", "Z"}, client.query(t, "SELECT * FROM events"))
+	assert.Equal(t, []string{"E:42601", "Z"}, client.query(t, "SELECT * FROM logs WHERE"))
+	assert.Equal(t, []string{"E:0A000", "Z"}, client.query(t, "DELETE FROM logs"))
+	assert.Equal(t, []string{"I", "Z"}, client.query(t, " ; "))
+
+	// Extended protocol messages are rejected until the next Sync
+	client.send(t, 'P', []byte("\x00SELECT 1\x00\x00\x00"))
+	client.send(t, 'B', []byte("\x00\x00\x00\x00\x00\x00\x00\x00"))
+	client.send(t, 'S', nil)
+	assert.Equal(t, []string{"E:0A000", "Z"}, client.readUntilReady(t))
+
+	// The session is still usable afterwards
+	assert.Equal(t, []string{"T:id,timestamp,status,service,host,message", "C:SELECT 0", "Z"}, client.query(t, "SELECT * FROM logs"))
+}
+
+func TestSplitStatements(t *testing.T) {
+	assert.Equal(t, []string{"SELECT 'a;b' FROM logs", "SET x = 1"}, splitStatements("SELECT 'a;b' FROM logs; ; SET x = 1;"))
+	assert.Empty(t, splitStatements("  ;  "))
+}
+
+// Helper functions
+
+type fakeSource struct {
+	pages       [][]datadogV2.Log
+	query       string
+	from, to    time.Time
+	pagesServed int
+}
+
+func (s *fakeSource) Fetch(ctx context.Context, query string, from, to time.Time, fn func([]datadogV2.Log) error) error {
+	s.query, s.from, s.to = query, from, to
+	s.pagesServed = 0
+	for _, page := range s.pages {
+		s.pagesServed++
+		if err := fn(page); err != nil {
+			return fmt.Errorf("failed to write page: %w", err)
+		}
+	}
+	return nil
+}
+
+// testClient speaks just enough of the Postgres protocol to run queries
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// startSession connects a client, going through an SSL request first as
+// psql does
+func startSession(t *testing.T, source Source) *testClient {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	server := &Server{Source: source, Table: "logs", DefaultRange: time.Hour}
+	go server.ServeConn(context.Background(), serverConn)
+	t.Cleanup(func() { clientConn.Close() })
+
+	c := &testClient{conn: clientConn, r: bufio.NewReader(clientConn)}
+
+	ssl := binary.BigEndian.AppendUint32([]byte{0, 0, 0, 8}, sslRequestCode)
+	_, err := clientConn.Write(ssl)
+	require.NoError(t, err)
+	b, err := c.r.ReadByte()
+	require.NoError(t, err)
+	require.Equal(t, byte('N'), b)
+
+	params := []byte("user\x00analyst\x00database\x00logs\x00\x00")
+	startup := binary.BigEndian.AppendUint32(nil, uint32(8+len(params)))
+	startup = binary.BigEndian.AppendUint32(startup, protocolVersion3)
+	_, err = clientConn.Write(append(startup, params...))
+	require.NoError(t, err)
+
+	msgs := c.readUntilReady(t)
+	require.Equal(t, "R", msgs[0])
+	require.Contains(t, msgs, "S:server_version")
+	return c
+}
+
+func (c *testClient) send(t *testing.T, typ byte, body []byte) {
+	t.Helper()
+	msg := binary.BigEndian.AppendUint32([]byte{typ}, uint32(len(body)+4))
+	_, err := c.conn.Write(append(msg, body...))
+	require.NoError(t, err)
+}
+
+func (c *testClient) query(t *testing.T, sql string) []string {
+	t.Helper()
+	c.send(t, 'Q', append([]byte(sql), 0))
+	return c.readUntilReady(t)
+}
+
+// readUntilReady summarizes messages up to ReadyForQuery, e.g. "D:web,boom"
+// for a data row or "E:42601" for an error
+func (c *testClient) readUntilReady(t *testing.T) []string {
+	t.Helper()
+	var msgs []string
+	for {
+		var header [5]byte
+		_, err := io.ReadFull(c.r, header[:])
+		require.NoError(t, err)
+		body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+		_, err = io.ReadFull(c.r, body)
+		require.NoError(t, err)
+
+		switch typ := header[0]; typ {
+		case 'Z':
+			return append(msgs, "Z")
+		case 'T':
+			n := int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+			var names []string
+			for i := 0; i < n; i++ {
+				end := strings.IndexByte(string(body), 0)
+				names = append(names, string(body[:end]))
+				body = body[end+1+18:]
+			}
+			msgs = append(msgs, "T:"+strings.Join(names, ","))
+		case 'D':
+			n := int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+			var values []string
+			for i := 0; i < n; i++ {
+				size := int32(binary.BigEndian.Uint32(body))
+				body = body[4:]
+				if size < 0 {
+					values = append(values, "NULL")
+					continue
+				}
+				values = append(values, string(body[:size]))
+				body = body[size:]
+			}
+			msgs = append(msgs, "D:"+strings.Join(values, ","))
+		case 'C', 'S':
+			msgs = append(msgs, string(typ)+":"+strings.SplitN(string(body), "\x00", 2)[0])
+		case 'E':
+			code := ""
+			for _, field := range strings.Split(string(body), "\x00") {
+				if strings.HasPrefix(field, "C") {
+					code = field[1:]
+				}
+			}
+			msgs = append(msgs, "E:"+code)
+		default:
+			msgs = append(msgs, string(typ))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jtzemp/dogfetch/internal/plan"
+)
+
+// Version is the state file format this build writes. Read migrates files
+// in older formats and refuses newer ones, so a backfill that spans an
+// upgrade can still be resumed
+const Version = 4
+
+// State records how far a fetch has got, so it can be resumed without
+// reading the cursor off stderr
+// It is the only place the full cursor is kept when --cursor-display hides
+// it elsewhere.
+type State struct {
+	Version   int          `json:"version"`
+	Query     string       `json:"query"`
+	Index     string       `json:"index"`
+	From      time.Time    `json:"from"`
+	To        *time.Time   `json:"to,omitempty"` // nil for an open-ended range
+	Format    string       `json:"format,omitempty"`
+	Output    string       `json:"output,omitempty"`    // empty for stdout
+	Window    *plan.Window `json:"window,omitempty"`    // the cursor's window when fetching in windows
+	Watermark *Watermark   `json:"watermark,omitempty"` // where the next --incremental run starts
+	Pending   *Watermark   `json:"pending,omitempty"`   // newest exported so far by an unfinished incremental run
+	Cursor    string       `json:"cursor"`              // next page, empty at the start of a window and once complete
+	Last      *Watermark   `json:"last,omitempty"`      // last log fetched under the cursor, where an expired cursor restarts
+	Logs      int          `json:"logs"`
+	Size      int64        `json:"output_size,omitempty"` // bytes of the output holding those logs, where a resumed fetch cuts it back to
+	Pages     int          `json:"pages"`
+	Complete  bool         `json:"complete"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Watermark is the newest log timestamp an incremental fetch has exported,
+// with the IDs of the logs at exactly that time, which the next run skips as
+// its range starts there
+type Watermark struct {
+	Timestamp time.Time `json:"timestamp"`
+	IDs       []string  `json:"ids"`
+}
+
+// Read loads a state file, migrating it to the current format
+func Read(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the version before decoding the rest, whose layout may differ
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if header.Version < 0 {
+		return nil, fmt.Errorf("invalid state file %s: unknown format version %d", path, header.Version)
+	}
+	if header.Version > Version {
+		return nil, fmt.Errorf("state file %s uses format version %d, but this dogfetch only reads up to version %d; resume with the release that wrote it or a newer one", path, header.Version, Version)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	s.migrate()
+	return &s, nil
+}
+
+// migrate upgrades a state read from an older format one version at a time
+func (s *State) migrate() {
+	for s.Version < Version {
+		switch s.Version {
+		case 0:
+			// Files from before the format was versioned have the same
+			// fields, less the format and output, which stay unknown
+		case 1:
+			// Version 2 added windows; older fetches covered their whole
+			// range with one cursor, which a nil window means
+		case 2:
+			// Version 3 added watermarks, which only incremental runs set
+		case 3:
+			// Version 4 added the last log fetched; without it an expired
+			// cursor can't be restarted and the resume fails as before
+		}
+		s.Version++
+	}
+}
+
+// Write saves the state atomically, so a crash mid-write leaves the previous
+// state intact; the file is private as the cursor can embed query details
+func (s *State) Write(path string) error {
+	s.Version = Version
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".dogfetch-state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s := &State{
+		Query:     "service:web",
+		Index:     "main",
+		From:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Cursor:    "abc",
+		Logs:      1000,
+		Pages:     1,
+		UpdatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, s.Write(path))
+
+	// Overwriting replaces the file rather than appending to it
+	s.Cursor = ""
+	s.Complete = true
+	require.NoError(t, s.Write(path))
+
+	got, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, s, got)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files should be left behind")
+}
+
+func TestReadErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := Read(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	bad := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(bad, []byte("{"), 0600))
+	_, err = Read(bad)
+	assert.Error(t, err)
+}
+
+func TestReadMigratesUnversionedState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	legacy := `{"query":"service:web","index":"main","from":"2024-01-01T00:00:00Z","cursor":"abc","logs":1000,"pages":1,"complete":false,"updated_at":"2024-01-02T00:00:00Z"}`
+	require.NoError(t, os.WriteFile(path, []byte(legacy), 0600))
+
+	s, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, Version, s.Version)
+	assert.Equal(t, "service:web", s.Query)
+	assert.Equal(t, "abc", s.Cursor)
+	assert.Empty(t, s.Format)
+}
+
+func TestReadRefusesNewerState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":99,"cursor":{"page":"abc"}}`), 0600))
+
+	_, err := Read(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "format version 99")
+}
+
+func TestWriteSetsVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, (&State{Query: "service:web"}).Write(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"version": 4`)
+}
+
+func TestReadMigratesUnwindowedState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	v1 := `{"version":1,"query":"service:web","index":"main","from":"2024-01-01T00:00:00Z","format":"ndjson","cursor":"abc"}`
+	require.NoError(t, os.WriteFile(path, []byte(v1), 0600))
+
+	s, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, Version, s.Version)
+	assert.Nil(t, s.Window)
+	assert.Equal(t, "abc", s.Cursor)
+}
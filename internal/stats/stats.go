@@ -0,0 +1,230 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+	"github.com/jtzemp/dogfetch/internal/topn"
+)
+
+// barWidth is the length of the longest bar in text output
+const barWidth = 40
+
+// Collector tallies logs as they stream past: how many have each status,
+// the most frequent services and hosts, and how many fall in each bucket of
+// time
+type Collector struct {
+	bucket   time.Duration
+	logs     int
+	statuses map[string]int
+	services *topn.Field
+	hosts    *topn.Field
+	timeline map[time.Time]int
+}
+
+// NewCollector creates a collector keeping the top n services and hosts and
+// a timeline in buckets of the given width
+func NewCollector(bucket time.Duration, n int) *Collector {
+	return &Collector{
+		bucket:   bucket,
+		statuses: make(map[string]int),
+		services: topn.NewField("service", n),
+		hosts:    topn.NewField("host", n),
+		timeline: make(map[time.Time]int),
+	}
+}
+
+// Observe tallies a page of logs
+func (c *Collector) Observe(logs []datadogV2.Log) {
+	c.logs += len(logs)
+	c.services.Observe(logs)
+	c.hosts.Observe(logs)
+	for _, log := range logs {
+		status, ok := logfield.LookupString(log, "status")
+		if !ok || status == "" {
+			status = "(none)"
+		}
+		c.statuses[status]++
+
+		if v, ok := logfield.Lookup(log, "timestamp"); ok {
+			c.timeline[v.(time.Time).UTC().Truncate(c.bucket)]++
+		}
+	}
+}
+
+// Count is the number of logs with a value
+type Count struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Bucket is the number of logs in a span of time starting at Start
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// Summary is what a collector has tallied
+type Summary struct {
+	Logs     int          `json:"logs"`
+	Statuses []Count      `json:"statuses"`
+	Services []topn.Entry `json:"services"`
+	Hosts    []topn.Entry `json:"hosts"`
+	Bucket   string       `json:"bucket"`
+	Timeline []Bucket     `json:"timeline"` // oldest first, including empty buckets
+}
+
+// Summary returns the tallies so far, statuses from most to least common
+func (c *Collector) Summary() Summary {
+	s := Summary{
+		Logs:     c.logs,
+		Statuses: []Count{},
+		Services: c.services.Top(),
+		Hosts:    c.hosts.Top(),
+		Bucket:   formatBucket(c.bucket),
+		Timeline: []Bucket{},
+	}
+	for status, n := range c.statuses {
+		s.Statuses = append(s.Statuses, Count{Value: status, Count: n})
+	}
+	sort.Slice(s.Statuses, func(i, j int) bool {
+		if s.Statuses[i].Count != s.Statuses[j].Count {
+			return s.Statuses[i].Count > s.Statuses[j].Count
+		}
+		return s.Statuses[i].Value < s.Statuses[j].Value
+	})
+
+	// Empty buckets stay in, so drops show up on the timeline
+	var first, last time.Time
+	for t := range c.timeline {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	if !first.IsZero() {
+		for t := first; !t.After(last); t = t.Add(c.bucket) {
+			s.Timeline = append(s.Timeline, Bucket{Start: t, Count: c.timeline[t]})
+		}
+	}
+	return s
+}
+
+// formatBucket formats a bucket width without trailing zero units, e.g. 1m
+// rather than 1m0s
+func formatBucket(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// WriteText writes the summary as histograms for reading in a terminal
+func (s Summary) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%d logs\n", s.Logs)
+
+	fmt.Fprintf(w, "\nStatus:\n")
+	most := 0
+	for _, c := range s.Statuses {
+		most = max(most, c.Count)
+	}
+	for _, c := range s.Statuses {
+		fmt.Fprintf(w, "  %-10s %8d  %s\n", c.Value, c.Count, bar(c.Count, most))
+	}
+
+	writeTop(w, "Top services", s.Services)
+	writeTop(w, "Top hosts", s.Hosts)
+
+	fmt.Fprintf(w, "\nLogs per %s:\n", s.Bucket)
+	most = 0
+	for _, b := range s.Timeline {
+		most = max(most, b.Count)
+	}
+	for _, b := range s.Timeline {
+		fmt.Fprintf(w, "  %s %8d  %s\n", b.Start.Format(time.RFC3339), b.Count, bar(b.Count, most))
+	}
+	if len(s.Timeline) == 0 {
+		fmt.Fprintf(w, "  (no timestamps)\n")
+	}
+}
+
+func writeTop(w io.Writer, title string, entries []topn.Entry) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "  (no values)\n")
+		return
+	}
+	for _, e := range entries {
+		fmt.Fprintf(w, "  %8d  %s", e.Count, e.Value)
+		if e.Error > 0 {
+			fmt.Fprintf(w, " (may be overcounted by up to %d)", e.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// bar draws n as a share of most
+func bar(n, most int) string {
+	if most == 0 {
+		return ""
+	}
+	length := n * barWidth / most
+	if length == 0 && n > 0 {
+		length = 1
+	}
+	return strings.Repeat("#", length)
+}
+
+// WriteJSON writes the summary as a JSON document
+func (s Summary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadNDJSON decodes logs written in the ndjson format and hands them to fn
+// a page at a time; fn must not keep the page, which is reused
+func ReadNDJSON(r io.Reader, fn func([]datadogV2.Log)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	page := make([]datadogV2.Log, 0, 1000)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := scanner.Bytes()
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var log datadogV2.Log
+		if err := json.Unmarshal(data, &log); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		page = append(page, log)
+		if len(page) == cap(page) {
+			fn(page)
+			page = page[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(page) > 0 {
+		fn(page)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jtzemp/dogfetch/internal/topn"
+)
+
+func testLog(status, service, host string, ts time.Time) datadogV2.Log {
+	return datadogV2.Log{Attributes: &datadogV2.LogAttributes{
+		Status:    &status,
+		Service:   &service,
+		Host:      &host,
+		Timestamp: &ts,
+	}}
+}
+
+func TestCollector(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	c := NewCollector(time.Minute, 2)
+	c.Observe([]datadogV2.Log{
+		testLog("info", "web", "a", t0.Add(10*time.Second)),
+		testLog("info", "web", "a", t0.Add(20*time.Second)),
+		testLog("error", "api", "b", t0.Add(30*time.Second)),
+	})
+	c.Observe([]datadogV2.Log{
+		testLog("info", "worker", "c", t0.Add(3*time.Minute)),
+		{Attributes: &datadogV2.LogAttributes{}},
+	})
+
+	s := c.Summary()
+	assert.Equal(t, 5, s.Logs)
+	assert.Equal(t, []Count{{Value: "info", Count: 3}, {Value: "(none)", Count: 1}, {Value: "error", Count: 1}}, s.Statuses)
+	assert.Equal(t, []topn.Entry{{Value: "web", Count: 2}, {Value: "api", Count: 1}}, s.Services[:2])
+	assert.Equal(t, "1m", s.Bucket)
+	assert.Equal(t, []Bucket{
+		{Start: t0, Count: 3},
+		{Start: t0.Add(time.Minute), Count: 0},
+		{Start: t0.Add(2 * time.Minute), Count: 0},
+		{Start: t0.Add(3 * time.Minute), Count: 1},
+	}, s.Timeline)
+}
+
+func TestWriteText(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	c := NewCollector(time.Hour, 5)
+	c.Observe([]datadogV2.Log{
+		testLog("info", "web", "a", t0),
+		testLog("info", "web", "a", t0),
+		testLog("error", "web", "a", t0.Add(time.Hour)),
+	})
+
+	var out bytes.Buffer
+	c.Summary().WriteText(&out)
+	assert.Equal(t, `3 logs
+
+Status:
+  info              2  `+strings.Repeat("#", 40)+`
+  error             1  `+strings.Repeat("#", 20)+`
+
+Top services:
+         3  web
+
+Top hosts:
+         3  a
+
+Logs per 1h:
+  2024-01-01T10:00:00Z        2  `+strings.Repeat("#", 40)+`
+  2024-01-01T11:00:00Z        1  `+strings.Repeat("#", 20)+`
+`, out.String())
+}
+
+func TestReadNDJSON(t *testing.T) {
+	input := `{"id":"a","type":"log","attributes":{"status":"info","timestamp":"2024-01-01T10:00:00Z"}}
+
+{"id":"b","type":"log","attributes":{"status":"error","timestamp":"2024-01-01T10:01:00Z"}}
+`
+	c := NewCollector(time.Minute, 5)
+	require.NoError(t, ReadNDJSON(strings.NewReader(input), c.Observe))
+	s := c.Summary()
+	assert.Equal(t, 2, s.Logs)
+	assert.Len(t, s.Timeline, 2)
+
+	err := ReadNDJSON(strings.NewReader(input+"not json\n"), c.Observe)
+	assert.ErrorContains(t, err, "line 4")
+}
+
+func TestFormatBucket(t *testing.T) {
+	assert.Equal(t, "1m", formatBucket(time.Minute))
+	assert.Equal(t, "6h", formatBucket(6*time.Hour))
+	assert.Equal(t, "1h30m", formatBucket(90*time.Minute))
+	assert.Equal(t, "30s", formatBucket(30*time.Second))
+}
@@ -0,0 +1,166 @@
+package synth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// LogsPath is the path of the Logs List (GET) endpoint
+const LogsPath = "/api/v2/logs/events"
+
+// SearchPath is the path of the Logs Search (POST) endpoint
+const SearchPath = "/api/v2/logs/events/search"
+
+// defaultPageLimit matches the Datadog API default page size
+const defaultPageLimit = 10
+
+// Handler serves a generator's corpus through a mock Logs API
+// Only the time range, pagination and sort parameters are honoured; other
+// filters are ignored. Like the API, logs come newest first unless sorted
+// by timestamp.
+func Handler(g *Generator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+LogsPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		limit := defaultPageLimit
+		if v := query.Get("page[limit]"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				writeError(w, http.StatusBadRequest, "invalid page[limit]")
+				return
+			}
+			limit = n
+		}
+
+		offset := 0
+		if v := query.Get("page[cursor]"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "invalid page[cursor]")
+				return
+			}
+			offset = n
+		}
+
+		from, err := parseTime(query.Get("filter[from]"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid filter[from]")
+			return
+		}
+		to, err := parseTime(query.Get("filter[to]"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid filter[to]")
+			return
+		}
+
+		first, last := g.Range(from, to)
+		writePage(w, g, first, last, offset, limit, query.Get("sort") == string(datadogV2.LOGSSORT_TIMESTAMP_ASCENDING))
+	})
+	mux.HandleFunc("POST "+SearchPath, func(w http.ResponseWriter, r *http.Request) {
+		var body datadogV2.LogsListRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		page := body.GetPage()
+
+		limit := defaultPageLimit
+		if page.Limit != nil {
+			if *page.Limit < 1 {
+				writeError(w, http.StatusBadRequest, "invalid page.limit")
+				return
+			}
+			limit = int(*page.Limit)
+		}
+
+		offset := 0
+		if page.Cursor != nil {
+			n, err := strconv.Atoi(*page.Cursor)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "invalid page.cursor")
+				return
+			}
+			offset = n
+		}
+
+		filter := body.GetFilter()
+		from, err := parseTime(filter.GetFrom())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid filter.from")
+			return
+		}
+		to, err := parseTime(filter.GetTo())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid filter.to")
+			return
+		}
+
+		first, last := g.Range(from, to)
+		writePage(w, g, first, last, offset, limit, body.GetSort() == datadogV2.LOGSSORT_TIMESTAMP_ASCENDING)
+	})
+	return mux
+}
+
+// writePage writes up to limit of the logs [first, last), starting offset
+// logs in from the oldest or the newest
+func writePage(w http.ResponseWriter, g *Generator, first, last, offset, limit int, ascending bool) {
+	response := datadogV2.LogsListResponse{Data: []datadogV2.Log{}}
+	for i := offset; i < last-first && len(response.Data) < limit; i++ {
+		if ascending {
+			response.Data = append(response.Data, g.Log(first+i))
+		} else {
+			response.Data = append(response.Data, g.Log(last-1-i))
+		}
+	}
+	if next := offset + limit; next < last-first {
+		after := strconv.Itoa(next)
+		response.Meta = &datadogV2.LogsResponseMetadata{
+			Page: &datadogV2.LogsResponseMetadataPage{After: &after},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseTime parses a filter's from or to, as the API client sends them:
+// RFC 3339 or milliseconds since the epoch. An empty value is the zero time
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]string{"errors": {message}})
+}
+
+// Transport serves a generator's corpus through the mock Logs API without a
+// listener, for clients that should never reach the network
+func Transport(g *Generator) http.RoundTripper {
+	return handlerTransport{handler: Handler(g)}
+}
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
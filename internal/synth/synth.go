@@ -0,0 +1,158 @@
+package synth
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+var services = []struct {
+	name  string
+	paths []string
+}{
+	{name: "web", paths: []string{"/", "/login", "/products", "/cart"}},
+	{name: "api", paths: []string{"/api/v1/orders", "/api/v1/users", "/api/v1/search"}},
+	{name: "checkout", paths: []string{"/checkout", "/checkout/confirm", "/payment"}},
+	{name: "auth", paths: []string{"/oauth/token", "/session", "/logout"}},
+	{name: "worker", paths: []string{"jobs.email", "jobs.invoice", "jobs.cleanup"}},
+}
+
+var envs = []string{"prod", "prod", "prod", "staging"}
+
+var methods = []string{"GET", "GET", "GET", "POST", "PUT", "DELETE"}
+
+var errorKinds = []string{"Timeout", "ConnectionRefused", "NullPointerException", "ValidationError"}
+
+// Generator produces realistic synthetic Datadog log records
+// Records are a pure function of the seed and their index, so the same
+// generator always yields the same corpus and records can be produced in
+// any order (e.g. to serve arbitrary pages of a mock API).
+type Generator struct {
+	seed  uint64
+	count int
+	from  time.Time
+	to    time.Time
+}
+
+// New creates a generator for count logs spread evenly over [from, to)
+func New(seed uint64, count int, from, to time.Time) *Generator {
+	return &Generator{seed: seed, count: count, from: from, to: to}
+}
+
+// Count returns the number of logs in the corpus
+func (g *Generator) Count() int {
+	return g.count
+}
+
+// Log returns the i-th log of the corpus
+func (g *Generator) Log(i int) datadogV2.Log {
+	r := rand.New(rand.NewPCG(g.seed, uint64(i)))
+	ts := g.timestamp(r, i)
+
+	svc := services[r.IntN(len(services))]
+	path := svc.paths[r.IntN(len(svc.paths))]
+	env := envs[r.IntN(len(envs))]
+	host := fmt.Sprintf("i-0%07x", r.IntN(16))
+	method := methods[r.IntN(len(methods))]
+	durationNs := int64(r.ExpFloat64() * float64(80*time.Millisecond))
+
+	status, statusCode, message := "info", 200, fmt.Sprintf("%s %s completed in %dms", method, path, durationNs/int64(time.Millisecond))
+	attributes := map[string]interface{}{}
+	switch p := r.IntN(100); {
+	case p < 2:
+		status = "debug"
+		message = fmt.Sprintf("cache lookup for %s", path)
+	case p < 10:
+		status, statusCode = "warn", 429
+		message = fmt.Sprintf("%s %s throttled, retrying", method, path)
+	case p < 18:
+		kind := errorKinds[r.IntN(len(errorKinds))]
+		status, statusCode = "error", 500
+		message = fmt.Sprintf("%s %s failed: %s", method, path, kind)
+		attributes["error"] = map[string]interface{}{
+			"kind":    kind,
+			"message": fmt.Sprintf("%s while handling request", kind),
+		}
+	}
+
+	attributes["http"] = map[string]interface{}{
+		"method":      method,
+		"status_code": statusCode,
+		"url_details": map[string]interface{}{"path": path},
+	}
+	attributes["duration"] = durationNs
+	attributes["env"] = env
+	attributes["session_id"] = fmt.Sprintf("sess-%04d", r.IntN(500))
+	attributes["usr"] = map[string]interface{}{"id": fmt.Sprintf("user-%d", r.IntN(1000))}
+	attributes["dd"] = map[string]interface{}{"trace_id": fmt.Sprintf("%d", r.Uint64())}
+
+	id := fmt.Sprintf("AQAAAY%016x%08x", g.seed, i)
+	logType := datadogV2.LOGTYPE_LOG
+	return datadogV2.Log{
+		Id:   &id,
+		Type: &logType,
+		Attributes: &datadogV2.LogAttributes{
+			Timestamp:  &ts,
+			Status:     &status,
+			Service:    &svc.name,
+			Host:       &host,
+			Message:    &message,
+			Tags:       []string{"env:" + env, "service:" + svc.name, "source:synth"},
+			Attributes: attributes,
+		},
+	}
+}
+
+// timestamp draws the i-th log's timestamp from r, the log's own source.
+// Each log falls in its own step of the range, so timestamps increase with
+// the index.
+func (g *Generator) timestamp(r *rand.Rand, i int) time.Time {
+	if g.count == 0 {
+		return g.from
+	}
+	step := g.to.Sub(g.from) / time.Duration(g.count)
+	jitter := time.Duration(0)
+	if step > 0 {
+		jitter = time.Duration(r.Int64N(int64(step)))
+	}
+	return g.from.Add(step*time.Duration(i) + jitter).UTC()
+}
+
+// Range returns the indexes [first, last) of the logs timestamped in
+// [from, to); a zero from or to leaves that end open
+func (g *Generator) Range(from, to time.Time) (int, int) {
+	before := func(t time.Time) func(int) bool {
+		return func(i int) bool {
+			return !g.timestamp(rand.New(rand.NewPCG(g.seed, uint64(i))), i).Before(t)
+		}
+	}
+	first, last := 0, g.count
+	if !from.IsZero() {
+		first = sort.Search(g.count, before(from))
+	}
+	if !to.IsZero() {
+		last = max(sort.Search(g.count, before(to)), first)
+	}
+	return first, last
+}
+
+// Page returns up to limit logs starting at offset
+func (g *Generator) Page(offset, limit int) []datadogV2.Log {
+	if offset >= g.count {
+		return []datadogV2.Log{}
+	}
+
+	end := offset + limit
+	if end > g.count {
+		end = g.count
+	}
+
+	logs := make([]datadogV2.Log, 0, end-offset)
+	for i := offset; i < end; i++ {
+		logs = append(logs, g.Log(i))
+	}
+	return logs
+}
@@ -0,0 +1,252 @@
+package synth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	a := New(42, 100, from, to)
+	b := New(42, 100, from, to)
+	for i := 0; i < 100; i++ {
+		la, err := json.Marshal(a.Log(i))
+		require.NoError(t, err)
+		lb, err := json.Marshal(b.Log(i))
+		require.NoError(t, err)
+		assert.Equal(t, string(la), string(lb))
+	}
+
+	other, err := json.Marshal(New(7, 100, from, to).Log(0))
+	require.NoError(t, err)
+	first, err := json.Marshal(a.Log(0))
+	require.NoError(t, err)
+	assert.NotEqual(t, string(first), string(other))
+}
+
+func TestGeneratorLogShape(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	g := New(1, 1000, from, to)
+
+	var prev time.Time
+	for i := 0; i < g.Count(); i++ {
+		log := g.Log(i)
+		require.NotNil(t, log.Id)
+		require.NotNil(t, log.Attributes)
+
+		attrs := log.GetAttributes()
+		assert.NotEmpty(t, attrs.GetService())
+		assert.NotEmpty(t, attrs.GetMessage())
+		assert.Contains(t, []string{"debug", "info", "warn", "error"}, attrs.GetStatus())
+
+		ts := attrs.GetTimestamp()
+		assert.False(t, ts.Before(from))
+		assert.True(t, ts.Before(to))
+		assert.False(t, ts.Before(prev), "timestamps should be ascending")
+		prev = ts
+	}
+}
+
+func TestGeneratorPage(t *testing.T) {
+	g := New(1, 25, time.Now().Add(-time.Hour), time.Now())
+
+	assert.Len(t, g.Page(0, 10), 10)
+	assert.Len(t, g.Page(20, 10), 5)
+	assert.Empty(t, g.Page(30, 10))
+}
+
+func TestGeneratorRange(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := New(1, 100, from, from.Add(100*time.Minute))
+
+	first, last := g.Range(from.Add(30*time.Minute), from.Add(40*time.Minute))
+	assert.Equal(t, 30, first)
+	assert.Equal(t, 40, last)
+	for i := first; i < last; i++ {
+		ts := g.Log(i).Attributes.GetTimestamp()
+		assert.False(t, ts.Before(from.Add(30*time.Minute)))
+		assert.True(t, ts.Before(from.Add(40*time.Minute)))
+	}
+
+	first, last = g.Range(time.Time{}, time.Time{})
+	assert.Equal(t, 0, first)
+	assert.Equal(t, 100, last)
+	first, last = g.Range(from.Add(2*time.Hour), time.Time{})
+	assert.Equal(t, first, last)
+}
+
+func TestHandlerFiltersByTime(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := New(1, 60, from, from.Add(time.Hour))
+	server := httptest.NewServer(Handler(g))
+	defer server.Close()
+
+	// Windows of the range are served their own logs, whole, once
+	seen := map[string]bool{}
+	for start := from; start.Before(from.Add(time.Hour)); start = start.Add(20 * time.Minute) {
+		resp, err := http.Get(server.URL + LogsPath + "?page[limit]=100&sort=timestamp" +
+			"&filter[from]=" + start.Format(time.RFC3339) + "&filter[to]=" + start.Add(20*time.Minute).Format(time.RFC3339))
+		require.NoError(t, err)
+		var page datadogV2.LogsListResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		resp.Body.Close()
+
+		assert.Len(t, page.Data, 20)
+		for _, log := range page.Data {
+			assert.False(t, seen[log.GetId()])
+			seen[log.GetId()] = true
+		}
+	}
+	assert.Len(t, seen, 60)
+
+	body := fmt.Sprintf(`{"filter":{"from":"%d","to":"%d"},"page":{"limit":100}}`, from.UnixMilli(), from.Add(10*time.Minute).UnixMilli())
+	resp, err := http.Post(server.URL+SearchPath, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	var page datadogV2.LogsListResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	resp.Body.Close()
+	assert.Len(t, page.Data, 10)
+
+	resp, err = http.Get(server.URL + LogsPath + "?filter[from]=yesterday")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandlerPaginates(t *testing.T) {
+	g := New(1, 25, time.Now().Add(-time.Hour), time.Now())
+	server := httptest.NewServer(Handler(g))
+	defer server.Close()
+
+	cursor := ""
+	total := 0
+	pages := 0
+	for {
+		url := server.URL + LogsPath + "?page[limit]=10"
+		if cursor != "" {
+			url += "&page[cursor]=" + cursor
+		}
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+
+		var page datadogV2.LogsListResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		resp.Body.Close()
+
+		total += len(page.Data)
+		pages++
+
+		cursor = page.GetMeta().Page.GetAfter()
+		if cursor == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, 25, total)
+	assert.Equal(t, 3, pages)
+}
+
+func TestHandlerSorts(t *testing.T) {
+	g := New(1, 25, time.Now().Add(-time.Hour), time.Now())
+	server := httptest.NewServer(Handler(g))
+	defer server.Close()
+
+	get := func(query string) []datadogV2.Log {
+		resp, err := http.Get(server.URL + LogsPath + "?page[limit]=10" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var page datadogV2.LogsListResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		return page.Data
+	}
+
+	// Newest first by default, like the API
+	newest := get("&page[cursor]=20")
+	require.Len(t, newest, 5)
+	first, last := g.Log(0), g.Log(4)
+	assert.Equal(t, last.GetId(), newest[0].GetId())
+	assert.Equal(t, first.GetId(), newest[4].GetId())
+
+	oldest := get("&sort=timestamp")
+	assert.Equal(t, first.GetId(), oldest[0].GetId())
+
+	resp, err := http.Post(server.URL+SearchPath, "application/json", strings.NewReader(`{"sort":"timestamp","page":{"limit":10}}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var page datadogV2.LogsListResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Equal(t, first.GetId(), page.Data[0].GetId())
+}
+
+func TestHandlerRejectsBadCursor(t *testing.T) {
+	server := httptest.NewServer(Handler(New(1, 5, time.Now().Add(-time.Hour), time.Now())))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + LogsPath + "?page[cursor]=nope")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandlerPaginatesSearch(t *testing.T) {
+	g := New(1, 25, time.Now().Add(-time.Hour), time.Now())
+	server := httptest.NewServer(Handler(g))
+	defer server.Close()
+
+	cursor := ""
+	total := 0
+	pages := 0
+	for {
+		body := `{"page":{"limit":10}}`
+		if cursor != "" {
+			body = `{"page":{"limit":10,"cursor":"` + cursor + `"}}`
+		}
+		resp, err := http.Post(server.URL+SearchPath, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+
+		var page datadogV2.LogsListResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		resp.Body.Close()
+
+		total += len(page.Data)
+		pages++
+
+		cursor = page.GetMeta().Page.GetAfter()
+		if cursor == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, 25, total)
+	assert.Equal(t, 3, pages)
+
+	resp, err := http.Post(server.URL+SearchPath, "application/json", strings.NewReader(`{"page":{"cursor":"nope"}}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestTransportServesWithoutListener(t *testing.T) {
+	client := &http.Client{Transport: Transport(New(1, 25, time.Now().Add(-time.Hour), time.Now()))}
+
+	resp, err := client.Get("https://api.datadoghq.com" + LogsPath + "?page[limit]=10&page[cursor]=20")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var page datadogV2.LogsListResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Len(t, page.Data, 5)
+	assert.Empty(t, page.GetMeta().Page.GetAfter())
+}
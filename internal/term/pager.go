@@ -0,0 +1,116 @@
+package term
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultHeight is the page height when the terminal's size is unknown
+const DefaultHeight = 24
+
+// Pager shows output on a terminal a screenful at a time, asking before each
+// next page so a large export can't flood it
+type Pager struct {
+	// OnQuit is called once when the user stops the output
+	OnQuit func()
+
+	out    io.Writer
+	in     *bufio.Reader
+	width  int // columns, for counting wrapped lines; 0 if unknown
+	height int
+	rows   int // rows shown on the current page
+	col    int // length of the unfinished line
+	all    bool
+	quit   bool
+}
+
+// NewPager creates a pager writing to out, a terminal of the given size, and
+// reading answers from in. A width of 0 counts every line as one row.
+func NewPager(out io.Writer, in io.Reader, width, height int) *Pager {
+	if height <= 1 {
+		height = DefaultHeight
+	}
+	return &Pager{out: out, in: bufio.NewReader(in), width: width, height: height}
+}
+
+// Terminal returns the file the pager writes to, or nil if it isn't one
+func (p *Pager) Terminal() *os.File {
+	f, _ := p.out.(*os.File)
+	return f
+}
+
+// Write shows b, pausing at every full page. Once the user has quit, output
+// is discarded rather than failing the writer it's behind.
+func (p *Pager) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		if p.quit {
+			return n, nil
+		}
+		if p.all {
+			_, err := p.out.Write(b)
+			return n, err
+		}
+
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.col += len(b)
+			_, err := p.out.Write(b)
+			return n, err
+		}
+		if _, err := p.out.Write(b[:i+1]); err != nil {
+			return n, err
+		}
+		p.rows += p.lineRows(p.col + i)
+		p.col = 0
+		b = b[i+1:]
+
+		if p.rows >= p.height-1 {
+			p.rows = 0
+			if err := p.ask(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// lineRows returns how many rows a line of length columns takes once the
+// terminal wraps it
+func (p *Pager) lineRows(length int) int {
+	if p.width <= 0 || length <= p.width {
+		return 1
+	}
+	return (length + p.width - 1) / p.width
+}
+
+// ask prompts for the next page, then clears the prompt
+func (p *Pager) ask() error {
+	if _, err := fmt.Fprint(p.out, "\x1b[7m-- More: Enter for the next page, a to show the rest, q to stop --\x1b[0m"); err != nil {
+		return err
+	}
+	answer, err := p.in.ReadString('\n')
+	if err != nil {
+		// End of input, such as Ctrl-D, stops the output
+		fmt.Fprint(p.out, "\r\x1b[2K")
+		answer = "q"
+	} else {
+		// The answer's newline moved the cursor down a line
+		fmt.Fprint(p.out, "\r\x1b[1A\x1b[2K")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "q":
+		p.quit = true
+		if p.OnQuit != nil {
+			p.OnQuit()
+		}
+	case "a":
+		p.all = true
+	}
+	return nil
+}
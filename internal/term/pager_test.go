@@ -0,0 +1,77 @@
+package term
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const prompt = "\x1b[7m-- More: Enter for the next page, a to show the rest, q to stop --\x1b[0m"
+
+func lines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		b.WriteString(strings.Repeat("x", i%10) + "\n")
+	}
+	return b.String()
+}
+
+func TestPagerPausesEveryPage(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPager(&out, strings.NewReader("\n\n"), 0, 4)
+
+	n, err := p.Write([]byte(lines(7)))
+	assert.NoError(t, err)
+	assert.Equal(t, len(lines(7)), n)
+	// Three lines fit above the prompt on a four row terminal
+	assert.Equal(t, 2, strings.Count(out.String(), prompt))
+	assert.True(t, strings.HasSuffix(out.String(), "x\n"))
+}
+
+func TestPagerCountsWrappedLines(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPager(&out, strings.NewReader("\n"), 10, 4)
+
+	// 25 columns wrap onto three rows of a 10 column terminal
+	p.Write([]byte(strings.Repeat("y", 15)))
+	p.Write([]byte(strings.Repeat("y", 10) + "\n"))
+	assert.Equal(t, 1, strings.Count(out.String(), prompt))
+}
+
+func TestPagerShowsTheRest(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPager(&out, strings.NewReader("a\n"), 0, 3)
+
+	p.Write([]byte(lines(100)))
+	assert.Equal(t, 1, strings.Count(out.String(), prompt))
+	assert.Equal(t, 100, strings.Count(out.String(), "\n"))
+}
+
+func TestPagerQuit(t *testing.T) {
+	var out bytes.Buffer
+	quits := 0
+	p := NewPager(&out, strings.NewReader("q\n"), 0, 3)
+	p.OnQuit = func() { quits++ }
+
+	n, err := p.Write([]byte(lines(10)))
+	assert.NoError(t, err)
+	assert.Equal(t, len(lines(10)), n)
+	p.Write([]byte("more\n"))
+
+	assert.Equal(t, 1, quits)
+	assert.NotContains(t, out.String(), "more")
+	assert.Equal(t, 2, strings.Count(out.String(), "x\n"))
+}
+
+func TestPagerQuitsAtEndOfInput(t *testing.T) {
+	var out bytes.Buffer
+	quits := 0
+	p := NewPager(&out, strings.NewReader(""), 0, 3)
+	p.OnQuit = func() { quits++ }
+
+	p.Write([]byte(lines(10)))
+	assert.Equal(t, 1, quits)
+	assert.Nil(t, p.Terminal())
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package term
+
+import "os"
+
+// size can't query the terminal here, so Width and Height rely on $COLUMNS
+// and $LINES
+func size(f *os.File) (cols, rows int) {
+	return 0, 0
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package term
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize is struct winsize from <sys/ioctl.h>
+type winsize struct {
+	rows, cols, xpixel, ypixel uint16
+}
+
+func size(f *os.File) (cols, rows int) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, 0
+	}
+	return int(ws.cols), int(ws.rows)
+}
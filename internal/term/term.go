@@ -0,0 +1,51 @@
+package term
+
+import (
+	"os"
+	"strconv"
+)
+
+// IsTerminal reports whether f is an interactive terminal rather than a
+// file or pipe
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Color reports whether output to f may use color: f must be a terminal
+// that understands escape codes, and neither noColor (--no-color) nor the
+// NO_COLOR environment variable (https://no-color.org) may ask for plain
+// output
+func Color(f *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return IsTerminal(f)
+}
+
+// Width returns the terminal's width in columns, falling back to $COLUMNS;
+// it returns 0 when neither is known
+func Width(f *os.File) int {
+	if w, _ := size(f); w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 0
+}
+
+// Height returns the terminal's height in rows, falling back to $LINES; it
+// returns 0 when neither is known
+func Height(f *os.File) int {
+	if _, h := size(f); h > 0 {
+		return h
+	}
+	if h, err := strconv.Atoi(os.Getenv("LINES")); err == nil && h > 0 {
+		return h
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package term
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesAreNotTerminals(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer f.Close()
+	assert.False(t, IsTerminal(f))
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	assert.False(t, IsTerminal(w))
+}
+
+func TestColor(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	assert.False(t, Color(f, false), "files are never colored")
+
+	// A terminal, if the test has one, is colored unless asked not to be
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		assert.True(t, Color(tty, false))
+		assert.False(t, Color(tty, true))
+		t.Setenv("NO_COLOR", "1")
+		assert.False(t, Color(tty, false))
+	}
+}
+
+func TestWidthFallsBackToColumns(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	t.Setenv("COLUMNS", "132")
+	assert.Equal(t, 132, Width(f))
+
+	t.Setenv("COLUMNS", "wide")
+	assert.Equal(t, 0, Width(f))
+}
@@ -0,0 +1,164 @@
+package topn
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// capacityFactor is how many more values are tracked than reported, which
+// keeps the space-saving error bound small for skewed distributions
+const capacityFactor = 10
+
+// minCapacity is the fewest values a sketch tracks
+const minCapacity = 100
+
+// Entry is a value and its estimated count
+// The true count lies between Count-Error and Count.
+type Entry struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+	Error int64  `json:"error"`
+}
+
+// Sketch keeps approximate counts of the most frequent values in a stream in
+// fixed memory, using the space-saving algorithm (Metwally et al.)
+type Sketch struct {
+	capacity int
+	entries  map[string]*item
+	heap     minHeap
+}
+
+type item struct {
+	Entry
+	index int
+}
+
+// NewSketch creates a sketch tracking at most capacity distinct values
+func NewSketch(capacity int) *Sketch {
+	return &Sketch{
+		capacity: capacity,
+		entries:  make(map[string]*item, capacity),
+	}
+}
+
+// Add counts one occurrence of value
+func (s *Sketch) Add(value string) {
+	if it, ok := s.entries[value]; ok {
+		it.Count++
+		heap.Fix(&s.heap, it.index)
+		return
+	}
+
+	if len(s.entries) < s.capacity {
+		it := &item{Entry: Entry{Value: value, Count: 1}}
+		s.entries[value] = it
+		heap.Push(&s.heap, it)
+		return
+	}
+
+	// Evict the smallest count; the newcomer inherits it as its error
+	min := s.heap[0]
+	delete(s.entries, min.Value)
+	min.Error = min.Count
+	min.Count++
+	min.Value = value
+	s.entries[value] = min
+	heap.Fix(&s.heap, 0)
+}
+
+// Top returns up to n entries with the highest counts, largest first
+func (s *Sketch) Top(n int) []Entry {
+	entries := make([]Entry, 0, len(s.entries))
+	for _, it := range s.entries {
+		entries = append(entries, it.Entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Value < entries[j].Value
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Field tracks the top values of one log field during a fetch
+type Field struct {
+	field  string
+	n      int
+	sketch *Sketch
+}
+
+// NewField creates a tracker reporting the n most frequent values of field
+func NewField(field string, n int) *Field {
+	return &Field{
+		field:  field,
+		n:      n,
+		sketch: NewSketch(max(n*capacityFactor, minCapacity)),
+	}
+}
+
+// ParseField parses a "field=N" specification, e.g. "error.kind=20"
+func ParseField(spec string) (*Field, error) {
+	i := strings.LastIndex(spec, "=")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid top-n spec '%s': expected field=N", spec)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(spec[i+1:]))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid top-n count in '%s': expected a positive integer", spec)
+	}
+	return NewField(strings.TrimSpace(spec[:i]), n), nil
+}
+
+// Name returns the tracked field
+func (f *Field) Name() string {
+	return f.field
+}
+
+// Observe counts the field's values in a page; logs without it are skipped
+func (f *Field) Observe(logs []datadogV2.Log) {
+	for _, log := range logs {
+		if v, ok := logfield.Lookup(log, f.field); ok && v != nil {
+			f.sketch.Add(fmt.Sprint(v))
+		}
+	}
+}
+
+// Top returns the most frequent values seen so far
+func (f *Field) Top() []Entry {
+	return f.sketch.Top(f.n)
+}
+
+// minHeap orders items by count, smallest first
+type minHeap []*item
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h minHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *minHeap) Push(x interface{}) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
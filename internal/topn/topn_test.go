@@ -0,0 +1,90 @@
+package topn
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketchExactUnderCapacity(t *testing.T) {
+	s := NewSketch(10)
+	for _, v := range []string{"a", "b", "a", "c", "a", "b"} {
+		s.Add(v)
+	}
+
+	assert.Equal(t, []Entry{
+		{Value: "a", Count: 3},
+		{Value: "b", Count: 2},
+	}, s.Top(2))
+}
+
+func TestSketchFindsHeavyHittersBeyondCapacity(t *testing.T) {
+	s := NewSketch(50)
+
+	// Three heavy hitters hidden among 1000 values seen once each
+	for i := 0; i < 1000; i++ {
+		s.Add(fmt.Sprintf("noise-%d", i))
+		if i%5 == 0 {
+			s.Add("Timeout")
+		}
+		if i%10 == 0 {
+			s.Add("ConnectionReset")
+		}
+		if i%20 == 0 {
+			s.Add("NullPointer")
+		}
+	}
+
+	top := s.Top(3)
+	require.Len(t, top, 3)
+	assert.Equal(t, "Timeout", top[0].Value)
+	assert.Equal(t, "ConnectionReset", top[1].Value)
+	assert.Equal(t, "NullPointer", top[2].Value)
+
+	// Counts are overestimates bounded by the error
+	assert.GreaterOrEqual(t, top[0].Count, int64(200))
+	assert.LessOrEqual(t, top[0].Count-top[0].Error, int64(200))
+}
+
+func TestFieldObserve(t *testing.T) {
+	f := NewField("error.kind", 2)
+	f.Observe([]datadogV2.Log{
+		createErrorLog("Timeout"),
+		createErrorLog("Timeout"),
+		createErrorLog("Refused"),
+		createErrorLog("Other"),
+		{Attributes: &datadogV2.LogAttributes{}},
+	})
+
+	top := f.Top()
+	require.Len(t, top, 2)
+	assert.Equal(t, Entry{Value: "Timeout", Count: 2}, top[0])
+	assert.Equal(t, "error.kind", f.Name())
+}
+
+func TestParseField(t *testing.T) {
+	f, err := ParseField("attributes.error.kind=20")
+	require.NoError(t, err)
+	assert.Equal(t, "attributes.error.kind", f.Name())
+	assert.Equal(t, 20, f.n)
+
+	for _, spec := range []string{"error.kind", "=5", "service=0", "service=ten"} {
+		_, err := ParseField(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// Helper functions
+
+func createErrorLog(kind string) datadogV2.Log {
+	return datadogV2.Log{
+		Attributes: &datadogV2.LogAttributes{
+			Attributes: map[string]interface{}{
+				"error": map[string]interface{}{"kind": kind},
+			},
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// hexID matches a 64-bit or 128-bit trace ID in hex, as OpenTelemetry and
+// W3C trace context write them
+var hexID = regexp.MustCompile(`^[0-9a-fA-F]{16}([0-9a-fA-F]{16})?$`)
+
+// traceparent matches a W3C traceparent header, whose second field is the
+// trace ID
+var traceparent = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// IDs returns the forms a trace ID takes in logs. Datadog tracers inject the
+// low 64 bits in decimal, and 128-bit aware ones the full ID in hex, so a hex
+// ID is searched for both ways. A decimal ID is taken as is, and a W3C
+// traceparent header stands for its trace ID.
+func IDs(id string) ([]string, error) {
+	id = strings.TrimSpace(id)
+	if m := traceparent.FindStringSubmatch(id); m != nil {
+		id = m[1]
+	}
+
+	if _, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return []string{id}, nil
+	}
+	if !hexID.MatchString(id) {
+		return nil, fmt.Errorf("invalid trace ID %q: expected a decimal ID, 16 or 32 hex digits, or a traceparent header", id)
+	}
+
+	id = strings.ToLower(id)
+	low, err := strconv.ParseUint(id[len(id)-16:], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace ID %q: %w", id, err)
+	}
+	return []string{id, strconv.FormatUint(low, 10)}, nil
+}
+
+// Query builds a search for the logs, or spans, of a trace, ANDed with query
+// when it isn't empty
+func Query(id, query string) (string, error) {
+	ids, err := IDs(id)
+	if err != nil {
+		return "", err
+	}
+
+	term := "trace_id:" + ids[0]
+	if len(ids) > 1 {
+		term = "trace_id:(" + strings.Join(ids, " OR ") + ")"
+	}
+	if query = strings.TrimSpace(query); query != "" && query != "*" {
+		term += " (" + query + ")"
+	}
+	return term, nil
+}
+
+// WriteSpans writes spans to path as NDJSON, one span per line as the Spans
+// API returns them
+func WriteSpans(path string, spans []datadogV2.Span) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, span := range spans {
+		if err := enc.Encode(span); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
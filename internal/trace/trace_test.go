@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDs(t *testing.T) {
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{"1234567890123456789", []string{"1234567890123456789"}},
+		{"00000000000000ff", []string{"00000000000000ff", "255"}},
+		{"4BF92F3577B34DA6A3CE929D0E0E4736", []string{"4bf92f3577b34da6a3ce929d0e0e4736", "11803532876627986230"}},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", []string{"4bf92f3577b34da6a3ce929d0e0e4736", "11803532876627986230"}},
+	}
+	for _, tt := range tests {
+		got, err := IDs(tt.id)
+		require.NoError(t, err, tt.id)
+		assert.Equal(t, tt.want, got, tt.id)
+	}
+
+	for _, id := range []string{"", "abc", "4bf92f3577b34da6a3ce929d0e0e47", "99999999999999999999999", "trace_id:1"} {
+		_, err := IDs(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	q, err := Query("1234", "")
+	require.NoError(t, err)
+	assert.Equal(t, "trace_id:1234", q)
+
+	q, err = Query("00000000000000ff", "service:web OR service:api")
+	require.NoError(t, err)
+	assert.Equal(t, "trace_id:(00000000000000ff OR 255) (service:web OR service:api)", q)
+
+	q, err = Query("1234", "*")
+	require.NoError(t, err)
+	assert.Equal(t, "trace_id:1234", q)
+
+	_, err = Query("nope", "")
+	assert.Error(t, err)
+}
+
+func TestWriteSpans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.ndjson")
+	a, b := "a", "b"
+	spans := []datadogV2.Span{{Id: &a}, {Id: &b}}
+	require.NoError(t, WriteSpans(path, spans))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span datadogV2.Span
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		ids = append(ids, span.GetId())
+	}
+	assert.Equal(t, []string{"a", "b"}, ids)
+}
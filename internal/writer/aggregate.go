@@ -0,0 +1,263 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+	"github.com/jtzemp/dogfetch/internal/spill"
+)
+
+// aggregateBucketOverhead approximates the memory cost of a bucket besides its key
+const aggregateBucketOverhead = 160
+
+// AggregateWriter exports bucketed counts instead of raw logs
+// Buckets with fewer than k logs are suppressed, and counts can optionally be
+// perturbed with Laplace noise for differential privacy. High-cardinality
+// groupings spill to disk once the counts exceed the memory budget.
+type AggregateWriter struct {
+	path      string
+	output    io.Writer
+	groupBy   []string
+	bucket    time.Duration
+	k         int
+	epsilon   float64
+	counts    map[string]*aggregateBucket
+	total     int
+	noise     func() float64
+	maxMemory int64
+	used      int64
+	spilled   *spill.Buffer
+}
+
+type aggregateBucket struct {
+	Time  time.Time         `json:"time"`
+	Group map[string]string `json:"group,omitempty"`
+	Count int               `json:"count"`
+}
+
+// NewAggregateWriter creates a new aggregate writer for a file
+func NewAggregateWriter(path string, opts Options) (*AggregateWriter, error) {
+	w := newAggregateWriter(opts)
+	w.path = path
+	return w, nil
+}
+
+// NewAggregateWriterWithOutput creates a new aggregate writer for any io.Writer
+func NewAggregateWriterWithOutput(out io.Writer, opts Options) (*AggregateWriter, error) {
+	w := newAggregateWriter(opts)
+	w.output = out
+	return w, nil
+}
+
+func newAggregateWriter(opts Options) *AggregateWriter {
+	w := &AggregateWriter{
+		groupBy:   opts.GroupBy,
+		bucket:    opts.Bucket,
+		k:         opts.KThreshold,
+		epsilon:   opts.Epsilon,
+		counts:    make(map[string]*aggregateBucket),
+		maxMemory: opts.MaxMemory,
+	}
+	if w.bucket <= 0 {
+		w.bucket = time.Hour
+	}
+	if w.k < 1 {
+		w.k = 1
+	}
+	w.noise = func() float64 {
+		return laplace(1 / w.epsilon)
+	}
+	return w
+}
+
+// WritePage counts the logs into their buckets
+func (w *AggregateWriter) WritePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		ts := time.Time{}
+		if v, ok := logfield.Lookup(log, "timestamp"); ok {
+			ts = v.(time.Time).UTC().Truncate(w.bucket)
+		}
+
+		group := make(map[string]string, len(w.groupBy))
+		parts := []string{ts.Format(time.RFC3339)}
+		for _, field := range w.groupBy {
+			value := ""
+			if v, ok := logfield.Lookup(log, field); ok && v != nil {
+				value = fmt.Sprint(v)
+			}
+			group[field] = value
+			parts = append(parts, value)
+		}
+
+		key := strings.Join(parts, "\x00")
+		b, ok := w.counts[key]
+		if !ok {
+			b = &aggregateBucket{Time: ts, Group: group}
+			w.counts[key] = b
+			w.used += int64(len(key)) + aggregateBucketOverhead
+		}
+		b.Count++
+		w.total++
+	}
+
+	if w.maxMemory > 0 && w.used > w.maxMemory {
+		return w.spillCounts()
+	}
+	return nil
+}
+
+// spillCounts moves the in-memory counts to disk; partial counts for the same
+// bucket are summed again at finalize time
+func (w *AggregateWriter) spillCounts() error {
+	if w.spilled == nil {
+		w.spilled = spill.New(0)
+	}
+
+	for key, b := range w.counts {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		if err := w.spilled.Add(key, data); err != nil {
+			return err
+		}
+	}
+
+	// A zero-budget buffer never spills by itself, so force it
+	if !w.spilled.Spilled() {
+		if err := w.spilled.Flush(); err != nil {
+			return err
+		}
+	}
+
+	w.counts = make(map[string]*aggregateBucket)
+	w.used = 0
+	return nil
+}
+
+// Finalize writes the anonymized buckets to the output
+func (w *AggregateWriter) Finalize() error {
+	var out io.Writer
+
+	if w.output != nil {
+		out = w.output
+	} else {
+		f, err := os.Create(w.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	buckets := make([]aggregateBucket, 0, len(w.counts))
+	suppressedBuckets := 0
+	suppressedLogs := 0
+	// With noise, the threshold applies to the noised count, so whether a
+	// bucket is shown reveals no more about its true count than its value
+	emit := func(b *aggregateBucket) {
+		bucket := *b
+		if w.epsilon > 0 {
+			bucket.Count = int(math.Max(0, math.Round(float64(b.Count)+w.noise())))
+		}
+		if bucket.Count < w.k {
+			suppressedBuckets++
+			suppressedLogs += b.Count
+			return
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if w.spilled == nil {
+		for _, b := range w.counts {
+			emit(b)
+		}
+	} else {
+		if err := w.spillCounts(); err != nil {
+			return err
+		}
+		err := w.spilled.Each(func(records []spill.Record) error {
+			merged := make(map[string]*aggregateBucket)
+			for _, r := range records {
+				var b aggregateBucket
+				if err := json.Unmarshal(r.Data, &b); err != nil {
+					return err
+				}
+				if m, ok := merged[r.Key]; ok {
+					m.Count += b.Count
+				} else {
+					merged[r.Key] = &b
+				}
+			}
+			for _, b := range merged {
+				emit(b)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		if !buckets[i].Time.Equal(buckets[j].Time) {
+			return buckets[i].Time.Before(buckets[j].Time)
+		}
+		for _, field := range w.groupBy {
+			if buckets[i].Group[field] != buckets[j].Group[field] {
+				return buckets[i].Group[field] < buckets[j].Group[field]
+			}
+		}
+		return false
+	})
+
+	meta := map[string]interface{}{
+		"group_by": w.groupBy,
+		"bucket":   w.bucket.String(),
+		"k":        w.k,
+	}
+	// Exact totals and suppression counts would undo the noise, so only
+	// report them without it
+	if w.epsilon > 0 {
+		meta["epsilon"] = w.epsilon
+	} else {
+		meta["total_fetched"] = w.total
+		meta["suppressed_buckets"] = suppressedBuckets
+		meta["suppressed_logs"] = suppressedLogs
+	}
+
+	output := map[string]interface{}{
+		"buckets": buckets,
+		"meta":    meta,
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+// Close removes any spill files
+func (w *AggregateWriter) Close() error {
+	if w.spilled != nil {
+		return w.spilled.Close()
+	}
+	return nil
+}
+
+// laplace samples from a zero-centred Laplace distribution with the given scale
+func laplace(scale float64) float64 {
+	u := rand.Float64() - 0.5
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
@@ -0,0 +1,72 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// atomicWriter writes another writer's output to a temporary file beside
+// path, logs.ndjson.tmp for logs.ndjson, and renames it over path when it is
+// closed after being finalized, so a consumer watching the directory only
+// ever sees a complete export. Output that failed or was abandoned stays at
+// the temporary path.
+type atomicWriter struct {
+	Writer
+	tmp, path string
+	finalized bool
+	abandoned bool
+}
+
+// Finalize finalizes the output, which is renamed into place on Close
+func (w *atomicWriter) Finalize() error {
+	if err := w.Writer.Finalize(); err != nil {
+		return err
+	}
+	w.finalized = true
+	return nil
+}
+
+// Close closes the output and, if it was finalized, moves it into place
+func (w *atomicWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	if !w.finalized || w.abandoned {
+		return nil
+	}
+	if err := os.Rename(w.tmp, w.path); err != nil {
+		return fmt.Errorf("failed to move output into place: %w", err)
+	}
+	return nil
+}
+
+// WriteRawPage passes raw records through when the output takes them
+func (w *atomicWriter) WriteRawPage(logs []datadogV2.Log, records []json.RawMessage) error {
+	raw, ok := w.Writer.(RawWriter)
+	if !ok {
+		return w.Writer.WritePage(logs)
+	}
+	return raw.WriteRawPage(logs, records)
+}
+
+// Abandon leaves the output of w at its temporary path when it is closed,
+// as for an export that was interrupted: finalized so it is readable, but
+// not complete. Outputs written in place are unaffected.
+func Abandon(w Writer) {
+	for {
+		switch v := w.(type) {
+		case *atomicWriter:
+			v.abandoned = true
+			return
+		case *SplitWriter:
+			w = v.current
+		case *TeeWriter:
+			w = v.primary
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package writer
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// encryptedWriter encrypts the output of another writer into a file as it
+// is written, so the file never holds logs in the clear
+type encryptedWriter struct {
+	Writer
+	streams []io.Closer // innermost first: gzip, if any, then the encryption
+	file    *os.File
+}
+
+// newEncryptedWriter creates a writer for format whose output is encrypted
+// into the file at path, gzipped first if name, where the file ends up,
+// ends in .gz, or .gz.age or .gz.gpg. An encrypted stream can't be
+// continued, so the file is always started afresh.
+func newEncryptedWriter(format, path, name string, opts Options) (*encryptedWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := opts.Encrypt.Encrypt(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e := &encryptedWriter{streams: []io.Closer{enc}, file: f}
+	var out io.Writer = enc
+	if Gzipped(trimEncrypted(name)) {
+		gz := gzip.NewWriter(enc)
+		e.streams = []io.Closer{gz, enc}
+		out = gz
+	}
+
+	opts.Encrypt = nil
+	w, err := NewWithOutput(format, out, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e.Writer = w
+	return e, nil
+}
+
+// Close finishes the encrypted stream and closes the file, so the output
+// can be decrypted even if the fetch stopped early
+func (w *encryptedWriter) Close() error {
+	err := w.Writer.Close()
+	for _, s := range w.streams {
+		if sErr := s.Close(); err == nil {
+			err = sErr
+		}
+	}
+	if fileErr := w.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
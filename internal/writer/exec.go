@@ -0,0 +1,149 @@
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// execRestartDelay is how long to wait before restarting a command that
+// exited, so one that fails at once doesn't spin
+var execRestartDelay = time.Second
+
+// IsExecURL reports whether an output path names a command to pipe logs
+// into, exec:<command>, rather than a file
+func IsExecURL(path string) bool {
+	return strings.HasPrefix(path, "exec:")
+}
+
+// ExecWriter streams ndjson into the stdin of a command run by the shell, a
+// page at a time. Writes block while the command isn't reading, so a slow
+// sink slows the fetch down rather than logs piling up in memory. A command
+// that exits before the fetch is done can be restarted; it's sent the page
+// it may not have finished again, so a restarted sink can see some logs
+// twice.
+type ExecWriter struct {
+	command   string
+	restarts  int // restarts left
+	onRestart func(err error)
+
+	encoder Writer       // encodes each page into page
+	page    bytes.Buffer // the page being sent
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	waited  bool
+}
+
+// NewExecWriter starts the command in rawURL, exec:<command>, with dogfetch's
+// stdout and stderr. It's restarted up to opts.ExecRestarts times.
+func NewExecWriter(rawURL string, opts Options) (*ExecWriter, error) {
+	command := strings.TrimSpace(strings.TrimPrefix(rawURL, "exec:"))
+	if command == "" {
+		return nil, fmt.Errorf("invalid exec output %q: no command", rawURL)
+	}
+	w := &ExecWriter{command: command, restarts: opts.ExecRestarts, onRestart: opts.OnExecRestart}
+	encoder, err := NewWithOutput("ndjson", &w.page, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.encoder = encoder
+	if err := w.start(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// shellCommand runs command as the platform's shell would
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("/bin/sh", "-c", command)
+}
+
+// start starts the command with a fresh stdin
+func (w *ExecWriter) start() error {
+	cmd := shellCommand(w.command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", w.command, err)
+	}
+	w.cmd, w.stdin, w.waited = cmd, stdin, false
+	return nil
+}
+
+// wait closes the command's stdin and waits for it to exit, returning why
+// it failed, if it did
+func (w *ExecWriter) wait() error {
+	if w.waited {
+		return nil
+	}
+	w.waited = true
+	w.stdin.Close()
+	if err := w.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %w", w.command, err)
+	}
+	return nil
+}
+
+// WritePage encodes a page as ndjson and sends it to the command
+func (w *ExecWriter) WritePage(logs []datadogV2.Log) error {
+	w.page.Reset()
+	if err := w.encoder.WritePage(logs); err != nil {
+		return err
+	}
+	return w.send(w.page.Bytes())
+}
+
+// send writes a page to the command. If the command has exited it's
+// restarted, while restarts are left, and sent the page again.
+func (w *ExecWriter) send(page []byte) error {
+	for {
+		_, err := w.stdin.Write(page)
+		if err == nil {
+			return nil
+		}
+		exitErr := w.wait()
+		if exitErr == nil {
+			exitErr = fmt.Errorf("%s exited", w.command)
+		}
+		if w.restarts == 0 {
+			return fmt.Errorf("%w before the fetch was done", exitErr)
+		}
+		w.restarts--
+		if w.onRestart != nil {
+			w.onRestart(exitErr)
+		}
+		time.Sleep(execRestartDelay)
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+}
+
+// Finalize ends the command's input and waits for it to finish with what
+// it was sent, failing if it does
+func (w *ExecWriter) Finalize() error {
+	if err := w.encoder.Finalize(); err != nil {
+		return err
+	}
+	return w.wait()
+}
+
+// Close ends the command's input if Finalize hasn't, letting it finish
+func (w *ExecWriter) Close() error {
+	w.wait()
+	return w.encoder.Close()
+}
@@ -0,0 +1,140 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+	"github.com/jtzemp/dogfetch/internal/firehose"
+)
+
+// firehoseMaxAttempts is how many times records are put before giving up
+const firehoseMaxAttempts = 5
+
+// IsFirehoseURL reports whether an output path names a Firehose delivery
+// stream, firehose://<stream>, rather than a file
+func IsFirehoseURL(path string) bool {
+	return strings.HasPrefix(path, "firehose://")
+}
+
+// FirehoseWriter puts logs to an Amazon Data Firehose delivery stream, one
+// ndjson line per record, batched up to PutRecordBatch's limits. Like a
+// webhook's, a batch never spans pages, so once a page is written all of it
+// has been accepted.
+type FirehoseWriter struct {
+	client   *firehose.Client
+	envelope *envelope.Wrapper
+	backoff  time.Duration
+}
+
+// NewFirehoseWriter creates a writer for rawURL, firehose://<stream>, with
+// an optional ?region= (AWS_REGION or AWS_DEFAULT_REGION otherwise).
+// Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, and AWS_ENDPOINT_URL_FIREHOSE or AWS_ENDPOINT_URL
+// replace the region's endpoint, e.g. for LocalStack.
+func NewFirehoseWriter(rawURL string, opts Options) (*FirehoseWriter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid firehose output %q: %w", rawURL, err)
+	}
+	stream := u.Host + strings.TrimSuffix(u.Path, "/")
+	if stream == "" {
+		return nil, fmt.Errorf("invalid firehose output %q: no delivery stream", rawURL)
+	}
+	region := u.Query().Get("region")
+	if region == "" {
+		region = firehose.RegionFromEnv()
+	}
+	if region == "" {
+		return nil, fmt.Errorf("firehose output requires a region: add ?region= or set AWS_REGION")
+	}
+	creds, err := firehose.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_FIREHOSE")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+
+	return &FirehoseWriter{
+		client:   firehose.NewClient(stream, region, endpoint, creds),
+		envelope: opts.Envelope,
+		backoff:  time.Second,
+	}, nil
+}
+
+// WritePage puts the page in as few batches as the limits allow
+func (w *FirehoseWriter) WritePage(logs []datadogV2.Log) error {
+	var batch [][]byte
+	var size int
+	for _, log := range logs {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(document(w.envelope, log)); err != nil {
+			return err
+		}
+		data := buf.Bytes()
+		if len(data) > firehose.MaxRecordBytes {
+			return fmt.Errorf("log %s is %d bytes, over Firehose's %d byte record limit", log.GetId(), len(data), firehose.MaxRecordBytes)
+		}
+		if len(batch) == firehose.MaxBatchRecords || size+len(data) > firehose.MaxBatchBytes {
+			if err := w.put(batch); err != nil {
+				return err
+			}
+			batch, size = nil, 0
+		}
+		batch = append(batch, data)
+		size += len(data)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return w.put(batch)
+}
+
+// put sends a batch, sending again just the records Firehose failed to
+// accept, or all of them if it was throttling or unavailable or the request
+// failed on the network
+func (w *FirehoseWriter) put(records [][]byte) error {
+	var err error
+	for attempt := 0; attempt < firehoseMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(w.backoff << (attempt - 1))
+		}
+		var failed []int
+		var code string
+		failed, code, err = w.client.PutRecordBatch(records)
+		if err != nil {
+			if firehose.Retryable(err) {
+				continue
+			}
+			return err
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+		retry := make([][]byte, len(failed))
+		for i, index := range failed {
+			retry[i] = records[index]
+		}
+		records = retry
+		err = fmt.Errorf("firehose failed to accept %d records: %s", len(records), code)
+	}
+	return err
+}
+
+// Finalize is a no-op for FirehoseWriter (already sent)
+func (w *FirehoseWriter) Finalize() error {
+	return nil
+}
+
+// Close is a no-op for FirehoseWriter
+func (w *FirehoseWriter) Close() error {
+	return nil
+}
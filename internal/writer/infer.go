@@ -0,0 +1,109 @@
+package writer
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultFormat is written when no format is given or implied by the path
+const DefaultFormat = "ndjson"
+
+// extensionFormats maps output file extensions to the format they imply
+var extensionFormats = map[string]string{
+	".json":    "json",
+	".ndjson":  "ndjson",
+	".jsonl":   "ndjson",
+	".msgpack": "msgpack",
+	".cef":     "cef",
+	".leef":    "leef",
+	".txt":     "text",
+	".log":     "text",
+	".sarif":   "sarif",
+	".xlsx":    "xlsx",
+}
+
+// unwritableExtensions are common export extensions no format writes, which
+// are refused rather than silently filled with another format
+var unwritableExtensions = []string{".csv", ".tsv", ".parquet"}
+
+// encryptedExtensions mark files encrypted with age or GPG
+var encryptedExtensions = []string{".age", ".gpg"}
+
+// InferFormat returns the format the extension of path implies, looking past
+// a trailing .gz and .age or .gpg, so logs.ndjson.gz.age is ndjson. It
+// returns "" when the extension implies none.
+func InferFormat(path string) (string, error) {
+	if path == "" || IsSyslogURL(path) || IsSocketURL(path) || IsExecURL(path) || IsWebhookURL(path) || IsFirehoseURL(path) {
+		return "", nil
+	}
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(trimEncrypted(strings.ToLower(path)), ".gz")))
+	for _, unwritable := range unwritableExtensions {
+		if ext == unwritable {
+			return "", fmt.Errorf("no supported format writes %s files", ext)
+		}
+	}
+	return extensionFormats[ext], nil
+}
+
+// Gzipped reports whether output to path is compressed, as a .gz extension
+// asks for
+func Gzipped(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".gz") && !IsSyslogURL(path) && !IsSocketURL(path) && !IsExecURL(path) && !IsWebhookURL(path) && !IsFirehoseURL(path)
+}
+
+// trimEncrypted removes a trailing .age or .gpg from path
+func trimEncrypted(path string) string {
+	for _, ext := range encryptedExtensions {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return path[:len(path)-len(ext)]
+		}
+	}
+	return path
+}
+
+// gzipWriter compresses the output of another writer into a file
+type gzipWriter struct {
+	Writer
+	gz   *gzip.Writer
+	file *os.File
+}
+
+// newGzipWriter creates a writer for format whose output is gzipped into the
+// file at path. Appending adds a gzip member, which gunzip and zcat read as
+// one stream with what came before.
+func newGzipWriter(format, path string, append bool, opts Options) (*gzipWriter, error) {
+	flags := os.O_CREATE | os.O_WRONLY
+	if append {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	w, err := NewWithOutput(format, gz, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipWriter{Writer: w, gz: gz, file: f}, nil
+}
+
+// Close ends the gzip stream and closes the file, so the output is readable
+// even if the fetch stopped early
+func (w *gzipWriter) Close() error {
+	err := w.Writer.Close()
+	if gzErr := w.gz.Close(); err == nil {
+		err = gzErr
+	}
+	if fileErr := w.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
@@ -1,27 +1,39 @@
 package writer
 
 import (
+	"bufio"
 	"encoding/json"
 	"io"
 	"os"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/envelope"
 )
 
-// JSONWriter buffers all logs in memory and writes a single JSON file
+// JSONWriter streams logs into a single JSON document
+// The "logs" array is written element by element as pages arrive and the
+// "meta" object is appended by Finalize, so memory use does not grow with the
+// size of the export.
 type JSONWriter struct {
-	path        string
-	output      io.Writer
-	logs        []datadogV2.Log
+	out         *bufio.Writer
+	closer      io.Closer
+	count       int
 	pageCount   int
+	started     bool
 	shouldClose bool
+	envelope    *envelope.Wrapper
 }
 
 // NewJSONWriter creates a new JSON writer for a file
 func NewJSONWriter(path string) (*JSONWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
 	return &JSONWriter{
-		path:        path,
-		logs:        make([]datadogV2.Log, 0),
+		out:         bufio.NewWriter(f),
+		closer:      f,
 		shouldClose: true,
 	}, nil
 }
@@ -29,50 +41,92 @@ func NewJSONWriter(path string) (*JSONWriter, error) {
 // NewJSONWriterWithOutput creates a new JSON writer for any io.Writer
 func NewJSONWriterWithOutput(w io.Writer) (*JSONWriter, error) {
 	return &JSONWriter{
-		output:      w,
-		logs:        make([]datadogV2.Log, 0),
+		out:         bufio.NewWriter(w),
 		shouldClose: false,
 	}, nil
 }
 
-// WritePage buffers the logs in memory
+// WritePage appends the logs to the "logs" array
 func (w *JSONWriter) WritePage(logs []datadogV2.Log) error {
-	w.logs = append(w.logs, logs...)
-	w.pageCount++
-	return nil
-}
+	if err := w.start(); err != nil {
+		return err
+	}
 
-// Finalize writes all buffered logs to the output
-func (w *JSONWriter) Finalize() error {
-	var out io.Writer
-
-	if w.output != nil {
-		// Writing to provided writer (e.g., stdout)
-		out = w.output
-	} else {
-		// Writing to file
-		f, err := os.Create(w.path)
+	for _, log := range logs {
+		data, err := json.MarshalIndent(document(w.envelope, log), "    ", "  ")
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-		out = f
+
+		sep := ",\n    "
+		if w.count == 0 {
+			sep = "\n    "
+		}
+		if _, err := w.out.WriteString(sep); err != nil {
+			return err
+		}
+		if _, err := w.out.Write(data); err != nil {
+			return err
+		}
+		w.count++
 	}
 
-	output := map[string]interface{}{
-		"logs": w.logs,
-		"meta": map[string]interface{}{
-			"total_fetched": len(w.logs),
-			"pages":         w.pageCount,
-		},
+	w.pageCount++
+	return w.out.Flush()
+}
+
+func (w *JSONWriter) setEnvelope(e *envelope.Wrapper) {
+	w.envelope = e
+}
+
+// Finalize closes the "logs" array and writes the "meta" object
+func (w *JSONWriter) Finalize() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	closing := "\n  ],\n"
+	if w.count == 0 {
+		closing = "],\n"
+	}
+	if _, err := w.out.WriteString(closing); err != nil {
+		return err
+	}
+
+	meta, err := json.MarshalIndent(map[string]interface{}{
+		"total_fetched": w.count,
+		"pages":         w.pageCount,
+	}, "  ", "  ")
+	if err != nil {
+		return err
 	}
 
-	encoder := json.NewEncoder(out)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(output)
+	if _, err := w.out.WriteString(`  "meta": `); err != nil {
+		return err
+	}
+	if _, err := w.out.Write(meta); err != nil {
+		return err
+	}
+	if _, err := w.out.WriteString("\n}\n"); err != nil {
+		return err
+	}
+	return w.out.Flush()
 }
 
-// Close is a no-op for JSONWriter
+// Close closes the output file (if it's a file)
 func (w *JSONWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
 	return nil
 }
+
+// start writes the document header once
+func (w *JSONWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := w.out.WriteString("{\n  \"logs\": [")
+	return err
+}
@@ -0,0 +1,78 @@
+package writer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+	"github.com/jtzemp/dogfetch/internal/msgpack"
+)
+
+// MsgpackWriter streams logs as a sequence of MessagePack maps, one per log
+// Each log has the same structure as its NDJSON line, so the format can be
+// decoded by reading values until EOF.
+type MsgpackWriter struct {
+	closer      io.Closer
+	encoder     *msgpack.Encoder
+	shouldClose bool
+	envelope    *envelope.Wrapper
+}
+
+// NewMsgpackWriter creates a new MessagePack writer for a file
+func NewMsgpackWriter(path string, append bool) (*MsgpackWriter, error) {
+	f, err := openPageFile(path, append)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MsgpackWriter{
+		closer:      f,
+		encoder:     msgpack.NewEncoder(f),
+		shouldClose: true,
+	}, nil
+}
+
+// NewMsgpackWriterWithOutput creates a new MessagePack writer for any io.Writer
+func NewMsgpackWriterWithOutput(w io.Writer) (*MsgpackWriter, error) {
+	return &MsgpackWriter{
+		encoder:     msgpack.NewEncoder(w),
+		shouldClose: false,
+	}, nil
+}
+
+// WritePage writes the logs and flushes them at the end of the page
+func (w *MsgpackWriter) WritePage(logs []datadogV2.Log) error {
+	return endPage(w.closer, w.writePage(logs))
+}
+
+// writePage encodes a page to the output
+func (w *MsgpackWriter) writePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		data, err := json.Marshal(document(w.envelope, log))
+		if err != nil {
+			return err
+		}
+		if err := w.encoder.EncodeJSON(data); err != nil {
+			return err
+		}
+	}
+	return w.encoder.Flush()
+}
+
+func (w *MsgpackWriter) setEnvelope(e *envelope.Wrapper) {
+	w.envelope = e
+}
+
+// Finalize is a no-op for MsgpackWriter (already written)
+func (w *MsgpackWriter) Finalize() error {
+	return nil
+}
+
+// Close closes the output file (if it's a file)
+func (w *MsgpackWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
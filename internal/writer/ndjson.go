@@ -1,31 +1,36 @@
 package writer
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+	"github.com/jtzemp/dogfetch/internal/sidecar"
 )
 
 // NDJSONWriter streams logs to a newline-delimited JSON file
 type NDJSONWriter struct {
-	writer     io.Writer
-	closer     io.Closer
-	encoder    *json.Encoder
+	writer      io.Writer
+	closer      io.Closer
+	encoder     *json.Encoder
 	shouldClose bool
+
+	// Sidecar index, when enabled
+	counter   *countingWriter
+	index     *sidecar.Builder
+	indexPath string
+
+	envelope *envelope.Wrapper
 }
 
 // NewNDJSONWriter creates a new NDJSON writer for a file
 func NewNDJSONWriter(path string, append bool) (*NDJSONWriter, error) {
-	flags := os.O_CREATE | os.O_WRONLY
-	if append {
-		flags |= os.O_APPEND
-	} else {
-		flags |= os.O_TRUNC
-	}
-
-	f, err := os.OpenFile(path, flags, 0644)
+	f, err := openPageFile(path, append)
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +43,51 @@ func NewNDJSONWriter(path string, append bool) (*NDJSONWriter, error) {
 	}, nil
 }
 
+// NewIndexedNDJSONWriter creates a new NDJSON writer for a file that also
+// maintains a sidecar index at sidecar.Path(path)
+// When appending, an index that matches the existing file is extended;
+// otherwise the existing file is indexed first.
+func NewIndexedNDJSONWriter(path string, append bool) (*NDJSONWriter, error) {
+	indexPath := sidecar.Path(path)
+	index := sidecar.NewBuilder(sidecar.DefaultBucket)
+	if append {
+		var err error
+		if index, err = resumeIndex(path, indexPath); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := NewNDJSONWriter(path, append)
+	if err != nil {
+		return nil, err
+	}
+	w.counter = &countingWriter{w: w.writer, n: index.Size()}
+	w.encoder = json.NewEncoder(w.counter)
+	w.index = index
+	w.indexPath = indexPath
+	return w, nil
+}
+
+// resumeIndex loads the index of a file about to be appended to, rebuilding
+// it if it is missing or out of date
+func resumeIndex(path, indexPath string) (*sidecar.Builder, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sidecar.NewBuilder(sidecar.DefaultBucket), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := sidecar.Read(indexPath)
+	if err != nil || !idx.Fresh(info.Size()) {
+		if idx, err = sidecar.Build(path, sidecar.DefaultBucket); err != nil {
+			return nil, fmt.Errorf("failed to index %s: %w", path, err)
+		}
+	}
+	return sidecar.Resume(idx)
+}
+
 // NewNDJSONWriterWithOutput creates a new NDJSON writer for any io.Writer
 func NewNDJSONWriterWithOutput(w io.Writer) (*NDJSONWriter, error) {
 	return &NDJSONWriter{
@@ -47,19 +97,72 @@ func NewNDJSONWriterWithOutput(w io.Writer) (*NDJSONWriter, error) {
 	}, nil
 }
 
-// WritePage writes logs immediately to the file (one per line)
+// WritePage writes logs one per line, a file getting the whole page at once
 func (w *NDJSONWriter) WritePage(logs []datadogV2.Log) error {
-	for _, log := range logs {
-		if err := w.encoder.Encode(log); err != nil {
+	return endPage(w.closer, w.writePage(logs))
+}
+
+// WriteRawPage writes the records of a page one per line, compacted but
+// otherwise as the API sent them
+func (w *NDJSONWriter) WriteRawPage(logs []datadogV2.Log, records []json.RawMessage) error {
+	return endPage(w.closer, w.writeRawPage(logs, records))
+}
+
+// writePage encodes a page to the output
+func (w *NDJSONWriter) writePage(logs []datadogV2.Log) error {
+	return w.writeRawPage(logs, nil)
+}
+
+// writeRawPage writes each log's record, or encodes the log when it has none
+func (w *NDJSONWriter) writeRawPage(logs []datadogV2.Log, records []json.RawMessage) error {
+	var line bytes.Buffer
+	for i, log := range logs {
+		start := w.offset()
+		if i < len(records) && records[i] != nil {
+			line.Reset()
+			if err := json.Compact(&line, records[i]); err != nil {
+				return err
+			}
+			line.WriteByte('\n')
+			if _, err := w.out().Write(line.Bytes()); err != nil {
+				return err
+			}
+		} else if err := w.encoder.Encode(document(w.envelope, log)); err != nil {
 			return err
 		}
+		if w.index != nil {
+			attrs := log.GetAttributes()
+			w.index.Add(w.offset()-start, attrs.GetTimestamp(), log.GetId())
+		}
 	}
 	return nil
 }
 
-// Finalize is a no-op for NDJSONWriter (already written)
+func (w *NDJSONWriter) setEnvelope(e *envelope.Wrapper) {
+	w.envelope = e
+}
+
+// out is where records go: through the byte counter when indexing
+func (w *NDJSONWriter) out() io.Writer {
+	if w.counter != nil {
+		return w.counter
+	}
+	return w.writer
+}
+
+func (w *NDJSONWriter) offset() int64 {
+	if w.counter == nil {
+		return 0
+	}
+	return w.counter.n
+}
+
+// Finalize saves the sidecar index, if enabled; logs are already written
 func (w *NDJSONWriter) Finalize() error {
-	return nil
+	if w.index == nil {
+		return nil
+	}
+	return w.index.Index().Write(w.indexPath)
 }
 
 // Close closes the output file (if it's a file)
@@ -69,3 +172,15 @@ func (w *NDJSONWriter) Close() error {
 	}
 	return nil
 }
+
+// countingWriter passes writes through and counts the bytes written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,180 @@
+package writer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/otlp"
+	"github.com/jtzemp/dogfetch/internal/version"
+)
+
+// otlpMaxAttempts is how many times an export is tried before giving up
+const otlpMaxAttempts = 4
+
+// otlpScope identifies dogfetch as the producer of converted records
+var otlpScope = otlp.InstrumentationScope{Name: "dogfetch", Version: version.Version}
+
+// OTLPWriter converts logs to OpenTelemetry and writes one OTLP/JSON export
+// request per page, one per line, the layout the collector's otlpjsonfile
+// receiver reads
+type OTLPWriter struct {
+	out         *bufio.Writer
+	closer      io.Closer
+	shouldClose bool
+}
+
+// NewOTLPWriter creates a new OTLP/JSON writer for a file
+func NewOTLPWriter(path string, append bool) (*OTLPWriter, error) {
+	f, err := openPageFile(path, append)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OTLPWriter{
+		out:         bufio.NewWriter(f),
+		closer:      f,
+		shouldClose: true,
+	}, nil
+}
+
+// NewOTLPWriterWithOutput creates a new OTLP/JSON writer for any io.Writer
+func NewOTLPWriterWithOutput(w io.Writer) (*OTLPWriter, error) {
+	return &OTLPWriter{
+		out:         bufio.NewWriter(w),
+		shouldClose: false,
+	}, nil
+}
+
+// WritePage writes the page as a single export request line
+func (w *OTLPWriter) WritePage(logs []datadogV2.Log) error {
+	return endPage(w.closer, w.writePage(logs))
+}
+
+// writePage encodes a page to the output
+func (w *OTLPWriter) writePage(logs []datadogV2.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(otlp.FromDatadog(logs, otlpScope, time.Now()))
+	if err != nil {
+		return err
+	}
+	if _, err := w.out.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// Finalize is a no-op for OTLPWriter (already written)
+func (w *OTLPWriter) Finalize() error {
+	return nil
+}
+
+// Close closes the output file (if it's a file)
+func (w *OTLPWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+// OTLPHTTPWriter sends each page to an OTLP/HTTP endpoint as a protobuf
+// export request
+type OTLPHTTPWriter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	backoff time.Duration
+}
+
+// NewOTLPHTTPWriter creates a writer that exports to endpoint, e.g.
+// http://collector:4318; "/v1/logs" is added unless the URL already has a path
+func NewOTLPHTTPWriter(endpoint string, headers map[string]string) (*OTLPHTTPWriter, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("OTLP endpoint must be an http:// or https:// URL, got '%s'", endpoint)
+	}
+
+	url := strings.TrimSuffix(endpoint, "/")
+	if rest := url[strings.Index(url, "//")+2:]; !strings.Contains(rest, "/") {
+		url += "/v1/logs"
+	}
+
+	return &OTLPHTTPWriter{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		backoff: time.Second,
+	}, nil
+}
+
+// WritePage exports the page, retrying when the collector is throttling or
+// temporarily unavailable
+func (w *OTLPHTTPWriter) WritePage(logs []datadogV2.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	body := otlp.FromDatadog(logs, otlpScope, time.Now()).MarshalProto()
+
+	var err error
+	for attempt := 0; attempt < otlpMaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(w.backoff << (attempt - 1))
+		}
+
+		var retry bool
+		retry, err = w.send(body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// send posts one request and reports whether a failure is worth retrying
+func (w *OTLPHTTPWriter) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("OTLP export failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return true, fmt.Errorf("OTLP export failed: %s", resp.Status)
+	default:
+		return false, fmt.Errorf("OTLP export rejected: %s", resp.Status)
+	}
+}
+
+// Finalize is a no-op for OTLPHTTPWriter (already sent)
+func (w *OTLPHTTPWriter) Finalize() error {
+	return nil
+}
+
+// Close is a no-op for OTLPHTTPWriter
+func (w *OTLPHTTPWriter) Close() error {
+	return nil
+}
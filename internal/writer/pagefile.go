@@ -0,0 +1,112 @@
+package writer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// reopens counts the calls to Reopen; a page file whose count is behind
+// reopens its path before committing its next page
+var reopens atomic.Int64
+
+// Reopen asks every open page file to close and reopen its path before its
+// next page, so an output moved away by logrotate is recreated rather than
+// written to under its old name. It is safe to call from a signal handler.
+func Reopen() {
+	reopens.Add(1)
+}
+
+// pageFile is an output file that takes a page at a time: writes are staged
+// in memory and reach the file in one write when the page is committed. If
+// that write fails part way, the file is cut back to where the page started,
+// so a page fetched again on resume is never left in the file twice.
+type pageFile struct {
+	path     string
+	file     *os.File
+	staged   bytes.Buffer
+	reopened int64 // the Reopen count the file was opened at
+}
+
+// openPageFile opens path for writing pages, truncating it unless append
+func openPageFile(path string, append bool) (*pageFile, error) {
+	flags := os.O_CREATE | os.O_WRONLY
+	if append {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &pageFile{path: path, file: f, reopened: reopens.Load()}, nil
+}
+
+// Write stages p as part of the current page
+func (p *pageFile) Write(b []byte) (int, error) {
+	return p.staged.Write(b)
+}
+
+// commit writes the staged page to the file. Files that can't be cut back,
+// such as pipes, are written as they are.
+func (p *pageFile) commit() error {
+	defer p.staged.Reset()
+	if p.staged.Len() == 0 {
+		return nil
+	}
+	if err := p.reopen(); err != nil {
+		return err
+	}
+
+	start, seekErr := p.file.Seek(0, io.SeekEnd)
+	n, err := p.file.Write(p.staged.Bytes())
+	if err != nil && n > 0 && seekErr == nil {
+		if p.file.Truncate(start) == nil {
+			p.file.Seek(start, io.SeekStart)
+		}
+	}
+	return err
+}
+
+// reopen swaps the file for a fresh one at its path, appending, if Reopen
+// was called since it was opened
+func (p *pageFile) reopen() error {
+	n := reopens.Load()
+	if n == p.reopened {
+		return nil
+	}
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	p.file.Close()
+	p.file, p.reopened = f, n
+	return nil
+}
+
+// discard drops a page that failed before it was committed
+func (p *pageFile) discard() {
+	p.staged.Reset()
+}
+
+// Close closes the file; a page that wasn't committed is dropped
+func (p *pageFile) Close() error {
+	return p.file.Close()
+}
+
+// endPage commits the page a writer staged in out, or drops it if writing
+// the page failed. Outputs that aren't page files took it as it was written.
+func endPage(out io.Closer, err error) error {
+	p, ok := out.(*pageFile)
+	if !ok {
+		return err
+	}
+	if err != nil {
+		p.discard()
+		return err
+	}
+	return p.commit()
+}
@@ -0,0 +1,206 @@
+package writer
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/term"
+)
+
+const (
+	// defaultPrettyWidth is used when the terminal's width is unknown
+	defaultPrettyWidth = 120
+
+	// maxServiceWidth caps the service column, which otherwise grows to fit
+	// the longest service seen
+	maxServiceWidth = 20
+)
+
+// ANSI colors for status levels
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiGreen  = "\x1b[32m"
+	ansiBlue   = "\x1b[34m"
+	ansiGray   = "\x1b[90m"
+	ansiBold   = "\x1b[1m"
+)
+
+// PrettyWriter streams logs to a terminal as aligned, color-coded lines,
+// each truncated to the terminal's width
+type PrettyWriter struct {
+	out          *bufio.Writer
+	closer       io.Closer
+	width        int
+	serviceWidth int
+	color        bool
+	shouldClose  bool
+}
+
+// NewPrettyWriter creates a pretty writer for a file, which is plain text
+// unless the file is a terminal such as /dev/tty
+func NewPrettyWriter(path string, append bool, opts Options) (Writer, error) {
+	f, err := openPageFile(path, append)
+	if err != nil {
+		return nil, err
+	}
+	if !term.IsTerminal(f.file) {
+		return &TextWriter{out: bufio.NewWriter(f), closer: f, fields: textFields(opts), shouldClose: true}, nil
+	}
+	// A terminal shows each line as it comes, so there's nothing to stage
+	return &PrettyWriter{out: bufio.NewWriter(f.file), closer: f.file, width: prettyWidth(f.file), color: term.Color(f.file, opts.NoColor), shouldClose: true}, nil
+}
+
+// NewPrettyWriterWithOutput creates a pretty writer for any io.Writer,
+// falling back to plain text when it isn't a terminal, e.g. when piped
+func NewPrettyWriterWithOutput(w io.Writer, opts Options) (Writer, error) {
+	f, ok := w.(*os.File)
+	if pager, isPager := w.(*term.Pager); isPager {
+		f = pager.Terminal()
+		ok = f != nil
+	}
+	if !ok || !term.IsTerminal(f) {
+		return NewTextWriterWithOutput(w, opts)
+	}
+	return &PrettyWriter{out: bufio.NewWriter(w), width: prettyWidth(f), color: term.Color(f, opts.NoColor), shouldClose: false}, nil
+}
+
+func prettyWidth(f *os.File) int {
+	if w := term.Width(f); w > 0 {
+		return w
+	}
+	return defaultPrettyWidth
+}
+
+// WritePage writes one line per log and flushes at the end of the page
+// The service column is widened for the whole page before it is written, so
+// a page's lines always line up.
+func (w *PrettyWriter) WritePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		attrs := log.GetAttributes()
+		service := truncate(attrs.GetService(), maxServiceWidth)
+		if n := utf8.RuneCountInString(service); n > w.serviceWidth {
+			w.serviceWidth = n
+		}
+	}
+	for _, log := range logs {
+		if err := w.writeLog(log); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// writeLog writes "timestamp STATUS service message", padding the status and
+// service so messages line up
+func (w *PrettyWriter) writeLog(log datadogV2.Log) error {
+	attrs := log.GetAttributes()
+
+	timestamp := "-"
+	if ts, ok := attrs.GetTimestampOk(); ok {
+		timestamp = ts.Local().Format("2006-01-02 15:04:05.000")
+	}
+
+	status := strings.ToUpper(attrs.GetStatus())
+	if status == "" {
+		status = "-"
+	}
+	color := statusColor(status)
+	if short, ok := statusAbbreviations[status]; ok {
+		status = short
+	}
+	status = truncate(status, 5)
+
+	service := attrs.GetService()
+	if service == "" {
+		service = "-"
+	}
+	service = truncate(service, maxServiceWidth)
+	if w.serviceWidth == 0 {
+		w.serviceWidth = 1 // "-" for logs without a service
+	}
+
+	// Columns are separated by two spaces; the message gets what is left
+	used := len(timestamp) + 2 + 5 + 2 + w.serviceWidth + 2
+	message := textEscaper.Replace(attrs.GetMessage())
+	message = truncate(message, max(w.width-used, 10))
+
+	w.out.WriteString(w.paint(ansiGray, timestamp) + "  ")
+	w.out.WriteString(w.paint(color, pad(status, 5)) + "  ")
+	w.out.WriteString(w.paint(ansiBold, pad(service, w.serviceWidth)) + "  ")
+	if color == ansiGray {
+		message = w.paint(ansiGray, message)
+	}
+	w.out.WriteString(message)
+	return w.out.WriteByte('\n')
+}
+
+// paint wraps s in an ANSI color, unless color is off
+func (w *PrettyWriter) paint(color, s string) string {
+	if !w.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// statusAbbreviations fit long status levels in the five-character column
+var statusAbbreviations = map[string]string{
+	"WARNING":   "WARN",
+	"CRITICAL":  "CRIT",
+	"EMERGENCY": "EMERG",
+	"NOTICE":    "NOTE",
+}
+
+// statusColor picks the color for a status level, matching Datadog's
+// severity names and their common abbreviations
+func statusColor(status string) string {
+	switch {
+	case strings.HasPrefix(status, "EMERG"), strings.HasPrefix(status, "ALERT"),
+		strings.HasPrefix(status, "CRIT"), strings.HasPrefix(status, "ERR"), status == "FATAL":
+		return ansiRed
+	case strings.HasPrefix(status, "WARN"):
+		return ansiYellow
+	case status == "NOTICE":
+		return ansiBlue
+	case status == "INFO", status == "OK":
+		return ansiGreen
+	case status == "DEBUG", status == "TRACE":
+		return ansiGray
+	}
+	return ""
+}
+
+// truncate shortens s to n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
+
+// pad right-pads s with spaces to n runes
+func pad(s string, n int) string {
+	if k := utf8.RuneCountInString(s); k < n {
+		return s + strings.Repeat(" ", n-k)
+	}
+	return s
+}
+
+// Finalize is a no-op for PrettyWriter (already written)
+func (w *PrettyWriter) Finalize() error {
+	return nil
+}
+
+// Close closes the output file (if it's a file)
+func (w *PrettyWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
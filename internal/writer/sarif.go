@@ -0,0 +1,129 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/sarif"
+	"github.com/jtzemp/dogfetch/internal/version"
+)
+
+// SARIFWriter streams logs as results of a single SARIF run
+// Results are written as pages arrive; the tool driver, which lists the
+// rules the results refer to, follows them in Finalize.
+type SARIFWriter struct {
+	out         *bufio.Writer
+	closer      io.Closer
+	converter   *sarif.Converter
+	count       int
+	started     bool
+	shouldClose bool
+}
+
+// NewSARIFWriter creates a new SARIF writer for a file
+func NewSARIFWriter(path string, opts Options) (*SARIFWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SARIFWriter{
+		out:         bufio.NewWriter(f),
+		closer:      f,
+		converter:   sarif.NewConverter(opts.SARIFRuleFields),
+		shouldClose: true,
+	}, nil
+}
+
+// NewSARIFWriterWithOutput creates a new SARIF writer for any io.Writer
+func NewSARIFWriterWithOutput(w io.Writer, opts Options) (*SARIFWriter, error) {
+	return &SARIFWriter{
+		out:         bufio.NewWriter(w),
+		converter:   sarif.NewConverter(opts.SARIFRuleFields),
+		shouldClose: false,
+	}, nil
+}
+
+// WritePage appends a result per log
+func (w *SARIFWriter) WritePage(logs []datadogV2.Log) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	for _, log := range logs {
+		data, err := json.Marshal(w.converter.Result(log))
+		if err != nil {
+			return err
+		}
+
+		sep := ",\n        "
+		if w.count == 0 {
+			sep = "\n        "
+		}
+		if _, err := w.out.WriteString(sep); err != nil {
+			return err
+		}
+		if _, err := w.out.Write(data); err != nil {
+			return err
+		}
+		w.count++
+	}
+	return w.out.Flush()
+}
+
+// Finalize closes the results array and writes the tool driver
+func (w *SARIFWriter) Finalize() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	closing := "\n      ],\n"
+	if w.count == 0 {
+		closing = "],\n"
+	}
+	if _, err := w.out.WriteString(closing); err != nil {
+		return err
+	}
+
+	driver, err := json.MarshalIndent(sarif.Driver{
+		Name:           "dogfetch",
+		Version:        version.Version,
+		InformationURI: sarif.InformationURI,
+		Rules:          w.converter.Rules(),
+	}, "        ", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.out.WriteString("      \"tool\": {\n        \"driver\": "); err != nil {
+		return err
+	}
+	if _, err := w.out.Write(driver); err != nil {
+		return err
+	}
+	if _, err := w.out.WriteString("\n      }\n    }\n  ]\n}\n"); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// Close closes the output file (if it's a file)
+func (w *SARIFWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+// start writes the document header once
+func (w *SARIFWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := w.out.WriteString("{\n  \"$schema\": \"" + sarif.Schema + "\",\n  \"version\": \"" + sarif.Version + "\",\n  \"runs\": [\n    {\n      \"results\": [")
+	return err
+}
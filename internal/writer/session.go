@@ -0,0 +1,157 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+	"github.com/jtzemp/dogfetch/internal/spill"
+)
+
+// SessionWriter groups logs into one NDJSON document per session, with each
+// session's logs ordered by time
+// Logs are buffered until Finalize because a session can span many pages;
+// beyond the memory budget they spill to disk. Sessions are ordered by start
+// time within each spill partition, so a spilled export is not globally
+// ordered. Logs without the stitch field share a session with an empty value.
+type SessionWriter struct {
+	out         *bufio.Writer
+	closer      io.Closer
+	field       string
+	buffer      *spill.Buffer
+	shouldClose bool
+}
+
+type session struct {
+	Session string            `json:"session"`
+	Start   time.Time         `json:"start"`
+	End     time.Time         `json:"end"`
+	Count   int               `json:"count"`
+	Logs    []json.RawMessage `json:"logs"`
+}
+
+type sessionLog struct {
+	ts   int64
+	data json.RawMessage
+}
+
+// NewSessionWriter creates a new session writer for a file
+func NewSessionWriter(path string, opts Options) (*SessionWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, _ := NewSessionWriterWithOutput(f, opts)
+	w.closer = f
+	w.shouldClose = true
+	return w, nil
+}
+
+// NewSessionWriterWithOutput creates a new session writer for any io.Writer
+func NewSessionWriterWithOutput(out io.Writer, opts Options) (*SessionWriter, error) {
+	return &SessionWriter{
+		out:    bufio.NewWriter(out),
+		field:  opts.StitchBy,
+		buffer: spill.New(opts.MaxMemory),
+	}, nil
+}
+
+// WritePage buffers the logs under their session
+func (w *SessionWriter) WritePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		key := ""
+		if v, ok := logfield.Lookup(log, w.field); ok && v != nil {
+			key = fmt.Sprint(v)
+		}
+
+		var ts int64
+		if v, ok := logfield.Lookup(log, "timestamp"); ok {
+			ts = v.(time.Time).UnixNano()
+		}
+
+		data, err := json.Marshal(log)
+		if err != nil {
+			return err
+		}
+
+		// Prefix the timestamp so ordering does not require decoding the log again
+		record := make([]byte, 8, 8+len(data))
+		binary.BigEndian.PutUint64(record, uint64(ts))
+		if err := w.buffer.Add(key, append(record, data...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finalize writes one document per session
+func (w *SessionWriter) Finalize() error {
+	err := w.buffer.Each(func(records []spill.Record) error {
+		var order []string
+		grouped := make(map[string][]sessionLog)
+		for _, r := range records {
+			if _, ok := grouped[r.Key]; !ok {
+				order = append(order, r.Key)
+			}
+			grouped[r.Key] = append(grouped[r.Key], sessionLog{
+				ts:   int64(binary.BigEndian.Uint64(r.Data[:8])),
+				data: r.Data[8:],
+			})
+		}
+
+		sessions := make([]*session, 0, len(order))
+		for _, key := range order {
+			logs := grouped[key]
+			sort.SliceStable(logs, func(i, j int) bool {
+				return logs[i].ts < logs[j].ts
+			})
+
+			s := &session{
+				Session: key,
+				Start:   time.Unix(0, logs[0].ts).UTC(),
+				End:     time.Unix(0, logs[len(logs)-1].ts).UTC(),
+				Count:   len(logs),
+				Logs:    make([]json.RawMessage, len(logs)),
+			}
+			for i, l := range logs {
+				s.Logs[i] = l.data
+			}
+			sessions = append(sessions, s)
+		}
+
+		sort.SliceStable(sessions, func(i, j int) bool {
+			return sessions[i].Start.Before(sessions[j].Start)
+		})
+
+		encoder := json.NewEncoder(w.out)
+		for _, s := range sessions {
+			if err := encoder.Encode(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// Close removes any spill files and closes the output file (if it's a file)
+func (w *SessionWriter) Close() error {
+	err := w.buffer.Close()
+	if w.shouldClose && w.closer != nil {
+		if cerr := w.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package writer
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/siem"
+	"github.com/jtzemp/dogfetch/internal/version"
+)
+
+// SIEMWriter streams logs as CEF or LEEF lines, one per log
+type SIEMWriter struct {
+	out         *bufio.Writer
+	closer      io.Closer
+	formatter   *siem.Formatter
+	shouldClose bool
+}
+
+// NewSIEMWriter creates a new CEF or LEEF writer for a file
+func NewSIEMWriter(format, path string, append bool, opts Options) (*SIEMWriter, error) {
+	f, err := openPageFile(path, append)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SIEMWriter{
+		out:         bufio.NewWriter(f),
+		closer:      f,
+		formatter:   newSIEMFormatter(format, opts),
+		shouldClose: true,
+	}, nil
+}
+
+// NewSIEMWriterWithOutput creates a new CEF or LEEF writer for any io.Writer
+func NewSIEMWriterWithOutput(format string, w io.Writer, opts Options) (*SIEMWriter, error) {
+	return &SIEMWriter{
+		out:         bufio.NewWriter(w),
+		formatter:   newSIEMFormatter(format, opts),
+		shouldClose: false,
+	}, nil
+}
+
+func newSIEMFormatter(format string, opts Options) *siem.Formatter {
+	if format == "leef" {
+		return siem.NewLEEF(version.Version, opts.SIEMFields)
+	}
+	return siem.NewCEF(version.Version, opts.SIEMFields)
+}
+
+// WritePage writes one line per log and flushes at the end of the page
+func (w *SIEMWriter) WritePage(logs []datadogV2.Log) error {
+	return endPage(w.closer, w.writePage(logs))
+}
+
+// writePage encodes a page to the output
+func (w *SIEMWriter) writePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		if _, err := w.out.WriteString(w.formatter.Format(log)); err != nil {
+			return err
+		}
+		if err := w.out.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// Finalize is a no-op for SIEMWriter (already written)
+func (w *SIEMWriter) Finalize() error {
+	return nil
+}
+
+// Close closes the output file (if it's a file)
+func (w *SIEMWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// socketDialTimeout bounds connecting to a unix socket
+const socketDialTimeout = 10 * time.Second
+
+// IsSocketURL reports whether an output path names a local socket to stream
+// to rather than a file: unix:///path/to.sock, or npipe:////./pipe/name for
+// a Windows named pipe
+func IsSocketURL(path string) bool {
+	return strings.HasPrefix(path, "unix://") || strings.HasPrefix(path, "npipe://")
+}
+
+// socketWriter writes a format to a connected socket or pipe, closing the
+// connection when done
+type socketWriter struct {
+	Writer
+	conn io.Closer
+}
+
+// newSocketWriter connects to the socket or named pipe in rawURL and writes
+// format to it as it would to stdout
+func newSocketWriter(format, rawURL string, opts Options) (*socketWriter, error) {
+	conn, err := dialSocket(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWithOutput(format, conn, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &socketWriter{Writer: w, conn: conn}, nil
+}
+
+// dialSocket connects to a unix socket, or opens a Windows named pipe, which
+// the agent must already be listening on
+func dialSocket(rawURL string) (io.WriteCloser, error) {
+	if path, ok := strings.CutPrefix(rawURL, "unix://"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("invalid socket URL %q: no path", rawURL)
+		}
+		return net.DialTimeout("unix", path, socketDialTimeout)
+	}
+
+	name := pipeName(rawURL)
+	if name == "" {
+		return nil, fmt.Errorf("invalid named pipe URL %q: no pipe name", rawURL)
+	}
+	return os.OpenFile(name, os.O_WRONLY, 0)
+}
+
+// pipeName returns the Windows path of a named pipe URL, in the form Docker
+// uses: npipe:////./pipe/name is \\.\pipe\name
+func pipeName(rawURL string) string {
+	name := strings.TrimLeft(strings.TrimPrefix(rawURL, "npipe:"), "/")
+	if name == "" {
+		return ""
+	}
+	return `\\` + strings.ReplaceAll(name, "/", `\`)
+}
+
+// Close closes the underlying writer, then the connection
+func (w *socketWriter) Close() error {
+	err := w.Writer.Close()
+	if connErr := w.conn.Close(); err == nil {
+		err = connErr
+	}
+	return err
+}
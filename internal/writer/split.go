@@ -0,0 +1,126 @@
+package writer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// Chunk is one of the files a SplitWriter wrote
+type Chunk struct {
+	Path    string
+	Records int
+}
+
+// SplitWriter writes logs to numbered files of at most a fixed number of
+// logs each, e.g. logs-00001.ndjson, logs-00002.ndjson. Each file is a
+// complete document of its format, finalized as soon as it is full.
+type SplitWriter struct {
+	path    string
+	size    int
+	open    func(path string) (Writer, error)
+	current Writer
+	chunks  []Chunk
+}
+
+// NewSplitWriter creates a writer splitting output to path into chunks of
+// size logs, each written by a writer from open
+func NewSplitWriter(path string, size int, open func(path string) (Writer, error)) *SplitWriter {
+	return &SplitWriter{path: path, size: size, open: open}
+}
+
+// ChunkPath returns the path of the nth chunk of output to path, numbered
+// before the extension and any .gz, .age or .gpg: logs.ndjson.gz.age becomes
+// logs-00001.ndjson.gz.age
+func ChunkPath(path string, n int) string {
+	base := trimEncrypted(path)
+	suffix := path[len(base):]
+	if strings.HasSuffix(strings.ToLower(base), ".gz") {
+		base, suffix = base[:len(base)-3], base[len(base)-3:]+suffix
+	}
+	ext := filepath.Ext(base)
+	return fmt.Sprintf("%s-%05d%s%s", strings.TrimSuffix(base, ext), n, ext, suffix)
+}
+
+// WritePage writes logs to the current chunk, starting the next whenever
+// one fills up
+func (w *SplitWriter) WritePage(logs []datadogV2.Log) error {
+	for len(logs) > 0 {
+		if w.current == nil || w.chunks[len(w.chunks)-1].Records == w.size {
+			if err := w.next(); err != nil {
+				return err
+			}
+		}
+		chunk := &w.chunks[len(w.chunks)-1]
+		n := min(len(logs), w.size-chunk.Records)
+		if err := w.current.WritePage(logs[:n]); err != nil {
+			return err
+		}
+		chunk.Records += n
+		logs = logs[n:]
+	}
+	return nil
+}
+
+// Finalize finalizes the last chunk. An export without logs still gets one,
+// empty chunk, so there is always a file to load.
+func (w *SplitWriter) Finalize() error {
+	if w.current == nil {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+	return w.current.Finalize()
+}
+
+// Close closes the last chunk
+func (w *SplitWriter) Close() error {
+	if w.current == nil {
+		return nil
+	}
+	return w.current.Close()
+}
+
+// Chunks returns the chunks written so far, in order
+func (w *SplitWriter) Chunks() []Chunk {
+	return w.chunks
+}
+
+// next finishes the current chunk, if any, and opens the next
+func (w *SplitWriter) next() error {
+	if w.current != nil {
+		if err := w.current.Finalize(); err != nil {
+			return err
+		}
+		if err := w.current.Close(); err != nil {
+			return err
+		}
+		w.current = nil
+	}
+
+	path := ChunkPath(w.path, len(w.chunks)+1)
+	current, err := w.open(path)
+	if err != nil {
+		return err
+	}
+	w.current = current
+	w.chunks = append(w.chunks, Chunk{Path: path})
+	return nil
+}
+
+// Chunks returns the chunks w wrote if it splits its output, looking through
+// a --tee to the file it writes; nil otherwise
+func Chunks(w Writer) []Chunk {
+	for {
+		switch v := w.(type) {
+		case *SplitWriter:
+			return v.Chunks()
+		case *TeeWriter:
+			w = v.primary
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package writer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// syslogMaxUDPSize caps datagrams at the message size most collectors
+// accept by default; longer messages are truncated
+const syslogMaxUDPSize = 8192
+
+// syslogDialTimeout bounds connecting to the collector
+const syslogDialTimeout = 10 * time.Second
+
+// syslogTimestamp is RFC 5424's TIMESTAMP, which allows at most six
+// fractional digits
+const syslogTimestamp = "2006-01-02T15:04:05.000000Z07:00"
+
+// syslogFacilities maps facility names to their codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverities maps Datadog statuses to syslog severities
+var syslogSeverities = map[string]int{
+	"emerg":     0,
+	"emergency": 0,
+	"alert":     1,
+	"crit":      2,
+	"critical":  2,
+	"err":       3,
+	"error":     3,
+	"warn":      4,
+	"warning":   4,
+	"notice":    5,
+	"info":      6,
+	"ok":        6,
+	"success":   6,
+	"debug":     7,
+	"trace":     7,
+}
+
+// IsSyslogURL reports whether an output path names a syslog collector
+// rather than a file
+func IsSyslogURL(path string) bool {
+	return strings.HasPrefix(path, "syslog://") || strings.HasPrefix(path, "syslog+")
+}
+
+// SyslogWriter forwards each log to a syslog collector as an RFC 5424
+// message, over UDP (RFC 5426) or TCP and TLS with octet-counted framing
+// (RFC 6587, RFC 5425)
+type SyslogWriter struct {
+	conn     net.Conn
+	out      *bufio.Writer
+	stream   bool
+	facility int
+}
+
+// NewSyslogWriter connects to the collector in rawURL:
+// syslog://host[:514] or syslog+udp:// for UDP, syslog+tcp://host[:514] and
+// syslog+tls://host[:6514]. A facility query parameter (default user) sets
+// the facility, e.g. syslog://siem:514?facility=local0.
+func NewSyslogWriter(rawURL string) (*SyslogWriter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog URL: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("syslog URL '%s' has no host", rawURL)
+	}
+
+	facility := syslogFacilities["user"]
+	if name := u.Query().Get("facility"); name != "" {
+		f, ok := syslogFacilities[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility '%s'", name)
+		}
+		facility = f
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "514"
+		if u.Scheme == "syslog+tls" {
+			port = "6514"
+		}
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "syslog", "syslog+udp":
+		conn, err = net.DialTimeout("udp", addr, syslogDialTimeout)
+	case "syslog+tcp":
+		conn, err = net.DialTimeout("tcp", addr, syslogDialTimeout)
+	case "syslog+tls":
+		dialer := &net.Dialer{Timeout: syslogDialTimeout}
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported syslog scheme '%s': use syslog, syslog+udp, syslog+tcp or syslog+tls", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog collector %s: %w", addr, err)
+	}
+
+	return &SyslogWriter{
+		conn:     conn,
+		out:      bufio.NewWriter(conn),
+		stream:   u.Scheme != "syslog" && u.Scheme != "syslog+udp",
+		facility: facility,
+	}, nil
+}
+
+// WritePage sends one message per log
+func (w *SyslogWriter) WritePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		msg := formatSyslog(log, w.facility)
+
+		if !w.stream {
+			// Each datagram is one message
+			if _, err := w.conn.Write(truncateUTF8(msg, syslogMaxUDPSize)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := w.out.WriteString(strconv.Itoa(len(msg))); err != nil {
+			return err
+		}
+		if err := w.out.WriteByte(' '); err != nil {
+			return err
+		}
+		if _, err := w.out.Write(msg); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// Finalize is a no-op for SyslogWriter (already sent)
+func (w *SyslogWriter) Finalize() error {
+	return nil
+}
+
+// Close closes the connection to the collector
+func (w *SyslogWriter) Close() error {
+	return w.conn.Close()
+}
+
+// formatSyslog renders a log as an RFC 5424 message: status maps to the
+// severity, host to HOSTNAME, service to APP-NAME and the message to MSG
+func formatSyslog(log datadogV2.Log, facility int) []byte {
+	attrs := log.GetAttributes()
+
+	severity, ok := syslogSeverities[strings.ToLower(attrs.GetStatus())]
+	if !ok {
+		severity = syslogSeverities["info"]
+	}
+
+	timestamp := "-"
+	if ts, ok := attrs.GetTimestampOk(); ok {
+		timestamp = ts.Format(syslogTimestamp)
+	}
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	msg := fmt.Sprintf("<%d>1 %s %s %s - - -", facility*8+severity, timestamp,
+		syslogHeaderField(attrs.GetHost(), 255),
+		syslogHeaderField(attrs.GetService(), 48))
+	if message := attrs.GetMessage(); message != "" {
+		msg += " " + message
+	}
+	return []byte(msg)
+}
+
+// syslogHeaderField makes a value fit a header field, which must be
+// printable ASCII without spaces and is "-" when empty
+func syslogHeaderField(s string, maxLen int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if field == "" {
+		return "-"
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	return field
+}
+
+// truncateUTF8 shortens b to at most n bytes without splitting a character
+func truncateUTF8(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	b = b[:n]
+	for i := 1; i < utf8.UTFMax && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size != 1 {
+			break
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
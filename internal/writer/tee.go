@@ -0,0 +1,61 @@
+package writer
+
+import (
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// TeeWriter writes every page to a primary writer and a best-effort mirror.
+// Errors from the primary fail the write as usual; the first error from the
+// mirror, such as the reader of a pipe exiting, stops only the mirror, so the
+// primary output is still complete.
+type TeeWriter struct {
+	primary Writer
+	mirror  Writer
+	onStop  func(error)
+	stopped bool
+}
+
+// NewTeeWriter creates a writer copying pages to mirror as well as primary.
+// onStop, if set, is called with the error that stopped the mirror.
+func NewTeeWriter(primary, mirror Writer, onStop func(error)) *TeeWriter {
+	return &TeeWriter{primary: primary, mirror: mirror, onStop: onStop}
+}
+
+// WritePage writes a page to both writers
+func (w *TeeWriter) WritePage(logs []datadogV2.Log) error {
+	if err := w.primary.WritePage(logs); err != nil {
+		return err
+	}
+	if !w.stopped {
+		w.check(w.mirror.WritePage(logs))
+	}
+	return nil
+}
+
+// Finalize finalizes both writers
+func (w *TeeWriter) Finalize() error {
+	if err := w.primary.Finalize(); err != nil {
+		return err
+	}
+	if !w.stopped {
+		w.check(w.mirror.Finalize())
+	}
+	return nil
+}
+
+// Close closes both writers, reporting only the primary's error
+func (w *TeeWriter) Close() error {
+	w.mirror.Close()
+	return w.primary.Close()
+}
+
+// check stops the mirror on its first error
+func (w *TeeWriter) check(err error) {
+	if err == nil {
+		return
+	}
+	w.stopped = true
+	if w.onStop != nil {
+		w.onStop(err)
+	}
+}
@@ -0,0 +1,119 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/logfield"
+)
+
+// DefaultTextFields are the columns of text output
+var DefaultTextFields = []string{"timestamp", "status", "service", "message"}
+
+// textEscaper keeps every log on one line
+var textEscaper = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+// TextWriter streams logs as human-readable lines of space-separated fields
+type TextWriter struct {
+	out         *bufio.Writer
+	closer      io.Closer
+	fields      []string
+	shouldClose bool
+}
+
+// NewTextWriter creates a new text writer for a file
+func NewTextWriter(path string, append bool, opts Options) (*TextWriter, error) {
+	f, err := openPageFile(path, append)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TextWriter{
+		out:         bufio.NewWriter(f),
+		closer:      f,
+		fields:      textFields(opts),
+		shouldClose: true,
+	}, nil
+}
+
+// NewTextWriterWithOutput creates a new text writer for any io.Writer
+func NewTextWriterWithOutput(w io.Writer, opts Options) (*TextWriter, error) {
+	return &TextWriter{
+		out:         bufio.NewWriter(w),
+		fields:      textFields(opts),
+		shouldClose: false,
+	}, nil
+}
+
+func textFields(opts Options) []string {
+	if len(opts.TextFields) > 0 {
+		return opts.TextFields
+	}
+	return DefaultTextFields
+}
+
+// WritePage writes one line per log and flushes at the end of the page
+func (w *TextWriter) WritePage(logs []datadogV2.Log) error {
+	return endPage(w.closer, w.writePage(logs))
+}
+
+// writePage encodes a page to the output
+func (w *TextWriter) writePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		for i, field := range w.fields {
+			if i > 0 {
+				w.out.WriteByte(' ')
+			}
+			w.out.WriteString(textValue(log, field))
+		}
+		if err := w.out.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+// textValue renders a field for text output, "-" when it is missing
+func textValue(log datadogV2.Log, field string) string {
+	v, ok := logfield.Lookup(log, field)
+	if !ok || v == nil {
+		return "-"
+	}
+
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case time.Time:
+		s = v.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	case []string:
+		s = strings.Join(v, ",")
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		s = string(data)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" {
+		return "-"
+	}
+	return textEscaper.Replace(s)
+}
+
+// Finalize is a no-op for TextWriter (already written)
+func (w *TextWriter) Finalize() error {
+	return nil
+}
+
+// Close closes the output file (if it's a file)
+func (w *TextWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+)
+
+// webhookMaxAttempts is how many times a batch is posted before giving up
+const webhookMaxAttempts = 5
+
+// DefaultWebhookBatch is how many logs a webhook request carries by default
+const DefaultWebhookBatch = 1000
+
+// IsWebhookURL reports whether an output path is an http:// or https:// URL
+// to post logs to rather than a file
+func IsWebhookURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// WebhookWriter posts logs to an HTTP endpoint in batches, as a JSON array
+// (json format) or newline-delimited JSON (ndjson). A batch never spans
+// pages, so once a page is written all of it has been accepted and a
+// resumed fetch neither loses nor repeats logs.
+type WebhookWriter struct {
+	url      string
+	headers  map[string]string
+	batch    int
+	ndjson   bool
+	envelope *envelope.Wrapper
+	client   *http.Client
+	backoff  time.Duration
+}
+
+// NewWebhookWriter creates a writer posting format to url, batch logs per
+// request (DefaultWebhookBatch if 0) with extra headers, e.g. for
+// authentication
+func NewWebhookWriter(format, url string, opts Options) (*WebhookWriter, error) {
+	if format != "json" && format != "ndjson" {
+		return nil, fmt.Errorf("webhook output sends json or ndjson, not %s", format)
+	}
+	batch := opts.WebhookBatch
+	if batch <= 0 {
+		batch = DefaultWebhookBatch
+	}
+	return &WebhookWriter{
+		url:      url,
+		headers:  opts.WebhookHeaders,
+		batch:    batch,
+		ndjson:   format == "ndjson",
+		envelope: opts.Envelope,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		backoff:  time.Second,
+	}, nil
+}
+
+// WritePage posts the page in batches
+func (w *WebhookWriter) WritePage(logs []datadogV2.Log) error {
+	for len(logs) > 0 {
+		n := min(w.batch, len(logs))
+		body, err := w.encode(logs[:n])
+		if err != nil {
+			return err
+		}
+		if err := w.post(body); err != nil {
+			return err
+		}
+		logs = logs[n:]
+	}
+	return nil
+}
+
+// encode builds a request body for a batch
+func (w *WebhookWriter) encode(logs []datadogV2.Log) ([]byte, error) {
+	var buf bytes.Buffer
+	if w.ndjson {
+		encoder := json.NewEncoder(&buf)
+		for _, log := range logs {
+			if err := encoder.Encode(document(w.envelope, log)); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	docs := make([]interface{}, len(logs))
+	for i, log := range logs {
+		docs[i] = document(w.envelope, log)
+	}
+	return json.Marshal(docs)
+}
+
+// post sends a batch, retrying when the endpoint is throttling or
+// temporarily unavailable
+func (w *WebhookWriter) post(body []byte) error {
+	var err error
+	for attempt := 0; attempt < webhookMaxAttempts; attempt++ {
+		var retry bool
+		var wait time.Duration
+		retry, wait, err = w.send(body)
+		if err == nil || !retry {
+			return err
+		}
+		if attempt+1 < webhookMaxAttempts {
+			time.Sleep(max(wait, w.backoff<<attempt))
+		}
+	}
+	return err
+}
+
+// send posts one request and reports whether a failure is worth retrying,
+// and how long the endpoint asked to wait first
+func (w *WebhookWriter) send(body []byte) (bool, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.ndjson {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, 0, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, 0, nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return true, retryAfter(resp), fmt.Errorf("webhook request failed: %s", resp.Status)
+	default:
+		return false, 0, fmt.Errorf("webhook rejected the logs: %s", resp.Status)
+	}
+}
+
+// retryAfter returns the delay a response's Retry-After header asks for in
+// seconds, or 0
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Finalize is a no-op for WebhookWriter (already sent)
+func (w *WebhookWriter) Finalize() error {
+	return nil
+}
+
+// Close is a no-op for WebhookWriter
+func (w *WebhookWriter) Close() error {
+	return nil
+}
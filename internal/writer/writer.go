@@ -1,10 +1,16 @@
 package writer
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/encrypt"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+	"github.com/jtzemp/dogfetch/internal/siem"
 )
 
 // Writer defines the interface for writing log data
@@ -19,21 +25,249 @@ type Writer interface {
 	Close() error
 }
 
+// RawWriter is a writer that can take logs as the JSON records the API sent,
+// writing them as they are instead of encoding the decoded logs again
+type RawWriter interface {
+	// WriteRawPage writes a page of logs from their records; a log whose
+	// record is nil is encoded as usual
+	WriteRawPage(logs []datadogV2.Log, records []json.RawMessage) error
+}
+
+// Options holds format-specific writer settings
+type Options struct {
+	// Aggregate format: fields to group by, bucket width, minimum bucket
+	// size (k-anonymity) and optional differential privacy budget
+	GroupBy    []string
+	Bucket     time.Duration
+	KThreshold int
+	Epsilon    float64
+
+	// StitchBy groups NDJSON output into one document per value of this
+	// field, e.g. session_id
+	StitchBy string
+
+	// SidecarIndex maintains a seek index next to NDJSON output files (see
+	// package sidecar)
+	SidecarIndex bool
+
+	// OTLP format: send to this OTLP/HTTP endpoint instead of writing
+	// OTLP/JSON, with extra request headers (e.g. for authentication)
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+
+	// CEF and LEEF formats: extension keys to add, override or (with an
+	// empty path) drop
+	SIEMFields []siem.Field
+
+	// Text format: fields written on each line (default DefaultTextFields)
+	TextFields []string
+
+	// Pretty format: align columns on a terminal without coloring them, as
+	// --no-color asks (NO_COLOR is honored either way)
+	NoColor bool
+
+	// SARIF format: fields tried in order to name each result's rule
+	// (default sarif.DefaultRuleFields)
+	SARIFRuleFields []string
+
+	// Split writes numbered files of at most this many logs each instead of
+	// one file (see SplitWriter); 0 doesn't split
+	Split int
+
+	// MaxMemory caps the memory used by buffering writers, in bytes; beyond
+	// it they spill to temporary files. Zero means unlimited.
+	MaxMemory int64
+
+	// JSON, NDJSON and MessagePack formats: wrap each log in a versioned
+	// envelope instead of writing it as the API returned it
+	Envelope *envelope.Wrapper
+
+	// Stdout replaces os.Stdout when there's no output path
+	Stdout io.Writer
+
+	// Encrypt output files as they are written; nil writes them in the
+	// clear
+	Encrypt *encrypt.Encrypter
+
+	// Write output files that aren't appended to under a temporary name,
+	// renamed into place once finalized (see atomicWriter)
+	Atomic bool
+
+	// Exec output: restart the command up to this many times if it exits
+	// before the fetch is done, calling OnExecRestart with why each time
+	ExecRestarts  int
+	OnExecRestart func(err error)
+
+	// Webhook output: logs per request (default DefaultWebhookBatch) and
+	// extra request headers, e.g. for authentication
+	WebhookBatch   int
+	WebhookHeaders map[string]string
+}
+
 // New creates a new writer based on format
 // If path is empty, writes to stdout
 func New(format, path string, append bool) (Writer, error) {
+	return NewWithOptions(format, path, append, Options{})
+}
+
+// NewWithOptions creates a new writer based on format with format-specific options
+// If path is empty, writes to stdout; a syslog:// URL forwards to a collector,
+// a unix:// or npipe:// URL streams to a local socket or named pipe,
+// exec:<command> pipes ndjson into a command, an http:// or https:// URL
+// posts batches to a webhook, and a firehose:// URL puts records to an
+// Amazon Data Firehose delivery stream.
+// An empty format is inferred from the extension of path, and a path ending
+// in .gz is compressed.
+func NewWithOptions(format, path string, append bool, opts Options) (Writer, error) {
+	if format == "" {
+		inferred, err := InferFormat(path)
+		if err != nil {
+			return nil, err
+		}
+		format = inferred
+		if format == "" {
+			format = DefaultFormat
+		}
+	}
+	if IsSyslogURL(path) {
+		return NewSyslogWriter(path)
+	}
+	if format == "otlp" && opts.OTLPEndpoint != "" {
+		return NewOTLPHTTPWriter(opts.OTLPEndpoint, opts.OTLPHeaders)
+	}
+	if path == "" {
+		if opts.Stdout != nil {
+			return NewWithOutput(format, opts.Stdout, opts)
+		}
+		return NewWithOutput(format, os.Stdout, opts)
+	}
+	if IsSocketURL(path) {
+		return newSocketWriter(format, path, opts)
+	}
+	if IsExecURL(path) {
+		return NewExecWriter(path, opts)
+	}
+	if IsWebhookURL(path) {
+		return NewWebhookWriter(format, path, opts)
+	}
+	if IsFirehoseURL(path) {
+		return NewFirehoseWriter(path, opts)
+	}
+	if opts.Split > 0 {
+		chunkOpts := opts
+		chunkOpts.Split = 0
+		return NewSplitWriter(path, opts.Split, func(chunk string) (Writer, error) {
+			return NewWithOptions(format, chunk, false, chunkOpts)
+		}), nil
+	}
+	if opts.Atomic && !append {
+		tmp := path + ".tmp"
+		w, err := newFileWriter(format, tmp, path, false, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &atomicWriter{Writer: w, tmp: tmp, path: path}, nil
+	}
+	return newFileWriter(format, path, path, append, opts)
+}
+
+// newFileWriter creates a writer for format to the file at path, which ends
+// up at name: name decides whether the output is compressed
+func newFileWriter(format, path, name string, append bool, opts Options) (Writer, error) {
+	if opts.Encrypt != nil {
+		return newEncryptedWriter(format, path, name, opts)
+	}
+	if Gzipped(name) {
+		return newGzipWriter(format, path, append, opts)
+	}
+
 	switch format {
 	case "json":
-		if path == "" {
-			return NewJSONWriterWithOutput(os.Stdout)
+		return enveloped(NewJSONWriter(path))(opts.Envelope)
+	case "ndjson":
+		if opts.StitchBy != "" {
+			return NewSessionWriter(path, opts)
 		}
-		return NewJSONWriter(path)
+		if opts.SidecarIndex {
+			return enveloped(NewIndexedNDJSONWriter(path, append))(opts.Envelope)
+		}
+		return enveloped(NewNDJSONWriter(path, append))(opts.Envelope)
+	case "msgpack":
+		return enveloped(NewMsgpackWriter(path, append))(opts.Envelope)
+	case "otlp":
+		return NewOTLPWriter(path, append)
+	case "cef", "leef":
+		return NewSIEMWriter(format, path, append, opts)
+	case "text":
+		return NewTextWriter(path, append, opts)
+	case "pretty":
+		return NewPrettyWriter(path, append, opts)
+	case "sarif":
+		return NewSARIFWriter(path, opts)
+	case "xlsx":
+		return NewXLSXWriter(path)
+	case "aggregate":
+		return NewAggregateWriter(path, opts)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// NewWithOutput creates a new writer based on format that writes to any
+// io.Writer
+func NewWithOutput(format string, out io.Writer, opts Options) (Writer, error) {
+	switch format {
+	case "json":
+		return enveloped(NewJSONWriterWithOutput(out))(opts.Envelope)
 	case "ndjson":
-		if path == "" {
-			return NewNDJSONWriterWithOutput(os.Stdout)
+		if opts.StitchBy != "" {
+			return NewSessionWriterWithOutput(out, opts)
 		}
-		return NewNDJSONWriter(path, append)
+		return enveloped(NewNDJSONWriterWithOutput(out))(opts.Envelope)
+	case "msgpack":
+		return enveloped(NewMsgpackWriterWithOutput(out))(opts.Envelope)
+	case "otlp":
+		return NewOTLPWriterWithOutput(out)
+	case "cef", "leef":
+		return NewSIEMWriterWithOutput(format, out, opts)
+	case "text":
+		return NewTextWriterWithOutput(out, opts)
+	case "pretty":
+		return NewPrettyWriterWithOutput(out, opts)
+	case "sarif":
+		return NewSARIFWriterWithOutput(out, opts)
+	case "xlsx":
+		return NewXLSXWriterWithOutput(out)
+	case "aggregate":
+		return NewAggregateWriterWithOutput(out, opts)
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", format)
 	}
 }
+
+// documentWriter is a writer that encodes each log as its own document, and
+// so can wrap it in an envelope
+type documentWriter interface {
+	Writer
+	setEnvelope(e *envelope.Wrapper)
+}
+
+// enveloped sets the envelope of a document writer as it is created
+func enveloped[W documentWriter](w W, err error) func(*envelope.Wrapper) (Writer, error) {
+	return func(e *envelope.Wrapper) (Writer, error) {
+		if err != nil {
+			return nil, err
+		}
+		w.setEnvelope(e)
+		return w, nil
+	}
+}
+
+// document returns what a document writer encodes for a log: the log as the
+// API returned it, or its envelope
+func document(e *envelope.Wrapper, log datadogV2.Log) interface{} {
+	if e == nil {
+		return log
+	}
+	return e.Wrap(log)
+}
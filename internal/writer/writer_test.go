@@ -1,13 +1,32 @@
 package writer
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"filippo.io/age"
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/jtzemp/dogfetch/internal/encrypt"
+	"github.com/jtzemp/dogfetch/internal/envelope"
+	"github.com/jtzemp/dogfetch/internal/firehose"
+	"github.com/jtzemp/dogfetch/internal/sidecar"
+	"github.com/jtzemp/dogfetch/internal/siem"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -36,6 +55,34 @@ func TestNDJSONWriterWithOutput(t *testing.T) {
 	}
 }
 
+func TestWritersWrapInEnvelope(t *testing.T) {
+	wrapper, err := envelope.New("v1", envelope.Source{Index: "main"})
+	require.NoError(t, err)
+
+	for _, format := range []string{"ndjson", "json", "msgpack"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWithOutput(format, &buf, Options{Envelope: wrapper})
+			require.NoError(t, err)
+			require.NoError(t, w.WritePage(createTestLogs(2)))
+			require.NoError(t, w.Finalize())
+
+			assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("dogfetch/v1")))
+		})
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWithOutput("ndjson", &buf, Options{Envelope: wrapper})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+
+	var got envelope.Envelope
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "test-id", got.ID)
+	assert.Equal(t, "test message", got.Record.Message)
+	assert.Equal(t, "main", got.Source.Index)
+}
+
 func TestNDJSONWriterWithFile(t *testing.T) {
 	tmpfile := createTempFile(t)
 	defer os.Remove(tmpfile)
@@ -79,6 +126,170 @@ func TestNDJSONWriterAppend(t *testing.T) {
 	assert.Len(t, lines, 5)
 }
 
+func TestFileWritersWriteWholePages(t *testing.T) {
+	// A value JSON can't encode fails the page on its second log
+	bad := createTestLogs(3)
+	bad[1].Attributes.Attributes = map[string]interface{}{"fn": func() {}}
+
+	for _, format := range []string{"ndjson", "msgpack"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out")
+			w, err := NewWithOptions(format, path, false, Options{})
+			require.NoError(t, err)
+			require.NoError(t, w.WritePage(createTestLogs(2)))
+			written, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NotEmpty(t, written)
+
+			require.Error(t, w.WritePage(bad))
+			require.NoError(t, w.Close())
+
+			after, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, written, after, "nothing of the failed page is written")
+		})
+	}
+}
+
+func TestEndPage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	p, err := openPageFile(path, false)
+	require.NoError(t, err)
+	defer p.Close()
+
+	fmt.Fprint(p, "one\n")
+	require.NoError(t, endPage(p, nil))
+	fmt.Fprint(p, "two\n")
+	assert.EqualError(t, endPage(p, errors.New("encoding failed")), "encoding failed")
+	fmt.Fprint(p, "three\n")
+	require.NoError(t, endPage(p, nil))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "one\nthree\n", string(data))
+
+	// Outputs other than page files pass the error through
+	assert.EqualError(t, endPage(nil, errors.New("broken pipe")), "broken pipe")
+	assert.NoError(t, endPage(nil, nil))
+}
+
+func TestPageFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	p, err := openPageFile(path, false)
+	require.NoError(t, err)
+	defer p.Close()
+
+	fmt.Fprint(p, "one\n")
+	require.NoError(t, endPage(p, nil))
+
+	// As logrotate does: move the file away, then signal
+	rotated := path + ".1"
+	require.NoError(t, os.Rename(path, rotated))
+	fmt.Fprint(p, "two\n")
+	Reopen()
+	fmt.Fprint(p, "three\n")
+	require.NoError(t, endPage(p, nil))
+	fmt.Fprint(p, "four\n")
+	require.NoError(t, endPage(p, nil))
+
+	old, err := os.ReadFile(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "one\n", string(old))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "two\nthree\nfour\n", string(data), "a page staged before the signal goes to the new file")
+}
+
+func TestNDJSONWriterRawPage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	w, err := NewWithOptions("ndjson", path, false, Options{SidecarIndex: true})
+	require.NoError(t, err)
+	raw, ok := w.(RawWriter)
+	require.True(t, ok)
+	logs := []datadogV2.Log{createSessionLog("s", "a", base), createSessionLog("s", "b", base.Add(time.Minute))}
+	require.NoError(t, raw.WriteRawPage(logs, []json.RawMessage{
+		json.RawMessage("{\"id\": \"a\",\n \"n\": 1.50}"),
+		nil, // no record: encoded as usual
+	}))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"id":"a","n":1.50}`, lines[0])
+	assert.Contains(t, lines[1], `"id":"b"`)
+
+	idx, err := sidecar.Read(sidecar.Path(path))
+	require.NoError(t, err)
+	assert.True(t, idx.Fresh(int64(len(data))))
+	assert.Equal(t, 2, idx.Records)
+}
+
+func TestIndexedNDJSONWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	w, err := NewWithOptions("ndjson", path, false, Options{SidecarIndex: true})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		createSessionLog("s", "a", base),
+		createSessionLog("s", "b", base.Add(30*time.Second)),
+		createSessionLog("s", "c", base.Add(2*time.Minute)),
+	}))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	// Appending extends the index; it must match one built from scratch
+	w, err = NewWithOptions("ndjson", path, true, Options{SidecarIndex: true})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage([]datadogV2.Log{createSessionLog("s", "d", base.Add(2*time.Minute))}))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	idx, err := sidecar.Read(sidecar.Path(path))
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, idx.Fresh(info.Size()))
+	assert.Equal(t, 4, idx.Records)
+	require.Len(t, idx.Blocks, 2)
+	assert.Equal(t, "a", idx.Blocks[0].MinID)
+	assert.Equal(t, "b", idx.Blocks[0].MaxID)
+	assert.Equal(t, 2, idx.Blocks[0].Records)
+
+	built, err := sidecar.Build(path, sidecar.DefaultBucket)
+	require.NoError(t, err)
+	assert.Equal(t, built.Blocks, idx.Blocks)
+}
+
+func TestIndexedNDJSONWriterRebuildsStaleIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// A file written without an index, then appended to with one
+	w, err := NewNDJSONWriter(path, false)
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage([]datadogV2.Log{createSessionLog("s", "a", base)}))
+	require.NoError(t, w.Close())
+
+	iw, err := NewIndexedNDJSONWriter(path, true)
+	require.NoError(t, err)
+	require.NoError(t, iw.WritePage([]datadogV2.Log{createSessionLog("s", "b", base.Add(time.Hour))}))
+	require.NoError(t, iw.Finalize())
+	require.NoError(t, iw.Close())
+
+	idx, err := sidecar.Read(sidecar.Path(path))
+	require.NoError(t, err)
+	assert.Equal(t, 2, idx.Records)
+	require.Len(t, idx.Blocks, 2)
+	assert.Equal(t, "b", idx.Blocks[1].MinID)
+	assert.Equal(t, idx.Blocks[0].Length, idx.Blocks[1].Offset)
+}
+
 func TestJSONWriterWithOutput(t *testing.T) {
 	var buf bytes.Buffer
 	w, err := NewJSONWriterWithOutput(&buf)
@@ -125,6 +336,33 @@ func TestJSONWriterWithFile(t *testing.T) {
 	assert.Len(t, logs, 3)
 }
 
+func TestJSONWriterStreamsPages(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewJSONWriterWithOutput(&buf)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	assert.Equal(t, 2, strings.Count(buf.String(), "test message"), "logs should be written before Finalize")
+
+	require.NoError(t, w.Finalize())
+	assert.True(t, json.Valid(buf.Bytes()))
+}
+
+func TestJSONWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewJSONWriterWithOutput(&buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Finalize())
+
+	var output map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+
+	logs, ok := output["logs"].([]interface{})
+	require.True(t, ok)
+	assert.Empty(t, logs)
+	assert.Equal(t, float64(0), output["meta"].(map[string]interface{})["total_fetched"])
+}
+
 func TestNewWriter(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -161,6 +399,20 @@ func TestNewWriter(t *testing.T) {
 			append:  false,
 			wantErr: false,
 		},
+		{
+			name:    "msgpack to file",
+			format:  "msgpack",
+			path:    createTempFile(t),
+			append:  false,
+			wantErr: false,
+		},
+		{
+			name:    "aggregate to stdout",
+			format:  "aggregate",
+			path:    "",
+			append:  false,
+			wantErr: false,
+		},
 		{
 			name:    "invalid format",
 			format:  "xml",
@@ -189,28 +441,1283 @@ func TestNewWriter(t *testing.T) {
 	}
 }
 
-// Helper functions
+func TestMsgpackWriterWithOutput(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewMsgpackWriterWithOutput(&buf)
+	require.NoError(t, err)
 
-func createTestLogs(count int) []datadogV2.Log {
-	logs := make([]datadogV2.Log, count)
-	for i := 0; i < count; i++ {
-		id := "test-id"
-		message := "test message"
-		logs[i] = datadogV2.Log{
-			Id: &id,
-			Attributes: &datadogV2.LogAttributes{
-				Message: &message,
-			},
+	id := "AQAAAZ"
+	require.NoError(t, w.WritePage([]datadogV2.Log{{Id: &id}}))
+	require.NoError(t, w.Finalize())
+
+	// fixmap of 1: fixstr "id" -> fixstr "AQAAAZ"
+	expected := append([]byte{0x81, 0xa2, 'i', 'd', 0xa6}, "AQAAAZ"...)
+	assert.Equal(t, expected, buf.Bytes())
+}
+
+func TestOTLPWriterWithOutput(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewOTLPWriterWithOutput(&buf)
+	require.NoError(t, err)
+
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+	require.NoError(t, w.Finalize())
+
+	// One export request per page, one per line
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var req struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []map[string]interface{} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	require.NoError(t, json.Unmarshal(lines[0], &req))
+	require.Len(t, req.ResourceLogs, 1)
+	assert.Len(t, req.ResourceLogs[0].ScopeLogs[0].LogRecords, 2)
+}
+
+func TestOTLPHTTPWriter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.NotEmpty(t, body)
+
+		// Throttle the first attempt to exercise the retry
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
 		}
+	}))
+	defer server.Close()
+
+	w, err := NewOTLPHTTPWriter(server.URL, map[string]string{"Authorization": "secret"})
+	require.NoError(t, err)
+	w.backoff = time.Millisecond
+
+	require.NoError(t, w.WritePage(createTestLogs(3)))
+	assert.Equal(t, 2, requests)
+}
+
+func TestOTLPHTTPWriterRejected(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w, err := NewOTLPHTTPWriter(server.URL+"/custom/logs", nil)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/custom/logs", w.url)
+
+	assert.Error(t, w.WritePage(createTestLogs(1)))
+	assert.Equal(t, 1, requests, "client errors are not retried")
+
+	_, err = NewOTLPHTTPWriter("localhost:4317", nil)
+	assert.Error(t, err)
+}
+
+func TestWebhookWriter(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	w, err := NewWithOptions("", server.URL+"/ingest", false, Options{
+		WebhookBatch:   2,
+		WebhookHeaders: map[string]string{"Authorization": "Bearer secret"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(3)))
+	require.NoError(t, w.Finalize())
+
+	// Batches don't span pages
+	require.Len(t, bodies, 2)
+	assert.Equal(t, 2, strings.Count(bodies[0], "\n"))
+	assert.Equal(t, 1, strings.Count(bodies[1], "\n"))
+}
+
+func TestWebhookWriterJSONArray(t *testing.T) {
+	requests := 0
+	var logs []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Throttle the first attempt to exercise the retry
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&logs))
+	}))
+	defer server.Close()
+
+	w, err := NewWebhookWriter("json", server.URL, Options{})
+	require.NoError(t, err)
+	w.backoff = time.Millisecond
+	require.NoError(t, w.WritePage(createTestLogs(3)))
+	assert.Equal(t, 2, requests)
+	assert.Len(t, logs, 3)
+
+	_, err = NewWebhookWriter("xlsx", server.URL, Options{})
+	assert.Error(t, err)
+}
+
+func TestWebhookWriterRejected(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	w, err := NewWebhookWriter("ndjson", server.URL, Options{})
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.WritePage(createTestLogs(1)), "401")
+	assert.Equal(t, 1, requests, "client errors are not retried")
+}
+
+func TestFirehoseWriter(t *testing.T) {
+	var batches [][]string
+	failOnce := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Firehose_20150804.PutRecordBatch", r.Header.Get("X-Amz-Target"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKID/")
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/firehose/aws4_request")
+		var req struct {
+			DeliveryStreamName string
+			Records            []struct{ Data []byte }
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "logs-to-s3", req.DeliveryStreamName)
+		var batch []string
+		for _, record := range req.Records {
+			batch = append(batch, string(record.Data))
+		}
+		batches = append(batches, batch)
+
+		// Fail the second record of the first batch to exercise the retry
+		responses := make([]map[string]string, len(req.Records))
+		failed := 0
+		for i := range responses {
+			responses[i] = map[string]string{"RecordId": "id"}
+			if failOnce && i == 1 {
+				responses[i] = map[string]string{"ErrorCode": "ServiceUnavailableException"}
+				failed++
+			}
+		}
+		failOnce = false
+		json.NewEncoder(w).Encode(map[string]interface{}{"FailedPutCount": failed, "RequestResponses": responses})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_FIREHOSE", server.URL)
+
+	w, err := NewWithOptions("", "firehose://logs-to-s3?region=eu-west-1", false, Options{})
+	require.NoError(t, err)
+	require.IsType(t, &FirehoseWriter{}, w)
+	w.(*FirehoseWriter).backoff = time.Millisecond
+	logs := createTestLogs(firehose.MaxBatchRecords + 1)
+	require.NoError(t, w.WritePage(logs))
+	require.NoError(t, w.Finalize())
+
+	// A full batch, the record it failed, then the rest of the page
+	require.Len(t, batches, 3)
+	assert.Len(t, batches[0], firehose.MaxBatchRecords)
+	require.Len(t, batches[1], 1)
+	assert.Equal(t, batches[0][1], batches[1][0])
+	assert.Len(t, batches[2], 1)
+	assert.True(t, strings.HasSuffix(batches[2][0], "}\n"), "each record is an ndjson line")
+	assert.Contains(t, batches[2][0], logs[firehose.MaxBatchRecords].GetId())
+}
+
+func TestFirehoseWriterRetriesNetworkErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// Drop the connection without a response
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		json.NewEncoder(w).Encode(map[string]interface{}{"FailedPutCount": 0, "RequestResponses": []map[string]string{{"RecordId": "id"}}})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_FIREHOSE", server.URL)
+
+	w, err := NewFirehoseWriter("firehose://logs?region=us-east-1", Options{})
+	require.NoError(t, err)
+	w.backoff = time.Millisecond
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+	assert.Equal(t, 2, requests)
+}
+
+func TestFirehoseWriterConfig(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	_, err := NewFirehoseWriter("firehose://", Options{})
+	assert.ErrorContains(t, err, "no delivery stream")
+	_, err = NewFirehoseWriter("firehose://logs", Options{})
+	assert.ErrorContains(t, err, "requires a region")
+	_, err = NewFirehoseWriter("firehose://logs?region=us-east-1", Options{})
+	assert.ErrorContains(t, err, "AWS_ACCESS_KEY_ID")
+
+	t.Setenv("AWS_DEFAULT_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	_, err = NewFirehoseWriter("firehose://logs", Options{})
+	assert.NoError(t, err)
+}
+
+func TestSIEMWriterWithOutput(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWithOptions("leef", "", false, Options{})
+	require.NoError(t, err)
+	require.IsType(t, &SIEMWriter{}, w)
+
+	w, err = NewSIEMWriterWithOutput("cef", &buf, Options{SIEMFields: []siem.Field{{Key: "msg"}}})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.Finalize())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "CEF:0|Datadog|dogfetch|"))
+	assert.True(t, strings.HasSuffix(lines[0], "|test message|2|externalId=test-id"))
+}
+
+func TestTextWriterWithOutput(t *testing.T) {
+	w, err := NewWithOptions("text", "", false, Options{})
+	require.NoError(t, err)
+	require.IsType(t, &TextWriter{}, w)
+
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 0, time.FixedZone("PST", -8*3600))
+	message, status, service := "panic: boom\ngoroutine 1", "error", "web"
+	logs := createTestLogs(2)
+	logs[0].Attributes.Timestamp = &ts
+	logs[0].Attributes.Status = &status
+	logs[0].Attributes.Service = &service
+	logs[0].Attributes.Message = &message
+
+	var buf bytes.Buffer
+	w, err = NewTextWriterWithOutput(&buf, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(logs))
+	require.NoError(t, w.Finalize())
+	assert.Equal(t, "2024-01-15T18:30:00.000Z error web panic: boom\\ngoroutine 1\n- - - test message\n", buf.String())
+
+	buf.Reset()
+	logs[0].Attributes.Tags = []string{"env:prod", "team:web"}
+	logs[0].Attributes.Attributes = map[string]interface{}{"duration": 12.5, "http": map[string]interface{}{"method": "GET"}}
+	w, err = NewTextWriterWithOutput(&buf, Options{TextFields: []string{"id", "duration", "http", "tags"}})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(logs[:1]))
+	assert.Equal(t, "test-id 12.5 {\"method\":\"GET\"} env:prod,team:web\n", buf.String())
+}
+
+func TestPrettyWriterFallsBackToText(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWithOutput("pretty", &buf, Options{})
+	require.NoError(t, err)
+	require.IsType(t, &TextWriter{}, w)
+
+	path := createTempFile(t)
+	defer os.Remove(path)
+	w, err = NewWithOptions("pretty", path, false, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	require.IsType(t, &TextWriter{}, w)
+}
+
+func TestPrettyWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &PrettyWriter{out: bufio.NewWriter(&buf), width: 70, color: true}
+
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 123e6, time.UTC)
+	stamp := ts.Local().Format("2006-01-02 15:04:05.000")
+	newLog := func(status, service, message string) datadogV2.Log {
+		return datadogV2.Log{Attributes: &datadogV2.LogAttributes{
+			Timestamp: &ts, Status: &status, Service: &service, Message: &message,
+		}}
 	}
-	return logs
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		newLog("error", "web", "upstream connect error"),
+		newLog("warning", "checkout-api", "slow\nquery"),
+		newLog("debug", "web", strings.Repeat("x", 80)),
+	}))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	// The service column fits the page's longest service
+	assert.Equal(t, ansiGray+stamp+ansiReset+"  "+ansiRed+"ERROR"+ansiReset+"  "+ansiBold+"web         "+ansiReset+"  upstream connect error", lines[0])
+	assert.Equal(t, ansiGray+stamp+ansiReset+"  "+ansiYellow+"WARN "+ansiReset+"  "+ansiBold+"checkout-api"+ansiReset+"  slow\\nquery", lines[1])
+	assert.Contains(t, lines[2], ansiBold+"web         "+ansiReset)
+
+	// Messages are cut to the terminal width
+	message := strings.TrimSuffix(lines[2][strings.LastIndex(lines[2], ansiGray):], ansiReset)
+	message = strings.TrimPrefix(message, ansiGray)
+	assert.Equal(t, 70-(len(stamp)+2+5+2+12+2), len([]rune(message)))
+	assert.True(t, strings.HasSuffix(message, "…"))
 }
 
-func createTempFile(t *testing.T) string {
-	t.Helper()
-	f, err := os.CreateTemp("", "dogfetch-test-*.json")
+func TestPrettyWriterWithoutColor(t *testing.T) {
+	var buf bytes.Buffer
+	w := &PrettyWriter{out: bufio.NewWriter(&buf), width: 70}
+
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 123e6, time.UTC)
+	stamp := ts.Local().Format("2006-01-02 15:04:05.000")
+	status, service, message := "debug", "web", "cache miss"
+	require.NoError(t, w.WritePage([]datadogV2.Log{{Attributes: &datadogV2.LogAttributes{
+		Timestamp: &ts, Status: &status, Service: &service, Message: &message,
+	}}}))
+
+	// Still aligned, but without escape codes
+	assert.Equal(t, stamp+"  DEBUG  web  cache miss\n", buf.String())
+}
+
+func TestSARIFWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWithOutput("sarif", &buf, Options{})
 	require.NoError(t, err)
-	path := f.Name()
-	f.Close()
-	return path
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+	require.NoError(t, w.Finalize())
+
+	var doc struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Message struct {
+					Text string `json:"text"`
+				} `json:"message"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "2.1.0", doc.Version)
+	require.Len(t, doc.Runs, 1)
+	assert.Equal(t, "dogfetch", doc.Runs[0].Tool.Driver.Name)
+	require.Len(t, doc.Runs[0].Results, 3)
+	assert.Equal(t, "log", doc.Runs[0].Results[0].RuleID)
+	assert.Equal(t, "test message", doc.Runs[0].Results[0].Message.Text)
+	require.Len(t, doc.Runs[0].Tool.Driver.Rules, 1)
+	assert.Equal(t, "log", doc.Runs[0].Tool.Driver.Rules[0].ID)
+}
+
+func TestSARIFWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewSARIFWriterWithOutput(&buf, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.Finalize())
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	run := doc["runs"].([]interface{})[0].(map[string]interface{})
+	assert.Empty(t, run["results"])
+	assert.NotNil(t, run["tool"])
+}
+
+func TestXLSXWriter(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	id, status, service, message := "AAA", "error", "web", "a < b & c"
+	logs := []datadogV2.Log{{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Timestamp:  &ts,
+			Status:     &status,
+			Service:    &service,
+			Message:    &message,
+			Tags:       []string{"env:prod", "team:core"},
+			Attributes: map[string]interface{}{"http": map[string]interface{}{"status_code": 500}},
+		},
+	}}
+
+	path := filepath.Join(t.TempDir(), "logs.xlsx")
+	w, err := NewWithOptions("xlsx", path, false, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(logs))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	parts := readXLSX(t, path)
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Logs" sheetId="1" r:id="rId1"/>`)
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">Timestamp (UTC)</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="A2" s="1"><v>45293.5000000000</v></c>`)
+	assert.Contains(t, sheet, `<c r="E2" t="inlineStr"><is><t xml:space="preserve">a &lt; b &amp; c</t></is></c>`)
+	assert.Contains(t, sheet, `<t xml:space="preserve">env:prod, team:core</t>`)
+	assert.Contains(t, sheet, `<t xml:space="preserve">{&#34;http&#34;:{&#34;status_code&#34;:500}}</t>`)
+	// No host, so no D cell
+	assert.NotContains(t, sheet, `r="D2"`)
+}
+
+func TestXLSXWriterSplitsSheets(t *testing.T) {
+	defer func(rows int) { xlsxMaxRows = rows }(xlsxMaxRows)
+	xlsxMaxRows = 3
+
+	path := filepath.Join(t.TempDir(), "logs.xlsx")
+	w, err := NewXLSXWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(3)))
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	// Each sheet has a header and two logs
+	parts := readXLSX(t, path)
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="Logs (3)" sheetId="3" r:id="rId3"/>`)
+	assert.Contains(t, parts["[Content_Types].xml"], `/xl/worksheets/sheet3.xml`)
+	assert.Contains(t, parts["xl/_rels/workbook.xml.rels"], `Id="rId4"`)
+	for i, rows := range []int{3, 3, 2} {
+		sheet := parts[fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)]
+		assert.Equal(t, rows, strings.Count(sheet, "<row "), "sheet %d", i+1)
+	}
+	assert.NotContains(t, parts, "xl/worksheets/sheet4.xml")
+}
+
+func TestXLSXWriterEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.xlsx")
+	w, err := NewXLSXWriter(path)
+	require.NoError(t, err)
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	parts := readXLSX(t, path)
+	assert.Equal(t, 1, strings.Count(parts["xl/worksheets/sheet1.xml"], "<row "))
+}
+
+// readXLSX returns the parts of a workbook, checking each is well-formed XML
+func readXLSX(t *testing.T, path string) map[string]string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	parts := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, f.Name)
+		}
+		parts[f.Name] = string(data)
+	}
+	return parts
+}
+
+func TestInferFormat(t *testing.T) {
+	for path, want := range map[string]string{
+		"":                       "",
+		"logs.ndjson":            "ndjson",
+		"logs.jsonl":             "ndjson",
+		"logs.ndjson.gz":         "ndjson",
+		"out/Logs.JSON":          "json",
+		"logs.json.gz":           "json",
+		"logs.ndjson.gz.age":     "ndjson",
+		"logs.txt.gpg":           "text",
+		"logs.xlsx":              "xlsx",
+		"logs.gz":                "",
+		"logs":                   "",
+		"syslog://logs.host:514": "",
+	} {
+		got, err := InferFormat(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, want, got, path)
+	}
+
+	for _, path := range []string{"logs.csv", "logs.parquet", "logs.csv.gz"} {
+		_, err := InferFormat(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestNewWriterInfersFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	// An explicit format wins over the extension
+	path := filepath.Join(dir, "logs.json")
+	w, err := NewWithOptions("ndjson", path, false, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &NDJSONWriter{}, w)
+	w.Close()
+
+	w, err = NewWithOptions("", path, false, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &JSONWriter{}, w)
+	w.Close()
+
+	_, err = NewWithOptions("", filepath.Join(dir, "logs.csv"), false, Options{})
+	assert.Error(t, err)
+}
+
+func TestGzipWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson.gz")
+	for _, append := range []bool{false, true} {
+		w, err := NewWithOptions("", path, append, Options{})
+		require.NoError(t, err)
+		require.NoError(t, w.WritePage(createTestLogs(2)))
+		require.NoError(t, w.Finalize())
+		require.NoError(t, w.Close())
+	}
+
+	// The appended run is a second gzip member, read as one stream
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[3], `"test message"`)
+}
+
+func TestTeeWriter(t *testing.T) {
+	var primary, mirror bytes.Buffer
+	var stopped error
+	p, err := NewNDJSONWriterWithOutput(&primary)
+	require.NoError(t, err)
+	m, err := NewNDJSONWriterWithOutput(&mirror)
+	require.NoError(t, err)
+	w := NewTeeWriter(p, m, func(err error) { stopped = err })
+
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+	assert.Equal(t, primary.String(), mirror.String())
+	assert.Equal(t, 2, strings.Count(primary.String(), "\n"))
+	assert.NoError(t, stopped)
+}
+
+// failingWriter fails every page
+type failingWriter struct {
+	err   error
+	calls int
+}
+
+func (w *failingWriter) WritePage([]datadogV2.Log) error { w.calls++; return w.err }
+func (w *failingWriter) Finalize() error                 { w.calls++; return w.err }
+func (w *failingWriter) Close() error                    { return nil }
+
+func TestTeeWriterStopsMirror(t *testing.T) {
+	var primary bytes.Buffer
+	p, err := NewNDJSONWriterWithOutput(&primary)
+	require.NoError(t, err)
+	m := &failingWriter{err: errors.New("broken pipe")}
+	var stops []error
+	w := NewTeeWriter(p, m, func(err error) { stops = append(stops, err) })
+
+	// The mirror's error stops it, once, without failing the primary
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+	require.NoError(t, w.Finalize())
+	assert.Equal(t, 2, strings.Count(primary.String(), "\n"))
+	assert.Len(t, stops, 1)
+	assert.Equal(t, 1, m.calls)
+}
+
+func TestChunkPath(t *testing.T) {
+	assert.Equal(t, "logs-00001.ndjson", ChunkPath("logs.ndjson", 1))
+	assert.Equal(t, "out/logs-00012.ndjson.gz", ChunkPath("out/logs.ndjson.gz", 12))
+	assert.Equal(t, "logs-123456", ChunkPath("logs", 123456))
+	assert.Equal(t, "logs-00001.ndjson.gz.age", ChunkPath("logs.ndjson.gz.age", 1))
+	assert.Equal(t, "logs-00002.ndjson.gpg", ChunkPath("logs.ndjson.gpg", 2))
+}
+
+func TestSplitWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.ndjson")
+	w, err := NewWithOptions("ndjson", path, false, Options{Split: 3})
+	require.NoError(t, err)
+
+	// Pages are cut across chunks
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.WritePage(createTestLogs(5)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	chunks := Chunks(w)
+	require.Len(t, chunks, 3)
+	for i, want := range []int{3, 3, 1} {
+		assert.Equal(t, ChunkPath(path, i+1), chunks[i].Path)
+		assert.Equal(t, want, chunks[i].Records)
+		data, err := os.ReadFile(chunks[i].Path)
+		require.NoError(t, err)
+		assert.Equal(t, want, strings.Count(string(data), "\n"))
+	}
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "nothing is written to the unsplit path")
+}
+
+func TestSplitWriterDocuments(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWithOptions("json", filepath.Join(dir, "logs.json"), false, Options{Split: 2})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(4)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	// Every chunk is a complete document, including one filled exactly
+	chunks := Chunks(w)
+	require.Len(t, chunks, 2)
+	for _, chunk := range chunks {
+		data, err := os.ReadFile(chunk.Path)
+		require.NoError(t, err)
+		var doc struct {
+			Logs []json.RawMessage `json:"logs"`
+		}
+		require.NoError(t, json.Unmarshal(data, &doc), chunk.Path)
+		assert.Len(t, doc.Logs, 2)
+	}
+}
+
+func TestSplitWriterEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	w, err := NewWithOptions("ndjson", path, false, Options{Split: 10})
+	require.NoError(t, err)
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []Chunk{{Path: ChunkPath(path, 1)}}, Chunks(w))
+	assert.FileExists(t, ChunkPath(path, 1))
+}
+
+func TestFormatSyslog(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 123456789, time.UTC)
+	log := createSyslogLog("error", "web", "host 1", "boom", ts)
+
+	assert.Equal(t, "<11>1 2024-01-01T10:00:00.123456Z host_1 web - - - boom", string(formatSyslog(log, 1)))
+	assert.Equal(t, "<134>1 - - - - - -", string(formatSyslog(datadogV2.Log{}, 16)), "missing fields are nil values, info severity")
+}
+
+func TestSyslogWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w, err := NewWithOptions("ndjson", "syslog://"+conn.LocalAddr().String()+"?facility=local0", false, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		createSyslogLog("warn", "web", "host-1", "slow", ts),
+		createSyslogLog("info", "api", "host-2", "ok", ts),
+	}))
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "<132>1 2024-01-01T10:00:00.000000Z host-1 web - - - slow", string(buf[:n]))
+	n, _, err = conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "<134>1 2024-01-01T10:00:00.000000Z host-2 api - - - ok", string(buf[:n]))
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	w, err := NewSyslogWriter("syslog+tcp://" + ln.Addr().String())
+	require.NoError(t, err)
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		createSyslogLog("error", "web", "host-1", "line one\nline two", ts),
+	}))
+	require.NoError(t, w.Close())
+
+	// Octet counting keeps multi-line messages intact
+	msg := "<11>1 2024-01-01T10:00:00.000000Z host-1 web - - - line one\nline two"
+	assert.Equal(t, fmt.Sprintf("%d %s", len(msg), msg), string(<-received))
+}
+
+func TestSocketWriter(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, shorter than some TempDirs
+	dir, err := os.MkdirTemp("", "dogfetch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ingest.sock")
+
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	w, err := NewWithOptions("", "unix://"+path, false, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	lines := strings.Split(strings.TrimSpace(string(<-received)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"test message"`)
+
+	_, err = NewWithOptions("ndjson", "unix://"+filepath.Join(dir, "missing.sock"), false, Options{})
+	assert.Error(t, err)
+}
+
+func TestPipeName(t *testing.T) {
+	assert.Equal(t, `\\.\pipe\ingest`, pipeName("npipe:////./pipe/ingest"))
+	assert.Equal(t, `\\.\pipe\ingest`, pipeName("npipe://./pipe/ingest"))
+	assert.Equal(t, "", pipeName("npipe://"))
+}
+
+func TestExecWriter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands run through /bin/sh")
+	}
+	out := filepath.Join(t.TempDir(), "out.ndjson")
+
+	w, err := NewWithOptions("", "exec:cat > "+out, false, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 3)
+
+	// The command's failure fails the fetch
+	w, err = NewWithOptions("", "exec:cat > /dev/null; exit 2", false, Options{})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(1)))
+	assert.ErrorContains(t, w.Finalize(), "exit status 2")
+
+	_, err = NewWithOptions("", "exec:", false, Options{})
+	assert.ErrorContains(t, err, "no command")
+}
+
+func TestExecWriterRestarts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands run through /bin/sh")
+	}
+	defer func(d time.Duration) { execRestartDelay = d }(execRestartDelay)
+	execRestartDelay = 0
+
+	// The first run exits without reading; a page larger than a pipe's
+	// buffer can't be taken before it does
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.ndjson")
+	command := fmt.Sprintf(`if [ ! -e %[1]s/started ]; then touch %[1]s/started; exit 3; fi; cat >> %[2]s`, dir, out)
+	page := createTestLogs(2000)
+
+	var restarts []error
+	w, err := NewWithOptions("ndjson", "exec:"+command, false, Options{
+		ExecRestarts:  1,
+		OnExecRestart: func(err error) { restarts = append(restarts, err) },
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(page))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	require.Len(t, restarts, 1)
+	assert.ErrorContains(t, restarts[0], "exit status 3")
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), len(page))
+
+	// Without restarts left the write fails
+	require.NoError(t, os.Remove(filepath.Join(dir, "started")))
+	w, err = NewWithOptions("ndjson", "exec:"+command, false, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+	assert.ErrorContains(t, w.WritePage(page), "exit status 3 before the fetch was done")
+}
+
+func TestNewSyslogWriterErrors(t *testing.T) {
+	for _, rawURL := range []string{"syslog://", "syslog+http://siem:514", "syslog://siem:514?facility=nope"} {
+		_, err := NewSyslogWriter(rawURL)
+		assert.Error(t, err, rawURL)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "ab", string(truncateUTF8([]byte("ab"), 5)))
+	assert.Equal(t, "a", string(truncateUTF8([]byte("a€"), 3)), "never splits a character")
+	assert.Equal(t, "a€", string(truncateUTF8([]byte("a€b"), 4)))
+}
+
+func TestAggregateWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewAggregateWriterWithOutput(&buf, Options{
+		GroupBy:    []string{"service"},
+		Bucket:     time.Hour,
+		KThreshold: 2,
+	})
+	require.NoError(t, err)
+
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		createServiceLog("web", base.Add(5*time.Minute)),
+		createServiceLog("web", base.Add(50*time.Minute)),
+		createServiceLog("api", base.Add(10*time.Minute)),
+	}))
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		createServiceLog("web", base.Add(70*time.Minute)),
+		createServiceLog("web", base.Add(80*time.Minute)),
+		createServiceLog("web", base.Add(90*time.Minute)),
+	}))
+	require.NoError(t, w.Finalize())
+
+	var output struct {
+		Buckets []struct {
+			Time  time.Time         `json:"time"`
+			Group map[string]string `json:"group"`
+			Count int               `json:"count"`
+		} `json:"buckets"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+
+	// The lone "api" log falls under k and must not be exported
+	require.Len(t, output.Buckets, 2)
+	assert.Equal(t, base, output.Buckets[0].Time)
+	assert.Equal(t, "web", output.Buckets[0].Group["service"])
+	assert.Equal(t, 2, output.Buckets[0].Count)
+	assert.Equal(t, base.Add(time.Hour), output.Buckets[1].Time)
+	assert.Equal(t, 3, output.Buckets[1].Count)
+
+	assert.Equal(t, float64(1), output.Meta["suppressed_buckets"])
+	assert.Equal(t, float64(1), output.Meta["suppressed_logs"])
+	assert.Equal(t, float64(6), output.Meta["total_fetched"])
+	assert.NotContains(t, buf.String(), "api")
+}
+
+func TestAggregateWriterSpillsToDisk(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewAggregateWriterWithOutput(&buf, Options{
+		GroupBy:    []string{"service"},
+		Bucket:     time.Hour,
+		KThreshold: 2,
+		MaxMemory:  1,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for page := 0; page < 3; page++ {
+		require.NoError(t, w.WritePage([]datadogV2.Log{
+			createServiceLog("web", ts),
+			createServiceLog(fmt.Sprintf("svc-%d", page), ts),
+		}))
+	}
+	require.NotNil(t, w.spilled, "a 1 byte budget must spill")
+	require.NoError(t, w.Finalize())
+
+	var output struct {
+		Buckets []struct {
+			Group map[string]string `json:"group"`
+			Count int               `json:"count"`
+		} `json:"buckets"`
+		Meta map[string]interface{} `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+
+	// Partial counts from every spill are summed back together
+	require.Len(t, output.Buckets, 1)
+	assert.Equal(t, "web", output.Buckets[0].Group["service"])
+	assert.Equal(t, 3, output.Buckets[0].Count)
+	assert.Equal(t, float64(3), output.Meta["suppressed_buckets"])
+}
+
+func TestAggregateWriterWithNoise(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewAggregateWriterWithOutput(&buf, Options{
+		Bucket:     time.Hour,
+		KThreshold: 1,
+		Epsilon:    0.5,
+	})
+	require.NoError(t, err)
+	w.noise = func() float64 { return 2.4 }
+
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, w.WritePage([]datadogV2.Log{createServiceLog("web", ts)}))
+	require.NoError(t, w.Finalize())
+
+	var output map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+
+	buckets := output["buckets"].([]interface{})
+	require.Len(t, buckets, 1)
+	assert.Equal(t, float64(3), buckets[0].(map[string]interface{})["count"])
+
+	meta := output["meta"].(map[string]interface{})
+	assert.NotContains(t, meta, "total_fetched", "exact totals would leak the un-noised count")
+	assert.NotContains(t, meta, "suppressed_buckets", "so would how many true counts fell under k")
+	assert.Equal(t, 0.5, meta["epsilon"])
+}
+
+func TestAggregateWriterThresholdsNoisedCounts(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	logs := []datadogV2.Log{
+		createServiceLog("web", ts),
+		createServiceLog("api", ts), createServiceLog("api", ts), createServiceLog("api", ts),
+	}
+	tests := []struct {
+		name  string
+		noise float64
+		want  map[string]int
+	}{
+		// web's 1 log noised to 3 is shown though its true count is under k
+		{"noised up", 2.4, map[string]int{"web": 3, "api": 5}},
+		// api's 3 logs noised to 2 are suppressed though its true count isn't
+		{"noised down", -1.2, map[string]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewAggregateWriterWithOutput(&buf, Options{
+				GroupBy:    []string{"service"},
+				Bucket:     time.Hour,
+				KThreshold: 3,
+				Epsilon:    0.5,
+			})
+			require.NoError(t, err)
+			w.noise = func() float64 { return tt.noise }
+			require.NoError(t, w.WritePage(logs))
+			require.NoError(t, w.Finalize())
+
+			var output struct {
+				Buckets []aggregateBucket
+			}
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+			got := map[string]int{}
+			for _, b := range output.Buckets {
+				got[b.Group["service"]] = b.Count
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSessionWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewSessionWriterWithOutput(&buf, Options{StitchBy: "session_id"})
+	require.NoError(t, err)
+
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		createSessionLog("b", "b-2", base.Add(3*time.Minute)),
+		createSessionLog("a", "a-2", base.Add(2*time.Minute)),
+	}))
+	require.NoError(t, w.WritePage([]datadogV2.Log{
+		createSessionLog("a", "a-1", base.Add(time.Minute)),
+		createSessionLog("b", "b-1", base.Add(2*time.Minute)),
+	}))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	sessions := decodeSessions(t, buf.Bytes())
+	require.Len(t, sessions, 2)
+
+	assert.Equal(t, "a", sessions[0].Session)
+	assert.Equal(t, base.Add(time.Minute), sessions[0].Start)
+	assert.Equal(t, base.Add(2*time.Minute), sessions[0].End)
+	assert.Equal(t, 2, sessions[0].Count)
+	assert.Equal(t, []string{"a-1", "a-2"}, sessions[0].ids())
+
+	assert.Equal(t, "b", sessions[1].Session)
+	assert.Equal(t, []string{"b-1", "b-2"}, sessions[1].ids())
+}
+
+func TestSessionWriterSpillsToDisk(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewSessionWriterWithOutput(&buf, Options{StitchBy: "session_id", MaxMemory: 1024})
+	require.NoError(t, err)
+
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for page := 0; page < 10; page++ {
+		var logs []datadogV2.Log
+		for i := 0; i < 20; i++ {
+			n := page*20 + i
+			// Later pages carry earlier events so ordering has work to do
+			ts := base.Add(time.Duration(200-n) * time.Second)
+			logs = append(logs, createSessionLog(fmt.Sprintf("s%d", n%7), fmt.Sprintf("%d", n), ts))
+		}
+		require.NoError(t, w.WritePage(logs))
+	}
+	require.True(t, w.buffer.Spilled())
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	sessions := decodeSessions(t, buf.Bytes())
+	require.Len(t, sessions, 7)
+
+	total := 0
+	for _, s := range sessions {
+		total += s.Count
+		require.Len(t, s.Logs, s.Count)
+		for i := 1; i < len(s.Logs); i++ {
+			assert.False(t, s.Logs[i].Attributes.Timestamp.Before(*s.Logs[i-1].Attributes.Timestamp),
+				"session %s is out of order", s.Session)
+		}
+	}
+	assert.Equal(t, 200, total)
+}
+
+// Helper functions
+
+type testSession struct {
+	Session string          `json:"session"`
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Count   int             `json:"count"`
+	Logs    []datadogV2.Log `json:"logs"`
+}
+
+func (s testSession) ids() []string {
+	ids := make([]string, len(s.Logs))
+	for i, log := range s.Logs {
+		ids[i] = log.GetId()
+	}
+	return ids
+}
+
+func decodeSessions(t *testing.T, data []byte) []testSession {
+	t.Helper()
+	var sessions []testSession
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var s testSession
+		require.NoError(t, decoder.Decode(&s))
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func createSessionLog(sessionID, id string, ts time.Time) datadogV2.Log {
+	return datadogV2.Log{
+		Id: &id,
+		Attributes: &datadogV2.LogAttributes{
+			Attributes: map[string]interface{}{"session_id": sessionID},
+			Timestamp:  &ts,
+		},
+	}
+}
+
+func createServiceLog(service string, ts time.Time) datadogV2.Log {
+	return datadogV2.Log{
+		Attributes: &datadogV2.LogAttributes{
+			Service:   &service,
+			Timestamp: &ts,
+		},
+	}
+}
+
+func createSyslogLog(status, service, host, message string, ts time.Time) datadogV2.Log {
+	return datadogV2.Log{
+		Attributes: &datadogV2.LogAttributes{
+			Status:    &status,
+			Service:   &service,
+			Host:      &host,
+			Message:   &message,
+			Timestamp: &ts,
+		},
+	}
+}
+
+func createTestLogs(count int) []datadogV2.Log {
+	logs := make([]datadogV2.Log, count)
+	for i := 0; i < count; i++ {
+		id := "test-id"
+		message := "test message"
+		logs[i] = datadogV2.Log{
+			Id: &id,
+			Attributes: &datadogV2.LogAttributes{
+				Message: &message,
+			},
+		}
+	}
+	return logs
+}
+
+func createTempFile(t *testing.T) string {
+	t.Helper()
+	f, err := os.CreateTemp("", "dogfetch-test-*.json")
+	require.NoError(t, err)
+	path := f.Name()
+	f.Close()
+	return path
+}
+
+func TestEncryptedWriter(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	e, err := encrypt.NewEncrypter([]string{identity.Recipient().String()})
+	require.NoError(t, err)
+
+	// Compressed before it is encrypted, as the name says
+	path := filepath.Join(t.TempDir(), "logs.ndjson.gz.age")
+	w, err := NewWithOptions("", path, false, Options{Encrypt: e})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := age.Decrypt(f, identity)
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"test message"`)
+}
+
+func TestEncryptedSplitWriter(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	e, err := encrypt.NewEncrypter([]string{identity.Recipient().String()})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "logs.ndjson.gz.age")
+	w, err := NewWithOptions("", path, false, Options{Encrypt: e, Split: 2})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(3)))
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+
+	// Each chunk keeps the name's .gz, and so is compressed before it is encrypted
+	chunks := Chunks(w)
+	require.Len(t, chunks, 2)
+	assert.True(t, strings.HasSuffix(chunks[0].Path, "logs-00001.ndjson.gz.age"))
+	f, err := os.Open(chunks[0].Path)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := age.Decrypt(f, identity)
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
+
+func TestAtomicWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.ndjson.gz")
+
+	w, err := NewWithOptions("", path, false, Options{Atomic: true})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(2)))
+	assert.FileExists(t, path+".tmp")
+	assert.NoFileExists(t, path)
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+	assert.NoFileExists(t, path+".tmp")
+
+	// Still compressed, as the final name asks
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+
+	// Output that wasn't finalized, or was abandoned, isn't moved into place
+	for _, abandon := range []bool{false, true} {
+		path := filepath.Join(dir, fmt.Sprintf("abandoned-%v.ndjson", abandon))
+		w, err := NewWithOptions("", path, false, Options{Atomic: true})
+		require.NoError(t, err)
+		require.NoError(t, w.WritePage(createTestLogs(1)))
+		if abandon {
+			Abandon(w)
+			require.NoError(t, w.Finalize())
+		}
+		require.NoError(t, w.Close())
+		assert.FileExists(t, path+".tmp")
+		assert.NoFileExists(t, path)
+	}
+}
+
+func TestAtomicSplitWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	w, err := NewWithOptions("", path, false, Options{Atomic: true, Split: 2})
+	require.NoError(t, err)
+	require.NoError(t, w.WritePage(createTestLogs(3)))
+
+	// Full chunks are complete; the one being written isn't
+	assert.FileExists(t, ChunkPath(path, 1))
+	assert.FileExists(t, ChunkPath(path, 2)+".tmp")
+	Abandon(w)
+	require.NoError(t, w.Finalize())
+	require.NoError(t, w.Close())
+	assert.NoFileExists(t, ChunkPath(path, 2))
 }
@@ -0,0 +1,275 @@
+package writer
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+)
+
+// xlsxMaxRows is Excel's row limit per worksheet, header included; logs past
+// it continue on a new sheet. A variable so tests can lower it.
+var xlsxMaxRows = 1048576
+
+// xlsxMaxCell is the most characters Excel allows in a cell; longer values
+// are cut, as Excel refuses to open a workbook with them
+const xlsxMaxCell = 32767
+
+// xlsxColumns are the worksheet's columns and their widths, in characters
+var xlsxColumns = []struct {
+	name  string
+	width int
+}{
+	{"Timestamp (UTC)", 24},
+	{"Status", 10},
+	{"Service", 20},
+	{"Host", 20},
+	{"Message", 80},
+	{"Tags", 40},
+	{"ID", 36},
+	{"Attributes", 80},
+}
+
+// excelEpoch is day 0 of Excel's date serial numbers
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// XLSXWriter streams logs into an Excel workbook, one row per log
+// The workbook is a zip archive whose worksheets are written as entries as
+// logs arrive, so memory stays flat however many logs there are; the parts
+// listing the sheets follow in Finalize.
+type XLSXWriter struct {
+	out         *bufio.Writer
+	zip         *zip.Writer
+	sheet       *bufio.Writer
+	closer      io.Closer
+	sheets      int
+	rows        int
+	shouldClose bool
+}
+
+// NewXLSXWriter creates a new xlsx writer for a file
+func NewXLSXWriter(path string) (*XLSXWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bufio.NewWriter(f)
+	return &XLSXWriter{
+		out:         out,
+		zip:         zip.NewWriter(out),
+		closer:      f,
+		shouldClose: true,
+	}, nil
+}
+
+// NewXLSXWriterWithOutput creates a new xlsx writer for any io.Writer
+func NewXLSXWriterWithOutput(w io.Writer) (*XLSXWriter, error) {
+	out := bufio.NewWriter(w)
+	return &XLSXWriter{
+		out:         out,
+		zip:         zip.NewWriter(out),
+		shouldClose: false,
+	}, nil
+}
+
+// WritePage appends a row per log, starting a new sheet when one is full
+func (w *XLSXWriter) WritePage(logs []datadogV2.Log) error {
+	for _, log := range logs {
+		if w.sheets == 0 || w.rows == xlsxMaxRows {
+			if err := w.startSheet(); err != nil {
+				return err
+			}
+		}
+		w.rows++
+		if err := w.writeRow(log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finalize ends the last sheet and writes the parts that make the sheets a
+// workbook
+func (w *XLSXWriter) Finalize() error {
+	if w.sheets == 0 {
+		if err := w.startSheet(); err != nil {
+			return err
+		}
+	}
+	if err := w.endSheet(); err != nil {
+		return err
+	}
+
+	var types, sheets, rels strings.Builder
+	for i := 1; i <= w.sheets; i++ {
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxSheetName(i), i, i)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, w.sheets+1)
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+		// Style 1 shows dates with milliseconds, style 2 is the bold header
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss.000"/></numFmts>` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, part := range parts {
+		pw, err := w.create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(pw, xml.Header+part.body); err != nil {
+			return err
+		}
+	}
+
+	if err := w.zip.Close(); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// Close closes the output file (if it's a file)
+func (w *XLSXWriter) Close() error {
+	if w.shouldClose && w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+// startSheet ends the current sheet, if any, and starts the next with the
+// header row frozen at the top
+func (w *XLSXWriter) startSheet() error {
+	if w.sheets > 0 {
+		if err := w.endSheet(); err != nil {
+			return err
+		}
+	}
+	w.sheets++
+	sheet, err := w.create(fmt.Sprintf("xl/worksheets/sheet%d.xml", w.sheets))
+	if err != nil {
+		return err
+	}
+	w.sheet = bufio.NewWriter(sheet)
+	w.rows = 1
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<cols>`)
+	for i, c := range xlsxColumns {
+		fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, c.width)
+	}
+	b.WriteString(`</cols><sheetData><row r="1">`)
+	for i, c := range xlsxColumns {
+		writeXLSXString(&b, i, 1, c.name, 2)
+	}
+	b.WriteString(`</row>`)
+	_, err = io.WriteString(w.sheet, b.String())
+	return err
+}
+
+// create starts a compressed entry of the archive
+func (w *XLSXWriter) create(name string) (io.Writer, error) {
+	return w.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+}
+
+// endSheet closes the current sheet's XML
+func (w *XLSXWriter) endSheet() error {
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return w.sheet.Flush()
+}
+
+// writeRow writes a log as row w.rows of the current sheet
+func (w *XLSXWriter) writeRow(log datadogV2.Log) error {
+	attrs := log.GetAttributes()
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, w.rows)
+
+	// Timestamps are date serial numbers, so they sort and filter as dates
+	if ts, ok := attrs.GetTimestampOk(); ok {
+		days := float64(ts.UTC().Sub(excelEpoch)) / float64(24*time.Hour)
+		fmt.Fprintf(&b, `<c r="%s%d" s="1"><v>%.10f</v></c>`, xlsxColumn(0), w.rows, days)
+	}
+	writeXLSXString(&b, 1, w.rows, attrs.GetStatus(), 0)
+	writeXLSXString(&b, 2, w.rows, attrs.GetService(), 0)
+	writeXLSXString(&b, 3, w.rows, attrs.GetHost(), 0)
+	writeXLSXString(&b, 4, w.rows, attrs.GetMessage(), 0)
+	writeXLSXString(&b, 5, w.rows, strings.Join(attrs.GetTags(), ", "), 0)
+	writeXLSXString(&b, 6, w.rows, log.GetId(), 0)
+	if len(attrs.GetAttributes()) > 0 {
+		data, err := json.Marshal(attrs.GetAttributes())
+		if err != nil {
+			return err
+		}
+		writeXLSXString(&b, 7, w.rows, string(data), 0)
+	}
+	b.WriteString(`</row>`)
+
+	_, err := io.WriteString(w.sheet, b.String())
+	return err
+}
+
+// writeXLSXString writes an inline string cell, leaving empty values out
+func writeXLSXString(b *strings.Builder, col, row int, value string, style int) {
+	if value == "" {
+		return
+	}
+	if utf8.RuneCountInString(value) > xlsxMaxCell {
+		value = string([]rune(value)[:xlsxMaxCell])
+	}
+	fmt.Fprintf(b, `<c r="%s%d" t="inlineStr"`, xlsxColumn(col), row)
+	if style != 0 {
+		fmt.Fprintf(b, ` s="%d"`, style)
+	}
+	b.WriteString(`><is><t xml:space="preserve">`)
+	// Characters XML can't hold, such as most control characters, become U+FFFD
+	xml.EscapeText(b, []byte(value))
+	b.WriteString(`</t></is></c>`)
+}
+
+// xlsxColumn returns the letter of a column; there are fewer than 26
+func xlsxColumn(i int) string {
+	return string(rune('A' + i))
+}
+
+// xlsxSheetName names the nth sheet: Logs, then Logs (2) and so on
+func xlsxSheetName(n int) string {
+	if n == 1 {
+		return "Logs"
+	}
+	return fmt.Sprintf("Logs (%d)", n)
+}